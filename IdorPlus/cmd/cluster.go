@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/cluster"
+	"idorplus/pkg/notify"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var coordinatorCmd = &cobra.Command{
+	Use:   "coordinator",
+	Short: "Distribute a scan across worker nodes",
+	Long: `Split a scan into shards of payloads and hand them to 'idorplus worker'
+instances over HTTP. Findings and stats are collected into one report.
+
+Workers renew their lease on a shard with heartbeats; a shard whose worker
+stops responding is reassigned to another worker.
+
+  idorplus coordinator -u "https://api.target.com/users/{ID}" -c "session=token" \
+      -n 100000 --shard-size 2000 --listen 0.0.0.0:8788 --token s3cret
+
+  idorplus worker --coordinator http://coordinator:8788 --token s3cret
+
+Several endpoints can be scanned at once with --targets (one URL per line),
+e.g. the output of 'crawl'; targets on several hosts get one report with a
+section per host. Scan options, including cookies, are sent to the
+workers, so keep the coordinator on a trusted network and set --token.`,
+	Run: runCoordinator,
+}
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Scan shards handed out by a coordinator",
+	Long: `Lease shards from an 'idorplus coordinator' and scan them until none are left.
+
+The worker uses its own configuration and network flags, so each node can
+have its own proxies, rate limits and WAF bypass settings.`,
+	Run: runWorker,
+}
+
+func init() {
+	rootCmd.AddCommand(coordinatorCmd, workerCmd)
+
+	addTargetFlags(coordinatorCmd)
+	coordinatorCmd.Flags().String("targets", "", "File with additional target URLs, one per line")
+	coordinatorCmd.Flags().Int("shard-size", 1000, "Payloads per shard")
+	coordinatorCmd.Flags().String("listen", "127.0.0.1:8788", "Address to listen on for workers")
+	coordinatorCmd.Flags().String("token", "", "Require this bearer token from workers")
+	coordinatorCmd.Flags().Duration("lease-timeout", time.Minute, "Reassign a shard when its worker sends no heartbeat for this long")
+	coordinatorCmd.Flags().Int("max-attempts", 3, "Give up on a shard after this many failed leases")
+	coordinatorCmd.Flags().StringP("output", "o", "idor_report.json", "Output report file, - for no report and findings as JSON lines on stdout")
+	coordinatorCmd.Flags().Bool("jsonl", false, "Also stream each finding to stdout as a JSON line as soon as a worker reports it")
+	coordinatorCmd.Flags().String("format", "", "Report format: json, markdown, html, burp (default: from file extension)")
+	coordinatorCmd.Flags().Bool("no-dedup", false, "Report every finding separately instead of grouping them by fingerprint")
+	coordinatorCmd.Flags().Bool("redact", false, "Mask PII in the report, keeping only its type and count")
+	coordinatorCmd.Flags().String("report-template", "", "Go template laying out markdown and HTML reports instead of the built-in layout")
+
+	workerCmd.Flags().String("coordinator", "", "Coordinator URL, e.g. http://10.0.0.5:8788 (required)")
+	workerCmd.Flags().String("token", "", "Bearer token expected by the coordinator")
+	workerCmd.Flags().String("name", "", "Worker name shown by the coordinator (default: hostname-pid)")
+	workerCmd.MarkFlagRequired("coordinator")
+}
+
+func runCoordinator(cmd *cobra.Command, args []string) {
+	targetsFile, _ := cmd.Flags().GetString("targets")
+	shardSize, _ := cmd.Flags().GetInt("shard-size")
+	listen, _ := cmd.Flags().GetString("listen")
+	token, _ := cmd.Flags().GetString("token")
+	leaseTimeout, _ := cmd.Flags().GetDuration("lease-timeout")
+	maxAttempts, _ := cmd.Flags().GetInt("max-attempts")
+	outputFile, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+	noDedup, _ := cmd.Flags().GetBool("no-dedup")
+	jsonl, _ := cmd.Flags().GetBool("jsonl")
+	redact, _ := cmd.Flags().GetBool("redact")
+	templatePath, _ := cmd.Flags().GetString("report-template")
+
+	opts, err := targetOptions(cmd)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+
+	var urls []string
+	if opts.URL != "" {
+		urls = append(urls, opts.URL)
+	}
+	if targetsFile != "" {
+		lines, err := utils.LoadWordlist(targetsFile)
+		if err != nil {
+			utils.Error.Printf("Failed to load targets: %v\n", err)
+			return
+		}
+		urls = append(urls, lines...)
+	}
+	if len(urls) == 0 {
+		utils.Error.Println("No targets: set --url or --targets")
+		return
+	}
+
+	if len(opts.Wordlists) > 0 {
+		utils.Error.Println("Named wordlists (-w NAME=file) aren't supported by the coordinator, scan those targets directly")
+		return
+	}
+	if opts.MaxRequests > 0 || opts.MaxDuration != "" {
+		utils.Error.Println("--max-requests and --max-duration would apply to each shard, they aren't supported by the coordinator")
+		return
+	}
+
+	var shards []scanner.Options
+	for _, u := range urls {
+		target := opts
+		target.URL = u
+		payloads := scanner.EncodePayloads(target, target.Payloads)
+		if client.IsDestructiveMethod(target.Method) && len(target.CanaryIDs) > 0 {
+			payloads = target.CanaryIDs
+		}
+		if len(payloads) == 0 {
+			payloads = scanner.GeneratePayloads(target)
+		}
+		shards = append(shards, cluster.Split(target, payloads, shardSize)...)
+	}
+	utils.Info.Printf("%d targets split into %d shards of up to %d payloads\n", len(urls), len(shards), shardSize)
+
+	cfg, err := loadConfig("")
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	notifier, err := notify.NewNotifier(cfg.Notify)
+	if err != nil {
+		utils.Error.Printf("Invalid notify config: %v\n", err)
+		return
+	}
+	defer notifier.Close()
+
+	rep := reporter.NewReporter(reportFormat(format, outputFile, cfg.Output.Format))
+	rep.Dedup = !noDedup
+	rep.Redact = redact || cfg.Output.Redact
+	rep.Targets = urls
+	rep.Scan = scanner.Info(opts, cfg)
+	if len(urls) > 1 {
+		rep.Scan.Target = ""
+	}
+	if templatePath == "" {
+		templatePath = cfg.Output.Template
+	}
+	if err := loadReportTemplate(rep, templatePath); err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	rep.OnFinding = notifier.Notify
+	if silent || jsonl || outputFile == "-" {
+		rep.OnFinding = findingLines(notifier, rep.Redact)
+	}
+
+	coord := cluster.NewCoordinator(shards, rep)
+	coord.Token = token
+	coord.LeaseTimeout = leaseTimeout
+	coord.MaxAttempts = maxAttempts
+	if token == "" {
+		utils.Warning.Println("No --token set, any host that can reach the coordinator can lease shards")
+	}
+
+	httpServer := &http.Server{Addr: listen, Handler: coord, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.Error.Printf("Coordinator failed: %v\n", err)
+			os.Exit(1)
+		}
+	}()
+	utils.Success.Printf("Waiting for workers on http://%s\n", listen)
+
+	ctx, stop := signalContext()
+	defer stop()
+	if err := coord.Wait(ctx); err != nil {
+		utils.Warning.Println("\nInterrupted, writing partial report")
+	} else {
+		// Keep answering for a moment so polling workers learn the scan is over
+		time.Sleep(3 * time.Second)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	httpServer.Shutdown(shutdownCtx)
+
+	status := coord.Status()
+	stats := coord.Stats()
+	tableData := pterm.TableData{
+		{"Shards done", fmt.Sprintf("%d/%d", status.Shards[cluster.ShardDone], len(shards))},
+		{"Workers", fmt.Sprintf("%d", len(status.Workers))},
+		{"Requests", fmt.Sprintf("%d", stats.Requests)},
+		{"Failed", fmt.Sprintf("%d", stats.Failed)},
+		{"Blocked", fmt.Sprintf("%d", stats.Blocked)},
+		{"Vulnerable", fmt.Sprintf("%d", stats.Vulnerable)},
+	}
+	pterm.DefaultTable.WithData(tableData).Render()
+	for _, f := range coord.Failed() {
+		utils.Error.Printf("Failed %s\n", f)
+	}
+
+	if outputFile != "-" {
+		if err := rep.GenerateReport(outputFile); err != nil {
+			utils.Error.Printf("Failed to save report: %v\n", err)
+		} else {
+			utils.Success.Printf("Report saved to %s\n", outputFile)
+		}
+	}
+	rep.PrintSummary()
+}
+
+func runWorker(cmd *cobra.Command, args []string) {
+	coordinator, _ := cmd.Flags().GetString("coordinator")
+	token, _ := cmd.Flags().GetString("token")
+	name, _ := cmd.Flags().GetString("name")
+	if name == "" {
+		host, _ := os.Hostname()
+		name = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	cfg, err := loadConfig("")
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	defer startTracing(cfg)()
+
+	w := &cluster.Worker{
+		Coordinator: coordinator,
+		Token:       token,
+		Name:        name,
+		Config:      cfg,
+		NewClient:   newClient,
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	utils.Info.Printf("Worker %s polling %s\n", name, coordinator)
+	if err := w.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		utils.Error.Printf("Worker failed: %v\n", err)
+	}
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 
-	"idorplus/pkg/client"
 	"idorplus/pkg/crawler"
 	"idorplus/pkg/utils"
 
@@ -48,13 +47,18 @@ func runCrawl(cmd *cobra.Command, args []string) {
 	utils.Info.Printf("Depth: %d | Max Pages: %d\n", depth, maxPages)
 
 	// Load config
-	cfg, _ := utils.LoadConfig("configs/default.yaml")
-	if cfg == nil {
-		cfg = getDefaultConfig()
+	cfg, err := loadConfig(url)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
 	}
 
 	// Initialize client
-	c := client.NewSmartClient(cfg)
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
 	if cookies != "" {
 		c.GetSessionManager().AddSession("crawler", cookies)
 	}
@@ -67,7 +71,9 @@ func runCrawl(cmd *cobra.Command, args []string) {
 	// Start crawling with spinner
 	spinner, _ := pterm.DefaultSpinner.Start("Crawling target...")
 
-	endpoints := cr.Crawl(url)
+	ctx, stop := signalContext()
+	defer stop()
+	endpoints := cr.Crawl(ctx, url)
 
 	spinner.Success(fmt.Sprintf("Found %d endpoints", len(endpoints)))
 
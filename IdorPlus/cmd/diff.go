@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old_report.json> <new_report.json>",
+	Short: "Compare two scan reports",
+	Long: `Show which findings are new, fixed or persisting between two JSON reports.
+
+Findings are matched by fingerprint (endpoint template, method, type and
+response shape), so the same vulnerability found with different IDs in each
+scan counts as persisting.
+
+  idorplus diff last_week.json idor_report.json
+  idorplus diff old.json new.json -o diff.json --fail-on-new`,
+	Args: cobra.ExactArgs(2),
+	Run:  runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("output", "o", "", "Write the diff as JSON to this file")
+	diffCmd.Flags().Bool("fail-on-new", false, "Exit with status 1 if there are new findings (for CI)")
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	failOnNew, _ := cmd.Flags().GetBool("fail-on-new")
+
+	older, err := reporter.LoadReport(args[0])
+	if err != nil {
+		utils.Error.Printf("Failed to load report: %v\n", err)
+		os.Exit(1)
+	}
+	newer, err := reporter.LoadReport(args[1])
+	if err != nil {
+		utils.Error.Printf("Failed to load report: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff := reporter.DiffReports(older, newer)
+
+	printDiffSection(pterm.Red("New"), diff.New)
+	printDiffSection(pterm.Green("Fixed"), diff.Fixed)
+	printDiffSection(pterm.Yellow("Persisting"), diff.Persisting)
+
+	utils.Info.Printf("%d new, %d fixed, %d persisting\n", len(diff.New), len(diff.Fixed), len(diff.Persisting))
+
+	if output != "" {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err == nil {
+			err = os.WriteFile(output, data, 0644)
+		}
+		if err != nil {
+			utils.Error.Printf("Failed to write diff: %v\n", err)
+		} else {
+			utils.Success.Printf("Diff saved to %s\n", output)
+		}
+	}
+
+	if failOnNew && len(diff.New) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printDiffSection(title string, findings []*reporter.Finding) {
+	pterm.DefaultSection.Printf("%s (%d)\n", title, len(findings))
+	if len(findings) == 0 {
+		return
+	}
+
+	tableData := pterm.TableData{
+		{"Fingerprint", "Type", "Method", "URL", "Severity", "IDs"},
+	}
+	for _, f := range findings {
+		ids := f.IDRange
+		if ids == "" {
+			ids = f.Payload
+		}
+		endpoint := f.Endpoint
+		if endpoint == "" {
+			endpoint = f.URL
+		}
+		tableData = append(tableData, []string{
+			f.Fingerprint,
+			f.Type,
+			f.Method,
+			endpoint,
+			fmt.Sprintf("%s %.1f", f.Severity, f.CVSSScore),
+			ids,
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
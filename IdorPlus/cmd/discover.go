@@ -3,12 +3,21 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 
+	"idorplus/pkg/analyzer"
 	"idorplus/pkg/client"
 	"idorplus/pkg/crawler"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/firebase"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/storage"
 	"idorplus/pkg/utils"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -24,6 +33,23 @@ This command crawls the target and extracts:
   - Internal/admin endpoints
   - Endpoints with ID parameters (IDOR candidates)
 
+With --classify each GET IDOR candidate is probed, its parameters filled
+with 1, and labelled object, collection, static, login, denied or other,
+with whether it requires auth. Export, report and download endpoints are
+labelled download, test them with 'idorplus download'.
+
+When endpoints live under versioned roots such as /api/v1/ and /api/v2/,
+the versions seen are listed. With --versions every GET endpoint is also
+requested under its sibling versions: the others seen, the next one, and
+the unlisted /v0, /beta and /internal. A version that answers while the
+discovered one denies access is flagged, unless it answers a random path
+the same way.
+
+With --methods each IDOR candidate gets an OPTIONS request and is listed
+once per method its Allow or Access-Control-Allow-Methods header names,
+so only supported verbs are scanned. Candidates allowing PUT, PATCH or
+DELETE that discovery only saw requested otherwise are flagged.
+
 Example:
   idorplus discover -u "https://target.com" -d 3 --js-only`,
 	Run: runDiscover,
@@ -39,6 +65,9 @@ func init() {
 	discoverCmd.Flags().Bool("js-only", false, "Only parse JavaScript files")
 	discoverCmd.Flags().Bool("internal", false, "Show only internal/admin endpoints")
 	discoverCmd.Flags().Bool("idor", false, "Show only endpoints with ID parameters")
+	discoverCmd.Flags().Bool("methods", false, "Ask each IDOR candidate which methods it allows with OPTIONS and list the requests to scan per method")
+	discoverCmd.Flags().Bool("versions", false, "Probe the sibling versions (/v1, /v0, /beta, /internal...) of endpoints under versioned APIs")
+	discoverCmd.Flags().Bool("classify", false, "Probe the IDOR candidates and label what they return (object, collection, static, login...)")
 
 	discoverCmd.MarkFlagRequired("url")
 }
@@ -51,17 +80,25 @@ func runDiscover(cmd *cobra.Command, args []string) {
 	jsOnly, _ := cmd.Flags().GetBool("js-only")
 	internalOnly, _ := cmd.Flags().GetBool("internal")
 	idorOnly, _ := cmd.Flags().GetBool("idor")
+	classify, _ := cmd.Flags().GetBool("classify")
+	probeVersions, _ := cmd.Flags().GetBool("versions")
+	enumerate, _ := cmd.Flags().GetBool("methods")
 
 	utils.Info.Printf("Target: %s\n", url)
 	utils.Info.Printf("Depth: %d\n", depth)
 
 	// Initialize
-	cfg, _ := utils.LoadConfig("configs/default.yaml")
-	if cfg == nil {
-		cfg = getDefaultConfig()
+	cfg, err := loadConfig(url)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
 	}
 
-	c := client.NewSmartClient(cfg)
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
 	if cookies != "" {
 		c.GetSessionManager().AddSession("crawler", cookies)
 	}
@@ -74,19 +111,22 @@ func runDiscover(cmd *cobra.Command, args []string) {
 	cr.Depth = depth
 	cr.MaxPages = 50
 
+	ctx, stop := signalContext()
+	defer stop()
 	spinner, _ := pterm.DefaultSpinner.Start("Crawling target...")
 
 	// Crawl and collect content
-	pages := cr.Crawl(url)
+	pages := cr.Crawl(ctx, url)
 	spinner.UpdateText(fmt.Sprintf("Processing %d pages...", len(pages)))
 
 	// For each discovered page, fetch and parse
-	ctx := context.Background()
+	var projects []firebase.Project
+	var objects []storage.Object
 	for _, pageURL := range pages {
 		// Rate limit to avoid WAF triggers
 		c.GetRateLimiter().Wait(ctx)
 
-		resp, err := c.Request().Get(pageURL)
+		resp, err := c.Request(ctx).Get(pageURL)
 		if err != nil {
 			continue
 		}
@@ -95,6 +135,14 @@ func runDiscover(cmd *cobra.Command, args []string) {
 		contentType := resp.Header().Get("Content-Type")
 
 		// Parse based on content type
+		if !strings.Contains(contentType, "json") {
+			projects = firebase.MergeProjects(projects, firebase.DetectProjects(body)...)
+		}
+		for _, obj := range storage.FindObjects(body) {
+			if !slices.ContainsFunc(objects, func(o storage.Object) bool { return o.URL == obj.URL }) {
+				objects = append(objects, obj)
+			}
+		}
 		if strings.Contains(contentType, "javascript") || strings.HasSuffix(pageURL, ".js") {
 			discoverer.ExtractFromJS(body, pageURL)
 		} else if strings.Contains(contentType, "html") && !jsOnly {
@@ -119,11 +167,33 @@ func runDiscover(cmd *cobra.Command, args []string) {
 		endpoints = discoverer.GetAllEndpoints()
 	}
 
+	// Firebase configs point at databases whose rules are worth testing
+	if len(projects) > 0 {
+		pterm.DefaultSection.Printf("🔥 Firebase Projects (%d)\n", len(projects))
+		for _, p := range projects {
+			pterm.Printf("  %s %s\n", p.ID, p.DatabaseURL)
+		}
+		utils.Info.Println("Test their rules with: idorplus firebase -u " + url)
+	}
+
+	// So do buckets behind pre-signed and public storage links
+	if len(objects) > 0 {
+		pterm.DefaultSection.Printf("☁️  Cloud Storage URLs (%d)\n", len(objects))
+		for _, obj := range objects {
+			signed := ""
+			if obj.Signed {
+				signed = " (signed)"
+			}
+			pterm.Printf("  [%s] %s/%s%s\n", obj.Provider, obj.Bucket, obj.Key, signed)
+		}
+		utils.Info.Println("Test them with: idorplus storage --object URL")
+	}
+
 	// Display results
 	utils.PrintSection("Discovered Endpoints")
 
 	if len(endpoints) == 0 {
-		pterm.Warning.Println("No endpoints discovered")
+		utils.Warning.Println("No endpoints discovered")
 		return
 	}
 
@@ -139,6 +209,36 @@ func runDiscover(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	var classes map[string]*analyzer.EndpointClass
+	if classify && len(idorEps) > 0 {
+		spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Classifying %d IDOR candidates...", len(idorEps)))
+		classes = classifyEndpoints(ctx, c, url, idorEps, cookies != "")
+		spinner.Success(fmt.Sprintf("Classified %d IDOR candidates", len(classes)))
+	}
+	var methods map[string]*detector.MethodSupport
+	if enumerate && len(idorEps) > 0 {
+		spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Sending OPTIONS to %d IDOR candidates...", len(idorEps)))
+		methods = enumerateMethods(ctx, c, url, idorEps, cookies != "")
+		spinner.Success(fmt.Sprintf("%d of %d IDOR candidates list their methods", len(methods), len(idorEps)))
+	}
+	label := func(ep crawler.EndpointInfo) string {
+		var tags []string
+		// Exports and downloads have their own test, see 'idorplus download'
+		if detector.IsDownloadEndpoint(ep.URL) {
+			tags = append(tags, "download")
+		}
+		if ec := classes[ep.URL]; ec != nil {
+			tags = append(tags, ec.String())
+		}
+		if ms := methods[ep.URL]; ms != nil {
+			tags = append(tags, "allows "+strings.Join(ms.Methods(), " "))
+		}
+		if len(tags) == 0 {
+			return ""
+		}
+		return " [" + strings.Join(tags, ", ") + "]"
+	}
+
 	// Show internal endpoints first (high value)
 	if len(internalEps) > 0 {
 		pterm.DefaultSection.Printf("🔴 Internal/Admin Endpoints (%d)\n", len(internalEps))
@@ -152,7 +252,7 @@ func runDiscover(cmd *cobra.Command, args []string) {
 		pterm.DefaultSection.Printf("🟡 IDOR Candidates (%d)\n", len(idorEps))
 		for _, ep := range idorEps {
 			params := strings.Join(ep.ParamNames, ", ")
-			pterm.Printf("  [%s] %s (params: %s)\n", ep.Method, ep.URL, params)
+			pterm.Printf("  [%s] %s (params: %s)%s\n", ep.Method, ep.URL, params, label(ep))
 		}
 	}
 
@@ -171,6 +271,37 @@ func runDiscover(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// GET scans leave the writes and deletes of an endpoint untested
+	if len(methods) > 0 {
+		for _, ep := range idorEps {
+			if ms := methods[ep.URL]; ms != nil {
+				if untested := ms.Untested(ep.Method); len(untested) > 0 {
+					utils.Warning.Printf("%s allows %s, untested by a %s scan\n", ep.URL, strings.Join(untested, ", "), ep.Method)
+				}
+			}
+		}
+	}
+
+	// Older, beta and internal versions often lack the newest one's checks
+	versions := crawler.BuildVersionMap(discoverer.GetAllEndpoints())
+	var versionResults []*detector.TamperResult
+	if len(versions.Roots) > 0 {
+		pterm.DefaultSection.Printf("🧭 API Versions (%d roots)\n", len(versions.Roots))
+		for _, root := range versions.Roots {
+			var seen []string
+			for _, v := range root.Versions {
+				seen = append(seen, fmt.Sprintf("%s (%d)", v, root.Endpoints[v]))
+			}
+			pterm.Printf("  %s/{version}: %s, canonical %s\n", root.Prefix, strings.Join(seen, ", "), root.Canonical)
+		}
+		if probeVersions {
+			spinner, _ := pterm.DefaultSpinner.Start("Probing sibling versions...")
+			versionResults = probeSiblingVersions(ctx, c, url, discoverer.GetAllEndpoints(), versions, cookies != "")
+			spinner.Success(fmt.Sprintf("Probed the sibling versions of %d endpoints", len(versionResults)))
+			printVersionBypasses(versionResults)
+		}
+	}
+
 	// Save to file
 	var outputContent strings.Builder
 	outputContent.WriteString("# Discovered API Endpoints\n\n")
@@ -186,7 +317,25 @@ func runDiscover(cmd *cobra.Command, args []string) {
 	if len(idorEps) > 0 {
 		outputContent.WriteString("## IDOR Candidates\n")
 		for _, ep := range idorEps {
-			outputContent.WriteString(fmt.Sprintf("%s %s # params: %s\n", ep.Method, ep.URL, strings.Join(ep.ParamNames, ",")))
+			outputContent.WriteString(fmt.Sprintf("%s %s # params: %s%s\n", ep.Method, ep.URL, strings.Join(ep.ParamNames, ","), label(ep)))
+		}
+		outputContent.WriteString("\n")
+	}
+
+	if len(methods) > 0 {
+		outputContent.WriteString("## Methods\n")
+		for _, ep := range idorEps {
+			for _, line := range methodRequests(ep, methods[ep.URL]) {
+				outputContent.WriteString(line + "\n")
+			}
+		}
+		outputContent.WriteString("\n")
+	}
+
+	if answered := answeringVersions(versionResults); len(answered) > 0 {
+		outputContent.WriteString("## API Versions\n")
+		for _, line := range answered {
+			outputContent.WriteString(line + "\n")
 		}
 		outputContent.WriteString("\n")
 	}
@@ -213,3 +362,178 @@ func runDiscover(cmd *cobra.Command, args []string) {
 	}
 	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
 }
+
+// oneParam sets every path parameter to 1
+func oneParam(string) string { return "1" }
+
+// classifyEndpoints probes the GET endpoints of eps, resolved against
+// target with their path parameters set to 1, and returns their classes
+// by URL. With the crawler session they are probed with and without its
+// cookies.
+func classifyEndpoints(ctx context.Context, c *client.SmartClient, target string, eps []crawler.EndpointInfo, session bool) map[string]*analyzer.EndpointClass {
+	base, err := url.Parse(target)
+	if err != nil {
+		return nil
+	}
+	classes := make(map[string]*analyzer.EndpointClass)
+	for _, ep := range eps {
+		if ep.Method != "" && !strings.EqualFold(ep.Method, "GET") {
+			continue
+		}
+		u, err := base.Parse(ep.URL)
+		if err != nil {
+			continue
+		}
+		u.Path, u.RawPath = utils.ReplacePathParams(u.Path, oneParam), ""
+
+		c.GetRateLimiter().Wait(ctx)
+		req := c.Request(ctx)
+		if session {
+			fuzzer.PrepareRequest(c, req, &fuzzer.FuzzJob{Session: "crawler"})
+		}
+		resp, err := req.Get(u.String())
+		if err != nil {
+			continue
+		}
+		var anon *resty.Response
+		if session {
+			if anon, err = c.Request(ctx).Get(u.String()); err != nil {
+				continue
+			}
+		}
+		classes[ep.URL] = analyzer.ClassifyEndpoint(resp, anon)
+	}
+	return classes
+}
+
+// enumerateMethods sends OPTIONS to the endpoints, resolved against target
+// and their parameters filled with 1, and returns what those that list
+// their methods allow by endpoint URL
+func enumerateMethods(ctx context.Context, c *client.SmartClient, target string, eps []crawler.EndpointInfo, session bool) map[string]*detector.MethodSupport {
+	base, err := url.Parse(target)
+	if err != nil {
+		return nil
+	}
+	sessionName := ""
+	if session {
+		sessionName = "crawler"
+	}
+	me := detector.NewMethodEnumerator(c)
+	methods := make(map[string]*detector.MethodSupport)
+	for _, ep := range eps {
+		if ctx.Err() != nil {
+			break
+		}
+		u, err := base.Parse(ep.URL)
+		if err != nil {
+			continue
+		}
+		u.Path, u.RawPath = utils.ReplacePathParams(u.Path, oneParam), ""
+
+		c.GetRateLimiter().Wait(ctx)
+		if ms, err := me.Enumerate(ctx, u.String(), sessionName); err == nil && ms.Known() {
+			methods[ep.URL] = ms
+		}
+	}
+	return methods
+}
+
+// methodRequests lists an endpoint once per method it allows, the requests
+// worth scanning, and as discovered when its methods are unknown
+func methodRequests(ep crawler.EndpointInfo, ms *detector.MethodSupport) []string {
+	params := strings.Join(ep.ParamNames, ",")
+	if ms == nil {
+		return []string{fmt.Sprintf("%s %s # params: %s", ep.Method, ep.URL, params)}
+	}
+	var lines []string
+	for _, m := range ms.Methods() {
+		switch {
+		case m == "HEAD" || m == "OPTIONS" || m == "TRACE" || m == "CONNECT":
+			continue
+		case slices.Contains(detector.DangerousMethods, m):
+			lines = append(lines, fmt.Sprintf("%s %s # params: %s, scan with --allow-destructive --canary", m, ep.URL, params))
+		default:
+			lines = append(lines, fmt.Sprintf("%s %s # params: %s", m, ep.URL, params))
+		}
+	}
+	return lines
+}
+
+// maxVersionProbes bounds the endpoints whose sibling versions are probed
+const maxVersionProbes = 50
+
+// probeSiblingVersions requests the GET endpoints under versioned APIs,
+// resolved against target and their parameters filled with 1, and each of
+// their sibling versions
+func probeSiblingVersions(ctx context.Context, c *client.SmartClient, target string, eps []crawler.EndpointInfo, versions *crawler.VersionMap, session bool) []*detector.TamperResult {
+	base, err := url.Parse(target)
+	if err != nil {
+		return nil
+	}
+	sessionName := ""
+	if session {
+		sessionName = "crawler"
+	}
+	slices.SortFunc(eps, func(a, b crawler.EndpointInfo) int { return strings.Compare(a.URL, b.URL) })
+
+	tester := detector.NewAPIVersionTester(c)
+	var results []*detector.TamperResult
+	seen := make(map[string]bool)
+	for _, ep := range eps {
+		if len(results) >= maxVersionProbes || ctx.Err() != nil {
+			break
+		}
+		if ep.Method != "" && !strings.EqualFold(ep.Method, "GET") {
+			continue
+		}
+		u, err := base.Parse(ep.URL)
+		if err != nil {
+			continue
+		}
+		u.Path, u.RawPath = utils.ReplacePathParams(u.Path, oneParam), ""
+		endpoint := u.String()
+		siblings := versions.Siblings(endpoint)
+		if len(siblings) == 0 || seen[endpoint] {
+			continue
+		}
+		seen[endpoint] = true
+		results = append(results, tester.TestVariants(ctx, endpoint, "GET", sessionName, siblings))
+	}
+	return results
+}
+
+// printVersionBypasses prints the sibling versions that answer while the
+// discovered version denies access
+func printVersionBypasses(results []*detector.TamperResult) {
+	found := 0
+	for _, r := range results {
+		if !detector.IsDenied(r.BaselineStatus) {
+			continue
+		}
+		for _, a := range r.Attempts {
+			if a.Bypassed {
+				found++
+				utils.Error.Printf("⚠️  [%s] %s answers %d while %s is denied (%d)\n", a.Technique, a.URL, a.StatusCode, r.URL, r.BaselineStatus)
+			}
+		}
+	}
+	if found > 0 {
+		utils.Info.Println("Test them with: idorplus scan --api-versions, or scan the answering version directly")
+	}
+}
+
+// answeringVersions lists the sibling versions that exist, i.e. answer
+// anything but 404 or 405. A 2xx that was not kept as a bypass is the
+// answer of a version to any path, see APIVersionTester.
+func answeringVersions(results []*detector.TamperResult) []string {
+	var lines []string
+	for _, r := range results {
+		for _, a := range r.Attempts {
+			catchAll := a.StatusCode >= 200 && a.StatusCode < 300 && !a.Bypassed
+			if a.StatusCode != 0 && a.StatusCode != http.StatusNotFound && a.StatusCode != http.StatusMethodNotAllowed && !catchAll {
+				lines = append(lines, fmt.Sprintf("GET %s # %s, %d (discovered version %d)", a.URL, a.Technique, a.StatusCode, r.BaselineStatus))
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/detector"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var downloadCmd = &cobra.Command{
+	Use:   "download",
+	Short: "Test export, report and download endpoints for other users' files",
+	Long: `Download the files an export, report or download endpoint generates for
+other users' IDs, e.g. /export?id={ID}, /reports/{ID}.pdf or
+/invoices/{ID}/download.
+
+The attacker's own file (--own-id) and the file served for an ID that
+doesn't exist are downloaded first. Another ID is vulnerable when it
+returns a different file; PDFs, images, Office documents and CSV exports
+are compared by hash, and their author, creator and email fields are
+reported as evidence of whose file it is. With -C the victim downloads
+the file too, confirming it is theirs.
+
+Without -u, --base tries common export and download paths below a URL
+with --own-id and tests those that return a file.
+
+Example:
+  idorplus download -u "https://app.target.com/invoices/{ID}/download" -c "session=token" --own-id 1041
+  idorplus download --base "https://app.target.com/api" -c "session=token" --own-id 1041 --ids 1040,1042`,
+	Run: runDownload,
+}
+
+func init() {
+	rootCmd.AddCommand(downloadCmd)
+
+	downloadCmd.Flags().StringP("url", "u", "", "Download URL with {ID}")
+	downloadCmd.Flags().String("base", "", "Base URL to try common export and download paths below")
+	downloadCmd.Flags().StringP("cookies", "c", "", "Attacker session cookies")
+	downloadCmd.Flags().StringP("cookies-b", "C", "", "Victim session cookies, to confirm downloaded files are theirs")
+	downloadCmd.Flags().StringArrayP("header", "H", nil, "Custom headers")
+	downloadCmd.Flags().StringP("auth", "a", "", "Bearer token for Authorization header")
+	downloadCmd.Flags().String("own-id", "", "ID of a file you own")
+	downloadCmd.Flags().StringSlice("ids", nil, "IDs to download (default: the 5 numbers below and above --own-id)")
+	downloadCmd.Flags().StringP("output", "o", "", "Also save the findings as a report to this file")
+	downloadCmd.Flags().String("format", "", "Report format: json, markdown, html, burp (default: from file extension)")
+}
+
+func runDownload(cmd *cobra.Command, args []string) {
+	target, _ := cmd.Flags().GetString("url")
+	base, _ := cmd.Flags().GetString("base")
+	cookies, _ := cmd.Flags().GetString("cookies")
+	cookiesB, _ := cmd.Flags().GetString("cookies-b")
+	headerFlags, _ := cmd.Flags().GetStringArray("header")
+	bearer, _ := cmd.Flags().GetString("auth")
+	ownID, _ := cmd.Flags().GetString("own-id")
+	ids, _ := cmd.Flags().GetStringSlice("ids")
+	outputFile, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+
+	switch {
+	case target == "" && base == "":
+		utils.Error.Println("Pass a download URL with -u or a base URL with --base")
+		return
+	case target != "" && !strings.Contains(target, "{ID}"):
+		utils.Error.Println("The download URL has no {ID}")
+		return
+	case base != "" && ownID == "":
+		utils.Error.Println("--base needs --own-id to find the paths that return your files")
+		return
+	}
+	if len(ids) == 0 {
+		if ids = neighborIDs(ownID, 5); len(ids) == 0 {
+			utils.Error.Println("No IDs to download, pass --ids or a numeric --own-id")
+			return
+		}
+	}
+
+	cfgTarget := target
+	if cfgTarget == "" {
+		cfgTarget = base
+	}
+	cfg, err := loadConfig(cfgTarget)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	headers := make(map[string]string)
+	for _, h := range headerFlags {
+		if key, val, ok := strings.Cut(h, ":"); ok {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+	}
+	if bearer != "" {
+		headers["Authorization"] = "Bearer " + bearer
+	}
+
+	dt := detector.NewDownloadTester(c)
+	if cookies != "" {
+		c.GetSessionManager().AddSession("attacker", cookies)
+		dt.Session = "attacker"
+	}
+	if cookiesB != "" {
+		c.GetSessionManager().AddSession("victim", cookiesB)
+		dt.VictimSession = "victim"
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	targets := []string{target}
+	if target == "" {
+		utils.Info.Printf("Trying %d export and download paths below %s\n", len(detector.DownloadPatterns), base)
+		if targets = dt.Discover(ctx, base, headers, ownID); len(targets) == 0 {
+			utils.Warning.Println("None of the paths returns a file for --own-id")
+			return
+		}
+	}
+
+	rep := reporter.NewReporter(reportFormat(format, outputFile, cfg.Output.Format))
+	vulnerable := 0
+	for _, tmpl := range targets {
+		result := dt.Test(ctx, tmpl, headers, ownID, ids)
+		dt.PrintResult(result)
+		if ownID != "" && result.Own == nil {
+			utils.Warning.Printf("Your own ID %s doesn't download a file (%d), every file counts as another user's\n", ownID, result.OwnStatus)
+		}
+		scanner.RecordDownloads(rep, result)
+		for _, d := range result.Downloads {
+			if d.Vulnerable {
+				vulnerable++
+			}
+		}
+	}
+	if outputFile != "" {
+		if err := rep.GenerateReport(outputFile); err != nil {
+			utils.Error.Printf("Failed to save report: %v\n", err)
+		} else {
+			utils.Success.Printf("Report saved to %s\n", outputFile)
+		}
+	}
+
+	if vulnerable > 0 {
+		utils.Error.Printf("%d files of other users downloaded\n", vulnerable)
+	} else {
+		utils.Success.Println("No other users' files downloaded")
+	}
+}
+
+// neighborIDs returns the n numbers below and above a numeric id, nil for
+// other IDs
+func neighborIDs(id string, n int) []string {
+	own, err := strconv.Atoi(id)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for i := own - n; i <= own+n; i++ {
+		if i >= 0 && i != own {
+			ids = append(ids, strconv.Itoa(i))
+		}
+	}
+	return ids
+}
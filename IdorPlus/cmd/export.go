@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"strings"
+
+	"idorplus/pkg/export"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Send the findings of a JSON report to an issue tracker",
+	Long: `Send the findings of a JSON report to DefectDojo or Jira, configured in the
+config's export section. Credentials are best kept in the environment:
+
+  IDORPLUS_EXPORT_DEFECTDOJO_API_KEY=... idorplus export defectdojo idor_report.json
+  IDORPLUS_EXPORT_JIRA_TOKEN=... idorplus export jira idor_report.json
+
+DefectDojo receives the report as a new test of the engagement and
+deduplicates findings itself. Jira gets an issue per finding, labelled with
+the finding's fingerprint so that exporting the next scan's report only
+files the new ones. min_severity (DefectDojo: LOW, Jira: MEDIUM) can be
+raised for one export with --set, e.g. --set export.jira.min_severity=HIGH.`,
+}
+
+var exportDefectDojoCmd = &cobra.Command{
+	Use:   "defectdojo <report.json>",
+	Short: "Import a report into a DefectDojo engagement",
+	Args:  cobra.ExactArgs(1),
+	Run:   runExportDefectDojo,
+}
+
+var exportJiraCmd = &cobra.Command{
+	Use:   "jira <report.json>",
+	Short: "Create a Jira issue per finding not filed yet",
+	Args:  cobra.ExactArgs(1),
+	Run:   runExportJira,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportDefectDojoCmd, exportJiraCmd)
+}
+
+// loadExport loads the config and the report to export
+func loadExport(path string) (*utils.Config, *reporter.Report, bool) {
+	cfg, err := loadConfig("")
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return nil, nil, false
+	}
+	report, err := reporter.LoadReport(path)
+	if err != nil {
+		utils.Error.Printf("Failed to load report: %v\n", err)
+		return nil, nil, false
+	}
+	return cfg, report, true
+}
+
+func runExportDefectDojo(cmd *cobra.Command, args []string) {
+	exitCode = exitError
+	cfg, report, ok := loadExport(args[0])
+	if !ok {
+		return
+	}
+	dojo, err := export.NewDefectDojo(cfg.Export.DefectDojo)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	test, err := dojo.Import(report)
+	if err != nil {
+		utils.Error.Printf("DefectDojo import failed: %v\n", err)
+		return
+	}
+	exitCode = exitClean
+	utils.Success.Printf("Imported %s into DefectDojo as test %d\n", args[0], test)
+}
+
+func runExportJira(cmd *cobra.Command, args []string) {
+	exitCode = exitError
+	cfg, report, ok := loadExport(args[0])
+	if !ok {
+		return
+	}
+	jira, err := export.NewJira(cfg.Export.Jira)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	result, err := jira.Export(report.Findings)
+	if len(result.Created) > 0 {
+		utils.Success.Printf("Created %d Jira issues: %s\n", len(result.Created), strings.Join(result.Created, ", "))
+	}
+	if len(result.Existing) > 0 {
+		utils.Info.Printf("%d findings already filed: %s\n", len(result.Existing), strings.Join(result.Existing, ", "))
+	}
+	if err != nil {
+		utils.Error.Printf("Jira export failed: %v\n", err)
+		return
+	}
+	exitCode = exitClean
+	if len(result.Created) == 0 && len(result.Existing) == 0 {
+		utils.Info.Println("No findings at or above the minimum severity")
+	}
+}
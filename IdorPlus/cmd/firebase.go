@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/firebase"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var firebaseCmd = &cobra.Command{
+	Use:   "firebase",
+	Short: "Test Firebase Realtime Database and Firestore rules",
+	Long: `Test the security rules of Firebase Realtime Database and Firestore
+REST endpoints for reads they shouldn't allow.
+
+The project is detected from the config in the target's HTML and scripts
+(databaseURL, projectId, apiKey), or given with --project and --database.
+Then:
+  - the database root and common paths are read with ?shallow=true
+  - the first documents of common Firestore collections are listed
+  - with --docs, documents such as users/{ID} are read for every --ids
+
+Each read is made anonymously and with every --token, Firebase ID tokens
+of test users. Nothing is written.
+
+Example:
+  idorplus firebase -u "https://app.target.com"
+  idorplus firebase --project target-app -t alice=eyJ... --docs "users/{ID}" --ids uid1,uid2`,
+	Run: runFirebase,
+}
+
+func init() {
+	rootCmd.AddCommand(firebaseCmd)
+
+	firebaseCmd.Flags().StringP("url", "u", "", "Page whose HTML and scripts configure Firebase")
+	firebaseCmd.Flags().String("project", "", "Firebase project ID")
+	firebaseCmd.Flags().String("database", "", "Realtime Database URL, e.g. https://target-default-rtdb.firebaseio.com")
+	firebaseCmd.Flags().String("api-key", "", "Web API key of the project")
+	firebaseCmd.Flags().StringArrayP("token", "t", nil, "Firebase ID token of a test user as NAME=token (repeatable)")
+	firebaseCmd.Flags().StringSlice("paths", firebase.DefaultPaths, "Database paths and Firestore collections to probe")
+	firebaseCmd.Flags().String("docs", "", "Document path with {ID} to read for every --ids, e.g. users/{ID}")
+	firebaseCmd.Flags().StringSlice("ids", nil, "IDs for --docs, e.g. the UIDs of other test users")
+}
+
+// scriptSrc matches the external scripts of a page
+var scriptSrc = regexp.MustCompile(`<script[^>]+src=["']([^"']+)["']`)
+
+func runFirebase(cmd *cobra.Command, args []string) {
+	target, _ := cmd.Flags().GetString("url")
+	projectID, _ := cmd.Flags().GetString("project")
+	database, _ := cmd.Flags().GetString("database")
+	apiKey, _ := cmd.Flags().GetString("api-key")
+	tokenFlags, _ := cmd.Flags().GetStringArray("token")
+	paths, _ := cmd.Flags().GetStringSlice("paths")
+	docs, _ := cmd.Flags().GetString("docs")
+	ids, _ := cmd.Flags().GetStringSlice("ids")
+
+	var tokens []firebase.Token
+	for _, t := range tokenFlags {
+		name, value, ok := strings.Cut(t, "=")
+		if !ok || name == "" || value == "" {
+			utils.Error.Printf("Invalid --token %q, expected NAME=token\n", t)
+			return
+		}
+		tokens = append(tokens, firebase.Token{Name: name, Value: value})
+	}
+	if docs != "" && len(ids) == 0 {
+		utils.Error.Println("--docs needs --ids")
+		return
+	}
+
+	cfg, err := loadConfig(target)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	var projects []firebase.Project
+	if projectID != "" || database != "" {
+		projects = append(projects, firebase.Project{ID: projectID, DatabaseURL: database, APIKey: apiKey})
+	}
+	if target != "" {
+		utils.Info.Printf("Looking for Firebase config in %s\n", target)
+		projects = firebase.MergeProjects(projects, detectFirebase(ctx, c, target)...)
+	}
+	if len(projects) == 0 {
+		utils.Error.Println("No Firebase project found, pass --project or --database")
+		return
+	}
+
+	tester := firebase.NewTester(c, tokens)
+	for _, p := range projects {
+		utils.PrintSection("Firebase project " + p.ID)
+		if p.DatabaseURL != "" {
+			utils.Info.Printf("Realtime Database: %s\n", p.DatabaseURL)
+		}
+		if p.APIKey != "" {
+			utils.Info.Printf("API key: %s\n", p.APIKey)
+		}
+
+		var results []firebase.PathResult
+		if p.DatabaseURL != "" {
+			r, err := tester.ProbeDatabase(ctx, p.DatabaseURL, paths)
+			results = append(results, r...)
+			if err != nil {
+				utils.Error.Printf("Database probe stopped: %v\n", err)
+			}
+		}
+		if p.ID != "" {
+			r, err := tester.ProbeFirestore(ctx, p, paths)
+			results = append(results, r...)
+			if err != nil {
+				utils.Error.Printf("Firestore probe stopped: %v\n", err)
+			}
+		}
+		if docs != "" {
+			r, err := tester.FuzzDocuments(ctx, p, docs, ids)
+			results = append(results, r...)
+			if err != nil {
+				utils.Error.Printf("Document fuzzing stopped: %v\n", err)
+			}
+		}
+		printFirebaseResults(tokens, results)
+	}
+}
+
+// detectFirebase fetches a page and its scripts and returns the Firebase
+// projects they configure
+func detectFirebase(ctx context.Context, c *client.SmartClient, target string) []firebase.Project {
+	resp, err := c.Request(ctx).Get(target)
+	if err != nil {
+		utils.Warning.Printf("Failed to fetch %s: %v\n", target, err)
+		return nil
+	}
+	page := string(resp.Body())
+	projects := firebase.DetectProjects(page)
+
+	base, err := url.Parse(target)
+	if err != nil {
+		return projects
+	}
+	for _, m := range scriptSrc.FindAllStringSubmatch(page, -1) {
+		src, err := base.Parse(m[1])
+		if err != nil || !c.GetScope().Allows(src.String()) {
+			continue
+		}
+		c.GetRateLimiter().Wait(ctx)
+		resp, err := c.Request(ctx).Get(src.String())
+		if err != nil {
+			continue
+		}
+		projects = firebase.MergeProjects(projects, firebase.DetectProjects(string(resp.Body()))...)
+	}
+	return projects
+}
+
+// printFirebaseResults prints who reads each path and warns about paths
+// anyone or any user reads
+func printFirebaseResults(tokens []firebase.Token, results []firebase.PathResult) {
+	header := []string{"Service", "Path", "anonymous"}
+	for _, t := range tokens {
+		header = append(header, t.Name)
+	}
+	tableData := pterm.TableData{header}
+	var exposed, readable []string
+	for _, r := range results {
+		row := []string{r.Service, r.Path, accessCell(r.Access[""])}
+		for _, t := range tokens {
+			row = append(row, accessCell(r.Access[t.Name]))
+		}
+		tableData = append(tableData, row)
+		if r.Exposed() {
+			exposed = append(exposed, r.Service+" "+r.Path)
+		} else if names := r.ReadableBy(); len(names) > 0 {
+			slices.Sort(names)
+			readable = append(readable, fmt.Sprintf("%s %s (%s)", r.Service, r.Path, strings.Join(names, ", ")))
+		}
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	for _, path := range exposed {
+		utils.Error.Printf("⚠️  Readable without authentication: %s\n", path)
+	}
+	for _, path := range readable {
+		utils.Warning.Printf("Readable by test users, check it is theirs: %s\n", path)
+	}
+	if len(exposed) == 0 && len(readable) == 0 {
+		utils.Success.Println("No path readable")
+	}
+}
+
+func accessCell(a firebase.Access) string {
+	switch {
+	case a.Status == 0:
+		return "-"
+	case a.Readable:
+		return fmt.Sprintf("%d read (%d bytes)", a.Status, a.Size)
+	case a.Error != "":
+		return fmt.Sprintf("%d %s", a.Status, a.Error)
+	}
+	return fmt.Sprintf("%d", a.Status)
+}
@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 
-	"idorplus/pkg/client"
 	"idorplus/pkg/graphql"
 	"idorplus/pkg/utils"
 
@@ -19,10 +21,18 @@ var graphqlCmd = &cobra.Command{
 Features:
   - Schema introspection to find ID-based queries
   - Batch query testing (aliasing attacks)
+  - Array batching: each ID's query alone vs. all of them in one JSON array
   - Mutation testing for privilege escalation
+  - Captured operations: a real query or mutation from --query-file with
+    one variable (--inject, default the first ID-like one) fuzzed as the
+    attacker (-c), the victim (-C) and without credentials. Mutations
+    change data for every ID and session: they need --allow-destructive
+    and are only sent with --canary IDs of objects you own.
 
 Example:
-  idorplus graphql -u "https://api.target.com/graphql" -c "session=token"`,
+  idorplus graphql -u "https://api.target.com/graphql" -c "session=token"
+  idorplus graphql -u "https://api.target.com/graphql" -c "session=a" -C "session=b" \
+    --query-file order.json --inject input.orderId --ids 1001,1002,1003`,
 	Run: runGraphQL,
 }
 
@@ -31,12 +41,20 @@ func init() {
 
 	graphqlCmd.Flags().StringP("url", "u", "", "GraphQL endpoint URL (required)")
 	graphqlCmd.Flags().StringP("cookies", "c", "", "Session cookies")
+	graphqlCmd.Flags().StringP("cookies-b", "C", "", "Second user cookies, --query-file operations are also sent as this user")
 	graphqlCmd.Flags().StringP("query", "q", "", "Specific query to test")
 	graphqlCmd.Flags().StringP("id-field", "i", "id", "ID field name in query")
 	graphqlCmd.Flags().StringP("valid-id", "V", "", "Known valid ID")
 	graphqlCmd.Flags().StringP("invalid-id", "I", "", "ID to test access for")
 	graphqlCmd.Flags().Bool("introspect", false, "Run introspection first")
 	graphqlCmd.Flags().Bool("batch", false, "Test batch/aliasing attack")
+	graphqlCmd.Flags().String("query-file", "", "File with a captured operation: a JSON request body (query, variables, operationName) or a GraphQL document")
+	graphqlCmd.Flags().String("variables", "", "JSON object of variables for --query-file, overriding those in the file")
+	graphqlCmd.Flags().String("inject", "", "Variable of --query-file to fuzz, dotted for nested ones (default: the first ID-like variable)")
+	graphqlCmd.Flags().StringSlice("ids", nil, "IDs to test (default: 1-5, 10, 100 and --valid-id, --invalid-id)")
+	graphqlCmd.Flags().Bool("allow-destructive", false, "Allow fuzzing a captured mutation, with --canary IDs only")
+	graphqlCmd.Flags().StringSlice("canary", nil, "IDs of objects you own, the only ones a mutation is sent with (the captured value must be one)")
+	graphqlCmd.Flags().Bool("batch-array", false, "Compare each ID's query sent alone with the same queries sent as one JSON array (Apollo-style batching)")
 
 	graphqlCmd.MarkFlagRequired("url")
 }
@@ -44,35 +62,55 @@ func init() {
 func runGraphQL(cmd *cobra.Command, args []string) {
 	url, _ := cmd.Flags().GetString("url")
 	cookies, _ := cmd.Flags().GetString("cookies")
+	cookiesB, _ := cmd.Flags().GetString("cookies-b")
 	query, _ := cmd.Flags().GetString("query")
 	idField, _ := cmd.Flags().GetString("id-field")
 	validID, _ := cmd.Flags().GetString("valid-id")
 	invalidID, _ := cmd.Flags().GetString("invalid-id")
 	introspect, _ := cmd.Flags().GetBool("introspect")
 	batch, _ := cmd.Flags().GetBool("batch")
+	batchArray, _ := cmd.Flags().GetBool("batch-array")
+	queryFile, _ := cmd.Flags().GetString("query-file")
+	variables, _ := cmd.Flags().GetString("variables")
+	inject, _ := cmd.Flags().GetString("inject")
+	ids, _ := cmd.Flags().GetStringSlice("ids")
+	allowDestructive, _ := cmd.Flags().GetBool("allow-destructive")
+	canaries, _ := cmd.Flags().GetStringSlice("canary")
 
 	utils.Info.Printf("GraphQL Endpoint: %s\n", url)
 
 	// Initialize client
-	cfg, _ := utils.LoadConfig("configs/default.yaml")
-	if cfg == nil {
-		cfg = getDefaultConfig()
+	cfg, err := loadConfig(url)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
 	}
 
-	c := client.NewSmartClient(cfg)
-	if cookies != "" {
-		c.GetSessionManager().AddSession("attacker", cookies)
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
 	}
 
 	// Create GraphQL tester
 	gt := graphql.NewGraphQLTester(c, url)
+	gt.AllowMutations, gt.Canaries = allowDestructive, canaries
+	if cookies != "" {
+		c.GetSessionManager().AddSession("attacker", cookies)
+		gt.Session = "attacker"
+	}
+	if cookiesB != "" {
+		c.GetSessionManager().AddSession("victim", cookiesB)
+	}
+	ctx, stop := signalContext()
+	defer stop()
 
 	// Run introspection if requested
 	if introspect {
 		utils.PrintSection("Running Introspection")
 
 		spinner, _ := pterm.DefaultSpinner.Start("Fetching schema...")
-		result, err := gt.Introspect()
+		result, err := gt.Introspect(ctx)
 		if err != nil {
 			spinner.Fail("Introspection failed: " + err.Error())
 			return
@@ -81,12 +119,12 @@ func runGraphQL(cmd *cobra.Command, args []string) {
 
 		// Show found queries with ID params
 		if len(result.Queries) > 0 {
-			pterm.Info.Printf("Found %d queries with ID parameters:\n", len(result.Queries))
+			utils.Info.Printf("Found %d queries with ID parameters:\n", len(result.Queries))
 			for _, q := range result.Queries {
 				pterm.Printf("  - %s\n", q.Name)
 			}
 		} else {
-			pterm.Warning.Println("No queries with ID parameters found")
+			utils.Warning.Println("No queries with ID parameters found")
 		}
 	}
 
@@ -94,7 +132,7 @@ func runGraphQL(cmd *cobra.Command, args []string) {
 	if query != "" && validID != "" && invalidID != "" {
 		utils.PrintSection("Testing IDOR on Query: " + query)
 
-		result, err := gt.TestIDOROnQuery(query, idField, validID, invalidID)
+		result, err := gt.TestIDOROnQuery(ctx, query, idField, validID, invalidID)
 		if err != nil {
 			utils.Error.Printf("Test failed: %v\n", err)
 			return
@@ -111,35 +149,166 @@ func runGraphQL(cmd *cobra.Command, args []string) {
 		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
 
 		if result.IsVulnerable {
-			pterm.Error.Println("⚠️  IDOR VULNERABILITY DETECTED!")
+			utils.Error.Println("⚠️  IDOR VULNERABILITY DETECTED!")
 			pterm.Printf("Evidence: %s\n", result.Evidence)
 		} else {
-			pterm.Success.Println("No IDOR detected")
+			utils.Success.Println("No IDOR detected")
 		}
 	}
 
+	testIDs := []string{"1", "2", "3", "4", "5", "10", "100"}
+	if validID != "" {
+		testIDs = append(testIDs, validID)
+	}
+	if invalidID != "" {
+		testIDs = append(testIDs, invalidID)
+	}
+	if len(ids) > 0 {
+		testIDs = ids
+	}
+
 	// Test batch attack
 	if batch && query != "" {
 		utils.PrintSection("Testing Batch/Aliasing Attack")
 
-		testIDs := []string{"1", "2", "3", "4", "5", "10", "100"}
-		if validID != "" {
-			testIDs = append(testIDs, validID)
+		vulnerableIDs, err := gt.TestBatchIDOR(ctx, query, idField, testIDs)
+		if err != nil {
+			utils.Error.Printf("Batch test stopped: %v\n", err)
 		}
-		if invalidID != "" {
-			testIDs = append(testIDs, invalidID)
+
+		if len(vulnerableIDs) > 0 {
+			utils.Error.Printf("⚠️  Accessible IDs found: %v\n", vulnerableIDs)
+		} else if err == nil {
+			utils.Success.Println("No additional accessible IDs found")
 		}
+	}
 
-		vulnerableIDs, err := gt.TestBatchIDOR(query, idField, testIDs)
+	// Test array batching
+	if batchArray && query != "" {
+		utils.PrintSection("Testing Array Batching")
+
+		result, err := gt.TestBatchArray(ctx, query, idField, testIDs)
 		if err != nil {
-			utils.Error.Printf("Batch test failed: %v\n", err)
+			utils.Error.Printf("Array batch test failed: %v\n", err)
+			return
+		}
+		if !result.Supported {
+			utils.Info.Println("The endpoint does not accept arrays of operations")
 			return
 		}
 
-		if len(vulnerableIDs) > 0 {
-			pterm.Error.Printf("⚠️  Accessible IDs found: %v\n", vulnerableIDs)
+		tableData := pterm.TableData{{"ID", "Alone", "Batched", "Error alone"}}
+		for _, op := range result.Operations {
+			tableData = append(tableData, []string{op.ID, fmt.Sprintf("%v", op.Single), fmt.Sprintf("%v", op.Batched), op.Error})
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+		if len(result.Bypassed) > 0 {
+			utils.Error.Printf("⚠️  Batched operations return data denied to single queries: %v\n", result.Bypassed)
 		} else {
-			pterm.Success.Println("No additional accessible IDs found")
+			utils.Success.Println("Batched operations are authorized like single queries")
+		}
+	}
+
+	// Test a captured operation
+	if queryFile != "" {
+		utils.PrintSection("Testing Captured Operation")
+
+		data, err := os.ReadFile(queryFile)
+		if err != nil {
+			utils.Error.Printf("Failed to read query file: %v\n", err)
+			return
 		}
+		op, err := graphql.LoadOperation(data)
+		if err != nil {
+			utils.Error.Printf("Invalid query file: %v\n", err)
+			return
+		}
+		if variables != "" {
+			dec := json.NewDecoder(strings.NewReader(variables))
+			dec.UseNumber()
+			var vars map[string]interface{}
+			if err := dec.Decode(&vars); err != nil {
+				utils.Error.Printf("Invalid --variables: %v\n", err)
+				return
+			}
+			if op.Variables == nil {
+				op.Variables = make(map[string]interface{})
+			}
+			for name, value := range vars {
+				op.Variables[name] = value
+			}
+		}
+		if inject == "" {
+			if inject = graphql.InjectionPoint(op); inject == "" {
+				utils.Error.Println("No ID-like variable to fuzz, pass --inject")
+				return
+			}
+		}
+		utils.Info.Printf("Fuzzing variable %s\n", inject)
+		if op.IsMutation() {
+			if !allowDestructive || len(canaries) == 0 {
+				utils.Error.Println("The operation is a mutation, which changes data: pass --allow-destructive and --canary with IDs of objects you own")
+				return
+			}
+			utils.Warning.Printf("Mutation: only sent with the %d canary IDs\n", len(canaries))
+			testIDs = canaries
+		}
+
+		sessions := []string{gt.Session}
+		if cookiesB != "" {
+			sessions = append(sessions, "victim")
+		}
+		if gt.Session != "" {
+			sessions = append(sessions, "")
+		}
+		results, err := gt.FuzzVariable(ctx, op, inject, testIDs, sessions)
+		if err != nil {
+			utils.Error.Printf("Operation test stopped: %v\n", err)
+		}
+
+		header := []string{"ID"}
+		for _, session := range sessions {
+			header = append(header, sessionLabel(session))
+		}
+		tableData := pterm.TableData{append(header, "Verdict")}
+		var vulnerable []string
+		for _, r := range results {
+			row := []string{r.ID}
+			for _, session := range sessions {
+				sr := r.Sessions[session]
+				cell := fmt.Sprintf("%d", sr.Status)
+				if sr.Granted {
+					cell += " data"
+				} else if sr.Error != "" {
+					cell += " " + sr.Error
+				}
+				row = append(row, cell)
+			}
+			verdict := ""
+			if r.Vulnerable {
+				verdict = "IDOR"
+				if r.Public {
+					verdict += ", also anonymous"
+				}
+				vulnerable = append(vulnerable, r.ID)
+			}
+			tableData = append(tableData, append(row, verdict))
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+		if len(vulnerable) > 0 {
+			utils.Error.Printf("⚠️  The attacker gets data for other IDs: %v\n", vulnerable)
+		} else if err == nil {
+			utils.Success.Println("No IDOR detected")
+		}
+	}
+}
+
+// sessionLabel names a session in tables, anonymous for none
+func sessionLabel(session string) string {
+	if session == "" {
+		return "anonymous"
 	}
+	return session
 }
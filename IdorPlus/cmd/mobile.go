@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"idorplus/pkg/crawler"
+	"idorplus/pkg/mobile"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var mobileCmd = &cobra.Command{
+	Use:   "mobile",
+	Short: "Discover API endpoints in an Android or iOS app package",
+	Long: `Extract the API endpoints of a mobile app from its APK or IPA, or from a
+directory unpacked with apktool or unzip.
+
+The DEX string tables (literals and Retrofit annotations such as
+@GET("users/{id}")), smali code, the strings of native libraries and
+Mach-O executables, and bundled JavaScript (React Native, Cordova) are
+run through the same patterns as 'discover'. Format verbs like %d and
+%@ become {id}. The API base URLs the app references are listed, most
+referenced first.
+
+With --scan every endpoint with an ID parameter on the host of --base is
+scanned: relative paths are resolved against --base and the ID parameter
+replaced by {ID}. --base is required, an app calls third-party APIs too
+and the base URLs it references are not necessarily in scope; endpoints
+on other hosts are left out. The findings go to one report.
+
+Example:
+  idorplus mobile -f app.apk
+  idorplus mobile -f app.ipa --scan --base "https://api.target.com/v2" -c "session=token" -C "session=token2"`,
+	Run: runMobile,
+}
+
+func init() {
+	rootCmd.AddCommand(mobileCmd)
+
+	mobileCmd.Flags().StringP("file", "f", "", "APK or IPA file, or a directory unpacked from one (required)")
+	mobileCmd.Flags().StringP("output", "o", "mobile_apis.txt", "Output file for the discovered endpoints")
+	mobileCmd.Flags().Bool("scan", false, "Scan every endpoint with an ID parameter")
+	mobileCmd.Flags().String("base", "", "Base URL of the API to scan, required by --scan; relative endpoints are resolved against it")
+	mobileCmd.Flags().StringP("cookies", "c", "", "Session cookies for --scan")
+	mobileCmd.Flags().StringP("cookies-b", "C", "", "Second user cookies for --scan")
+	mobileCmd.Flags().IntP("count", "n", 50, "Number of IDs to try per endpoint for --scan")
+	mobileCmd.Flags().String("report", "mobile_report.json", "Report of the --scan findings")
+	mobileCmd.Flags().String("format", "", "Report format: json, markdown, html, burp (default: from file extension)")
+
+	mobileCmd.MarkFlagRequired("file")
+}
+
+func runMobile(cmd *cobra.Command, args []string) {
+	file, _ := cmd.Flags().GetString("file")
+	output, _ := cmd.Flags().GetString("output")
+	scan, _ := cmd.Flags().GetBool("scan")
+
+	spinner, _ := pterm.DefaultSpinner.Start("Unpacking " + file + "...")
+	app, err := mobile.Analyze(file)
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to read %s: %v", file, err))
+		return
+	}
+	endpoints, candidates := app.Endpoints(), app.Candidates()
+	spinner.Success(fmt.Sprintf("Read %d files and %d strings, found %d endpoints", app.Files, app.Strings, len(endpoints)))
+	if app.Platform != "" {
+		utils.Info.Printf("Platform: %s\n", app.Platform)
+	}
+
+	if len(app.BaseURLs) > 0 {
+		pterm.DefaultSection.Printf("🌐 Base URLs (%d)\n", len(app.BaseURLs))
+		for _, b := range app.BaseURLs {
+			pterm.Printf("  %s\n", b)
+		}
+	}
+	if len(candidates) > 0 {
+		pterm.DefaultSection.Printf("🟡 IDOR Candidates (%d)\n", len(candidates))
+		for _, ep := range candidates {
+			pterm.Printf("  %s (params: %s) from %s\n", ep.URL, strings.Join(ep.ParamNames, ", "), ep.Source)
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("# API endpoints of " + file + "\n\n## Base URLs\n")
+	for _, b := range app.BaseURLs {
+		out.WriteString(b + "\n")
+	}
+	out.WriteString("\n## IDOR Candidates\n")
+	for _, ep := range candidates {
+		out.WriteString(fmt.Sprintf("%s %s # params: %s\n", ep.Method, ep.URL, strings.Join(ep.ParamNames, ",")))
+	}
+	out.WriteString("\n## All\n")
+	for _, ep := range endpoints {
+		out.WriteString(fmt.Sprintf("%s %s # %s\n", ep.Method, ep.URL, ep.Source))
+	}
+	if err := utils.WriteFile(output, []byte(out.String())); err != nil {
+		utils.Error.Printf("Failed to save: %v\n", err)
+	} else {
+		utils.Success.Printf("Saved %d endpoints to %s\n", len(endpoints), output)
+	}
+
+	if scan {
+		scanMobile(cmd, app, candidates)
+	}
+}
+
+// scanMobile scans the IDOR candidates of an app one after the other
+func scanMobile(cmd *cobra.Command, app *mobile.App, candidates []crawler.EndpointInfo) {
+	base, _ := cmd.Flags().GetString("base")
+	cookies, _ := cmd.Flags().GetString("cookies")
+	cookiesB, _ := cmd.Flags().GetString("cookies-b")
+	count, _ := cmd.Flags().GetInt("count")
+	reportFile, _ := cmd.Flags().GetString("report")
+	format, _ := cmd.Flags().GetString("format")
+
+	if base == "" {
+		utils.Error.Println("--scan needs --base, the URL of the API to scan, e.g. one of the base URLs above")
+		return
+	}
+	targets, offHost := mobile.Targets(base, candidates)
+	if offHost > 0 {
+		utils.Warning.Printf("Leaving out %d endpoints on other hosts than %s\n", offHost, base)
+	}
+
+	cfg, err := loadConfig(base)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	scope, err := newScope(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	targets = slices.DeleteFunc(targets, func(target string) bool {
+		if !scope.Allows(target) {
+			utils.Warning.Printf("Skipping %s, out of scope\n", target)
+			return true
+		}
+		return false
+	})
+	if len(targets) == 0 {
+		utils.Warning.Printf("No endpoint with an ID parameter to scan on %s\n", base)
+		return
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	rep := reporter.NewReporter(reportFormat(format, reportFile, cfg.Output.Format))
+	rep.Targets = targets
+	for i, target := range targets {
+		utils.PrintSection(fmt.Sprintf("Scan %d/%d: %s", i+1, len(targets), target))
+		c, err := newClient(cfg)
+		if err != nil {
+			utils.Error.Printf("%v\n", err)
+			return
+		}
+		sc := scanner.New(c, cfg, scanner.Options{URL: target, Cookies: cookies, CookiesB: cookiesB, Count: count, PII: true})
+		err = sc.Run(ctx)
+		rep.Findings = append(rep.Findings, sc.Reporter.Snapshot()...)
+		if errors.Is(err, context.Canceled) {
+			utils.Warning.Println("Scan interrupted")
+			break
+		}
+		if err != nil {
+			utils.Warning.Printf("Skipping %s: %v\n", target, err)
+		}
+	}
+
+	if err := rep.GenerateReport(reportFile); err != nil {
+		utils.Error.Printf("Failed to save report: %v\n", err)
+	} else {
+		utils.Success.Printf("%d findings in %d endpoints, report saved to %s\n", len(rep.Findings), len(targets), reportFile)
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var rawCmd = &cobra.Command{
+	Use:   "raw",
+	Short: "Send hand-crafted requests over a raw socket",
+	Long: `Send a request file byte for byte, bypassing net/http normalisation.
+
+Duplicate headers, unusual whitespace, header case and absolute-URI request
+lines are preserved. Each request uses its own connection, which is closed
+after the response. Proxies are not used in raw mode.
+
+The request file uses the format saved by Burp ("Copy to file"). {ID} is
+replaced with every value given by --id:
+  idorplus raw -r req.txt -u https://api.target.com --id 1 --id 2
+
+Example request file:
+  GET /api/users/{ID} HTTP/1.1
+  Host: api.target.com
+  X-Original-URL: /api/users/{ID}
+  X-Original-URL : /public
+  Cookie: session=token
+
+PUT, PATCH and DELETE requests, also through a method override header, are
+only sent with --allow-destructive.`,
+	Run: runRaw,
+}
+
+func init() {
+	rootCmd.AddCommand(rawCmd)
+
+	rawCmd.Flags().StringP("request", "r", "", "Raw request file (required)")
+	rawCmd.Flags().StringP("url", "u", "", "Target base URL (default: http:// + Host header)")
+	rawCmd.Flags().StringArray("id", nil, "Value substituted for {ID}, repeat for multiple requests")
+	rawCmd.Flags().Bool("keep-length", false, "Do not update Content-Length after substitution")
+	rawCmd.Flags().Int("timeout", 10, "Timeout per request in seconds")
+	rawCmd.Flags().Bool("show-response", false, "Print the raw response")
+	rawCmd.Flags().Bool("allow-destructive", false, "Allow sending PUT, PATCH and DELETE requests, which change or delete data")
+
+	rawCmd.MarkFlagRequired("request")
+}
+
+func runRaw(cmd *cobra.Command, args []string) {
+	requestFile, _ := cmd.Flags().GetString("request")
+	target, _ := cmd.Flags().GetString("url")
+	ids, _ := cmd.Flags().GetStringArray("id")
+	keepLength, _ := cmd.Flags().GetBool("keep-length")
+	timeout, _ := cmd.Flags().GetInt("timeout")
+	showResponse, _ := cmd.Flags().GetBool("show-response")
+	allowDestructive, _ := cmd.Flags().GetBool("allow-destructive")
+
+	data, err := os.ReadFile(requestFile)
+	if err != nil {
+		utils.Error.Printf("Failed to read request file: %v\n", err)
+		return
+	}
+
+	if target == "" {
+		tmpl, err := client.ParseRawRequest(data)
+		if err != nil {
+			utils.Error.Printf("%v\n", err)
+			return
+		}
+		if tmpl.Host() == "" {
+			utils.Error.Println("No Host header in request, use --url")
+			return
+		}
+		target = "http://" + tmpl.Host()
+	}
+
+	if len(ids) == 0 {
+		ids = []string{""}
+	}
+
+	cfg, err := loadConfig(target)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	scope, err := newScope(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+
+	// Only for its dialer: raw requests go out with --bind and --resolve
+	// but skip the transport
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+
+	sender := client.NewRawSender(time.Duration(timeout) * time.Second)
+	sender.Scope = scope
+	sender.Safety = &client.Safety{AllowDestructive: allowDestructive}
+	sender.Dial = c.Dial()
+	sender.TLSConfig = c.TLSConfig()
+	utils.Info.Printf("Target: %s\n", target)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	tableData := pterm.TableData{
+		{"ID", "Status", "Length", "Time"},
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		job := &fuzzer.FuzzJob{Payload: id}
+		req, err := client.ParseRawRequest([]byte(job.Interpolate(string(data))))
+		if err != nil {
+			utils.Error.Printf("%v\n", err)
+			return
+		}
+		if !keepLength {
+			setRawContentLength(req)
+		}
+
+		resp, err := sender.Send(ctx, target, req)
+		if err != nil {
+			utils.Error.Printf("[%s] %v\n", id, err)
+			continue
+		}
+
+		tableData = append(tableData, []string{
+			id,
+			fmt.Sprintf("%d", resp.StatusCode),
+			fmt.Sprintf("%d", len(resp.Body)),
+			resp.Duration.Round(time.Millisecond).String(),
+		})
+
+		if showResponse {
+			utils.PrintSection("Response for " + strconv.Quote(id))
+			fmt.Println(string(resp.Raw))
+		}
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// setRawContentLength rewrites an existing Content-Length header to match the body
+func setRawContentLength(req *client.RawRequest) {
+	for i, h := range req.Headers {
+		name, _, ok := strings.Cut(h, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "content-length") {
+			req.Headers[i] = name + ": " + strconv.Itoa(len(req.Body))
+			return
+		}
+	}
+}
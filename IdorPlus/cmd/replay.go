@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Re-send the request behind a finding",
+	Long: `Replay a finding from a JSON report exactly as it was sent (same headers,
+cookies, payload and body), print the full request/response pair and re-run
+IDOR detection against a fresh baseline.
+
+The finding is referenced as <report.json>#<id>:
+  idorplus replay --finding idor_report.json#3
+
+Findings recorded without cookies (e.g. bypass findings) can be given a session:
+  idorplus replay --finding idor_report.json#5 -c "session=token"
+
+PUT, PATCH and DELETE findings are only replayed with --allow-destructive.`,
+	Run: runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringP("finding", "f", "", "Finding to replay as <report.json>#<id> (required)")
+	replayCmd.Flags().StringP("cookies", "c", "", "Cookies to send if the recorded request has none")
+	replayCmd.Flags().Float64P("threshold", "T", 0.8, "Similarity threshold for detection (0.0-1.0)")
+	replayCmd.Flags().Int("max-body", 2000, "Truncate printed bodies to N bytes (0 = no limit)")
+	replayCmd.Flags().Bool("allow-destructive", false, "Allow replaying PUT, PATCH and DELETE requests, which change or delete data")
+
+	replayCmd.MarkFlagRequired("finding")
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	ref, _ := cmd.Flags().GetString("finding")
+	cookies, _ := cmd.Flags().GetString("cookies")
+	threshold, _ := cmd.Flags().GetFloat64("threshold")
+	maxBody, _ := cmd.Flags().GetInt("max-body")
+	allowDestructive, _ := cmd.Flags().GetBool("allow-destructive")
+
+	path, id, ok := strings.Cut(ref, "#")
+	if !ok || id == "" {
+		utils.Error.Println("--finding must be <report.json>#<id>")
+		return
+	}
+
+	report, err := reporter.LoadReport(path)
+	if err != nil {
+		utils.Error.Printf("Failed to load report: %v\n", err)
+		return
+	}
+	finding := report.FindFinding(id)
+	if finding == nil {
+		utils.Error.Printf("No finding with id %s in %s\n", id, path)
+		return
+	}
+
+	rec := finding.Request
+	if rec == nil {
+		// Reports written before requests were recorded
+		rec = &reporter.RecordedRequest{Method: finding.Method, URL: finding.URL}
+		utils.Warning.Println("Finding has no recorded request, replaying method and URL only")
+	}
+	if client.IsDestructiveMethod(rec.Method) && !allowDestructive {
+		utils.Error.Printf("%s requests change or delete data and must be allowed explicitly (--allow-destructive)\n", strings.ToUpper(rec.Method))
+		return
+	}
+
+	cfg, err := loadConfig(rec.URL)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	// Send exactly what was recorded, without extra bypass headers
+	cfg.WAFBypass.Enabled = false
+
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	c.SetSafety(&client.Safety{AllowDestructive: allowDestructive})
+
+	utils.Info.Printf("Replaying finding %s (%s) from %s\n", finding.ID, finding.Type, path)
+
+	ctx, stop := signalContext()
+	defer stop()
+	resp, err := replayRequest(ctx, c, rec, cookies, finding.Payload, "")
+	if err != nil {
+		utils.Error.Printf("Request failed: %v\n", err)
+		return
+	}
+
+	printRecordedRequest(rec, maxBody)
+	printReplayResponse(resp, maxBody)
+
+	// Re-run detection against a baseline for a non-existent ID
+	verdict := pterm.Yellow("UNKNOWN (no payload to build a baseline)")
+	if finding.Type == reporter.FindingIDOR && finding.Payload != "" {
+		baseline, err := replayRequest(ctx, c, rec, cookies, finding.Payload, "999999999999999")
+		if err != nil {
+			utils.Warning.Printf("Baseline request failed: %v\n", err)
+		} else {
+			det := detector.NewIDORDetector(baseline, baseline, threshold, true)
+			if det.Detect(resp) {
+				verdict = pterm.Red("STILL VULNERABLE")
+			} else {
+				verdict = pterm.Green("NOT REPRODUCED")
+			}
+		}
+	} else if finding.Type != reporter.FindingIDOR {
+		if resp.StatusCode() >= 200 && resp.StatusCode() < 300 {
+			verdict = pterm.Red("STILL BYPASSED")
+		} else {
+			verdict = pterm.Green("NOT REPRODUCED")
+		}
+	}
+
+	pterm.DefaultSection.Println("Verdict")
+	tableData := pterm.TableData{
+		{"", "Recorded", "Replayed"},
+		{"Status", fmt.Sprintf("%d", finding.StatusCode), fmt.Sprintf("%d", resp.StatusCode())},
+		{"Length", fmt.Sprintf("%d", finding.ContentLen), fmt.Sprintf("%d", analyzer.BodySize(resp))},
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	pterm.Println("Result: " + verdict)
+}
+
+// replayRequest sends rec. When replacement is set, occurrences of payload
+// as a whole token in the URL path and query, headers and body are swapped for it.
+func replayRequest(ctx context.Context, c *client.SmartClient, rec *reporter.RecordedRequest, cookies, payload, replacement string) (*resty.Response, error) {
+	swap := func(s string) string { return s }
+	if replacement != "" && payload != "" {
+		re := regexp.MustCompile(`(^|[^A-Za-z0-9_-])` + regexp.QuoteMeta(payload) + `($|[^A-Za-z0-9_-])`)
+		swap = func(s string) string {
+			return re.ReplaceAllString(s, "${1}"+replacement+"${2}")
+		}
+	}
+
+	target := rec.URL
+	if u, err := url.Parse(rec.URL); err == nil && replacement != "" {
+		target = u.Scheme + "://" + u.Host + swap(u.EscapedPath())
+		if u.RawQuery != "" {
+			target += "?" + swap(u.RawQuery)
+		}
+	}
+
+	req := c.Request(ctx)
+	hasCookie := false
+	for name, value := range rec.Headers {
+		// Credentials masked in the report are replaced by --cookies
+		if value == reporter.MaskedHeader {
+			continue
+		}
+		if strings.EqualFold(name, "Cookie") {
+			hasCookie = true
+		}
+		req.SetHeader(name, swap(value))
+	}
+	if !hasCookie && cookies != "" {
+		req.SetHeader("Cookie", cookies)
+	}
+	if rec.Body != "" {
+		req.SetBody(swap(rec.Body))
+	}
+
+	return req.Execute(rec.Method, target)
+}
+
+func printRecordedRequest(rec *reporter.RecordedRequest, maxBody int) {
+	pterm.DefaultSection.Println("Request")
+
+	fmt.Println(truncateBody(rec.Dump(), maxBody))
+}
+
+func printReplayResponse(resp *resty.Response, maxBody int) {
+	pterm.DefaultSection.Println("Response")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s (%s)\n", resp.Proto(), resp.Status(), resp.Time().Round(time.Millisecond))
+	names := make([]string, 0, len(resp.Header()))
+	for name := range resp.Header() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, strings.Join(resp.Header()[name], ", "))
+	}
+	b.WriteString("\n" + truncateBody(string(resp.Body()), maxBody) + "\n")
+	fmt.Print(b.String())
+}
+
+func truncateBody(body string, maxLen int) string {
+	if maxLen <= 0 || len(body) <= maxLen {
+		return body
+	}
+	return body[:maxLen] + "...[truncated]"
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Query results stored by scan --db",
+	Long: `Query the SQLite database written by 'scan --db' and regenerate reports.
+
+  idorplus results scans
+  idorplus results list --target api.example.com --status 200 --since 2024-01-01
+  idorplus results findings --severity HIGH
+  idorplus results report --scan 3 -o scan3.html
+
+Without --scan, the report consolidates every matching scan; when they
+cover several hosts it opens with an executive summary and has a section
+per host:
+  idorplus results report --since 2024-06-01 -o engagement.html`,
+}
+
+var resultsScansCmd = &cobra.Command{
+	Use:   "scans",
+	Short: "List stored scans",
+	Run:   runResultsScans,
+}
+
+var resultsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored request results",
+	Run:   runResultsList,
+}
+
+var resultsFindingsCmd = &cobra.Command{
+	Use:   "findings",
+	Short: "List stored findings",
+	Run:   runResultsFindings,
+}
+
+var resultsReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Regenerate a report from stored findings",
+	Run:   runResultsReport,
+}
+
+func init() {
+	rootCmd.AddCommand(resultsCmd)
+	resultsCmd.AddCommand(resultsScansCmd, resultsListCmd, resultsFindingsCmd, resultsReportCmd)
+
+	resultsCmd.PersistentFlags().String("db", "idorplus.db", "Results database")
+	resultsCmd.PersistentFlags().Int64("scan", 0, "Only this scan ID")
+	resultsCmd.PersistentFlags().String("target", "", "Only scans whose target contains this string")
+	resultsCmd.PersistentFlags().String("since", "", "Only entries at or after this date (YYYY-MM-DD or RFC3339)")
+	resultsCmd.PersistentFlags().String("until", "", "Only entries before this date (YYYY-MM-DD or RFC3339)")
+	resultsCmd.PersistentFlags().Int("limit", 100, "Maximum rows to show (0 = no limit)")
+
+	resultsListCmd.Flags().Int("status", 0, "Only results with this status code")
+	resultsListCmd.Flags().Bool("vulnerable", false, "Only vulnerable results")
+
+	resultsFindingsCmd.Flags().String("severity", "", "Only findings with this severity")
+
+	resultsReportCmd.Flags().StringP("output", "o", "idor_report.json", "Output report file")
+	resultsReportCmd.Flags().String("format", "", "Report format: json, markdown, html, burp (default: from file extension)")
+	resultsReportCmd.Flags().String("severity", "", "Only findings with this severity")
+	resultsReportCmd.Flags().Bool("no-dedup", false, "Report every finding separately instead of grouping them by fingerprint")
+	resultsReportCmd.Flags().Bool("redact", false, "Mask PII in the report, keeping only its type and count")
+	resultsReportCmd.Flags().String("report-template", "", "Go template laying out markdown and HTML reports instead of the built-in layout")
+}
+
+// openResults opens the database and builds the filter shared by all subcommands
+func openResults(cmd *cobra.Command) (*store.Store, store.Filter, error) {
+	var filter store.Filter
+	dbPath, _ := cmd.Flags().GetString("db")
+	filter.ScanID, _ = cmd.Flags().GetInt64("scan")
+	filter.Target, _ = cmd.Flags().GetString("target")
+	filter.Limit, _ = cmd.Flags().GetInt("limit")
+
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	var err error
+	if filter.Since, err = parseDate(since); err != nil {
+		return nil, filter, err
+	}
+	if filter.Until, err = parseDate(until); err != nil {
+		return nil, filter, err
+	}
+
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, filter, fmt.Errorf("results database %s: %w", dbPath, err)
+	}
+	db, err := store.Open(dbPath)
+	return db, filter, err
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD or RFC3339)", s)
+	}
+	return t, nil
+}
+
+func runResultsScans(cmd *cobra.Command, args []string) {
+	db, filter, err := openResults(cmd)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	defer db.Close()
+
+	scans, err := db.Scans(filter)
+	if err != nil {
+		utils.Error.Printf("Query failed: %v\n", err)
+		return
+	}
+
+	tableData := pterm.TableData{{"ID", "Target", "Started", "Duration", "Results", "Findings"}}
+	for _, sc := range scans {
+		duration := "running"
+		if !sc.FinishedAt.IsZero() {
+			duration = sc.FinishedAt.Sub(sc.StartedAt).Round(time.Second).String()
+		}
+		tableData = append(tableData, []string{
+			fmt.Sprintf("%d", sc.ID),
+			sc.Target,
+			sc.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			duration,
+			fmt.Sprintf("%d", sc.Results),
+			fmt.Sprintf("%d", sc.Findings),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+func runResultsList(cmd *cobra.Command, args []string) {
+	db, filter, err := openResults(cmd)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	defer db.Close()
+
+	filter.StatusCode, _ = cmd.Flags().GetInt("status")
+	filter.Vulnerable, _ = cmd.Flags().GetBool("vulnerable")
+
+	results, err := db.Results(filter)
+	if err != nil {
+		utils.Error.Printf("Query failed: %v\n", err)
+		return
+	}
+
+	tableData := pterm.TableData{{"Scan", "Method", "URL", "Status", "Length", "Time", "Vulnerable"}}
+	for _, r := range results {
+		status := fmt.Sprintf("%d", r.StatusCode)
+		if r.Error != "" {
+			status = "error"
+		} else if r.Blocked {
+			status += " (blocked)"
+		}
+		vulnerable := ""
+		if r.Vulnerable {
+			vulnerable = pterm.Red("yes")
+		}
+		tableData = append(tableData, []string{
+			fmt.Sprintf("%d", r.ScanID),
+			r.Method,
+			r.URL,
+			status,
+			fmt.Sprintf("%d", r.ContentLen),
+			r.Duration.String(),
+			vulnerable,
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	utils.Info.Printf("%d results\n", len(results))
+}
+
+func runResultsFindings(cmd *cobra.Command, args []string) {
+	db, filter, err := openResults(cmd)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	defer db.Close()
+
+	filter.Severity, _ = cmd.Flags().GetString("severity")
+
+	findings, err := db.Findings(filter)
+	if err != nil {
+		utils.Error.Printf("Query failed: %v\n", err)
+		return
+	}
+
+	tableData := pterm.TableData{{"Found", "Type", "Method", "URL", "Status", "Severity"}}
+	for _, f := range findings {
+		tableData = append(tableData, []string{
+			f.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			f.Type,
+			f.Method,
+			f.URL,
+			fmt.Sprintf("%d", f.StatusCode),
+			fmt.Sprintf("%s %.1f", f.Severity, f.CVSSScore),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	utils.Info.Printf("%d findings\n", len(findings))
+}
+
+func runResultsReport(cmd *cobra.Command, args []string) {
+	db, filter, err := openResults(cmd)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	defer db.Close()
+
+	outputFile, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+	noDedup, _ := cmd.Flags().GetBool("no-dedup")
+	redact, _ := cmd.Flags().GetBool("redact")
+	templatePath, _ := cmd.Flags().GetString("report-template")
+	filter.Severity, _ = cmd.Flags().GetString("severity")
+	if !cmd.Flags().Changed("limit") {
+		filter.Limit = 0
+	}
+
+	findings, err := db.Findings(filter)
+	if err != nil {
+		utils.Error.Printf("Query failed: %v\n", err)
+		return
+	}
+
+	rep := reporter.NewReporter(reportFormat(format, outputFile, ""))
+	rep.Dedup = !noDedup
+	rep.Redact = redact
+	if err := loadReportTemplate(rep, templatePath); err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	rep.Findings = findings
+	if scans, err := db.Scans(filter); err == nil {
+		for _, sc := range scans {
+			rep.Targets = append(rep.Targets, sc.Target)
+		}
+	}
+	if filter.ScanID != 0 {
+		if sc, err := db.GetScan(filter.ScanID); err == nil {
+			rep.StartTime, rep.EndTime = sc.StartedAt, sc.FinishedAt
+			rep.Scan = &reporter.ScanInfo{Target: sc.Target}
+		}
+	} else if len(findings) > 0 {
+		rep.StartTime, rep.EndTime = findings[0].Timestamp, findings[len(findings)-1].Timestamp
+	}
+
+	if err := rep.GenerateReport(outputFile); err != nil {
+		utils.Error.Printf("Failed to save report: %v\n", err)
+		return
+	}
+	utils.Success.Printf("Report with %d findings saved to %s\n", len(findings), outputFile)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"idorplus/pkg/client"
+	"idorplus/pkg/reporter"
 	"idorplus/pkg/utils"
 
 	"github.com/spf13/cobra"
@@ -11,12 +13,62 @@ import (
 
 var (
 	cfgFile   string
+	profile   string
+	settings  []string
 	verbose   bool
 	debug     bool
 	version   = "2.0.0"
 	proxyList []string
+	proxyFile string
+
+	upstreamProxy string
+	upstreamCA    string
+	burpProxy     bool
+
+	clientCert string
+	clientKey  string
+	insecure   bool
+	caCert     string
+	pinSHA256  []string
+
+	resolveHosts []string
+	hostHeader   string
+	bindAddr     string
+	preferIPv6   bool
+
+	awsSigV4 string
+
+	csrfURL     string
+	csrfExtract string
+	csrfHeader  string
+	csrfParam   string
+
+	cacheEnabled bool
+	cacheDir     string
+
+	scopeInclude []string
+	scopeExclude []string
+
+	logFormat string
+	logFile   string
+	noColor   bool
+	silent    bool
 )
 
+// Exit codes of scan and export, for CI gating
+const (
+	exitClean      = reporter.ExitClean
+	exitError      = reporter.ExitError
+	exitFindings   = reporter.ExitFindings
+	exitIncomplete = reporter.ExitIncomplete
+)
+
+// exitCode is the exit status set by the command that ran
+var exitCode = exitClean
+
+// defaultBurpProxy is Burp Suite's default listener
+const defaultBurpProxy = "http://127.0.0.1:8080"
+
 var rootCmd = &cobra.Command{
 	Use:   "idorplus",
 	Short: "Advanced IDOR Hunter",
@@ -28,28 +80,105 @@ Features:
   - Proxy Rotation
   - Auth Matrix Testing
   - PII Detection
-  - Smart Pattern Analysis`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+  - Smart Pattern Analysis
+
+Configuration, from lowest to highest precedence:
+  1. built-in defaults
+  2. the config file: --config, ./configs/default.yaml or ~/.config/idorplus/config.yaml
+  3. the --profile selected from the config's profiles
+  4. the config's per-target overrides (targets)
+  5. IDORPLUS_<SECTION>_<KEY> environment variables, e.g. IDORPLUS_SCANNER_THREADS=5
+  6. --set section.key=value, e.g. --set waf_bypass.mode=stealth
+  7. IDORPLUS_<FLAG> environment variables for flags, e.g. IDORPLUS_COOKIES
+  8. flags given on the command line
+
+Environment variables keep secrets such as cookies and tokens out of files
+and the process list.
+
+Logs are colored console output by default. --log-format json writes one JSON
+object per message to stderr instead (time, level, msg and attributes) and
+turns off banners, tables and progress bars, for CI and log collectors.
+--log-file additionally appends the logs to a file.
+
+--no-color (or the NO_COLOR environment variable) prints plain text without
+colors, banner, spinners or progress bars, for CI logs. --silent prints only
+errors, on stderr; scan then writes each finding to stdout as a JSON line.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := flagsFromEnv(cmd); err != nil {
+			return err
+		}
+		if err := utils.InitLogger(utils.LogOptions{
+			Debug:   debug,
+			Format:  logFormat,
+			File:    logFile,
+			NoColor: noColor || os.Getenv("NO_COLOR") != "",
+			Silent:  silent,
+		}); err != nil {
+			return err
+		}
+		if stdoutReserved(cmd) {
+			utils.SetOutput(os.Stderr)
+		}
 		// Don't print banner for version or help
-		if cmd.Name() == "version" || cmd.Name() == "help" {
-			return
+		if cmd.Name() == "version" || cmd.Name() == "help" || !utils.Decorated() {
+			return nil
 		}
 		utils.PrintBanner(version)
-		utils.InitLogger(debug)
+		return nil
 	},
 }
 
+// stdoutReserved reports whether cmd streams findings to stdout (--jsonl or
+// -o -), so that everything else has to go to stderr
+func stdoutReserved(cmd *cobra.Command) bool {
+	if cmd.Flags().Lookup("jsonl") == nil {
+		return false
+	}
+	jsonl, _ := cmd.Flags().GetBool("jsonl")
+	output, _ := cmd.Flags().GetString("output")
+	return jsonl || output == "-"
+}
+
 // Execute runs the root command
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
+	os.Exit(exitCode)
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ./configs/default.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ./configs/default.yaml, then ~/.config/idorplus/config.yaml)")
+	rootCmd.PersistentFlags().StringArrayVar(&settings, "set", nil, "override a config setting as section.key=value (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "config profile to apply, see profiles in configs/default.yaml")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug mode")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format: text or json (JSON lines on stderr)")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "also append logs to this file")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "plain output without colors, banner, spinners or progress bars")
+	rootCmd.PersistentFlags().BoolVar(&silent, "silent", false, "print only errors (stderr) and findings as JSON lines (stdout)")
 	rootCmd.PersistentFlags().StringSliceVar(&proxyList, "proxy", []string{}, "proxy list for rotation (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringVar(&proxyFile, "proxy-file", "", "file with one proxy per line (http, https, socks5, host:port:user:pass)")
+	rootCmd.PersistentFlags().StringVar(&upstreamProxy, "upstream-proxy", "", "route all traffic through an intercepting proxy (overrides rotation)")
+	rootCmd.PersistentFlags().BoolVar(&burpProxy, "burp", false, "shortcut for --upstream-proxy "+defaultBurpProxy)
+	rootCmd.PersistentFlags().StringVar(&upstreamCA, "upstream-ca", "", "CA certificate of the upstream proxy (PEM or DER)")
+	rootCmd.PersistentFlags().StringVar(&clientCert, "client-cert", "", "client certificate for mutual TLS (PEM)")
+	rootCmd.PersistentFlags().StringVar(&clientKey, "client-key", "", "private key for --client-cert (if not bundled)")
+	rootCmd.PersistentFlags().BoolVarP(&insecure, "insecure", "k", false, "skip TLS certificate verification")
+	rootCmd.PersistentFlags().StringVar(&caCert, "ca-cert", "", "trust this CA certificate (PEM or DER) besides the system roots, e.g. for internal services")
+	rootCmd.PersistentFlags().StringArrayVar(&pinSHA256, "pin-sha256", nil, "only accept servers with this public key, as sha256//<base64> like curl --pinnedpubkey (repeatable, checked even with -k)")
+	rootCmd.PersistentFlags().StringArrayVar(&resolveHosts, "resolve", nil, "connect to host at addr instead of resolving it, as host:addr or host:port:addr like curl (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&hostHeader, "host-header", "", "send this Host header instead of the URL's host, e.g. to test virtual hosts")
+	rootCmd.PersistentFlags().StringVar(&bindAddr, "bind", "", "send from this local IP address or interface, e.g. 10.8.0.2 or tun0")
+	rootCmd.PersistentFlags().BoolVar(&preferIPv6, "prefer-ipv6", false, "connect to a target's IPv6 addresses first")
+	rootCmd.PersistentFlags().BoolVar(&cacheEnabled, "cache", false, "serve repeated identical GET and HEAD requests from an in-memory cache")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "persist cached responses in this directory (implies --cache)")
+	rootCmd.PersistentFlags().StringArrayVar(&scopeInclude, "include", nil, "only request URLs matching this regex (repeatable, adds to scope.include)")
+	rootCmd.PersistentFlags().StringArrayVar(&scopeExclude, "exclude", nil, "never request URLs matching this regex, e.g. '/delete-account' (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&csrfURL, "csrf-url", "", "fetch an anti-CSRF token from this URL for POST/PUT/PATCH/DELETE requests, per session")
+	rootCmd.PersistentFlags().StringVar(&csrfExtract, "csrf-extract", "", "where the token is: auto, regex:PATTERN, json:$.path, meta:NAME, input:NAME or cookie:NAME (default auto)")
+	rootCmd.PersistentFlags().StringVar(&csrfHeader, "csrf-header", "", "request header carrying the token (default "+client.DefaultCSRFHeader+")")
+	rootCmd.PersistentFlags().StringVar(&csrfParam, "csrf-param", "", "send the token in this form or JSON body field instead of a header")
+	rootCmd.PersistentFlags().StringVar(&awsSigV4, "aws-sigv4", "", "sign requests with AWS SigV4 as <region>/<service> (credentials from AWS_* env)")
 }
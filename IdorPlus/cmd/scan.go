@@ -1,23 +1,35 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"idorplus/pkg/analyzer"
 	"idorplus/pkg/client"
-	"idorplus/pkg/detector"
+	"idorplus/pkg/elastic"
 	"idorplus/pkg/fuzzer"
 	"idorplus/pkg/generator"
+	"idorplus/pkg/notify"
 	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/store"
+	"idorplus/pkg/tracing"
 	"idorplus/pkg/utils"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var scanCmd = &cobra.Command{
@@ -28,6 +40,83 @@ var scanCmd = &cobra.Command{
 Use {ID} as a placeholder in the URL where you want to fuzz:
   idorplus scan -u "https://api.target.com/users/{ID}/profile" -c "session=token"
 
+{ID} also works inside header values and cookies:
+  idorplus scan -u "https://api.target.com/me" -H "X-User-Id: {ID}" -c "session=token; uid={ID}"
+
+and in request bodies (JSON, form or XML, detected automatically):
+  idorplus scan -u "https://api.target.com/orders/view" -m POST --data '{"order_id": {ID}}' -c "session=token"
+
+Structured IDs such as order numbers come from --template, where {num} is
+the generated number (with a printf format), {uuid}, {hex:N} and {alnum:N}
+are random, and pipes transform a value (upper, lower, md5, sha1, sha256,
+base64, url, hex...):
+  idorplus scan -u "https://api.target.com/invoices/{ID}" --template 'INV-{num:06d}' --id-range 1000-2000
+  idorplus scan -u "https://api.target.com/files/{ID}" --template '{num|md5}'
+
+Hook scripts (Starlark, a Python dialect) handle request signing, checksums
+or encrypted responses that no flag covers:
+  idorplus scan -u "https://api.target.com/users/{ID}" --script hooks.star
+
+  def on_request(req):       # req: method, url, headers (dict), body
+      req["headers"]["X-Sig"] = hmac_sha256("secret", req["url"] + str(now()))
+
+  def on_response(req, resp): # resp: status, headers, body, vulnerable
+      return resp["status"] == 200 and req["id"] in resp["body"]
+
+Scripts can use json, md5, sha1, sha256, hmac_sha1, hmac_sha256, base64_encode,
+base64_decode, hex_encode, hex_decode, now, random_hex, aes_cbc_decrypt and
+aes_gcm_decrypt. on_response returns True/False to override the verdict or
+None to keep it.
+
+PUT, PATCH and DELETE change or delete data and need --allow-destructive.
+Add --canary to only fuzz IDs of resources you own (e.g. created with the
+second account), each checked with a GET first, and --confirm to be asked
+before anything destructive is sent:
+  idorplus scan -u "https://api.target.com/notes/{ID}" -m DELETE -c "session=a" \
+      -C "session=b" --allow-destructive --canary 5012,5013 --confirm
+
+Findings can be piped to other tools as they are confirmed: --jsonl writes
+each one to stdout as a JSON line, and -o - does so instead of saving a
+report. All other output then goes to stderr:
+  idorplus scan -u "https://api.target.com/users/{ID}" -c "session=token" -o - | jq .url
+
+Cookies the server sets, e.g. a rotated session token, replace those of
+-c and -C for the rest of the scan. --session-file keeps them across runs:
+  idorplus scan -u "https://app.target.com/orders/{ID}" -c "session=a" --session-file sessions.json
+
+--save-profile writes the scan's options and effective config to a YAML
+file to rerun it later or share it; --load-profile runs it again in place of
+the target flags. Cookies, tokens and auth headers are not saved, the
+profile refers to environment variables such as IDORPLUS_COOKIES instead:
+  idorplus scan -u "https://api.target.com/users/{ID}" -c "session=a" --save-profile pentest1.yaml
+  IDORPLUS_COOKIES="session=a" idorplus scan --load-profile pentest1.yaml
+
+Review every request before scanning production with --dry-run; it prints
+each request as it would be sent, with sessions, scripts and signing
+applied, and sends nothing:
+  idorplus scan -u "https://api.target.com/users/{ID}" -c "session=token" --dry-run
+
+--fail-on makes the scan a CI gate: it exits with status 2 when findings at
+or above a severity are found, 1 when the scan fails, 3 when it was
+interrupted or ran out of budget without such findings and 0 otherwise:
+  idorplus scan -u "https://staging.target.com/users/{ID}" -c "session=token" --fail-on high
+
+In GitHub Actions the counts by severity and the top findings are added to
+the job's step summary, and the top findings are annotated on the run.
+
+Ctrl+C or SIGTERM stops the scan and still saves the report of what was
+tested, marked as interrupted, with its findings and statistics. If the
+scan doesn't stop within a few seconds, or on a second Ctrl+C, it is saved
+as is and idorplus exits. The payloads not tested yet are written to a
+resume state file next to the report (--resume-file), which --resume picks
+up from:
+  idorplus scan -u "https://api.target.com/users/{ID}" -c "session=token" --id-range 1-100000 -o big.json
+  idorplus scan -u "https://api.target.com/users/{ID}" -c "session=token" --id-range 1-100000 -o big.json --resume big.resume.json
+
+With output.elasticsearch.url set in the config, every result, findings or
+not, is indexed into Elasticsearch or OpenSearch for dashboards, tagged with
+a scan_id per scan.
+
 The scanner will:
   1. Establish baseline responses
   2. Generate payloads based on detected ID type
@@ -39,288 +128,772 @@ The scanner will:
 func init() {
 	rootCmd.AddCommand(scanCmd)
 
-	scanCmd.Flags().StringP("url", "u", "", "Target URL with {ID} placeholder (required)")
-	scanCmd.Flags().StringP("cookies", "c", "", "Session cookies")
-	scanCmd.Flags().StringP("cookies-b", "C", "", "Second user cookies for auth matrix testing")
-	scanCmd.Flags().IntP("threads", "t", 10, "Number of concurrent workers")
-	scanCmd.Flags().StringP("wordlist", "w", "", "Custom wordlist file")
-	scanCmd.Flags().IntP("count", "n", 100, "Number of payloads to generate (if no wordlist)")
+	addTargetFlags(scanCmd)
 	scanCmd.Flags().StringP("bypass", "b", "normal", "WAF bypass mode: none, normal, aggressive, stealth")
-	scanCmd.Flags().StringP("method", "m", "GET", "HTTP method: GET, POST, PUT, DELETE, PATCH")
-	scanCmd.Flags().StringP("output", "o", "idor_report.json", "Output report file")
-	scanCmd.Flags().Float64P("threshold", "T", 0.8, "Similarity threshold for detection (0.0-1.0)")
-	scanCmd.Flags().Bool("auth-matrix", false, "Enable auth matrix testing (requires -C)")
-	scanCmd.Flags().Bool("pii", true, "Enable PII detection")
+	scanCmd.Flags().StringP("output", "o", "idor_report.json", "Output report file, - for no report and findings as JSON lines on stdout")
+	scanCmd.Flags().Bool("jsonl", false, "Also stream each finding to stdout as a JSON line as soon as it is confirmed")
+	scanCmd.Flags().String("format", "", "Report format: json, markdown, html, burp (default: from file extension)")
+	scanCmd.Flags().String("db", "", "Store every result and finding in this SQLite database (see 'results')")
+	scanCmd.Flags().Bool("no-dedup", false, "Report every finding separately instead of grouping them by fingerprint")
+	scanCmd.Flags().String("burp-xml", "", "Also export findings as Burp Suite issues XML to this file")
 	scanCmd.Flags().Int("delay", 100, "Delay between requests in milliseconds")
-	scanCmd.Flags().StringArrayP("header", "H", nil, "Custom headers (e.g. -H 'Authorization: Bearer token')")
-	scanCmd.Flags().StringP("auth", "a", "", "Bearer token for Authorization header")
+	scanCmd.Flags().String("fail-on", "", "Exit with status 2 if findings at or above this severity are found: low, medium, high, critical")
+	scanCmd.Flags().Bool("confirm", false, "Ask before sending destructive requests to an endpoint")
+	scanCmd.Flags().Bool("dry-run", false, "Print every request the scan would send, then exit without sending any")
+	scanCmd.Flags().Int("retest", 2, "Re-send each finding this many times and drop it unless reproducible (0 = off)")
+	scanCmd.Flags().String("session-file", "", "Keep the cookies the server sets for -c/-C in this file: restored before the scan unless -c/-C set them, saved after it")
+	scanCmd.Flags().Bool("save-responses", false, "Save the full request/response of each finding to a responses/ directory next to the report")
+	scanCmd.Flags().Bool("redact", false, "Mask PII in reports and saved responses, keeping only its type and count")
+	scanCmd.Flags().String("report-template", "", "Go template laying out markdown and HTML reports instead of the built-in layout")
+	scanCmd.Flags().String("save-profile", "", "Save the scan's options and effective config as a YAML profile, secrets as environment variable references")
+	scanCmd.Flags().String("load-profile", "", "Run the scan of a profile saved with --save-profile instead of the target flags")
+	scanCmd.Flags().String("resume", "", "Continue an interrupted scan from its resume state file, with the same target flags")
+	scanCmd.Flags().String("resume-file", "", "Where an interrupted scan saves its resume state (default: next to the report, as <name>.resume.json)")
+
+	scanCmd.MarkFlagsOneRequired("url", "load-profile")
+}
+
+// addTargetFlags registers the flags describing what to scan, shared by
+// scan and coordinator
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("url", "u", "", "Target URL with {ID} placeholder (required)")
+	cmd.Flags().StringP("cookies", "c", "", "Session cookies")
+	cmd.Flags().StringP("cookies-b", "C", "", "Second user cookies for auth matrix testing")
+	cmd.Flags().IntP("threads", "t", 10, "Number of concurrent workers")
+	cmd.Flags().StringArrayP("wordlist", "w", nil, "Wordlist file for {ID}, or NAME=file for a {NAME} placeholder (repeatable, e.g. -w ORG=orgs.txt -w users.txt)")
+	cmd.Flags().String("combine", scanner.CombineProduct, "How named wordlists combine: product (every combination) or zip (line by line)")
+	cmd.Flags().IntP("count", "n", 100, "Number of payloads to generate (if no wordlist)")
+	cmd.Flags().String("id-range", "", "Generate the numeric IDs START-END instead of -n IDs from 1 (e.g. 100000-110000)")
+	cmd.Flags().Int64("id-step", 1, "Distance between generated numeric IDs")
+	cmd.Flags().Int("random-sample", 0, "Pick this many IDs of --id-range at random")
+	cmd.Flags().String("checksum", "", "Append check digits to generated numeric IDs: "+strings.Join(analyzer.Checksums, ", ")+" (default: detected from known IDs)")
+	cmd.Flags().String("learn-from", "", "File of known IDs, one per line: generate IDs following their shared pattern (prefix, width, counter, check digit)")
+	cmd.Flags().String("template", "", "Generate structured IDs, e.g. 'INV-{num:06d}' or 'user_{uuid|md5}' (see scan --help)")
+	cmd.Flags().Bool("estimate-range", false, "Probe for the lowest and highest existing IDs first and fuzz -n IDs sampled from that range")
+	cmd.Flags().StringSlice("encodings", nil, "Also send every payload encoded: "+strings.Join(generator.Encodings, ", ")+"; chain with + (e.g. base64+url)")
+	cmd.Flags().Bool("injection", false, "Also try NoSQL operator and always-true SQL filter IDs like [$ne]=1 and ' OR '1'='1 (never with PUT, PATCH or DELETE)")
+	cmd.Flags().StringP("method", "m", "GET", "HTTP method: GET, POST, PUT, DELETE, PATCH")
+	cmd.Flags().Float64P("threshold", "T", 0.8, "Similarity threshold for detection (0.0-1.0)")
+	cmd.Flags().Int("min-confidence", 30, "Only report findings with at least this confidence (0-100), see detection.weights")
+	cmd.Flags().Bool("auth-matrix", false, "Enable auth matrix testing (requires -C)")
+	cmd.Flags().Bool("pii", true, "Enable PII detection")
+	cmd.Flags().StringArrayP("header", "H", nil, "Custom headers, {ID} is fuzzed per request (e.g. -H 'X-User-Id: {ID}')")
+	cmd.Flags().StringP("auth", "a", "", "Bearer token for Authorization header")
+	cmd.Flags().String("data", "", "Request body, {ID} is fuzzed per request")
+	cmd.Flags().Bool("stop-on-first", false, "Stop fuzzing an endpoint after its first confirmed finding")
+	cmd.Flags().Int("max-findings", 0, "Stop fuzzing an endpoint after N confirmed findings (0 = no limit)")
+	cmd.Flags().Int("max-requests", 0, "Send at most N requests in total, baselines and retries included (0 = no limit)")
+	cmd.Flags().Duration("max-duration", 0, "Stop sending requests after this long, e.g. 30m (0 = no limit)")
+	cmd.Flags().Bool("verb-tamper", false, "Retry denied requests with method override headers and alternate verbs")
+	cmd.Flags().Bool("path-bypass", false, "Retry denied requests with path normalisation mutations (case, encoding, traversal)")
+	cmd.Flags().Bool("api-versions", false, "Retry denied and vulnerable GET requests on other API versions (/v1/ for /v2/, /api/internal/, mobile prefixes)")
+	cmd.Flags().Bool("content-shift", false, "Retry denied body requests re-encoded as JSON, form, XML and multipart")
+	cmd.Flags().Bool("pollution", false, "Send your own ID together with denied IDs in duplicated, array and query-vs-body parameters")
+	cmd.Flags().String("own-id", "", "ID of an object you own, for --pollution, --pagination and as a sample of the ID format (default: the first --canary)")
+	cmd.Flags().Bool("pagination", false, "List your own collection with huge page sizes, offsets past your records and without owner filters like user_id")
+	cmd.Flags().Bool("mass-assign", false, "Inject privileged fields (role, is_admin, balance...) into the JSON body of your own object after fuzzing")
+	cmd.Flags().String("script", "", "Starlark hook script defining on_request and/or on_response (see scan --help)")
+	cmd.Flags().Bool("allow-destructive", false, "Allow fuzzing with PUT, PATCH and DELETE, which change or delete data")
+	cmd.Flags().StringSlice("canary", nil, "Limit destructive fuzzing to these IDs of resources you own, verified with a GET first")
+	cmd.Flags().String("canary-check", "", "URL with {ID} used to verify canaries (default: --url)")
+	cmd.Flags().Bool("cors", false, "Send an IDOR finding, or else a request of the scan, with attacker origins and report those allowed to read it with credentials")
+	cmd.Flags().Bool("methods", false, "Ask the endpoint which methods it allows with OPTIONS first, skip fuzzing a method it doesn't and list allowed PUT, PATCH and DELETE left untested")
+	cmd.Flags().Bool("no-classify", false, "Fuzz without probing the endpoint first (static assets are skipped, login redirects fail the scan)")
+}
+
+// targetOptions builds scan options from the flags of addTargetFlags.
+// Wordlist IDs are loaded into Payloads.
+func targetOptions(cmd *cobra.Command) (scanner.Options, error) {
+	var opts scanner.Options
+	opts.URL, _ = cmd.Flags().GetString("url")
+	opts.Cookies, _ = cmd.Flags().GetString("cookies")
+	opts.CookiesB, _ = cmd.Flags().GetString("cookies-b")
+	// Threads, threshold and min confidence stay zero unless set, so the
+	// config decides
+	if cmd.Flags().Changed("threads") {
+		opts.Threads, _ = cmd.Flags().GetInt("threads")
+	}
+	opts.Count, _ = cmd.Flags().GetInt("count")
+	if idRange, _ := cmd.Flags().GetString("id-range"); idRange != "" {
+		start, end, ok := strings.Cut(idRange, "-")
+		var err1, err2 error
+		opts.IDStart, err1 = strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+		opts.IDEnd, err2 = strconv.ParseInt(strings.TrimSpace(end), 10, 64)
+		if !ok || err1 != nil || err2 != nil {
+			return opts, fmt.Errorf("--id-range must be START-END, got %q", idRange)
+		}
+	}
+	opts.IDStep, _ = cmd.Flags().GetInt64("id-step")
+	opts.Sample, _ = cmd.Flags().GetInt("random-sample")
+	opts.Checksum, _ = cmd.Flags().GetString("checksum")
+	opts.EstimateRange, _ = cmd.Flags().GetBool("estimate-range")
+	opts.Template, _ = cmd.Flags().GetString("template")
+	if opts.Template != "" {
+		if _, err := generator.ParseTemplate(opts.Template); err != nil {
+			return opts, fmt.Errorf("--template: %w", err)
+		}
+	}
+	if opts.Checksum != "" {
+		if err := analyzer.ValidateChecksum(opts.Checksum); err != nil {
+			return opts, fmt.Errorf("--checksum: %w", err)
+		}
+	}
+	if err := (&generator.NumericGenerator{Start: opts.IDStart, End: opts.IDEnd, Step: opts.IDStep, Sample: opts.Sample}).Validate(); err != nil {
+		return opts, fmt.Errorf("invalid ID range: %w", err)
+	}
+	opts.Encodings, _ = cmd.Flags().GetStringSlice("encodings")
+	opts.Injection, _ = cmd.Flags().GetBool("injection")
+	for _, chain := range opts.Encodings {
+		if err := generator.ValidateEncoding(chain); err != nil {
+			return opts, fmt.Errorf("--encodings: %w", err)
+		}
+	}
+	opts.Method, _ = cmd.Flags().GetString("method")
+	if err := utils.ValidateMethod(opts.Method); err != nil {
+		return opts, fmt.Errorf("--method: %w", err)
+	}
+	if cmd.Flags().Changed("threshold") {
+		opts.Threshold, _ = cmd.Flags().GetFloat64("threshold")
+		if err := utils.ValidateThreshold(opts.Threshold); err != nil {
+			return opts, fmt.Errorf("--threshold: %w", err)
+		}
+	}
+	if cmd.Flags().Changed("min-confidence") {
+		minConfidence, _ := cmd.Flags().GetInt("min-confidence")
+		if minConfidence < 0 || minConfidence > 100 {
+			return opts, fmt.Errorf("--min-confidence must be between 0 and 100, got %d", minConfidence)
+		}
+		opts.MinConfidence = &minConfidence
+	}
+	opts.AuthMatrix, _ = cmd.Flags().GetBool("auth-matrix")
+	opts.PII, _ = cmd.Flags().GetBool("pii")
+	opts.Headers, _ = cmd.Flags().GetStringArray("header")
+	opts.Bearer, _ = cmd.Flags().GetString("auth")
+	opts.Body, _ = cmd.Flags().GetString("data")
+	opts.MaxFindings, _ = cmd.Flags().GetInt("max-findings")
+	opts.MaxRequests, _ = cmd.Flags().GetInt("max-requests")
+	if opts.MaxRequests < 0 {
+		return opts, fmt.Errorf("--max-requests must be at least 0, got %d", opts.MaxRequests)
+	}
+	if maxDuration, _ := cmd.Flags().GetDuration("max-duration"); maxDuration < 0 {
+		return opts, fmt.Errorf("--max-duration must not be negative, got %s", maxDuration)
+	} else if maxDuration > 0 {
+		opts.MaxDuration = maxDuration.String()
+	}
+	opts.VerbTamper, _ = cmd.Flags().GetBool("verb-tamper")
+	opts.PathBypass, _ = cmd.Flags().GetBool("path-bypass")
+	opts.APIVersions, _ = cmd.Flags().GetBool("api-versions")
+	opts.ContentShift, _ = cmd.Flags().GetBool("content-shift")
+	opts.MassAssign, _ = cmd.Flags().GetBool("mass-assign")
+	opts.Pollution, _ = cmd.Flags().GetBool("pollution")
+	opts.Pagination, _ = cmd.Flags().GetBool("pagination")
+	opts.OwnID, _ = cmd.Flags().GetString("own-id")
+	opts.AllowDestructive, _ = cmd.Flags().GetBool("allow-destructive")
+	opts.CanaryIDs, _ = cmd.Flags().GetStringSlice("canary")
+	opts.CanaryCheckURL, _ = cmd.Flags().GetString("canary-check")
+	opts.NoClassify, _ = cmd.Flags().GetBool("no-classify")
+	opts.Methods, _ = cmd.Flags().GetBool("methods")
+	opts.CORS, _ = cmd.Flags().GetBool("cors")
+	if client.IsDestructiveMethod(opts.Method) && !opts.AllowDestructive {
+		return opts, fmt.Errorf("%s requests change or delete data, pass --allow-destructive to fuzz with them (see scan --help for canary mode)", strings.ToUpper(opts.Method))
+	}
+	if stopOnFirst, _ := cmd.Flags().GetBool("stop-on-first"); stopOnFirst {
+		opts.MaxFindings = 1
+	}
+
+	if scriptPath, _ := cmd.Flags().GetString("script"); scriptPath != "" {
+		src, err := os.ReadFile(scriptPath)
+		if err != nil {
+			return opts, fmt.Errorf("failed to load script: %w", err)
+		}
+		opts.Script = string(src)
+	}
+
+	// Wordlist IDs are real candidates and run ahead of generated sequences
+	wordlists, _ := cmd.Flags().GetStringArray("wordlist")
+	for _, w := range wordlists {
+		name, path := "ID", w
+		if n, p, ok := strings.Cut(w, "="); ok && namedWordlist.MatchString(n) {
+			name, path = n, p
+		}
+		payloads, err := utils.LoadWordlist(path)
+		if err != nil {
+			return opts, fmt.Errorf("failed to load wordlist: %w", err)
+		}
+		if name == "ID" {
+			opts.Payloads = append(opts.Payloads, payloads...)
+		} else {
+			if opts.Wordlists == nil {
+				opts.Wordlists = make(map[string][]string)
+			}
+			opts.Wordlists[name] = append(opts.Wordlists[name], payloads...)
+		}
+		utils.Info.Printf("Loaded %d payloads for {%s} from wordlist\n", len(payloads), name)
+	}
+	if samplesPath, _ := cmd.Flags().GetString("learn-from"); samplesPath != "" {
+		samples, err := utils.LoadWordlist(samplesPath)
+		if err != nil {
+			return opts, fmt.Errorf("failed to load known IDs: %w", err)
+		}
+		if _, err := analyzer.NewIdentifierAnalyzer().LearnFromSamples(samples); err != nil {
+			return opts, fmt.Errorf("--learn-from: %w", err)
+		}
+		opts.SampleIDs = samples
+	}
+	opts.Combine, _ = cmd.Flags().GetString("combine")
+	if opts.Combine != scanner.CombineProduct && opts.Combine != scanner.CombineZip {
+		return opts, fmt.Errorf("--combine must be %s or %s, got %q", scanner.CombineProduct, scanner.CombineZip, opts.Combine)
+	}
+	return opts, nil
+}
+
+// scanTarget returns the options and config of the scan: those of the
+// profile when one is loaded, otherwise from the target flags and config
+func scanTarget(cmd *cobra.Command, profilePath string) (scanner.Options, *utils.Config, error) {
+	if profilePath == "" {
+		opts, err := targetOptions(cmd)
+		if err != nil {
+			return opts, nil, err
+		}
+		cfg, err := loadConfig(opts.URL)
+		return opts, cfg, err
+	}
+
+	// Target flags set from the environment are what the profile's
+	// references are filled in from, not a conflict
+	var conflicts []string
+	targets := &cobra.Command{}
+	addTargetFlags(targets)
+	targets.Flags().VisitAll(func(f *pflag.Flag) {
+		if flag := cmd.Flags().Lookup(f.Name); flag != nil && flag.Changed && flag.Annotations[envAnnotation] == nil {
+			conflicts = append(conflicts, "--"+f.Name)
+		}
+	})
+	if len(conflicts) > 0 {
+		return scanner.Options{}, nil, fmt.Errorf("--load-profile replaces the target flags, drop %s", strings.Join(conflicts, ", "))
+	}
 
-	scanCmd.MarkFlagRequired("url")
+	opts, cfg, err := scanner.LoadProfile(profilePath)
+	if err != nil {
+		return opts, nil, fmt.Errorf("failed to load profile: %w", err)
+	}
+	utils.Info.Printf("Loaded scan profile %s\n", profilePath)
+	return opts, cfg, nil
 }
 
+// namedWordlist matches the NAME of a -w NAME=file
+var namedWordlist = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
 func runScan(cmd *cobra.Command, args []string) {
+	// Any return before the scan completes is a scan error
+	exitCode = exitError
+
 	// Parse flags
-	url, _ := cmd.Flags().GetString("url")
-	cookies, _ := cmd.Flags().GetString("cookies")
-	cookiesB, _ := cmd.Flags().GetString("cookies-b")
-	threads, _ := cmd.Flags().GetInt("threads")
-	wordlistPath, _ := cmd.Flags().GetString("wordlist")
-	count, _ := cmd.Flags().GetInt("count")
 	bypass, _ := cmd.Flags().GetString("bypass")
-	method, _ := cmd.Flags().GetString("method")
 	outputFile, _ := cmd.Flags().GetString("output")
-	threshold, _ := cmd.Flags().GetFloat64("threshold")
-	authMatrix, _ := cmd.Flags().GetBool("auth-matrix")
-	piiCheck, _ := cmd.Flags().GetBool("pii")
+	format, _ := cmd.Flags().GetString("format")
+	burpXML, _ := cmd.Flags().GetString("burp-xml")
+	noDedup, _ := cmd.Flags().GetBool("no-dedup")
+	dbPath, _ := cmd.Flags().GetString("db")
 	delay, _ := cmd.Flags().GetInt("delay")
-	customHeaders, _ := cmd.Flags().GetStringArray("header")
-	bearerToken, _ := cmd.Flags().GetString("auth")
-
-	utils.Info.Printf("Target: %s\n", url)
-	utils.Info.Printf("Mode: %s | Threads: %d | Method: %s\n", bypass, threads, method)
+	saveResponses, _ := cmd.Flags().GetBool("save-responses")
+	redact, _ := cmd.Flags().GetBool("redact")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	confirm, _ := cmd.Flags().GetBool("confirm")
+	jsonl, _ := cmd.Flags().GetBool("jsonl")
+	failOn, _ := cmd.Flags().GetString("fail-on")
+
+	saveProfile, _ := cmd.Flags().GetString("save-profile")
+	loadProfile, _ := cmd.Flags().GetString("load-profile")
+	resume, _ := cmd.Flags().GetString("resume")
+	resumeFile, _ := cmd.Flags().GetString("resume-file")
+	if resumeFile == "" {
+		resumeFile = resumePath(outputFile)
+	}
 
-	// Load config
-	cfg, err := utils.LoadConfig("configs/default.yaml")
+	opts, cfg, err := scanTarget(cmd, loadProfile)
 	if err != nil {
-		utils.Warning.Printf("Config not found, using defaults\n")
-		cfg = getDefaultConfig()
+		utils.Error.Printf("%v\n", err)
+		return
 	}
-
-	// Override config with flags
-	cfg.Scanner.Threads = threads
-	cfg.WAFBypass.Mode = bypass
-	cfg.WAFBypass.Enabled = bypass != "none"
-	cfg.Detection.Threshold = threshold
-	cfg.Detection.CheckPII = piiCheck
-	cfg.Scanner.Delay = fmt.Sprintf("%dms", delay)
-
-	// Initialize client
-	c := client.NewSmartClient(cfg)
-
-	// Set up sessions
-	if cookies != "" {
-		c.GetSessionManager().AddSession("attacker", cookies)
+	if err := utils.ValidateBypassMode(bypass); err != nil {
+		utils.Error.Printf("--bypass: %v\n", err)
+		return
 	}
-	if cookiesB != "" {
-		c.GetSessionManager().AddSession("victim", cookiesB)
+	if format != "" {
+		if err := utils.ValidateReportFormat(format); err != nil {
+			utils.Error.Printf("--format: %v\n", err)
+			return
+		}
 	}
-
-	// Set proxies if provided
-	if len(proxyList) > 0 {
-		c.SetProxies(proxyList)
-		utils.Info.Printf("Using %d proxies\n", len(proxyList))
+	if delay < 0 {
+		utils.Error.Printf("--delay must not be negative, got %d\n", delay)
+		return
 	}
-
-	// Add custom headers
-	for _, h := range customHeaders {
-		parts := strings.SplitN(h, ":", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			val := strings.TrimSpace(parts[1])
-			c.SetDefaultHeader(key, val)
-			utils.Info.Printf("Custom header: %s\n", key)
+	if failOn != "" {
+		if err := utils.ValidateSeverity(failOn); err != nil {
+			utils.Error.Printf("--fail-on: %v\n", err)
+			return
 		}
 	}
 
-	// Add bearer token
-	if bearerToken != "" {
-		c.SetDefaultHeader("Authorization", "Bearer "+bearerToken)
-		utils.Info.Println("Using Bearer token authentication")
+	// Flags set on the command line override the config and its profiles
+	if cmd.Flags().Changed("threads") {
+		cfg.Scanner.Threads = opts.Threads
+	}
+	if cmd.Flags().Changed("bypass") {
+		cfg.WAFBypass.Mode = bypass
+		cfg.WAFBypass.Enabled = bypass != "none"
+	}
+	if cmd.Flags().Changed("threshold") {
+		cfg.Detection.Threshold = opts.Threshold
+	}
+	if cmd.Flags().Changed("pii") {
+		cfg.Detection.CheckPII = opts.PII
+	} else {
+		opts.PII = cfg.Detection.CheckPII
+	}
+	if cmd.Flags().Changed("delay") {
+		cfg.Scanner.Delay = fmt.Sprintf("%dms", delay)
+	}
+	if cmd.Flags().Changed("save-responses") {
+		cfg.Output.SaveResponses = saveResponses
+	}
+	if cmd.Flags().Changed("redact") {
+		cfg.Output.Redact = redact
+	}
+	if cmd.Flags().Changed("report-template") {
+		cfg.Output.Template, _ = cmd.Flags().GetString("report-template")
+	}
+	if cmd.Flags().Changed("retest") {
+		cfg.Detection.Confirmations, _ = cmd.Flags().GetInt("retest")
+	}
+	if dbPath != "" {
+		cfg.Output.Database = dbPath
 	}
 
-	// Generate or load payloads
-	var payloads []string
-	if wordlistPath != "" {
-		payloads, err = utils.LoadWordlist(wordlistPath)
+	if saveProfile != "" {
+		vars, err := scanner.SaveProfile(saveProfile, opts, cfg)
 		if err != nil {
-			utils.Error.Printf("Failed to load wordlist: %v\n", err)
+			utils.Error.Printf("Failed to save profile: %v\n", err)
 			return
 		}
-		utils.Info.Printf("Loaded %d payloads from wordlist\n", len(payloads))
-	} else {
-		// Detect ID type from URL
-		existingID := extractExistingID(url)
-		idType := analyzer.TypeNumeric
-		if existingID != "" {
-			ia := analyzer.NewIdentifierAnalyzer()
-			idType = ia.DetectType(existingID)
-			utils.Info.Printf("Detected ID type: %v\n", idType)
+		utils.Success.Printf("Scan profile saved to %s\n", saveProfile)
+		if len(vars) > 0 {
+			utils.Info.Printf("Set %s to load it\n", strings.Join(vars, ", "))
 		}
+	}
 
-		gen := generator.NewPayloadGenerator(idType)
-		payloads = gen.Generate(count)
-		utils.Info.Printf("Generated %d payloads\n", len(payloads))
+	mode := cfg.WAFBypass.Mode
+	if !cfg.WAFBypass.Enabled {
+		mode = "none"
 	}
+	utils.Info.Printf("Target: %s\n", opts.URL)
+	utils.Info.Printf("Mode: %s | Threads: %d | Method: %s\n", mode, cfg.Scanner.Threads, opts.Method)
 
-	// Get baselines
-	utils.Info.Println("Establishing baselines...")
+	defer startTracing(cfg)()
 
-	// Invalid baseline (non-existent resource)
-	invalidURL := replaceID(url, "999999999999999")
-	invalidResp, err := c.Request().Get(invalidURL)
+	// Initialize client
+	c, err := newClient(cfg)
 	if err != nil {
-		utils.Error.Printf("Failed to get invalid baseline: %v\n", err)
+		utils.Error.Printf("%v\n", err)
 		return
 	}
-	utils.Debug.Printf("Invalid baseline: Status %d, Length %d\n", invalidResp.StatusCode(), len(invalidResp.Body()))
 
-	// Valid baseline (if we have an existing ID in the URL)
-	var validResp = invalidResp // Fallback
-	existingID := extractExistingID(url)
-	if existingID != "" && cookies != "" {
-		validURL := replaceID(url, existingID)
-		vr, err := c.Request().Get(validURL)
-		if err == nil {
-			validResp = vr
-			utils.Debug.Printf("Valid baseline: Status %d, Length %d\n", validResp.StatusCode(), len(validResp.Body()))
+	if dryRun {
+		printPlan(scanner.New(c, cfg, opts))
+		exitCode = exitClean
+		return
+	}
+
+	notifier, err := notify.NewNotifier(cfg.Notify)
+	if err != nil {
+		utils.Error.Printf("Invalid notify config: %v\n", err)
+		return
+	}
+	defer notifier.Close()
+
+	indexer, err := elastic.NewIndexer(cfg.Output.Elasticsearch, opts.URL)
+	if err != nil {
+		utils.Warning.Printf("Elasticsearch output disabled: %v\n", err)
+	}
+	defer func() {
+		if sent, failed := indexer.Close(); sent+failed > 0 {
+			utils.Info.Printf("Indexed %d results into Elasticsearch as scan %s (%d failed)\n", sent, indexer.ScanID, failed)
+		}
+	}()
+
+	sc := scanner.New(c, cfg, opts)
+	if resume != "" {
+		if sc.Resume, err = scanner.LoadResume(resume); err != nil {
+			utils.Error.Printf("Failed to load resume state: %v\n", err)
+			return
 		}
 	}
+	if confirm {
+		sc.Confirm = confirmDestructive
+	}
+	sc.SessionFile, _ = cmd.Flags().GetString("session-file")
 
-	// Create detector
-	det := detector.NewIDORDetector(validResp, invalidResp, threshold, piiCheck)
+	rep := reporter.NewReporter(reportFormat(format, outputFile, cfg.Output.Format))
+	rep.Dedup = !noDedup
+	rep.Redact = cfg.Output.Redact
+	if err := loadReportTemplate(rep, cfg.Output.Template); err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	rep.OnFinding = notifier.Notify
+	if silent || jsonl || outputFile == "-" {
+		rep.OnFinding = findingLines(notifier, rep.Redact)
+	}
+	if cfg.Output.SaveResponses {
+		rep.ResponsesDir = filepath.Join(filepath.Dir(outputFile), "responses")
+	}
+	sc.Reporter = rep
+	if sc.Resume != nil {
+		rep.Findings = append(rep.Findings, sc.Resume.Findings...)
+	}
 
-	// Auth Matrix testing
-	if authMatrix && cookiesB != "" {
-		utils.PrintSection("Auth Matrix Testing")
-		amt := detector.NewAuthMatrixTester(c)
-		amt.AddSession("user_a", cookies)
-		amt.AddSession("user_b", cookiesB)
+	if cfg.Output.Database != "" {
+		db, err := store.Open(cfg.Output.Database)
+		if err != nil {
+			utils.Warning.Printf("Results database disabled: %v\n", err)
+		} else {
+			defer db.Close()
+			sc.Store = db
+		}
+	}
 
-		testURL := replaceID(url, existingID)
-		result := amt.TestEndpoint(testURL, method)
-		amt.PrintMatrix(result)
+	// Progress bar
+	var progressBar *pterm.ProgressbarPrinter
+	sc.OnStart = func(total int) {
+		if !utils.Decorated() {
+			return
+		}
+		progressBar, _ = pterm.DefaultProgressbar.
+			WithTotal(total).
+			WithTitle("Scanning").
+			WithShowElapsedTime(true).
+			WithShowCount(true).
+			Start()
+	}
+	// The title shows the status codes so far, e.g. a flood of 429s
+	title, results := "Scanning", 0
+	sc.OnResult = func(result *fuzzer.FuzzResult) {
+		indexer.Add(result)
+		results++
+		if result.IsVulnerable {
+			title = pterm.Red("VULNERABLE FOUND!")
+			utils.PrintVulnerable(result.Job.URL, result.StatusCode)
+		}
+		if progressBar == nil {
+			return
+		}
+		if result.IsVulnerable || results%statusTitleEvery == 0 {
+			progressBar.UpdateTitle(title + " | " + fuzzer.StatusLine(sc.Engine.Stats.StatusCodes()))
+		}
+		progressBar.Increment()
 	}
 
-	// Setup signal handling
+	// Setup signal handling. The first signal cancels the scan; a second
+	// one, or the scan not stopping in time, saves what it has right away.
 	ctx, cancel := context.WithCancel(context.Background())
-	sigChan := make(chan os.Signal, 1)
+	defer cancel()
+	sigChan := make(chan os.Signal, 2)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	forced := make(chan struct{})
 
 	go func() {
 		<-sigChan
-		utils.Warning.Println("\nInterrupt received, stopping scan...")
+		utils.Warning.Println("\nInterrupt received, stopping scan (again to save and exit now)...")
 		cancel()
+		select {
+		case <-sigChan:
+		case <-time.After(shutdownGrace):
+		}
+		close(forced)
 	}()
 
-	// Initialize fuzzer
-	fe := fuzzer.NewFuzzEngine(c, threads, det)
-	fe.Start()
+	done := make(chan error, 1)
+	go func() { done <- sc.Run(ctx) }()
+	select {
+	case err = <-done:
+	case <-forced:
+		// Run is left behind, its findings so far are saved below
+		utils.Warning.Println("Scan did not stop in time, saving partial results")
+		err = ctx.Err()
+		if sc.Engine != nil {
+			rep.Statistics = sc.Engine.Stats.Breakdown()
+			rep.Coverage = sc.Engine.Coverage()
+		}
+	}
+	if progressBar != nil {
+		progressBar.Stop()
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		utils.Error.Printf("Scan failed: %v\n", err)
+		return
+	}
+	if ctx.Err() != nil {
+		rep.Interrupted = true
+		saveResumeState(sc, resumeFile)
+	} else if resume != "" {
+		os.Remove(resume)
+	}
+	findings := rep.Snapshot()
 
-	// Setup progress bar
-	progressBar, _ := pterm.DefaultProgressbar.
-		WithTotal(len(payloads)).
-		WithTitle("Scanning").
-		WithShowElapsedTime(true).
-		WithShowCount(true).
-		Start()
+	// Print stats
+	if sc.Engine != nil {
+		sc.Engine.Stats.Print()
+		if rep.Budget != nil {
+			rep.Budget.Print()
+		}
+		fuzzer.PrintCoverage(rep.Coverage)
+		if logFormat == "json" {
+			st := sc.Engine.Stats
+			utils.Logger().Info("Scan statistics",
+				"requests", st.GetTotal(), "failed", st.GetFailedCount(),
+				"blocked", st.GetBlockedCount(), "skipped", st.GetSkippedCount(),
+				"vulnerable", len(findings), "elapsed", st.GetElapsed().Round(time.Millisecond).String())
+		}
+	}
+	if c.GetProxyManager().IsEnabled() {
+		printProxyStats(c.GetProxyManager().Stats())
+	}
+	if cache := c.GetCache(); cache != nil {
+		hits, misses := cache.Stats()
+		utils.Info.Printf("Cache: %d hits, %d misses\n", hits, misses)
+	}
+	if sc.Store != nil {
+		utils.Info.Printf("Results stored as scan %d in %s\n", sc.ScanID, cfg.Output.Database)
+	}
 
-	// Feed jobs in goroutine
-	go func() {
-	JobLoop:
-		for i, p := range payloads {
-			select {
-			case <-ctx.Done():
-				break JobLoop
-			default:
-				targetURL := replaceID(url, p)
-				job := &fuzzer.FuzzJob{
-					ID:      i,
-					URL:     targetURL,
-					Method:  method,
-					Payload: p,
-					Session: "attacker",
-				}
-				if !fe.Submit(job) {
-					break JobLoop
-				}
-			}
+	// Save report
+	saveFailed := false
+	if outputFile != "-" {
+		if err := rep.GenerateReport(outputFile); err != nil {
+			utils.Error.Printf("Failed to save report: %v\n", err)
+			saveFailed = true
+		} else {
+			utils.Success.Printf("Report saved to %s\n", outputFile)
 		}
-		fe.CloseQueue()
-		fe.WaitAndClose() // Wait for workers and close Results channel
-	}()
+	}
+	if burpXML != "" {
+		if err := rep.ExportBurp(burpXML); err != nil {
+			utils.Error.Printf("Failed to export Burp issues: %v\n", err)
+			saveFailed = true
+		} else {
+			utils.Success.Printf("Burp issues exported to %s\n", burpXML)
+		}
+	}
 
-	// Collect results
-	rep := reporter.NewReporter("json")
-	done := make(chan bool)
+	if reporter.InGitHubActions() {
+		out := os.Stdout
+		if silent || jsonl || outputFile == "-" {
+			out = os.Stderr
+		}
+		if err := rep.PublishGitHub(out); err != nil {
+			utils.Warning.Printf("Failed to write the GitHub step summary: %v\n", err)
+		}
+	}
 
-	go func() {
-		for result := range fe.Results {
-			progressBar.Increment()
+	// Summary
+	if len(findings) > 0 {
+		utils.Error.Printf("\n%d VULNERABILITIES FOUND!\n", len(findings))
+	} else {
+		utils.Success.Println("\nNo vulnerabilities found")
+	}
 
-			if result.IsVulnerable {
-				progressBar.UpdateTitle(pterm.Red("VULNERABLE FOUND!"))
-				utils.PrintVulnerable(result.Job.URL, result.StatusCode)
-				rep.AddFinding(result)
+	exitCode = reporter.ExitCode(findings, failOn, rep.Incomplete(), saveFailed)
+	switch exitCode {
+	case exitFindings:
+		utils.Error.Printf("%d findings at or above %s, failing (--fail-on)\n", reporter.CountAtLeast(findings, failOn), strings.ToUpper(failOn))
+	case exitIncomplete:
+		utils.Warning.Println("The scan did not finish, exiting with status 3")
+	}
+}
+
+func printProxyStats(stats []client.ProxyStats) {
+	pterm.DefaultSection.Println("Proxy Health")
+
+	tableData := pterm.TableData{
+		{"Proxy", "State", "OK", "Errors", "Avg Latency"},
+	}
+	for _, ps := range stats {
+		state := pterm.Green("healthy")
+		if ps.Evicted {
+			state = pterm.Red("evicted")
+		} else if !ps.Healthy {
+			state = pterm.Yellow("unhealthy")
+		}
+		tableData = append(tableData, []string{
+			ps.URL,
+			state,
+			fmt.Sprintf("%d", ps.Successes),
+			fmt.Sprintf("%d", ps.Failures),
+			ps.AvgLatency.Round(time.Millisecond).String(),
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// confirmDestructive asks on stdin whether to send destructive requests,
+// prompting on stderr
+func confirmDestructive(method, endpoint string, requests int) bool {
+	utils.Warning.Printf("About to send %d %s requests to %s\n", requests, method, endpoint)
+	fmt.Fprint(os.Stderr, "Continue? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// printPlan prints the requests of a dry run and their count to stderr,
+// stdout stays free for --jsonl
+func printPlan(sc *scanner.Scanner) {
+	plan, err := sc.Plan()
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+
+	failed := 0
+	for i, req := range plan.Requests {
+		fmt.Fprintf(os.Stderr, "### %d/%d %s (ID %s)\n", i+1, len(plan.Requests), req.Purpose, req.Payload)
+		if req.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "NOT SENT: %v\n\n", req.Err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s %s\n", req.Method, req.URL)
+		names := make([]string, 0, len(req.Header))
+		for name := range req.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range req.Header[name] {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", name, value)
 			}
 		}
-		done <- true
-	}()
+		if req.Body != "" {
+			fmt.Fprintf(os.Stderr, "\n%s\n", req.Body)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
 
-	// Wait for completion
-	<-done
-	progressBar.Stop()
+	tableData := pterm.TableData{
+		{"Requests listed", fmt.Sprintf("%d", len(plan.Requests))},
+		{"Auth matrix", fmt.Sprintf("%d", plan.AuthMatrix)},
+		{"Mass assignment", fmt.Sprintf("%d", plan.MassAssignment)},
+		{"Total (excluding retries)", fmt.Sprintf("%d", plan.Total())},
+	}
+	if len(plan.BypassModules) > 0 {
+		tableData = append(tableData, []string{"Bypass modules",
+			fmt.Sprintf("%s, on up to %d denied requests each", strings.Join(plan.BypassModules, ", "), plan.MaxBypassSamples)})
+	}
+	pterm.DefaultTable.WithWriter(os.Stderr).WithData(tableData).Render()
+	if failed > 0 {
+		utils.Warning.Printf("%d requests could not be built and would fail\n", failed)
+	}
+	utils.Info.Println("Dry run, nothing was sent")
+}
 
-	// Print stats
-	fe.Stats.Print()
+// findingLines notifies about each finding and writes it to stdout as a
+// JSON line, for --jsonl, -o - and --silent
+func findingLines(notifier *notify.Notifier, redact bool) func(*reporter.Finding) {
+	write := reporter.JSONLines(os.Stdout)
+	return func(f *reporter.Finding) {
+		if redact {
+			f = reporter.RedactFinding(f)
+		}
+		notifier.Notify(f)
+		write(f)
+	}
+}
 
-	// Save report
-	if err := rep.GenerateReport(outputFile); err != nil {
-		utils.Error.Printf("Failed to save report: %v\n", err)
-	} else {
-		utils.Success.Printf("Report saved to %s\n", outputFile)
+// reportFormat picks the report format from --format, the output file
+// extension, then the config
+func reportFormat(flag, outputFile, configured string) string {
+	if flag != "" {
+		return flag
+	}
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case ".html", ".htm":
+		return "html"
+	case ".md":
+		return "markdown"
+	case ".xml":
+		return "burp"
+	case ".json":
+		return "json"
+	}
+	if configured != "" {
+		return configured
 	}
+	return "json"
+}
 
-	// Summary
-	if fe.Stats.GetVulnCount() > 0 {
-		utils.Error.Printf("\n%d VULNERABILITIES FOUND!\n", fe.Stats.GetVulnCount())
-	} else {
-		utils.Success.Println("\nNo vulnerabilities found")
+// shutdownGrace is how long an interrupted scan gets to stop before its
+// partial results are saved regardless, well within the 10s containers
+// usually get between SIGTERM and SIGKILL
+const shutdownGrace = 5 * time.Second
+
+// resumePath is the default resume state file of a scan reporting to output
+func resumePath(output string) string {
+	if output == "-" {
+		return "idorplus.resume.json"
 	}
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".resume.json"
 }
 
-func getDefaultConfig() *utils.Config {
-	return &utils.Config{
-		Scanner: utils.ScannerConfig{
-			Threads:    10,
-			Timeout:    "10s",
-			MaxRetries: 3,
-			Delay:      "100ms",
-		},
-		WAFBypass: utils.WAFBypassConfig{
-			Enabled: true,
-			Mode:    "normal",
-			Headers: map[string]string{
-				"X-Forwarded-For": "127.0.0.1",
-				"X-Real-IP":       "127.0.0.1",
-			},
-		},
-		Detection: utils.DetectionConfig{
-			Threshold: 0.8,
-			CheckPII:  true,
-			BlindIDOR: false,
-		},
-		Output: utils.OutputConfig{
-			Format:  "json",
-			Verbose: true,
-		},
+// saveResumeState writes what an interrupted scan has left to path
+func saveResumeState(sc *scanner.Scanner, path string) {
+	st := sc.ResumeState()
+	if st == nil || len(st.Payloads) == 0 {
+		return
+	}
+	if err := scanner.SaveResume(path, st); err != nil {
+		utils.Error.Printf("Failed to save resume state: %v\n", err)
+		return
 	}
+	utils.Success.Printf("%d of %d payloads tested, resume with --resume %s\n", st.Tested(), st.Total, path)
 }
 
-func replaceID(url, id string) string {
-	if strings.Contains(url, "{ID}") {
-		return strings.Replace(url, "{ID}", id, 1)
+// statusTitleEvery is how many results apart the progress title's status
+// codes are refreshed
+const statusTitleEvery = 25
+
+// loadReportTemplate lays out markdown and HTML reports with the template
+// at path, if set
+func loadReportTemplate(rep *reporter.Reporter, path string) error {
+	if path == "" {
+		return nil
+	}
+	if rep.Format != "markdown" && rep.Format != "html" {
+		utils.Warning.Printf("Report template %s ignored for the %s format\n", path, rep.Format)
+		return nil
 	}
-	// Fallback: append to URL
-	if strings.HasSuffix(url, "/") {
-		return url + id
+	if err := rep.LoadTemplate(path); err != nil {
+		return fmt.Errorf("invalid report template: %w", err)
 	}
-	return url + "/" + id
+	return nil
 }
 
-func extractExistingID(url string) string {
-	// Try to find an existing ID in the URL
-	if strings.Contains(url, "{ID}") {
-		return ""
+// startTracing exports traces of the scans as configured; the returned
+// function flushes them
+func startTracing(cfg *utils.Config) func() {
+	shutdown, err := tracing.Start(context.Background(), cfg.Tracing, version)
+	if err != nil {
+		utils.Warning.Printf("Tracing disabled: %v\n", err)
+		return func() {}
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			utils.Warning.Printf("Failed to flush traces: %v\n", err)
+		}
 	}
-	return utils.ExtractIDFromURL(url)
 }
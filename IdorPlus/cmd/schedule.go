@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"idorplus/pkg/schedule"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule [crontab]",
+	Short: "Rerun saved scan profiles on a schedule",
+	Long: `Run as a daemon that rescans saved profiles (scan --save-profile) on cron
+schedules. Results and findings of every run go to the SQLite database, and
+webhooks configured in a profile are only notified of findings the previous
+run of the same target did not have.
+
+The crontab has a schedule and a profile path per line, relative paths being
+relative to the crontab:
+
+  # m h dom mon dow  profile
+  0 3 * * *          users.yaml
+  */30 9-18 * * 1-5  orders.yaml
+  @every 6h          admin.yaml
+
+  idorplus schedule scans.cron --db idorplus.db
+  idorplus schedule --cron "@daily" --profile users.yaml --run-now`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runSchedule,
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+
+	scheduleCmd.Flags().String("cron", "", "Schedule of --profile, e.g. \"0 3 * * *\" or \"@every 6h\"")
+	scheduleCmd.Flags().String("profile", "", "Scan profile to run on --cron")
+	scheduleCmd.Flags().String("db", "idorplus.db", "Database for results and findings")
+	scheduleCmd.Flags().Bool("run-now", false, "Run every profile once at startup")
+	scheduleCmd.MarkFlagsRequiredTogether("cron", "profile")
+}
+
+func runSchedule(cmd *cobra.Command, args []string) {
+	spec, _ := cmd.Flags().GetString("cron")
+	profile, _ := cmd.Flags().GetString("profile")
+	dbPath, _ := cmd.Flags().GetString("db")
+	runNow, _ := cmd.Flags().GetBool("run-now")
+
+	var entries []*schedule.Entry
+	if len(args) > 0 {
+		var err error
+		if entries, err = schedule.ParseCrontab(args[0]); err != nil {
+			utils.Error.Printf("%v\n", err)
+			return
+		}
+	}
+	if spec != "" {
+		e, err := schedule.NewEntry(spec, profile)
+		if err != nil {
+			utils.Error.Printf("--cron: %v\n", err)
+			return
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		utils.Error.Println("Nothing to schedule: pass a crontab or --cron and --profile")
+		return
+	}
+
+	db, err := store.Open(dbPath)
+	if err != nil {
+		utils.Error.Printf("Failed to open database: %v\n", err)
+		return
+	}
+	defer db.Close()
+
+	sched, err := schedule.New(schedule.Config{NewClient: newClient, Store: db}, entries)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	sched.OnRun = func(run *schedule.Run, err error) {
+		switch {
+		case errors.Is(err, context.Canceled):
+		case err != nil:
+			utils.Error.Printf("%s: run failed: %v\n", run.Profile, err)
+		default:
+			utils.Success.Printf("%s: scan %d of %s done, %d findings, %d new\n",
+				run.Profile, run.ScanID, run.Target, run.Findings, len(run.New))
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	if runNow {
+		for _, e := range entries {
+			run, err := sched.RunEntry(ctx, e)
+			if ctx.Err() != nil {
+				return
+			}
+			sched.OnRun(run, err)
+		}
+	}
+
+	utils.Success.Printf("Scheduled %d profiles (database %s)\n", len(entries), dbPath)
+	sched.Run(ctx)
+	utils.Warning.Println("Scheduler stopped")
+}
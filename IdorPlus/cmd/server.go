@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"idorplus/pkg/server"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Run scans submitted over a REST API",
+	Long: `Serve a REST API that queues and runs scans. Jobs, results and findings are
+kept in the SQLite database, so queued and interrupted scans resume after a restart.
+
+  POST   /api/scans                 submit a scan (JSON options, e.g. {"url": "...", "cookies": "..."})
+  GET    /api/scans                 list jobs
+  GET    /api/scans/{id}            job status and progress
+  DELETE /api/scans/{id}            cancel a job
+  GET    /api/scans/{id}/findings   findings so far
+  GET    /api/scans/{id}/events     stream findings and status changes (server-sent events)
+  GET    /api/scans/{id}/report     report, ?format=json|markdown|html|burp
+  GET    /metrics                   Prometheus metrics: requests, errors, in-flight
+                                    requests, queue depths, jobs and findings
+
+With --token, Prometheus needs it too (authorization in the scrape config).
+
+  idorplus server --listen 127.0.0.1:8787 --workers 2 --token s3cret`,
+	Run: runServer,
+}
+
+func init() {
+	rootCmd.AddCommand(serverCmd)
+
+	serverCmd.Flags().String("listen", "127.0.0.1:8787", "Address to listen on")
+	serverCmd.Flags().String("db", "idorplus.db", "Database for jobs, results and findings")
+	serverCmd.Flags().Int("workers", 1, "Number of scans to run at once")
+	serverCmd.Flags().String("token", "", "Require this bearer token on every request")
+}
+
+func runServer(cmd *cobra.Command, args []string) {
+	listen, _ := cmd.Flags().GetString("listen")
+	dbPath, _ := cmd.Flags().GetString("db")
+	workers, _ := cmd.Flags().GetInt("workers")
+	token, _ := cmd.Flags().GetString("token")
+
+	cfg, err := loadConfig("")
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	defer startTracing(cfg)()
+
+	db, err := store.Open(dbPath)
+	if err != nil {
+		utils.Error.Printf("Failed to open database: %v\n", err)
+		return
+	}
+	defer db.Close()
+
+	srv, err := server.New(server.Config{
+		Scan:      cfg,
+		NewClient: newClient,
+		Store:     db,
+		Workers:   workers,
+		Token:     token,
+	})
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	if token == "" {
+		utils.Warning.Println("No --token set, the API is unauthenticated")
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	srv.Start(ctx)
+
+	httpServer := &http.Server{
+		Addr:              listen,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		utils.Warning.Println("Shutting down, running scans resume on the next start")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	utils.Success.Printf("Listening on http://%s (%d workers, database %s)\n", listen, workers, dbPath)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		utils.Error.Printf("Server failed: %v\n", err)
+		stop()
+	}
+	srv.Wait()
+}
@@ -0,0 +1,377 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// signalContext returns a context canceled on the first interrupt or
+// SIGTERM, stop releases the signals
+func signalContext() (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// loadConfig loads --config, or the first file on the config search path,
+// and applies --profile, the environment and --set overrides and the
+// overrides for target (if set). See the precedence in 'idorplus --help'.
+func loadConfig(target string) (*utils.Config, error) {
+	path := cfgFile
+	if path == "" {
+		path = utils.FindConfig()
+	}
+
+	cfg := utils.DefaultConfig()
+	if path == "" {
+		utils.Warning.Printf("Config not found, using defaults\n")
+	} else {
+		var err error
+		if cfg, err = utils.LoadConfig(path); err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+		utils.Debug.Printf("Using config %s\n", path)
+	}
+
+	if profile != "" {
+		if err := cfg.ApplyProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.LoadOverrides(settings); err != nil {
+		return nil, err
+	}
+	cfg, err := cfg.ForTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// envAnnotation marks the flags flagsFromEnv set, with their variable
+const envAnnotation = "idorplus_env"
+
+// flagsFromEnv sets flags not given on the command line from IDORPLUS_<FLAG>
+// environment variables, e.g. IDORPLUS_COOKIES_B for --cookies-b
+func flagsFromEnv(cmd *cobra.Command) error {
+	v := viper.New()
+	v.SetEnvPrefix(utils.EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	var errs []error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "help" || !v.IsSet(f.Name) {
+			return
+		}
+		env := utils.EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if err := cmd.Flags().Set(f.Name, v.GetString(f.Name)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", env, err))
+			return
+		}
+		cmd.Flags().SetAnnotation(f.Name, envAnnotation, []string{env})
+	})
+	return errors.Join(errs...)
+}
+
+// newClient creates a SmartClient and applies the global TLS, proxy and
+// signing options shared by every subcommand
+func newClient(cfg *utils.Config) (*client.SmartClient, error) {
+	c := client.NewSmartClient(cfg)
+
+	scope, err := newScope(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.SetScope(scope)
+	if err := setupTLS(c, cfg); err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+	if err := setupProxies(c, cfg); err != nil {
+		return nil, fmt.Errorf("invalid proxy configuration: %w", err)
+	}
+	if err := setupResolve(c, cfg); err != nil {
+		return nil, fmt.Errorf("invalid --resolve: %w", err)
+	}
+	if err := setupBinding(c, cfg); err != nil {
+		return nil, fmt.Errorf("invalid --bind: %w", err)
+	}
+	if err := setupSigning(c, cfg); err != nil {
+		return nil, fmt.Errorf("invalid signing configuration: %w", err)
+	}
+	if err := setupCache(c, cfg); err != nil {
+		return nil, fmt.Errorf("invalid cache configuration: %w", err)
+	}
+	if err := setupCSRF(c, cfg); err != nil {
+		return nil, fmt.Errorf("invalid CSRF configuration: %w", err)
+	}
+
+	return c, nil
+}
+
+// newScope combines the config's scope rules with --include and --exclude
+func newScope(cfg *utils.Config) (*client.Scope, error) {
+	include := append(slices.Clone(cfg.Scope.Include), scopeInclude...)
+	exclude := append(slices.Clone(cfg.Scope.Exclude), scopeExclude...)
+	scope, err := client.NewScope(include, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scope: %w", err)
+	}
+	return scope, nil
+}
+
+// resolveProxies merges --proxy values with the entries of --proxy-file
+func resolveProxies() ([]string, error) {
+	var proxies []string
+	for _, p := range proxyList {
+		normalized, err := client.ParseProxyLine(p)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, normalized)
+	}
+
+	if proxyFile != "" {
+		fromFile, err := client.LoadProxyFile(proxyFile)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, fromFile...)
+	}
+
+	return utils.UniqueStrings(proxies), nil
+}
+
+// setupTLS applies certificate verification and mutual TLS settings from
+// the global flags and config
+func setupTLS(c *client.SmartClient, cfg *utils.Config) error {
+	if insecure || cfg.Scanner.Insecure {
+		c.SetInsecure(true)
+		utils.Warning.Println("TLS certificate verification is disabled")
+	}
+	ca := cfg.Scanner.CACert
+	if caCert != "" {
+		ca = caCert
+	}
+	if ca != "" {
+		if err := c.SetCACert(ca); err != nil {
+			return err
+		}
+		utils.Info.Printf("Trusting CA certificate %s\n", ca)
+	}
+	if pins := append(slices.Clone(cfg.Scanner.PinSHA256), pinSHA256...); len(pins) > 0 {
+		if err := c.SetPins(pins); err != nil {
+			return err
+		}
+	}
+
+	cert, key := cfg.Scanner.ClientCert, cfg.Scanner.ClientKey
+	if clientCert != "" {
+		cert, key = clientCert, clientKey
+	}
+	if cert == "" {
+		return nil
+	}
+
+	if err := c.SetClientCertificate(cert, key); err != nil {
+		return err
+	}
+	utils.Info.Printf("Using client certificate %s\n", cert)
+	return nil
+}
+
+// setupProxies configures the upstream proxy or proxy rotation on the client
+// from the global flags and config
+func setupProxies(c *client.SmartClient, cfg *utils.Config) error {
+	upstream, ca := cfg.Scanner.UpstreamProxy, cfg.Scanner.UpstreamCA
+	if burpProxy {
+		upstream = defaultBurpProxy
+	}
+	if upstreamProxy != "" {
+		upstream = upstreamProxy
+	}
+	if upstreamCA != "" {
+		ca = upstreamCA
+	}
+
+	proxies, err := resolveProxies()
+	if err != nil {
+		return err
+	}
+
+	if upstream != "" {
+		if len(proxies) > 0 {
+			utils.Warning.Println("Upstream proxy set, ignoring rotation proxies (chain them in the intercepting proxy instead)")
+		}
+		if err := c.SetUpstreamProxy(upstream, ca); err != nil {
+			return err
+		}
+		utils.Info.Printf("Routing traffic through upstream proxy %s\n", upstream)
+		return nil
+	}
+
+	if len(proxies) > 0 {
+		c.SetProxies(proxies)
+	}
+	return nil
+}
+
+// setupResolve pins hosts from --resolve and config and sets --host-header
+func setupResolve(c *client.SmartClient, cfg *utils.Config) error {
+	entries := append(slices.Clone(cfg.Scanner.Resolve), resolveHosts...)
+	if len(entries) > 0 {
+		if err := c.SetResolve(entries); err != nil {
+			return err
+		}
+		if c.GetProxyManager().IsEnabled() || upstreamProxy != "" || burpProxy || cfg.Scanner.UpstreamProxy != "" {
+			utils.Warning.Println("Proxies resolve the target themselves, --resolve only applies to the proxy hosts")
+		}
+		utils.Info.Printf("Resolving %s\n", strings.Join(entries, ", "))
+	}
+
+	host := cfg.Scanner.HostHeader
+	if hostHeader != "" {
+		host = hostHeader
+	}
+	if host != "" {
+		c.SetHostHeader(host)
+		utils.Info.Printf("Sending Host: %s\n", host)
+	}
+	return nil
+}
+
+// setupBinding binds connections to --bind and sets --prefer-ipv6, or
+// their config settings
+func setupBinding(c *client.SmartClient, cfg *utils.Config) error {
+	source, prefer := cfg.Scanner.Bind, cfg.Scanner.PreferIPv6 || preferIPv6
+	if bindAddr != "" {
+		source = bindAddr
+	}
+	if source == "" && !prefer {
+		return nil
+	}
+
+	binding, err := client.NewBinding(source, prefer)
+	if err != nil {
+		return err
+	}
+	c.SetBinding(binding)
+	if source != "" {
+		utils.Info.Printf("Sending from %s\n", source)
+	}
+	return nil
+}
+
+// setupCache enables the response cache from --cache/--cache-dir or config
+func setupCache(c *client.SmartClient, cfg *utils.Config) error {
+	enabled, dir := cfg.Scanner.Cache, cfg.Scanner.CacheDir
+	if cacheDir != "" {
+		dir = cacheDir
+	}
+	if !enabled && !cacheEnabled && cacheDir == "" {
+		return nil
+	}
+
+	var ttl time.Duration
+	if cfg.Scanner.CacheTTL != "" {
+		d, err := time.ParseDuration(cfg.Scanner.CacheTTL)
+		if err != nil {
+			return err
+		}
+		ttl = d
+	}
+
+	if err := c.EnableCache(dir, ttl); err != nil {
+		return err
+	}
+	if dir != "" {
+		utils.Info.Printf("Caching responses in %s\n", dir)
+	} else {
+		utils.Info.Println("Caching responses in memory")
+	}
+	return nil
+}
+
+// setupCSRF installs the anti-CSRF token handler from the --csrf-* flags
+// or config
+func setupCSRF(c *client.SmartClient, cfg *utils.Config) error {
+	conf := cfg.CSRF
+	if csrfURL != "" {
+		conf.URL = csrfURL
+	}
+	if csrfExtract != "" {
+		conf.Extract = csrfExtract
+	}
+	if csrfHeader != "" {
+		conf.Header = csrfHeader
+	}
+	if csrfParam != "" {
+		conf.Param = csrfParam
+	}
+	if conf.URL == "" {
+		return nil
+	}
+
+	h, err := client.NewCSRFHandler(c, conf.URL, conf.Extract)
+	if err != nil {
+		return err
+	}
+	if conf.Header != "" {
+		h.Header = conf.Header
+	}
+	h.Param = conf.Param
+	h.Install()
+	utils.Info.Printf("Fetching anti-CSRF tokens from %s\n", conf.URL)
+	return nil
+}
+
+// setupSigning installs the AWS SigV4 signer from --aws-sigv4 or config
+func setupSigning(c *client.SmartClient, cfg *utils.Config) error {
+	aws := cfg.Signing.AWS
+	if awsSigV4 != "" {
+		parts := strings.SplitN(awsSigV4, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("--aws-sigv4 must be <region>/<service>, got %q", awsSigV4)
+		}
+		aws.Enabled = true
+		aws.Region, aws.Service = parts[0], parts[1]
+	}
+	if !aws.Enabled {
+		return nil
+	}
+
+	if aws.AccessKey == "" {
+		aws.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if aws.SecretKey == "" {
+		aws.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if aws.SessionToken == "" {
+		aws.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if aws.AccessKey == "" || aws.SecretKey == "" {
+		return fmt.Errorf("AWS credentials missing (set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	if aws.Region == "" || aws.Service == "" {
+		return fmt.Errorf("AWS region and service are required")
+	}
+
+	c.SetRequestSigner(client.NewSigV4Signer(aws.AccessKey, aws.SecretKey, aws.SessionToken, aws.Region, aws.Service))
+	utils.Info.Printf("Signing requests with AWS SigV4 (%s/%s)\n", aws.Region, aws.Service)
+	return nil
+}
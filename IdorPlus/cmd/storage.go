@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/storage"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Test S3, GCS and Azure Blob URLs for bucket listing and predictable keys",
+	Long: `Test object storage URLs found in responses for access beyond the object.
+
+The URLs are taken from --object or searched for in the responses of -u,
+pages or API calls that return pre-signed links or public bucket URLs.
+For each bucket:
+  - the bucket is listed
+  - signed URLs (X-Amz-Signature, X-Goog-Signature, SAS sig) are requested
+    without their signature
+  - keys predicted from the object's key are requested, its last number
+    replaced by --ids or the numbers around it
+
+Nothing is written.
+
+Example:
+  idorplus storage -u "https://app.target.com/api/invoices/41" -c "session=token"
+  idorplus storage --object "https://files.s3.amazonaws.com/invoices/00041.pdf" --ids 1,2,3`,
+	Run: runStorage,
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+
+	storageCmd.Flags().StringArrayP("url", "u", nil, "Page or API URL whose response holds storage URLs (repeatable)")
+	storageCmd.Flags().StringP("cookies", "c", "", "Session cookies for -u")
+	storageCmd.Flags().StringArray("object", nil, "Storage URL to test (repeatable)")
+	storageCmd.Flags().StringSlice("ids", nil, "IDs to put in predicted keys (default: the 3 numbers below and above the key's)")
+}
+
+func runStorage(cmd *cobra.Command, args []string) {
+	urls, _ := cmd.Flags().GetStringArray("url")
+	cookies, _ := cmd.Flags().GetString("cookies")
+	objectURLs, _ := cmd.Flags().GetStringArray("object")
+	ids, _ := cmd.Flags().GetStringSlice("ids")
+
+	target := ""
+	if len(urls) > 0 {
+		target = urls[0]
+	}
+	cfg, err := loadConfig(target)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		utils.Error.Printf("%v\n", err)
+		return
+	}
+	if cookies != "" {
+		c.GetSessionManager().AddSession("user", cookies)
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	var objects []storage.Object
+	for _, raw := range objectURLs {
+		obj, ok := storage.ParseObject(raw)
+		if !ok {
+			utils.Error.Printf("Not an S3, GCS or Azure Blob URL: %s\n", raw)
+			return
+		}
+		objects = append(objects, obj)
+	}
+	for _, u := range urls {
+		found := findStorageObjects(ctx, c, u, cookies != "")
+		utils.Info.Printf("%d storage URLs in %s\n", len(found), u)
+		objects = append(objects, found...)
+	}
+	if len(objects) == 0 {
+		utils.Warning.Println("No storage URLs to test")
+		return
+	}
+
+	tester := storage.NewTester(c)
+	tested := make(map[string]bool)
+	for _, obj := range objects {
+		// The same object is often linked several times
+		if tested[obj.Base+"/"+obj.Key] {
+			continue
+		}
+		tested[obj.Base+"/"+obj.Key] = true
+
+		utils.PrintSection(fmt.Sprintf("%s bucket %s", obj.Provider, obj.Bucket))
+		result, err := tester.Test(ctx, obj, ids)
+		if err != nil {
+			utils.Error.Printf("Test stopped: %v\n", err)
+		}
+		printStorageResult(result)
+	}
+}
+
+// findStorageObjects fetches a URL and returns the storage URLs in its
+// response
+func findStorageObjects(ctx context.Context, c *client.SmartClient, u string, session bool) []storage.Object {
+	req := c.Request(ctx)
+	if session {
+		fuzzer.PrepareRequest(c, req, &fuzzer.FuzzJob{Session: "user"})
+	}
+	resp, err := req.Get(u)
+	if err != nil {
+		utils.Warning.Printf("Failed to fetch %s: %v\n", u, err)
+		return nil
+	}
+	return storage.FindObjects(string(resp.Body()))
+}
+
+func printStorageResult(r *storage.Result) {
+	pterm.Printf("Object: %s\n", r.Object.URL)
+	tableData := pterm.TableData{{"Check", "Request", "Status", "Result"}}
+	verdict := func(ok bool) string {
+		if ok {
+			return "accessible"
+		}
+		return "denied"
+	}
+	tableData = append(tableData, []string{"listing", r.Listing.URL, fmt.Sprintf("%d", r.Listing.Status), verdict(r.Listable)})
+	if r.Unsigned != nil {
+		tableData = append(tableData, []string{"no signature", r.Unsigned.URL, fmt.Sprintf("%d", r.Unsigned.Status), verdict(r.Unsigned.Accessible)})
+	}
+	for _, p := range r.Enumerated {
+		tableData = append(tableData, []string{"predicted key", p.Key, fmt.Sprintf("%d", p.Status), verdict(p.Accessible)})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	if r.Listable {
+		utils.Error.Printf("⚠️  Bucket listable, e.g. %v\n", r.Keys)
+	}
+	if r.Unsigned != nil && r.Unsigned.Accessible {
+		utils.Error.Println("⚠️  Signed object readable without its signature")
+	}
+	for _, p := range r.Enumerated {
+		if p.Accessible {
+			utils.Error.Printf("⚠️  Predicted key readable: %s\n", p.Key)
+		}
+	}
+	if !r.Vulnerable() {
+		utils.Success.Println("Nothing beyond the object is accessible")
+	}
+}
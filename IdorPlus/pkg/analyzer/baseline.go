@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// BaselineProfile summarizes several responses to the same kind of request,
+// e.g. for IDs that don't exist. Responses are matched against the spread
+// of the samples rather than one snapshot, so timestamps, request IDs or
+// the echoed ID don't make a "not found" page look like a new resource.
+type BaselineProfile struct {
+	Samples int
+	// Status is the most common status code of the samples
+	Status  int
+	MeanLen float64
+	StdDev  float64
+	// Tokens are the words present in every sample
+	Tokens map[string]bool
+}
+
+const (
+	// minLengthTolerance is the smallest length band, for samples that
+	// happen not to vary
+	minLengthTolerance = 32
+	// minTokenOverlap is the share of stable tokens a matching response
+	// must contain
+	minTokenOverlap = 0.9
+)
+
+// NewBaselineProfile builds a profile from samples. It returns nil when
+// there are none.
+func NewBaselineProfile(samples []*resty.Response) *BaselineProfile {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	p := &BaselineProfile{Samples: len(samples)}
+	statuses := make(map[int]int)
+	for _, s := range samples {
+		statuses[s.StatusCode()]++
+		p.MeanLen += float64(BodySize(s))
+	}
+	p.MeanLen /= float64(len(samples))
+	for status, n := range statuses {
+		if n > statuses[p.Status] || (n == statuses[p.Status] && status < p.Status) {
+			p.Status = status
+		}
+	}
+
+	var variance float64
+	for _, s := range samples {
+		d := float64(BodySize(s)) - p.MeanLen
+		variance += d * d
+	}
+	p.StdDev = math.Sqrt(variance / float64(len(samples)))
+
+	p.Tokens = tokenSet(samples[0].Body())
+	for _, s := range samples[1:] {
+		tokens := tokenSet(s.Body())
+		for t := range p.Tokens {
+			if !tokens[t] {
+				delete(p.Tokens, t)
+			}
+		}
+	}
+	return p
+}
+
+// LengthTolerance is how far a body length may be from the mean and still
+// match: three standard deviations, at least 5% of the mean or 32 bytes
+func (p *BaselineProfile) LengthTolerance() float64 {
+	return math.Max(3*p.StdDev, math.Max(0.05*p.MeanLen, minLengthTolerance))
+}
+
+// TokenOverlap is the share of the profile's stable tokens found in body
+func (p *BaselineProfile) TokenOverlap(body []byte) float64 {
+	if len(p.Tokens) == 0 {
+		return 1
+	}
+	tokens := tokenSet(body)
+	found := 0
+	for t := range p.Tokens {
+		if tokens[t] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(p.Tokens))
+}
+
+// Matches reports whether resp falls within the profile: same status, a
+// length within LengthTolerance and most of the stable tokens
+func (p *BaselineProfile) Matches(resp *resty.Response) bool {
+	if resp == nil || resp.StatusCode() != p.Status {
+		return false
+	}
+	if math.Abs(float64(BodySize(resp))-p.MeanLen) > p.LengthTolerance() {
+		return false
+	}
+	return p.TokenOverlap(resp.Body()) >= minTokenOverlap
+}
+
+// tokenSet splits a body into its distinct words
+func tokenSet(body []byte) map[string]bool {
+	words := strings.FieldsFunc(string(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
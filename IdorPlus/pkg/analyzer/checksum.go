@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check digit schemes of numeric IDs
+const (
+	ChecksumLuhn  = "luhn"  // one digit, as in card and account numbers
+	ChecksumMod97 = "mod97" // two digits, ISO 7064 MOD 97-10 as in IBANs
+)
+
+// Checksums are the supported check digit schemes
+var Checksums = []string{ChecksumLuhn, ChecksumMod97}
+
+// checksumSamples is how many IDs must carry valid check digits before a
+// scheme is detected, as random digits pass Luhn one time in 10 and
+// mod 97 one time in 97
+var checksumSamples = map[string]int{ChecksumLuhn: 3, ChecksumMod97: 2}
+
+// ValidateChecksum checks a check digit scheme name
+func ValidateChecksum(scheme string) error {
+	for _, c := range Checksums {
+		if c == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown checksum %q, want one of %s", scheme, strings.Join(Checksums, ", "))
+}
+
+// CheckDigits returns the check digits of a string of digits, empty for
+// anything else
+func CheckDigits(digits, scheme string) string {
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return ""
+	}
+	switch scheme {
+	case ChecksumLuhn:
+		return string(LuhnDigit(digits))
+	case ChecksumMod97:
+		return fmt.Sprintf("%02d", 98-mod97(digits+"00"))
+	}
+	return ""
+}
+
+// checkDigitCount is the number of check digits of a scheme
+func checkDigitCount(scheme string) int {
+	if scheme == ChecksumMod97 {
+		return 2
+	}
+	return 1
+}
+
+// HasCheckDigits reports whether id is digits ending in valid check
+// digits of scheme
+func HasCheckDigits(id, scheme string) bool {
+	n := checkDigitCount(scheme)
+	if len(id) <= n || strings.Trim(id, "0123456789") != "" {
+		return false
+	}
+	return CheckDigits(id[:len(id)-n], scheme) == id[len(id)-n:]
+}
+
+// StripCheckDigits returns id without the check digits of scheme
+func StripCheckDigits(id, scheme string) string {
+	return id[:len(id)-checkDigitCount(scheme)]
+}
+
+// DetectChecksum returns the check digit scheme every numeric ID follows,
+// empty when there are too few IDs to tell it from chance
+func DetectChecksum(ids []string) string {
+	// mod 97 first, a number passing it is less likely a coincidence
+	for _, scheme := range []string{ChecksumMod97, ChecksumLuhn} {
+		if len(ids) < checksumSamples[scheme] {
+			continue
+		}
+		valid := true
+		for _, id := range ids {
+			if !HasCheckDigits(id, scheme) {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return scheme
+		}
+	}
+	return ""
+}
+
+// LuhnDigit returns the Luhn check digit of a string of digits
+func LuhnDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// mod97 returns a string of digits modulo 97, whatever its length
+func mod97(digits string) int {
+	r := 0
+	for _, c := range digits {
+		r = (r*10 + int(c-'0')) % 97
+	}
+	return r
+}
@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// exportTypes are text content types of generated exports
+var exportTypes = []string{"text/csv", "application/csv", "text/tab-separated-values", "application/vnd.ms-excel", "text/calendar", "text/vcard", "text/x-vcard"}
+
+// ownerKeys are the metadata fields naming the author or owner of a file
+var ownerKeys = []string{"Author", "XPAuthor", "Artist", "Copyright", "dc:creator", "cp:lastModifiedBy", "meta:initial-creator", "Emails"}
+
+// emailPattern matches email addresses in exports
+var emailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+
+// maxExportEmails caps the addresses kept from an export
+const maxExportEmails = 5
+
+// InspectDownload describes a downloaded file: a binary body like
+// InspectFile, or a text export such as a CSV or an attachment. The file
+// name of a Content-Disposition is kept as Filename. It is nil for other
+// responses.
+func InspectDownload(resp *resty.Response) *FileInfo {
+	body := resp.Body()
+	_, params, err := mime.ParseMediaType(resp.Header().Get("Content-Disposition"))
+	attachment := err == nil && strings.HasPrefix(strings.ToLower(resp.Header().Get("Content-Disposition")), "attachment")
+
+	f := InspectFile(body)
+	if f == nil {
+		contentType := mediaType(resp)
+		if len(body) == 0 || (!slices.Contains(exportTypes, contentType) && !attachment) {
+			return nil
+		}
+		sum := sha256.Sum256(body)
+		f = &FileInfo{
+			ContentType: contentType,
+			Size:        len(body),
+			SHA256:      hex.EncodeToString(sum[:]),
+			Metadata:    make(map[string]string),
+		}
+		f.textMetadata(body)
+	}
+	if name := params["filename"]; name != "" {
+		f.set("Filename", name)
+	}
+	return f
+}
+
+// textMetadata counts the rows of a CSV export and keeps its header and
+// the first email addresses, which usually name the owner
+func (f *FileInfo) textMetadata(body []byte) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if rows, err := r.ReadAll(); err == nil && len(rows) > 1 && len(rows[0]) > 1 {
+		f.set("Columns", strings.Join(rows[0], ", "))
+		f.set("Rows", fmt.Sprintf("%d", len(rows)-1))
+	}
+
+	var emails []string
+	for _, m := range emailPattern.FindAllString(string(body), -1) {
+		if len(emails) == maxExportEmails {
+			break
+		}
+		if !slices.Contains(emails, m) {
+			emails = append(emails, m)
+		}
+	}
+	f.set("Emails", strings.Join(emails, ", "))
+}
+
+// Owners returns the metadata values naming the author or owner of the
+// file, e.g. a PDF's Author or the emails of a CSV export
+func (f *FileInfo) Owners() []string {
+	var owners []string
+	for _, k := range ownerKeys {
+		if v := f.Metadata[k]; v != "" && !slices.Contains(owners, v) {
+			owners = append(owners, v)
+		}
+	}
+	return owners
+}
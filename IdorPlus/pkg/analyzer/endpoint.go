@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Endpoint kinds, see ClassifyEndpoint
+const (
+	EndpointObject     = "object"     // a single record or file, what IDORs expose
+	EndpointCollection = "collection" // a list of records
+	EndpointStatic     = "static"     // a stylesheet, script, image or font anyone gets
+	EndpointLogin      = "login"      // redirects to a login page
+	EndpointDenied     = "denied"     // 401 or 403
+	EndpointOther      = "other"      // anything else, e.g. a 404 or a server error
+)
+
+// EndpointClass is what a probe of an endpoint found
+type EndpointClass struct {
+	Kind        string `json:"kind"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Records     int    `json:"records,omitempty"` // of a collection
+	// AuthRequired is set when the endpoint denies a request without
+	// credentials that it answers with them
+	AuthRequired bool `json:"auth_required,omitempty"`
+	// Public is set when the endpoint answers the same without credentials
+	Public bool `json:"public,omitempty"`
+}
+
+// String describes the class, e.g. "collection of 12 records, auth required"
+func (ec *EndpointClass) String() string {
+	s := ec.Kind
+	switch {
+	case ec.Kind == EndpointCollection:
+		s += fmt.Sprintf(" of %d records", ec.Records)
+	case ec.Kind == EndpointStatic && ec.ContentType != "":
+		s += " " + ec.ContentType
+	case ec.Kind == EndpointDenied || ec.Kind == EndpointOther:
+		s += fmt.Sprintf(" (status %d)", ec.Status)
+	}
+	if ec.AuthRequired {
+		s += ", auth required"
+	}
+	if ec.Public {
+		s += ", public"
+	}
+	return s
+}
+
+// ClassifyEndpoint classifies an endpoint by its response to resp's
+// request and, when that carried credentials, by anon, the same request
+// without them. anon is nil when there were no credentials to leave out.
+// Assets only count as static when they are served without credentials.
+func ClassifyEndpoint(resp, anon *resty.Response) *EndpointClass {
+	ec := &EndpointClass{Status: resp.StatusCode(), ContentType: mediaType(resp)}
+	body := resp.Body()
+	switch {
+	case IsLoginRedirect(RedirectChain(resp)):
+		ec.Kind = EndpointLogin
+	case ec.Status == 401 || ec.Status == 403:
+		ec.Kind = EndpointDenied
+	case ec.Status < 200 || ec.Status >= 300:
+		ec.Kind = EndpointOther
+	case IsCollection(body):
+		ec.Kind = EndpointCollection
+		ec.Records = CountRecords(body)
+	case isStaticType(ec.ContentType):
+		ec.Kind = EndpointStatic
+	default:
+		ec.Kind = EndpointObject
+	}
+	if anon == nil || ec.Kind == EndpointLogin || ec.Kind == EndpointDenied || ec.Kind == EndpointOther {
+		return ec
+	}
+
+	if anon.StatusCode() == 401 || anon.StatusCode() == 403 || IsLoginRedirect(RedirectChain(anon)) {
+		ec.AuthRequired = true
+		if ec.Kind == EndpointStatic {
+			ec.Kind = EndpointObject
+		}
+	} else if anon.StatusCode() == ec.Status && NewResponseComparator(resp).Compare(anon).BodySimilarity >= publicSimilarity {
+		ec.Public = true
+	}
+	return ec
+}
+
+// publicSimilarity is how alike the responses with and without
+// credentials must be for an endpoint to count as public
+const publicSimilarity = 0.95
+
+// mediaType returns the media type of resp's Content-Type, without
+// parameters
+func mediaType(resp *resty.Response) string {
+	ct := resp.Header().Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(ct))
+}
+
+// isStaticType reports whether a media type is that of a web asset
+func isStaticType(mt string) bool {
+	switch {
+	case mt == "text/css", strings.HasSuffix(mt, "javascript"):
+		return true
+	case strings.HasPrefix(mt, "font/"), strings.HasPrefix(mt, "application/font"):
+		return true
+	case strings.HasPrefix(mt, "image/"), strings.HasPrefix(mt, "video/"), strings.HasPrefix(mt, "audio/"):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,367 @@
+package analyzer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// FileInfo describes a binary response body, e.g. a downloaded PDF or
+// image. Text heuristics don't work on these: the body is compared by hash
+// and PII is looked for in the metadata.
+type FileInfo struct {
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+	// Metadata holds document and EXIF fields that often name the owner,
+	// such as Author, Artist or Creator
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+const (
+	// maxMetadataValue truncates long metadata values
+	maxMetadataValue = 256
+	// maxZipMetadata is the largest document properties entry read from an
+	// archive
+	maxZipMetadata = 64 << 10
+)
+
+// InspectFile returns a description of body, or nil when it is text (HTML,
+// JSON, XML...) and the usual heuristics apply
+func InspectFile(body []byte) *FileInfo {
+	if len(body) == 0 {
+		return nil
+	}
+	contentType := http.DetectContentType(body)
+	if strings.HasPrefix(contentType, "text/") {
+		return nil
+	}
+
+	sum := sha256.Sum256(body)
+	f := &FileInfo{
+		ContentType: contentType,
+		Size:        len(body),
+		SHA256:      hex.EncodeToString(sum[:]),
+		Metadata:    make(map[string]string),
+	}
+	switch contentType {
+	case "application/pdf":
+		f.pdfMetadata(body)
+	case "image/jpeg":
+		f.exifMetadata(body)
+	case "image/png":
+		f.pngMetadata(body)
+	case "application/zip":
+		f.zipMetadata(body)
+	}
+	return f
+}
+
+// String summarizes the file for reports, one metadata field per line
+func (f *FileInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, %d bytes, sha256 %s", f.ContentType, f.Size, f.SHA256)
+	for _, k := range f.keys() {
+		fmt.Fprintf(&b, "\n%s: %s", k, f.Metadata[k])
+	}
+	return b.String()
+}
+
+// Text joins the metadata values, for PII patterns
+func (f *FileInfo) Text() string {
+	values := make([]string, 0, len(f.Metadata))
+	for _, k := range f.keys() {
+		values = append(values, f.Metadata[k])
+	}
+	return strings.Join(values, "\n")
+}
+
+func (f *FileInfo) keys() []string {
+	keys := make([]string, 0, len(f.Metadata))
+	for k := range f.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *FileInfo) set(key, value string) {
+	value = strings.TrimSpace(strings.ToValidUTF8(strings.Trim(value, "\x00"), ""))
+	if value == "" {
+		return
+	}
+	if len(value) > maxMetadataValue {
+		value = value[:maxMetadataValue]
+	}
+	if _, ok := f.Metadata[key]; !ok {
+		f.Metadata[key] = value
+	}
+}
+
+var (
+	// pdfInfoString matches literal strings of the document information
+	// dictionary, e.g. /Author (Jane Doe)
+	pdfInfoString = regexp.MustCompile(`/(Author|Creator|Producer|Title|Subject|Keywords)\s*\(((?:\\.|[^\\)])*)\)`)
+	// pdfInfoHex matches hex strings, e.g. /Author <FEFF004A...>
+	pdfInfoHex = regexp.MustCompile(`/(Author|Creator|Producer|Title|Subject|Keywords)\s*<([0-9A-Fa-f\s]+)>`)
+	// xmlProperty matches document properties of Office (docProps/core.xml)
+	// and OpenDocument (meta.xml) files
+	xmlProperty = regexp.MustCompile(`<(dc:creator|dc:title|cp:lastModifiedBy|meta:initial-creator)>([^<]*)<`)
+)
+
+// pdfMetadata reads the information dictionary. It is usually stored
+// uncompressed, so the raw bytes are searched.
+func (f *FileInfo) pdfMetadata(body []byte) {
+	for _, m := range pdfInfoString.FindAllSubmatch(body, -1) {
+		f.set(string(m[1]), pdfString(unescapePDF(m[2])))
+	}
+	for _, m := range pdfInfoHex.FindAllSubmatch(body, -1) {
+		raw, err := hex.DecodeString(strings.Join(strings.Fields(string(m[2])), ""))
+		if err == nil {
+			f.set(string(m[1]), pdfString(raw))
+		}
+	}
+}
+
+// unescapePDF resolves the backslash escapes of a PDF literal string
+func unescapePDF(s []byte) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		default:
+			if c >= '0' && c <= '7' {
+				v := 0
+				for n := 0; n < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; n++ {
+					v = v*8 + int(s[i]-'0')
+					i++
+				}
+				i--
+				out = append(out, byte(v))
+			} else {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// pdfString decodes a PDF text string, UTF-16BE when it starts with a BOM
+func pdfString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return decodeUTF16(b[2:], binary.BigEndian)
+	}
+	return string(b)
+}
+
+func decodeUTF16(b []byte, order binary.ByteOrder) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, order.Uint16(b[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// EXIF tags read from the first IFD
+var exifTags = map[uint16]string{
+	0x010E: "ImageDescription",
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0131: "Software",
+	0x013B: "Artist",
+	0x8298: "Copyright",
+	0x9C9D: "XPAuthor",
+}
+
+const exifGPSPointer = 0x8825
+
+// exifMetadata reads the EXIF segment of a JPEG
+func (f *FileInfo) exifMetadata(body []byte) {
+	for i := 2; i+4 <= len(body) && body[i] == 0xFF; {
+		marker := body[i+1]
+		size := int(binary.BigEndian.Uint16(body[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(body) {
+			// Image data follows
+			return
+		}
+		segment := body[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			f.tiffMetadata(segment[6:])
+			return
+		}
+		i += 2 + size
+	}
+}
+
+// tiffMetadata reads the EXIF TIFF structure: the tags of the first IFD
+// and the GPS position, if any
+func (f *FileInfo) tiffMetadata(tiff []byte) {
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	entries := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	for _, e := range entries {
+		if name, ok := exifTags[e.tag]; ok {
+			if e.tag == 0x9C9D {
+				f.set(name, decodeUTF16(e.data, binary.LittleEndian))
+			} else if e.typ == 2 {
+				f.set(name, string(e.data))
+			}
+		}
+		if e.tag == exifGPSPointer && len(e.data) == 4 {
+			f.gpsMetadata(order, readIFD(tiff, order, order.Uint32(e.data)))
+		}
+	}
+}
+
+func (f *FileInfo) gpsMetadata(order binary.ByteOrder, entries []ifdEntry) {
+	values := make(map[uint16]ifdEntry, len(entries))
+	for _, e := range entries {
+		values[e.tag] = e
+	}
+	coordinate := func(ref, value uint16) (string, bool) {
+		v := values[value].data
+		if len(v) != 24 {
+			return "", false
+		}
+		var deg float64
+		for i, scale := range []float64{1, 60, 3600} {
+			num, den := order.Uint32(v[i*8:]), order.Uint32(v[i*8+4:])
+			if den == 0 {
+				return "", false
+			}
+			deg += float64(num) / float64(den) / scale
+		}
+		return fmt.Sprintf("%.6f %s", deg, strings.Trim(string(values[ref].data), "\x00")), true
+	}
+	lat, okLat := coordinate(1, 2)
+	lon, okLon := coordinate(3, 4)
+	if okLat && okLon {
+		f.set("GPS", lat+", "+lon)
+	}
+}
+
+type ifdEntry struct {
+	tag  uint16
+	typ  uint16
+	data []byte
+}
+
+// exifTypeSizes are the sizes of the TIFF field types read here: BYTE,
+// ASCII, SHORT, LONG, RATIONAL and UNDEFINED
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1}
+
+// readIFD returns the entries of the IFD at offset with their values
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) []ifdEntry {
+	if int64(offset)+2 > int64(len(tiff)) {
+		return nil
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	var entries []ifdEntry
+	for i := 0; i < n; i++ {
+		at := int(offset) + 2 + i*12
+		if at+12 > len(tiff) {
+			break
+		}
+		e := ifdEntry{tag: order.Uint16(tiff[at:]), typ: order.Uint16(tiff[at+2:])}
+		size, ok := exifTypeSizes[e.typ]
+		if !ok {
+			continue
+		}
+		length := int64(size) * int64(order.Uint32(tiff[at+4:]))
+		if length <= 4 {
+			e.data = tiff[at+8 : at+8+int(length)]
+		} else {
+			start := int64(order.Uint32(tiff[at+8:]))
+			if start+length > int64(len(tiff)) {
+				continue
+			}
+			e.data = tiff[start : start+length]
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// pngMetadata reads the uncompressed text chunks of a PNG
+func (f *FileInfo) pngMetadata(body []byte) {
+	for i := 8; i+8 <= len(body); {
+		size := int(binary.BigEndian.Uint32(body[i:]))
+		kind := string(body[i+4 : i+8])
+		if size < 0 || i+12+size > len(body) || kind == "IEND" {
+			return
+		}
+		data := body[i+8 : i+8+size]
+		switch kind {
+		case "tEXt":
+			if key, value, ok := bytes.Cut(data, []byte{0}); ok {
+				f.set(string(key), string(value))
+			}
+		case "iTXt":
+			// keyword, compression flag and method, language, translated
+			// keyword, text
+			key, rest, ok := bytes.Cut(data, []byte{0})
+			if ok && len(rest) > 2 && rest[0] == 0 {
+				parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+				if len(parts) == 3 {
+					f.set(string(key), string(parts[2]))
+				}
+			}
+		}
+		i += 12 + size
+	}
+}
+
+// zipMetadata counts the entries of an archive and reads the document
+// properties of Office and OpenDocument files
+func (f *FileInfo) zipMetadata(body []byte) {
+	r, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return
+	}
+	f.set("Entries", fmt.Sprintf("%d", len(r.File)))
+	for _, zf := range r.File {
+		if zf.Name != "docProps/core.xml" && zf.Name != "meta.xml" {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			continue
+		}
+		data, _ := io.ReadAll(io.LimitReader(rc, maxZipMetadata))
+		rc.Close()
+		for _, m := range xmlProperty.FindAllSubmatch(data, -1) {
+			f.set(string(m[1]), html.UnescapeString(string(m[2])))
+		}
+	}
+}
@@ -15,6 +15,8 @@ const (
 	TypeMD5
 	TypeSHA1
 	TypeBase64
+	TypeDate      // a date and a counter, e.g. 20240115-0042
+	TypeComposite // numbers joined by a separator, e.g. 17-42
 )
 
 type IdentifierAnalyzer struct{}
@@ -47,6 +49,11 @@ func (ia *IdentifierAnalyzer) DetectType(id string) IDType {
 		return TypeUUID
 	}
 
+	// Structured IDs, before base64 which also matches 2024/01/15/42
+	if p := learnStructure(id); p != nil {
+		return p.Type
+	}
+
 	// Base64 check (Simple heuristic)
 	if matched, _ := regexp.MatchString(`^[A-Za-z0-9+/]+={0,2}$`, id); matched {
 		// Ensure it has some length to avoid false positives with short strings
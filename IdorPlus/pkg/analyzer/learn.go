@@ -0,0 +1,201 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Charsets of the variable part of learned IDs
+const (
+	CharsetDigits   = "digits"
+	CharsetHexLower = "hex"
+	CharsetHexUpper = "HEX"
+	CharsetAlpha    = "alpha"
+	CharsetAlnum    = "alnum"
+)
+
+// LearnedPattern is the template shared by several observed IDs: a fixed
+// Prefix and Suffix around a variable part. A numeric variable part is a
+// counter, possibly followed by a check digit.
+type LearnedPattern struct {
+	Prefix string
+	Suffix string
+	// Length is the length of every sample, 0 when they differ
+	Length int
+	// Charset and VarLength describe the variable part, VarLength being 0
+	// when it differs between samples
+	Charset   string
+	VarLength int
+
+	// Counter is set when the variable part is a number. Width is its
+	// zero-padded width (0 when not padded), Min and Max the observed
+	// counters and Step the largest step dividing all their differences.
+	// Counters are the distinct observed counters in order.
+	Counter  bool
+	Width    int
+	Min, Max int64
+	Step     int64
+	Counters []int64
+	// Checksum is the scheme of check digits ending the counter, see
+	// DetectChecksum
+	Checksum string
+}
+
+// LearnFromSamples infers the template shared by known IDs, e.g. harvested
+// from responses or listed in a file. It needs at least two distinct IDs.
+func (ia *IdentifierAnalyzer) LearnFromSamples(ids []string) (*LearnedPattern, error) {
+	var samples []string
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(samples, id) {
+			samples = append(samples, id)
+		}
+	}
+	if len(samples) < 2 {
+		return nil, errors.New("learning an ID pattern needs at least two distinct IDs")
+	}
+
+	p := &LearnedPattern{Prefix: samples[0], Length: len(samples[0])}
+	for _, s := range samples[1:] {
+		p.Prefix = commonPrefix(p.Prefix, s)
+		if len(s) != p.Length {
+			p.Length = 0
+		}
+	}
+	p.Suffix = samples[0][len(p.Prefix):]
+	for _, s := range samples[1:] {
+		p.Suffix = commonSuffix(p.Suffix, s[len(p.Prefix):])
+	}
+
+	// Digits shared by every sample belong to the counter, not the prefix
+	middles := make([]string, len(samples))
+	for i, s := range samples {
+		middles[i] = s[len(p.Prefix) : len(s)-len(p.Suffix)]
+	}
+	if allDigits(middles) {
+		head := len(strings.TrimRight(p.Prefix, "0123456789"))
+		tail := len(p.Suffix) - len(strings.TrimLeft(p.Suffix, "0123456789"))
+		for i, s := range samples {
+			middles[i] = s[head : len(s)-len(p.Suffix)+tail]
+		}
+		p.Prefix, p.Suffix = p.Prefix[:head], p.Suffix[tail:]
+	}
+
+	p.VarLength = len(middles[0])
+	for _, m := range middles {
+		if len(m) != p.VarLength {
+			p.VarLength = 0
+		}
+	}
+	p.Charset = charsetOf(middles)
+	if p.Charset != CharsetDigits || slices.Contains(middles, "") {
+		return p, nil
+	}
+
+	if p.Checksum = DetectChecksum(middles); p.Checksum != "" {
+		for i, m := range middles {
+			middles[i] = StripCheckDigits(m, p.Checksum)
+		}
+	}
+	var counters []int64
+	for _, m := range middles {
+		n, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			// Too long for a counter, e.g. a random number
+			p.Checksum = ""
+			return p, nil
+		}
+		counters = append(counters, n)
+		if len(m) > 1 && m[0] == '0' && p.VarLength > 0 {
+			p.Width = len(m)
+		}
+	}
+	p.Counter, p.Counters = true, counters
+	slices.Sort(p.Counters)
+	p.Counters = slices.Compact(p.Counters)
+	p.Min, p.Max = p.Counters[0], p.Counters[len(p.Counters)-1]
+	for i := 1; i < len(p.Counters); i++ {
+		p.Step = gcd(p.Step, p.Counters[i]-p.Counters[i-1])
+	}
+	p.Step = max(p.Step, 1)
+	return p, nil
+}
+
+// String describes the pattern in the syntax of payload templates, e.g.
+// INV-{num:06d} or user_{hex:32}
+func (p *LearnedPattern) String() string {
+	switch {
+	case p.Counter:
+		num := "{num}"
+		if p.Width > 0 {
+			num = fmt.Sprintf("{num:0%dd}", p.Width)
+		}
+		if p.Checksum != "" {
+			num = num[:len(num)-1] + "|" + p.Checksum + "}"
+		}
+		return p.Prefix + num + p.Suffix
+	case p.VarLength > 0:
+		return fmt.Sprintf("%s{%s:%d}%s", p.Prefix, p.Charset, p.VarLength, p.Suffix)
+	}
+	return p.Prefix + "{" + p.Charset + "}" + p.Suffix
+}
+
+// Format builds the ID for a counter, adding the check digit if any
+func (p *LearnedPattern) Format(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	if len(digits) < p.Width {
+		digits = strings.Repeat("0", p.Width-len(digits)) + digits
+	}
+	digits += CheckDigits(digits, p.Checksum)
+	return p.Prefix + digits + p.Suffix
+}
+
+func allDigits(values []string) bool {
+	for _, v := range values {
+		if strings.Trim(v, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// charsetOf returns the smallest charset holding every value
+func charsetOf(values []string) string {
+	all := strings.Join(values, "")
+	switch {
+	case strings.Trim(all, "0123456789") == "":
+		return CharsetDigits
+	case strings.Trim(all, "0123456789abcdef") == "":
+		return CharsetHexLower
+	case strings.Trim(all, "0123456789ABCDEF") == "":
+		return CharsetHexUpper
+	case strings.Trim(all, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == "":
+		return CharsetAlpha
+	}
+	return CharsetAlnum
+}
+
+func commonPrefix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+func commonSuffix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return a[len(a)-n:]
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
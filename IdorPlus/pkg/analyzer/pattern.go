@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IDPattern is the structure learned from a sample ID, so that generated
+// IDs look like the real ones
+type IDPattern struct {
+	Type IDType
+	// Date IDs: the date in Layout (a time format such as 20060102 or
+	// 2006/01/02), then Separator and the counter, zero-padded to Width
+	// digits when the sample is
+	Layout    string
+	Date      time.Time
+	Separator string
+	Counter   int64
+	Width     int
+	// Composite IDs: the parts joined by Separator, each an optional
+	// prefix such as "u" followed by a number
+	Parts []IDPart
+}
+
+// IDPart is one part of a composite ID
+type IDPart struct {
+	Prefix string
+	Number int64
+	Width  int // zero-padded width, 0 when not padded
+}
+
+var (
+	datedID     = regexp.MustCompile(`^((?:19|20)\d{2})([-/.]?)(0[1-9]|1[0-2])([-/.]?)(0[1-9]|[12]\d|3[01])([-/_.])(\d{1,9})$`)
+	compositeID = regexp.MustCompile(`^[A-Za-z]*\d{1,18}$`)
+)
+
+// LearnPattern detects the type of a sample ID and, for date-stamped and
+// composite IDs, their structure
+func (ia *IdentifierAnalyzer) LearnPattern(id string) *IDPattern {
+	if p := learnStructure(id); p != nil {
+		return p
+	}
+	return &IDPattern{Type: ia.DetectType(id)}
+}
+
+// learnStructure parses date-stamped and composite IDs, nil otherwise
+func learnStructure(id string) *IDPattern {
+	if m := datedID.FindStringSubmatch(id); m != nil && m[2] == m[4] {
+		layout := "2006" + m[2] + "01" + m[2] + "02"
+		if date, err := time.Parse(layout, m[1]+m[2]+m[3]+m[4]+m[5]); err == nil {
+			counter, _ := strconv.ParseInt(m[7], 10, 64)
+			return &IDPattern{Type: TypeDate, Layout: layout, Date: date, Separator: m[6], Counter: counter, Width: paddedWidth(m[7])}
+		}
+	}
+
+	sep := strings.IndexAny(id, "-_:.|")
+	if sep <= 0 {
+		return nil
+	}
+	p := &IDPattern{Type: TypeComposite, Separator: id[sep : sep+1]}
+	for _, s := range strings.Split(id, p.Separator) {
+		if !compositeID.MatchString(s) {
+			return nil
+		}
+		digits := strings.TrimLeft(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		n, _ := strconv.ParseInt(digits, 10, 64)
+		p.Parts = append(p.Parts, IDPart{Prefix: s[:len(s)-len(digits)], Number: n, Width: paddedWidth(digits)})
+	}
+	return p
+}
+
+// paddedWidth is the width of a zero-padded number, 0 when not padded
+func paddedWidth(digits string) int {
+	if len(digits) > 1 && digits[0] == '0' {
+		return len(digits)
+	}
+	return 0
+}
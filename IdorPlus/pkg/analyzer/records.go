@@ -0,0 +1,65 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// envelopeKeys name the list of a paginated or wrapped response
+var envelopeKeys = []string{"data", "items", "results", "records", "rows", "hits", "entries", "list", "content", "docs"}
+
+// CountRecords returns how many objects a JSON body holds: the length of a
+// top-level array or of the list in an envelope such as {"data": [...]},
+// and 1 for any other object. It is 0 for anything else.
+func CountRecords(body []byte) int {
+	var doc interface{}
+	if json.Unmarshal(body, &doc) != nil {
+		return 0
+	}
+	if list, ok := recordList(doc); ok {
+		return len(list)
+	}
+	if _, ok := doc.(map[string]interface{}); ok {
+		return 1
+	}
+	return 0
+}
+
+// IsCollection reports whether a JSON body is a list of records, a
+// top-level array or an envelope, even an empty one
+func IsCollection(body []byte) bool {
+	var doc interface{}
+	if json.Unmarshal(body, &doc) != nil {
+		return false
+	}
+	_, ok := recordList(doc)
+	return ok
+}
+
+// Records returns the records of a JSON collection, numbers kept as
+// json.Number so IDs compare exactly. ok is false for anything but a
+// top-level array or an envelope.
+func Records(body []byte) (records []interface{}, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if dec.Decode(&doc) != nil {
+		return nil, false
+	}
+	return recordList(doc)
+}
+
+// recordList returns the top-level array or the list in an envelope
+func recordList(doc interface{}) ([]interface{}, bool) {
+	switch v := doc.(type) {
+	case []interface{}:
+		return v, true
+	case map[string]interface{}:
+		for _, key := range envelopeKeys {
+			if list, ok := v[key].([]interface{}); ok {
+				return list, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Redirect is one hop of a redirect chain
+type Redirect struct {
+	Status int    `json:"status"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// maxRedirects bounds the walk back through a chain
+const maxRedirects = 20
+
+// loginPath matches redirect targets that ask for authentication
+var loginPath = regexp.MustCompile(`(?i)/(log-?in|sign-?in|auth(enticate)?|sso|oauth2?|cas|session/new|unauthori[sz]ed|forbidden|access-denied)(/|\.|$)`)
+
+// RedirectChain returns the redirects behind resp, oldest first. A final
+// 3xx that wasn't followed, e.g. one leaving the scope, is the last hop.
+func RedirectChain(resp *resty.Response) []Redirect {
+	if resp == nil || resp.RawResponse == nil || resp.RawResponse.Request == nil {
+		return nil
+	}
+	raw := resp.RawResponse
+
+	var chain []Redirect
+	if loc := raw.Header.Get("Location"); loc != "" && raw.StatusCode >= 300 && raw.StatusCode < 400 {
+		to := loc
+		if u, err := raw.Request.URL.Parse(loc); err == nil {
+			to = u.String()
+		}
+		chain = append(chain, Redirect{Status: raw.StatusCode, From: raw.Request.URL.String(), To: to})
+	}
+	// Each followed request carries the redirect response that caused it
+	for req := raw.Request; req.Response != nil && len(chain) < maxRedirects; req = req.Response.Request {
+		hop := Redirect{Status: req.Response.StatusCode, To: req.URL.String()}
+		if req.Response.Request == nil {
+			break
+		}
+		hop.From = req.Response.Request.URL.String()
+		chain = append(chain, hop)
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// IsLoginRedirect reports whether a chain ends up asking for
+// authentication, i.e. access was denied
+func IsLoginRedirect(chain []Redirect) bool {
+	for _, hop := range chain {
+		if u, err := url.Parse(hop.To); err == nil && loginPath.MatchString(u.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedirectTarget identifies where a chain leads, independent of the
+// requested ID: the path segments of the final target that don't appear in
+// the original request. /orders/5 redirected to /users/17/orders/5 gives
+// "/users/17". It is empty without redirects.
+func RedirectTarget(chain []Redirect) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	from, err := url.Parse(chain[0].From)
+	if err != nil {
+		return ""
+	}
+	to, err := url.Parse(chain[len(chain)-1].To)
+	if err != nil {
+		return ""
+	}
+
+	// Drop the requested segments, matched in order from the end, so that
+	// an owner ID equal to the requested one is kept
+	requested := strings.Split(strings.Trim(from.Path, "/"), "/")
+	segments := strings.Split(strings.Trim(to.Path, "/"), "/")
+	var kept []string
+	j := len(requested) - 1
+	for i := len(segments) - 1; i >= 0; i-- {
+		if j >= 0 && segments[i] == requested[j] {
+			j--
+			continue
+		}
+		kept = append(kept, segments[i])
+	}
+	slices.Reverse(kept)
+	target := "/" + strings.Join(kept, "/")
+	if to.Host != from.Host {
+		target = to.Host + target
+	}
+	return target
+}
@@ -30,8 +30,8 @@ func (rc *ResponseComparator) Compare(resp *resty.Response) *ComparisonResult {
 	result.StatusMatch = (rc.Baseline.StatusCode() == resp.StatusCode())
 
 	// Content length
-	baselineLen := len(rc.Baseline.Body())
-	respLen := len(resp.Body())
+	baselineLen := BodySize(rc.Baseline)
+	respLen := BodySize(resp)
 	result.LengthDiff = int(math.Abs(float64(baselineLen - respLen)))
 
 	// Body similarity (Levenshtein based)
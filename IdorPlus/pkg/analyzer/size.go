@@ -0,0 +1,29 @@
+package analyzer
+
+import (
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// BodySizeHeader is added to responses whose body was cut at the client's
+// body cap, with the size of the whole body, or "unknown" when the server
+// didn't say
+const BodySizeHeader = "X-Idorplus-Body-Size"
+
+// BodySize returns the size of the body of resp as sent, which is more
+// than len(resp.Body()) when it was truncated. Sizes are compared on it,
+// so two large files that only differ past the cap still differ.
+func BodySize(resp *resty.Response) int {
+	if v := resp.Header().Get(BodySizeHeader); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return len(resp.Body())
+}
+
+// Truncated reports whether the body of resp was cut at the body cap
+func Truncated(resp *resty.Response) bool {
+	return resp.Header().Get(BodySizeHeader) != ""
+}
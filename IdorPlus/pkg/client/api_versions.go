@@ -0,0 +1,119 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionSegment matches an API version path segment such as v2 or V1.1
+var versionSegment = regexp.MustCompile(`^[vV](\d+)(?:\.(\d+))?$`)
+
+// ParseVersion parses an API version path segment such as v2 or V1.1; minor
+// is empty without one. ok is false for other segments.
+func ParseVersion(segment string) (major int, minor string, ok bool) {
+	m := versionSegment.FindStringSubmatch(segment)
+	if m == nil {
+		return 0, "", false
+	}
+	major, err := strconv.Atoi(m[1])
+	return major, m[2], err == nil
+}
+
+// maxOlderVersions bounds how many older versions are tried
+const maxOlderVersions = 5
+
+// mobilePrefixes are path prefixes mobile APIs are often served under
+var mobilePrefixes = []string{"mobile", "m", "app", "android", "ios"}
+
+// GenerateVersionVariants returns the URLs of other versions of the same
+// API: older and newer version segments (/v2/ to /v1/ and /v3/), no
+// version, internal APIs (/api/ to /api/internal/) and mobile prefixes.
+// Access checks are often only enforced on the newest public version.
+func GenerateVersionVariants(rawURL string) []PathMutation {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	path := u.EscapedPath()
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 0 || segments[0] == "" {
+		return nil
+	}
+
+	var out []PathMutation
+	seen := map[string]bool{path: true}
+	add := func(technique string, segs ...string) {
+		p := "/" + strings.Join(segs, "/")
+		if strings.HasSuffix(path, "/") {
+			p += "/"
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, PathMutation{Technique: technique, URL: rebuildURL(u, p)})
+		}
+	}
+	with := func(i int, replacement ...string) []string {
+		segs := append([]string{}, segments[:i]...)
+		segs = append(segs, replacement...)
+		return append(segs, segments[i+1:]...)
+	}
+
+	version, api := -1, -1
+	for i, s := range segments {
+		if _, _, ok := ParseVersion(s); ok && version < 0 {
+			version = i
+		}
+		if api < 0 && strings.EqualFold(s, "api") {
+			api = i
+		}
+	}
+
+	if version >= 0 {
+		n, minor, _ := ParseVersion(segments[version])
+		prefix := segments[version][:1]
+		for v := n - 1; v >= 1 && v >= n-maxOlderVersions; v-- {
+			add(fmt.Sprintf("older version %s%d", prefix, v), with(version, fmt.Sprintf("%s%d", prefix, v))...)
+		}
+		if minor != "" {
+			add(fmt.Sprintf("major version %s%d", prefix, n), with(version, fmt.Sprintf("%s%d", prefix, n))...)
+		}
+		add(fmt.Sprintf("newer version %s%d", prefix, n+1), with(version, fmt.Sprintf("%s%d", prefix, n+1))...)
+		add("unversioned", with(version)...)
+	} else if api >= 0 {
+		for v := 1; v <= 3; v++ {
+			add(fmt.Sprintf("versioned v%d", v), with(api, segments[api], fmt.Sprintf("v%d", v))...)
+		}
+	}
+
+	// Internal APIs, or the public one when internal is requested
+	internal := -1
+	for i, s := range segments {
+		if strings.EqualFold(s, "internal") {
+			internal = i
+			break
+		}
+	}
+	switch {
+	case internal >= 0:
+		add("public API", with(internal)...)
+	case api >= 0:
+		add("internal API", with(api, segments[api], "internal")...)
+		add("internal prefix", append([]string{"internal"}, segments...)...)
+	default:
+		add("internal prefix", append([]string{"internal"}, segments...)...)
+	}
+
+	// Mobile APIs
+	for _, p := range mobilePrefixes {
+		if !strings.EqualFold(segments[0], p) {
+			add("mobile prefix /"+p, append([]string{p}, segments...)...)
+		}
+	}
+	if api >= 0 {
+		add("mobile API", with(api, "mobile-api")...)
+	}
+	return out
+}
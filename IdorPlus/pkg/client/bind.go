@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Binding picks the local address connections originate from, e.g. the
+// interface of a jump box an engagement is scoped to, and the address
+// family tried first.
+type Binding struct {
+	Source     string
+	PreferIPv6 bool
+	// the local address of each family, nil when not bound, and the zone
+	// of a link-local v6
+	v4, v6 net.IP
+	zone   string
+}
+
+// NewBinding binds to source, a local IP address or an interface name. An
+// interface binds one address of each family it has, so both IPv4 and
+// IPv6 targets stay reachable. An empty source binds nothing and only
+// sets the preference.
+func NewBinding(source string, preferIPv6 bool) (*Binding, error) {
+	b := &Binding{Source: source, PreferIPv6: preferIPv6}
+	if source == "" {
+		return b, nil
+	}
+	if ip := net.ParseIP(source); ip != nil {
+		b.set(ip)
+		return b, nil
+	}
+
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("bind %q: not an IP address or interface: %w", source, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("bind %q: %w", source, err)
+	}
+	// Link-local IPv6 addresses need a zone and rarely route, so they
+	// are only used when nothing else is there
+	var linkLocal net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipNet.IP.To4() == nil && ipNet.IP.IsLinkLocalUnicast() {
+			linkLocal = ipNet.IP
+			continue
+		}
+		b.set(ipNet.IP)
+	}
+	if b.v6 == nil && linkLocal != nil && b.v4 == nil {
+		b.v6, b.zone = linkLocal, iface.Name
+	}
+	if b.v4 == nil && b.v6 == nil {
+		return nil, fmt.Errorf("bind %q: interface has no IP address", source)
+	}
+	return b, nil
+}
+
+// set records ip as the local address of its family, the first one wins
+func (b *Binding) set(ip net.IP) {
+	if ip.To4() != nil {
+		if b.v4 == nil {
+			b.v4 = ip
+		}
+	} else if b.v6 == nil {
+		b.v6 = ip
+	}
+}
+
+// bound reports whether a local address is set
+func (b *Binding) bound() bool {
+	return b.v4 != nil || b.v6 != nil
+}
+
+// local returns the local address to reach ip from, nil if the family
+// isn't bound
+func (b *Binding) local(ip net.IP) net.IP {
+	if ip.To4() != nil {
+		return b.v4
+	}
+	return b.v6
+}
+
+// order sorts addresses by the preferred family, keeping the resolver's
+// order within a family
+func (b *Binding) order(addrs []net.IPAddr) []net.IPAddr {
+	var first, second []net.IPAddr
+	for _, a := range addrs {
+		if (a.IP.To4() == nil) == b.PreferIPv6 {
+			first = append(first, a)
+		} else {
+			second = append(second, a)
+		}
+	}
+	return append(first, second...)
+}
+
+// Dial resolves the host itself and tries its addresses in the preferred
+// order, from the bound local address of each family
+func (b *Binding) Dial(d *net.Dialer) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		var errs []error
+		for _, a := range b.order(addrs) {
+			dialer := *d
+			if local := b.local(a.IP); local != nil {
+				dialer.LocalAddr = &net.TCPAddr{IP: local, Zone: b.zone}
+			} else if b.bound() {
+				errs = append(errs, fmt.Errorf("%s: no local address of its family on %s", a.String(), b.Source))
+				continue
+			}
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(a.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("%s has no address", host)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// BlockSignature describes a WAF/CDN block or challenge page
+type BlockSignature struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Denied only applies the pattern to responses with a blocking status,
+	// for markers such as captcha widgets that normal pages embed too
+	Denied bool
+}
+
+// BlockPageDetector recognises block and challenge pages served by WAFs and CDNs
+// so they are not mistaken for real application responses
+type BlockPageDetector struct {
+	signatures []BlockSignature
+}
+
+// NewBlockPageDetector creates a detector with the built-in signature set
+func NewBlockPageDetector() *BlockPageDetector {
+	return &BlockPageDetector{
+		signatures: []BlockSignature{
+			// Cloudflare
+			{Name: "cloudflare_1020", Pattern: regexp.MustCompile(`(?i)error code:?\s*1020`)},
+			{Name: "cloudflare_block", Pattern: regexp.MustCompile(`(?i)attention required! \| cloudflare|used cloudflare to restrict access`)},
+			{Name: "cloudflare_challenge", Pattern: regexp.MustCompile(`(?i)cf-chl-|cf_chl_opt|just a moment\.\.\.</title>`)},
+
+			// Akamai
+			{Name: "akamai_reference", Pattern: regexp.MustCompile(`(?i)reference\s*#\d+\.[0-9a-f]+\.\d+\.[0-9a-f]+`)},
+			{Name: "akamai_denied", Pattern: regexp.MustCompile(`(?i)you don't have permission to access .* on this server`)},
+
+			// Imperva / Incapsula
+			{Name: "incapsula", Pattern: regexp.MustCompile(`(?i)incapsula incident id|_incapsula_resource`)},
+
+			// Sucuri
+			{Name: "sucuri", Pattern: regexp.MustCompile(`(?i)sucuri website firewall|sucuri cloudproxy`)},
+
+			// AWS WAF
+			{Name: "aws_waf", Pattern: regexp.MustCompile(`(?i)<h1>403 forbidden</h1>.*request blocked`)},
+
+			// Bot managers: the DataDome block page loads its captcha from
+			// captcha-delivery.com, any page may carry their JS tags
+			{Name: "datadome", Pattern: regexp.MustCompile(`(?i)<iframe[^>]+src=["']https://geo\.captcha-delivery\.com/`)},
+			{Name: "perimeterx", Pattern: regexp.MustCompile(`(?i)px-captcha`), Denied: true},
+
+			// Generic captchas, embedded as widgets in normal pages too
+			{Name: "captcha", Pattern: regexp.MustCompile(`(?i)g-recaptcha|h-captcha|hcaptcha\.com|cf-turnstile`), Denied: true},
+		},
+	}
+}
+
+// blockingStatus reports whether a status is one block and challenge pages
+// are served with
+func blockingStatus(status int) bool {
+	switch status {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// AddSignature registers a custom block page signature
+func (b *BlockPageDetector) AddSignature(name, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	b.signatures = append(b.signatures, BlockSignature{Name: name, Pattern: re})
+	return nil
+}
+
+// Check reports whether the response is a block/challenge page and which signature matched
+func (b *BlockPageDetector) Check(resp *resty.Response) (bool, string) {
+	if resp == nil {
+		return false, ""
+	}
+	return b.CheckRaw(resp.StatusCode(), resp.Header(), resp.Body())
+}
+
+// CheckRaw is Check for a plain status, header and body
+func (b *BlockPageDetector) CheckRaw(status int, header http.Header, body []byte) (bool, string) {
+	// Cloudflare marks managed challenges explicitly
+	if strings.EqualFold(header.Get("Cf-Mitigated"), "challenge") {
+		return true, "cloudflare_challenge"
+	}
+
+	if len(body) == 0 {
+		return false, ""
+	}
+
+	for _, sig := range b.signatures {
+		if sig.Denied && !blockingStatus(status) {
+			continue
+		}
+		if sig.Pattern.Match(body) {
+			return true, sig.Name
+		}
+	}
+
+	return false, ""
+}
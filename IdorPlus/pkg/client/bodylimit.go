@@ -0,0 +1,58 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"idorplus/pkg/analyzer"
+)
+
+// defaultMaxBody is the body cap without a config
+const defaultMaxBody = 5 << 20
+
+// limitTransport reads at most max bytes of a body, so a huge download
+// can't exhaust memory; the rest is never read. The size the server gave
+// is kept in analyzer.BodySizeHeader. A max of 0 reads bodies whole.
+type limitTransport struct {
+	base http.RoundTripper
+	max  int64
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	// Only the client records sizes, a server could otherwise fake them
+	resp.Header.Del(analyzer.BodySizeHeader)
+	if t.max <= 0 || resp.Body == nil || (resp.ContentLength >= 0 && resp.ContentLength <= t.max) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.max+1))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > t.max {
+		size := "unknown"
+		if resp.ContentLength >= 0 {
+			size = strconv.FormatInt(resp.ContentLength, 10)
+		}
+		body = truncateBody(resp, body, t.max, size)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// truncateBody cuts body to max bytes and records size as the size of the
+// whole body, unless an earlier cut recorded it already
+func truncateBody(resp *http.Response, body []byte, max int64, size string) []byte {
+	if resp.Header.Get(analyzer.BodySizeHeader) == "" {
+		resp.Header.Set(analyzer.BodySizeHeader, size)
+	}
+	return body[:max]
+}
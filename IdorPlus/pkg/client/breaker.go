@@ -0,0 +1,121 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"idorplus/pkg/utils"
+)
+
+// ErrCircuitOpen is returned for requests to a host whose circuit breaker
+// is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker stops sending to a host that keeps failing. After
+// Threshold consecutive refused, timed out, dropped or 502-504 requests
+// the host's circuit opens and its requests fail with ErrCircuitOpen for
+// Cooldown. Then a single probe goes through: a response closes the
+// circuit, another failure opens it again.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time // zero while closed
+	probing   bool
+}
+
+// NewCircuitBreaker creates a breaker, threshold 0 disables it
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, hosts: make(map[string]*circuit)}
+}
+
+// Open reports whether the circuit of host is open, i.e. its requests are
+// not sent
+func (b *CircuitBreaker) Open(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.hosts[host]
+	return c != nil && !c.openUntil.IsZero() && (time.Now().Before(c.openUntil) || c.probing)
+}
+
+// RoundTripper wraps base so requests pass the breaker, base itself when
+// the breaker is disabled
+func (b *CircuitBreaker) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	if b == nil || b.Threshold <= 0 {
+		return base
+	}
+	return &breakerTransport{base: base, breaker: b}
+}
+
+type breakerTransport struct {
+	base    http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.breaker.allow(host); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	t.breaker.record(host, resp, err)
+	return resp, err
+}
+
+// allow returns ErrCircuitOpen unless a request to host may be sent
+func (b *CircuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.hosts[host]
+	if c == nil || c.openUntil.IsZero() {
+		return nil
+	}
+	if wait := time.Until(c.openUntil); wait > 0 {
+		return fmt.Errorf("%w for %s, retrying in %s", ErrCircuitOpen, host, wait.Round(time.Second))
+	}
+	if c.probing {
+		return fmt.Errorf("%w for %s, probing", ErrCircuitOpen, host)
+	}
+	c.probing = true
+	return nil
+}
+
+// record counts the outcome of a request to host. Cancelled requests and
+// 429s say nothing about whether the host is up.
+func (b *CircuitBreaker) record(host string, resp *http.Response, err error) {
+	class := Classify(resp, err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.hosts[host]
+	if c == nil {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	probe := c.probing
+	c.probing = false
+
+	switch {
+	case class == "" && err == nil:
+		if !c.openUntil.IsZero() {
+			utils.Info.Printf("%s is answering again, circuit breaker closed\n", host)
+		}
+		c.failures, c.openUntil = 0, time.Time{}
+	case class == FailureRefused || class == FailureTimeout || class == FailureNetwork || class == Failure5xx:
+		c.failures++
+		if probe || c.failures >= b.Threshold {
+			if c.openUntil.IsZero() {
+				utils.Warning.Printf("%s failed %d times in a row (%s), circuit breaker open for %s\n", host, c.failures, class, b.Cooldown)
+			}
+			c.openUntil = time.Now().Add(b.Cooldown)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBudgetExhausted is returned for requests beyond the scan's budget
+var ErrBudgetExhausted = errors.New("scan budget exhausted")
+
+// Budget caps how many requests a scan sends and for how long, as
+// engagements often stipulate. Requests beyond it are not sent. Retries,
+// baselines and re-tests count like any other request.
+type Budget struct {
+	// MaxRequests and MaxDuration are the caps, 0 for none
+	MaxRequests int64
+	MaxDuration time.Duration
+
+	start time.Time
+	sent  atomic.Int64
+}
+
+// NewBudget creates a budget whose time starts now
+func NewBudget(maxRequests int64, maxDuration time.Duration) *Budget {
+	return &Budget{MaxRequests: maxRequests, MaxDuration: maxDuration, start: time.Now()}
+}
+
+// Sent returns how many requests the budget let through
+func (b *Budget) Sent() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.sent.Load()
+}
+
+// Elapsed returns how long ago the budget started
+func (b *Budget) Elapsed() time.Duration {
+	if b == nil {
+		return 0
+	}
+	return time.Since(b.start)
+}
+
+// Exhausted returns why no more requests may be sent, "" while they may
+func (b *Budget) Exhausted() string {
+	if b == nil {
+		return ""
+	}
+	if b.MaxRequests > 0 && b.sent.Load() >= b.MaxRequests {
+		return fmt.Sprintf("request budget of %d used up", b.MaxRequests)
+	}
+	if b.MaxDuration > 0 && time.Since(b.start) >= b.MaxDuration {
+		return fmt.Sprintf("time budget of %s used up", b.MaxDuration)
+	}
+	return ""
+}
+
+// take counts a request about to be sent, or returns an error wrapping
+// ErrBudgetExhausted if it may not be
+func (b *Budget) take() error {
+	if b == nil {
+		return nil
+	}
+	for {
+		if reason := b.Exhausted(); reason != "" {
+			return fmt.Errorf("%w: %s", ErrBudgetExhausted, reason)
+		}
+		n := b.sent.Load()
+		if b.sent.CompareAndSwap(n, n+1) {
+			return nil
+		}
+	}
+}
+
+// SetBudget caps the requests sent from now on, nil removes the cap
+func (c *SmartClient) SetBudget(budget *Budget) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+}
+
+// GetBudget returns the budget set with SetBudget
+func (c *SmartClient) GetBudget() *Budget {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.budget
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// volatileHeaders change between otherwise identical requests and are left
+// out of the cache key
+var volatileHeaders = map[string]bool{
+	"User-Agent":      true,
+	"Accept-Encoding": true,
+	"X-Amz-Date":      true,
+}
+
+// cachedResponse is a stored response; it is also the on-disk format
+type cachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// inflightCall is a request currently on the wire that identical requests wait for
+type inflightCall struct {
+	done chan struct{}
+	resp *cachedResponse
+}
+
+// ResponseCache serves repeated identical requests from memory (and optionally
+// disk) and collapses concurrent duplicates into a single request. Requests are
+// keyed on method, URL, body and all non-volatile headers, which includes the
+// session cookies and Authorization header. Only GET and HEAD requests are
+// cached; others change state and always go to the server, as do requests
+// marked WithoutCache or sent with Cache-Control: no-cache.
+type ResponseCache struct {
+	mu       sync.Mutex
+	entries  map[string]*cachedResponse
+	inflight map[string]*inflightCall
+	dir      string
+	ttl      time.Duration
+
+	// BlockPages, when set, keeps WAF block pages out of the cache so retries hit the wire
+	BlockPages *BlockPageDetector
+
+	hits   int64
+	misses int64
+}
+
+// NewResponseCache creates a cache. dir enables persistence across runs when
+// non-empty; ttl of 0 keeps entries forever.
+func NewResponseCache(dir string, ttl time.Duration) (*ResponseCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ResponseCache{
+		entries:  make(map[string]*cachedResponse),
+		inflight: make(map[string]*inflightCall),
+		dir:      dir,
+		ttl:      ttl,
+	}, nil
+}
+
+// RoundTripper wraps base so responses are served from and stored in the cache
+func (rc *ResponseCache) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	return &cacheTransport{cache: rc, base: base}
+}
+
+// Stats returns the number of cache hits and misses
+func (rc *ResponseCache) Stats() (hits, misses int64) {
+	return atomic.LoadInt64(&rc.hits), atomic.LoadInt64(&rc.misses)
+}
+
+type cacheTransport struct {
+	cache *ResponseCache
+	base  http.RoundTripper
+}
+
+type noCacheCtxKey struct{}
+
+// WithoutCache marks requests sent with ctx to go to the server, neither
+// served from nor stored in the cache, e.g. to check a response is
+// reproducible
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheCtxKey{}, true)
+}
+
+// bypassesCache reports whether req goes to the server: it is neither GET
+// nor HEAD, was marked with WithoutCache, or asks for no cache
+func bypassesCache(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return true
+	}
+	if bypass, _ := req.Context().Value(noCacheCtxKey{}).(bool); bypass {
+		return true
+	}
+	for _, directive := range strings.Split(req.Header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "no-store":
+			return true
+		}
+	}
+	return false
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if bypassesCache(req) {
+		return t.base.RoundTrip(req)
+	}
+	key, err := cacheKey(req)
+	if err != nil {
+		return t.base.RoundTrip(req)
+	}
+
+	rc := t.cache
+	for {
+		rc.mu.Lock()
+		if cached := rc.lookup(key); cached != nil {
+			rc.mu.Unlock()
+			atomic.AddInt64(&rc.hits, 1)
+			return cached.toResponse(req), nil
+		}
+
+		// Wait for an identical request already on the wire
+		if call, ok := rc.inflight[key]; ok {
+			rc.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			if call.resp != nil {
+				atomic.AddInt64(&rc.hits, 1)
+				return call.resp.toResponse(req), nil
+			}
+			// The leader failed or got an uncacheable response, try again
+			continue
+		}
+
+		call := &inflightCall{done: make(chan struct{})}
+		rc.inflight[key] = call
+		rc.mu.Unlock()
+
+		atomic.AddInt64(&rc.misses, 1)
+		resp, err := t.base.RoundTrip(req)
+		stored := rc.store(key, resp, err)
+
+		rc.mu.Lock()
+		call.resp = stored
+		delete(rc.inflight, key)
+		rc.mu.Unlock()
+		close(call.done)
+
+		if stored != nil {
+			return stored.toResponse(req), nil
+		}
+		return resp, err
+	}
+}
+
+// lookup returns a fresh entry from memory or disk (caller must hold the lock)
+func (rc *ResponseCache) lookup(key string) *cachedResponse {
+	entry, ok := rc.entries[key]
+	if !ok && rc.dir != "" {
+		if data, err := os.ReadFile(filepath.Join(rc.dir, key+".json")); err == nil {
+			var disk cachedResponse
+			if json.Unmarshal(data, &disk) == nil {
+				entry = &disk
+				rc.entries[key] = entry
+			}
+		}
+	}
+	if entry == nil {
+		return nil
+	}
+	if rc.ttl > 0 && time.Since(entry.StoredAt) > rc.ttl {
+		delete(rc.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// store reads the response body and caches it. Errors, 429, 5xx and block
+// pages are not cached.
+func (rc *ResponseCache) store(key string, resp *http.Response, err error) *cachedResponse {
+	if err != nil || resp == nil {
+		return nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		// The body is gone; hand back what was read so the caller sees the error
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return nil
+	}
+
+	if rc.BlockPages != nil {
+		if blocked, _ := rc.BlockPages.CheckRaw(resp.StatusCode, resp.Header, body); blocked {
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			return nil
+		}
+	}
+
+	entry := &cachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   time.Now(),
+	}
+
+	rc.mu.Lock()
+	rc.entries[key] = entry
+	rc.mu.Unlock()
+
+	if rc.dir != "" {
+		if data, err := json.Marshal(entry); err == nil {
+			os.WriteFile(filepath.Join(rc.dir, key+".json"), data, 0o644)
+		}
+	}
+
+	return entry
+}
+
+// toResponse builds a fresh http.Response for req from the entry
+func (e *cachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheKey hashes method, URL, body and non-volatile headers, restoring the body
+func cacheKey(req *http.Request) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.String() + "\n"))
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		if !volatileHeaders[http.CanonicalHeaderKey(name)] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Write([]byte(strings.ToLower(name) + ": " + strings.Join(req.Header[name], ",") + "\n"))
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		h.Write([]byte("\n"))
+		h.Write(body)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
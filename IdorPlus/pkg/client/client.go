@@ -3,15 +3,29 @@ package client
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"idorplus/pkg/utils"
 
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// tracer traces rate limit waits, see the tracing package
+var tracer = otel.Tracer("idorplus/pkg/client")
+
+// ErrCaptured is returned for requests recorded by SetCapture instead of sent
+var ErrCaptured = errors.New("request captured, not sent")
+
 // SmartClient is a production-grade HTTP client with WAF bypass capabilities
 type SmartClient struct {
 	client       *resty.Client
@@ -19,17 +33,42 @@ type SmartClient struct {
 	sessions     *SessionManager
 	rateLimiter  *RateLimiter
 	proxyManager *ProxyManager
+	blockPages   *BlockPageDetector
 	config       *utils.Config
+	retry        *RetryPolicy
+	breaker      *CircuitBreaker
 	mu           sync.RWMutex
 	userAgents   []string
+
+	upstreamProxy *url.URL
+	upstreamCAs   []*x509.Certificate
+	caCerts       []*x509.Certificate
+	clientCerts   []tls.Certificate
+	insecure      bool
+	pins          [][]byte
+	certWarning   sync.Once
+	signer        RequestSigner
+	mutator       RequestMutator
+	cache         *ResponseCache
+	scope         *Scope
+	safety        *Safety
+	budget        *Budget
+	capture       func(*http.Request)
+	resolver      *Resolver
+	binding       *Binding
+	hostHeader    string
+	requestHooks  []namedHook[RequestHook]
+	responseHooks []namedHook[ResponseHook]
 }
 
 // NewSmartClient creates a new smart client with all production features
 func NewSmartClient(config *utils.Config) *SmartClient {
 	r := resty.New()
-
-	// Set custom transport with TLS spoofing
-	r.SetTransport(NewCustomTransport())
+	r.SetLogger(restyLogger{})
+	// Each session keeps its own cookies, a shared jar would send one
+	// user's cookies with another's requests
+	r.SetCookieJar(nil)
+	r.SetHeader("Accept-Encoding", acceptEncoding)
 
 	// Parse and set timeout
 	timeout := 10 * time.Second
@@ -40,17 +79,29 @@ func NewSmartClient(config *utils.Config) *SmartClient {
 	}
 	r.SetTimeout(timeout)
 
-	// Set retry count
-	maxRetries := 3
-	if config != nil && config.Scanner.MaxRetries > 0 {
-		maxRetries = config.Scanner.MaxRetries
-	}
-	r.SetRetryCount(maxRetries)
-	r.SetRetryWaitTime(500 * time.Millisecond)
-	r.SetRetryMaxWaitTime(5 * time.Second)
+	// Failed requests are retried here and nowhere else, as the policy says
+	retry := newRetryPolicy(config)
+	r.SetRetryCount(retry.MaxRetries)
+	r.SetRetryWaitTime(0)
+	r.SetRetryMaxWaitTime(retry.MaxBackoff)
+	r.AddRetryCondition(func(resp *resty.Response, err error) bool {
+		// nil when a request middleware failed
+		if resp == nil {
+			return false
+		}
+		return retry.ShouldRetry(resp.Request.Method, resp.RawResponse, err)
+	})
+	r.SetRetryAfter(func(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+		return retry.Delay(resp.Request.Attempt-1, resp.RawResponse), nil
+	})
 
-	// Disable TLS verification for testing
-	r.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	breakerThreshold, breakerCooldown := 10, 30*time.Second
+	if config != nil {
+		breakerThreshold = config.Scanner.BreakerThreshold
+		if d, err := time.ParseDuration(config.Scanner.BreakerCooldown); err == nil {
+			breakerCooldown = d
+		}
+	}
 
 	// Initialize WAF Bypass
 	var wafMode string
@@ -98,20 +149,118 @@ func NewSmartClient(config *utils.Config) *SmartClient {
 		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
 	}
 
-	return &SmartClient{
+	c := &SmartClient{
 		client:       r,
 		wafBypass:    waf,
 		sessions:     NewSessionManager(),
 		rateLimiter:  rateLimiter,
 		proxyManager: proxyManager,
+		blockPages:   NewBlockPageDetector(),
 		config:       config,
 		userAgents:   userAgents,
+		retry:        retry,
+		breaker:      NewCircuitBreaker(breakerThreshold, breakerCooldown),
+		// Destructive requests are refused until a caller allows them
+		safety: &Safety{},
 	}
+
+	// Set custom transport with TLS spoofing
+	r.SetTransport(c.buildTransport())
+
+	// Session headers go on the request itself, before resty merges in the
+	// client's default headers, so headers set per request still win
+	r.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		if session := c.sessions.sessionOf(req.Context()); session != nil {
+			session.setHeaders(req)
+		}
+		return nil
+	})
+
+	// Mutation and signing must run on the final request, after resty has
+	// applied headers, cookies and body
+	r.SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+		c.mu.RLock()
+		signer, mutator, scope, safety, capture := c.signer, c.mutator, c.scope, c.safety, c.capture
+		budget := c.budget
+		hostHeader, hooks := c.hostHeader, c.requestHooks
+		c.mu.RUnlock()
+
+		if session := c.sessions.sessionOf(req.Context()); session != nil {
+			session.apply(req)
+		}
+		// A Host given per request, e.g. by -H, wins
+		if hostHeader != "" && req.Host == req.URL.Host {
+			req.Host = hostHeader
+		}
+		if mutator != nil {
+			if err := mutator.Mutate(req); err != nil {
+				return err
+			}
+		}
+		if err := runRequestHooks(hooks, req); err != nil {
+			return err
+		}
+		anonymous := isAnonymous(req.Context())
+		if anonymous {
+			stripCredentials(req)
+		}
+		// Checked last so a mutator cannot move the request out of scope
+		// or make it destructive.
+		// Errors from this hook are not retried.
+		if err := scope.checkRequest(req); err != nil {
+			return err
+		}
+		if err := safety.Check(req); err != nil {
+			return err
+		}
+		if signer != nil && !anonymous {
+			if err := signer.Sign(req); err != nil {
+				return err
+			}
+		}
+		if capture != nil {
+			capture(req)
+			return ErrCaptured
+		}
+		// Counted last, only requests that go out use the budget
+		return budget.take()
+	})
+
+	r.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		if raw := resp.RawResponse; raw != nil && raw.Request != nil {
+			if session := c.sessions.sessionOf(raw.Request.Context()); session != nil {
+				session.Track(raw.Request.URL, resp.Cookies())
+			}
+		}
+		return c.runResponseHooks(resp)
+	})
+
+	// Certificates are verified unless -k, say how to trust a private CA
+	// the first time one is not
+	r.OnError(func(_ *resty.Request, err error) {
+		if isCertError(err) {
+			c.certWarning.Do(func() {
+				utils.Warning.Printf("TLS certificate verification failed: %v. Trust its CA with --ca-cert, or skip verification with -k\n", err)
+			})
+		}
+	})
+
+	// Redirects leaving the scope are not followed, the 3xx is returned as is
+	r.SetRedirectPolicy(resty.FlexibleRedirectPolicy(10), resty.RedirectPolicyFunc(func(req *http.Request, _ []*http.Request) error {
+		if err := c.GetScope().checkRequest(req); err != nil {
+			utils.Debug.Printf("Not following redirect: %v\n", err)
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}))
+
+	return c
 }
 
-// Request creates a new request with WAF bypass headers applied
-func (c *SmartClient) Request() *resty.Request {
-	req := c.client.R()
+// Request creates a new request with WAF bypass headers applied. It is
+// sent with ctx, so cancelling ctx aborts it.
+func (c *SmartClient) Request(ctx context.Context) *resty.Request {
+	req := c.client.R().SetContext(ctx)
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -145,10 +294,17 @@ func (c *SmartClient) Request() *resty.Request {
 
 // RequestWithRateLimit creates a request after waiting for rate limit
 func (c *SmartClient) RequestWithRateLimit(ctx context.Context) (*resty.Request, error) {
-	if err := c.rateLimiter.Wait(ctx); err != nil {
+	_, span := tracer.Start(ctx, "ratelimit.wait")
+	err := c.rateLimiter.Wait(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	if err != nil {
 		return nil, err
 	}
-	return c.Request(), nil
+	return c.Request(ctx), nil
 }
 
 // GetSessionManager returns the session manager
@@ -166,19 +322,245 @@ func (c *SmartClient) GetProxyManager() *ProxyManager {
 	return c.proxyManager
 }
 
+// GetBlockPageDetector returns the WAF block page detector
+func (c *SmartClient) GetBlockPageDetector() *BlockPageDetector {
+	return c.blockPages
+}
+
 // SetProxies sets the proxy list for rotation
 func (c *SmartClient) SetProxies(proxies []string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.proxyManager = NewProxyManager(proxies)
+	c.proxyManager.TLSConfig = c.TLSConfig
+	if c.config != nil && c.config.Scanner.ProxyMaxFailures > 0 {
+		c.proxyManager.MaxFailures = c.config.Scanner.ProxyMaxFailures
+	}
 
 	// Update transport with proxy
 	if c.proxyManager.IsEnabled() {
-		transport := NewCustomTransport()
-		transport.Proxy = c.proxyManager.GetProxyFunc()
-		c.client.SetTransport(transport)
+		c.client.SetTransport(c.buildTransport())
+	}
+}
+
+// SetUpstreamProxy routes all traffic through a single intercepting proxy
+// (Burp, ZAP, mitmproxy). It takes precedence over proxy rotation.
+// caPath optionally points to the proxy's CA certificate (PEM or DER).
+func (c *SmartClient) SetUpstreamProxy(proxyURL, caPath string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid upstream proxy %q, expected scheme://host:port", proxyURL)
+	}
+
+	var certs []*x509.Certificate
+	if caPath != "" {
+		certs, err = loadCerts(caPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.upstreamProxy = u
+	c.upstreamCAs = certs
+	c.client.SetTransport(c.buildTransport())
+	return nil
+}
+
+// SetResolve pins host names to addresses, see Resolver. Through a proxy
+// the proxy resolves the target, only the proxy's own host is pinned.
+func (c *SmartClient) SetResolve(entries []string) error {
+	resolver, err := NewResolver(entries)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resolver = resolver
+	c.client.SetTransport(c.buildTransport())
+	return nil
+}
+
+// SetBinding makes connections originate from a local address or
+// interface and sets the address family tried first, see Binding
+func (c *SmartClient) SetBinding(binding *Binding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.binding = binding
+	c.client.SetTransport(c.buildTransport())
+}
+
+// Dial returns how the client opens connections, with its binding and
+// pinned hosts, for senders that bypass the HTTP transport
+func (c *SmartClient) Dial() DialFunc {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dial()
+}
+
+// dial layers the binding and the pinned hosts over the configured dialer
+// (caller must hold the lock)
+func (c *SmartClient) dial() DialFunc {
+	dialer := newDialer(c.config)
+	dial := DialFunc(dialer.DialContext)
+	if c.binding != nil {
+		dial = c.binding.Dial(dialer)
+	}
+	if c.resolver != nil {
+		dial = c.resolver.Dial(dial)
 	}
+	return dial
+}
+
+// SetHostHeader sends host as the Host header of every request, leaving
+// the URL, and so the connection and TLS server name, unchanged
+func (c *SmartClient) SetHostHeader(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hostHeader = host
+}
+
+// SetClientCertificate loads a client certificate for mutual TLS.
+// keyPath may be empty when the key is bundled in the certificate PEM.
+func (c *SmartClient) SetClientCertificate(certPath, keyPath string) error {
+	if keyPath == "" {
+		keyPath = certPath
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("load client certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clientCerts = []tls.Certificate{cert}
+	c.client.SetTransport(c.buildTransport())
+	return nil
+}
+
+// buildTransport assembles the HTTP transport from the current proxy and TLS
+// settings (caller must hold the lock)
+func (c *SmartClient) buildTransport() http.RoundTripper {
+	transport := NewCustomTransport()
+	tuneTransport(transport, c.config)
+	transport.DialContext = c.dial()
+
+	c.applyTLS(transport.TLSClientConfig)
+
+	var rt http.RoundTripper = transport
+	if c.upstreamProxy != nil {
+		transport.Proxy = http.ProxyURL(c.upstreamProxy)
+	} else if c.proxyManager.IsEnabled() {
+		rt = c.proxyManager.RoundTripper(transport)
+	}
+	maxBody := int64(defaultMaxBody)
+	if c.config != nil {
+		maxBody = int64(c.config.Scanner.MaxBodyMB) << 20
+	}
+	rt = &limitTransport{base: rt, max: maxBody}
+	rt = &decodingTransport{base: rt, max: maxBody}
+	// Inside the cache, cached responses are served whatever the host's state
+	rt = c.breaker.RoundTripper(rt)
+
+	if c.cache != nil {
+		rt = c.cache.RoundTripper(rt)
+	}
+
+	return rt
+}
+
+// EnableCache serves repeated identical GET and HEAD requests from a
+// response cache, see ResponseCache. dir persists responses across runs when
+// non-empty; ttl of 0 never expires.
+func (c *SmartClient) EnableCache(dir string, ttl time.Duration) error {
+	cache, err := NewResponseCache(dir, ttl)
+	if err != nil {
+		return err
+	}
+	cache.BlockPages = c.blockPages
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = cache
+	c.client.SetTransport(c.buildTransport())
+	return nil
+}
+
+// GetRetryPolicy returns the policy failed requests are retried by
+func (c *SmartClient) GetRetryPolicy() *RetryPolicy {
+	return c.retry
+}
+
+// GetCircuitBreaker returns the per-host circuit breaker
+func (c *SmartClient) GetCircuitBreaker() *CircuitBreaker {
+	return c.breaker
+}
+
+// GetCache returns the response cache, or nil when caching is disabled
+func (c *SmartClient) GetCache() *ResponseCache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cache
+}
+
+// SetRequestSigner installs a signer applied to every outgoing request
+func (c *SmartClient) SetRequestSigner(signer RequestSigner) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.signer = signer
+}
+
+// SetRequestMutator installs a mutator applied to every outgoing request
+// before it is signed
+func (c *SmartClient) SetRequestMutator(mutator RequestMutator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mutator = mutator
+}
+
+// SetScope restricts the URLs the client may request, including redirects
+func (c *SmartClient) SetScope(scope *Scope) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scope = scope
+}
+
+// GetScope returns the scope set with SetScope, nil if unrestricted. The
+// methods of a nil Scope allow everything.
+func (c *SmartClient) GetScope() *Scope {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.scope
+}
+
+// SetSafety guards against destructive requests, see Safety. New clients
+// refuse them all; nil allows every request.
+func (c *SmartClient) SetSafety(safety *Safety) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.safety = safety
+}
+
+// SetCapture makes the client hand every request to fn instead of sending
+// it, for dry runs. fn sees the request exactly as it would be sent, after
+// mutation and signing; the request then fails with ErrCaptured. A nil fn
+// sends requests again.
+func (c *SmartClient) SetCapture(fn func(*http.Request)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capture = fn
 }
 
 // SetWAFBypassMode changes the WAF bypass mode
@@ -188,7 +570,24 @@ func (c *SmartClient) SetWAFBypassMode(mode string) {
 	c.wafBypass.Mode = mode
 }
 
-// SetDefaultHeader sets a default header for all requests
+// SetDefaultHeader sets a default header for all requests. Headers that
+// authenticate a user belong on its Session instead, see Session.SetHeader.
 func (c *SmartClient) SetDefaultHeader(key, value string) {
 	c.client.SetHeader(key, value)
 }
+
+// restyLogger sends resty's messages to the idorplus logger. Retries, and
+// requests the budget refused, which the scan sums up, are only shown with
+// --debug.
+type restyLogger struct{}
+
+func (restyLogger) Errorf(format string, v ...interface{}) {
+	// resty may log the error inside a wrapper errors.Is cannot see through
+	if strings.Contains(fmt.Sprintf(format, v...), ErrBudgetExhausted.Error()) {
+		utils.Debug.Printf(format, v...)
+		return
+	}
+	utils.Warning.Printf(format, v...)
+}
+func (restyLogger) Warnf(format string, v ...interface{})  { utils.Debug.Printf(format, v...) }
+func (restyLogger) Debugf(format string, v ...interface{}) { utils.Debug.Printf(format, v...) }
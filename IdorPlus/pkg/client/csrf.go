@@ -0,0 +1,495 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrCSRFRejected is returned for a state-changing request the server
+// rejected for a stale anti-CSRF token. The token is fetched again and the
+// request retried once, unless max_retries is 0.
+var ErrCSRFRejected = errors.New("anti-CSRF token rejected")
+
+// DefaultCSRFHeader carries the token unless CSRFHandler.Param is set
+const DefaultCSRFHeader = "X-CSRF-Token"
+
+// CSRFExtractors are the kinds of --csrf-extract specs
+var CSRFExtractors = []string{"auto", "regex:PATTERN", "json:PATH", "meta:NAME", "input:NAME", "cookie:NAME"}
+
+var (
+	metaTag   = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	inputTag  = regexp.MustCompile(`(?is)<input\b[^>]*>`)
+	htmlAttr  = regexp.MustCompile(`(?is)([a-z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	csrfName  = regexp.MustCompile(`(?i)csrf|xsrf|authenticity_token|^_token$|request_?verification_?token`)
+	jsonIndex = regexp.MustCompile(`^([^\[]*)((?:\[\d+\])*)$`)
+
+	// csrfRejection matches error pages of token checks, e.g. Django's
+	// "CSRF verification failed" or Rails' InvalidAuthenticityToken
+	csrfRejection = regexp.MustCompile(`(?i)csrf|xsrf|authenticity.?token|(invalid|expired|missing|mismatch(ed)?)\W+(request\W+)?token|token\W+(is\W+)?(invalid|expired|missing|mismatch)`)
+)
+
+// CSRFExtractor pulls a token out of a response, "" when there is none
+type CSRFExtractor func(resp *resty.Response) string
+
+// ParseCSRFExtractor parses a --csrf-extract spec, see CSRFExtractors.
+// auto tries meta tags, hidden inputs, JSON keys and cookies named like a
+// CSRF token.
+func ParseCSRFExtractor(spec string) (CSRFExtractor, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "", "auto":
+		return autoCSRF, nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSRF regex: %w", err)
+		}
+		return func(resp *resty.Response) string {
+			m := re.FindStringSubmatch(resp.String())
+			if len(m) > 1 {
+				return m[1]
+			}
+			if len(m) == 1 {
+				return m[0]
+			}
+			return ""
+		}, nil
+	case "json":
+		path, err := parseJSONPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(resp *resty.Response) string {
+			var v any
+			if json.Unmarshal(resp.Body(), &v) != nil {
+				return ""
+			}
+			return jsonString(walkJSON(v, path))
+		}, nil
+	case "meta", "input", "cookie":
+		if arg == "" {
+			return nil, fmt.Errorf("CSRF extractor %s needs a name, e.g. %s:csrf-token", kind, kind)
+		}
+		match := func(name string) bool { return strings.EqualFold(name, arg) }
+		switch kind {
+		case "meta":
+			return func(resp *resty.Response) string { return metaToken(resp.String(), match) }, nil
+		case "input":
+			return func(resp *resty.Response) string { return inputToken(resp.String(), match) }, nil
+		default:
+			return func(resp *resty.Response) string { return cookieToken(resp, match) }, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown CSRF extractor %q, want one of %s", spec, strings.Join(CSRFExtractors, ", "))
+}
+
+func autoCSRF(resp *resty.Response) string {
+	match := csrfName.MatchString
+	body := resp.String()
+	if token := metaToken(body, match); token != "" {
+		return token
+	}
+	if token := inputToken(body, match); token != "" {
+		return token
+	}
+	var v any
+	if json.Unmarshal(resp.Body(), &v) == nil {
+		if token := findJSONKey(v, match); token != "" {
+			return token
+		}
+	}
+	return cookieToken(resp, match)
+}
+
+// attrs returns the attributes of an HTML tag, names lowercased
+func attrs(tag string) map[string]string {
+	m := make(map[string]string)
+	for _, a := range htmlAttr.FindAllStringSubmatch(tag, -1) {
+		m[strings.ToLower(a[1])] = a[2] + a[3] + a[4]
+	}
+	return m
+}
+
+func metaToken(body string, match func(string) bool) string {
+	for _, tag := range metaTag.FindAllString(body, -1) {
+		a := attrs(tag)
+		if match(a["name"]) && a["content"] != "" {
+			return a["content"]
+		}
+	}
+	return ""
+}
+
+func inputToken(body string, match func(string) bool) string {
+	for _, tag := range inputTag.FindAllString(body, -1) {
+		a := attrs(tag)
+		if match(a["name"]) && a["value"] != "" {
+			return a["value"]
+		}
+	}
+	return ""
+}
+
+func cookieToken(resp *resty.Response, match func(string) bool) string {
+	for _, c := range resp.Cookies() {
+		if match(c.Name) && c.Value != "" {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// jsonStep is one key of a JSON path with the array indexes after it
+type jsonStep struct {
+	key     string
+	indexes []int
+}
+
+// parseJSONPath parses a dotted path such as $.data.csrf or
+// meta.tokens[0].value
+func parseJSONPath(path string) ([]jsonStep, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil, errors.New("CSRF JSON path is empty, e.g. json:$.data.csrf_token")
+	}
+	var steps []jsonStep
+	for _, part := range strings.Split(path, ".") {
+		m := jsonIndex.FindStringSubmatch(part)
+		if m == nil || (m[1] == "" && m[2] == "") {
+			return nil, fmt.Errorf("invalid CSRF JSON path %q", path)
+		}
+		step := jsonStep{key: m[1]}
+		for _, idx := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if idx == "" {
+				continue
+			}
+			n, _ := strconv.Atoi(idx)
+			step.indexes = append(step.indexes, n)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func walkJSON(v any, path []jsonStep) any {
+	for _, step := range path {
+		if step.key != "" {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = obj[step.key]
+		}
+		for _, i := range step.indexes {
+			arr, ok := v.([]any)
+			if !ok || i >= len(arr) {
+				return nil
+			}
+			v = arr[i]
+		}
+	}
+	return v
+}
+
+// findJSONKey returns the first string under a matching key, depth first
+func findJSONKey(v any, match func(string) bool) string {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if s, ok := child.(string); ok && s != "" && match(k) {
+				return s
+			}
+		}
+		for _, child := range v {
+			if s := findJSONKey(child, match); s != "" {
+				return s
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if s := findJSONKey(child, match); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// CSRFHandler keeps state-changing requests supplied with an anti-CSRF
+// token. The token is fetched from URL with the request's own cookies and
+// Authorization, since servers bind tokens to the session, and is fetched
+// again once the server rejects it.
+type CSRFHandler struct {
+	client  *SmartClient
+	URL     string
+	extract CSRFExtractor
+	// Header carries the token, or Param, a form or JSON body field, when set
+	Header string
+	Param  string
+
+	mu      sync.Mutex
+	tokens  map[string]*csrfToken // by session
+	warned  bool
+	fetchMu sync.Mutex // one fetch at a time, workers share its token
+}
+
+type csrfToken struct {
+	value string
+	// accepted is set once a request with the token got through, a
+	// rejection after that means the token went stale
+	accepted bool
+}
+
+// NewCSRFHandler creates a handler fetching tokens from pageURL with the
+// extractor spec, see ParseCSRFExtractor
+func NewCSRFHandler(c *SmartClient, pageURL, spec string) (*CSRFHandler, error) {
+	if u, err := url.Parse(pageURL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid CSRF URL %q", pageURL)
+	}
+	extract, err := ParseCSRFExtractor(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &CSRFHandler{
+		client:  c,
+		URL:     pageURL,
+		extract: extract,
+		Header:  DefaultCSRFHeader,
+		tokens:  make(map[string]*csrfToken),
+	}, nil
+}
+
+// Install registers the handler's hooks on its client
+func (h *CSRFHandler) Install() {
+	h.client.RegisterRequestHook("csrf", h.onRequest)
+	h.client.RegisterResponseHook("csrf", h.onResponse)
+}
+
+// needsToken reports whether a request is state-changing
+func needsToken(method string) bool {
+	return method == http.MethodPost || IsDestructiveMethod(method)
+}
+
+// session identifies whose token a request needs
+func session(header http.Header) string {
+	return header.Get("Cookie") + "\x00" + header.Get("Authorization")
+}
+
+func (h *CSRFHandler) onRequest(req *http.Request) error {
+	if !needsToken(req.Method) {
+		return nil
+	}
+	token, err := h.token(req.Context(), req.Header)
+	if err != nil {
+		return err
+	}
+	if h.Param == "" {
+		req.Header.Set(h.Header, token)
+		return nil
+	}
+	return setBodyParam(req, h.Param, token)
+}
+
+// token returns the session's token, fetching it with ctx when there is none
+func (h *CSRFHandler) token(ctx context.Context, header http.Header) (string, error) {
+	key := session(header)
+	cached := func() *csrfToken {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.tokens[key]
+	}
+	if tok := cached(); tok != nil {
+		return tok.value, nil
+	}
+	h.fetchMu.Lock()
+	defer h.fetchMu.Unlock()
+	if tok := cached(); tok != nil {
+		return tok.value, nil
+	}
+
+	req := h.client.Request(ctx)
+	for _, name := range []string{"Cookie", "Authorization"} {
+		if v := header.Get(name); v != "" {
+			req.SetHeader(name, v)
+		}
+	}
+	resp, err := req.Get(h.URL)
+	if err != nil {
+		return "", fmt.Errorf("fetch CSRF token: %w", err)
+	}
+	value := h.extract(resp)
+	if value == "" {
+		return "", fmt.Errorf("no CSRF token in %s (status %d)", h.URL, resp.StatusCode())
+	}
+	utils.Debug.Printf("Fetched CSRF token from %s\n", h.URL)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens[key] = &csrfToken{value: value}
+	return value, nil
+}
+
+// onResponse drops a token the server rejected. A token rejected before it
+// was ever accepted is not the problem, or not extracted right, so the
+// response is passed on instead of retried forever.
+func (h *CSRFHandler) onResponse(resp *resty.Response) error {
+	raw := resp.Request.RawRequest
+	if raw == nil || !needsToken(raw.Method) {
+		return nil
+	}
+	// Matched by value: the cookie jar may have added to the Cookie
+	// header the session was keyed by
+	sent := h.sentToken(raw)
+	if sent == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var key string
+	var tok *csrfToken
+	for k, t := range h.tokens {
+		if t.value == sent {
+			key, tok = k, t
+			break
+		}
+	}
+	if tok == nil {
+		return nil
+	}
+	if !isCSRFRejection(resp) {
+		tok.accepted = true
+		return nil
+	}
+	if !tok.accepted {
+		if !h.warned {
+			h.warned = true
+			utils.Warning.Printf("Fresh CSRF token from %s was rejected, check --csrf-extract and --csrf-header/--csrf-param\n", h.URL)
+		}
+		return nil
+	}
+	delete(h.tokens, key)
+	return ErrCSRFRejected
+}
+
+// sentToken returns the token a request carried
+func (h *CSRFHandler) sentToken(req *http.Request) string {
+	if h.Param == "" {
+		return req.Header.Get(h.Header)
+	}
+	if req.GetBody == nil {
+		return ""
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		return ""
+	}
+	var obj map[string]any
+	if json.Unmarshal(body, &obj) == nil {
+		return jsonString(obj[h.Param])
+	}
+	form, _ := url.ParseQuery(string(body))
+	return form.Get(h.Param)
+}
+
+// isCSRFRejection reports whether a response rejects the request's token:
+// Laravel's 419, or a 400/403/422 talking about the token
+func isCSRFRejection(resp *resty.Response) bool {
+	switch resp.StatusCode() {
+	case 419:
+		return true
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity:
+		return csrfRejection.Match(resp.Body())
+	}
+	return false
+}
+
+// setBodyParam sets a field of a form or JSON object body
+func setBodyParam(req *http.Request, name, value string) error {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch {
+	case strings.Contains(mediaType, "json"):
+		obj := map[string]any{}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &obj); err != nil {
+				return fmt.Errorf("add CSRF token to JSON body: %w", err)
+			}
+		}
+		obj[name] = value
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		body = b
+	case mediaType == "application/x-www-form-urlencoded" || (mediaType == "" && len(bytes.TrimSpace(body)) == 0):
+		if mediaType == "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		body = []byte(setFormParam(string(body), name, value))
+	default:
+		return fmt.Errorf("cannot add a CSRF token to a %s body, use --csrf-header", mediaType)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	req.ContentLength = int64(len(body))
+	return nil
+}
+
+// setFormParam replaces the value of a form field, or appends the field,
+// leaving the other fields as they are
+func setFormParam(form, name, value string) string {
+	pair := url.QueryEscape(name) + "=" + url.QueryEscape(value)
+	fields := strings.Split(form, "&")
+	for i, f := range fields {
+		key, _, _ := strings.Cut(f, "=")
+		if k, err := url.QueryUnescape(key); err == nil && k == name {
+			fields[i] = pair
+			return strings.Join(fields, "&")
+		}
+	}
+	if form == "" {
+		return pair
+	}
+	return form + "&" + pair
+}
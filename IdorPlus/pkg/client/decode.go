@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/utils"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/html/charset"
+)
+
+// maxDecodedBody caps decompressed bodies so a compression bomb can't
+// exhaust memory
+const maxDecodedBody = 64 << 20
+
+// acceptEncoding advertises the encodings decodingTransport decompresses
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// zstdDecoder is shared, DecodeAll is safe for concurrent use
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxDecodedBody))
+
+// decodingTransport hands resty, the cache and the detectors plain UTF-8:
+// gzip, deflate, br and zstd bodies are decompressed and text in other
+// charsets is converted. A body that fails to decode is passed on as is.
+// Decoded bodies are cut at max bytes like those limitTransport reads.
+type decodingTransport struct {
+	base http.RoundTripper
+	max  int64
+}
+
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil || req.Method == http.MethodHead {
+		return resp, err
+	}
+
+	encoding := resp.Header.Get("Content-Encoding")
+	contentType := resp.Header.Get("Content-Type")
+	if (encoding == "" || strings.EqualFold(encoding, "identity")) && !isText(contentType) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// What was read of a truncated body is decoded as far as it goes
+	partial := resp.Header.Get(analyzer.BodySizeHeader) != ""
+	if encoding != "" {
+		if decoded, err := decompress(body, encoding, partial); err != nil {
+			utils.Debug.Printf("Keeping %s body of %s encoded: %v\n", encoding, req.URL, err)
+		} else {
+			body = decoded
+			resp.Header.Del("Content-Encoding")
+			resp.Uncompressed = true
+		}
+	}
+	if decoded, ok := toUTF8(body, contentType); ok {
+		body = decoded
+		if mediaType, params, err := mime.ParseMediaType(contentType); err == nil {
+			params["charset"] = "utf-8"
+			resp.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+		}
+	}
+
+	if t.max > 0 && int64(len(body)) > t.max {
+		body = truncateBody(resp, body, t.max, strconv.Itoa(len(body)))
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+// decompress undoes the codings of a Content-Encoding header, listed in the
+// order they were applied. A partial body decodes to what it holds.
+func decompress(body []byte, encoding string, partial bool) ([]byte, error) {
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var r io.Reader
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			r = gr
+		case "deflate":
+			// Meant to be zlib, but some servers send raw deflate
+			if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+				r = zr
+			} else {
+				r = flate.NewReader(bytes.NewReader(body))
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		case "zstd":
+			decoded, err := zstdDecoder.DecodeAll(body, nil)
+			if err != nil {
+				return nil, err
+			}
+			body = decoded
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", coding)
+		}
+
+		decoded, err := io.ReadAll(io.LimitReader(r, maxDecodedBody+1))
+		if err != nil && !(partial && errors.Is(err, io.ErrUnexpectedEOF)) {
+			return nil, err
+		}
+		if len(decoded) > maxDecodedBody {
+			return nil, fmt.Errorf("decoded body exceeds %d bytes", maxDecodedBody)
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+// toUTF8 converts a text body to UTF-8. The charset comes from a BOM or
+// the Content-Type; HTML may also declare it in a meta tag, otherwise it
+// falls back to windows-1252 like browsers do. It reports false when the
+// body is UTF-8 already or not text.
+func toUTF8(body []byte, contentType string) ([]byte, bool) {
+	if !isText(contentType) {
+		return nil, false
+	}
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" || (!certain && !strings.HasPrefix(strings.ToLower(contentType), "text/html")) {
+		return nil, false
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// isText reports whether a Content-Type is text the detectors read: text/*,
+// JSON, XML, JavaScript or form data
+func isText(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "x-www-form-urlencoded"} {
+		if strings.Contains(mediaType, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RequestHook sees every request right before it is checked against the
+// scope and signed, and may rewrite it, e.g. to add a fresh anti-CSRF
+// token. An error aborts the request.
+type RequestHook func(req *http.Request) error
+
+// ResponseHook sees every response before any detector does, and may
+// rewrite it, e.g. decrypt the body with resp.SetBody. An error fails the
+// request.
+type ResponseHook func(resp *resty.Response) error
+
+// namedHook keeps the name a hook was registered with for its errors
+type namedHook[T any] struct {
+	name string
+	hook T
+}
+
+// RegisterRequestHook adds a hook run on every request, after the hooks
+// registered before it
+func (c *SmartClient) RegisterRequestHook(name string, hook RequestHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestHooks = append(c.requestHooks, namedHook[RequestHook]{name, hook})
+}
+
+// RegisterResponseHook adds a hook run on every response, after the hooks
+// registered before it
+func (c *SmartClient) RegisterResponseHook(name string, hook ResponseHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responseHooks = append(c.responseHooks, namedHook[ResponseHook]{name, hook})
+}
+
+// runRequestHooks runs the request hooks in order, hooks is a snapshot
+// taken under the lock
+func runRequestHooks(hooks []namedHook[RequestHook], req *http.Request) error {
+	for _, h := range hooks {
+		if err := h.hook(req); err != nil {
+			return fmt.Errorf("request hook %s: %w", h.name, err)
+		}
+	}
+	return nil
+}
+
+// runResponseHooks runs the response hooks in order
+func (c *SmartClient) runResponseHooks(resp *resty.Response) error {
+	c.mu.RLock()
+	hooks := c.responseHooks
+	c.mu.RUnlock()
+
+	for _, h := range hooks {
+		if err := h.hook(resp); err != nil {
+			return fmt.Errorf("response hook %s: %w", h.name, err)
+		}
+	}
+	return nil
+}
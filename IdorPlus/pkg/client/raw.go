@@ -0,0 +1,247 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RawRequest is an HTTP request written to the socket byte for byte. Unlike
+// net/http nothing is canonicalised: header names keep their case, duplicates
+// and odd whitespace survive, and Target may be an absolute URI.
+type RawRequest struct {
+	Method  string
+	Target  string
+	Proto   string
+	Headers []string // complete header lines without the trailing CRLF
+	Body    []byte
+}
+
+// RawResponse is the parsed response plus the bytes read from the socket
+type RawResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Raw        []byte
+	Duration   time.Duration
+}
+
+// RawSender sends RawRequests over a fresh connection per request. The
+// connection is closed after one response so a malformed request can never
+// desync a pooled connection used by other requests.
+type RawSender struct {
+	Timeout   time.Duration
+	TLSConfig *tls.Config
+	// Scope, if set, is checked against the target plus the request target
+	Scope *Scope
+	// Dial, if set, opens the connections, e.g. SmartClient.Dial
+	Dial DialFunc
+	// Safety, if set, guards against destructive requests
+	Safety *Safety
+}
+
+// NewRawSender creates a raw sender that verifies certificates, see
+// SmartClient.TLSConfig for the client's settings
+func NewRawSender(timeout time.Duration) *RawSender {
+	return &RawSender{
+		Timeout:   timeout,
+		TLSConfig: &tls.Config{},
+	}
+}
+
+// Bytes serialises the request exactly as it will be sent
+func (r *RawRequest) Bytes() []byte {
+	proto := r.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s %s\r\n", r.Method, r.Target, proto)
+	for _, h := range r.Headers {
+		b.WriteString(h)
+		b.WriteString("\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(r.Body)
+	return b.Bytes()
+}
+
+// ParseRawRequest parses a request in the format saved by Burp ("Copy to file").
+// Bare LF line endings are accepted; header lines are kept verbatim, and so
+// is the body, CRLFs of a multipart body included.
+func ParseRawRequest(data []byte) (*RawRequest, error) {
+	head, body := headerBlock(string(data))
+	head = strings.ReplaceAll(head, "\r\n", "\n")
+
+	lines := strings.Split(head, "\n")
+	parts := strings.SplitN(lines[0], " ", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("malformed request line %q", lines[0])
+	}
+
+	req := &RawRequest{
+		Method: parts[0],
+		Target: parts[1],
+		Body:   []byte(body),
+	}
+	if len(parts) == 3 {
+		req.Proto = parts[2]
+	}
+	for _, line := range lines[1:] {
+		if line != "" {
+			req.Headers = append(req.Headers, line)
+		}
+	}
+	return req, nil
+}
+
+// headerBlock splits a raw request at the first empty line, ended by CRLF
+// or LF
+func headerBlock(text string) (head, body string) {
+	end := -1
+	for _, sep := range []string{"\n\r\n", "\n\n"} {
+		if i := strings.Index(text, sep); i >= 0 && (end < 0 || i < end) {
+			end = i
+			body = text[i+len(sep):]
+		}
+	}
+	if end < 0 {
+		return text, ""
+	}
+	return strings.TrimSuffix(text[:end], "\r"), body
+}
+
+// Host returns the value of the first Host header, if any
+func (r *RawRequest) Host() string {
+	for _, h := range r.Headers {
+		if name, value, ok := strings.Cut(h, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "host") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// url returns the URL the request addresses when sent to base
+func (r *RawRequest) url(base *url.URL) string {
+	if strings.HasPrefix(r.Target, "http://") || strings.HasPrefix(r.Target, "https://") {
+		return r.Target
+	}
+	u := *base
+	u.User = nil
+	u.Path, u.RawPath, u.RawQuery = "", "", ""
+	return strings.TrimSuffix(u.String(), "/") + r.Target
+}
+
+// httpRequest converts the request for checks that take an http.Request
+func (r *RawRequest) httpRequest(base *url.URL) (*http.Request, error) {
+	hr, err := http.NewRequest(r.Method, r.url(base), bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range r.Headers {
+		if name, value, ok := strings.Cut(h, ":"); ok {
+			hr.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	return hr, nil
+}
+
+// Send dials target (http[s]://host[:port]), writes the request and reads one response
+func (s *RawSender) Send(ctx context.Context, target string, req *RawRequest) (*RawResponse, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Scope.Check(req.url(u)); err != nil {
+		return nil, err
+	}
+	if s.Safety != nil {
+		hr, err := req.httpRequest(u)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Safety.Check(hr); err != nil {
+			return nil, err
+		}
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	dial := s.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if u.Scheme == "https" {
+		cfg := s.TLSConfig.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// Keep a copy of everything read so the exact response can be shown
+	var raw bytes.Buffer
+	br := bufio.NewReader(io.TeeReader(conn, &raw))
+	resp, err := http.ReadResponse(br, &http.Request{Method: req.Method})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil && len(body) == 0 {
+		return nil, err
+	}
+
+	return &RawResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Raw:        raw.Bytes(),
+		Duration:   time.Since(start),
+	}, nil
+}
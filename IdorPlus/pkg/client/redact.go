@@ -0,0 +1,82 @@
+package client
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Masked replaces the secrets masked by MaskURL and MaskBody
+const Masked = "[redacted]"
+
+// secretName matches query parameter and body field names holding a
+// credential: access_token, apiKey, client_secret, password...
+var secretName = regexp.MustCompile(`(?i)(token|secret|passw(or)?d|^pwd$|api[_-]?key|session|jwt|signature|^sig$|^auth$|authorization|credential)`)
+
+// jsonSecret matches a JSON string field, the name captured
+var jsonSecret = regexp.MustCompile(`"([^"\\]+)"(\s*:\s*)"((?:[^"\\]|\\.)*)"`)
+
+// IsSecretName reports whether a query parameter or body field of that
+// name holds a credential
+func IsSecretName(name string) bool {
+	return secretName.MatchString(name)
+}
+
+// MaskURL masks the values of secret query parameters in rawURL, which may
+// hold placeholders such as {ID}, and drops userinfo
+func MaskURL(rawURL string) string {
+	base, query, ok := strings.Cut(rawURL, "?")
+	if u, err := url.Parse(base); err == nil && u.User != nil {
+		if scheme, rest, found := strings.Cut(base, "://"); found {
+			_, host, _ := strings.Cut(rest, "@")
+			base = scheme + "://" + host
+		}
+	}
+	if !ok {
+		return base
+	}
+	return base + "?" + maskPairs(query)
+}
+
+// MaskBody masks the values of secret fields in a JSON or form body.
+// Values with placeholders are kept, they are fuzzed rather than secret.
+func MaskBody(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return jsonSecret.ReplaceAllStringFunc(body, func(field string) string {
+			m := jsonSecret.FindStringSubmatch(field)
+			if !IsSecretName(m[1]) || hasPlaceholder(m[3]) {
+				return field
+			}
+			return `"` + m[1] + `"` + m[2] + `"` + Masked + `"`
+		})
+	}
+	if strings.Contains(body, "=") && !strings.ContainsAny(trimmed, " \n") {
+		return maskPairs(body)
+	}
+	return body
+}
+
+// maskPairs masks the values of secret names in name=value&... pairs
+func maskPairs(pairs string) string {
+	parts := strings.Split(pairs, "&")
+	for i, p := range parts {
+		name, value, ok := strings.Cut(p, "=")
+		if !ok || hasPlaceholder(value) {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if IsSecretName(name) {
+			parts[i] = p[:len(p)-len(value)] + Masked
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// hasPlaceholder reports whether s holds a placeholder such as {ID}
+func hasPlaceholder(s string) bool {
+	open := strings.Index(s, "{")
+	return open >= 0 && strings.Contains(s[open:], "}")
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DialFunc opens a network connection, see http.Transport.DialContext
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// ParseResolve parses a curl-style --resolve entry: host:port:addr pins
+// one port, host:addr every port. IPv6 addresses must be bracketed, as in
+// host:443:[::1], or their first group would be taken for a port.
+func ParseResolve(entry string) (host, port, addr string, err error) {
+	host, rest, ok := strings.Cut(strings.TrimSpace(entry), ":")
+	if !ok || host == "" || rest == "" {
+		return "", "", "", fmt.Errorf("invalid resolve entry %q, expected host:addr or host:port:addr", entry)
+	}
+	if p, a, ok := strings.Cut(rest, ":"); ok && isPort(p) {
+		port, rest = p, a
+	}
+	addr = rest
+	if strings.HasPrefix(rest, "[") && strings.HasSuffix(rest, "]") {
+		addr = rest[1 : len(rest)-1]
+	} else if strings.Contains(rest, ":") {
+		return "", "", "", fmt.Errorf("invalid resolve entry %q: put the IPv6 address in brackets, e.g. host:[::1]", entry)
+	}
+	if net.ParseIP(addr) == nil {
+		return "", "", "", fmt.Errorf("invalid resolve entry %q: %q is not an IP address", entry, addr)
+	}
+	return strings.ToLower(host), port, addr, nil
+}
+
+func isPort(s string) bool {
+	if s == "" || len(s) > 5 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolver pins host names to addresses, like curl --resolve or an
+// /etc/hosts entry. TLS still uses the original host name for SNI and
+// certificate checks, so an origin behind a CDN can be reached directly.
+type Resolver struct {
+	// keyed by host:port, or by host for every port
+	addrs map[string]string
+}
+
+// NewResolver parses --resolve entries
+func NewResolver(entries []string) (*Resolver, error) {
+	r := &Resolver{addrs: make(map[string]string, len(entries))}
+	for _, entry := range entries {
+		host, port, addr, err := ParseResolve(entry)
+		if err != nil {
+			return nil, err
+		}
+		key := host
+		if port != "" {
+			key = net.JoinHostPort(host, port)
+		}
+		r.addrs[key] = addr
+	}
+	return r, nil
+}
+
+// Lookup returns the address pinned for a host:port, if any
+func (r *Resolver) Lookup(hostport string) (string, bool) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", false
+	}
+	host = strings.ToLower(host)
+	if addr, ok := r.addrs[net.JoinHostPort(host, port)]; ok {
+		return net.JoinHostPort(addr, port), true
+	}
+	if addr, ok := r.addrs[host]; ok {
+		return net.JoinHostPort(addr, port), true
+	}
+	return "", false
+}
+
+// Dial wraps dial so pinned hosts are dialled at their address
+func (r *Resolver) Dial(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if pinned, ok := r.Lookup(addr); ok {
+			addr = pinned
+		}
+		return dial(ctx, network, addr)
+	}
+}
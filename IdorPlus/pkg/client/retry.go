@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"idorplus/pkg/utils"
+)
+
+// Failure classes a RetryPolicy tells apart, see Classify
+const (
+	FailureRefused = "refused" // connection refused, nothing listens
+	FailureTimeout = "timeout" // no answer in time
+	FailureNetwork = "network" // connection reset, unexpected EOF, DNS
+	Failure5xx     = "5xx"     // 502, 503 or 504 from a gateway or overloaded server
+	Failure429     = "429"     // rate limited
+	FailureCSRF    = "csrf"    // anti-CSRF token rejected, see ErrCSRFRejected
+)
+
+// Classify returns the failure class of a response or transport error, ""
+// for responses and errors that retrying doesn't help with: any other
+// status, cancellation, requests refused by scope, safety or budget, and
+// certificate errors. A 500 is the application's answer, not a failure.
+func Classify(resp *http.Response, err error) string {
+	if errors.Is(err, ErrCSRFRejected) {
+		return FailureCSRF
+	}
+	if err == nil {
+		if resp == nil {
+			return ""
+		}
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests:
+			return Failure429
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return Failure5xx
+		}
+		return ""
+	}
+	if errors.Is(err, context.Canceled) || isCertError(err) {
+		return ""
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return FailureRefused
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return ""
+		}
+		return FailureNetwork
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return FailureNetwork
+	}
+	return ""
+}
+
+// RetryPolicy decides which failed requests are sent again and how long
+// to wait before. It is the only place requests are retried.
+type RetryPolicy struct {
+	// MaxRetries is how often a request is retried, 0 for never
+	MaxRetries int
+	// On are the failure classes retried
+	On []string
+	// Backoff is the wait before the first retry, doubled for each further
+	// one up to MaxBackoff. Waits are jittered so workers don't retry in
+	// lockstep.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryClasses are the failure classes retried unless configured
+var DefaultRetryClasses = []string{FailureRefused, FailureTimeout, FailureNetwork, Failure5xx, Failure429}
+
+// newRetryPolicy reads the retry settings of the config
+func newRetryPolicy(config *utils.Config) *RetryPolicy {
+	p := &RetryPolicy{
+		MaxRetries: 3,
+		On:         DefaultRetryClasses,
+		Backoff:    500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+	if config == nil {
+		return p
+	}
+	sc := config.Scanner
+	p.MaxRetries = max(sc.MaxRetries, 0)
+	if len(sc.RetryOn) > 0 {
+		p.On = sc.RetryOn
+	}
+	if d, err := time.ParseDuration(sc.RetryBackoff); err == nil {
+		p.Backoff = d
+	}
+	if d, err := time.ParseDuration(sc.RetryMaxBackoff); err == nil {
+		p.MaxBackoff = d
+	}
+	return p
+}
+
+// ShouldRetry reports whether a request with method that got resp or err
+// is retried. Requests that aren't idempotent, such as POST, may already
+// have taken effect and are only retried when the server refused the
+// connection or rate limited them. A request whose anti-CSRF token was
+// rejected took no effect and is retried whatever On says, with the token
+// fetched again; a fresh token rejected too is not retried, see
+// CSRFHandler.
+func (p *RetryPolicy) ShouldRetry(method string, resp *http.Response, err error) bool {
+	class := Classify(resp, err)
+	if class == FailureCSRF {
+		return true
+	}
+	if class == "" || !slices.Contains(p.On, class) {
+		return false
+	}
+	return isIdempotent(method) || class == FailureRefused || class == Failure429
+}
+
+// isIdempotent reports whether sending a request twice has the effect of
+// sending it once
+func isIdempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Delay returns the wait before retry number attempt (0 for the first)
+// after resp, which may be nil. A Retry-After of a 429 or 503 is honoured
+// up to MaxBackoff, otherwise the backoff is exponential with equal jitter.
+func (p *RetryPolicy) Delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(d, p.MaxBackoff)
+		}
+	}
+	d := p.Backoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses a Retry-After header, seconds or an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
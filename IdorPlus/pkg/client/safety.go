@@ -0,0 +1,124 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrDestructive is returned for destructive requests that are not allowed
+var ErrDestructive = errors.New("destructive request not allowed")
+
+// methodOverrideHeaders tunnel another verb through the request method
+var methodOverrideHeaders = []string{"X-HTTP-Method-Override", "X-Method-Override", "X-HTTP-Method"}
+
+// IsDestructiveMethod reports whether method changes or deletes resources
+func IsDestructiveMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Safety guards against requests that change or delete resources: PUT,
+// PATCH and DELETE, also when tunnelled through a method override header or
+// a _method parameter.
+type Safety struct {
+	// AllowDestructive permits destructive requests
+	AllowDestructive bool
+	// Canaries, when set, are the only IDs destructive requests may
+	// reference, in the URL, a header or the body
+	Canaries []string
+}
+
+// Check returns an error wrapping ErrDestructive if req may not be sent
+func (s *Safety) Check(req *http.Request) error {
+	if s == nil {
+		return nil
+	}
+	method := effectiveMethod(req)
+	if !IsDestructiveMethod(method) {
+		return nil
+	}
+	if !s.AllowDestructive {
+		return fmt.Errorf("%w: %s %s", ErrDestructive, method, redactURL(req.URL))
+	}
+	if len(s.Canaries) == 0 {
+		return nil
+	}
+
+	parts := []string{req.URL.EscapedPath(), req.URL.RawQuery, readRequestBody(req)}
+	if unescaped, err := url.PathUnescape(req.URL.EscapedPath()); err == nil {
+		parts = append(parts, unescaped)
+	}
+	for _, values := range req.Header {
+		parts = append(parts, values...)
+	}
+	for _, canary := range s.Canaries {
+		for _, part := range parts {
+			if containsToken(part, canary) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s %s references no canary ID", ErrDestructive, method, redactURL(req.URL))
+}
+
+// effectiveMethod is the method a server may act on, taking overrides into account
+func effectiveMethod(req *http.Request) string {
+	for _, h := range methodOverrideHeaders {
+		if v := req.Header.Get(h); IsDestructiveMethod(v) {
+			return strings.ToUpper(v)
+		}
+	}
+	if v := req.URL.Query().Get("_method"); IsDestructiveMethod(v) {
+		return strings.ToUpper(v)
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if form, err := url.ParseQuery(readRequestBody(req)); err == nil && IsDestructiveMethod(form.Get("_method")) {
+			return strings.ToUpper(form.Get("_method"))
+		}
+	}
+	return strings.ToUpper(req.Method)
+}
+
+// readRequestBody returns the body without consuming it
+func readRequestBody(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	return string(data)
+}
+
+// containsToken reports whether id occurs in s delimited by characters that
+// cannot be part of an ID, so canary 12 does not match 123
+func containsToken(s, id string) bool {
+	if id == "" {
+		return false
+	}
+	for i := 0; ; {
+		j := strings.Index(s[i:], id)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(id)
+		if (start == 0 || !isIDChar(s[start-1])) && (end == len(s) || !isIDChar(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isIDChar(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '-' || b == '_'
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// ErrOutOfScope is returned for requests to URLs outside the scan scope
+var ErrOutOfScope = errors.New("out of scope")
+
+// Scope decides which URLs may be requested. Rules are regular expressions
+// matched against the full URL. A URL is in scope when it matches no
+// exclude rule and, if there are include rules, at least one of them.
+type Scope struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewScope compiles include and exclude rules
+func NewScope(include, exclude []string) (*Scope, error) {
+	s := &Scope{}
+	var err error
+	if s.include, err = compileRules(include); err != nil {
+		return nil, fmt.Errorf("invalid include rule: %w", err)
+	}
+	if s.exclude, err = compileRules(exclude); err != nil {
+		return nil, fmt.Errorf("invalid exclude rule: %w", err)
+	}
+	return s, nil
+}
+
+func compileRules(rules []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(rules))
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Check returns an error wrapping ErrOutOfScope if rawURL may not be requested
+func (s *Scope) Check(rawURL string) error {
+	if s == nil {
+		return nil
+	}
+	for _, re := range s.exclude {
+		if re.MatchString(rawURL) {
+			return fmt.Errorf("%w: %s matches exclude rule %q", ErrOutOfScope, rawURL, re)
+		}
+	}
+	if len(s.include) == 0 {
+		return nil
+	}
+	for _, re := range s.include {
+		if re.MatchString(rawURL) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s matches no include rule", ErrOutOfScope, rawURL)
+}
+
+// Allows reports whether rawURL is in scope
+func (s *Scope) Allows(rawURL string) bool {
+	return s.Check(rawURL) == nil
+}
+
+// checkRequest checks the URL a request is about to be sent to
+func (s *Scope) checkRequest(req *http.Request) error {
+	return s.Check(redactURL(req.URL))
+}
+
+// redactURL drops userinfo so credentials never end up in scope errors
+func redactURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	clean := *u
+	clean.User = nil
+	return clean.String()
+}
@@ -1,39 +1,297 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
 )
 
+// Session is a named user of the target. Cookies are the ones it was
+// given and are sent with placeholders filled in. Cookies the server sets
+// later go into the session's jar and replace given ones of the same name,
+// except templated ones, so rotating session tokens keep working.
+//
+// Headers, e.g. the session's Authorization, go on each request made as
+// the session and never on the shared client, so they cannot leak into
+// another session's requests. Set them with SetHeader.
 type Session struct {
 	Name    string
 	Cookies []*http.Cookie
 	Headers map[string]string
+
+	jar       *cookiejar.Jar
+	templated map[string]bool // given cookies with a placeholder
+	mu        sync.Mutex
+	set       map[string]*savedCookie // by name, domain and path
+}
+
+// savedCookie is a cookie a server set, with the URL that set it so
+// host-only cookies can be restored
+type savedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
 }
 
 type SessionManager struct {
+	mu       sync.RWMutex
 	sessions map[string]*Session
+	// saved are the cookies loaded from a session file, by session name
+	saved map[string][]*savedCookie
 }
 
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
 		sessions: make(map[string]*Session),
+		saved:    make(map[string][]*savedCookie),
 	}
 }
 
 func (sm *SessionManager) AddSession(name string, cookieStr string) {
 	cookies := parseCookies(cookieStr)
-	sm.sessions[name] = &Session{
-		Name:    name,
-		Cookies: cookies,
-		Headers: make(map[string]string),
+	jar, _ := cookiejar.New(nil)
+	s := &Session{
+		Name:      name,
+		Cookies:   cookies,
+		Headers:   make(map[string]string),
+		jar:       jar,
+		templated: make(map[string]bool),
+		set:       make(map[string]*savedCookie),
+	}
+	for _, c := range cookies {
+		if strings.Contains(c.Value, "{") {
+			s.templated[c.Name] = true
+		}
 	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, sc := range sm.saved[name] {
+		// Cookies given explicitly win over those saved by earlier runs
+		if slices.ContainsFunc(cookies, func(c *http.Cookie) bool { return c.Name == sc.Cookie.Name }) {
+			continue
+		}
+		if u, err := url.Parse(sc.URL); err == nil {
+			s.track(u, sc.Cookie)
+		}
+	}
+	sm.sessions[name] = s
 }
 
 func (sm *SessionManager) GetSession(name string) *Session {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 	return sm.sessions[name]
 }
 
+// Load reads the cookies of a session file written by Save. They are
+// restored into sessions added afterwards, except those the session is
+// given explicitly.
+func (sm *SessionManager) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	saved := make(map[string][]*savedCookie)
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for name, cookies := range saved {
+		sm.saved[name] = cookies
+	}
+	return nil
+}
+
+// Save writes the unexpired cookies servers set for every session, plus
+// those loaded for sessions not used this run
+func (sm *SessionManager) Save(path string) error {
+	sm.mu.RLock()
+	saved := make(map[string][]*savedCookie, len(sm.saved)+len(sm.sessions))
+	for name, cookies := range sm.saved {
+		saved[name] = cookies
+	}
+	for name, s := range sm.sessions {
+		saved[name] = s.savedCookies()
+	}
+	sm.mu.RUnlock()
+
+	now := time.Now()
+	for name, cookies := range saved {
+		var live []*savedCookie
+		for _, sc := range cookies {
+			if sc.Cookie.MaxAge >= 0 && (sc.Cookie.Expires.IsZero() || sc.Cookie.Expires.After(now)) {
+				live = append(live, sc)
+			}
+		}
+		if len(live) == 0 {
+			delete(saved, name)
+		} else {
+			saved[name] = live
+		}
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// SetHeader makes every request of the session carry a header, unless
+// the request sets it itself
+func (s *Session) SetHeader(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Headers[http.CanonicalHeaderKey(key)] = value
+}
+
+// authHeaders are headers that carry who a request is from
+var authHeaders = map[string]bool{
+	"Authorization":  true,
+	"X-Api-Key":      true,
+	"Api-Key":        true,
+	"X-Auth-Token":   true,
+	"X-Access-Token": true,
+}
+
+// IsAuthHeader reports whether a header authenticates the request, so it
+// belongs to a session rather than to every request
+func IsAuthHeader(name string) bool {
+	return authHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// IsCredentialHeader reports whether a header carries a secret: an auth
+// header, cookies or proxy credentials
+func IsCredentialHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Cookie", "Proxy-Authorization":
+		return true
+	}
+	return IsAuthHeader(name)
+}
+
+// Track stores the cookies a response from u set
+func (s *Session) Track(u *url.URL, cookies []*http.Cookie) {
+	for _, c := range cookies {
+		s.track(u, c)
+	}
+}
+
+func (s *Session) track(u *url.URL, c *http.Cookie) {
+	s.jar.SetCookies(u, []*http.Cookie{c})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := c.Name + "\x00" + c.Domain + "\x00" + c.Path
+	if c.Domain == "" {
+		key += "\x00" + u.Host
+	}
+	s.set[key] = &savedCookie{URL: (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(), Cookie: c}
+}
+
+func (s *Session) savedCookies() []*savedCookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cookies := make([]*savedCookie, 0, len(s.set))
+	for _, sc := range s.set {
+		cookies = append(cookies, sc)
+	}
+	return cookies
+}
+
+// setHeaders adds the session's headers to a request, before resty merges
+// in the client's so they override its defaults
+func (s *Session) setHeaders(req *resty.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.Headers {
+		if req.Header.Get(k) == "" {
+			req.SetHeader(k, v)
+		}
+	}
+}
+
+// apply merges the jar's cookies for the request into its Cookie header
+func (s *Session) apply(req *http.Request) {
+	fresh := s.jar.Cookies(req.URL)
+	if len(fresh) == 0 {
+		return
+	}
+	values := make(map[string]string, len(fresh))
+	for _, c := range fresh {
+		values[c.Name] = c.Value
+	}
+
+	var pairs []string
+	for _, c := range req.Cookies() {
+		if v, ok := values[c.Name]; ok && !s.templated[c.Name] {
+			c.Value = v
+		}
+		delete(values, c.Name)
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	for _, c := range fresh {
+		if _, ok := values[c.Name]; ok {
+			pairs = append(pairs, c.Name+"="+c.Value)
+		}
+	}
+	req.Header.Set("Cookie", strings.Join(pairs, "; "))
+}
+
+type sessionCtxKey struct{}
+
+// WithSession marks requests sent with ctx as made by a session, so
+// cookies the server sets are kept for it
+func WithSession(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, sessionCtxKey{}, name)
+}
+
+type anonymousCtxKey struct{}
+
+// WithoutCredentials marks requests sent with ctx as made without
+// credentials: the client strips the cookies and auth headers they would
+// carry by default, e.g. from -H, and does not sign them
+func WithoutCredentials(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anonymousCtxKey{}, true)
+}
+
+// isAnonymous reports whether a request context was marked with
+// WithoutCredentials
+func isAnonymous(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(anonymousCtxKey{}).(bool)
+	return anonymous
+}
+
+// stripCredentials removes the cookies and auth headers of a request;
+// Proxy-Authorization is for the proxy and stays
+func stripCredentials(req *http.Request) {
+	for name := range req.Header {
+		if name == "Cookie" || IsAuthHeader(name) {
+			req.Header.Del(name)
+		}
+	}
+}
+
+// sessionOf returns the session a request context was marked with
+func (sm *SessionManager) sessionOf(ctx context.Context) *Session {
+	name, _ := ctx.Value(sessionCtxKey{}).(string)
+	if name == "" {
+		return nil
+	}
+	return sm.GetSession(name)
+}
+
 func parseCookies(cookieStr string) []*http.Cookie {
 	var cookies []*http.Cookie
 	parts := strings.Split(cookieStr, ";")
@@ -2,17 +2,23 @@ package client
 
 import (
 	"crypto/tls"
+	"net"
 	"net/http"
 	"time"
+
+	"idorplus/pkg/utils"
 )
 
+// defaultDialTimeout bounds opening a connection when scanner.dial_timeout
+// is unset
+const defaultDialTimeout = 30 * time.Second
+
 // NewCustomTransport creates a transport with custom TLS configuration
 // to mimic a real browser and bypass basic TLS fingerprinting.
 func NewCustomTransport() *http.Transport {
 	return &http.Transport{
 		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-			MinVersion:         tls.VersionTLS12,
+			MinVersion: tls.VersionTLS12,
 			CipherSuites: []uint16{
 				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
 				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
@@ -28,3 +34,36 @@ func NewCustomTransport() *http.Transport {
 		ForceAttemptHTTP2:   true,
 	}
 }
+
+// newDialer opens connections with the dial timeout of the scanner config
+func newDialer(config *utils.Config) *net.Dialer {
+	timeout := defaultDialTimeout
+	if config != nil {
+		if d, err := time.ParseDuration(config.Scanner.DialTimeout); err == nil && d > 0 {
+			timeout = d
+		}
+	}
+	return &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+}
+
+// tuneTransport applies the connection pool settings of the scanner
+// config. Idle connections per host default to the thread count: with
+// fewer, every surplus connection is closed after its request and the next
+// one dials again, until ephemeral ports run out.
+func tuneTransport(t *http.Transport, config *utils.Config) {
+	if config == nil {
+		return
+	}
+	sc := config.Scanner
+	if sc.Threads > t.MaxIdleConnsPerHost {
+		t.MaxIdleConnsPerHost = sc.Threads
+	}
+	if sc.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = sc.MaxIdleConnsPerHost
+	}
+	if t.MaxIdleConns < t.MaxIdleConnsPerHost {
+		t.MaxIdleConns = t.MaxIdleConnsPerHost
+	}
+	t.MaxConnsPerHost = sc.MaxConnsPerHost
+	t.DisableKeepAlives = sc.DisableKeepAlives
+}
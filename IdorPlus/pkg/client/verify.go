@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ErrPinMismatch is returned for a TLS connection whose certificate chain
+// has none of the pinned public keys
+var ErrPinMismatch = errors.New("certificate does not match any pinned public key")
+
+// pinPrefix is curl's --pinnedpubkey prefix for SHA-256 pins
+const pinPrefix = "sha256//"
+
+// ParsePin parses the base64 SHA-256 of a certificate's public key (its
+// SubjectPublicKeyInfo), with or without curl's sha256// prefix, e.g. from
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func ParsePin(pin string) ([]byte, error) {
+	hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(pin), pinPrefix))
+	if err != nil || len(hash) != sha256.Size {
+		return nil, fmt.Errorf("invalid pin %q, expected sha256//<base64 SHA-256 of the public key>", pin)
+	}
+	return hash, nil
+}
+
+// PinOf returns the pin of a certificate, as ParsePin accepts it
+func PinOf(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// verifyPins accepts a connection when any certificate of the chain the
+// server sent has a pinned public key. It also runs with -k, so a pinned
+// self-signed service stays safe from interception.
+func verifyPins(pins [][]byte) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, cert := range cs.PeerCertificates {
+			hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if bytes.Equal(hash[:], pin) {
+					return nil
+				}
+			}
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return ErrPinMismatch
+		}
+		return fmt.Errorf("%w: %s presented %s", ErrPinMismatch, cs.ServerName, PinOf(cs.PeerCertificates[0]))
+	}
+}
+
+// SetInsecure turns certificate verification off, or back on. Pins set
+// with SetPins are still checked.
+func (c *SmartClient) SetInsecure(insecure bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.insecure = insecure
+	c.client.SetTransport(c.buildTransport())
+}
+
+// SetCACert trusts the certificate(s) at path (PEM or DER) in addition to
+// the system roots, e.g. the CA of internal services
+func (c *SmartClient) SetCACert(path string) error {
+	certs, err := loadCerts(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.caCerts = certs
+	c.client.SetTransport(c.buildTransport())
+	return nil
+}
+
+// SetPins only accepts servers whose certificate chain has one of the
+// public keys, see ParsePin. No pins accept any verified server.
+func (c *SmartClient) SetPins(pins []string) error {
+	var hashes [][]byte
+	for _, pin := range pins {
+		// curl separates several pins with ;
+		for _, p := range strings.Split(pin, ";") {
+			if strings.TrimSpace(p) == "" {
+				continue
+			}
+			hash, err := ParsePin(p)
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pins = hashes
+	c.client.SetTransport(c.buildTransport())
+	return nil
+}
+
+// TLSConfig returns the client's TLS settings, for senders that bypass
+// the HTTP transport
+func (c *SmartClient) TLSConfig() *tls.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cfg := &tls.Config{}
+	c.applyTLS(cfg)
+	return cfg
+}
+
+// applyTLS sets verification, the trusted roots, pins and client
+// certificates on cfg (caller must hold the lock)
+func (c *SmartClient) applyTLS(cfg *tls.Config) {
+	cfg.InsecureSkipVerify = c.insecure
+	if extra := slices.Concat(c.upstreamCAs, c.caCerts); len(extra) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, cert := range extra {
+			pool.AddCert(cert)
+		}
+		cfg.RootCAs = pool
+	}
+	if len(c.pins) > 0 {
+		cfg.VerifyConnection = verifyPins(c.pins)
+	}
+	if len(c.clientCerts) > 0 {
+		cfg.Certificates = c.clientCerts
+	}
+}
+
+// isCertError reports whether err is a failed certificate verification
+func isCertError(err error) bool {
+	var unknown x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var verify *tls.CertificateVerificationError
+	return errors.As(err, &unknown) || errors.As(err, &invalid) || errors.As(err, &hostname) || errors.As(err, &verify)
+}
+
+// loadCerts reads the certificate(s) at path, PEM or DER
+func loadCerts(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Burp exports DER by default, most other tools PEM
+	if block, _ := pem.Decode(data); block == nil {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: not a PEM or DER certificate: %w", path, err)
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return certs, nil
+}
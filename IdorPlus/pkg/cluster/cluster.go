@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+)
+
+// Lease is a shard handed to a worker
+type Lease struct {
+	Shard   int             `json:"shard"`
+	Options scanner.Options `json:"options"`
+	// HeartbeatEvery is how often the worker must renew the lease
+	HeartbeatEvery time.Duration `json:"heartbeat_every"`
+}
+
+// ShardResult is what a worker reports for a finished shard
+type ShardResult struct {
+	Worker   string              `json:"worker"`
+	Findings []*reporter.Finding `json:"findings,omitempty"`
+	Stats    Stats               `json:"stats"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// Stats are the request counters of a shard, summed by the coordinator
+type Stats struct {
+	Requests   int64 `json:"requests"`
+	Success    int64 `json:"success"`
+	Failed     int64 `json:"failed"`
+	Vulnerable int64 `json:"vulnerable"`
+	Blocked    int64 `json:"blocked"`
+	Skipped    int64 `json:"skipped"`
+}
+
+// Add sums other into s
+func (s *Stats) Add(other Stats) {
+	s.Requests += other.Requests
+	s.Success += other.Success
+	s.Failed += other.Failed
+	s.Vulnerable += other.Vulnerable
+	s.Blocked += other.Blocked
+	s.Skipped += other.Skipped
+}
+
+// statsFrom copies the counters of a fuzz engine
+func statsFrom(st *fuzzer.Stats) Stats {
+	return Stats{
+		Requests:   st.TotalRequests,
+		Success:    st.SuccessCount,
+		Failed:     st.FailedCount,
+		Vulnerable: st.VulnCount,
+		Blocked:    st.BlockedCount,
+		Skipped:    st.SkippedCount,
+	}
+}
+
+// Split shards the payloads of a target into chunks of size. Each shard
+// carries the full options with only its own payloads.
+func Split(opts scanner.Options, payloads []string, size int) []scanner.Options {
+	if size <= 0 {
+		size = len(payloads)
+	}
+	var shards []scanner.Options
+	for start := 0; start < len(payloads); start += size {
+		end := min(start+size, len(payloads))
+		shard := opts
+		shard.Payloads = payloads[start:end]
+		// The payloads are final, encodings included
+		shard.Encodings = nil
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// String describes a shard for logs
+func (l *Lease) String() string {
+	return fmt.Sprintf("shard %d (%s, %d IDs)", l.Shard, l.Options.URL, len(l.Options.Payloads))
+}
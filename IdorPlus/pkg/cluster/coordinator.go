@@ -0,0 +1,301 @@
+package cluster
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+// Shard states
+const (
+	ShardPending = "pending"
+	ShardLeased  = "leased"
+	ShardDone    = "done"
+	ShardFailed  = "failed"
+)
+
+type shard struct {
+	id       int
+	options  scanner.Options
+	state    string
+	worker   string
+	deadline time.Time
+	attempts int
+	lastErr  string
+}
+
+// Coordinator splits a scan into shards that workers lease over HTTP.
+// A shard whose lease is not renewed by heartbeats goes back to the queue
+// and is handed to another worker, so a crashed worker only delays it.
+// Findings and stats of finished shards are aggregated centrally.
+type Coordinator struct {
+	// Reporter receives the findings of every finished shard
+	Reporter *reporter.Reporter
+	// Token, when set, is required from workers as "Authorization: Bearer <token>"
+	Token string
+	// LeaseTimeout is how long a lease lasts without a heartbeat
+	LeaseTimeout time.Duration
+	// MaxAttempts is how often a shard is retried before it is given up
+	MaxAttempts int
+
+	mu      sync.Mutex
+	shards  []*shard
+	stats   Stats
+	workers map[string]time.Time // last contact
+	done    chan struct{}
+	mux     *http.ServeMux
+}
+
+// NewCoordinator creates a coordinator for the given shards, see Split
+func NewCoordinator(shards []scanner.Options, rep *reporter.Reporter) *Coordinator {
+	c := &Coordinator{
+		Reporter:     rep,
+		LeaseTimeout: time.Minute,
+		MaxAttempts:  3,
+		workers:      make(map[string]time.Time),
+		done:         make(chan struct{}),
+		mux:          http.NewServeMux(),
+	}
+	for i, opts := range shards {
+		c.shards = append(c.shards, &shard{id: i, options: opts, state: ShardPending})
+	}
+	if len(c.shards) == 0 {
+		close(c.done)
+	}
+
+	c.mux.HandleFunc("POST /api/lease", c.handleLease)
+	c.mux.HandleFunc("POST /api/shards/{id}/heartbeat", c.handleHeartbeat)
+	c.mux.HandleFunc("POST /api/shards/{id}/complete", c.handleComplete)
+	c.mux.HandleFunc("GET /api/status", c.handleStatus)
+	return c
+}
+
+// Wait blocks until every shard is done or given up, expiring stale leases
+// meanwhile. It returns early with ctx's error.
+func (c *Coordinator) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(max(c.LeaseTimeout/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return nil
+		case <-ticker.C:
+			c.expireLeases(time.Now())
+		}
+	}
+}
+
+// Stats returns the summed stats of finished shards
+func (c *Coordinator) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Status summarizes shard states and workers
+type Status struct {
+	Shards  map[string]int `json:"shards"`
+	Workers []string       `json:"workers"`
+	Stats   Stats          `json:"stats"`
+}
+
+// Status returns the current progress
+func (c *Coordinator) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := Status{Shards: make(map[string]int), Stats: c.stats}
+	for _, s := range c.shards {
+		st.Shards[s.state]++
+	}
+	for w := range c.workers {
+		st.Workers = append(st.Workers, w)
+	}
+	return st
+}
+
+// ServeHTTP implements http.Handler
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) != 1 {
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+	}
+	c.mux.ServeHTTP(w, r)
+}
+
+// expireLeases returns shards whose lease ran out to the queue
+func (c *Coordinator) expireLeases(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.shards {
+		if s.state == ShardLeased && now.After(s.deadline) {
+			utils.Warning.Printf("Worker %s lost shard %d, reassigning\n", s.worker, s.id)
+			c.retry(s, "lease expired on "+s.worker)
+		}
+	}
+}
+
+// retry puts a shard back in the queue or gives up on it. Called with mu held.
+func (c *Coordinator) retry(s *shard, reason string) {
+	s.lastErr, s.worker = reason, ""
+	if s.attempts >= c.MaxAttempts {
+		s.state = ShardFailed
+		utils.Error.Printf("Giving up on shard %d after %d attempts: %s\n", s.id, s.attempts, reason)
+		c.checkDone()
+		return
+	}
+	s.state = ShardPending
+}
+
+// checkDone closes done once no shard is pending or leased. Called with mu held.
+func (c *Coordinator) checkDone() {
+	for _, s := range c.shards {
+		if s.state == ShardPending || s.state == ShardLeased {
+			return
+		}
+	}
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
+type workerRequest struct {
+	Worker string `json:"worker"`
+}
+
+func (c *Coordinator) handleLease(w http.ResponseWriter, r *http.Request) {
+	var req workerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Worker == "" {
+		http.Error(w, "worker name required", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[req.Worker] = time.Now()
+
+	select {
+	case <-c.done:
+		// Nothing left, the worker can exit
+		w.WriteHeader(http.StatusGone)
+		return
+	default:
+	}
+
+	for _, s := range c.shards {
+		if s.state != ShardPending {
+			continue
+		}
+		s.state, s.worker = ShardLeased, req.Worker
+		s.deadline = time.Now().Add(c.LeaseTimeout)
+		s.attempts++
+		lease := &Lease{Shard: s.id, Options: s.options, HeartbeatEvery: c.LeaseTimeout / 3}
+		utils.Info.Printf("Leased %s to %s\n", lease, req.Worker)
+		writeJSON(w, lease)
+		return
+	}
+	// Everything is leased, the worker should poll again
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// leased looks up the shard of the request and checks the worker holds it
+func (c *Coordinator) leased(w http.ResponseWriter, r *http.Request, worker string) (*shard, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 0 || id >= len(c.shards) {
+		http.Error(w, "unknown shard", http.StatusNotFound)
+		return nil, false
+	}
+	s := c.shards[id]
+	if s.state != ShardLeased || s.worker != worker {
+		http.Error(w, "lease lost", http.StatusConflict)
+		return nil, false
+	}
+	return s, true
+}
+
+func (c *Coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var req workerRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[req.Worker] = time.Now()
+	s, ok := c.leased(w, r, req.Worker)
+	if !ok {
+		return
+	}
+	s.deadline = time.Now().Add(c.LeaseTimeout)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Coordinator) handleComplete(w http.ResponseWriter, r *http.Request) {
+	var res ShardResult
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		http.Error(w, "invalid result: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[res.Worker] = time.Now()
+	s, ok := c.leased(w, r, res.Worker)
+	if !ok {
+		// The shard was reassigned; its new owner reports it
+		return
+	}
+
+	if res.Error != "" {
+		utils.Warning.Printf("Shard %d failed on %s: %s\n", s.id, res.Worker, res.Error)
+		c.retry(s, res.Error)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.state = ShardDone
+	c.stats.Add(res.Stats)
+	for _, f := range res.Findings {
+		c.Reporter.AddCustomFinding(f)
+	}
+	utils.Success.Printf("Shard %d done on %s: %d requests, %d findings\n",
+		s.id, res.Worker, res.Stats.Requests, len(res.Findings))
+	c.checkDone()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Coordinator) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.Status())
+}
+
+// Failed returns a description of every shard that was given up
+func (c *Coordinator) Failed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var failed []string
+	for _, s := range c.shards {
+		if s.state == ShardFailed {
+			failed = append(failed, fmt.Sprintf("shard %d (%s, %d IDs): %s", s.id, s.options.URL, len(s.options.Payloads), s.lastErr))
+		}
+	}
+	return failed
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,199 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+var (
+	// errLeaseLost means the coordinator handed the shard to another worker
+	errLeaseLost = errors.New("lease lost")
+	// errDone means the coordinator has no shards left
+	errDone = errors.New("no shards left")
+)
+
+// Worker leases shards from a coordinator and scans them until none are left
+type Worker struct {
+	// Coordinator is the coordinator's base URL
+	Coordinator string
+	// Token is sent as "Authorization: Bearer <token>"
+	Token string
+	// Name identifies the worker in the coordinator's logs
+	Name string
+
+	// Config is the local configuration; WAF bypass, rate limits and
+	// proxies are per worker, with the config's target overrides applied
+	// per shard
+	Config    *utils.Config
+	NewClient func(*utils.Config) (*client.SmartClient, error)
+
+	// PollInterval is how long to wait when every shard is leased
+	PollInterval time.Duration
+	HTTP         *http.Client
+}
+
+// Run works through shards until the coordinator has none left or ctx is
+// cancelled. A shard interrupted by ctx is reassigned once its lease expires.
+func (w *Worker) Run(ctx context.Context) error {
+	if w.HTTP == nil {
+		w.HTTP = &http.Client{Timeout: 30 * time.Second}
+	}
+	if w.PollInterval <= 0 {
+		w.PollInterval = 2 * time.Second
+	}
+
+	for {
+		lease, err := w.lease(ctx)
+		switch {
+		case errors.Is(err, errDone):
+			utils.Info.Println("No shards left, exiting")
+			return nil
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			utils.Warning.Printf("Coordinator unavailable: %v\n", err)
+		case lease == nil:
+			// All shards are leased to others, wait in case one comes back
+		default:
+			w.runShard(ctx, lease)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// lease asks for a shard. It returns nil when every shard is leased.
+func (w *Worker) lease(ctx context.Context) (*Lease, error) {
+	resp, err := w.post(ctx, "/api/lease", workerRequest{Worker: w.Name})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var lease Lease
+		if err := json.NewDecoder(resp.Body).Decode(&lease); err != nil {
+			return nil, err
+		}
+		return &lease, nil
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusGone:
+		return nil, errDone
+	default:
+		return nil, statusError(resp)
+	}
+}
+
+// runShard scans one shard while renewing its lease, then reports back
+func (w *Worker) runShard(ctx context.Context, lease *Lease) {
+	utils.Info.Printf("Scanning %s\n", lease)
+
+	shardCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go w.heartbeat(shardCtx, lease, cancel)
+
+	res := ShardResult{Worker: w.Name}
+	cfg, err := w.Config.ForTarget(lease.Options.URL)
+	var c *client.SmartClient
+	if err == nil {
+		c, err = w.NewClient(cfg)
+	}
+	if err == nil {
+		sc := scanner.New(c, cfg, lease.Options)
+		err = sc.Run(shardCtx)
+		if sc.Engine != nil {
+			res.Stats = statsFrom(sc.Engine.Stats)
+		}
+		res.Findings = sc.Reporter.Findings
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down: let the lease expire so another worker takes over
+		return
+	case errors.Is(context.Cause(shardCtx), errLeaseLost):
+		utils.Warning.Printf("Lost %s to another worker\n", lease)
+		return
+	case err != nil:
+		res.Error = err.Error()
+	}
+
+	resp, err := w.post(ctx, fmt.Sprintf("/api/shards/%d/complete", lease.Shard), res)
+	if err != nil {
+		utils.Error.Printf("Failed to report shard %d: %v\n", lease.Shard, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		utils.Warning.Printf("Coordinator rejected shard %d: %v\n", lease.Shard, statusError(resp))
+		return
+	}
+	utils.Success.Printf("Finished shard %d: %d findings\n", lease.Shard, len(res.Findings))
+}
+
+// heartbeat renews the lease until ctx ends, cancelling the shard if the
+// coordinator reassigned it
+func (w *Worker) heartbeat(ctx context.Context, lease *Lease, cancel context.CancelCauseFunc) {
+	every := lease.HeartbeatEvery
+	if every <= 0 {
+		every = 10 * time.Second
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			resp, err := w.post(ctx, fmt.Sprintf("/api/shards/%d/heartbeat", lease.Shard), workerRequest{Worker: w.Name})
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusConflict {
+				cancel(errLeaseLost)
+				return
+			}
+		}
+	}
+}
+
+func (w *Worker) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(w.Coordinator, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	return w.HTTP.Do(req)
+}
+
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("coordinator returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"context"
 	"net/url"
 	"strings"
 
@@ -26,13 +27,13 @@ func NewCrawler(c *client.SmartClient) *Crawler {
 	}
 }
 
-func (c *Crawler) Crawl(startURL string) []string {
-	c.crawlRecursive(startURL, 0)
+func (c *Crawler) Crawl(ctx context.Context, startURL string) []string {
+	c.crawlRecursive(ctx, startURL, 0)
 	return c.Endpoints
 }
 
-func (c *Crawler) crawlRecursive(currentURL string, depth int) {
-	if depth > c.Depth || len(c.Visited) >= c.MaxPages {
+func (c *Crawler) crawlRecursive(ctx context.Context, currentURL string, depth int) {
+	if ctx.Err() != nil || depth > c.Depth || len(c.Visited) >= c.MaxPages {
 		return
 	}
 	if c.Visited[currentURL] {
@@ -40,7 +41,7 @@ func (c *Crawler) crawlRecursive(currentURL string, depth int) {
 	}
 	c.Visited[currentURL] = true
 
-	resp, err := c.Client.Request().Get(currentURL)
+	resp, err := c.Client.Request(ctx).Get(currentURL)
 	if err != nil {
 		return
 	}
@@ -56,6 +57,9 @@ func (c *Crawler) crawlRecursive(currentURL string, depth int) {
 		for _, ep := range endpoints {
 			// Resolve relative URLs
 			fullURL := c.resolveURL(currentURL, ep)
+			if !c.Client.GetScope().Allows(fullURL) {
+				continue
+			}
 			c.Endpoints = append(c.Endpoints, fullURL)
 		}
 	} else {
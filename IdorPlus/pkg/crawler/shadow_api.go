@@ -206,7 +206,7 @@ func (s *ShadowAPIDiscoverer) GetEndpointsWithIDParams() []EndpointInfo {
 	var withID []EndpointInfo
 	for _, ep := range s.foundEndpoints {
 		for _, param := range ep.ParamNames {
-			if isIDParam(param) {
+			if IsIDParam(param) {
 				withID = append(withID, ep)
 				break
 			}
@@ -244,7 +244,8 @@ func extractParamNames(url string) []string {
 	return params
 }
 
-func isIDParam(param string) bool {
+// IsIDParam reports whether a parameter name looks like it holds an ID
+func IsIDParam(param string) bool {
 	param = strings.ToLower(param)
 	idPatterns := []string{"id", "uid", "uuid", "guid", "key", "token"}
 	for _, p := range idPatterns {
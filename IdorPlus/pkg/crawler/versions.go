@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/client"
+)
+
+// unlistedVersions are tried under every versioned API root even when no
+// discovered endpoint uses them
+var unlistedVersions = []string{"v0", "beta", "internal"}
+
+// VersionRoot is an API whose paths carry a version: /api/v1/... and
+// /api/v2/... share the root /api
+type VersionRoot struct {
+	Prefix string
+	// Versions are the versions seen, oldest first, with the number of
+	// endpoints under each. Canonical is the newest.
+	Versions  []string
+	Endpoints map[string]int
+	Canonical string
+}
+
+// VersionMap holds the versioned API roots of the discovered endpoints
+type VersionMap struct {
+	Roots []*VersionRoot
+}
+
+// BuildVersionMap groups the endpoints by API root and version
+func BuildVersionMap(endpoints []EndpointInfo) *VersionMap {
+	roots := make(map[string]*VersionRoot)
+	for _, ep := range endpoints {
+		prefix, version, _, ok := splitVersion(ep.URL)
+		if !ok {
+			continue
+		}
+		root := roots[prefix]
+		if root == nil {
+			root = &VersionRoot{Prefix: prefix, Endpoints: make(map[string]int)}
+			roots[prefix] = root
+		}
+		if root.Endpoints[version] == 0 {
+			root.Versions = append(root.Versions, version)
+		}
+		root.Endpoints[version]++
+	}
+
+	m := &VersionMap{}
+	for _, root := range roots {
+		sort.Slice(root.Versions, func(i, j int) bool { return versionLess(root.Versions[i], root.Versions[j]) })
+		root.Canonical = root.Versions[len(root.Versions)-1]
+		m.Roots = append(m.Roots, root)
+	}
+	sort.Slice(m.Roots, func(i, j int) bool { return m.Roots[i].Prefix < m.Roots[j].Prefix })
+	return m
+}
+
+// Siblings returns the URLs of an endpoint under the other versions of its
+// root: the versions seen, the next one, and v0, beta and internal. An
+// unversioned endpoint under a versioned root gets every version.
+func (m *VersionMap) Siblings(rawURL string) []client.PathMutation {
+	origin, path := splitOrigin(rawURL)
+	path, query, hasQuery := strings.Cut(path, "?")
+	if hasQuery {
+		query = "?" + query
+	}
+	prefix, version, rest, ok := splitVersion(path)
+	var root *VersionRoot
+	for _, r := range m.Roots {
+		if ok && r.Prefix == prefix {
+			root = r
+			break
+		}
+		// The longest root the unversioned path is under
+		if !ok && strings.HasPrefix(path, r.Prefix+"/") && (root == nil || len(r.Prefix) > len(root.Prefix)) {
+			root = r
+		}
+	}
+	if root == nil {
+		return nil
+	}
+	if !ok {
+		prefix, rest = root.Prefix, strings.TrimPrefix(path, root.Prefix)
+	}
+
+	candidates := append([]string{}, root.Versions...)
+	if n, ok := versionNumber(root.Canonical); ok {
+		candidates = append(candidates, fmt.Sprintf("v%d", n+1))
+	}
+	candidates = append(candidates, unlistedVersions...)
+
+	var out []client.PathMutation
+	seen := map[string]bool{version: true}
+	for _, v := range candidates {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		technique := "version " + v
+		switch {
+		case !slices.Contains(root.Versions, v):
+			technique = "unlisted version " + v
+		case v == root.Canonical:
+			technique = "canonical version " + v
+		}
+		out = append(out, client.PathMutation{Technique: technique, URL: origin + prefix + "/" + v + rest + query})
+	}
+	return out
+}
+
+// splitVersion splits the path of a URL at its first version segment into
+// the root before it, the version and the path after it. ok is false for
+// unversioned paths.
+func splitVersion(rawURL string) (prefix, version, rest string, ok bool) {
+	_, path := splitOrigin(rawURL)
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if _, _, ok := client.ParseVersion(s); ok {
+			return strings.Join(segments[:i], "/"), strings.ToLower(s), strings.TrimPrefix(path, strings.Join(segments[:i+1], "/")), true
+		}
+	}
+	return "", "", "", false
+}
+
+// splitOrigin splits an absolute URL into its scheme and host and its
+// path; a relative URL is all path
+func splitOrigin(rawURL string) (origin, path string) {
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		return "", rawURL
+	}
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		return scheme + "://" + rest[:i], rest[i:]
+	}
+	return rawURL, ""
+}
+
+// versionNumber returns the major number of a version segment
+func versionNumber(v string) (int, bool) {
+	major, _, ok := client.ParseVersion(v)
+	return major, ok
+}
+
+// versionLess orders versions by major and minor number
+func versionLess(a, b string) bool {
+	majorA, minorA, okA := client.ParseVersion(a)
+	majorB, minorB, okB := client.ParseVersion(b)
+	if !okA || !okB {
+		return a < b
+	}
+	if majorA != majorB {
+		return majorA < majorB
+	}
+	x, _ := strconv.Atoi(minorA)
+	y, _ := strconv.Atoi(minorB)
+	return x < y
+}
@@ -0,0 +1,104 @@
+package detector
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
+)
+
+// APIVersionTester retries a request against older, newer, internal and
+// mobile versions of the same API, which often lack the access checks of
+// the newest public one
+type APIVersionTester struct {
+	client *client.SmartClient
+}
+
+// NewAPIVersionTester creates a new API version tester
+func NewAPIVersionTester(c *client.SmartClient) *APIVersionTester {
+	return &APIVersionTester{client: c}
+}
+
+// TestEndpoint sends the original request and, if it is denied or
+// succeeds, the same request to every other version of the API. A
+// successful variant of a denied request bypasses the access check; of a
+// successful one, exposes the same object elsewhere. Only GET requests are
+// tested, other methods would change the object on every version.
+func (a *APIVersionTester) TestEndpoint(ctx context.Context, url, method, session string) *TamperResult {
+	return a.TestVariants(ctx, url, method, session, client.GenerateVersionVariants(url))
+}
+
+// TestVariants is TestEndpoint with the version URLs given, e.g. the
+// siblings of a discovered version map
+func (a *APIVersionTester) TestVariants(ctx context.Context, url, method, session string, variants []client.PathMutation) *TamperResult {
+	method = strings.ToUpper(method)
+	result := &TamperResult{
+		URL:    url,
+		Method: method,
+	}
+	if method != http.MethodGet {
+		return result
+	}
+
+	baseline, err := sendRequest(ctx, a.client, method, url, session, nil, "")
+	if err != nil {
+		return result
+	}
+	result.BaselineStatus = baseline.StatusCode()
+	if !IsDenied(result.BaselineStatus) && !baseline.IsSuccess() {
+		return result
+	}
+
+	var attempts []*TamperAttempt
+	for _, m := range variants {
+		attempts = append(attempts, &TamperAttempt{
+			Technique: m.Technique,
+			Method:    method,
+			URL:       m.URL,
+		})
+	}
+
+	runAttempts(ctx, a.client, result, attempts, session)
+
+	// A version answering any path, e.g. with an SPA page, serves nothing
+	result.IsVulnerable = false
+	for _, attempt := range result.Attempts {
+		if attempt.Bypassed && a.catchAll(ctx, attempt, session) {
+			attempt.Bypassed = false
+		}
+		result.IsVulnerable = result.IsVulnerable || attempt.Bypassed
+	}
+	return result
+}
+
+// catchAll reports whether the version of an attempt answers a random path
+// next to it with the same status and about the same size, its soft 404
+func (a *APIVersionTester) catchAll(ctx context.Context, attempt *TamperAttempt, session string) bool {
+	u, err := url.Parse(attempt.URL)
+	if err != nil {
+		return false
+	}
+	dir, _ := path.Split(strings.TrimSuffix(u.Path, "/"))
+	u.Path, u.RawPath = dir+"idorplus-"+strings.ToLower(utils.RandomString(12)), ""
+	resp, err := sendRequest(ctx, a.client, http.MethodGet, u.String(), session, nil, "")
+	if err != nil || resp.StatusCode() != attempt.StatusCode {
+		return false
+	}
+	diff := math.Abs(float64(analyzer.BodySize(resp) - attempt.ContentLen))
+	return diff <= 0.1*float64(attempt.ContentLen)
+}
+
+// PrintResult prints the version attempts as a table
+func (a *APIVersionTester) PrintResult(result *TamperResult) {
+	if result.Method != http.MethodGet {
+		utils.Info.Printf("API Versions: %s %s not tried, only GET requests are\n", result.Method, result.URL)
+		return
+	}
+	printTamperResult("API Versions", result)
+}
@@ -1,10 +1,12 @@
 package detector
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
 	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
 
 	"github.com/pterm/pterm"
 )
@@ -52,7 +54,7 @@ func (amt *AuthMatrixTester) AddSession(name, cookies string) {
 }
 
 // TestEndpoint tests authorization on a specific endpoint
-func (amt *AuthMatrixTester) TestEndpoint(url, method string) *MatrixResult {
+func (amt *AuthMatrixTester) TestEndpoint(ctx context.Context, url, method string) *MatrixResult {
 	amt.mu.RLock()
 	defer amt.mu.RUnlock()
 
@@ -64,12 +66,12 @@ func (amt *AuthMatrixTester) TestEndpoint(url, method string) *MatrixResult {
 
 	// Test with each session
 	for name := range amt.sessions {
-		sessionResult := amt.testWithSession(url, method, name)
+		sessionResult := amt.testWithSession(ctx, url, method, name)
 		result.Results[name] = sessionResult
 	}
 
 	// Test without any session
-	noSessionResult := amt.testWithoutSession(url, method)
+	noSessionResult := amt.testWithoutSession(ctx, url, method)
 	result.Results["no_session"] = noSessionResult
 
 	// Analyze results for IDOR
@@ -79,7 +81,7 @@ func (amt *AuthMatrixTester) TestEndpoint(url, method string) *MatrixResult {
 }
 
 // testWithSession tests endpoint with a specific session
-func (amt *AuthMatrixTester) testWithSession(url, method, sessionName string) *SessionResult {
+func (amt *AuthMatrixTester) testWithSession(ctx context.Context, url, method, sessionName string) *SessionResult {
 	session := amt.client.GetSessionManager().GetSession(sessionName)
 	if session == nil {
 		return &SessionResult{
@@ -88,7 +90,8 @@ func (amt *AuthMatrixTester) testWithSession(url, method, sessionName string) *S
 		}
 	}
 
-	req := amt.client.Request()
+	req := amt.client.Request(ctx)
+	req.SetContext(client.WithSession(req.Context(), sessionName))
 
 	// Add session cookies
 	for _, cookie := range session.Cookies {
@@ -139,8 +142,8 @@ func (amt *AuthMatrixTester) testWithSession(url, method, sessionName string) *S
 }
 
 // testWithoutSession tests endpoint without any authentication
-func (amt *AuthMatrixTester) testWithoutSession(url, method string) *SessionResult {
-	req := amt.client.Request()
+func (amt *AuthMatrixTester) testWithoutSession(ctx context.Context, url, method string) *SessionResult {
+	req := amt.client.Request(ctx)
 
 	// Execute request without cookies
 	var resp interface {
@@ -251,9 +254,9 @@ func (amt *AuthMatrixTester) PrintMatrix(result *MatrixResult) {
 	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
 
 	if result.IsVulnerable {
-		pterm.Error.Printf("IDOR DETECTED: %s\n", result.Reason)
+		utils.Error.Printf("IDOR DETECTED: %s\n", result.Reason)
 	} else {
-		pterm.Success.Println("No IDOR detected for this endpoint")
+		utils.Success.Println("No IDOR detected for this endpoint")
 	}
 }
 
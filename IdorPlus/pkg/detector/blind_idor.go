@@ -46,7 +46,7 @@ func (b *BlindIDORDetector) DetectByTiming(ctx context.Context, validURL, invali
 		}
 
 		start := time.Now()
-		_, err := b.client.Request().Get(validURL)
+		_, err := b.client.Request(ctx).Get(validURL)
 		if err != nil {
 			continue
 		}
@@ -63,7 +63,7 @@ func (b *BlindIDORDetector) DetectByTiming(ctx context.Context, validURL, invali
 		}
 
 		start := time.Now()
-		_, err := b.client.Request().Get(invalidURL)
+		_, err := b.client.Request(ctx).Get(invalidURL)
 		if err != nil {
 			continue
 		}
@@ -110,7 +110,7 @@ func (b *BlindIDORDetector) DetectBySequence(ctx context.Context, baseURL string
 		default:
 		}
 
-		resp, err := b.client.Request().Get(baseURL + id)
+		resp, err := b.client.Request(ctx).Get(baseURL + id)
 		if err != nil {
 			continue
 		}
@@ -136,7 +136,7 @@ func (b *BlindIDORDetector) DetectByErrorMessage(ctx context.Context, url string
 		default:
 		}
 
-		resp, err := b.client.Request().Get(url + id)
+		resp, err := b.client.Request(ctx).Get(url + id)
 		if err != nil {
 			continue
 		}
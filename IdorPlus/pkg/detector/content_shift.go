@@ -0,0 +1,70 @@
+package detector
+
+import (
+	"context"
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/generator"
+)
+
+// ContentShiftTester re-sends denied requests with the same body data encoded
+// as JSON, form, XML and multipart, since authorization is often enforced by
+// only one of the body parsers
+type ContentShiftTester struct {
+	client *client.SmartClient
+}
+
+// NewContentShiftTester creates a new content-type shift tester
+func NewContentShiftTester(c *client.SmartClient) *ContentShiftTester {
+	return &ContentShiftTester{client: c}
+}
+
+// TestRequest sends the original request and, if it is denied, the body in
+// every other supported format. format is detected from body when empty.
+func (cs *ContentShiftTester) TestRequest(ctx context.Context, url, method, body, format, session string) *TamperResult {
+	method = strings.ToUpper(method)
+	result := &TamperResult{
+		URL:    url,
+		Method: method,
+	}
+
+	if format == "" {
+		format = generator.DetectBodyFormat(body)
+	}
+	headers := map[string]string{"Content-Type": generator.ContentTypeFor(format)}
+
+	baseline, err := sendRequest(ctx, cs.client, method, url, session, headers, body)
+	if err != nil {
+		return result
+	}
+	result.BaselineStatus = baseline.StatusCode()
+
+	if !IsDenied(result.BaselineStatus) {
+		return result
+	}
+
+	shifted, err := generator.ShiftContentType(body, format)
+	if err != nil {
+		return result
+	}
+
+	var attempts []*TamperAttempt
+	for _, sb := range shifted {
+		attempts = append(attempts, &TamperAttempt{
+			Technique: format + " -> " + sb.Format,
+			Method:    method,
+			URL:       url,
+			Headers:   map[string]string{"Content-Type": sb.ContentType},
+			Body:      sb.Body,
+		})
+	}
+
+	runAttempts(ctx, cs.client, result, attempts, session)
+	return result
+}
+
+// PrintResult prints the content-type shift attempts as a table
+func (cs *ContentShiftTester) PrintResult(result *TamperResult) {
+	printTamperResult("Content-Type Shift", result)
+}
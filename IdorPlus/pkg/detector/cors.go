@@ -0,0 +1,146 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pterm/pterm"
+)
+
+// corsAttackerDomain is the attacker's site in the origins sent
+const corsAttackerDomain = "idorplus-attacker.com"
+
+// CORSTester sends a request with attacker origins and checks whether the
+// response lets them read it with the victim's cookies: a reflected
+// Access-Control-Allow-Origin together with Access-Control-Allow-Credentials
+type CORSTester struct {
+	client *client.SmartClient
+	// Session is sent with every request, the cookies a browser would
+	// attach for the victim
+	Session string
+}
+
+// CORSAttempt is the request with one attacker origin
+type CORSAttempt struct {
+	Technique   string
+	Origin      string
+	StatusCode  int
+	ContentLen  int
+	AllowOrigin string
+	Credentials bool
+	Response    *resty.Response
+	// Vulnerable is set when the origin may read the response with
+	// credentials
+	Vulnerable bool
+}
+
+// CORSResult aggregates the attempts of one request
+type CORSResult struct {
+	URL          string
+	Method       string
+	Headers      map[string]string
+	Body         string
+	Attempts     []*CORSAttempt
+	IsVulnerable bool
+}
+
+// NewCORSTester creates a new CORS tester
+func NewCORSTester(c *client.SmartClient) *CORSTester {
+	return &CORSTester{client: c}
+}
+
+// CORSOrigin is an attacker origin and how it tries to pass the server's
+// origin check
+type CORSOrigin struct {
+	Technique string
+	Origin    string
+}
+
+// CORSOrigins returns the attacker origins tried against a URL: an
+// arbitrary site, null (sandboxed iframes, file:// pages), and the target's
+// host as a prefix and a suffix of the attacker's, which slip past
+// unanchored origin checks
+func CORSOrigins(rawURL string) []CORSOrigin {
+	origins := []CORSOrigin{
+		{"arbitrary origin", "https://" + corsAttackerDomain},
+		{"null origin", "null"},
+	}
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		origins = append(origins,
+			CORSOrigin{"target as prefix", u.Scheme + "://" + u.Hostname() + "." + corsAttackerDomain},
+			CORSOrigin{"target as suffix", u.Scheme + "://" + strings.TrimSuffix(corsAttackerDomain, ".com") + u.Hostname()},
+		)
+	}
+	return origins
+}
+
+// Test sends the request once per attacker origin. headers and body are
+// sent as-is with each.
+func (t *CORSTester) Test(ctx context.Context, method, url string, headers map[string]string, body string) *CORSResult {
+	method = strings.ToUpper(method)
+	result := &CORSResult{URL: url, Method: method, Headers: headers, Body: body}
+
+	for _, o := range CORSOrigins(url) {
+		h := make(map[string]string, len(headers)+1)
+		for k, v := range headers {
+			h[k] = v
+		}
+		h["Origin"] = o.Origin
+
+		resp, err := sendRequest(ctx, t.client, method, url, t.Session, h, body)
+		if err != nil {
+			continue
+		}
+		a := &CORSAttempt{
+			Technique:   o.Technique,
+			Origin:      o.Origin,
+			StatusCode:  resp.StatusCode(),
+			ContentLen:  analyzer.BodySize(resp),
+			AllowOrigin: resp.Header().Get("Access-Control-Allow-Origin"),
+			Credentials: strings.EqualFold(strings.TrimSpace(resp.Header().Get("Access-Control-Allow-Credentials")), "true"),
+			Response:    resp,
+		}
+		// Browsers never send cookies to a wildcard, only to the exact origin
+		a.Vulnerable = a.Credentials && a.AllowOrigin == o.Origin && resp.IsSuccess()
+		if a.Vulnerable {
+			result.IsVulnerable = true
+		}
+		result.Attempts = append(result.Attempts, a)
+	}
+	return result
+}
+
+// PrintResult prints the origins tried as a table
+func (t *CORSTester) PrintResult(result *CORSResult) {
+	pterm.DefaultSection.Printf("CORS: %s %s\n", result.Method, result.URL)
+	if len(result.Attempts) == 0 {
+		utils.Info.Println("No response to any origin")
+		return
+	}
+
+	tableData := pterm.TableData{
+		{"Technique", "Origin", "Status", "Allow-Origin", "Credentials", "Result"},
+	}
+	for _, a := range result.Attempts {
+		status := pterm.Gray("denied")
+		if a.Vulnerable {
+			status = pterm.Red("READABLE")
+		}
+		tableData = append(tableData, []string{
+			a.Technique,
+			a.Origin,
+			fmt.Sprintf("%d", a.StatusCode),
+			a.AllowOrigin,
+			fmt.Sprintf("%t", a.Credentials),
+			status,
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
@@ -0,0 +1,275 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pterm/pterm"
+)
+
+// DownloadTester fetches the files an export, report or download endpoint
+// generates for other users' IDs. Generated files are binary or CSV, so
+// they are compared by hash, and the author and email fields they carry
+// show whose they are.
+type DownloadTester struct {
+	client *client.SmartClient
+	// Session downloads the files. VictimSession, if set, downloads them
+	// too, confirming the attacker got the victim's copy.
+	Session       string
+	VictimSession string
+}
+
+// DownloadPatterns are common export and download endpoints, appended to
+// a base URL by Discover
+var DownloadPatterns = []string{
+	"/export?id={ID}",
+	"/export/{ID}",
+	"/exports/{ID}.csv",
+	"/download?id={ID}",
+	"/download/{ID}",
+	"/files/{ID}/download",
+	"/documents/{ID}/download",
+	"/attachments/{ID}",
+	"/reports/{ID}.pdf",
+	"/reports/{ID}/download",
+	"/reports/{ID}/export?format=csv",
+	"/invoices/{ID}.pdf",
+	"/invoices/{ID}/download",
+	"/invoices/{ID}/pdf",
+	"/statements/{ID}.pdf",
+	"/receipts/{ID}.pdf",
+}
+
+// downloadURL matches the paths and queries of export and download endpoints
+var downloadURL = regexp.MustCompile(`(?i)/(exports?|downloads?|reports?|invoices?|statements?|receipts?|attachments?)(/|\?|$|\.)|\.(pdf|csv|xlsx?|docx?|zip)(\?|$)|[?&](format|type|export)=(pdf|csv|xlsx?)`)
+
+// IsDownloadEndpoint reports whether a URL looks like an export, report or
+// download endpoint
+func IsDownloadEndpoint(url string) bool {
+	return downloadURL.MatchString(url)
+}
+
+// Download is the file fetched for one ID
+type Download struct {
+	ID         string
+	URL        string
+	StatusCode int
+	File       *analyzer.FileInfo
+	// Owners are the file's author and email fields the attacker's own
+	// file doesn't have
+	Owners []string
+	// Response is the response body, for PII checks
+	Response []byte
+	// VictimConfirmed is set when the victim session downloads the same file
+	VictimConfirmed bool
+	Vulnerable      bool
+	Reason          string
+}
+
+// DownloadResult aggregates the downloads of an endpoint
+type DownloadResult struct {
+	URL     string // with {ID}
+	OwnID   string
+	Headers map[string]string
+	// OwnStatus and Own describe the attacker's own file. Placeholder is
+	// the file served for an ID that doesn't exist, if any.
+	OwnStatus   int
+	Own         *analyzer.FileInfo
+	Placeholder *analyzer.FileInfo
+	// Unstable is set when the same file downloads with another hash the
+	// second time, e.g. an export stamped with the time it was made
+	Unstable     bool
+	Downloads    []*Download
+	IsVulnerable bool
+}
+
+// NewDownloadTester creates a new download tester
+func NewDownloadTester(c *client.SmartClient) *DownloadTester {
+	return &DownloadTester{client: c}
+}
+
+// Discover requests every DownloadPatterns below base for ownID and returns
+// the patterns that answer with a file
+func (t *DownloadTester) Discover(ctx context.Context, base string, headers map[string]string, ownID string) []string {
+	base = strings.TrimRight(base, "/")
+	var found []string
+	for _, p := range DownloadPatterns {
+		if ctx.Err() != nil {
+			break
+		}
+		tmpl := base + p
+		resp, err := t.send(ctx, tmpl, t.Session, headers, ownID)
+		if err == nil && resp.IsSuccess() && analyzer.InspectDownload(resp) != nil {
+			found = append(found, tmpl)
+		}
+	}
+	return found
+}
+
+// Test downloads the file of ownID, of an ID that doesn't exist and of
+// every ids from tmpl, a URL with {ID}. A download is vulnerable when it
+// is a file other than the attacker's own and the placeholder. The own
+// file, or else the placeholder, is downloaded twice first: when the two
+// differ hashes tell nothing, and only files naming other owners count.
+func (t *DownloadTester) Test(ctx context.Context, tmpl string, headers map[string]string, ownID string, ids []string) *DownloadResult {
+	result := &DownloadResult{URL: tmpl, OwnID: ownID, Headers: headers}
+
+	var ownOwners []string
+	if ownID != "" {
+		if own, err := t.send(ctx, tmpl, t.Session, headers, ownID); err == nil {
+			result.OwnStatus = own.StatusCode()
+			if own.IsSuccess() {
+				if result.Own = analyzer.InspectDownload(own); result.Own != nil {
+					ownOwners = result.Own.Owners()
+					result.Unstable = t.changes(ctx, tmpl, headers, ownID, result.Own)
+				}
+			}
+		}
+	}
+	if missing, err := t.send(ctx, tmpl, t.Session, headers, missingID(ownID)); err == nil && missing.IsSuccess() {
+		result.Placeholder = analyzer.InspectDownload(missing)
+		if result.Own == nil && result.Placeholder != nil {
+			result.Unstable = t.changes(ctx, tmpl, headers, missingID(ownID), result.Placeholder)
+		}
+	}
+	var placeholderOwners []string
+	if result.Placeholder != nil {
+		placeholderOwners = result.Placeholder.Owners()
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if id == ownID {
+			continue
+		}
+		resp, err := t.send(ctx, tmpl, t.Session, headers, id)
+		if err != nil {
+			continue
+		}
+		d := &Download{ID: id, URL: strings.ReplaceAll(tmpl, "{ID}", id), StatusCode: resp.StatusCode(), Response: resp.Body()}
+		result.Downloads = append(result.Downloads, d)
+		if !resp.IsSuccess() {
+			continue
+		}
+		if d.File = analyzer.InspectDownload(resp); d.File == nil {
+			continue
+		}
+		for _, o := range d.File.Owners() {
+			if !slices.Contains(ownOwners, o) {
+				d.Owners = append(d.Owners, o)
+			}
+		}
+		switch {
+		case result.Unstable && !slices.ContainsFunc(d.Owners, func(o string) bool { return !slices.Contains(placeholderOwners, o) }):
+			continue
+		case result.Own != nil && d.File.SHA256 == result.Own.SHA256:
+			continue
+		case result.Placeholder != nil && d.File.SHA256 == result.Placeholder.SHA256:
+			continue
+		}
+
+		d.Vulnerable = true
+		d.Reason = fmt.Sprintf("Downloads a %s of %d bytes", d.File.ContentType, d.File.Size)
+		if result.Own != nil {
+			d.Reason += " unlike the attacker's own file"
+		}
+		if len(d.Owners) > 0 {
+			d.Reason += ", owned by " + strings.Join(d.Owners, "; ")
+		}
+		if t.VictimSession != "" {
+			if victim, err := t.send(ctx, tmpl, t.VictimSession, headers, id); err == nil && victim.IsSuccess() {
+				if vf := analyzer.InspectDownload(victim); vf != nil && vf.SHA256 == d.File.SHA256 {
+					d.VictimConfirmed = true
+					d.Reason += ", the victim downloads the same file"
+				}
+			}
+		}
+		result.IsVulnerable = true
+	}
+	return result
+}
+
+// changes reports whether id downloads another file than first the second time
+func (t *DownloadTester) changes(ctx context.Context, tmpl string, headers map[string]string, id string, first *analyzer.FileInfo) bool {
+	resp, err := t.send(ctx, tmpl, t.Session, headers, id)
+	if err != nil || !resp.IsSuccess() {
+		return false
+	}
+	again := analyzer.InspectDownload(resp)
+	return again == nil || again.SHA256 != first.SHA256
+}
+
+// send requests the template for id
+func (t *DownloadTester) send(ctx context.Context, tmpl, session string, headers map[string]string, id string) (*resty.Response, error) {
+	h := make(map[string]string, len(headers))
+	for k, v := range headers {
+		h[k] = strings.ReplaceAll(v, "{ID}", id)
+	}
+	return sendRequest(ctx, t.client, "GET", strings.ReplaceAll(tmpl, "{ID}", id), session, h, "")
+}
+
+// missingID returns an ID shaped like id that shouldn't exist: every
+// letter and digit zeroed
+func missingID(id string) string {
+	if id == "" {
+		return "0"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return '0'
+		}
+		return r
+	}, id)
+}
+
+// PrintResult prints the downloads as a table
+func (t *DownloadTester) PrintResult(result *DownloadResult) {
+	pterm.DefaultSection.Printf("Downloads: %s, own ID %s (%d)\n", result.URL, result.OwnID, result.OwnStatus)
+	if result.Own != nil {
+		utils.Info.Printf("Own file: %s\n", strings.ReplaceAll(result.Own.String(), "\n", ", "))
+	}
+	if result.Placeholder != nil {
+		utils.Info.Printf("IDs that don't exist get a %s of %d bytes, ignored\n", result.Placeholder.ContentType, result.Placeholder.Size)
+	}
+	if result.Unstable {
+		utils.Warning.Println("The same file differs between downloads, only files naming other owners are reported")
+	}
+	if len(result.Downloads) == 0 {
+		utils.Info.Println("No IDs to download")
+		return
+	}
+
+	tableData := pterm.TableData{
+		{"ID", "Status", "Type", "Size", "Owner", "Result"},
+	}
+	for _, d := range result.Downloads {
+		kind, size := "-", "-"
+		if d.File != nil {
+			kind, size = d.File.ContentType, fmt.Sprintf("%d", d.File.Size)
+		}
+		status := pterm.Red("NO")
+		if d.Vulnerable {
+			status = pterm.Green("LEAK")
+		}
+		tableData = append(tableData, []string{
+			d.ID,
+			fmt.Sprintf("%d", d.StatusCode),
+			kind,
+			size,
+			strings.Join(d.Owners, "; "),
+			status,
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
@@ -1,10 +1,12 @@
 package detector
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 
 	"idorplus/pkg/analyzer"
+	"idorplus/pkg/utils"
 
 	"github.com/go-resty/resty/v2"
 )
@@ -16,13 +18,29 @@ type IDORDetector struct {
 	Threshold         float64
 	CheckPII          bool
 	piiPatterns       map[string]*regexp.Regexp
+
+	// InvalidProfile summarizes several invalid baselines; responses within
+	// it are the "doesn't exist" answer and never vulnerable
+	InvalidProfile *analyzer.BaselineProfile
+
+	// Collection is set for endpoints returning lists, where an empty list
+	// is the answer for an ID without records and never vulnerable
+	Collection bool
+
+	// Weights score the heuristics; a response is vulnerable from
+	// MinConfidence (0-100) on
+	Weights       utils.ConfidenceWeights
+	MinConfidence int
 }
 
 // NewIDORDetector creates a new IDOR detector
 func NewIDORDetector(validBaseline, invalidBaseline *resty.Response, threshold float64, checkPII bool) *IDORDetector {
+	defaults := utils.DefaultConfig().Detection
 	det := &IDORDetector{
-		Threshold: threshold,
-		CheckPII:  checkPII,
+		Threshold:     threshold,
+		CheckPII:      checkPII,
+		Weights:       defaults.Weights,
+		MinConfidence: defaults.MinConfidence,
 	}
 
 	if validBaseline != nil {
@@ -32,31 +50,73 @@ func NewIDORDetector(validBaseline, invalidBaseline *resty.Response, threshold f
 		det.InvalidComparator = analyzer.NewResponseComparator(invalidBaseline)
 	}
 
-	// Initialize PII patterns
-	det.piiPatterns = map[string]*regexp.Regexp{
-		"email":       regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
-		"phone_us":    regexp.MustCompile(`\(?\d{3}\)?[-.\s]?\d{3}[-.\s]?\d{4}`),
-		"phone_intl":  regexp.MustCompile(`\+\d{1,3}[-.\s]?\d{1,4}[-.\s]?\d{1,4}[-.\s]?\d{1,9}`),
-		"ssn":         regexp.MustCompile(`\d{3}-\d{2}-\d{4}`),
-		"credit_card": regexp.MustCompile(`\d{4}[-\s]?\d{4}[-\s]?\d{4}[-\s]?\d{4}`),
-		"api_key":     regexp.MustCompile(`(api[_-]?key|apikey|api_secret)["\s:=]+["']?([a-zA-Z0-9_-]{20,})["']?`),
-		"jwt":         regexp.MustCompile(`eyJ[a-zA-Z0-9_-]*\.eyJ[a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]*`),
-		"password":    regexp.MustCompile(`(password|passwd|pwd)["\s:=]+["']?([^"'\s]{4,})["']?`),
-		"private_key": regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH )?PRIVATE KEY-----`),
-	}
+	det.piiPatterns = piiPatterns
 
 	return det
 }
 
-// Detect checks if a response indicates an IDOR vulnerability
-func (d *IDORDetector) Detect(resp *resty.Response) bool {
+// piiPatterns match personally identifiable information and secrets, by type
+var piiPatterns = map[string]*regexp.Regexp{
+	"email":       regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
+	"phone_us":    regexp.MustCompile(`\(?\d{3}\)?[-.\s]?\d{3}[-.\s]?\d{4}`),
+	"phone_intl":  regexp.MustCompile(`\+\d{1,3}[-.\s]?\d{1,4}[-.\s]?\d{1,4}[-.\s]?\d{1,9}`),
+	"ssn":         regexp.MustCompile(`\d{3}-\d{2}-\d{4}`),
+	"credit_card": regexp.MustCompile(`\d{4}[-\s]?\d{4}[-\s]?\d{4}[-\s]?\d{4}`),
+	"api_key":     regexp.MustCompile(`(api[_-]?key|apikey|api_secret)["\s:=]+["']?([a-zA-Z0-9_-]{20,})["']?`),
+	"jwt":         regexp.MustCompile(`eyJ[a-zA-Z0-9_-]*\.eyJ[a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]*`),
+	"password":    regexp.MustCompile(`(password|passwd|pwd)["\s:=]+["']?([^"'\s]{4,})["']?`),
+	"private_key": regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH )?PRIVATE KEY-----`),
+}
+
+// FindPII returns the PII matches in text, by type
+func FindPII(text string) map[string][]string {
+	matches := make(map[string][]string)
+	for name, pattern := range piiPatterns {
+		if found := pattern.FindAllString(text, -1); len(found) > 0 {
+			matches[name] = found
+		}
+	}
+	return matches
+}
+
+// Assessment is the detector's verdict on a response
+type Assessment struct {
+	// Confidence is the sum of the weights of the heuristics that matched,
+	// 0-100
+	Confidence int
+	Reasons    []string
+}
+
+func (a *Assessment) add(weight int, reason string) {
+	a.Confidence = min(a.Confidence+weight, 100)
+	a.Reasons = append(a.Reasons, reason)
+}
+
+// Assess scores a response with the weighted heuristics
+func (d *IDORDetector) Assess(resp *resty.Response) *Assessment {
+	a := &Assessment{}
 	if resp == nil {
-		return false
+		return a
+	}
+
+	if d.InvalidProfile != nil && d.InvalidProfile.Matches(resp) {
+		return a
+	}
+	if d.Collection && analyzer.IsCollection(resp.Body()) && analyzer.CountRecords(resp.Body()) == 0 {
+		return a
 	}
 
-	// Heuristic 1: Status code indicates access granted
+	// Heuristic 1: Status code indicates access granted. Behind redirects
+	// the final status says little, where the chain leads decides.
 	statusCode := resp.StatusCode()
-	if statusCode >= 200 && statusCode < 300 {
+	if chain := analyzer.RedirectChain(resp); len(chain) > 0 {
+		if analyzer.IsLoginRedirect(chain) {
+			return a
+		}
+		if d.redirectsElsewhere(chain) {
+			a.add(d.Weights.Redirect, fmt.Sprintf("Redirected to %s, not where the baselines lead", chain[len(chain)-1].To))
+		}
+	} else if statusCode >= 200 && statusCode < 300 {
 		// Check against invalid baseline
 		if d.InvalidComparator != nil {
 			invalidBaseline := d.InvalidComparator.Baseline
@@ -64,11 +124,18 @@ func (d *IDORDetector) Detect(resp *resty.Response) bool {
 			if invalidBaseline.StatusCode() == 403 ||
 				invalidBaseline.StatusCode() == 401 ||
 				invalidBaseline.StatusCode() == 404 {
-				return true
+				a.add(d.Weights.Status, fmt.Sprintf("Status %d where a missing ID gets %d", statusCode, invalidBaseline.StatusCode()))
 			}
 		}
 	}
 
+	// Files are compared by hash and their metadata checked for PII; a
+	// length ratio says nothing about two PDFs
+	if file := analyzer.InspectFile(resp.Body()); file != nil {
+		d.assessFile(a, resp, file)
+		return a
+	}
+
 	// Heuristic 2: Content similarity check
 	if d.ValidComparator != nil {
 		comparison := d.ValidComparator.Compare(resp)
@@ -77,22 +144,98 @@ func (d *IDORDetector) Detect(resp *resty.Response) bool {
 		// AND has successful status code, it might be another user's data
 		if comparison.BodySimilarity < d.Threshold && statusCode >= 200 && statusCode < 300 {
 			// Additional check: make sure it's not just an error page
-			bodyLen := len(resp.Body())
-			baselineLen := len(d.ValidComparator.Baseline.Body())
+			bodyLen := analyzer.BodySize(resp)
+			baselineLen := analyzer.BodySize(d.ValidComparator.Baseline)
 
 			// If response has substantial content
 			if bodyLen > 100 && bodyLen > baselineLen/2 {
-				return true
+				a.add(d.Weights.Similarity, fmt.Sprintf("Content differs from the baseline (similarity %.2f)", comparison.BodySimilarity))
 			}
 		}
 	}
 
+	// Filters such as id[$ne]=1 or ' OR '1'='1 return every user's objects
+	if statusCode >= 200 && statusCode < 300 {
+		if records, own := d.massRecords(resp); records > 0 {
+			a.add(d.Weights.MassData, fmt.Sprintf("Returns %d records where the baseline has %d", records, own))
+		}
+	}
+
 	// Heuristic 3: PII detection
 	if d.CheckPII && d.containsPII(resp.Body()) {
-		return true
+		a.add(d.Weights.PII, "PII in response")
 	}
 
-	return false
+	return a
+}
+
+// minMassRecords is the fewest records a response must hold to count as a
+// mass data response
+const minMassRecords = 3
+
+// massRecords returns the records of resp and of the valid baseline when
+// resp holds at least twice as many, zeros otherwise
+func (d *IDORDetector) massRecords(resp *resty.Response) (int, int) {
+	records := analyzer.CountRecords(resp.Body())
+	if records < minMassRecords {
+		return 0, 0
+	}
+	own := 1
+	if d.ValidComparator != nil {
+		own = max(analyzer.CountRecords(d.ValidComparator.Baseline.Body()), 1)
+	}
+	if records < 2*own {
+		return 0, 0
+	}
+	return records, own
+}
+
+// redirectsElsewhere reports whether a chain leads somewhere the baselines
+// don't, e.g. into another user's resource
+func (d *IDORDetector) redirectsElsewhere(chain []analyzer.Redirect) bool {
+	target := analyzer.RedirectTarget(chain)
+	for _, c := range []*analyzer.ResponseComparator{d.ValidComparator, d.InvalidComparator} {
+		if c == nil {
+			continue
+		}
+		baseline := analyzer.RedirectChain(c.Baseline)
+		if len(baseline) > 0 && analyzer.RedirectTarget(baseline) == target {
+			return false
+		}
+	}
+	return true
+}
+
+// assessFile scores a file download: another file than the attacker's own
+// counts as different content, owner names or emails in its metadata as PII
+func (d *IDORDetector) assessFile(a *Assessment, resp *resty.Response, file *analyzer.FileInfo) {
+	statusCode := resp.StatusCode()
+	if d.ValidComparator != nil && statusCode >= 200 && statusCode < 300 {
+		own := analyzer.InspectFile(d.ValidComparator.Baseline.Body())
+		if own == nil || own.SHA256 != file.SHA256 {
+			a.add(d.Weights.Similarity, fmt.Sprintf("File download differs from the baseline (%s, %d bytes)", file.ContentType, file.Size))
+		}
+	}
+
+	if d.CheckPII && d.containsPII([]byte(file.Text())) {
+		a.add(d.Weights.PII, "PII in file metadata")
+	}
+}
+
+// ConfirmVictim adds the victim weight to an assessment, for a response the
+// victim's own session gets as well
+func (d *IDORDetector) ConfirmVictim(a *Assessment) {
+	a.add(d.Weights.Victim, "Victim session gets the same response")
+}
+
+// Vulnerable reports whether an assessment reaches MinConfidence
+func (d *IDORDetector) Vulnerable(a *Assessment) bool {
+	return a.Confidence > 0 && a.Confidence >= d.MinConfidence
+}
+
+// Detect checks if a response indicates an IDOR vulnerability
+func (d *IDORDetector) Detect(resp *resty.Response) bool {
+	return d.Vulnerable(d.Assess(resp))
 }
 
 // containsPII checks if response contains personally identifiable information
@@ -108,9 +251,13 @@ func (d *IDORDetector) containsPII(body []byte) bool {
 	return false
 }
 
-// GetPIIMatches returns all PII matches found in the response
+// GetPIIMatches returns all PII matches found in the response, or in the
+// metadata of a file
 func (d *IDORDetector) GetPIIMatches(body []byte) map[string][]string {
 	bodyStr := string(body)
+	if file := analyzer.InspectFile(body); file != nil {
+		bodyStr = file.Text()
+	}
 	matches := make(map[string][]string)
 
 	for name, pattern := range d.piiPatterns {
@@ -130,7 +277,12 @@ func (d *IDORDetector) DetectWithEvidence(resp *resty.Response) *DetectionResult
 		Reasons:      []string{},
 		PIIFound:     make(map[string][]string),
 		StatusCode:   resp.StatusCode(),
-		ContentLen:   len(resp.Body()),
+		ContentLen:   analyzer.BodySize(resp),
+	}
+
+	if d.InvalidProfile != nil && d.InvalidProfile.Matches(resp) {
+		result.Reasons = append(result.Reasons, "Matches the invalid baselines")
+		return result
 	}
 
 	// Check status code
@@ -1,27 +1,53 @@
 package detector
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
 
+	"idorplus/pkg/analyzer"
 	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/pterm/pterm"
 )
 
 // MassAssignmentTester tests for mass assignment vulnerabilities
 type MassAssignmentTester struct {
 	client *client.SmartClient
+
+	// Session names the session whose object is modified, empty for none
+	Session string
+	// VerifyURL is fetched with GET before and after each injection to see
+	// whether the value was stored, default the tested URL
+	VerifyURL string
 }
 
 // MassAssignmentResult represents test result
 type MassAssignmentResult struct {
 	URL              string
 	Method           string
+	BaselineStatus   int
 	TestedParams     []string
 	VulnerableParams []string
-	IsVulnerable     bool
-	Evidence         string
+	// Attempts are the accepted injections, with the request behind each
+	Attempts     []*MassAssignmentAttempt
+	IsVulnerable bool
+	Evidence     string
+}
+
+// MassAssignmentAttempt is one injected parameter
+type MassAssignmentAttempt struct {
+	Param      string
+	Value      interface{}
+	Body       string // the JSON body sent
+	StatusCode int
+	ContentLen int
+	// Verified is set when a GET of the object showed the injected value,
+	// rather than only the update response
+	Verified bool
 }
 
 // NewMassAssignmentTester creates a new tester
@@ -57,7 +83,7 @@ func (m *MassAssignmentTester) GetSensitiveParams() []string {
 }
 
 // TestEndpoint tests an endpoint for mass assignment
-func (m *MassAssignmentTester) TestEndpoint(url, method string, basePayload map[string]interface{}) *MassAssignmentResult {
+func (m *MassAssignmentTester) TestEndpoint(ctx context.Context, url, method string, basePayload map[string]interface{}) *MassAssignmentResult {
 	result := &MassAssignmentResult{
 		URL:    url,
 		Method: method,
@@ -67,11 +93,20 @@ func (m *MassAssignmentTester) TestEndpoint(url, method string, basePayload map[
 	result.TestedParams = sensitiveParams
 
 	// Get baseline response first
-	baselineResp := m.sendRequest(url, method, basePayload)
+	baselineResp := m.sendRequest(ctx, url, method, basePayload)
 	if baselineResp == nil {
 		return result
 	}
-	baselineBody := string(baselineResp.Body())
+	result.BaselineStatus = baselineResp.StatusCode()
+	baselineBody := baselineResp.Body()
+
+	// Snapshot the object with the base payload applied, nil when it
+	// can't be read back
+	verifyURL := m.VerifyURL
+	if verifyURL == "" {
+		verifyURL = url
+	}
+	snapshot := m.fetch(ctx, verifyURL)
 
 	// Test each sensitive parameter
 	for _, param := range sensitiveParams {
@@ -91,14 +126,33 @@ func (m *MassAssignmentTester) TestEndpoint(url, method string, basePayload map[
 			testPayload[param] = "injected_value"
 		}
 
-		resp := m.sendRequest(url, method, testPayload)
-		if resp == nil {
+		resp := m.sendRequest(ctx, url, method, testPayload)
+		if resp == nil || !resp.IsSuccess() {
 			continue
 		}
 
-		// Check if parameter was accepted
-		if m.wasParamAccepted(baselineBody, string(resp.Body()), param) {
+		// The object read back decides; without it the update response
+		var accepted, verified bool
+		if snapshot != nil {
+			if current := m.fetch(ctx, verifyURL); current != nil {
+				accepted, verified = persisted(snapshot, current, param, testPayload[param]), true
+			}
+		}
+		if !verified {
+			accepted = m.wasParamAccepted(baselineBody, resp.Body(), param, testPayload[param])
+		}
+
+		if accepted {
 			result.VulnerableParams = append(result.VulnerableParams, param)
+			body, _ := json.Marshal(testPayload)
+			result.Attempts = append(result.Attempts, &MassAssignmentAttempt{
+				Param:      param,
+				Value:      testPayload[param],
+				Body:       string(body),
+				StatusCode: resp.StatusCode(),
+				ContentLen: analyzer.BodySize(resp),
+				Verified:   verified,
+			})
 		}
 	}
 
@@ -110,51 +164,8 @@ func (m *MassAssignmentTester) TestEndpoint(url, method string, basePayload map[
 	return result
 }
 
-// TestParameterPollution tests for HTTP Parameter Pollution
-func (m *MassAssignmentTester) TestParameterPollution(url string, paramName string, values []string) []string {
-	var vulnerablePatterns []string
-
-	// Test duplicate parameter names
-	// ?id=1&id=2 - some backends take first, some take last, some take all
-	for i := 0; i < len(values)-1; i++ {
-		testURL := url + "?" + paramName + "=" + values[i] + "&" + paramName + "=" + values[i+1]
-		resp, err := m.client.Request().Get(testURL)
-		if err != nil {
-			continue
-		}
-
-		body := string(resp.Body())
-		// Check which value was used
-		if strings.Contains(body, values[i+1]) && !strings.Contains(body, values[i]) {
-			vulnerablePatterns = append(vulnerablePatterns, "LAST_PARAM_WINS: "+testURL)
-		} else if strings.Contains(body, values[i]) && strings.Contains(body, values[i+1]) {
-			vulnerablePatterns = append(vulnerablePatterns, "BOTH_PARAMS: "+testURL)
-		}
-	}
-
-	// Test array notation
-	arrayURLs := []string{
-		url + "?" + paramName + "[]=1&" + paramName + "[]=2",
-		url + "?" + paramName + "[0]=1&" + paramName + "[1]=2",
-		url + "?" + paramName + "=1," + paramName + "=2",
-	}
-
-	for _, testURL := range arrayURLs {
-		resp, err := m.client.Request().Get(testURL)
-		if err != nil {
-			continue
-		}
-
-		if resp.StatusCode() == 200 {
-			vulnerablePatterns = append(vulnerablePatterns, "ARRAY_NOTATION: "+testURL)
-		}
-	}
-
-	return vulnerablePatterns
-}
-
 // TestJSONInjection tests for JSON injection in parameters
-func (m *MassAssignmentTester) TestJSONInjection(url, method string, basePayload map[string]interface{}) []string {
+func (m *MassAssignmentTester) TestJSONInjection(ctx context.Context, url, method string, basePayload map[string]interface{}) []string {
 	var vulnerabilities []string
 
 	injectionPayloads := []struct {
@@ -181,7 +192,7 @@ func (m *MassAssignmentTester) TestJSONInjection(url, method string, basePayload
 			testPayload[k] = v
 		}
 
-		resp := m.sendRequest(url, method, testPayload)
+		resp := m.sendRequest(ctx, url, method, testPayload)
 		if resp != nil && resp.StatusCode() == 200 {
 			// Check if injection was processed
 			if strings.Contains(string(resp.Body()), "admin") {
@@ -193,46 +204,117 @@ func (m *MassAssignmentTester) TestJSONInjection(url, method string, basePayload
 	return vulnerabilities
 }
 
-func (m *MassAssignmentTester) sendRequest(url, method string, payload map[string]interface{}) *resty.Response {
+func (m *MassAssignmentTester) sendRequest(ctx context.Context, url, method string, payload map[string]interface{}) *resty.Response {
 	body, _ := json.Marshal(payload)
 
-	req := m.client.Request().
-		SetHeader("Content-Type", "application/json").
-		SetBody(body)
-
-	var resp *resty.Response
-	var err error
-
+	method = strings.ToUpper(method)
 	switch method {
-	case "POST":
-		resp, err = req.Post(url)
-	case "PUT":
-		resp, err = req.Put(url)
-	case "PATCH":
-		resp, err = req.Patch(url)
+	case "POST", "PUT", "PATCH":
 	default:
-		resp, err = req.Post(url)
+		method = "POST"
 	}
 
+	resp, err := sendRequest(ctx, m.client, method, url, m.Session, map[string]string{"Content-Type": "application/json"}, string(body))
 	if err != nil {
 		return nil
 	}
 	return resp
 }
 
-func (m *MassAssignmentTester) wasParamAccepted(baseline, response, param string) bool {
-	// If response differs significantly and status is still 200
-	// the parameter might have been accepted
-	if strings.Contains(response, param) && !strings.Contains(baseline, param) {
-		return true
+// PrintResult prints the accepted parameters as a table
+func (m *MassAssignmentTester) PrintResult(result *MassAssignmentResult) {
+	pterm.DefaultSection.Printf("Mass Assignment: %s %s (baseline %d)\n", result.Method, result.URL, result.BaselineStatus)
+
+	if !result.IsVulnerable {
+		utils.Info.Printf("None of %d sensitive parameters was accepted\n", len(result.TestedParams))
+		return
+	}
+
+	tableData := pterm.TableData{
+		{"Parameter", "Value", "Status", "Length", "Stored"},
 	}
+	for _, a := range result.Attempts {
+		stored := pterm.Yellow("response only")
+		if a.Verified {
+			stored = pterm.Red("yes")
+		}
+		tableData = append(tableData, []string{
+			a.Param,
+			fmt.Sprintf("%v", a.Value),
+			fmt.Sprintf("%d", a.StatusCode),
+			fmt.Sprintf("%d", a.ContentLen),
+			stored,
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
 
-	// Check if response contains our injected value
-	if strings.Contains(response, "admin") || strings.Contains(response, "999999") {
-		return true
+// wasParamAccepted reports whether the update response shows the injected
+// value where the baseline response didn't. Non-JSON responses only need
+// to mention the parameter.
+func (m *MassAssignmentTester) wasParamAccepted(baseline, response []byte, param string, value interface{}) bool {
+	var before, after interface{}
+	if json.Unmarshal(response, &after) != nil {
+		return strings.Contains(string(response), param) && !strings.Contains(string(baseline), param)
+	}
+	json.Unmarshal(baseline, &before)
+	return persisted(before, after, param, value)
+}
+
+// fetch GETs the object and returns its decoded JSON, nil when that fails.
+// It goes past the response cache, which would hand back the object as it
+// was before the injection.
+func (m *MassAssignmentTester) fetch(ctx context.Context, url string) interface{} {
+	resp, err := sendRequest(client.WithoutCache(ctx), m.client, "GET", url, m.Session, nil, "")
+	if err != nil || !resp.IsSuccess() {
+		return nil
+	}
+	var obj interface{}
+	if json.Unmarshal(resp.Body(), &obj) != nil {
+		return nil
 	}
+	return obj
+}
+
+// persisted reports whether param holds value in after but not in before
+func persisted(before, after interface{}, param string, value interface{}) bool {
+	got, ok := findField(after, param)
+	if !ok || !sameJSON(got, value) {
+		return false
+	}
+	old, ok := findField(before, param)
+	return !ok || !sameJSON(old, value)
+}
+
+// findField returns the first value of key in a decoded JSON document,
+// searching nested objects and arrays, e.g. {"data": {"role": ...}}
+func findField(doc interface{}, key string) (interface{}, bool) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if val, ok := v[key]; ok {
+			return val, true
+		}
+		for _, child := range v {
+			if val, ok := findField(child, key); ok {
+				return val, true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if val, ok := findField(child, key); ok {
+				return val, true
+			}
+		}
+	}
+	return nil, false
+}
 
-	return false
+// sameJSON compares values by their JSON encoding, so 999999 equals the
+// float64 it decodes to
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
 }
 
 func copyMap(m map[string]interface{}) map[string]interface{} {
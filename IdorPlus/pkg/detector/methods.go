@@ -0,0 +1,115 @@
+package detector
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+
+	"idorplus/pkg/client"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// DangerousMethods change or delete data; an endpoint that allows them
+// deserves a scan with each, not only with GET
+var DangerousMethods = []string{"PUT", "PATCH", "DELETE"}
+
+// MethodEnumerator asks endpoints which methods they support with an
+// OPTIONS request, read from its Allow and CORS headers
+type MethodEnumerator struct {
+	client *client.SmartClient
+}
+
+// MethodSupport is what an OPTIONS request to one endpoint returned
+type MethodSupport struct {
+	URL        string
+	StatusCode int
+	// Allow is the Allow header, CORS the Access-Control-Allow-Methods of
+	// the preflight
+	Allow []string
+	CORS  []string
+}
+
+// NewMethodEnumerator creates a new method enumerator
+func NewMethodEnumerator(c *client.SmartClient) *MethodEnumerator {
+	return &MethodEnumerator{client: c}
+}
+
+// Enumerate sends OPTIONS to url as a same-origin CORS preflight, so both
+// a plain Allow header and the CORS allowed methods are answered. session
+// may be empty.
+func (m *MethodEnumerator) Enumerate(ctx context.Context, rawURL, session string) (*MethodSupport, error) {
+	resp, err := sendRequest(ctx, m.client, "OPTIONS", rawURL, session, PreflightHeaders(rawURL), "")
+	if err != nil {
+		return nil, err
+	}
+	return ReadMethods(rawURL, resp), nil
+}
+
+// PreflightHeaders are the headers of a same-origin CORS preflight of a
+// GET of url
+func PreflightHeaders(rawURL string) map[string]string {
+	headers := map[string]string{"Access-Control-Request-Method": "GET"}
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		headers["Origin"] = u.Scheme + "://" + u.Host
+	}
+	return headers
+}
+
+// ReadMethods reads the methods an OPTIONS response to url allows
+func ReadMethods(rawURL string, resp *resty.Response) *MethodSupport {
+	return &MethodSupport{
+		URL:        rawURL,
+		StatusCode: resp.StatusCode(),
+		Allow:      ParseMethods(resp.Header().Values("Allow")...),
+		CORS:       ParseMethods(resp.Header().Values("Access-Control-Allow-Methods")...),
+	}
+}
+
+// ParseMethods returns the methods of Allow style headers, upper case and
+// without duplicates. The CORS wildcard * names no method.
+func ParseMethods(headers ...string) []string {
+	var methods []string
+	for _, h := range headers {
+		for _, m := range strings.Split(h, ",") {
+			m = strings.ToUpper(strings.TrimSpace(m))
+			if m != "" && m != "*" && !slices.Contains(methods, m) {
+				methods = append(methods, m)
+			}
+		}
+	}
+	return methods
+}
+
+// Methods returns the methods allowed by either header, sorted
+func (s *MethodSupport) Methods() []string {
+	methods := ParseMethods(strings.Join(append(append([]string{}, s.Allow...), s.CORS...), ","))
+	slices.Sort(methods)
+	return methods
+}
+
+// Known reports whether the endpoint listed any method
+func (s *MethodSupport) Known() bool {
+	return len(s.Allow)+len(s.CORS) > 0
+}
+
+// Supports reports whether method is allowed. Without a list every method
+// may be, and HEAD goes with GET.
+func (s *MethodSupport) Supports(method string) bool {
+	method = strings.ToUpper(method)
+	methods := s.Methods()
+	return !s.Known() || slices.Contains(methods, method) || method == "HEAD" && slices.Contains(methods, "GET")
+}
+
+// Untested returns the dangerous methods the endpoint allows besides the
+// tested ones
+func (s *MethodSupport) Untested(tested ...string) []string {
+	var untested []string
+	for _, m := range s.Methods() {
+		if slices.Contains(DangerousMethods, m) && !slices.ContainsFunc(tested, func(t string) bool { return strings.EqualFold(t, m) }) {
+			untested = append(untested, m)
+		}
+	}
+	return untested
+}
@@ -0,0 +1,372 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
+
+	"github.com/pterm/pterm"
+)
+
+// PaginationTester abuses the paging and filtering of a collection the
+// attacker may list: huge page sizes, offsets and pages past the
+// attacker's own records, and owner filters left out. Listings often
+// scope records by a parameter the client is trusted to send.
+type PaginationTester struct {
+	client *client.SmartClient
+	// Session lists the collection
+	Session string
+	// PageSizes are the page sizes tried on limit-like parameters
+	PageSizes []int
+}
+
+// PaginationAttempt is a single manipulated listing
+type PaginationAttempt struct {
+	Technique  string
+	Param      string
+	URL        string
+	StatusCode int
+	ContentLen int
+	Records    int
+	// Foreign is the number of records owned by someone else, New the
+	// number of records the baseline lacks
+	Foreign int
+	New     int
+	// Response is the response body, for PII checks
+	Response   []byte
+	Vulnerable bool
+	Reason     string
+}
+
+// PaginationResult aggregates the manipulated listings of a collection
+type PaginationResult struct {
+	Method  string
+	URL     string
+	OwnID   string
+	Headers map[string]string
+	Body    string
+	// BaselineStatus and BaselineRecords describe the unmodified listing.
+	// OwnerField is the record field naming the owner, if one was found.
+	BaselineStatus  int
+	BaselineRecords int
+	OwnerField      string
+	Attempts        []*PaginationAttempt
+	IsVulnerable    bool
+}
+
+// NewPaginationTester creates a new pagination tester
+func NewPaginationTester(c *client.SmartClient) *PaginationTester {
+	return &PaginationTester{client: c, PageSizes: []int{1000, 100000}}
+}
+
+var (
+	// pageSizeParams set how many records a page holds
+	pageSizeParams = []string{"limit", "per_page", "perpage", "page_size", "pagesize", "size", "count", "max", "take", "top", "$top", "first", "max_results", "maxresults"}
+	// offsetParams set the first record of a page
+	offsetParams = []string{"offset", "skip", "$skip", "start", "from"}
+	// pageParams set the page number
+	pageParams = []string{"page", "page_number", "pagenumber", "pageno"}
+	// ownerParams and ownerFields name the owner of records, compared
+	// lowercased without '_' and '-'
+	ownerParams = []string{"userid", "user", "uid", "owner", "ownerid", "accountid", "account", "customerid", "memberid", "tenantid", "orgid", "organizationid", "createdby", "authorid", "profileid", "clientid"}
+)
+
+// normalizeParam lowercases a parameter or field name and drops '_' and '-'
+func normalizeParam(name string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+}
+
+// queryPair is a raw name=value pair of a query string
+type queryPair struct {
+	name, value string
+}
+
+// splitQuery returns the path and the raw query pairs of a URL
+func splitQuery(u string) (string, []queryPair) {
+	path, query, _ := strings.Cut(u, "?")
+	var pairs []queryPair
+	for _, part := range strings.Split(query, "&") {
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		pairs = append(pairs, queryPair{name, value})
+	}
+	return path, pairs
+}
+
+// joinQuery rebuilds a URL from its path and query pairs
+func joinQuery(path string, pairs []queryPair) string {
+	if len(pairs) == 0 {
+		return path
+	}
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p.name + "=" + p.value
+	}
+	return path + "?" + strings.Join(parts, "&")
+}
+
+// withParam returns u with the query parameter at index i set to value, or
+// value appended as name when i is negative
+func withParam(u string, i int, name, value string) string {
+	path, pairs := splitQuery(u)
+	if i < 0 {
+		return joinQuery(path, append(pairs, queryPair{name, value}))
+	}
+	pairs = slices.Clone(pairs)
+	pairs[i].value = value
+	return joinQuery(path, pairs)
+}
+
+// withoutParam returns u without the query parameter at index i
+func withoutParam(u string, i int) string {
+	path, pairs := splitQuery(u)
+	return joinQuery(path, slices.Delete(slices.Clone(pairs), i, i+1))
+}
+
+// Test lists the collection at url unmodified, then with every paging and
+// filter manipulation. A listing is vulnerable when it holds records whose
+// owner field names someone other than the owners in the baseline, or when
+// any records appear although the baseline is empty. Records a removed
+// filter adds are only reported with an owner field showing they are
+// someone else's, the filter may not be an owner's at all.
+func (p *PaginationTester) Test(ctx context.Context, method, url string, headers map[string]string, body, ownID string) *PaginationResult {
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = "GET"
+	}
+	result := &PaginationResult{Method: method, URL: url, OwnID: ownID, Headers: headers, Body: body}
+
+	base, err := sendRequest(ctx, p.client, method, url, p.Session, headers, body)
+	if err != nil {
+		return result
+	}
+	result.BaselineStatus = base.StatusCode()
+	baseRecords, ok := analyzer.Records(base.Body())
+	if !base.IsSuccess() || !ok {
+		return result
+	}
+	result.BaselineRecords = len(baseRecords)
+
+	_, pairs := splitQuery(url)
+	var filters []string
+	// Only filters named like owners name the owner field, a status or
+	// category may equal the attacker's ID too
+	for _, pair := range pairs {
+		if isOwnerParam(pair, "") {
+			filters = append(filters, normalizeParam(pair.name))
+		}
+	}
+	result.OwnerField = ownerField(baseRecords, filters)
+
+	seen := make(map[string]bool, len(baseRecords))
+	owners := make(map[string]bool)
+	if ownID != "" {
+		owners[ownID] = true
+	}
+	for _, r := range baseRecords {
+		seen[recordKey(r)] = true
+		if v, ok := fieldValue(r, result.OwnerField); ok {
+			owners[v] = true
+		}
+	}
+
+	for _, a := range p.buildAttempts(url, pairs, len(baseRecords), ownID) {
+		resp, err := sendRequest(ctx, p.client, method, a.URL, p.Session, headers, body)
+		if err != nil {
+			continue
+		}
+		a.StatusCode = resp.StatusCode()
+		a.ContentLen = analyzer.BodySize(resp)
+		a.Response = resp.Body()
+		if records, ok := analyzer.Records(resp.Body()); ok && resp.IsSuccess() {
+			a.Records = len(records)
+			var others []string
+			for _, r := range records {
+				if !seen[recordKey(r)] {
+					a.New++
+				}
+				if v, ok := fieldValue(r, result.OwnerField); ok && !owners[v] {
+					a.Foreign++
+					if len(others) < 5 && !slices.Contains(others, v) {
+						others = append(others, v)
+					}
+				}
+			}
+			switch {
+			case a.Foreign > 0:
+				a.Vulnerable = true
+				a.Reason = fmt.Sprintf("%d records of other owners (%s: %s)", a.Foreign, result.OwnerField, strings.Join(others, ", "))
+			case a.New > 0 && len(baseRecords) == 0:
+				a.Vulnerable = true
+				a.Reason = fmt.Sprintf("%d records although the attacker's listing is empty", a.New)
+			case a.New > 0:
+				a.Reason = fmt.Sprintf("%d records the baseline lacks, owner unknown", a.New)
+			}
+		}
+		if a.Vulnerable {
+			result.IsVulnerable = true
+		}
+		result.Attempts = append(result.Attempts, a)
+	}
+	return result
+}
+
+// buildAttempts returns the manipulated listing URLs. Paging parameters of
+// the URL are changed; without any, the common ones are added.
+func (p *PaginationTester) buildAttempts(url string, pairs []queryPair, own int, ownID string) []*PaginationAttempt {
+	var attempts []*PaginationAttempt
+	add := func(technique, param, u string) {
+		attempts = append(attempts, &PaginationAttempt{Technique: technique, Param: param, URL: u})
+	}
+	index := func(names []string) []int {
+		var found []int
+		for i, pair := range pairs {
+			if slices.Contains(names, normalizeParam(pair.name)) || slices.Contains(names, strings.ToLower(pair.name)) {
+				found = append(found, i)
+			}
+		}
+		return found
+	}
+
+	if sizes := index(pageSizeParams); len(sizes) > 0 {
+		for _, i := range sizes {
+			for _, n := range p.PageSizes {
+				add("large page size", pairs[i].name, withParam(url, i, "", strconv.Itoa(n)))
+			}
+		}
+	} else if len(p.PageSizes) > 0 {
+		n := strconv.Itoa(p.PageSizes[0])
+		for _, name := range []string{"limit", "per_page", "page_size", "size"} {
+			add("added page size", name, withParam(url, -1, name, n))
+		}
+	}
+
+	// Past the attacker's records, and far past them
+	offsets := []string{strconv.Itoa(own), "10000"}
+	if found := index(offsetParams); len(found) > 0 {
+		for _, i := range found {
+			for _, o := range offsets {
+				add("offset past own data", pairs[i].name, withParam(url, i, "", o))
+			}
+		}
+	} else {
+		for _, o := range offsets {
+			add("added offset", "offset", withParam(url, -1, "offset", o))
+		}
+	}
+	if found := index(pageParams); len(found) > 0 {
+		for _, i := range found {
+			for _, page := range []string{"2", "1000"} {
+				add("page past own data", pairs[i].name, withParam(url, i, "", page))
+			}
+		}
+	} else {
+		add("added page", "page", withParam(url, -1, "page", "2"))
+	}
+
+	for i, pair := range pairs {
+		if !isOwnerParam(pair, ownID) {
+			continue
+		}
+		add("filter removed", pair.name, withoutParam(url, i))
+		add("filter emptied", pair.name, withParam(url, i, "", ""))
+	}
+	return attempts
+}
+
+// isOwnerParam reports whether a query parameter scopes a listing to its
+// owner: by its name, or because its value is the attacker's own ID
+func isOwnerParam(pair queryPair, ownID string) bool {
+	return slices.Contains(ownerParams, normalizeParam(pair.name)) || (ownID != "" && pair.value == ownID)
+}
+
+// ownerField returns the field of the records naming their owner: a field
+// named like a filter parameter of the URL, or like an owner
+func ownerField(records []interface{}, filters []string) string {
+	for _, names := range [][]string{filters, ownerParams} {
+		for _, r := range records {
+			obj, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			keys := make([]string, 0, len(obj))
+			for k := range obj {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				if _, ok := fieldValue(r, k); ok && slices.Contains(names, normalizeParam(k)) {
+					return k
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// fieldValue returns a scalar field of a record as a string
+func fieldValue(record interface{}, field string) (string, bool) {
+	obj, ok := record.(map[string]interface{})
+	if !ok || field == "" {
+		return "", false
+	}
+	switch v := obj[field].(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// recordKey identifies a record by its JSON, object keys are sorted
+func recordKey(record interface{}) string {
+	b, _ := json.Marshal(record)
+	return string(b)
+}
+
+// PrintResult prints the manipulated listings as a table
+func (p *PaginationTester) PrintResult(result *PaginationResult) {
+	pterm.DefaultSection.Printf("Pagination: %s %s (baseline %d, %d records)\n",
+		result.Method, result.URL, result.BaselineStatus, result.BaselineRecords)
+
+	if len(result.Attempts) == 0 {
+		utils.Info.Println("Not a JSON collection the attacker may list, nothing to page through")
+		return
+	}
+	if result.OwnerField != "" {
+		utils.Info.Printf("Records are owned by their %q field\n", result.OwnerField)
+	}
+
+	tableData := pterm.TableData{
+		{"Parameter", "Technique", "Status", "Records", "Result"},
+	}
+	for _, a := range result.Attempts {
+		status := pterm.Red("NO")
+		if a.Vulnerable {
+			status = pterm.Green("LEAK")
+		} else if a.New > 0 {
+			status = pterm.Yellow("MORE")
+		}
+		tableData = append(tableData, []string{
+			a.Param,
+			a.Technique,
+			fmt.Sprintf("%d", a.StatusCode),
+			fmt.Sprintf("%d", a.Records),
+			status,
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
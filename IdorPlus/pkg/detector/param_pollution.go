@@ -0,0 +1,316 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pterm/pterm"
+)
+
+// ParamPollutionTester sends the attacker's own ID and a victim's ID in the
+// same request: duplicated, as an array, or split between the query and the
+// body. The access check and the lookup often read different copies.
+type ParamPollutionTester struct {
+	client *client.SmartClient
+	// Session sends the polluted requests. VictimSession, if set, fetches
+	// the victim's object to recognize it in the responses.
+	Session       string
+	VictimSession string
+	// Threshold is the similarity (0-1) above which two responses show the
+	// same object
+	Threshold float64
+}
+
+// PollutionParam is a parameter carrying the ID
+type PollutionParam struct {
+	Name string
+	In   string // query, json or form
+}
+
+// PollutionTemplate is the request to pollute, {ID} marks the ID
+type PollutionTemplate struct {
+	Method  string
+	URL     string
+	Body    string
+	Headers map[string]string
+}
+
+// PollutionAttempt is a single polluted request
+type PollutionAttempt struct {
+	Technique  string
+	Param      string
+	Method     string
+	URL        string
+	Headers    map[string]string
+	Body       string
+	StatusCode int
+	ContentLen int
+	// Response is the response body, for PII checks
+	Response   []byte
+	Vulnerable bool
+	Reason     string
+}
+
+// PollutionResult aggregates the polluted requests for one victim ID
+type PollutionResult struct {
+	Method   string
+	Endpoint string
+	OwnID    string
+	VictimID string
+	// DirectStatus is the status of the attacker requesting VictimID
+	// directly, VictimStatus of the victim requesting it (0 without a
+	// victim session)
+	DirectStatus int
+	VictimStatus int
+	Attempts     []*PollutionAttempt
+	IsVulnerable bool
+}
+
+// NewParamPollutionTester creates a new parameter pollution tester
+func NewParamPollutionTester(c *client.SmartClient) *ParamPollutionTester {
+	return &ParamPollutionTester{client: c, Threshold: 0.8}
+}
+
+var (
+	// queryParam matches name={ID} in a query string or form body
+	queryParam = regexp.MustCompile(`(?:^|[?&])([^?&=]+)=\{ID\}(?:&|$)`)
+	// jsonParam matches "name": {ID} or "name": "{ID}" in a JSON body
+	jsonParam = regexp.MustCompile(`"([^"]+)"\s*:\s*("?)\{ID\}"?`)
+)
+
+// FindPollutionParams returns the query and body parameters whose value
+// is the ID
+func FindPollutionParams(url, body string) []PollutionParam {
+	var params []PollutionParam
+	if _, query, ok := strings.Cut(url, "?"); ok {
+		for _, m := range queryParam.FindAllStringSubmatch(query, -1) {
+			params = append(params, PollutionParam{Name: m[1], In: "query"})
+		}
+	}
+	for _, m := range jsonParam.FindAllStringSubmatch(body, -1) {
+		params = append(params, PollutionParam{Name: m[1], In: "json"})
+	}
+	if !strings.HasPrefix(strings.TrimSpace(body), "{") {
+		for _, m := range queryParam.FindAllStringSubmatch(body, -1) {
+			params = append(params, PollutionParam{Name: m[1], In: "form"})
+		}
+	}
+	return params
+}
+
+// Test requests ownID and victimID directly, then sends the polluted
+// variants of every ID parameter. A variant is vulnerable when it returns
+// the victim's object, or without a victim session, something other than
+// the attacker's own object, while the direct request doesn't.
+func (p *ParamPollutionTester) Test(ctx context.Context, tmpl PollutionTemplate, ownID, victimID string) *PollutionResult {
+	method := strings.ToUpper(tmpl.Method)
+	if method == "" {
+		method = "GET"
+	}
+	tmpl.Method = method
+	result := &PollutionResult{Method: method, Endpoint: tmpl.URL, OwnID: ownID, VictimID: victimID}
+
+	params := FindPollutionParams(tmpl.URL, tmpl.Body)
+	if len(params) == 0 || ownID == victimID {
+		return result
+	}
+
+	own, err := p.send(ctx, tmpl, p.Session, ownID)
+	if err != nil || !own.IsSuccess() {
+		return result
+	}
+	var victim *resty.Response
+	if p.VictimSession != "" {
+		if victim, err = p.send(ctx, tmpl, p.VictimSession, victimID); err == nil {
+			result.VictimStatus = victim.StatusCode()
+			if !victim.IsSuccess() {
+				victim = nil
+			}
+		}
+	}
+	direct, err := p.send(ctx, tmpl, p.Session, victimID)
+	if err != nil {
+		return result
+	}
+	result.DirectStatus = direct.StatusCode()
+	// Plain IDOR, the fuzzer reports it
+	if ok, _ := p.leaks(direct, own, victim); ok {
+		return result
+	}
+
+	for _, param := range params {
+		for _, a := range p.buildAttempts(tmpl, param, ownID, victimID) {
+			a.Method = method
+			resp, err := sendRequest(ctx, p.client, a.Method, a.URL, p.Session, a.Headers, a.Body)
+			if err != nil {
+				continue
+			}
+			a.StatusCode = resp.StatusCode()
+			a.ContentLen = analyzer.BodySize(resp)
+			a.Response = resp.Body()
+			a.Vulnerable, a.Reason = p.leaks(resp, own, victim)
+			if a.Vulnerable {
+				result.IsVulnerable = true
+			}
+			result.Attempts = append(result.Attempts, a)
+		}
+	}
+	return result
+}
+
+// leaks reports whether resp shows another object than the attacker's own
+func (p *ParamPollutionTester) leaks(resp, own, victim *resty.Response) (bool, string) {
+	if !resp.IsSuccess() {
+		return false, ""
+	}
+	simOwn := analyzer.CalculateSimilarity(own.String(), resp.String())
+	if victim != nil {
+		simVictim := analyzer.CalculateSimilarity(victim.String(), resp.String())
+		if simVictim >= p.Threshold && simVictim > simOwn {
+			return true, fmt.Sprintf("Returns the victim's object (similarity %.2f, %.2f to the attacker's)", simVictim, simOwn)
+		}
+		return false, ""
+	}
+	if simOwn < p.Threshold {
+		return true, fmt.Sprintf("Differs from the attacker's own object (similarity %.2f)", simOwn)
+	}
+	return false, ""
+}
+
+// buildAttempts returns the polluted variants of one parameter. Other
+// occurrences of {ID} get the victim's ID.
+func (p *ParamPollutionTester) buildAttempts(tmpl PollutionTemplate, param PollutionParam, ownID, victimID string) []*PollutionAttempt {
+	name := param.Name
+	fill := func(s string) string { return strings.ReplaceAll(s, "{ID}", victimID) }
+	headers := make(map[string]string, len(tmpl.Headers))
+	for k, v := range tmpl.Headers {
+		headers[k] = fill(v)
+	}
+	attempt := func(technique, url, body string, extra map[string]string) *PollutionAttempt {
+		h := make(map[string]string, len(headers)+len(extra))
+		for k, v := range headers {
+			h[k] = v
+		}
+		for k, v := range extra {
+			h[k] = v
+		}
+		return &PollutionAttempt{Technique: technique, Param: name, URL: fill(url), Headers: h, Body: fill(body)}
+	}
+
+	var attempts []*PollutionAttempt
+	switch param.In {
+	case "query", "form":
+		placeholder := name + "={ID}"
+		variants := []struct{ technique, value string }{
+			{"duplicate, own first", name + "=" + ownID + "&" + name + "=" + victimID},
+			{"duplicate, victim first", name + "=" + victimID + "&" + name + "=" + ownID},
+			{"array", name + "[]=" + ownID + "&" + name + "[]=" + victimID},
+			{"indexed array", name + "[0]=" + ownID + "&" + name + "[1]=" + victimID},
+			{"comma list", name + "=" + ownID + "," + victimID},
+		}
+		for _, v := range variants {
+			if param.In == "query" {
+				attempts = append(attempts, attempt(v.technique, replaceParam(tmpl.URL, placeholder, v.value), tmpl.Body, nil))
+			} else {
+				attempts = append(attempts, attempt(v.technique, tmpl.URL, replaceParam(tmpl.Body, placeholder, v.value), nil))
+			}
+		}
+		if param.In == "form" {
+			body := replaceParam(tmpl.Body, placeholder, name+"="+ownID)
+			attempts = append(attempts, attempt("form vs query", appendQueryParam(tmpl.URL, name, victimID), body, nil))
+		} else if tmpl.Body == "" && tmpl.Method != "GET" && tmpl.Method != "HEAD" {
+			// resty drops GET bodies, and a body changes the request anyway
+			url := replaceParam(tmpl.URL, placeholder, name+"="+ownID)
+			body := fmt.Sprintf("{%q:%q}", name, victimID)
+			attempts = append(attempts, attempt("JSON vs query", url, body, map[string]string{"Content-Type": "application/json"}))
+		}
+	case "json":
+		var m []string
+		for _, match := range jsonParam.FindAllStringSubmatch(tmpl.Body, -1) {
+			if match[1] == name {
+				m = match
+				break
+			}
+		}
+		if m == nil {
+			return nil
+		}
+		q := m[2]
+		key := fmt.Sprintf("%q:", name)
+		own, victim := q+ownID+q, q+victimID+q
+		variants := []struct{ technique, value string }{
+			{"duplicate key, own first", key + own + "," + key + victim},
+			{"duplicate key, victim first", key + victim + "," + key + own},
+			{"array", key + "[" + own + "," + victim + "]"},
+		}
+		for _, v := range variants {
+			attempts = append(attempts, attempt(v.technique, tmpl.URL, strings.Replace(tmpl.Body, m[0], v.value, 1), nil))
+		}
+		body := strings.Replace(tmpl.Body, m[0], key+own, 1)
+		attempts = append(attempts, attempt("JSON vs query", appendQueryParam(tmpl.URL, name, victimID), body, nil))
+	}
+	return attempts
+}
+
+// send requests the template for id
+func (p *ParamPollutionTester) send(ctx context.Context, tmpl PollutionTemplate, session, id string) (*resty.Response, error) {
+	headers := make(map[string]string, len(tmpl.Headers))
+	for k, v := range tmpl.Headers {
+		headers[k] = strings.ReplaceAll(v, "{ID}", id)
+	}
+	url := strings.ReplaceAll(tmpl.URL, "{ID}", id)
+	return sendRequest(ctx, p.client, tmpl.Method, url, session, headers, strings.ReplaceAll(tmpl.Body, "{ID}", id))
+}
+
+// replaceParam replaces the name={ID} pair of a query string or form body
+func replaceParam(s, placeholder, value string) string {
+	for i := 0; ; {
+		j := strings.Index(s[i:], placeholder)
+		if j < 0 {
+			return s
+		}
+		j += i
+		end := j + len(placeholder)
+		if (j == 0 || s[j-1] == '?' || s[j-1] == '&') && (end == len(s) || s[end] == '&') {
+			return s[:j] + value + s[end:]
+		}
+		i = end
+	}
+}
+
+// PrintResult prints the polluted requests as a table
+func (p *ParamPollutionTester) PrintResult(result *PollutionResult) {
+	pterm.DefaultSection.Printf("Parameter Pollution: %s %s, own ID %s, victim ID %s (direct %d)\n",
+		result.Method, result.Endpoint, result.OwnID, result.VictimID, result.DirectStatus)
+
+	if len(result.Attempts) == 0 {
+		utils.Info.Println("No ID parameter to pollute, or the victim's object is reachable directly")
+		return
+	}
+
+	tableData := pterm.TableData{
+		{"Parameter", "Technique", "Status", "Length", "Result"},
+	}
+	for _, a := range result.Attempts {
+		status := pterm.Red("NO")
+		if a.Vulnerable {
+			status = pterm.Green("LEAK")
+		}
+		tableData = append(tableData, []string{
+			a.Param,
+			a.Technique,
+			fmt.Sprintf("%d", a.StatusCode),
+			fmt.Sprintf("%d", a.ContentLen),
+			status,
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
@@ -0,0 +1,56 @@
+package detector
+
+import (
+	"context"
+	"strings"
+
+	"idorplus/pkg/client"
+)
+
+// PathBypassTester retries denied requests with path normalisation tricks to
+// find access rules that match the raw path while the router matches a normalised one
+type PathBypassTester struct {
+	client *client.SmartClient
+}
+
+// NewPathBypassTester creates a new path bypass tester
+func NewPathBypassTester(c *client.SmartClient) *PathBypassTester {
+	return &PathBypassTester{client: c}
+}
+
+// TestEndpoint sends the original request and, if it is denied, every path
+// mutation of the URL. session may be empty for unauthenticated tests.
+func (p *PathBypassTester) TestEndpoint(ctx context.Context, url, method, session string) *TamperResult {
+	method = strings.ToUpper(method)
+	result := &TamperResult{
+		URL:    url,
+		Method: method,
+	}
+
+	baseline, err := sendRequest(ctx, p.client, method, url, session, nil, "")
+	if err != nil {
+		return result
+	}
+	result.BaselineStatus = baseline.StatusCode()
+
+	if !IsDenied(result.BaselineStatus) {
+		return result
+	}
+
+	var attempts []*TamperAttempt
+	for _, m := range client.GeneratePathMutations(url) {
+		attempts = append(attempts, &TamperAttempt{
+			Technique: m.Technique,
+			Method:    method,
+			URL:       m.URL,
+		})
+	}
+
+	runAttempts(ctx, p.client, result, attempts, session)
+	return result
+}
+
+// PrintResult prints the path mutation attempts as a table
+func (p *PathBypassTester) PrintResult(result *TamperResult) {
+	printTamperResult("Path Bypass", result)
+}
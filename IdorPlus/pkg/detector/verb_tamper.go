@@ -0,0 +1,256 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pterm/pterm"
+)
+
+// VerbTamperTester retries denied requests with method override headers and
+// alternate verbs to find access controls enforced only for specific methods
+type VerbTamperTester struct {
+	client *client.SmartClient
+}
+
+// TamperAttempt is a single tampered request
+type TamperAttempt struct {
+	Technique  string
+	Method     string
+	URL        string
+	Headers    map[string]string
+	Body       string
+	StatusCode int
+	ContentLen int
+	Bypassed   bool
+
+	// tunnel is the verb an override rides on, empty for other attempts
+	tunnel string
+	body   []byte
+}
+
+// TamperResult aggregates all tamper attempts for one endpoint
+type TamperResult struct {
+	URL            string
+	Method         string
+	BaselineStatus int
+	Attempts       []*TamperAttempt
+	IsVulnerable   bool
+}
+
+// NewVerbTamperTester creates a new verb tamper tester
+func NewVerbTamperTester(c *client.SmartClient) *VerbTamperTester {
+	return &VerbTamperTester{client: c}
+}
+
+// overrideHeaders are honoured by many frameworks to tunnel a verb through POST
+var overrideHeaders = []string{
+	"X-HTTP-Method-Override",
+	"X-Method-Override",
+	"X-HTTP-Method",
+}
+
+// TestEndpoint sends the original request and, if it is denied, a set of verb
+// tampering variants. session may be empty for unauthenticated tests.
+func (v *VerbTamperTester) TestEndpoint(ctx context.Context, url, method, session string) *TamperResult {
+	method = strings.ToUpper(method)
+	result := &TamperResult{
+		URL:    url,
+		Method: method,
+	}
+
+	baseline, err := v.send(ctx, method, url, session, nil, "")
+	if err != nil {
+		return result
+	}
+	result.BaselineStatus = baseline.StatusCode()
+
+	// Nothing to bypass if the original request already succeeds
+	if !IsDenied(result.BaselineStatus) {
+		return result
+	}
+
+	runAttempts(ctx, v.client, result, v.buildAttempts(url, method), session)
+
+	// A 2xx serving the denial page, or an endpoint answering the tunnel
+	// verb the same with or without the override, bypassed nothing
+	result.IsVulnerable = false
+	for _, attempt := range result.Attempts {
+		if attempt.Bypassed && (bytes.Equal(attempt.body, baseline.Body()) || v.overrideIgnored(ctx, attempt, url, session)) {
+			attempt.Bypassed = false
+		}
+		result.IsVulnerable = result.IsVulnerable || attempt.Bypassed
+	}
+	return result
+}
+
+// overrideIgnored reports whether the endpoint answers the attempt's tunnel
+// verb alike without the override: same status, a body within 10% of its
+// length
+func (v *VerbTamperTester) overrideIgnored(ctx context.Context, attempt *TamperAttempt, url, session string) bool {
+	if attempt.tunnel == "" {
+		return false
+	}
+	resp, err := v.send(ctx, attempt.tunnel, url, session, nil, "")
+	if err != nil || resp.StatusCode() != attempt.StatusCode {
+		return false
+	}
+	diff := math.Abs(float64(analyzer.BodySize(resp) - attempt.ContentLen))
+	return diff <= 0.1*float64(attempt.ContentLen)
+}
+
+// buildAttempts returns the tamper variants for a method
+func (v *VerbTamperTester) buildAttempts(url, method string) []*TamperAttempt {
+	var attempts []*TamperAttempt
+
+	// Tunnel the original verb through POST with override headers
+	for _, h := range overrideHeaders {
+		attempts = append(attempts, &TamperAttempt{
+			Technique: h + ": " + method,
+			Method:    "POST",
+			URL:       url,
+			Headers:   map[string]string{h: method},
+			tunnel:    "POST",
+		})
+	}
+
+	// Rails/Laravel/Symfony style _method parameter
+	attempts = append(attempts, &TamperAttempt{
+		Technique: "_method=" + method + " (query)",
+		Method:    "POST",
+		URL:       appendQueryParam(url, "_method", method),
+		tunnel:    "POST",
+	}, &TamperAttempt{
+		Technique: "_method=" + method + " (body)",
+		Method:    "POST",
+		URL:       url,
+		Headers:   map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		Body:      "_method=" + method,
+		tunnel:    "POST",
+	})
+
+	// Alternate verbs
+	switch method {
+	case "GET":
+		// A plain POST answering says nothing of the GET, the tunnels above
+		// cover it
+		attempts = append(attempts,
+			&TamperAttempt{Technique: "HEAD instead of GET", Method: "HEAD", URL: url},
+		)
+	default:
+		attempts = append(attempts,
+			&TamperAttempt{Technique: "GET with override to " + method, Method: "GET", URL: url,
+				Headers: map[string]string{"X-HTTP-Method-Override": method}, tunnel: "GET"},
+		)
+	}
+
+	// Verb case and unknown verbs, which some servers route like GET
+	attempts = append(attempts,
+		&TamperAttempt{Technique: "lowercase verb", Method: strings.ToLower(method), URL: url},
+		&TamperAttempt{Technique: "arbitrary verb", Method: "IDORPLUS", URL: url},
+	)
+
+	return attempts
+}
+
+// send executes a request with an arbitrary method and optional session
+func (v *VerbTamperTester) send(ctx context.Context, method, url, session string, headers map[string]string, body string) (*resty.Response, error) {
+	return sendRequest(ctx, v.client, method, url, session, headers, body)
+}
+
+// PrintResult prints the tamper attempts as a table
+func (v *VerbTamperTester) PrintResult(result *TamperResult) {
+	printTamperResult("Verb Tampering", result)
+}
+
+// runAttempts sends each attempt and marks the ones that got a 2xx response
+func runAttempts(ctx context.Context, c *client.SmartClient, result *TamperResult, attempts []*TamperAttempt, session string) {
+	for _, attempt := range attempts {
+		resp, err := sendRequest(ctx, c, attempt.Method, attempt.URL, session, attempt.Headers, attempt.Body)
+		if err != nil {
+			continue
+		}
+
+		attempt.StatusCode = resp.StatusCode()
+		attempt.ContentLen = analyzer.BodySize(resp)
+		attempt.body = resp.Body()
+		attempt.Bypassed = attempt.StatusCode >= 200 && attempt.StatusCode < 300
+		if attempt.Bypassed {
+			result.IsVulnerable = true
+		}
+		result.Attempts = append(result.Attempts, attempt)
+	}
+}
+
+// sendRequest executes a request with an arbitrary method and optional session
+func sendRequest(ctx context.Context, c *client.SmartClient, method, url, session string, headers map[string]string, body string) (*resty.Response, error) {
+	req := c.Request(ctx)
+
+	if session != "" {
+		req.SetContext(client.WithSession(req.Context(), session))
+		if s := c.GetSessionManager().GetSession(session); s != nil {
+			for _, cookie := range s.Cookies {
+				req.SetCookie(cookie)
+			}
+		}
+	}
+
+	for k, val := range headers {
+		req.SetHeader(k, val)
+	}
+	if body != "" {
+		req.SetBody(body)
+	}
+
+	return req.Execute(method, url)
+}
+
+// printTamperResult prints bypass attempts as a table
+func printTamperResult(title string, result *TamperResult) {
+	pterm.DefaultSection.Printf("%s: %s %s (baseline %d)\n", title, result.Method, result.URL, result.BaselineStatus)
+
+	if len(result.Attempts) == 0 {
+		utils.Info.Println("Baseline not denied, nothing to tamper")
+		return
+	}
+
+	tableData := pterm.TableData{
+		{"Technique", "Method", "Status", "Length", "Result"},
+	}
+	for _, a := range result.Attempts {
+		status := pterm.Red("DENIED")
+		if a.Bypassed {
+			status = pterm.Green("BYPASS")
+		}
+		tableData = append(tableData, []string{
+			a.Technique,
+			a.Method,
+			fmt.Sprintf("%d", a.StatusCode),
+			fmt.Sprintf("%d", a.ContentLen),
+			status,
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// IsDenied reports whether a status code indicates an access control decision
+func IsDenied(status int) bool {
+	return status == 401 || status == 403 || status == 405
+}
+
+func appendQueryParam(url, name, value string) string {
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	return url + sep + name + "=" + value
+}
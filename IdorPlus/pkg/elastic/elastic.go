@@ -0,0 +1,302 @@
+// Package elastic ships fuzz results to Elasticsearch or OpenSearch, every
+// result and not just findings, for statistics and dashboards in Kibana or
+// OpenSearch Dashboards
+package elastic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+)
+
+// DefaultIndex is the index results go to when none is configured
+const DefaultIndex = "idorplus-results"
+
+// batchSize and flushInterval bound how long a result waits to be sent
+const (
+	batchSize     = 500
+	flushInterval = 5 * time.Second
+)
+
+// Document is a fuzz result as indexed, see Mapping
+type Document struct {
+	Timestamp   time.Time `json:"@timestamp"`
+	ScanID      string    `json:"scan_id"`     // one per scan, see Indexer.ScanID
+	Target      string    `json:"target"`      // the scan's target URL, {ID} included
+	Host        string    `json:"host"`        // host of URL
+	Endpoint    string    `json:"endpoint"`    // URL template the job belongs to
+	URL         string    `json:"url"`         // URL requested
+	Method      string    `json:"method"`      // HTTP method
+	Payload     string    `json:"payload"`     // ID tried
+	Session     string    `json:"session"`     // session name, e.g. attacker
+	StatusCode  int       `json:"status_code"` // 0 when the request failed
+	ContentLen  int       `json:"content_length"`
+	DurationMS  int64     `json:"duration_ms"` // response time, retries included
+	Vulnerable  bool      `json:"vulnerable"`
+	Confidence  int       `json:"confidence"`
+	Reasons     []string  `json:"reasons,omitempty"`
+	Blocked     bool      `json:"blocked"` // WAF block page
+	BlockReason string    `json:"block_reason,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	BodyDigest  string    `json:"body_sha256,omitempty"`
+}
+
+// Mapping is the index mapping the Indexer creates a missing index with.
+// Strings are keywords for aggregations, except the error text.
+const Mapping = `{
+  "mappings": {
+    "properties": {
+      "@timestamp":     {"type": "date"},
+      "scan_id":        {"type": "keyword"},
+      "target":         {"type": "keyword"},
+      "host":           {"type": "keyword"},
+      "endpoint":       {"type": "keyword"},
+      "url":            {"type": "keyword"},
+      "method":         {"type": "keyword"},
+      "payload":        {"type": "keyword"},
+      "session":        {"type": "keyword"},
+      "status_code":    {"type": "integer"},
+      "content_length": {"type": "integer"},
+      "duration_ms":    {"type": "long"},
+      "vulnerable":     {"type": "boolean"},
+      "confidence":     {"type": "integer"},
+      "reasons":        {"type": "keyword"},
+      "blocked":        {"type": "boolean"},
+      "block_reason":   {"type": "keyword"},
+      "error":          {"type": "text"},
+      "body_sha256":    {"type": "keyword"}
+    }
+  }
+}`
+
+// Indexer indexes results with the bulk API in the background. Add blocks
+// only once a few batches are waiting, so a slow cluster slows the scan
+// rather than losing results.
+type Indexer struct {
+	// ScanID tells the results of this scan apart from earlier ones
+	ScanID string
+
+	cfg    utils.ElasticConfig
+	target string
+	client *http.Client
+
+	queue  chan *Document
+	wg     sync.WaitGroup
+	sent   int
+	failed int
+}
+
+// NewIndexer creates the index if it doesn't exist and starts indexing
+// the results of a scan of target. Returns nil when no URL is configured;
+// a nil Indexer ignores all calls.
+func NewIndexer(cfg utils.ElasticConfig, target string) (*Indexer, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	if cfg.Index == "" {
+		cfg.Index = DefaultIndex
+	}
+	id := make([]byte, 4)
+	rand.Read(id)
+	ix := &Indexer{
+		ScanID: time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(id),
+		cfg:    cfg,
+		target: target,
+		client: &http.Client{Timeout: 30 * time.Second},
+		queue:  make(chan *Document, 4*batchSize),
+	}
+	if err := ix.ensureIndex(); err != nil {
+		return nil, err
+	}
+	ix.wg.Add(1)
+	go ix.run()
+	return ix, nil
+}
+
+// Add queues a result
+func (ix *Indexer) Add(r *fuzzer.FuzzResult) {
+	if ix == nil {
+		return
+	}
+	ix.queue <- ix.document(r)
+}
+
+// Close indexes the remaining results, stops the indexer and returns how
+// many results were indexed and how many were rejected or lost
+func (ix *Indexer) Close() (sent, failed int) {
+	if ix == nil {
+		return 0, 0
+	}
+	close(ix.queue)
+	ix.wg.Wait()
+	return ix.sent, ix.failed
+}
+
+func (ix *Indexer) document(r *fuzzer.FuzzResult) *Document {
+	doc := &Document{
+		Timestamp:   time.Now().UTC(),
+		ScanID:      ix.ScanID,
+		Target:      ix.target,
+		URL:         r.Job.URL,
+		Endpoint:    r.Job.Endpoint,
+		Method:      r.Job.Method,
+		Payload:     r.Job.Payload,
+		Session:     r.Job.Session,
+		StatusCode:  r.StatusCode,
+		ContentLen:  r.ContentLen,
+		DurationMS:  r.Duration.Milliseconds(),
+		Vulnerable:  r.IsVulnerable,
+		Confidence:  r.Confidence,
+		Reasons:     r.Reasons,
+		Blocked:     r.Blocked,
+		BlockReason: r.BlockReason,
+		BodyDigest:  r.BodyDigest,
+	}
+	if doc.Endpoint == "" {
+		doc.Endpoint = doc.URL
+	}
+	if u, err := url.Parse(doc.URL); err == nil {
+		doc.Host = u.Host
+	}
+	if r.Error != nil {
+		doc.Error = r.Error.Error()
+	}
+	return doc
+}
+
+func (ix *Indexer) run() {
+	defer ix.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var batch []*Document
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := ix.bulk(batch); err != nil {
+			utils.Warning.Printf("Elasticsearch indexing failed: %v\n", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case doc, ok := <-ix.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, doc); len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// bulk indexes a batch, counting the documents indexed and rejected
+func (ix *Indexer) bulk(batch []*Document) error {
+	var body bytes.Buffer
+	action, _ := json.Marshal(map[string]any{"index": map[string]string{"_index": ix.cfg.Index}})
+	enc := json.NewEncoder(&body)
+	for _, doc := range batch {
+		body.Write(action)
+		body.WriteByte('\n')
+		enc.Encode(doc)
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  any `json:"error"`
+		} `json:"items"`
+	}
+	if err := ix.do(http.MethodPost, "/_bulk", "application/x-ndjson", &body, &resp); err != nil {
+		ix.failed += len(batch)
+		return err
+	}
+	if !resp.Errors {
+		ix.sent += len(batch)
+		return nil
+	}
+
+	var firstErr any
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 300 {
+				ix.failed++
+				if firstErr == nil {
+					firstErr = result.Error
+				}
+			} else {
+				ix.sent++
+			}
+		}
+	}
+	return fmt.Errorf("documents rejected: %v", firstErr)
+}
+
+// ensureIndex creates the index with Mapping unless it exists
+func (ix *Indexer) ensureIndex() error {
+	err := ix.do(http.MethodHead, "/"+url.PathEscape(ix.cfg.Index), "", nil, nil)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, errNotFound) {
+		return err
+	}
+	return ix.do(http.MethodPut, "/"+url.PathEscape(ix.cfg.Index), "application/json", strings.NewReader(Mapping), nil)
+}
+
+// errNotFound is returned by do for a 404
+var errNotFound = errors.New("not found")
+
+// do sends an authenticated request to the cluster and decodes its JSON
+// response into out, if set
+func (ix *Indexer) do(method, path, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequest(method, strings.TrimSuffix(ix.cfg.URL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case ix.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+ix.cfg.APIKey)
+	case ix.cfg.Username != "":
+		req.SetBasicAuth(ix.cfg.Username, ix.cfg.Password)
+	}
+
+	resp, err := ix.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 300))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// defectDojoScanType is the DefectDojo parser of the imported file
+const defectDojoScanType = "Generic Findings Import"
+
+// DefectDojo imports reports with DefectDojo's import-scan API. DefectDojo
+// deduplicates findings by their unique_id_from_tool, the fingerprint.
+type DefectDojo struct {
+	cfg    utils.DefectDojoConfig
+	client *http.Client
+}
+
+// NewDefectDojo checks the DefectDojo config
+func NewDefectDojo(cfg utils.DefectDojoConfig) (*DefectDojo, error) {
+	switch {
+	case cfg.URL == "":
+		return nil, errors.New("export.defectdojo.url is not set")
+	case cfg.APIKey == "":
+		return nil, errors.New("export.defectdojo.api_key is not set, e.g. with IDORPLUS_EXPORT_DEFECTDOJO_API_KEY")
+	case cfg.Engagement == 0 && (cfg.Product == "" || cfg.EngagementName == ""):
+		return nil, errors.New("set export.defectdojo.engagement, or product and engagement_name")
+	}
+	return &DefectDojo{cfg: cfg, client: &http.Client{Timeout: defaultTimeout}}, nil
+}
+
+// dojoFinding is a finding of DefectDojo's generic findings format
+type dojoFinding struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Severity    string         `json:"severity"`
+	Mitigation  string         `json:"mitigation"`
+	Date        string         `json:"date"`
+	CWE         int            `json:"cwe"`
+	CVSSv3      string         `json:"cvssv3,omitempty"`
+	CVSSv3Score float64        `json:"cvssv3_score,omitempty"`
+	UniqueID    string         `json:"unique_id_from_tool"`
+	VulnID      string         `json:"vuln_id_from_tool"`
+	Payload     string         `json:"payload,omitempty"`
+	Steps       string         `json:"steps_to_reproduce,omitempty"`
+	Endpoints   []dojoEndpoint `json:"endpoints,omitempty"`
+}
+
+type dojoEndpoint struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Query    string `json:"query,omitempty"`
+}
+
+// findings converts the findings of a report at or above the minimum
+// severity (default LOW) to DefectDojo's generic findings format, redacted
+func (d *DefectDojo) findings(report *reporter.Report) any {
+	findings := []dojoFinding{}
+	for _, f := range atLeast(report.Findings, d.cfg.MinSeverity, "LOW") {
+		f = redact(f)
+		var desc strings.Builder
+		for _, kv := range details(f) {
+			fmt.Fprintf(&desc, "**%s:** %s\n\n", kv[0], kv[1])
+		}
+		if f.Evidence != "" {
+			desc.WriteString("**Evidence:**\n```\n" + f.Evidence + "\n```\n")
+		}
+
+		df := dojoFinding{
+			Title:       title(f),
+			Description: desc.String(),
+			Severity:    dojoSeverity(f.Severity),
+			Mitigation:  reporter.Remediation(f.Type),
+			Date:        f.Timestamp.Format(time.DateOnly),
+			CWE:         CWE(f.Type),
+			CVSSv3:      f.CVSSVector,
+			CVSSv3Score: f.CVSSScore,
+			UniqueID:    fingerprint(f),
+			VulnID:      f.Type,
+			Payload:     f.Payload,
+			Steps:       f.Curl,
+		}
+		if f.Timestamp.IsZero() {
+			df.Date = report.ScanTime.Format(time.DateOnly)
+		}
+		if u, err := url.Parse(f.URL); err == nil && u.Host != "" {
+			port, _ := strconv.Atoi(u.Port())
+			df.Endpoints = []dojoEndpoint{{Protocol: u.Scheme, Host: u.Hostname(), Port: port, Path: strings.TrimPrefix(u.Path, "/"), Query: u.RawQuery}}
+		}
+		findings = append(findings, df)
+	}
+	return map[string]any{"findings": findings}
+}
+
+// Import uploads the report as a new test of the engagement and returns
+// the test's ID
+func (d *DefectDojo) Import(report *reporter.Report) (int, error) {
+	data, err := json.Marshal(d.findings(report))
+	if err != nil {
+		return 0, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"scan_type":        defectDojoScanType,
+		"scan_date":        report.ScanTime.Format(time.DateOnly),
+		"minimum_severity": "Info",
+		"active":           "true",
+		"verified":         "false",
+	}
+	if d.cfg.Engagement != 0 {
+		fields["engagement"] = strconv.Itoa(d.cfg.Engagement)
+	} else {
+		fields["product_name"] = d.cfg.Product
+		fields["engagement_name"] = d.cfg.EngagementName
+		fields["auto_create_context"] = "true"
+	}
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	fw, err := mw.CreateFormFile("file", "idorplus.json")
+	if err != nil {
+		return 0, err
+	}
+	fw.Write(data)
+	if err := mw.Close(); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(d.cfg.URL, "/")+"/api/v2/import-scan/", &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Token "+d.cfg.APIKey)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var resp struct {
+		Test int `json:"test"`
+	}
+	if err := doJSON(d.client, req, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Test, nil
+}
+
+// dojoSeverity spells a severity the way DefectDojo does, e.g. High
+func dojoSeverity(severity string) string {
+	if severity == "" {
+		return "Info"
+	}
+	return strings.ToUpper(severity[:1]) + strings.ToLower(severity[1:])
+}
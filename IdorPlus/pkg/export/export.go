@@ -0,0 +1,157 @@
+// Package export sends findings to issue trackers: DefectDojo and Jira
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/reporter"
+)
+
+// defaultTimeout bounds each request to a tracker
+const defaultTimeout = 30 * time.Second
+
+// cwes are the CWE IDs of the finding types, 285 (improper authorization)
+// for the others
+var cwes = map[string]int{
+	reporter.FindingIDOR:           639,
+	reporter.FindingVerbTamper:     650,
+	reporter.FindingMassAssign:     915,
+	reporter.FindingParamPollution: 235,
+	reporter.FindingPagination:     639,
+	reporter.FindingDownload:       639,
+	reporter.FindingCORS:           942,
+}
+
+// CWE returns the CWE ID of a finding type
+func CWE(findingType string) int {
+	if id, ok := cwes[findingType]; ok {
+		return id
+	}
+	return 285
+}
+
+// atLeast returns the findings at or above a severity, min defaulting to def
+func atLeast(findings []*reporter.Finding, min, def string) []*reporter.Finding {
+	if min == "" {
+		min = def
+	}
+	var kept []*reporter.Finding
+	for _, f := range findings {
+		if reporter.SeverityAtLeast(f.Severity, strings.ToUpper(min)) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// redact returns a copy of a finding fit to leave the tester's hands: PII
+// masked, see reporter.RedactFinding, and the credentials and secrets of
+// its request masked, in its curl reproduction too
+func redact(f *reporter.Finding) *reporter.Finding {
+	r := reporter.RedactFinding(f)
+	r.URL = client.MaskURL(f.URL)
+	r.Curl = ""
+	if f.Request != nil {
+		rec := reporter.RecordedRequest{Method: f.Request.Method, URL: client.MaskURL(f.Request.URL), Body: client.MaskBody(f.Request.Body)}
+		if f.Request.Headers != nil {
+			rec.Headers = make(map[string]string, len(f.Request.Headers))
+			for name, value := range f.Request.Headers {
+				if client.IsCredentialHeader(name) {
+					value = reporter.MaskedHeader
+				}
+				rec.Headers[name] = value
+			}
+		}
+		r.Request = &rec
+		r.Curl = rec.Curl()
+	}
+	return r
+}
+
+// fingerprint returns the fingerprint of a finding, computed for findings
+// of reports written before fingerprints
+func fingerprint(f *reporter.Finding) string {
+	if f.Fingerprint != "" {
+		return f.Fingerprint
+	}
+	return reporter.Fingerprint(f)
+}
+
+// title is the one-line summary of a finding
+func title(f *reporter.Finding) string {
+	return fmt.Sprintf("[%s] %s: %s %s", f.Severity, f.Type, f.Method, f.URL)
+}
+
+// details lists what a finding shows as label and value
+func details(f *reporter.Finding) [][2]string {
+	var d [][2]string
+	add := func(label, value string) {
+		if value != "" {
+			d = append(d, [2]string{label, value})
+		}
+	}
+	add("URL", f.URL)
+	add("Method", f.Method)
+	add("Technique", f.Technique)
+	add("Payload", f.Payload)
+	add("Affected IDs", f.IDRange)
+	add("Status", fmt.Sprintf("%d (%d bytes)", f.StatusCode, f.ContentLen))
+	if f.CVSSVector != "" {
+		add("CVSS", fmt.Sprintf("%.1f %s", f.CVSSScore, f.CVSSVector))
+	}
+	add("OWASP API Top 10", strings.Join(f.OWASP, "; "))
+	if f.Confidence > 0 {
+		add("Confidence", fmt.Sprintf("%d/100 (%s)", f.Confidence, strings.Join(f.Reasons, "; ")))
+	}
+	if len(f.PIIFound) > 0 {
+		kinds := make([]string, 0, len(f.PIIFound))
+		for k, v := range f.PIIFound {
+			kinds = append(kinds, fmt.Sprintf("%s (%d)", k, len(v)))
+		}
+		sort.Strings(kinds)
+		add("PII", strings.Join(kinds, ", "))
+	}
+	add("Fingerprint", fingerprint(f))
+	return d
+}
+
+// doJSON sends a request with a JSON body, if any, and decodes the JSON
+// response into out, if set
+func doJSON(client *http.Client, req *http.Request, body, out any) error {
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 300 {
+			msg = msg[:300] + "..."
+		}
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
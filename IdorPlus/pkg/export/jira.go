@@ -0,0 +1,147 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// fingerprintLabel prefixes the label identifying a finding's issue
+const fingerprintLabel = "idorplus-"
+
+// Jira creates an issue per finding with Jira's REST API. Issues carry the
+// finding's fingerprint as a label, so a finding already filed, e.g. by an
+// earlier scan, is not filed again.
+type Jira struct {
+	cfg    utils.JiraConfig
+	client *http.Client
+}
+
+// JiraResult is what an export to Jira did
+type JiraResult struct {
+	Created  []string // keys of the new issues
+	Existing []string // keys of the issues already filed for findings
+}
+
+// NewJira checks the Jira config
+func NewJira(cfg utils.JiraConfig) (*Jira, error) {
+	switch {
+	case cfg.URL == "":
+		return nil, errors.New("export.jira.url is not set")
+	case cfg.Token == "":
+		return nil, errors.New("export.jira.token is not set, e.g. with IDORPLUS_EXPORT_JIRA_TOKEN")
+	case cfg.Project == "":
+		return nil, errors.New("export.jira.project is not set")
+	}
+	if cfg.IssueType == "" {
+		cfg.IssueType = "Bug"
+	}
+	return &Jira{cfg: cfg, client: &http.Client{Timeout: defaultTimeout}}, nil
+}
+
+// Export files the findings at or above the minimum severity (default
+// MEDIUM) that have no issue yet, redacted
+func (j *Jira) Export(findings []*reporter.Finding) (*JiraResult, error) {
+	result := &JiraResult{}
+	for _, f := range atLeast(findings, j.cfg.MinSeverity, "MEDIUM") {
+		f = redact(f)
+		label := fingerprintLabel + fingerprint(f)
+		key, err := j.find(label)
+		if err != nil {
+			return result, err
+		}
+		if key != "" {
+			result.Existing = append(result.Existing, key)
+			continue
+		}
+		if key, err = j.create(f, label); err != nil {
+			return result, err
+		}
+		result.Created = append(result.Created, key)
+	}
+	return result, nil
+}
+
+// find returns the key of the project's issue with a label, "" if none
+func (j *Jira) find(label string) (string, error) {
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s"`, j.cfg.Project, label)
+	q := url.Values{"jql": {jql}, "fields": {"key"}, "maxResults": {"1"}}
+	req, err := j.request(http.MethodGet, "/rest/api/3/search/jql?"+q.Encode())
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+	if err := doJSON(j.client, req, nil, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Issues) == 0 {
+		return "", nil
+	}
+	return resp.Issues[0].Key, nil
+}
+
+// create files an issue for a finding and returns its key
+func (j *Jira) create(f *reporter.Finding, label string) (string, error) {
+	req, err := j.request(http.MethodPost, "/rest/api/2/issue")
+	if err != nil {
+		return "", err
+	}
+	summary := title(f)
+	if len(summary) > 250 {
+		summary = summary[:247] + "..."
+	}
+	issue := map[string]any{
+		"fields": map[string]any{
+			"project":     map[string]string{"key": j.cfg.Project},
+			"issuetype":   map[string]string{"name": j.cfg.IssueType},
+			"summary":     summary,
+			"description": jiraDescription(f),
+			"labels":      append(append([]string{}, j.cfg.Labels...), label),
+		},
+	}
+	var resp struct {
+		Key string `json:"key"`
+	}
+	if err := doJSON(j.client, req, issue, &resp); err != nil {
+		return "", err
+	}
+	return resp.Key, nil
+}
+
+func (j *Jira) request(method, path string) (*http.Request, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(j.cfg.URL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if j.cfg.User != "" {
+		req.SetBasicAuth(j.cfg.User, j.cfg.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.cfg.Token)
+	}
+	return req, nil
+}
+
+// jiraDescription renders a finding in Jira wiki markup
+func jiraDescription(f *reporter.Finding) string {
+	var b strings.Builder
+	for _, kv := range details(f) {
+		fmt.Fprintf(&b, "*%s:* %s\n", kv[0], kv[1])
+	}
+	b.WriteString("\nh3. Remediation\n" + reporter.Remediation(f.Type) + "\n")
+	if f.Curl != "" {
+		b.WriteString("\nh3. Reproduce\n{code:bash}\n" + f.Curl + "\n{code}\n")
+	}
+	if f.Evidence != "" {
+		b.WriteString("\nh3. Evidence\n{noformat}\n" + f.Evidence + "\n{noformat}\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package firebase
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Project is a Firebase project found in client code. A web app's config
+// holds all three; any one is enough to test its rules.
+type Project struct {
+	ID          string `json:"project_id,omitempty"`
+	DatabaseURL string `json:"database_url,omitempty"` // Realtime Database
+	APIKey      string `json:"api_key,omitempty"`
+}
+
+var (
+	// databaseURLPattern matches Realtime Database URLs, the legacy
+	// firebaseio.com ones and regional firebasedatabase.app ones
+	databaseURLPattern = regexp.MustCompile(`https://([a-z0-9-]+?)(?:-default-rtdb)?\.(?:firebaseio\.com|[a-z0-9-]+\.firebasedatabase\.app)`)
+	projectIDPattern   = regexp.MustCompile(`["']?projectId["']?\s*[:=]\s*["']([a-z0-9-]{4,30})["']`)
+	firestorePattern   = regexp.MustCompile(`firestore\.googleapis\.com/v1(?:beta\d)?/projects/([a-z0-9-]{4,30})/`)
+	apiKeyPattern      = regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`)
+)
+
+// DetectProjects finds the Firebase projects configured in JavaScript or
+// HTML. Database URLs and project IDs of one config are merged when they
+// name the same project.
+func DetectProjects(content string) []Project {
+	projects := make(map[string]*Project)
+	get := func(id string) *Project {
+		if projects[id] == nil {
+			projects[id] = &Project{ID: id}
+		}
+		return projects[id]
+	}
+	for _, m := range databaseURLPattern.FindAllStringSubmatch(content, -1) {
+		get(m[1]).DatabaseURL = m[0]
+	}
+	for _, m := range projectIDPattern.FindAllStringSubmatch(content, -1) {
+		get(m[1])
+	}
+	for _, m := range firestorePattern.FindAllStringSubmatch(content, -1) {
+		get(m[1])
+	}
+
+	// Nothing ties a key to its project in the code; apps rarely configure
+	// more than one, so the first key goes with every project
+	keys := apiKeyPattern.FindAllString(content, -1)
+	var found []Project
+	for _, p := range projects {
+		if len(keys) > 0 {
+			p.APIKey = keys[0]
+		}
+		found = append(found, *p)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+	return found
+}
+
+// MergeProjects adds the projects of more to projects, filling in what
+// one finding of a project lacks from another
+func MergeProjects(projects []Project, more ...Project) []Project {
+	for _, m := range more {
+		i := indexOf(projects, m.ID)
+		if i < 0 {
+			projects = append(projects, m)
+			continue
+		}
+		p := &projects[i]
+		if p.DatabaseURL == "" {
+			p.DatabaseURL = m.DatabaseURL
+		}
+		if p.APIKey == "" {
+			p.APIKey = m.APIKey
+		}
+	}
+	return projects
+}
+
+func indexOf(projects []Project, id string) int {
+	for i, p := range projects {
+		if strings.EqualFold(p.ID, id) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,206 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"idorplus/pkg/client"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// FirestoreURL is the Firestore REST API
+const FirestoreURL = "https://firestore.googleapis.com/v1"
+
+// DefaultPaths are the Realtime Database paths and Firestore collections
+// probed unless others are given
+var DefaultPaths = []string{"users", "accounts", "profiles", "orders", "payments", "messages", "chats", "posts", "admins", "config", "settings", "tokens"}
+
+// Token is a Firebase ID token of a user, sent as the auth parameter to
+// the Realtime Database and as a bearer token to Firestore
+type Token struct {
+	Name  string
+	Value string
+}
+
+// Access is one token's read of a path, Token "" for anonymous
+type Access struct {
+	Status   int    `json:"status"`
+	Readable bool   `json:"readable"` // data came back
+	Size     int    `json:"size,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PathResult is how a path reads for each token
+type PathResult struct {
+	Service string            `json:"service"` // "rtdb" or "firestore"
+	Path    string            `json:"path"`
+	URL     string            `json:"url"`
+	Access  map[string]Access `json:"access"` // by token name, "" for anonymous
+}
+
+// Exposed reports whether the path is readable without credentials
+func (r *PathResult) Exposed() bool {
+	return r.Access[""].Readable
+}
+
+// ReadableBy returns the names of the tokens that read the path
+func (r *PathResult) ReadableBy() []string {
+	var names []string
+	for name, a := range r.Access {
+		if a.Readable && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Tester reads Realtime Database and Firestore paths over REST as each
+// token and anonymously, to find rules that let users read what isn't
+// theirs. It only reads.
+type Tester struct {
+	client *client.SmartClient
+	Tokens []Token
+	// FirestoreURL is the Firestore API, default FirestoreURL
+	FirestoreURL string
+}
+
+// NewTester creates a tester for the users with tokens
+func NewTester(c *client.SmartClient, tokens []Token) *Tester {
+	return &Tester{client: c, Tokens: tokens, FirestoreURL: FirestoreURL}
+}
+
+// ProbeDatabase reads the root and paths of a Realtime Database. Shallow
+// reads keep the response to the keys of a path, not its whole tree.
+func (t *Tester) ProbeDatabase(ctx context.Context, databaseURL string, paths []string) ([]PathResult, error) {
+	var results []PathResult
+	for _, path := range append([]string{""}, paths...) {
+		r, err := t.readDatabase(ctx, databaseURL, path, true)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// ProbeFirestore lists the first documents of Firestore collections
+func (t *Tester) ProbeFirestore(ctx context.Context, p Project, collections []string) ([]PathResult, error) {
+	var results []PathResult
+	for _, collection := range collections {
+		r, err := t.readFirestore(ctx, p, collection, "pageSize=3")
+		if err != nil {
+			return results, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// FuzzDocuments reads the document of each ID at pattern, a path with an
+// {ID} placeholder such as users/{ID}, in the Realtime Database and in
+// Firestore, whichever the project has
+func (t *Tester) FuzzDocuments(ctx context.Context, p Project, pattern string, ids []string) ([]PathResult, error) {
+	if !strings.Contains(pattern, "{ID}") {
+		return nil, fmt.Errorf("document pattern %q has no {ID}", pattern)
+	}
+	var results []PathResult
+	for _, id := range ids {
+		path := strings.ReplaceAll(pattern, "{ID}", url.PathEscape(id))
+		if p.DatabaseURL != "" {
+			r, err := t.readDatabase(ctx, p.DatabaseURL, path, false)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, r)
+		}
+		if p.ID != "" {
+			r, err := t.readFirestore(ctx, p, path, "")
+			if err != nil {
+				return results, err
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// readDatabase reads a Realtime Database path as every token
+func (t *Tester) readDatabase(ctx context.Context, databaseURL, path string, shallow bool) (PathResult, error) {
+	r := PathResult{Service: "rtdb", Path: "/" + path, URL: strings.TrimSuffix(databaseURL, "/") + "/" + path + ".json"}
+	for _, token := range t.tokens() {
+		req := t.client.Request(ctx)
+		if shallow {
+			req.SetQueryParam("shallow", "true")
+		}
+		if token.Value != "" {
+			req.SetQueryParam("auth", token.Value)
+		}
+		resp, err := req.Get(r.URL)
+		if err != nil {
+			return r, err
+		}
+		r.add(token.Name, resp, databaseError(resp), resp.StatusCode() == http.StatusOK && string(resp.Body()) != "null")
+	}
+	return r, nil
+}
+
+// readFirestore reads a Firestore document or collection as every token
+func (t *Tester) readFirestore(ctx context.Context, p Project, path, query string) (PathResult, error) {
+	r := PathResult{Service: "firestore", Path: path, URL: fmt.Sprintf("%s/projects/%s/databases/(default)/documents/%s", t.FirestoreURL, p.ID, path)}
+	for _, token := range t.tokens() {
+		req := t.client.Request(ctx)
+		if query != "" {
+			req.SetQueryString(query)
+		}
+		if p.APIKey != "" {
+			req.SetQueryParam("key", p.APIKey)
+		}
+		if token.Value != "" {
+			req.SetAuthToken(token.Value)
+		}
+		resp, err := req.Get(r.URL)
+		if err != nil {
+			return r, err
+		}
+		var doc struct {
+			Name      string            `json:"name"`
+			Documents []json.RawMessage `json:"documents"`
+			Error     struct {
+				Status string `json:"status"`
+			} `json:"error"`
+		}
+		json.Unmarshal(resp.Body(), &doc)
+		readable := resp.StatusCode() == http.StatusOK && (doc.Name != "" || len(doc.Documents) > 0)
+		r.add(token.Name, resp, doc.Error.Status, readable)
+	}
+	return r, nil
+}
+
+func (r *PathResult) add(name string, resp *resty.Response, errMsg string, readable bool) {
+	if r.Access == nil {
+		r.Access = make(map[string]Access)
+	}
+	r.Access[name] = Access{Status: resp.StatusCode(), Readable: readable, Size: len(resp.Body()), Error: errMsg}
+}
+
+// tokens returns anonymous access followed by the tokens
+func (t *Tester) tokens() []Token {
+	return append([]Token{{}}, t.Tokens...)
+}
+
+// databaseError returns the error of a Realtime Database response, e.g.
+// "Permission denied"
+func databaseError(resp *resty.Response) string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if resp.StatusCode() == http.StatusOK || json.Unmarshal(resp.Body(), &body) != nil {
+		return ""
+	}
+	return body.Error
+}
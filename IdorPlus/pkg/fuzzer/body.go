@@ -0,0 +1,113 @@
+package fuzzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"idorplus/pkg/utils"
+)
+
+// SnippetSize caps the Evidence kept per result. The reporter truncates
+// evidence at 1000 bytes, a little more lets it mark the cut.
+const SnippetSize = 1024
+
+// snippet is the Evidence of a body
+func snippet(body []byte) string {
+	if len(body) > SnippetSize {
+		body = body[:SnippetSize]
+	}
+	return string(body)
+}
+
+// Body returns the full response body: from the response while the result
+// holds it, else from BodyFile. It is nil once the body was released.
+func (r *FuzzResult) Body() []byte {
+	if r.Response != nil && len(r.Response.Body()) > 0 {
+		return r.Response.Body()
+	}
+	if r.BodyFile != "" {
+		body, err := os.ReadFile(r.BodyFile)
+		if err != nil {
+			utils.Debug.Printf("Reading body of %s: %v\n", r.Job.URL, err)
+			return nil
+		}
+		return body
+	}
+	return nil
+}
+
+// WriteBody copies the full response body to w without loading a spooled
+// body into memory
+func (r *FuzzResult) WriteBody(w io.Writer) error {
+	if r.Response != nil && len(r.Response.Body()) > 0 {
+		_, err := w.Write(r.Response.Body())
+		return err
+	}
+	if r.BodyFile == "" {
+		return nil
+	}
+	f, err := os.Open(r.BodyFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// release bounds what a result keeps once it has been assessed. Results
+// pile up in the results channel, store batches and OnResult consumers, so
+// a non-vulnerable one keeps only a digest and a snippet of its body and
+// drops the response. A finding keeps its response for the reporter, with
+// the body moved to BodyDir when set.
+func (fe *FuzzEngine) release(result *FuzzResult) {
+	if result.Response == nil {
+		return
+	}
+	body := result.Response.Body()
+	sum := sha256.Sum256(body)
+	result.BodyDigest = hex.EncodeToString(sum[:])
+	if len(result.Evidence) > SnippetSize {
+		result.Evidence = result.Evidence[:SnippetSize]
+	}
+
+	if !result.IsVulnerable {
+		result.Response = nil
+		return
+	}
+	if fe.BodyDir == "" || len(body) == 0 {
+		return
+	}
+	// Named by digest, identical bodies are written once
+	path := filepath.Join(fe.BodyDir, result.BodyDigest+".body")
+	if _, err := os.Stat(path); err != nil {
+		if err := writeFileAtomic(path, body); err != nil {
+			utils.Warning.Printf("Keeping body of %s in memory: %v\n", result.Job.URL, err)
+			return
+		}
+	}
+	result.BodyFile = path
+	result.Response.SetBody(nil)
+}
+
+// writeFileAtomic writes through a temporary file, so a worker spooling
+// the same body concurrently never exposes a partial file
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".body-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,238 @@
+package fuzzer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Latency histogram buckets grow by latencyGrowth from latencyMin, so a
+// percentile is off by at most that factor whatever the scale
+const (
+	latencyMin     = time.Millisecond
+	latencyGrowth  = 1.2
+	latencyBuckets = 64 // up to about 2.5 minutes
+)
+
+// Breakdown details the responses of a scan: how many got each status
+// code, their latency and size, overall and per endpoint
+type Breakdown struct {
+	// StatusCodes counts responses by status code, 0 for requests that
+	// got none
+	StatusCodes map[int]int64   `json:"status_codes"`
+	Bytes       int64           `json:"bytes"` // response bodies received
+	Latency     LatencySummary  `json:"latency"`
+	Endpoints   []EndpointStats `json:"endpoints"`
+}
+
+// EndpointStats is the Breakdown of one endpoint
+type EndpointStats struct {
+	Endpoint    string         `json:"endpoint"`
+	Requests    int64          `json:"requests"`
+	Errors      int64          `json:"errors"`
+	Vulnerable  int64          `json:"vulnerable"`
+	StatusCodes map[int]int64  `json:"status_codes"`
+	Bytes       int64          `json:"bytes"`
+	Latency     LatencySummary `json:"latency"`
+}
+
+// LatencySummary sums up response times. Percentiles are estimated from a
+// histogram and within 20% of the exact value.
+type LatencySummary struct {
+	Samples int64         `json:"samples"`
+	Avg     time.Duration `json:"avg"`
+	P50     time.Duration `json:"p50"`
+	P90     time.Duration `json:"p90"`
+	P95     time.Duration `json:"p95"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+}
+
+// String formats the summary for the terminal
+func (l LatencySummary) String() string {
+	if l.Samples == 0 {
+		return "-"
+	}
+	r := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	return fmt.Sprintf("avg %s, p50 %s, p95 %s, p99 %s, max %s", r(l.Avg), r(l.P50), r(l.P95), r(l.P99), r(l.Max))
+}
+
+// latencyHistogram counts durations in exponential buckets, so it takes the
+// same memory for a hundred requests as for millions
+type latencyHistogram struct {
+	counts  [latencyBuckets]int64
+	samples int64
+	sum     time.Duration
+	max     time.Duration
+}
+
+// latencyBound is the upper bound of bucket i
+func latencyBound(i int) time.Duration {
+	return time.Duration(float64(latencyMin) * math.Pow(latencyGrowth, float64(i)))
+}
+
+func (h *latencyHistogram) add(d time.Duration) {
+	i := 0
+	if d > latencyMin {
+		i = min(int(math.Ceil(math.Log(float64(d)/float64(latencyMin))/math.Log(latencyGrowth))), latencyBuckets-1)
+	}
+	h.counts[i]++
+	h.samples++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+// percentile estimates the q-th (0-1) percentile, interpolating within
+// its bucket
+func (h *latencyHistogram) percentile(q float64) time.Duration {
+	rank := q * float64(h.samples)
+	var seen float64
+	for i, n := range h.counts {
+		if n == 0 || seen+float64(n) < rank {
+			seen += float64(n)
+			continue
+		}
+		lower := time.Duration(0)
+		if i > 0 {
+			lower = latencyBound(i - 1)
+		}
+		upper := latencyBound(i)
+		d := lower + time.Duration((rank-seen)/float64(n)*float64(upper-lower))
+		return min(d, h.max)
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) summary() LatencySummary {
+	if h.samples == 0 {
+		return LatencySummary{}
+	}
+	return LatencySummary{
+		Samples: h.samples,
+		Avg:     h.sum / time.Duration(h.samples),
+		P50:     h.percentile(0.50),
+		P90:     h.percentile(0.90),
+		P95:     h.percentile(0.95),
+		P99:     h.percentile(0.99),
+		Max:     h.max,
+	}
+}
+
+// endpointDetail is the running tally behind an EndpointStats
+type endpointDetail struct {
+	requests, errors, vulnerable, bytes int64
+	statusCodes                         map[int]int64
+	latency                             latencyHistogram
+}
+
+// Record counts the response of a job sent to endpoint. latency is how
+// long the server took, see resultLatency.
+func (s *Stats) Record(endpoint string, result *FuzzResult, latency time.Duration) {
+	s.detailMu.Lock()
+	defer s.detailMu.Unlock()
+	if s.statusCodes == nil {
+		s.statusCodes = make(map[int]int64)
+		s.endpoints = make(map[string]*endpointDetail)
+	}
+	e := s.endpoints[endpoint]
+	if e == nil {
+		e = &endpointDetail{statusCodes: make(map[int]int64)}
+		s.endpoints[endpoint] = e
+	}
+
+	status := result.StatusCode
+	if result.Error != nil {
+		status = 0
+		e.errors++
+	}
+	s.statusCodes[status]++
+	e.statusCodes[status]++
+	e.requests++
+	if result.IsVulnerable {
+		e.vulnerable++
+	}
+	s.bytes += int64(result.ContentLen)
+	e.bytes += int64(result.ContentLen)
+	s.latency.add(latency)
+	e.latency.add(latency)
+}
+
+// StatusCodes returns the responses recorded so far by status code
+func (s *Stats) StatusCodes() map[int]int64 {
+	s.detailMu.Lock()
+	defer s.detailMu.Unlock()
+	codes := make(map[int]int64, len(s.statusCodes))
+	for code, n := range s.statusCodes {
+		codes[code] = n
+	}
+	return codes
+}
+
+// Breakdown returns the responses recorded so far, endpoints with the
+// most requests first. It can be called while the scan runs.
+func (s *Stats) Breakdown() *Breakdown {
+	s.detailMu.Lock()
+	defer s.detailMu.Unlock()
+
+	b := &Breakdown{
+		StatusCodes: make(map[int]int64, len(s.statusCodes)),
+		Bytes:       s.bytes,
+		Latency:     s.latency.summary(),
+	}
+	for code, n := range s.statusCodes {
+		b.StatusCodes[code] = n
+	}
+	for endpoint, e := range s.endpoints {
+		codes := make(map[int]int64, len(e.statusCodes))
+		for code, n := range e.statusCodes {
+			codes[code] = n
+		}
+		b.Endpoints = append(b.Endpoints, EndpointStats{
+			Endpoint:    endpoint,
+			Requests:    e.requests,
+			Errors:      e.errors,
+			Vulnerable:  e.vulnerable,
+			StatusCodes: codes,
+			Bytes:       e.bytes,
+			Latency:     e.latency.summary(),
+		})
+	}
+	sort.Slice(b.Endpoints, func(i, j int) bool {
+		if b.Endpoints[i].Requests != b.Endpoints[j].Requests {
+			return b.Endpoints[i].Requests > b.Endpoints[j].Requests
+		}
+		return b.Endpoints[i].Endpoint < b.Endpoints[j].Endpoint
+	})
+	return b
+}
+
+// StatusLine formats status code counts most frequent first, e.g.
+// "404: 120, 200: 12, error: 3", for the live progress title
+func StatusLine(codes map[int]int64) string {
+	sorted := make([]int, 0, len(codes))
+	for code := range codes {
+		sorted = append(sorted, code)
+	}
+	slices.SortFunc(sorted, func(a, b int) int {
+		if codes[a] != codes[b] {
+			return int(codes[b] - codes[a])
+		}
+		return a - b
+	})
+	parts := make([]string, len(sorted))
+	for i, code := range sorted {
+		parts[i] = fmt.Sprintf("%s: %d", StatusLabel(code), codes[code])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// StatusLabel names a status code of a Breakdown, "error" for 0
+func StatusLabel(code int) string {
+	if code == 0 {
+		return "error"
+	}
+	return fmt.Sprint(code)
+}
@@ -0,0 +1,92 @@
+package fuzzer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"idorplus/pkg/client"
+
+	"github.com/pterm/pterm"
+)
+
+// BudgetReport describes a scan cut short by its budget, see client.Budget
+type BudgetReport struct {
+	Reason    string             `json:"reason"`
+	Requests  int64              `json:"requests"`
+	Elapsed   time.Duration      `json:"elapsed"`
+	Endpoints []EndpointCoverage `json:"endpoints"`
+	// Skipped are the checks that did not run at all, e.g. path bypass
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Untested returns how many jobs were not sent
+func (b *BudgetReport) Untested() int {
+	n := 0
+	for _, e := range b.Endpoints {
+		n += e.Untested
+	}
+	return n
+}
+
+// Print displays what was and wasn't tested
+func (b *BudgetReport) Print() {
+	pterm.DefaultSection.Println("Budget Exhausted")
+	pterm.Warning.Printf("Stopped after %d requests in %s: %s\n", b.Requests, b.Elapsed.Round(time.Second), b.Reason)
+
+	tableData := pterm.TableData{{"Endpoint", "Tested", "Untested"}}
+	for _, e := range b.Endpoints {
+		tableData = append(tableData, []string{e.Endpoint, fmt.Sprintf("%d", e.Tested), fmt.Sprintf("%d", e.Untested)})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	if len(b.Skipped) > 0 {
+		pterm.Warning.Printf("Not run: %s\n", strings.Join(b.Skipped, ", "))
+	}
+}
+
+// BudgetReport returns what the engine tested, nil while the client's
+// budget is not exhausted
+func (fe *FuzzEngine) BudgetReport() *BudgetReport {
+	budget := fe.Client.GetBudget()
+	reason := budget.Exhausted()
+	if reason == "" {
+		return nil
+	}
+
+	return &BudgetReport{Reason: reason, Requests: budget.Sent(), Elapsed: budget.Elapsed(), Endpoints: fe.Coverage()}
+}
+
+// budgetSpent reports whether the client's budget is exhausted
+func (fe *FuzzEngine) budgetSpent() bool {
+	return fe.Client.GetBudget().Exhausted() != ""
+}
+
+// isBudgetError reports whether a job failed because the budget ran out
+// before it was sent
+func isBudgetError(err error) bool {
+	return errors.Is(err, client.ErrBudgetExhausted)
+}
+
+// dropUntested drops the queued and set-aside jobs once the budget is
+// spent, counting them as untested
+func (fe *FuzzEngine) dropUntested() {
+	var dropped []*FuzzJob
+	fe.Queue.Drop(func(j *FuzzJob) bool {
+		dropped = append(dropped, j)
+		return true
+	})
+
+	fe.deferredMu.Lock()
+	held := len(fe.deferred)
+	dropped = append(dropped, fe.deferred...)
+	fe.deferred = nil
+	fe.deferredMu.Unlock()
+	fe.Queue.Unhold(held)
+
+	for _, j := range dropped {
+		fe.countCoverage(j, func(c *EndpointCoverage) { c.Untested++ })
+		fe.Stats.AddUntested(1)
+	}
+}
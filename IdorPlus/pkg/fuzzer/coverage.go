@@ -0,0 +1,131 @@
+package fuzzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// EndpointCoverage accounts for the jobs of an endpoint, so a scan whose
+// requests half failed doesn't look like a clean one. Every planned job
+// ends up tested, skipped, untested or, in an interrupted scan, missing.
+type EndpointCoverage struct {
+	Endpoint string `json:"endpoint"`
+	Planned  int    `json:"planned"`
+	// Tested jobs were sent; Failed of them got no response and Blocked
+	// a block page, so they say nothing about the ID
+	Tested  int `json:"tested"`
+	Failed  int `json:"failed,omitempty"`
+	Blocked int `json:"blocked,omitempty"`
+	// Skipped jobs were dropped once their endpoint had enough findings
+	// or was too slow, Untested ones once the budget ran out
+	Skipped  int `json:"skipped,omitempty"`
+	Untested int `json:"untested,omitempty"`
+	// Vulnerable are the responses the detector flagged
+	Vulnerable int `json:"vulnerable,omitempty"`
+}
+
+// Missing returns how many planned jobs were never accounted for, those
+// still queued when the scan was interrupted
+func (c EndpointCoverage) Missing() int {
+	return max(c.Planned-c.Tested-c.Skipped-c.Untested, 0)
+}
+
+// Gaps returns how many planned jobs didn't test their ID: failed,
+// blocked, untested and missing ones. Skipped jobs are left out on
+// purpose.
+func (c EndpointCoverage) Gaps() int {
+	return c.Failed + c.Blocked + c.Untested + c.Missing()
+}
+
+// CoverageGaps returns the planned jobs and the gaps of all endpoints
+func CoverageGaps(coverage []EndpointCoverage) (planned, gaps int) {
+	for _, c := range coverage {
+		planned += c.Planned
+		gaps += c.Gaps()
+	}
+	return planned, gaps
+}
+
+// Coverage returns the accounting of every endpoint, sorted by endpoint
+func (fe *FuzzEngine) Coverage() []EndpointCoverage {
+	fe.coverageMu.Lock()
+	defer fe.coverageMu.Unlock()
+	coverage := make([]EndpointCoverage, 0, len(fe.coverage))
+	for _, c := range fe.coverage {
+		coverage = append(coverage, *c)
+	}
+	slices.SortFunc(coverage, func(a, b EndpointCoverage) int { return strings.Compare(a.Endpoint, b.Endpoint) })
+	return coverage
+}
+
+// countCoverage updates the accounting of the job's endpoint
+func (fe *FuzzEngine) countCoverage(job *FuzzJob, update func(*EndpointCoverage)) {
+	fe.countEndpoint(jobEndpoint(job), update)
+}
+
+func (fe *FuzzEngine) countEndpoint(endpoint string, update func(*EndpointCoverage)) {
+	fe.coverageMu.Lock()
+	defer fe.coverageMu.Unlock()
+	c := fe.coverage[endpoint]
+	if c == nil {
+		c = &EndpointCoverage{Endpoint: endpoint}
+		fe.coverage[endpoint] = c
+	}
+	update(c)
+}
+
+// countResult accounts for a job that was processed
+func (fe *FuzzEngine) countResult(job *FuzzJob, result *FuzzResult) {
+	fe.countCoverage(job, func(c *EndpointCoverage) {
+		c.Tested++
+		switch {
+		case result.Error != nil:
+			c.Failed++
+		case result.Blocked:
+			c.Blocked++
+		case result.IsVulnerable:
+			c.Vulnerable++
+		}
+	})
+}
+
+// skipJobs counts n jobs of endpoint as skipped
+func (fe *FuzzEngine) skipJobs(endpoint string, n int) {
+	if n == 0 {
+		return
+	}
+	fe.countEndpoint(endpoint, func(c *EndpointCoverage) { c.Skipped += n })
+	fe.Stats.AddSkipped(n)
+}
+
+// PrintCoverage lists the endpoints with gaps, nothing when every planned
+// job was tested
+func PrintCoverage(coverage []EndpointCoverage) {
+	planned, gaps := CoverageGaps(coverage)
+	if gaps == 0 {
+		return
+	}
+	pterm.DefaultSection.Println("Coverage Gaps")
+	pterm.Warning.Printf("%d of %d planned jobs did not test their ID\n", gaps, planned)
+
+	tableData := pterm.TableData{{"Endpoint", "Planned", "Tested", "Failed", "Blocked", "Skipped", "Untested", "Missing"}}
+	for _, c := range coverage {
+		if c.Gaps() == 0 {
+			continue
+		}
+		tableData = append(tableData, []string{
+			c.Endpoint,
+			fmt.Sprint(c.Planned),
+			fmt.Sprint(c.Tested),
+			fmt.Sprint(c.Failed),
+			fmt.Sprint(c.Blocked),
+			fmt.Sprint(c.Skipped),
+			fmt.Sprint(c.Untested),
+			fmt.Sprint(c.Missing()),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
@@ -2,15 +2,25 @@ package fuzzer
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"idorplus/pkg/analyzer"
 	"idorplus/pkg/client"
 	"idorplus/pkg/detector"
+	"idorplus/pkg/utils"
 
 	"github.com/go-resty/resty/v2"
 )
 
+// PayloadPlaceholder is replaced with the job payload in headers, cookies and body
+const PayloadPlaceholder = "{ID}"
+
 // FuzzJob represents a single fuzzing task
 type FuzzJob struct {
 	ID      int
@@ -20,6 +30,88 @@ type FuzzJob struct {
 	Headers map[string]string
 	Body    string
 	Session string
+
+	// Priority orders the queue, higher first (see Priority* constants)
+	Priority int
+	// Endpoint groups jobs for early exit; defaults to URL
+	Endpoint string
+
+	// queuedAt is when the job was queued, for its trace
+	queuedAt time.Time
+
+	// Vars holds values for named placeholders ({NAME}) besides {ID}
+	Vars map[string]string
+}
+
+// Interpolate substitutes {ID} and any named placeholders in s
+func (j *FuzzJob) Interpolate(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	// null, arrays and objects replace a quoted "{ID}" whole, so a JSON
+	// body gets them as values rather than strings
+	if isJSONValue(j.Payload) {
+		s = strings.ReplaceAll(s, `"`+PayloadPlaceholder+`"`, j.Payload)
+	}
+	if IsOperatorPayload(j.Payload) {
+		s = strings.ReplaceAll(s, "="+PayloadPlaceholder, j.Payload)
+	}
+	s = strings.ReplaceAll(s, PayloadPlaceholder, j.Payload)
+	for name, value := range j.Vars {
+		s = strings.ReplaceAll(s, "{"+name+"}", value)
+	}
+	return s
+}
+
+// IsOperatorPayload reports whether a payload is a query operator such as
+// [$ne]=1. It replaces "={ID}" in query strings and form bodies, turning
+// id={ID} into id[$ne]=1.
+func IsOperatorPayload(payload string) bool {
+	return strings.HasPrefix(payload, "[$") && strings.Contains(payload, "]=")
+}
+
+// isJSONValue reports whether a payload is JSON null, an array or an object
+func isJSONValue(payload string) bool {
+	if payload == "null" {
+		return true
+	}
+	return (strings.HasPrefix(payload, "[") || strings.HasPrefix(payload, "{")) && json.Valid([]byte(payload))
+}
+
+// HTTPMethod is the method the job is sent with: its Method if that is a
+// known method, GET otherwise
+func (j *FuzzJob) HTTPMethod() string {
+	switch j.Method {
+	case "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS":
+		return j.Method
+	default:
+		return "GET"
+	}
+}
+
+// PrepareRequest fills in the job's headers, session cookies and body,
+// interpolating payload placeholders
+func PrepareRequest(c *client.SmartClient, req *resty.Request, job *FuzzJob) {
+	for k, v := range job.Headers {
+		req.SetHeader(k, job.Interpolate(v))
+	}
+
+	if job.Session != "" {
+		req.SetContext(client.WithSession(req.Context(), job.Session))
+		session := c.GetSessionManager().GetSession(job.Session)
+		if session != nil {
+			for _, cookie := range session.Cookies {
+				req.SetCookie(&http.Cookie{
+					Name:  cookie.Name,
+					Value: job.Interpolate(cookie.Value),
+				})
+			}
+		}
+	}
+
+	if job.Body != "" {
+		req.SetBody(job.Interpolate(job.Body))
+	}
 }
 
 // FuzzResult represents the result of a fuzzing task
@@ -29,20 +121,102 @@ type FuzzResult struct {
 	StatusCode   int
 	ContentLen   int
 	IsVulnerable bool
-	Evidence     string
-	Error        error
-	Duration     time.Duration
+	Confidence   int      // 0-100, see detector.Assessment
+	Reasons      []string // heuristics behind Confidence
+	Blocked      bool
+	BlockReason  string
+	// Evidence is the start of the body, at most SnippetSize bytes, see
+	// Body for all of it
+	Evidence string
+	// BodyDigest is the SHA-256 of the full body. BodyFile holds the body
+	// of a finding when the engine spools to BodyDir.
+	BodyDigest string
+	BodyFile   string
+	// File describes a binary body; Evidence then holds its summary
+	File *analyzer.FileInfo
+	// Redirects are the redirects followed to the response
+	Redirects []analyzer.Redirect
+	Error     error
+	Duration  time.Duration
 }
 
 // FuzzEngine is a production-grade fuzzing engine with proper concurrency handling
 type FuzzEngine struct {
 	Client     *client.SmartClient
 	Workers    int
-	Queue      chan *FuzzJob
+	Queue      *JobQueue
 	Results    chan *FuzzResult
 	Detector   *detector.IDORDetector
 	Stats      *Stats
-	MaxRetries int
+	MaxRetries int // times a job is sent again after a block page
+
+	// BlockCooldown is how long all workers pause after a WAF block page
+	BlockCooldown time.Duration
+
+	// Verdict, when set, decides whether a response is vulnerable. It sees
+	// the detector's verdict in result.IsVulnerable and may set Evidence.
+	Verdict func(result *FuzzResult) bool
+
+	// VictimSession, when set, names the session of the resource owners.
+	// Responses the detector flags are requested again with it, and one
+	// the victim gets as well gains confidence.
+	VictimSession string
+
+	// Confirmations is how many times a flagged job is sent again; it is
+	// only reported if every re-test is flagged too. Proxies and User-Agents
+	// rotate per request, so re-tests usually go out with another identity.
+	Confirmations int
+
+	// MaxFindings cancels the remaining jobs of an endpoint once it has this
+	// many confirmed findings. 0 means scan everything.
+	MaxFindings int
+
+	// MaxInFlight caps concurrent requests across all hosts, MaxPerHost per
+	// host. 0 means no limit beyond the worker count.
+	MaxInFlight int
+	MaxPerHost  int
+
+	// JobTimeout bounds each job, its retries included. 0 leaves only the
+	// client's per-request timeout.
+	JobTimeout time.Duration
+	// SlowP95 is the p95 latency above which an endpoint is run one job at
+	// a time, then skipped if it stays slow, see SlowEndpoints. 0 disables
+	// the check.
+	SlowP95 time.Duration
+
+	// BodyDir, when set, receives the full bodies of findings so results
+	// don't hold them in memory, see FuzzResult.BodyFile
+	BodyDir string
+
+	// Overflow is what workers do with results while Results is full
+	// because the consumer is slower than they are: OverflowBlock (the
+	// default), OverflowDrop or OverflowSpill, to a file in SpillDir or
+	// the temporary directory
+	Overflow string
+	SpillDir string
+
+	cooldownUntil int64 // unix nanos, accessed atomically
+
+	limiter    *HostLimiter
+	deferred   []*FuzzJob // jobs set aside because their host was saturated
+	deferredMu sync.Mutex
+
+	findings      map[string]int
+	doneEndpoints map[string]bool
+	findingsMu    sync.Mutex
+
+	latency   map[string]*endpointLatency
+	latencyMu sync.Mutex
+
+	// coverage accounts for the jobs of each endpoint, see Coverage
+	coverage   map[string]*EndpointCoverage
+	coverageMu sync.Mutex
+
+	spill     *spill
+	spillErr  error
+	spillOnce sync.Once
+	spillWarn sync.Once
+	closeOnce sync.Once
 
 	ctx     context.Context
 	cancel  context.CancelFunc
@@ -55,22 +229,27 @@ type FuzzEngine struct {
 func NewFuzzEngine(c *client.SmartClient, workers int, det *detector.IDORDetector) *FuzzEngine {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Buffer channels appropriately
+	// Buffer results appropriately
 	queueSize := workers * 10
 	if queueSize < 100 {
 		queueSize = 100
 	}
 
 	return &FuzzEngine{
-		Client:     c,
-		Workers:    workers,
-		Queue:      make(chan *FuzzJob, queueSize),
-		Results:    make(chan *FuzzResult, queueSize),
-		Detector:   det,
-		Stats:      NewStats(),
-		MaxRetries: 3,
-		ctx:        ctx,
-		cancel:     cancel,
+		Client:        c,
+		Workers:       workers,
+		Queue:         NewJobQueue(),
+		Results:       make(chan *FuzzResult, queueSize),
+		Detector:      det,
+		Stats:         NewStats(),
+		MaxRetries:    3,
+		BlockCooldown: 30 * time.Second,
+		findings:      make(map[string]int),
+		doneEndpoints: make(map[string]bool),
+		latency:       make(map[string]*endpointLatency),
+		coverage:      make(map[string]*EndpointCoverage),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 }
 
@@ -82,6 +261,7 @@ func (fe *FuzzEngine) Start() {
 		return
 	}
 	fe.started = true
+	fe.limiter = NewHostLimiter(fe.MaxInFlight, fe.MaxPerHost)
 	fe.mu.Unlock()
 
 	for i := 0; i < fe.Workers; i++ {
@@ -90,29 +270,23 @@ func (fe *FuzzEngine) Start() {
 	}
 }
 
-// Stop gracefully stops the engine
-func (fe *FuzzEngine) Stop() {
-	fe.cancel() // Signal all workers to stop
-
-	// Close queue to signal workers
+// InFlight returns the number of requests being sent, 0 before Start
+func (fe *FuzzEngine) InFlight() int {
 	fe.mu.Lock()
-	if fe.started {
-		close(fe.Queue)
-	}
+	limiter := fe.limiter
 	fe.mu.Unlock()
+	if limiter == nil {
+		return 0
+	}
+	return limiter.InFlight()
+}
 
-	// Wait for workers to finish
-	fe.wg.Wait()
-
-	// Drain any remaining results to prevent blocking
-	go func() {
-		for range fe.Results {
-			// Discard remaining results
-		}
-	}()
-
-	// Close results channel
-	close(fe.Results)
+// Stop cancels the engine, waits for its workers and closes Results.
+// Results not yet received are discarded.
+func (fe *FuzzEngine) Stop() {
+	fe.cancel()
+	fe.Queue.Close()
+	fe.WaitAndClose()
 }
 
 // Cancel immediately cancels all operations
@@ -125,21 +299,19 @@ func (fe *FuzzEngine) GetContext() context.Context {
 	return fe.ctx
 }
 
-// Submit adds a job to the queue
+// Submit adds a job to the queue, waiting while a bounded queue is full.
+// Returns false once the engine is cancelled or the queue closed.
 func (fe *FuzzEngine) Submit(job *FuzzJob) bool {
-	select {
-	case <-fe.ctx.Done():
+	if fe.ctx.Err() != nil {
 		return false
-	case fe.Queue <- job:
-		return true
 	}
+	fe.countCoverage(job, func(c *EndpointCoverage) { c.Planned++ })
+	return fe.Queue.PushContext(fe.ctx, job)
 }
 
 // CloseQueue closes the job queue (call after submitting all jobs)
 func (fe *FuzzEngine) CloseQueue() {
-	fe.mu.Lock()
-	defer fe.mu.Unlock()
-	close(fe.Queue)
+	fe.Queue.Close()
 }
 
 // worker processes jobs from the queue
@@ -147,32 +319,228 @@ func (fe *FuzzEngine) worker(id int) {
 	defer fe.wg.Done()
 
 	for {
-		select {
-		case <-fe.ctx.Done():
+		job, ok := fe.nextJob()
+		if !ok {
 			return
-		case job, ok := <-fe.Queue:
-			if !ok {
-				return
-			}
-			result := fe.processJob(job)
+		}
+
+		ctx, span := startJobSpan(fe.ctx, job)
+		result := fe.processJob(ctx, job)
+		endJobSpan(span, result)
+		latency, sent := resultLatency(result)
+		fe.finishEndpoint(job, latency, sent)
+		fe.limiter.Release(jobHost(job))
+		fe.release(result)
+		if isBudgetError(result.Error) {
+			fe.countCoverage(job, func(c *EndpointCoverage) { c.Untested++ })
+			fe.Stats.AddUntested(1)
+			continue
+		}
+		// Cut off by the scan stopping, the job is missing rather than failed
+		if result.Error != nil && fe.ctx.Err() != nil {
+			continue
+		}
+		fe.countResult(job, result)
+		if sent {
+			fe.Stats.Record(jobEndpoint(job), result, latency)
+		}
+		if result.IsVulnerable {
+			fe.recordFinding(job)
+		}
 
-			// Send result, but check for cancellation
-			select {
-			case <-fe.ctx.Done():
-				return
-			case fe.Results <- result:
+		if !fe.send(result) {
+			return
+		}
+	}
+}
+
+// send hands a result to the consumer, applying the Overflow policy while
+// Results is full. Returns false once the engine is cancelled.
+func (fe *FuzzEngine) send(result *FuzzResult) bool {
+	if !result.IsVulnerable && (fe.Overflow == OverflowDrop || fe.Overflow == OverflowSpill) {
+		select {
+		case fe.Results <- result:
+			return true
+		default:
+		}
+		if fe.Overflow == OverflowDrop {
+			fe.Stats.IncrementDropped()
+			return true
+		}
+		err := fe.spillResult(result)
+		if err == nil {
+			fe.Stats.IncrementSpilled()
+			return true
+		}
+		fe.spillWarn.Do(func() {
+			utils.Warning.Printf("Cannot spill results, waiting for the consumer instead: %v\n", err)
+		})
+	}
+
+	select {
+	case <-fe.ctx.Done():
+		return false
+	case fe.Results <- result:
+		return true
+	}
+}
+
+// nextJob returns the next job whose host has a free slot, holding that slot.
+// Jobs for saturated hosts are set aside so other targets keep moving.
+// Returns false once the queue is closed and drained or the engine is cancelled.
+func (fe *FuzzEngine) nextJob() (*FuzzJob, bool) {
+	for {
+		// Grab the channels first so a release or push in between is not missed
+		slotFreed := fe.limiter.Changed()
+		queued := fe.Queue.Changed()
+
+		// Keep draining rather than stop, jobs may still be submitted
+		if fe.budgetSpent() {
+			fe.dropUntested()
+		}
+
+		if job := fe.takeDeferred(); job != nil {
+			return job, true
+		}
+
+		job, closed := fe.Queue.TryPop()
+		if job != nil {
+			if fe.endpointDone(job) {
+				fe.skipJobs(jobEndpoint(job), 1)
+				continue
+			}
+			if fe.acquire(job) {
+				return job, true
 			}
+			// Set-aside jobs keep their room in the queue, so a host
+			// backing off can't make them pile up without bound
+			fe.deferredMu.Lock()
+			fe.deferred = append(fe.deferred, job)
+			fe.deferredMu.Unlock()
+			fe.Queue.Hold(1)
+			continue
+		}
+		if closed && fe.deferredCount() == 0 {
+			return nil, false
+		}
+
+		select {
+		case <-fe.ctx.Done():
+			return nil, false
+		case <-slotFreed:
+		case <-queued:
+		}
+	}
+}
+
+// recordFinding counts a confirmed finding and, once the endpoint reaches
+// MaxFindings, drops its remaining queued jobs
+func (fe *FuzzEngine) recordFinding(job *FuzzJob) {
+	if fe.MaxFindings <= 0 {
+		return
+	}
+
+	endpoint := jobEndpoint(job)
+	fe.findingsMu.Lock()
+	fe.findings[endpoint]++
+	if fe.findings[endpoint] < fe.MaxFindings || fe.doneEndpoints[endpoint] {
+		fe.findingsMu.Unlock()
+		return
+	}
+	fe.findingsMu.Unlock()
+
+	fe.skipJobs(endpoint, fe.dropEndpoint(endpoint))
+}
+
+// dropEndpoint drops the queued and set-aside jobs of an endpoint, and
+// those queued later, returning how many were dropped
+func (fe *FuzzEngine) dropEndpoint(endpoint string) int {
+	fe.findingsMu.Lock()
+	fe.doneEndpoints[endpoint] = true
+	fe.findingsMu.Unlock()
+
+	match := func(j *FuzzJob) bool { return jobEndpoint(j) == endpoint }
+	skipped := fe.Queue.Drop(match)
+
+	fe.deferredMu.Lock()
+	kept := fe.deferred[:0]
+	for _, j := range fe.deferred {
+		if !match(j) {
+			kept = append(kept, j)
+		}
+	}
+	released := len(fe.deferred) - len(kept)
+	fe.deferred = kept
+	fe.deferredMu.Unlock()
+	fe.Queue.Unhold(released)
+	return skipped + released
+}
+
+// endpointDone reports whether the job's endpoint already hit MaxFindings
+// or was skipped as slow
+func (fe *FuzzEngine) endpointDone(job *FuzzJob) bool {
+	fe.findingsMu.Lock()
+	defer fe.findingsMu.Unlock()
+	return fe.doneEndpoints[jobEndpoint(job)]
+}
+
+func jobEndpoint(job *FuzzJob) string {
+	if job.Endpoint != "" {
+		return job.Endpoint
+	}
+	return job.URL
+}
+
+// acquire reserves a slot of the job's endpoint and host
+func (fe *FuzzEngine) acquire(job *FuzzJob) bool {
+	if !fe.startEndpoint(job) {
+		return false
+	}
+	if fe.limiter.TryAcquire(jobHost(job)) {
+		return true
+	}
+	fe.finishEndpoint(job, 0, false)
+	return false
+}
+
+// takeDeferred removes and returns the oldest set-aside job whose host now has a free slot
+func (fe *FuzzEngine) takeDeferred() *FuzzJob {
+	fe.deferredMu.Lock()
+	defer fe.deferredMu.Unlock()
+
+	for i, job := range fe.deferred {
+		if fe.acquire(job) {
+			fe.deferred = append(fe.deferred[:i], fe.deferred[i+1:]...)
+			fe.Queue.Unhold(1)
+			return job
 		}
 	}
+	return nil
+}
+
+func (fe *FuzzEngine) deferredCount() int {
+	fe.deferredMu.Lock()
+	defer fe.deferredMu.Unlock()
+	return len(fe.deferred)
 }
 
-// processJob executes a single fuzzing job with retry logic
-func (fe *FuzzEngine) processJob(job *FuzzJob) *FuzzResult {
+// processJob executes a single fuzzing job with retry logic. parent carries
+// the job's span.
+func (fe *FuzzEngine) processJob(parent context.Context, job *FuzzJob) *FuzzResult {
 	startTime := time.Now()
 	var resp *resty.Response
 	var err error
+	var blockReason string
+
+	ctx := parent
+	if fe.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, fe.JobTimeout)
+		defer cancel()
+	}
 
-	// Retry loop with exponential backoff
+	// Sent again after a block page. Failed requests are retried by the
+	// client, retrying them here too would multiply the requests.
 	for attempt := 0; attempt <= fe.MaxRetries; attempt++ {
 		// Check for cancellation
 		select {
@@ -183,73 +551,60 @@ func (fe *FuzzEngine) processJob(job *FuzzJob) *FuzzResult {
 			}
 		default:
 		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
 
-		// Get request with rate limiting
-		req, reqErr := fe.Client.RequestWithRateLimit(fe.ctx)
-		if reqErr != nil {
-			if attempt == fe.MaxRetries {
-				fe.Stats.IncrementTotal()
-				fe.Stats.IncrementFailed()
-				return &FuzzResult{
-					Job:   job,
-					Error: reqErr,
-				}
+		// Honour any active WAF cooldown before sending
+		if cdErr := fe.waitForCooldown(); cdErr != nil {
+			return &FuzzResult{
+				Job:   job,
+				Error: cdErr,
 			}
-			time.Sleep(time.Duration(attempt+1) * time.Second)
-			continue
 		}
 
-		// Add custom headers
-		for k, v := range job.Headers {
-			req.SetHeader(k, v)
+		// Get request with rate limiting, fails only once ctx is done
+		var req *resty.Request
+		if req, err = fe.Client.RequestWithRateLimit(ctx); err != nil {
+			break
 		}
 
-		// Add session cookies if specified
-		if job.Session != "" {
-			session := fe.Client.GetSessionManager().GetSession(job.Session)
-			if session != nil {
-				for _, cookie := range session.Cookies {
-					req.SetCookie(cookie)
-				}
-			}
+		PrepareRequest(fe.Client, req, job)
+		req.EnableTrace()
+		span := startRoundTripSpan(ctx, job, attempt)
+		resp, err = req.Execute(job.HTTPMethod(), job.URL)
+		endRoundTripSpan(span, resp, err)
+		fe.recordConn(resp)
+		if isBudgetError(err) {
+			// Never sent, so neither a request nor a failure
+			return &FuzzResult{Job: job, Error: err}
 		}
 
-		// Add body if present
-		if job.Body != "" {
-			req.SetBody(job.Body)
-		}
-
-		// Execute request based on method
-		switch job.Method {
-		case "POST":
-			resp, err = req.Post(job.URL)
-		case "PUT":
-			resp, err = req.Put(job.URL)
-		case "DELETE":
-			resp, err = req.Delete(job.URL)
-		case "PATCH":
-			resp, err = req.Patch(job.URL)
-		case "HEAD":
-			resp, err = req.Head(job.URL)
-		case "OPTIONS":
-			resp, err = req.Options(job.URL)
-		default:
-			resp, err = req.Get(job.URL)
+		if err != nil {
+			// Already retried as the client's retry policy says
+			break
 		}
-
-		if err == nil {
+		blocked, reason := fe.Client.GetBlockPageDetector().Check(resp)
+		if !blocked {
+			blockReason = ""
 			break
 		}
 
-		// Exponential backoff for retries
-		if attempt < fe.MaxRetries {
-			time.Sleep(time.Duration(attempt+1) * time.Second)
+		// Pause everyone, then retry. Proxy and User-Agent rotate on
+		// every request, so the retry goes out with a fresh identity.
+		blockReason = reason
+		if fe.triggerCooldown() {
+			utils.Warning.Printf("Block page detected (%s), cooling down for %s\n", reason, fe.BlockCooldown)
 		}
 	}
 
 	fe.Stats.IncrementTotal()
 
 	if err != nil {
+		if ctx.Err() != nil && fe.ctx.Err() == nil {
+			err = fmt.Errorf("job timed out after %s: %w", fe.JobTimeout, err)
+		}
 		fe.Stats.IncrementFailed()
 		return &FuzzResult{
 			Job:      job,
@@ -258,26 +613,170 @@ func (fe *FuzzEngine) processJob(job *FuzzJob) *FuzzResult {
 		}
 	}
 
+	if blockReason != "" {
+		fe.Stats.IncrementBlocked()
+		return &FuzzResult{
+			Job:         job,
+			Response:    resp,
+			StatusCode:  resp.StatusCode(),
+			ContentLen:  analyzer.BodySize(resp),
+			Blocked:     true,
+			BlockReason: blockReason,
+			Duration:    time.Since(startTime),
+		}
+	}
+
 	fe.Stats.IncrementSuccess()
 
+	result := &FuzzResult{
+		Job:        job,
+		Response:   resp,
+		StatusCode: resp.StatusCode(),
+		ContentLen: analyzer.BodySize(resp),
+		Evidence:   snippet(resp.Body()),
+		Duration:   time.Since(startTime),
+	}
+	if file := analyzer.InspectFile(resp.Body()); file != nil {
+		result.File, result.Evidence = file, file.String()
+	}
+	result.Redirects = analyzer.RedirectChain(resp)
+
 	// Detect vulnerability
-	isVuln := false
+	detectCtx, detectSpan := tracer.Start(parent, "fuzz.detect")
+	defer detectSpan.End()
+	victim := false
 	if fe.Detector != nil {
-		isVuln = fe.Detector.Detect(resp)
+		assessment := fe.Detector.Assess(resp)
+		if assessment.Confidence > 0 && fe.VictimSession != "" && fe.victimSees(detectCtx, job, resp) {
+			fe.Detector.ConfirmVictim(assessment)
+			victim = true
+		}
+		result.IsVulnerable = fe.Detector.Vulnerable(assessment)
+		result.Confidence, result.Reasons = assessment.Confidence, assessment.Reasons
+	}
+	if fe.Verdict != nil {
+		result.IsVulnerable = fe.Verdict(result)
+	}
+
+	if result.IsVulnerable && fe.Confirmations > 0 {
+		if n := fe.reproduce(detectCtx, job, result.StatusCode, victim); n < fe.Confirmations {
+			result.IsVulnerable = false
+			result.Reasons = append(result.Reasons, fmt.Sprintf("Not reproducible: %d/%d re-tests flagged", n, fe.Confirmations))
+			fe.Stats.IncrementFlaky()
+			utils.Debug.Printf("Dropped %s: %d/%d re-tests flagged\n", job.URL, n, fe.Confirmations)
+		}
 	}
 
-	if isVuln {
+	if result.IsVulnerable {
 		fe.Stats.IncrementVuln()
 	}
+	return result
+}
 
-	return &FuzzResult{
-		Job:          job,
-		Response:     resp,
-		StatusCode:   resp.StatusCode(),
-		ContentLen:   len(resp.Body()),
-		IsVulnerable: isVuln,
-		Evidence:     string(resp.Body()),
-		Duration:     time.Since(startTime),
+// recordConn counts whether a response came over a new or a pooled
+// connection. Cached responses never touched one.
+func (fe *FuzzEngine) recordConn(resp *resty.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	if trace := resp.Request.TraceInfo(); trace.RemoteAddr != nil {
+		fe.Stats.IncrementConn(trace.IsConnReused)
+	}
+}
+
+// reproduce sends job up to Confirmations more times and returns how many
+// re-tests in a row were flagged with the same status. A victim
+// confirmation of the original response carries over. Re-tests go to the
+// server, a cached response would reproduce anything.
+func (fe *FuzzEngine) reproduce(ctx context.Context, job *FuzzJob, status int, victim bool) int {
+	ctx = client.WithoutCache(ctx)
+	for n := 0; n < fe.Confirmations; n++ {
+		req, err := fe.Client.RequestWithRateLimit(ctx)
+		if err != nil {
+			return n
+		}
+		PrepareRequest(fe.Client, req, job)
+		resp, err := req.Execute(job.HTTPMethod(), job.URL)
+		if err != nil || resp.StatusCode() != status {
+			return n
+		}
+		if blocked, _ := fe.Client.GetBlockPageDetector().Check(resp); blocked {
+			return n
+		}
+
+		retest := &FuzzResult{
+			Job:        job,
+			Response:   resp,
+			StatusCode: resp.StatusCode(),
+			ContentLen: analyzer.BodySize(resp),
+			Evidence:   snippet(resp.Body()),
+		}
+		if fe.Detector != nil {
+			assessment := fe.Detector.Assess(resp)
+			if victim && assessment.Confidence > 0 {
+				fe.Detector.ConfirmVictim(assessment)
+			}
+			retest.IsVulnerable = fe.Detector.Vulnerable(assessment)
+		}
+		if fe.Verdict != nil {
+			retest.IsVulnerable = fe.Verdict(retest)
+		}
+		if !retest.IsVulnerable {
+			return n
+		}
+	}
+	return fe.Confirmations
+}
+
+// victimSees requests a job again with the victim session and reports
+// whether the victim gets the same status and a body of about the same size
+func (fe *FuzzEngine) victimSees(ctx context.Context, job *FuzzJob, resp *resty.Response) bool {
+	req, err := fe.Client.RequestWithRateLimit(ctx)
+	if err != nil {
+		return false
+	}
+	victimJob := *job
+	victimJob.Session = fe.VictimSession
+	PrepareRequest(fe.Client, req, &victimJob)
+	victimResp, err := req.Execute(victimJob.HTTPMethod(), victimJob.URL)
+	if err != nil || victimResp.StatusCode() != resp.StatusCode() {
+		return false
+	}
+	return analyzer.NewResponseComparator(victimResp).Compare(resp).BodySimilarity >= 0.9
+}
+
+// triggerCooldown pauses all workers for BlockCooldown.
+// Returns true if this call started a new cooldown window.
+func (fe *FuzzEngine) triggerCooldown() bool {
+	if fe.BlockCooldown <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	until := now.Add(fe.BlockCooldown).UnixNano()
+	for {
+		cur := atomic.LoadInt64(&fe.cooldownUntil)
+		if cur >= until {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&fe.cooldownUntil, cur, until) {
+			return cur < now.UnixNano()
+		}
+	}
+}
+
+// waitForCooldown blocks until the current cooldown window has passed
+func (fe *FuzzEngine) waitForCooldown() error {
+	wait := time.Until(time.Unix(0, atomic.LoadInt64(&fe.cooldownUntil)))
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-fe.ctx.Done():
+		return fe.ctx.Err()
 	}
 }
 
@@ -286,9 +785,14 @@ func (fe *FuzzEngine) WaitForCompletion() {
 	fe.wg.Wait()
 }
 
-// WaitAndClose waits for all workers to finish and closes the Results channel
-// This should be called after CloseQueue() to properly signal completion
+// WaitAndClose waits for the workers to finish and the spilled results to
+// be sent, then closes Results. Call it after CloseQueue, or Cancel to stop
+// early; the consumer must keep reading Results until it is closed unless
+// the engine is cancelled. Calling it again does nothing.
 func (fe *FuzzEngine) WaitAndClose() {
 	fe.wg.Wait()
-	close(fe.Results)
+	fe.closeOnce.Do(func() {
+		fe.closeSpill()
+		close(fe.Results)
+	})
 }
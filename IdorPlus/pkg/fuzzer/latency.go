@@ -0,0 +1,145 @@
+package fuzzer
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"idorplus/pkg/utils"
+)
+
+// latencyWindow is how many recent job durations of an endpoint its p95
+// is computed from, minLatencySamples how many it takes to judge it
+const (
+	latencyWindow     = 50
+	minLatencySamples = 10
+)
+
+// SlowEndpoint is an endpoint whose p95 latency exceeded
+// FuzzEngine.SlowP95. It is first limited to one job at a time and skipped
+// if it stays slow.
+type SlowEndpoint struct {
+	Endpoint string        `json:"endpoint"`
+	P95      time.Duration `json:"p95"`
+	Samples  int           `json:"samples"`
+	// Skipped is the number of jobs dropped, 0 while it is only limited
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// endpointLatency tracks the recent durations and running jobs of an endpoint
+type endpointLatency struct {
+	recent   []time.Duration
+	next     int
+	samples  int
+	inFlight int
+	// serial limits the endpoint to one job at a time, dropped is set once
+	// its jobs are skipped
+	serial  bool
+	dropped bool
+	slow    *SlowEndpoint
+}
+
+func (l *endpointLatency) add(d time.Duration) {
+	if len(l.recent) < latencyWindow {
+		l.recent = append(l.recent, d)
+	} else {
+		l.recent[l.next] = d
+		l.next = (l.next + 1) % latencyWindow
+	}
+	l.samples++
+}
+
+func (l *endpointLatency) p95() time.Duration {
+	sorted := slices.Clone(l.recent)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)*95+99)/100-1]
+}
+
+// resultLatency is how long the server took to answer a job, without rate
+// limiting and retry backoff. Jobs that failed count whole, so timeouts
+// weigh in. It reports false for jobs never sent.
+func resultLatency(result *FuzzResult) (time.Duration, bool) {
+	if result.Response != nil {
+		return result.Response.Time(), true
+	}
+	return result.Duration, result.Duration > 0
+}
+
+// startEndpoint reserves a job slot of the job's endpoint. It fails while
+// a slow endpoint already runs a job.
+func (fe *FuzzEngine) startEndpoint(job *FuzzJob) bool {
+	if fe.SlowP95 <= 0 {
+		return true
+	}
+	fe.latencyMu.Lock()
+	defer fe.latencyMu.Unlock()
+
+	l := fe.latency[jobEndpoint(job)]
+	if l == nil {
+		l = &endpointLatency{}
+		fe.latency[jobEndpoint(job)] = l
+	}
+	if l.serial && l.inFlight > 0 {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// finishEndpoint releases the slot of startEndpoint and records how long
+// the job took. An endpoint whose p95 exceeds SlowP95 is limited to one job
+// at a time, and its remaining jobs are dropped if it stays slow.
+func (fe *FuzzEngine) finishEndpoint(job *FuzzJob, d time.Duration, sent bool) {
+	if fe.SlowP95 <= 0 {
+		return
+	}
+	endpoint := jobEndpoint(job)
+	fe.latencyMu.Lock()
+	l := fe.latency[endpoint]
+	l.inFlight--
+	if !sent || l.dropped {
+		fe.latencyMu.Unlock()
+		return
+	}
+	l.add(d)
+	if l.samples < minLatencySamples || l.p95() <= fe.SlowP95 {
+		fe.latencyMu.Unlock()
+		return
+	}
+
+	p95 := l.p95()
+	if !l.serial {
+		// Judge it again on durations measured one at a time
+		l.serial = true
+		l.slow = &SlowEndpoint{Endpoint: endpoint, P95: p95, Samples: l.samples}
+		l.recent, l.next, l.samples = nil, 0, 0
+		fe.latencyMu.Unlock()
+		utils.Warning.Printf("%s is slow (p95 %s), sending its jobs one at a time\n", endpoint, p95.Round(time.Millisecond))
+		return
+	}
+	l.slow.P95, l.slow.Samples = p95, l.slow.Samples+l.samples
+	l.dropped = true
+	fe.latencyMu.Unlock()
+
+	skipped := fe.dropEndpoint(endpoint)
+	fe.skipJobs(endpoint, skipped)
+	fe.latencyMu.Lock()
+	l.slow.Skipped = skipped
+	fe.latencyMu.Unlock()
+	utils.Warning.Printf("%s is still slow (p95 %s), skipping its %d remaining jobs\n", endpoint, p95.Round(time.Millisecond), skipped)
+}
+
+// SlowEndpoints returns the endpoints found slow, see SlowP95
+func (fe *FuzzEngine) SlowEndpoints() []SlowEndpoint {
+	fe.latencyMu.Lock()
+	defer fe.latencyMu.Unlock()
+
+	var slow []SlowEndpoint
+	for _, l := range fe.latency {
+		if l.slow != nil {
+			slow = append(slow, *l.slow)
+		}
+	}
+	slices.SortFunc(slow, func(a, b SlowEndpoint) int { return strings.Compare(a.Endpoint, b.Endpoint) })
+	return slow
+}
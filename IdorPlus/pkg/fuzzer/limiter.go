@@ -0,0 +1,83 @@
+package fuzzer
+
+import (
+	"net/url"
+	"sync"
+)
+
+// HostLimiter caps concurrent requests globally and per host so one small
+// API is not hammered while jobs for other targets wait behind it.
+// A limit of 0 means unlimited.
+type HostLimiter struct {
+	maxInFlight int
+	maxPerHost  int
+
+	mu       sync.Mutex
+	inFlight int
+	perHost  map[string]int
+	changed  chan struct{}
+}
+
+// NewHostLimiter creates a limiter
+func NewHostLimiter(maxInFlight, maxPerHost int) *HostLimiter {
+	return &HostLimiter{
+		maxInFlight: maxInFlight,
+		maxPerHost:  maxPerHost,
+		perHost:     make(map[string]int),
+		changed:     make(chan struct{}),
+	}
+}
+
+// TryAcquire takes a slot for host if both the global and host limits allow it
+func (hl *HostLimiter) TryAcquire(host string) bool {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	if hl.maxInFlight > 0 && hl.inFlight >= hl.maxInFlight {
+		return false
+	}
+	if hl.maxPerHost > 0 && hl.perHost[host] >= hl.maxPerHost {
+		return false
+	}
+
+	hl.inFlight++
+	hl.perHost[host]++
+	return true
+}
+
+// Release frees a slot taken by TryAcquire and wakes up waiters
+func (hl *HostLimiter) Release(host string) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	hl.inFlight--
+	if hl.perHost[host]--; hl.perHost[host] <= 0 {
+		delete(hl.perHost, host)
+	}
+
+	close(hl.changed)
+	hl.changed = make(chan struct{})
+}
+
+// Changed returns a channel that is closed the next time a slot is released
+func (hl *HostLimiter) Changed() <-chan struct{} {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	return hl.changed
+}
+
+// InFlight returns the number of requests currently holding a slot
+func (hl *HostLimiter) InFlight() int {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	return hl.inFlight
+}
+
+// jobHost returns the host a job is sent to, used as the per-host limiter key
+func jobHost(job *FuzzJob) string {
+	u, err := url.Parse(job.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
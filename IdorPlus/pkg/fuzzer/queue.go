@@ -0,0 +1,196 @@
+package fuzzer
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Job priorities, higher runs first
+const (
+	PrioritySynthetic = 0   // generated sequences
+	PriorityWordlist  = 50  // user supplied lists
+	PriorityHarvested = 100 // real IDs: the target's, known or seen in responses
+)
+
+// JobQueue is a priority queue of jobs. Jobs with equal priority are served
+// in submission order. It is unbounded unless given a limit, see SetLimit.
+type JobQueue struct {
+	mu     sync.Mutex
+	items  jobHeap
+	seq    uint64
+	closed bool
+	limit  int
+	// held are jobs taken from the queue and set aside by the consumer,
+	// counted toward the limit until released
+	held    int
+	changed chan struct{}
+	freed   chan struct{}
+}
+
+// NewJobQueue creates an empty queue
+func NewJobQueue() *JobQueue {
+	return &JobQueue{changed: make(chan struct{}), freed: make(chan struct{})}
+}
+
+// SetLimit bounds the queue to n jobs, Push then waits for room; 0 means
+// unbounded. Priorities only order the jobs within the limit.
+func (q *JobQueue) SetLimit(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limit = n
+	q.free()
+}
+
+// Push adds a job, waiting while the queue is full. Returns false if the
+// queue is closed.
+func (q *JobQueue) Push(job *FuzzJob) bool {
+	return q.PushContext(context.Background(), job)
+}
+
+// PushContext is Push that also gives up, returning false, once ctx is done
+func (q *JobQueue) PushContext(ctx context.Context, job *FuzzJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.limit > 0 && len(q.items)+q.held >= q.limit && !q.closed {
+		freed := q.freed
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			return false
+		case <-freed:
+		}
+		q.mu.Lock()
+	}
+	if q.closed {
+		return false
+	}
+	job.queuedAt = time.Now()
+	heap.Push(&q.items, &queuedJob{job: job, seq: q.seq})
+	q.seq++
+	q.notify()
+	return true
+}
+
+// TryPop returns the highest priority job, or nil if the queue is empty.
+// closed reports whether no more jobs will ever be returned.
+func (q *JobQueue) TryPop() (job *FuzzJob, closed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, q.closed
+	}
+	q.free()
+	return heap.Pop(&q.items).(*queuedJob).job, false
+}
+
+// Hold counts n jobs taken from the queue but set aside, e.g. for a busy
+// host, toward the limit
+func (q *JobQueue) Hold(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.held += n
+}
+
+// Unhold gives the room of n held jobs back, once they run or are dropped
+func (q *JobQueue) Unhold(n int) {
+	if n <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.held -= n
+	q.free()
+}
+
+// Drop removes all queued jobs matching fn and returns how many were removed
+func (q *JobQueue) Drop(fn func(*FuzzJob) bool) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.items[:0]
+	for _, item := range q.items {
+		if !fn(item.job) {
+			kept = append(kept, item)
+		}
+	}
+	dropped := len(q.items) - len(kept)
+	q.items = kept
+	heap.Init(&q.items)
+	if dropped > 0 {
+		q.free()
+	}
+	return dropped
+}
+
+// Close marks the queue as complete; queued jobs are still returned
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		q.notify()
+		q.free()
+	}
+}
+
+// Len returns the number of queued jobs
+func (q *JobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Changed returns a channel that is closed on the next Push or Close
+func (q *JobQueue) Changed() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.changed
+}
+
+// notify wakes up waiters (caller must hold the lock)
+func (q *JobQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// free wakes up pushes waiting for room (caller must hold the lock)
+func (q *JobQueue) free() {
+	close(q.freed)
+	q.freed = make(chan struct{})
+}
+
+type queuedJob struct {
+	job *FuzzJob
+	seq uint64
+}
+
+// jobHeap implements heap.Interface ordered by priority, then submission order
+type jobHeap []*queuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].job.Priority != h[j].job.Priority {
+		return h[i].job.Priority > h[j].job.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x any) { *h = append(*h, x.(*queuedJob)) }
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,134 @@
+package fuzzer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"idorplus/pkg/utils"
+)
+
+// What a worker does with a result while Results is full, see
+// FuzzEngine.Overflow. Findings always wait for the consumer.
+const (
+	OverflowBlock = "block" // wait for the consumer
+	OverflowDrop  = "drop"  // discard it, counted in Stats.DroppedCount
+	OverflowSpill = "spill" // write it to a file, sent once there is room
+)
+
+// spill is an on-disk FIFO of results that found Results full. A goroutine
+// sends them on as the consumer catches up, so a slow consumer costs disk
+// rather than memory.
+type spill struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	path    string
+	w       *os.File
+	enc     *json.Encoder
+	pending int
+	closed  bool
+	done    chan struct{}
+}
+
+// spilledResult is a result as written to the spill file. Errors are kept
+// as their message.
+type spilledResult struct {
+	*FuzzResult
+	Err string `json:",omitempty"`
+}
+
+// spillResult writes a result to the spill file, creating it and starting
+// the goroutine that sends spilled results on first use
+func (fe *FuzzEngine) spillResult(result *FuzzResult) error {
+	fe.spillOnce.Do(func() { fe.spill, fe.spillErr = fe.openSpill() })
+	if fe.spillErr != nil {
+		return fe.spillErr
+	}
+
+	s := fe.spill
+	r := *result
+	r.Response, r.Error = nil, nil
+	rec := spilledResult{FuzzResult: &r}
+	if result.Error != nil {
+		rec.Err = result.Error.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(rec); err != nil {
+		return err
+	}
+	s.pending++
+	s.cond.Signal()
+	return nil
+}
+
+func (fe *FuzzEngine) openSpill() (*spill, error) {
+	w, err := os.CreateTemp(fe.SpillDir, "idorplus-spill-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	r, err := os.Open(w.Name())
+	if err != nil {
+		w.Close()
+		os.Remove(w.Name())
+		return nil, err
+	}
+	s := &spill{path: w.Name(), w: w, enc: json.NewEncoder(w), done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	go fe.drainSpill(s, r)
+	return s, nil
+}
+
+// drainSpill sends spilled results on in the order they were written until
+// the spill is closed and empty, or the engine is cancelled
+func (fe *FuzzEngine) drainSpill(s *spill, r *os.File) {
+	defer close(s.done)
+	defer r.Close()
+	// Only whole records are counted in pending, so the decoder never
+	// reads past the end of the file
+	dec := json.NewDecoder(r)
+	for {
+		s.mu.Lock()
+		for s.pending == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.pending == 0 {
+			s.mu.Unlock()
+			return
+		}
+		s.pending--
+		s.mu.Unlock()
+
+		var rec spilledResult
+		if err := dec.Decode(&rec); err != nil {
+			utils.Warning.Printf("Spilled results lost: %v\n", err)
+			return
+		}
+		if rec.Err != "" {
+			rec.FuzzResult.Error = errors.New(rec.Err)
+		}
+		select {
+		case <-fe.ctx.Done():
+			return
+		case fe.Results <- rec.FuzzResult:
+		}
+	}
+}
+
+// closeSpill waits until the spilled results are sent, or dropped if the
+// engine was cancelled, and removes the spill file
+func (fe *FuzzEngine) closeSpill() {
+	s := fe.spill
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	<-s.done
+	s.w.Close()
+	os.Remove(s.path)
+}
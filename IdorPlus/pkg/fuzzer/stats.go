@@ -2,6 +2,8 @@ package fuzzer
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,15 +11,37 @@ import (
 	"github.com/pterm/pterm"
 )
 
+// Connection reuse below lowReuse is reported once lowReuseMinConns
+// connections were opened
+const (
+	lowReuse         = 0.5
+	lowReuseMinConns = 100
+)
+
 // Stats tracks scanning statistics in real-time
 type Stats struct {
 	TotalRequests   int64
 	SuccessCount    int64
 	FailedCount     int64
 	VulnCount       int64
+	BlockedCount    int64
+	SkippedCount    int64
+	UntestedCount   int64
+	FlakyCount      int64
+	DroppedCount    int64
+	SpilledCount    int64
+	ConnsOpened     int64
+	ConnsReused     int64
 	StartTime       time.Time
 	LastRequestTime time.Time
 	mu              sync.RWMutex
+
+	// Responses by status code and endpoint, see Record and Breakdown
+	statusCodes map[int]int64
+	endpoints   map[string]*endpointDetail
+	bytes       int64
+	latency     latencyHistogram
+	detailMu    sync.Mutex
 }
 
 // NewStats creates a new stats tracker
@@ -51,6 +75,59 @@ func (s *Stats) IncrementVuln() {
 	atomic.AddInt64(&s.VulnCount, 1)
 }
 
+// IncrementBlocked increments the count of jobs answered by a WAF block page
+func (s *Stats) IncrementBlocked() {
+	atomic.AddInt64(&s.BlockedCount, 1)
+}
+
+// AddSkipped counts jobs cancelled because their endpoint already hit the
+// finding limit or stayed too slow
+func (s *Stats) AddSkipped(n int) {
+	atomic.AddInt64(&s.SkippedCount, int64(n))
+}
+
+// AddUntested counts jobs not sent because the scan's budget ran out
+func (s *Stats) AddUntested(n int) {
+	atomic.AddInt64(&s.UntestedCount, int64(n))
+}
+
+// IncrementFlaky counts flagged responses that did not reproduce when re-tested
+func (s *Stats) IncrementFlaky() {
+	atomic.AddInt64(&s.FlakyCount, 1)
+}
+
+// IncrementDropped counts results discarded because the consumer was too
+// slow, see OverflowDrop
+func (s *Stats) IncrementDropped() {
+	atomic.AddInt64(&s.DroppedCount, 1)
+}
+
+// IncrementSpilled counts results written to disk because the consumer
+// was too slow, see OverflowSpill
+func (s *Stats) IncrementSpilled() {
+	atomic.AddInt64(&s.SpilledCount, 1)
+}
+
+// GetDroppedCount returns the count of results dropped for a slow consumer
+func (s *Stats) GetDroppedCount() int64 {
+	return atomic.LoadInt64(&s.DroppedCount)
+}
+
+// GetSpilledCount returns the count of results spilled to disk
+func (s *Stats) GetSpilledCount() int64 {
+	return atomic.LoadInt64(&s.SpilledCount)
+}
+
+// IncrementConn counts the connection a request went out on: a new one
+// or one reused from the keep-alive pool
+func (s *Stats) IncrementConn(reused bool) {
+	if reused {
+		atomic.AddInt64(&s.ConnsReused, 1)
+	} else {
+		atomic.AddInt64(&s.ConnsOpened, 1)
+	}
+}
+
 // GetRPS calculates requests per second
 func (s *Stats) GetRPS() float64 {
 	elapsed := time.Since(s.StartTime).Seconds()
@@ -85,12 +162,49 @@ func (s *Stats) GetFailedCount() int64 {
 	return atomic.LoadInt64(&s.FailedCount)
 }
 
+// GetBlockedCount returns blocked count
+func (s *Stats) GetBlockedCount() int64 {
+	return atomic.LoadInt64(&s.BlockedCount)
+}
+
+// GetSkippedCount returns skipped job count
+func (s *Stats) GetSkippedCount() int64 {
+	return atomic.LoadInt64(&s.SkippedCount)
+}
+
+// GetUntestedCount returns the count of jobs left untested by the budget
+func (s *Stats) GetUntestedCount() int64 {
+	return atomic.LoadInt64(&s.UntestedCount)
+}
+
+// GetFlakyCount returns the count of findings dropped as not reproducible
+func (s *Stats) GetFlakyCount() int64 {
+	return atomic.LoadInt64(&s.FlakyCount)
+}
+
+// GetConnReuse returns the share (0-1) of requests sent on a reused
+// connection, 0 before any request
+func (s *Stats) GetConnReuse() float64 {
+	opened := atomic.LoadInt64(&s.ConnsOpened)
+	reused := atomic.LoadInt64(&s.ConnsReused)
+	if opened+reused == 0 {
+		return 0
+	}
+	return float64(reused) / float64(opened+reused)
+}
+
 // Print displays stats in a formatted table
 func (s *Stats) Print() {
 	total := atomic.LoadInt64(&s.TotalRequests)
 	success := atomic.LoadInt64(&s.SuccessCount)
 	failed := atomic.LoadInt64(&s.FailedCount)
 	vulns := atomic.LoadInt64(&s.VulnCount)
+	blocked := atomic.LoadInt64(&s.BlockedCount)
+	skipped := atomic.LoadInt64(&s.SkippedCount)
+	untested := atomic.LoadInt64(&s.UntestedCount)
+	flaky := atomic.LoadInt64(&s.FlakyCount)
+	opened := atomic.LoadInt64(&s.ConnsOpened)
+	detail := s.Breakdown()
 
 	pterm.DefaultSection.Println("Scan Statistics")
 
@@ -99,12 +213,97 @@ func (s *Stats) Print() {
 		{"Total Requests", fmt.Sprintf("%d", total)},
 		{"Successful", fmt.Sprintf("%d", success)},
 		{"Failed", fmt.Sprintf("%d", failed)},
+		{"Blocked (WAF)", fmt.Sprintf("%d", blocked)},
+		{"Skipped (early exit)", fmt.Sprintf("%d", skipped)},
+		{"Untested (budget)", fmt.Sprintf("%d", untested)},
+		{"Not reproducible", fmt.Sprintf("%d", flaky)},
 		{"Vulnerabilities", pterm.LightRed(fmt.Sprintf("%d", vulns))},
 		{"RPS", fmt.Sprintf("%.2f", s.GetRPS())},
+		{"Connections opened", fmt.Sprintf("%d", opened)},
+		{"Connection reuse", fmt.Sprintf("%.0f%%", s.GetConnReuse()*100)},
+		{"Received", FormatBytes(detail.Bytes)},
+		{"Latency", detail.Latency.String()},
 		{"Elapsed", s.GetElapsed().Round(time.Second).String()},
 	}
 
+	if dropped := atomic.LoadInt64(&s.DroppedCount); dropped > 0 {
+		tableData = append(tableData, []string{"Dropped (slow consumer)", fmt.Sprintf("%d", dropped)})
+	}
+	if spilled := atomic.LoadInt64(&s.SpilledCount); spilled > 0 {
+		tableData = append(tableData, []string{"Spilled to disk", fmt.Sprintf("%d", spilled)})
+	}
+
 	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	printBreakdown(detail)
+
+	// Each new connection ties up an ephemeral port for TIME_WAIT, so a
+	// pool that doesn't keep up is what makes RPS collapse
+	if opened >= lowReuseMinConns && s.GetConnReuse() < lowReuse {
+		pterm.Warning.Printf("Only %.0f%% of requests reused a connection; raise scanner.max_idle_conns_per_host or keep keep-alives enabled\n", s.GetConnReuse()*100)
+	}
+}
+
+// maxPrintedEndpoints caps the endpoints listed by Print, busiest first
+const maxPrintedEndpoints = 10
+
+// printBreakdown prints the status code histogram and the busiest endpoints
+func printBreakdown(b *Breakdown) {
+	if len(b.StatusCodes) == 0 {
+		return
+	}
+	var total, most int64
+	for _, n := range b.StatusCodes {
+		total += n
+		most = max(most, n)
+	}
+	codes := make([]int, 0, len(b.StatusCodes))
+	for code := range b.StatusCodes {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+
+	pterm.DefaultSection.WithLevel(2).Println("Status Codes")
+	for _, code := range codes {
+		n := b.StatusCodes[code]
+		bar := strings.Repeat("█", max(1, int(n*30/most)))
+		pterm.Printf("  %-6s %s %d (%.1f%%)\n", StatusLabel(code), bar, n, float64(n)*100/float64(total))
+	}
+
+	if len(b.Endpoints) < 2 {
+		return
+	}
+	pterm.DefaultSection.WithLevel(2).Println("Endpoints")
+	tableData := pterm.TableData{{"Endpoint", "Requests", "Errors", "Vulnerable", "Status codes", "p95", "Received"}}
+	for i, e := range b.Endpoints {
+		if i == maxPrintedEndpoints {
+			tableData = append(tableData, []string{fmt.Sprintf("%d more in the report", len(b.Endpoints)-i), "", "", "", "", "", ""})
+			break
+		}
+		tableData = append(tableData, []string{
+			e.Endpoint,
+			fmt.Sprint(e.Requests),
+			fmt.Sprint(e.Errors),
+			fmt.Sprint(e.Vulnerable),
+			StatusLine(e.StatusCodes),
+			e.Latency.P95.Round(time.Millisecond).String(),
+			FormatBytes(e.Bytes),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// FormatBytes formats a byte count for humans, e.g. 1.5 MB
+func FormatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "kMGT"[prefix])
 }
 
 // PrintSummary prints a compact summary
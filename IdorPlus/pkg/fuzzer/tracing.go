@@ -0,0 +1,91 @@
+package fuzzer
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces jobs, see the tracing package. Spans cost next to nothing
+// until a tracer provider is installed.
+var tracer = otel.Tracer("idorplus/pkg/fuzzer")
+
+// startJobSpan starts the span of a job, backdated to when it was queued,
+// with a child span for the time it waited in the queue
+func startJobSpan(ctx context.Context, job *FuzzJob) (context.Context, trace.Span) {
+	start := job.queuedAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	ctx, span := tracer.Start(ctx, "fuzz.job", trace.WithTimestamp(start))
+	if !span.IsRecording() {
+		return ctx, span
+	}
+	span.SetAttributes(
+		attribute.String("http.request.method", job.HTTPMethod()),
+		attribute.String("url.full", job.URL),
+		attribute.String("idorplus.endpoint", jobEndpoint(job)),
+		attribute.String("idorplus.payload", job.Payload),
+		attribute.String("idorplus.session", job.Session),
+		attribute.Int("idorplus.priority", job.Priority),
+	)
+	if !job.queuedAt.IsZero() {
+		_, wait := tracer.Start(ctx, "fuzz.queue_wait", trace.WithTimestamp(start))
+		wait.End()
+	}
+	return ctx, span
+}
+
+// endJobSpan records the outcome of a job on its span and ends it
+func endJobSpan(span trace.Span, result *FuzzResult) {
+	defer span.End()
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", result.StatusCode),
+		attribute.Bool("idorplus.vulnerable", result.IsVulnerable),
+		attribute.Int("idorplus.confidence", result.Confidence),
+		attribute.Bool("idorplus.blocked", result.Blocked),
+	)
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		span.SetStatus(codes.Error, result.Error.Error())
+	}
+}
+
+// startRoundTripSpan starts the span of one attempt at sending a job
+func startRoundTripSpan(ctx context.Context, job *FuzzJob, attempt int) trace.Span {
+	_, span := tracer.Start(ctx, "http.request", trace.WithSpanKind(trace.SpanKindClient))
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.String("http.request.method", job.HTTPMethod()),
+			attribute.String("url.full", job.URL),
+			attribute.Int("http.request.resend_count", attempt),
+		)
+	}
+	return span
+}
+
+// endRoundTripSpan records the response or error of an attempt and ends
+// its span
+func endRoundTripSpan(span trace.Span, resp *resty.Response, err error) {
+	defer span.End()
+	if !span.IsRecording() {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", resp.StatusCode()),
+		attribute.Int("http.response.body.size", len(resp.Body())),
+	)
+}
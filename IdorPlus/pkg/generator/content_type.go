@@ -0,0 +1,220 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Body formats understood by the content-type shifter
+const (
+	FormatJSON      = "json"
+	FormatForm      = "form"
+	FormatXML       = "xml"
+	FormatMultipart = "multipart"
+)
+
+// BodyField is a single top-level request body parameter
+type BodyField struct {
+	Name  string
+	Value string
+}
+
+// ShiftedBody is a request body re-encoded in another format
+type ShiftedBody struct {
+	Format      string
+	ContentType string
+	Body        string
+}
+
+// DetectBodyFormat guesses the format of a request body from its first character
+func DetectBodyFormat(body string) string {
+	trimmed := strings.TrimSpace(body)
+	switch {
+	case strings.HasPrefix(trimmed, "{"):
+		return FormatJSON
+	case strings.HasPrefix(trimmed, "<"):
+		return FormatXML
+	default:
+		return FormatForm
+	}
+}
+
+// ContentTypeFor returns the Content-Type header for a non-multipart format
+func ContentTypeFor(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatXML:
+		return "application/xml"
+	default:
+		return "application/x-www-form-urlencoded"
+	}
+}
+
+// ParseBodyFields extracts top-level fields from a JSON object, flat XML
+// document or urlencoded form. Nested JSON values are kept as raw JSON.
+func ParseBodyFields(body, format string) ([]BodyField, error) {
+	var fields []BodyField
+
+	switch format {
+	case FormatJSON:
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(body), &obj); err != nil {
+			return nil, err
+		}
+		for name, raw := range obj {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				s = string(raw)
+			}
+			fields = append(fields, BodyField{Name: name, Value: s})
+		}
+
+	case FormatXML:
+		dec := xml.NewDecoder(strings.NewReader(body))
+		depth := 0
+		var name string
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				depth++
+				if depth == 2 {
+					name = t.Name.Local
+				}
+			case xml.CharData:
+				if value := strings.TrimSpace(string(t)); depth == 2 && name != "" && value != "" {
+					fields = append(fields, BodyField{Name: name, Value: value})
+					name = ""
+				}
+			case xml.EndElement:
+				if depth == 2 && name != "" {
+					fields = append(fields, BodyField{Name: name})
+					name = ""
+				}
+				depth--
+			}
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("no fields found in XML body")
+		}
+
+	default:
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return nil, err
+		}
+		for name, vs := range values {
+			for _, v := range vs {
+				fields = append(fields, BodyField{Name: name, Value: v})
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields, nil
+}
+
+// EncodeBody serialises fields into the given format
+func EncodeBody(fields []BodyField, format string) (*ShiftedBody, error) {
+	switch format {
+	case FormatJSON:
+		var b strings.Builder
+		b.WriteString("{")
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(jsonString(f.Name))
+			b.WriteString(":")
+			b.WriteString(jsonValue(f.Value))
+		}
+		b.WriteString("}")
+		return &ShiftedBody{Format: format, ContentType: ContentTypeFor(format), Body: b.String()}, nil
+
+	case FormatXML:
+		var b strings.Builder
+		b.WriteString("<root>")
+		for _, f := range fields {
+			fmt.Fprintf(&b, "<%s>", f.Name)
+			xml.EscapeText(&b, []byte(f.Value))
+			fmt.Fprintf(&b, "</%s>", f.Name)
+		}
+		b.WriteString("</root>")
+		return &ShiftedBody{Format: format, ContentType: ContentTypeFor(format), Body: b.String()}, nil
+
+	case FormatMultipart:
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for _, f := range fields {
+			if err := w.WriteField(f.Name, f.Value); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return &ShiftedBody{Format: format, ContentType: w.FormDataContentType(), Body: buf.String()}, nil
+
+	default:
+		values := url.Values{}
+		for _, f := range fields {
+			values.Add(f.Name, f.Value)
+		}
+		return &ShiftedBody{Format: FormatForm, ContentType: ContentTypeFor(FormatForm), Body: values.Encode()}, nil
+	}
+}
+
+// ShiftContentType re-encodes body into every other supported format
+func ShiftContentType(body, format string) ([]*ShiftedBody, error) {
+	fields, err := ParseBodyFields(body, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var shifted []*ShiftedBody
+	for _, target := range []string{FormatJSON, FormatForm, FormatXML, FormatMultipart} {
+		if target == format {
+			continue
+		}
+		sb, err := EncodeBody(fields, target)
+		if err != nil {
+			return nil, err
+		}
+		shifted = append(shifted, sb)
+	}
+	return shifted, nil
+}
+
+// jsonValue keeps numbers, booleans and raw JSON as-is and quotes everything else
+func jsonValue(v string) string {
+	if _, err := strconv.ParseFloat(v, 64); err == nil && json.Valid([]byte(v)) {
+		return v
+	}
+	if v == "true" || v == "false" || v == "null" {
+		return v
+	}
+	if (strings.HasPrefix(v, "{") || strings.HasPrefix(v, "[")) && json.Valid([]byte(v)) {
+		return v
+	}
+	return jsonString(v)
+}
+
+// jsonString quotes s without HTML escaping so values reach the server unchanged
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -5,8 +5,27 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/url"
+	"strings"
 )
 
+// Encodings are the encoding methods Encode supports
+var Encodings = []string{"url", "double_url", "base64", "hex", "unicode", "json_wrap", "array"}
+
+// ValidateEncoding checks an encoding or chain of encodings joined by "+",
+// e.g. base64+url
+func ValidateEncoding(chain string) error {
+	for _, method := range strings.Split(chain, "+") {
+		known := false
+		for _, e := range Encodings {
+			known = known || e == method
+		}
+		if !known {
+			return fmt.Errorf("unknown encoding %q, want one of %s", method, strings.Join(Encodings, ", "))
+		}
+	}
+	return nil
+}
+
 type EncodingEngine struct{}
 
 func NewEncodingEngine() *EncodingEngine {
@@ -34,6 +53,15 @@ func (ee *EncodingEngine) Encode(payload string, method string) string {
 	}
 }
 
+// EncodeChain applies a chain of encodings joined by "+" from left to right,
+// e.g. base64+url URL-encodes the base64 of payload
+func (ee *EncodingEngine) EncodeChain(payload string, chain string) string {
+	for _, method := range strings.Split(chain, "+") {
+		payload = ee.Encode(payload, method)
+	}
+	return payload
+}
+
 func (ee *EncodingEngine) unicodeEncode(s string) string {
 	result := ""
 	for _, r := range s {
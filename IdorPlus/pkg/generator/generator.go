@@ -6,6 +6,18 @@ type PayloadGenerator struct {
 	IDType    analyzer.IDType
 	Numeric   *NumericGenerator
 	UUID      *UUIDGenerator
+	Special   *SpecialGenerator
+	Injection *InjectionGenerator
+	// Pattern is the structure of a sample ID, needed for date-stamped and
+	// composite IDs
+	Pattern *analyzer.IDPattern
+	// Template, when set, builds structured IDs from the numeric IDs
+	Template *Template
+	// Learned, when set, generates IDs like the known IDs it was learned
+	// from
+	Learned *analyzer.LearnedPattern
+	// Encodings add an encoded copy of every payload per encoding, each
+	// a method or a chain such as base64+url
 	Encodings []string
 	Encoder   *EncodingEngine
 }
@@ -15,34 +27,70 @@ func NewPayloadGenerator(idType analyzer.IDType) *PayloadGenerator {
 		IDType:    idType,
 		Numeric:   NewNumericGenerator(),
 		UUID:      NewUUIDGenerator(),
+		Special:   NewSpecialGenerator(),
+		Injection: NewInjectionGenerator(),
 		Encoder:   NewEncodingEngine(),
-		Encodings: []string{}, // Add encodings here if needed
+		Encodings: []string{},
 	}
 }
 
 func (pg *PayloadGenerator) Generate(count int) []string {
 	var basePayloads []string
 
-	switch pg.IDType {
-	case analyzer.TypeNumeric:
+	switch {
+	case pg.Template != nil:
+		basePayloads = pg.Template.Generate(pg.Numeric.IDs(count))
+	case pg.Learned != nil:
+		basePayloads = NewLearnedGenerator(pg.Learned).Generate(count)
+	case pg.IDType == analyzer.TypeNumeric:
 		basePayloads = pg.Numeric.Generate(count)
-	case analyzer.TypeUUID:
+	case pg.IDType == analyzer.TypeUUID:
 		basePayloads = pg.UUID.Generate(count)
+	case pg.IDType == analyzer.TypeDate && pg.Pattern != nil:
+		basePayloads = NewDateGenerator(pg.Pattern).Generate(count)
+	case pg.IDType == analyzer.TypeComposite && pg.Pattern != nil:
+		basePayloads = NewCompositeGenerator(pg.Pattern).Generate(count)
 	default:
 		// Default to numeric if unknown
 		basePayloads = pg.Numeric.Generate(count)
 	}
 
-	// Apply encodings if any
+	// Special values and injections apply whatever the ID type
+	var extra []string
+	if pg.Special != nil {
+		extra = append(extra, pg.Special.Generate(basePayloads)...)
+	}
+	if pg.Injection != nil {
+		extra = append(extra, pg.Injection.Generate()...)
+	}
+	seen := make(map[string]bool, len(basePayloads)+len(extra))
+	for _, p := range basePayloads {
+		seen[p] = true
+	}
+	for _, p := range extra {
+		if !seen[p] {
+			seen[p] = true
+			basePayloads = append(basePayloads, p)
+		}
+	}
+
+	return pg.Encode(basePayloads)
+}
+
+// Encode returns payloads, each followed by its encodings. Encodings that
+// leave a payload unchanged, e.g. URL-encoding a number, are skipped.
+func (pg *PayloadGenerator) Encode(payloads []string) []string {
 	if len(pg.Encodings) == 0 {
-		return basePayloads
+		return payloads
 	}
 
 	var encodedPayloads []string
-	for _, p := range basePayloads {
+	for _, p := range payloads {
 		encodedPayloads = append(encodedPayloads, p) // Keep original
-		for _, method := range pg.Encodings {
-			encodedPayloads = append(encodedPayloads, pg.Encoder.Encode(p, method))
+		for _, chain := range pg.Encodings {
+			if encoded := pg.Encoder.EncodeChain(p, chain); encoded != p {
+				encodedPayloads = append(encodedPayloads, encoded)
+			}
 		}
 	}
 
@@ -0,0 +1,24 @@
+package generator
+
+// InjectionGenerator produces IDs that turn the lookup into a filter
+// matching every object: query operators parsed by qs-style decoders into
+// NoSQL conditions, and SQL conditions that are always true. A lookup
+// that takes them returns many users' objects at once.
+type InjectionGenerator struct{}
+
+func NewInjectionGenerator() *InjectionGenerator {
+	return &InjectionGenerator{}
+}
+
+// injectionValues are tried for every ID type. Operators start with "[$"
+// and follow the parameter name, e.g. id[$ne]=1, see
+// fuzzer.IsOperatorPayload.
+var injectionValues = []string{
+	"[$ne]=1", "[$gt]=0", "[$regex]=.*", "[$exists]=true",
+	"' OR '1'='1", "1 OR 1=1", `" OR "1"="1`, "1' OR '1'='1' -- ",
+}
+
+// Generate returns the injection payloads
+func (ig *InjectionGenerator) Generate() []string {
+	return append([]string{}, injectionValues...)
+}
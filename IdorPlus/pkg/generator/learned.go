@@ -0,0 +1,56 @@
+package generator
+
+import "idorplus/pkg/analyzer"
+
+// LearnedGenerator generates IDs from a pattern learned from known IDs:
+// counters next to the observed ones, or random values of the observed
+// charset and length
+type LearnedGenerator struct {
+	Pattern *analyzer.LearnedPattern
+}
+
+func NewLearnedGenerator(p *analyzer.LearnedPattern) *LearnedGenerator {
+	return &LearnedGenerator{Pattern: p}
+}
+
+// learnedCharsets are the alphabets of the learned charsets
+var learnedCharsets = map[string]string{
+	analyzer.CharsetDigits:   "0123456789",
+	analyzer.CharsetHexLower: "0123456789abcdef",
+	analyzer.CharsetHexUpper: "0123456789ABCDEF",
+	analyzer.CharsetAlpha:    "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	analyzer.CharsetAlnum:    "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+}
+
+func (lg *LearnedGenerator) Generate(count int) []string {
+	p := lg.Pattern
+	payloads := make([]string, 0, count)
+	if !p.Counter {
+		length := p.VarLength
+		if length == 0 {
+			length = 8
+		}
+		for range count {
+			payloads = append(payloads, p.Prefix+randomString(learnedCharsets[p.Charset], length)+p.Suffix)
+		}
+		return payloads
+	}
+
+	// Neighbours of every observed counter, nearest first, as other users'
+	// objects sit between and around them
+	seen := make(map[int64]bool, count+len(p.Counters))
+	for _, c := range p.Counters {
+		seen[c] = true
+	}
+	for d := int64(1); len(payloads) < count; d++ {
+		for _, c := range p.Counters {
+			for _, n := range []int64{c - d*p.Step, c + d*p.Step} {
+				if n >= 0 && len(payloads) < count && !seen[n] {
+					seen[n] = true
+					payloads = append(payloads, p.Format(n))
+				}
+			}
+		}
+	}
+	return payloads
+}
@@ -1,19 +1,82 @@
 package generator
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
 
-type NumericGenerator struct{}
+	"idorplus/pkg/analyzer"
+)
+
+// maxNumericPayloads caps the IDs of a range, sample larger ones
+const maxNumericPayloads = 1000000
+
+type NumericGenerator struct {
+	// Start is the first ID, default 1. With End set, IDs run from Start
+	// to End inclusive instead of count IDs.
+	Start int64
+	End   int64
+	// Step is the distance between IDs, default 1
+	Step int64
+	// Sample picks this many IDs of the range at random instead of all
+	Sample int
+	// Checksum appends check digits of this scheme (analyzer.Checksums)
+	// to every ID, so that IDs pass validation. Start and End then bound
+	// the numbers before the check digits.
+	Checksum string
+}
 
 func NewNumericGenerator() *NumericGenerator {
 	return &NumericGenerator{}
 }
 
-func (ng *NumericGenerator) Generate(count int) []string {
-	payloads := []string{}
+// Validate checks the range, step and sample
+func (ng *NumericGenerator) Validate() error {
+	if ng.Checksum != "" {
+		if err := analyzer.ValidateChecksum(ng.Checksum); err != nil {
+			return err
+		}
+	}
+	if ng.Step < 0 {
+		return fmt.Errorf("step must be positive, got %d", ng.Step)
+	}
+	if ng.Sample < 0 {
+		return fmt.Errorf("sample must be positive, got %d", ng.Sample)
+	}
+	if ng.End == 0 {
+		if ng.Sample > 0 {
+			return errors.New("a random sample needs a range to pick from")
+		}
+		return nil
+	}
+	if ng.End < ng.Start {
+		return fmt.Errorf("range %d-%d ends before it starts", ng.Start, ng.End)
+	}
+	if n := ng.rangeSize(); n > maxNumericPayloads && (ng.Sample == 0 || ng.Sample > maxNumericPayloads) {
+		return fmt.Errorf("range %d-%d holds %d IDs, more than %d, use a larger step or a random sample", ng.Start, ng.End, n, maxNumericPayloads)
+	}
+	return nil
+}
+
+func (ng *NumericGenerator) step() int64 {
+	return max(ng.Step, 1)
+}
 
-	// Sequential
-	for i := 1; i <= count; i++ {
-		payloads = append(payloads, fmt.Sprintf("%d", i))
+func (ng *NumericGenerator) rangeSize() int64 {
+	return (ng.End-ng.Start)/ng.step() + 1
+}
+
+func (ng *NumericGenerator) Generate(count int) []string {
+	ids := ng.IDs(count)
+	payloads := make([]string, 0, len(ids))
+	for _, id := range ids {
+		payloads = append(payloads, ng.Format(id))
+	}
+	// Boundaries would fail the check digits
+	if ng.End != 0 || ng.Checksum != "" {
+		return payloads
 	}
 
 	// Boundary values
@@ -28,3 +91,53 @@ func (ng *NumericGenerator) Generate(count int) []string {
 
 	return payloads
 }
+
+// Format returns an ID with its check digits, if any
+func (ng *NumericGenerator) Format(id int64) string {
+	s := strconv.FormatInt(id, 10)
+	return s + analyzer.CheckDigits(s, ng.Checksum)
+}
+
+// IDs returns the IDs from Start to End, or Sample of them in ascending
+// order. Without End, it returns count IDs from Start.
+func (ng *NumericGenerator) IDs(count int) []int64 {
+	if ng.End == 0 {
+		start := ng.Start
+		if start == 0 {
+			start = 1
+		}
+		ids := make([]int64, count)
+		for i := range ids {
+			ids[i] = start + int64(i)*ng.step()
+		}
+		return ids
+	}
+
+	n := ng.rangeSize()
+	var indexes []int64
+	if ng.Sample > 0 && int64(ng.Sample) < n {
+		// Floyd's algorithm, no need to hold the whole range
+		picked := make(map[int64]bool, ng.Sample)
+		for j := n - int64(ng.Sample); j < n; j++ {
+			k := rand.Int64N(j + 1)
+			if picked[k] {
+				k = j
+			}
+			picked[k] = true
+			indexes = append(indexes, k)
+		}
+		slices.Sort(indexes)
+	} else {
+		n = min(n, maxNumericPayloads)
+		indexes = make([]int64, n)
+		for i := range indexes {
+			indexes[i] = int64(i)
+		}
+	}
+
+	ids := make([]int64, len(indexes))
+	for i, k := range indexes {
+		ids[i] = ng.Start + k*ng.step()
+	}
+	return ids
+}
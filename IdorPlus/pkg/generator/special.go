@@ -0,0 +1,33 @@
+package generator
+
+// SpecialGenerator produces values that stand for the current user, every
+// object or no object rather than one ID. Frameworks resolving "me", a
+// wildcard or a NoSQL operator before checking ownership leak other
+// users' data whatever the ID type.
+type SpecialGenerator struct{}
+
+func NewSpecialGenerator() *SpecialGenerator {
+	return &SpecialGenerator{}
+}
+
+// specialValues are tried for every ID type
+var specialValues = []string{
+	// Aliases of the current user
+	"me", "self", "current",
+	// Wildcards
+	"*", "all",
+	// Null, empty and out of range
+	"null", "", "0", "-1", "-2",
+	// NoSQL operators, matching any document
+	`{"$ne":null}`, `{"$gt":""}`,
+}
+
+// Generate returns the special values and arrays of the first IDs of ids,
+// e.g. [1,2] or ["a","b"]
+func (sg *SpecialGenerator) Generate(ids []string) []string {
+	payloads := append([]string{}, specialValues...)
+	if len(ids) >= 2 {
+		payloads = append(payloads, "["+jsonValue(ids[0])+"]", "["+jsonValue(ids[0])+","+jsonValue(ids[1])+"]")
+	}
+	return payloads
+}
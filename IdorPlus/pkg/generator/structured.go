@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+)
+
+// DateGenerator generates date-stamped IDs such as 20240115-0042: counters
+// around the sample's on its date and the days around it, as such
+// counters usually restart daily
+type DateGenerator struct {
+	Pattern *analyzer.IDPattern
+	// Days is the number of days to cover, centred on the sample's
+	Days int
+}
+
+func NewDateGenerator(p *analyzer.IDPattern) *DateGenerator {
+	return &DateGenerator{Pattern: p, Days: 7}
+}
+
+func (dg *DateGenerator) Generate(count int) []string {
+	p := dg.Pattern
+	days := max(dg.Days, 1)
+	perDay := max(count/days, 1)
+	first := max(p.Counter-int64(perDay/2), 0)
+
+	var payloads []string
+	// Sample date first, then alternately before and after it
+	for i := 0; i < days && len(payloads) < count; i++ {
+		offset := (i + 1) / 2
+		if i%2 == 1 {
+			offset = -offset
+		}
+		date := p.Date.AddDate(0, 0, offset).Format(p.Layout)
+		for n := first; n < first+int64(perDay) && len(payloads) < count; n++ {
+			payloads = append(payloads, date+p.Separator+padNumber(n, p.Width))
+		}
+	}
+	return payloads
+}
+
+// CompositeGenerator generates composite IDs such as 17-42 by varying one
+// part at a time: other resources of the same owner, and the same
+// resource under other owners
+type CompositeGenerator struct {
+	Pattern *analyzer.IDPattern
+}
+
+func NewCompositeGenerator(p *analyzer.IDPattern) *CompositeGenerator {
+	return &CompositeGenerator{Pattern: p}
+}
+
+func (cg *CompositeGenerator) Generate(count int) []string {
+	p := cg.Pattern
+	if len(p.Parts) == 0 {
+		return nil
+	}
+	// The last part, usually the resource, varies first
+	perPart := max(count/len(p.Parts), 1)
+	var payloads []string
+	for i := len(p.Parts) - 1; i >= 0; i-- {
+		part := p.Parts[i]
+		for _, n := range around(part.Number, perPart) {
+			payloads = append(payloads, cg.build(i, n))
+		}
+	}
+	return payloads
+}
+
+// build returns the sample with part i set to n
+func (cg *CompositeGenerator) build(i int, n int64) string {
+	parts := make([]string, len(cg.Pattern.Parts))
+	for j, part := range cg.Pattern.Parts {
+		if j == i {
+			part.Number = n
+		}
+		parts[j] = part.Prefix + padNumber(part.Number, part.Width)
+	}
+	return strings.Join(parts, cg.Pattern.Separator)
+}
+
+// around returns n numbers next to center, alternately below and above
+// it, never negative
+func around(center int64, n int) []int64 {
+	var nums []int64
+	for d := int64(1); len(nums) < n && d <= int64(n)+center; d++ {
+		if center-d >= 0 {
+			nums = append(nums, center-d)
+		}
+		if len(nums) < n {
+			nums = append(nums, center+d)
+		}
+	}
+	return nums
+}
+
+func padNumber(n int64, width int) string {
+	return fmt.Sprintf("%0*d", width, n)
+}
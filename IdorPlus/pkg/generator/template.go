@@ -0,0 +1,202 @@
+package generator
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+
+	"github.com/google/uuid"
+)
+
+// Template builds structured IDs such as INV-{num:06d} or user_{uuid|md5}.
+// Placeholders are {name[:format][|transform]...}:
+//
+//	num    the numeric ID, format is a printf verb such as 06d or x
+//	uuid   a random UUID v4
+//	hex    random hex digits, format is the length (default 8)
+//	alnum  random letters and digits, format is the length (default 8)
+//
+// Transforms apply left to right: upper, lower, md5, sha1, sha256, the
+// check digits luhn and mod97, and the encodings of EncodingEngine (base64,
+// url, hex...).
+type Template struct {
+	source string
+	parts  []templatePart
+}
+
+// templatePart is literal text or a placeholder
+type templatePart struct {
+	literal    string
+	name       string
+	format     string
+	transforms []string
+}
+
+// TemplateTransforms are the transforms besides the encodings
+var TemplateTransforms = []string{"upper", "lower", "md5", "sha1", "sha256", analyzer.ChecksumLuhn, analyzer.ChecksumMod97}
+
+var (
+	templatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+	numFormat           = regexp.MustCompile(`^[-+ 0#]*[0-9]*[dxXob]$`)
+)
+
+// ParseTemplate parses a payload template
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{source: s}
+	last := 0
+	for _, m := range templatePlaceholder.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] > last {
+			t.parts = append(t.parts, templatePart{literal: s[last:m[0]]})
+		}
+		part, err := parsePlaceholder(s[m[2]:m[3]])
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", s, err)
+		}
+		t.parts = append(t.parts, part)
+		last = m[1]
+	}
+	if last < len(s) {
+		t.parts = append(t.parts, templatePart{literal: s[last:]})
+	}
+	if len(t.parts) == 0 || (len(t.parts) == 1 && t.parts[0].name == "") {
+		return nil, fmt.Errorf("template %q has no placeholder such as {num} or {uuid}", s)
+	}
+	return t, nil
+}
+
+func parsePlaceholder(s string) (templatePart, error) {
+	fields := strings.Split(s, "|")
+	name, format, _ := strings.Cut(strings.TrimSpace(fields[0]), ":")
+	part := templatePart{name: name, format: format}
+
+	switch name {
+	case "num":
+		if format != "" && !numFormat.MatchString(format) {
+			return part, fmt.Errorf("invalid number format %q, want a printf verb such as 06d or x", format)
+		}
+	case "hex", "alnum":
+		if format != "" {
+			if n, err := strconv.Atoi(format); err != nil || n <= 0 {
+				return part, fmt.Errorf("invalid %s length %q", name, format)
+			}
+		}
+	case "uuid":
+		if format != "" {
+			return part, fmt.Errorf("uuid takes no format, got %q", format)
+		}
+	default:
+		return part, fmt.Errorf("unknown placeholder {%s}, want num, uuid, hex or alnum", name)
+	}
+
+	for _, tr := range fields[1:] {
+		tr = strings.TrimSpace(tr)
+		if !isTemplateTransform(tr) {
+			return part, fmt.Errorf("unknown transform %q, want one of %s, %s", tr, strings.Join(TemplateTransforms, ", "), strings.Join(Encodings, ", "))
+		}
+		part.transforms = append(part.transforms, tr)
+	}
+	return part, nil
+}
+
+func isTemplateTransform(name string) bool {
+	for _, t := range TemplateTransforms {
+		if t == name {
+			return true
+		}
+	}
+	return ValidateEncoding(name) == nil
+}
+
+// String returns the template source
+func (t *Template) String() string {
+	return t.source
+}
+
+// Expand builds the ID for a number. Random placeholders differ per call.
+func (t *Template) Expand(num int64) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.name == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		v := p.value(num)
+		for _, tr := range p.transforms {
+			v = transform(v, tr)
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// Generate builds one ID per number
+func (t *Template) Generate(nums []int64) []string {
+	payloads := make([]string, len(nums))
+	for i, n := range nums {
+		payloads[i] = t.Expand(n)
+	}
+	return payloads
+}
+
+func (p templatePart) value(num int64) string {
+	switch p.name {
+	case "num":
+		format := p.format
+		if format == "" {
+			format = "d"
+		}
+		return fmt.Sprintf("%"+format, num)
+	case "uuid":
+		return uuid.New().String()
+	case "hex":
+		return randomString("0123456789abcdef", p.length())
+	case "alnum":
+		return randomString("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", p.length())
+	}
+	return ""
+}
+
+func (p templatePart) length() int {
+	if n, err := strconv.Atoi(p.format); err == nil {
+		return n
+	}
+	return 8
+}
+
+func randomString(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[rand.IntN(len(alphabet))]
+	}
+	return string(b)
+}
+
+func transform(v, name string) string {
+	switch name {
+	case "upper":
+		return strings.ToUpper(v)
+	case "lower":
+		return strings.ToLower(v)
+	case "md5":
+		sum := md5.Sum([]byte(v))
+		return hex.EncodeToString(sum[:])
+	case "sha1":
+		sum := sha1.Sum([]byte(v))
+		return hex.EncodeToString(sum[:])
+	case "sha256":
+		sum := sha256.Sum256([]byte(v))
+		return hex.EncodeToString(sum[:])
+	case analyzer.ChecksumLuhn, analyzer.ChecksumMod97:
+		return v + analyzer.CheckDigits(v, name)
+	default:
+		return NewEncodingEngine().EncodeChain(v, name)
+	}
+}
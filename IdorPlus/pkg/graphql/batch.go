@@ -0,0 +1,121 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// OperationResult is the outcome of one ID's query, sent alone and as
+// part of an array batch
+type OperationResult struct {
+	ID      string
+	Single  bool   // the query alone returned data
+	Batched bool   // the query in the batch returned data
+	Error   string // first error message the single query got
+}
+
+// BatchArrayResult is the result of TestBatchArray
+type BatchArrayResult struct {
+	// Supported is false when the endpoint doesn't answer an array of
+	// operations with an array of results
+	Supported  bool
+	Operations []OperationResult
+	// Bypassed are the IDs whose data the batch returns although the
+	// query alone is denied: authorization is checked per request, not
+	// per operation
+	Bypassed []string
+}
+
+// TestBatchArray sends the query for each ID alone, then all of them as
+// a JSON array of operations in one POST (Apollo-style batching), and
+// compares what each operation returns on the two paths. Batches shrink
+// like those of TestBatchIDOR when the endpoint refuses them.
+func (gt *GraphQLTester) TestBatchArray(ctx context.Context, queryName, idArgName string, ids []string) (*BatchArrayResult, error) {
+	result := &BatchArrayResult{Supported: true}
+	for _, id := range ids {
+		resp, err := gt.executeQuery(ctx, idQuery(queryName, idArgName, id))
+		if err != nil {
+			return nil, err
+		}
+		if limited := limitError(resp); limited != "" {
+			return nil, fmt.Errorf("query for %s refused: %s", id, limited)
+		}
+		var op operationResponse
+		if err := json.Unmarshal(resp.Body(), &op); err != nil {
+			return nil, fmt.Errorf("query for %s got no GraphQL response (status %d): %w", id, resp.StatusCode(), err)
+		}
+		result.Operations = append(result.Operations, OperationResult{ID: id, Single: op.granted(queryName), Error: op.errorMessage()})
+	}
+
+	err := gt.sendBatches(ids, func(start int, chunk []string) (string, error) {
+		batch := make([]GraphQLQuery, 0, len(chunk))
+		for _, id := range chunk {
+			batch = append(batch, idQuery(queryName, idArgName, id))
+		}
+		resp, err := gt.post(ctx, batch)
+		if err != nil {
+			return "", err
+		}
+		if limited := limitError(resp); limited != "" {
+			return limited, nil
+		}
+		var ops []operationResponse
+		if json.Unmarshal(resp.Body(), &ops) != nil || len(ops) != len(batch) {
+			result.Supported = false
+			return "", errUnsupported
+		}
+		for j, op := range ops {
+			result.Operations[start+j].Batched = op.granted(queryName)
+		}
+		return "", nil
+	})
+	if errors.Is(err, errUnsupported) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range result.Operations {
+		if op.Batched && !op.Single {
+			result.Bypassed = append(result.Bypassed, op.ID)
+		}
+	}
+	return result, nil
+}
+
+// errUnsupported stops TestBatchArray at an endpoint not answering arrays
+var errUnsupported = errors.New("array batching not supported")
+
+// operationResponse is the response to one operation
+type operationResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// granted reports whether the operation returned the queried object
+func (op *operationResponse) granted(queryName string) bool {
+	data, ok := op.Data[queryName]
+	return ok && string(data) != "null"
+}
+
+// hasData reports whether any field of the operation returned data
+func (op *operationResponse) hasData() bool {
+	for _, data := range op.Data {
+		if string(data) != "null" {
+			return true
+		}
+	}
+	return false
+}
+
+func (op *operationResponse) errorMessage() string {
+	if len(op.Errors) == 0 {
+		return ""
+	}
+	return op.Errors[0].Message
+}
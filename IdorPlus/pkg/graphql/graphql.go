@@ -1,12 +1,14 @@
 package graphql
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
 
 	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
 
 	"github.com/go-resty/resty/v2"
 )
@@ -15,6 +17,17 @@ import (
 type GraphQLTester struct {
 	client   *client.SmartClient
 	endpoint string
+
+	// Session names the client session whose cookies queries carry,
+	// anonymous when empty
+	Session string
+	// BatchSize is the most operations sent in one request, default and
+	// at most 50. Batch tests lower it when the endpoint refuses batches.
+	BatchSize int
+	// AllowMutations permits FuzzVariable to send mutations, only with
+	// Canaries: IDs of objects the tester owns
+	AllowMutations bool
+	Canaries       []string
 }
 
 // GraphQLQuery represents a GraphQL query
@@ -57,7 +70,7 @@ func NewGraphQLTester(c *client.SmartClient, endpoint string) *GraphQLTester {
 }
 
 // Introspect performs GraphQL introspection to discover schema
-func (gt *GraphQLTester) Introspect() (*IntrospectionResult, error) {
+func (gt *GraphQLTester) Introspect(ctx context.Context) (*IntrospectionResult, error) {
 	query := GraphQLQuery{
 		Query: `
 		query IntrospectionQuery {
@@ -78,7 +91,7 @@ func (gt *GraphQLTester) Introspect() (*IntrospectionResult, error) {
 		}`,
 	}
 
-	resp, err := gt.executeQuery(query)
+	resp, err := gt.executeQuery(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -115,23 +128,19 @@ func (gt *GraphQLTester) Introspect() (*IntrospectionResult, error) {
 }
 
 // TestIDOROnQuery tests a specific GraphQL query for IDOR
-func (gt *GraphQLTester) TestIDOROnQuery(queryName string, idArgName string, validID, invalidID string) (*IDORResult, error) {
+func (gt *GraphQLTester) TestIDOROnQuery(ctx context.Context, queryName string, idArgName string, validID, invalidID string) (*IDORResult, error) {
 	// Build query with valid ID (baseline)
-	validQuery := GraphQLQuery{
-		Query: fmt.Sprintf(`query { %s(%s: "%s") { id } }`, queryName, idArgName, validID),
-	}
+	validQuery := idQuery(queryName, idArgName, validID)
 
-	validResp, err := gt.executeQuery(validQuery)
+	validResp, err := gt.executeQuery(ctx, validQuery)
 	if err != nil {
 		return nil, err
 	}
 
 	// Build query with invalid/other user's ID
-	invalidQuery := GraphQLQuery{
-		Query: fmt.Sprintf(`query { %s(%s: "%s") { id } }`, queryName, idArgName, invalidID),
-	}
+	invalidQuery := idQuery(queryName, idArgName, invalidID)
 
-	invalidResp, err := gt.executeQuery(invalidQuery)
+	invalidResp, err := gt.executeQuery(ctx, invalidQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -155,66 +164,65 @@ func (gt *GraphQLTester) TestIDOROnQuery(queryName string, idArgName string, val
 	return result, nil
 }
 
-// TestBatchIDOR tests for batch/aliasing IDOR attacks
-// Processes IDs in batches of 50 to prevent memory issues
-func (gt *GraphQLTester) TestBatchIDOR(queryName, idArgName string, ids []string) ([]string, error) {
-	const maxBatchSize = 50
+// maxBatchSize caps the operations of a batch, aliased or as an array
+const maxBatchSize = 50
 
+// TestBatchIDOR tests for batch/aliasing IDOR attacks. IDs go in batches
+// of up to BatchSize aliases, smaller ones when the endpoint refuses a
+// batch as too complex or rate limited, and one query per ID at worst.
+// The IDs found before an error are returned with it.
+func (gt *GraphQLTester) TestBatchIDOR(ctx context.Context, queryName, idArgName string, ids []string) ([]string, error) {
 	var allVulnerable []string
-
-	// Process in chunks
-	for i := 0; i < len(ids); i += maxBatchSize {
-		end := i + maxBatchSize
-		if end > len(ids) {
-			end = len(ids)
-		}
-
-		batch := ids[i:end]
-		vulnerable, err := gt.testBatchChunk(queryName, idArgName, batch)
+	err := gt.sendBatches(ids, func(_ int, batch []string) (string, error) {
+		resp, vulnerable, err := gt.testBatchChunk(ctx, queryName, idArgName, batch)
 		if err != nil {
-			continue
+			return "", err
+		}
+		if limited := limitError(resp); limited != "" {
+			return limited, nil
 		}
 		allVulnerable = append(allVulnerable, vulnerable...)
-	}
-
-	return allVulnerable, nil
+		return "", nil
+	})
+	return allVulnerable, err
 }
 
-// testBatchChunk tests a single batch of IDs
-func (gt *GraphQLTester) testBatchChunk(queryName, idArgName string, ids []string) ([]string, error) {
-	// Build batch query with aliases
-	var queryParts []string
-	for i, id := range ids {
-		alias := fmt.Sprintf("q%d", i)
-		queryParts = append(queryParts, fmt.Sprintf(`%s: %s(%s: "%s") { id }`, alias, queryName, idArgName, id))
-	}
-
-	batchQuery := GraphQLQuery{
-		Query: fmt.Sprintf("query { %s }", strings.Join(queryParts, " ")),
+// testBatchChunk tests a single batch of IDs, aliased unless it is a
+// single ID
+func (gt *GraphQLTester) testBatchChunk(ctx context.Context, queryName, idArgName string, ids []string) (*resty.Response, []string, error) {
+	batchQuery := idQuery(queryName, idArgName, ids[0])
+	alias := func(i int) string { return queryName }
+	if len(ids) > 1 {
+		// Build batch query with aliases
+		var queryParts []string
+		for i, id := range ids {
+			queryParts = append(queryParts, fmt.Sprintf(`q%d: %s(%s: "%s") { id }`, i, queryName, idArgName, id))
+		}
+		batchQuery = GraphQLQuery{Query: fmt.Sprintf("query { %s }", strings.Join(queryParts, " "))}
+		alias = func(i int) string { return fmt.Sprintf("q%d", i) }
 	}
 
-	resp, err := gt.executeQuery(batchQuery)
+	resp, err := gt.executeQuery(ctx, batchQuery)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Parse response to find which IDs returned data
 	var vulnerableIDs []string
 	var result map[string]interface{}
 	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return nil, err
+		return resp, nil, nil
 	}
 
 	if data, ok := result["data"].(map[string]interface{}); ok {
 		for i, id := range ids {
-			alias := fmt.Sprintf("q%d", i)
-			if data[alias] != nil {
+			if data[alias(i)] != nil {
 				vulnerableIDs = append(vulnerableIDs, id)
 			}
 		}
 	}
 
-	return vulnerableIDs, nil
+	return resp, vulnerableIDs, nil
 }
 
 // IDORResult represents GraphQL IDOR test result
@@ -226,13 +234,30 @@ type IDORResult struct {
 	Evidence      string
 }
 
-func (gt *GraphQLTester) executeQuery(query GraphQLQuery) (*resty.Response, error) {
-	return gt.client.Request().
+func (gt *GraphQLTester) executeQuery(ctx context.Context, query GraphQLQuery) (*resty.Response, error) {
+	return gt.post(ctx, query)
+}
+
+// post sends a request body, a query or a batch of them, as the session
+func (gt *GraphQLTester) post(ctx context.Context, body interface{}) (*resty.Response, error) {
+	return gt.postAs(ctx, gt.Session, body)
+}
+
+// postAs sends a request body as a session, anonymously for ""
+func (gt *GraphQLTester) postAs(ctx context.Context, session string, body interface{}) (*resty.Response, error) {
+	req := gt.client.Request(ctx)
+	fuzzer.PrepareRequest(gt.client, req, &fuzzer.FuzzJob{Session: session})
+	return req.
 		SetHeader("Content-Type", "application/json").
-		SetBody(query).
+		SetBody(body).
 		Post(gt.endpoint)
 }
 
+// idQuery queries queryName for the object with an ID
+func idQuery(queryName, idArgName, id string) GraphQLQuery {
+	return GraphQLQuery{Query: fmt.Sprintf(`query { %s(%s: "%s") { id } }`, queryName, idArgName, id)}
+}
+
 func isIDArgument(name string) bool {
 	idPatterns := []string{"id", "userId", "user_id", "accountId", "resourceId", "objectId"}
 	nameLower := strings.ToLower(name)
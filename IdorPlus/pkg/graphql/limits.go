@@ -0,0 +1,79 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// limitPattern matches the error messages and codes of query cost,
+// complexity, depth, batch size and rate limits
+var limitPattern = regexp.MustCompile(`(?i)complexity|query cost|max(imum)?[ _-]?(cost|depth|aliases|operations|batch)|too[ _-](many|complex|large|deep)|rate[ _-]?limit|throttl|batch[ _-](size|limit)`)
+
+// limitError returns why resp refuses a query as too costly or too many,
+// "" when it doesn't. Batches get a single error object for the whole
+// array or one per operation.
+func limitError(resp *resty.Response) string {
+	if resp.StatusCode() == http.StatusTooManyRequests || resp.StatusCode() == http.StatusRequestEntityTooLarge {
+		return fmt.Sprintf("status %d", resp.StatusCode())
+	}
+	var ops []limitResponse
+	if json.Unmarshal(resp.Body(), &ops) != nil {
+		var op limitResponse
+		if json.Unmarshal(resp.Body(), &op) != nil {
+			return ""
+		}
+		ops = []limitResponse{op}
+	}
+	for _, op := range ops {
+		for _, e := range op.Errors {
+			if limitPattern.MatchString(e.Message) || limitPattern.MatchString(e.Extensions.Code) {
+				return e.Message
+			}
+		}
+	}
+	return ""
+}
+
+type limitResponse struct {
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// sendBatches sends ids in batches with send, which reports why the
+// endpoint refused a batch as too costly, "" when it took it. A refused
+// batch is sent again at half the size, down to one ID per query; the
+// size that works is kept in BatchSize for the next test.
+func (gt *GraphQLTester) sendBatches(ids []string, send func(start int, batch []string) (string, error)) error {
+	size := gt.BatchSize
+	if size <= 0 || size > maxBatchSize {
+		size = maxBatchSize
+	}
+	for i := 0; i < len(ids); {
+		batch := ids[i:min(i+size, len(ids))]
+		limited, err := send(i, batch)
+		if err != nil {
+			return err
+		}
+		if limited == "" {
+			i += len(batch)
+			continue
+		}
+		if size == 1 {
+			return fmt.Errorf("query for %s refused even alone: %s", batch[0], limited)
+		}
+		size = max(len(batch)/2, 1)
+		gt.BatchSize = size
+		utils.Debug.Printf("Batch of %d refused (%s), retrying with %d\n", len(batch), limited, size)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/client"
+)
+
+// LoadOperation reads a captured query or mutation: a JSON request body
+// with query, variables and operationName as sent by a client, or a bare
+// GraphQL document
+func LoadOperation(data []byte) (GraphQLQuery, error) {
+	var op GraphQLQuery
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		// Numbers stay as captured, IDs beyond 2^53 included
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if err := dec.Decode(&op); err != nil {
+			return op, fmt.Errorf("invalid request body: %w", err)
+		}
+	} else {
+		op.Query = string(trimmed)
+	}
+	if strings.TrimSpace(op.Query) == "" {
+		return op, errors.New("no query")
+	}
+	return op, nil
+}
+
+// InjectionPoint returns the variable of op that looks most like an ID,
+// a dotted path such as input.userId for nested ones, "" without any
+func InjectionPoint(op GraphQLQuery) string {
+	var paths []string
+	var walk func(prefix string, vars map[string]interface{})
+	walk = func(prefix string, vars map[string]interface{}) {
+		for name, value := range vars {
+			if nested, ok := value.(map[string]interface{}); ok {
+				walk(prefix+name+".", nested)
+			} else if isIDArgument(name) {
+				paths = append(paths, prefix+name)
+			}
+		}
+	}
+	walk("", op.Variables)
+	// Shallow paths first, then by name, so the choice is stable
+	sort.Slice(paths, func(i, j int) bool {
+		if di, dj := strings.Count(paths[i], "."), strings.Count(paths[j], "."); di != dj {
+			return di < dj
+		}
+		return paths[i] < paths[j]
+	})
+	if len(paths) == 0 {
+		return ""
+	}
+	return paths[0]
+}
+
+// operationDefinition matches the keyword and name of an operation
+// definition, e.g. "mutation UpdateOrder"
+var operationDefinition = regexp.MustCompile(`(?:^|[\s}])(query|mutation|subscription)\b\s*([_A-Za-z][_0-9A-Za-z]*)?`)
+
+// IsMutation reports whether the operation sent is a mutation: the one
+// named by OperationName, or else the first of the document
+func (q GraphQLQuery) IsMutation() bool {
+	for _, m := range operationDefinition.FindAllStringSubmatch(q.Query, -1) {
+		if q.OperationName == "" || m[2] == q.OperationName {
+			return m[1] == "mutation"
+		}
+	}
+	return false
+}
+
+// Variable returns the value of a dotted variable path
+func (q GraphQLQuery) Variable(path string) (interface{}, bool) {
+	var value interface{} = q.Variables
+	for _, name := range strings.Split(path, ".") {
+		vars, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = vars[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// WithVariable returns a copy of q with the variable at a dotted path set
+// to id, a number when the captured value is one and id is numeric
+func (q GraphQLQuery) WithVariable(path, id string) (GraphQLQuery, error) {
+	old, ok := q.Variable(path)
+	if !ok {
+		return q, fmt.Errorf("no variable %s", path)
+	}
+	var value interface{} = id
+	switch old.(type) {
+	case json.Number, float64:
+		if _, err := strconv.ParseFloat(id, 64); err == nil && json.Valid([]byte(id)) {
+			value = json.Number(id)
+		}
+	}
+
+	names := strings.Split(path, ".")
+	q.Variables = copyVariables(q.Variables)
+	vars := q.Variables
+	for _, name := range names[:len(names)-1] {
+		vars = vars[name].(map[string]interface{})
+	}
+	vars[names[len(names)-1]] = value
+	return q, nil
+}
+
+// copyVariables deep-copies nested variable objects; lists and values are
+// shared, they are never modified
+func copyVariables(vars map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = copyVariables(nested)
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// SessionResult is one session's response to an operation
+type SessionResult struct {
+	Status  int
+	Granted bool // data came back
+	Length  int
+	Error   string // first GraphQL error message
+}
+
+// VariableResult is the outcome of an operation with one ID injected
+type VariableResult struct {
+	ID string
+	// Sessions are the results by session name, "" for anonymous
+	Sessions map[string]SessionResult
+	// Vulnerable is set when the attacker gets data for the ID other than
+	// for its own, the captured value
+	Vulnerable bool
+	// Public is set when the data also comes back without credentials
+	Public bool
+}
+
+// FuzzVariable sends op with each ID injected at the variable path as
+// every session, the attacker first; "" sends it without credentials. The
+// captured value is the attacker's own ID, its response the baseline that
+// tells data for another ID from a variable the server ignores.
+func (gt *GraphQLTester) FuzzVariable(ctx context.Context, op GraphQLQuery, path string, ids []string, sessions []string) ([]VariableResult, error) {
+	if len(sessions) == 0 {
+		return nil, errors.New("no sessions")
+	}
+	own, ok := op.Variable(path)
+	if !ok {
+		return nil, fmt.Errorf("no variable %s", path)
+	}
+	ownID := fmt.Sprint(own)
+	// A mutation changes data for every ID and session it is sent with
+	if op.IsMutation() {
+		if !gt.AllowMutations {
+			return nil, fmt.Errorf("%w: the operation is a mutation", client.ErrDestructive)
+		}
+		if len(gt.Canaries) == 0 {
+			return nil, fmt.Errorf("%w: mutations are only sent with canary IDs", client.ErrDestructive)
+		}
+		if !slices.Contains(gt.Canaries, ownID) {
+			return nil, fmt.Errorf("%w: the captured %s %s is not a canary ID", client.ErrDestructive, path, ownID)
+		}
+		ids = slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return !slices.Contains(gt.Canaries, id) })
+	}
+	baseline, err := gt.postAs(ctx, sessions[0], op)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []VariableResult
+	for _, id := range ids {
+		q, err := op.WithVariable(path, id)
+		if err != nil {
+			return nil, err
+		}
+		result := VariableResult{ID: id, Sessions: make(map[string]SessionResult)}
+		var attacker []byte
+		for _, session := range sessions {
+			resp, err := gt.postAs(ctx, session, q)
+			if err != nil {
+				return results, err
+			}
+			if limited := limitError(resp); limited != "" {
+				return results, fmt.Errorf("operation for %s refused: %s", id, limited)
+			}
+			var or operationResponse
+			json.Unmarshal(resp.Body(), &or)
+			result.Sessions[session] = SessionResult{Status: resp.StatusCode(), Granted: or.hasData(), Length: len(resp.Body()), Error: or.errorMessage()}
+			if session == sessions[0] {
+				attacker = resp.Body()
+			}
+		}
+		granted := result.Sessions[sessions[0]].Granted
+		result.Vulnerable = granted && id != ownID && !bytes.Equal(attacker, baseline.Body())
+		if anon, ok := result.Sessions[""]; ok && sessions[0] != "" {
+			result.Public = result.Vulnerable && anon.Granted
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
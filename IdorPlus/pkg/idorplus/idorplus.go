@@ -0,0 +1,555 @@
+package idorplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/generator"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+// Target is an endpoint to test. {ID} in URL, Headers, Cookies or Body marks
+// the fuzzed position; without it IDs are appended to the URL path.
+type Target struct {
+	URL     string
+	Method  string // default GET
+	Body    string
+	Headers map[string]string
+
+	// Cookies is the attacker's session. VictimCookies is a second user's
+	// session, used by Options.AuthMatrix.
+	Cookies       string
+	VictimCookies string
+	BearerToken   string
+}
+
+// Options tune a Scanner. Zero values fall back to the CLI defaults,
+// except Delay where zero means no delay.
+type Options struct {
+	// IDs are tried as-is. When empty, Count IDs of the type of the ID in
+	// the target URL are generated (default 100).
+	IDs   []string
+	Count int
+	// Wordlists fill named placeholders such as {ORG} in the target,
+	// combined with the IDs and each other: every combination, or with
+	// Combine "zip" the n-th entries together
+	Wordlists map[string][]string
+	Combine   string
+	// IDStart-IDEnd is the range of generated numeric IDs, IDStep (default
+	// 1) apart, and Sample picks that many of them at random. Without
+	// IDEnd, Count IDs from IDStart (default 1) are generated.
+	IDStart int64
+	IDEnd   int64
+	IDStep  int64
+	Sample  int
+	// Checksum appends check digits to generated numeric IDs, luhn or
+	// mod97 (see analyzer.Checksums), detected from known IDs when empty
+	Checksum string
+	// SampleIDs are known IDs, e.g. harvested from responses: generated
+	// IDs follow the pattern learned from them, see
+	// IdentifierAnalyzer.LearnFromSamples
+	SampleIDs []string
+	// Template builds structured IDs from the generated numbers, e.g.
+	// INV-{num:06d} or user_{uuid|md5}, see generator.Template
+	Template string
+	// EstimateRange probes for the lowest and highest existing IDs first
+	// and samples Count IDs from that range
+	EstimateRange bool
+	// Encodings add encoded copies of every ID, each an encoding (url,
+	// double_url, base64, hex, unicode, json_wrap, array) or a chain such
+	// as base64+url
+	Encodings []string
+	// Injection adds NoSQL operator and always-true SQL filter IDs, never
+	// sent with PUT, PATCH or DELETE
+	Injection bool
+
+	Concurrency int           // concurrent requests per scan, default 10
+	Delay       time.Duration // delay between requests
+	Timeout     time.Duration // per request, default 10s
+	JobTimeout  time.Duration // per ID, retries included
+	// SlowP95 is the p95 latency above which an endpoint gets one request
+	// at a time, then is skipped if it stays slow (see Stats.SlowEndpoints)
+	SlowP95 time.Duration
+
+	// Threshold is the response similarity (0-1) above which two responses
+	// are considered the same resource, default 0.8
+	Threshold float64
+	// MinConfidence (0-100) drops findings whose heuristics score lower,
+	// default 30, i.e. any one heuristic
+	MinConfidence int
+	// BypassMode is the WAF bypass mode: none, normal (default), aggressive or stealth
+	BypassMode string
+	DisablePII bool
+	// MaxFindings stops fuzzing after this many findings (0 = no limit)
+	MaxFindings int
+	// MaxRequests and MaxDuration are the budget of each scan: no request
+	// is sent beyond either (0 = no limit), see Stats.Untested
+	MaxRequests int
+	MaxDuration time.Duration
+	// Retests re-sends each finding this many times and drops it unless
+	// every re-test is flagged too, default 2, negative disables
+	Retests int
+
+	AuthMatrix   bool // compare access between Cookies and VictimCookies
+	VerbTamper   bool // retry denied requests with method overrides
+	PathBypass   bool // retry denied requests with path mutations
+	APIVersions  bool // retry denied and vulnerable requests on other API versions
+	ContentShift bool // retry denied requests with re-encoded bodies
+	MassAssign   bool // inject privileged fields into the JSON body of POST, PUT and PATCH targets
+	// Pollution sends OwnID together with denied IDs in duplicated, array
+	// and query-vs-body parameters; OwnID defaults to the first CanaryIDs.
+	// Generated IDs are shaped like OwnID when the target URL has no ID.
+	Pollution bool
+	OwnID     string
+	// Pagination lists the collection with OwnID using huge page sizes,
+	// offsets past the attacker's records and without owner filters
+	Pagination bool
+	// Methods asks the target which methods it allows with OPTIONS first
+	// and doesn't fuzz Target.Method unless it is one of them
+	Methods bool
+	// CORS sends a request, an IDOR finding if there is one, with attacker
+	// origins and reports those allowed to read it with credentials
+	CORS bool
+
+	// AllowDestructive permits Target.Method PUT, PATCH and DELETE.
+	// CanaryIDs then limits fuzzing to these IDs of resources the caller
+	// owns; each is verified with a GET of CanaryCheckURL (default
+	// Target.URL, using VictimCookies if set) before anything is sent.
+	AllowDestructive bool
+	CanaryIDs        []string
+	CanaryCheckURL   string
+
+	// Script is the source of a Starlark hook script defining
+	// on_request(req) and/or on_response(req, resp), see 'idorplus scan --help'
+	Script string
+
+	// Include and Exclude are scope rules, regular expressions matched
+	// against full URLs. Requests outside the scope fail with
+	// client.ErrOutOfScope. Logout URLs are always excluded.
+	Include []string
+	Exclude []string
+
+	// Proxies are rotated per request. UpstreamProxy routes all traffic
+	// through one intercepting proxy instead, e.g. Burp.
+	Proxies       []string
+	UpstreamProxy string
+
+	// Certificates are verified unless Insecure. CACert is a file of CA
+	// certificates trusted besides the system roots; PinSHA256 only
+	// accepts servers with one of these public keys, see client.ParsePin.
+	Insecure  bool
+	CACert    string
+	PinSHA256 []string
+
+	// Resolve pins host names to addresses, as host:addr or host:port:addr
+	// like curl --resolve. HostHeader replaces the Host header.
+	Resolve    []string
+	HostHeader string
+	// Bind is the local IP address or interface requests are sent from.
+	// PreferIPv6 connects to a target's IPv6 addresses first.
+	Bind       string
+	PreferIPv6 bool
+
+	// CSRFURL is fetched for an anti-CSRF token sent with POST, PUT, PATCH
+	// and DELETE requests, in CSRFHeader (default X-CSRF-Token) or the body
+	// field CSRFParam. CSRFExtract says where the token is, see
+	// client.ParseCSRFExtractor.
+	CSRFURL     string
+	CSRFExtract string
+	CSRFHeader  string
+	CSRFParam   string
+
+	// SessionFile keeps the cookies the target sets for the sessions
+	// across scans: restored before a scan if it exists, saved after it
+	SessionFile string
+}
+
+// Finding is a confirmed vulnerability
+type Finding struct {
+	Type        string // idor, verb_tamper, path_bypass, content_shift, mass_assignment, param_pollution, pagination or download
+	Technique   string // bypass technique, injected or polluted parameter, empty for plain IDOR
+	URL         string
+	Endpoint    string // URL with {ID}
+	Method      string
+	ID          string // the fuzzed ID
+	StatusCode  int
+	Severity    string
+	Confidence  int // 0-100, how many heuristics agree
+	CVSSScore   float64
+	CVSSVector  string
+	OWASP       []string
+	PII         map[string][]string
+	Evidence    string
+	Curl        string // command reproducing the request
+	Fingerprint string // stable across scans, see 'idorplus diff'
+	Timestamp   time.Time
+}
+
+// Stats summarizes the requests of a scan
+type Stats struct {
+	Requests   int64
+	Succeeded  int64
+	Failed     int64
+	Blocked    int64
+	Vulnerable int64
+	Skipped    int64 // IDs not sent after an early exit or for a slow endpoint
+	Untested   int64 // IDs not sent because the budget ran out
+	// Planned are the IDs the scan set out to test, Gaps those that did
+	// not get tested: failed, blocked, untested, or left when it stopped
+	Planned  int64
+	Gaps     int64
+	Duration time.Duration
+	// ConnsOpened and ConnsReused count the requests sent on a new and on
+	// a pooled keep-alive connection
+	ConnsOpened int64
+	ConnsReused int64
+	// SlowEndpoints were limited to one request at a time or skipped
+	SlowEndpoints []string
+	// BudgetExhausted is why the scan stopped short, "" if it did not
+	BudgetExhausted string
+}
+
+// Scanner runs IDOR scans. Several scans may run concurrently.
+//
+//	s, _ := idorplus.New(idorplus.Options{Count: 500, Concurrency: 20})
+//	scan, _ := s.Start(ctx, idorplus.Target{
+//		URL:     "https://api.example.com/users/{ID}",
+//		Cookies: "session=attacker",
+//	})
+//	for f := range scan.Findings {
+//		fmt.Println(f.Severity, f.URL)
+//	}
+//	stats, err := scan.Wait()
+type Scanner struct {
+	opts Options
+}
+
+// New creates a scanner
+func New(opts Options) (*Scanner, error) {
+	if opts.BypassMode != "" {
+		if err := utils.ValidateBypassMode(opts.BypassMode); err != nil {
+			return nil, err
+		}
+	}
+	if err := utils.ValidateThreshold(opts.Threshold); err != nil {
+		return nil, fmt.Errorf("threshold %w", err)
+	}
+	if opts.MinConfidence < 0 || opts.MinConfidence > 100 {
+		return nil, fmt.Errorf("min confidence must be between 0 and 100, got %d", opts.MinConfidence)
+	}
+	if opts.MaxRequests < 0 || opts.MaxDuration < 0 {
+		return nil, errors.New("max requests and max duration must not be negative")
+	}
+	ng := &generator.NumericGenerator{Start: opts.IDStart, End: opts.IDEnd, Step: opts.IDStep, Sample: opts.Sample, Checksum: opts.Checksum}
+	if err := ng.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ID range: %w", err)
+	}
+	if opts.Combine != "" && opts.Combine != scanner.CombineProduct && opts.Combine != scanner.CombineZip {
+		return nil, fmt.Errorf("combine must be %q or %q, got %q", scanner.CombineProduct, scanner.CombineZip, opts.Combine)
+	}
+	if opts.Template != "" {
+		if _, err := generator.ParseTemplate(opts.Template); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.SampleIDs) > 0 {
+		if _, err := analyzer.NewIdentifierAnalyzer().LearnFromSamples(opts.SampleIDs); err != nil {
+			return nil, err
+		}
+	}
+	for _, chain := range opts.Encodings {
+		if err := generator.ValidateEncoding(chain); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := client.NewScope(opts.Include, opts.Exclude); err != nil {
+		return nil, err
+	}
+	if _, err := client.NewResolver(opts.Resolve); err != nil {
+		return nil, err
+	}
+	for _, pin := range opts.PinSHA256 {
+		if _, err := client.ParsePin(pin); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := client.NewBinding(opts.Bind, opts.PreferIPv6); err != nil {
+		return nil, err
+	}
+	if opts.CSRFURL != "" {
+		if _, err := client.NewCSRFHandler(nil, opts.CSRFURL, opts.CSRFExtract); err != nil {
+			return nil, err
+		}
+	}
+	return &Scanner{opts: opts}, nil
+}
+
+// SetLogOutput redirects the progress messages idorplus prints, which go to
+// stdout by default. Use io.Discard to silence them. This is process-wide.
+func SetLogOutput(w io.Writer) {
+	utils.SetOutput(w)
+}
+
+// Scan is a running scan
+type Scan struct {
+	// Findings delivers findings as they are confirmed and is closed when
+	// the scan ends. It must be drained, or the scan stalls.
+	Findings <-chan Finding
+
+	done  chan struct{}
+	stats Stats
+	err   error
+}
+
+// Wait blocks until the scan ends. The error is ctx's error when the scan
+// was cancelled.
+func (s *Scan) Wait() (Stats, error) {
+	<-s.done
+	return s.stats, s.err
+}
+
+// Start begins scanning target in the background
+func (s *Scanner) Start(ctx context.Context, target Target) (*Scan, error) {
+	if target.URL == "" {
+		return nil, errors.New("target URL is required")
+	}
+	if target.Method != "" {
+		if err := utils.ValidateMethod(target.Method); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := s.config()
+	c := client.NewSmartClient(cfg)
+	scope, err := client.NewScope(cfg.Scope.Include, cfg.Scope.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	c.SetScope(scope)
+	if s.opts.UpstreamProxy != "" {
+		if err := c.SetUpstreamProxy(s.opts.UpstreamProxy, ""); err != nil {
+			return nil, fmt.Errorf("invalid upstream proxy: %w", err)
+		}
+	} else if len(s.opts.Proxies) > 0 {
+		c.SetProxies(s.opts.Proxies)
+	}
+	c.SetInsecure(s.opts.Insecure)
+	if s.opts.CACert != "" {
+		if err := c.SetCACert(s.opts.CACert); err != nil {
+			return nil, fmt.Errorf("invalid CA certificate: %w", err)
+		}
+	}
+	if err := c.SetPins(s.opts.PinSHA256); err != nil {
+		return nil, err
+	}
+	if len(s.opts.Resolve) > 0 {
+		if err := c.SetResolve(s.opts.Resolve); err != nil {
+			return nil, err
+		}
+	}
+	c.SetHostHeader(s.opts.HostHeader)
+	if s.opts.Bind != "" || s.opts.PreferIPv6 {
+		binding, err := client.NewBinding(s.opts.Bind, s.opts.PreferIPv6)
+		if err != nil {
+			return nil, err
+		}
+		c.SetBinding(binding)
+	}
+	if s.opts.CSRFURL != "" {
+		h, err := client.NewCSRFHandler(c, s.opts.CSRFURL, s.opts.CSRFExtract)
+		if err != nil {
+			return nil, err
+		}
+		if s.opts.CSRFHeader != "" {
+			h.Header = s.opts.CSRFHeader
+		}
+		h.Param = s.opts.CSRFParam
+		h.Install()
+	}
+
+	sc := scanner.New(c, cfg, s.scanOptions(target))
+	sc.SessionFile = s.opts.SessionFile
+
+	findings := make(chan Finding, 64)
+	scan := &Scan{Findings: findings, done: make(chan struct{})}
+	sc.Reporter.OnFinding = func(f *reporter.Finding) {
+		select {
+		case findings <- toFinding(f):
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(scan.done)
+		defer close(findings)
+
+		start := time.Now()
+		scan.err = sc.Run(ctx)
+		scan.stats.Duration = time.Since(start)
+		if sc.Engine != nil {
+			st := sc.Engine.Stats
+			scan.stats.Requests = st.TotalRequests
+			scan.stats.Succeeded = st.SuccessCount
+			scan.stats.Failed = st.FailedCount
+			scan.stats.Blocked = st.BlockedCount
+			scan.stats.Vulnerable = st.VulnCount
+			scan.stats.Skipped = st.SkippedCount
+			scan.stats.Untested = st.UntestedCount
+			planned, gaps := fuzzer.CoverageGaps(sc.Engine.Coverage())
+			scan.stats.Planned, scan.stats.Gaps = int64(planned), int64(gaps)
+			scan.stats.ConnsOpened = st.ConnsOpened
+			scan.stats.ConnsReused = st.ConnsReused
+			for _, e := range sc.Engine.SlowEndpoints() {
+				scan.stats.SlowEndpoints = append(scan.stats.SlowEndpoints, e.Endpoint)
+			}
+			if budget := sc.Reporter.Budget; budget != nil {
+				scan.stats.BudgetExhausted = budget.Reason
+			}
+		}
+	}()
+	return scan, nil
+}
+
+// Scan scans target and returns all findings once it ends
+func (s *Scanner) Scan(ctx context.Context, target Target) ([]Finding, Stats, error) {
+	scan, err := s.Start(ctx, target)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	var findings []Finding
+	for f := range scan.Findings {
+		findings = append(findings, f)
+	}
+	stats, err := scan.Wait()
+	return findings, stats, err
+}
+
+// config builds the internal configuration from the options
+func (s *Scanner) config() *utils.Config {
+	cfg := utils.DefaultConfig()
+	if s.opts.Concurrency > 0 {
+		cfg.Scanner.Threads = s.opts.Concurrency
+	}
+	cfg.Scanner.Delay = s.opts.Delay.String()
+	if s.opts.Timeout > 0 {
+		cfg.Scanner.Timeout = s.opts.Timeout.String()
+	}
+	if s.opts.JobTimeout > 0 {
+		cfg.Scanner.JobTimeout = s.opts.JobTimeout.String()
+	}
+	if s.opts.SlowP95 > 0 {
+		cfg.Scanner.SlowP95 = s.opts.SlowP95.String()
+	}
+	if s.opts.BypassMode != "" {
+		cfg.WAFBypass.Mode = s.opts.BypassMode
+		cfg.WAFBypass.Enabled = s.opts.BypassMode != "none"
+	}
+	if s.opts.Threshold > 0 {
+		cfg.Detection.Threshold = s.opts.Threshold
+	}
+	cfg.Detection.CheckPII = !s.opts.DisablePII
+	if s.opts.Retests != 0 {
+		cfg.Detection.Confirmations = max(s.opts.Retests, 0)
+	}
+	cfg.Scope.Include = s.opts.Include
+	cfg.Scope.Exclude = append(cfg.Scope.Exclude, s.opts.Exclude...)
+	return cfg
+}
+
+func (s *Scanner) scanOptions(t Target) scanner.Options {
+	headers := make([]string, 0, len(t.Headers))
+	for k, v := range t.Headers {
+		headers = append(headers, k+": "+v)
+	}
+	sort.Strings(headers)
+	var maxDuration string
+	if s.opts.MaxDuration > 0 {
+		maxDuration = s.opts.MaxDuration.String()
+	}
+	var minConfidence *int
+	if s.opts.MinConfidence > 0 {
+		minConfidence = &s.opts.MinConfidence
+	}
+
+	return scanner.Options{
+		URL:           t.URL,
+		Method:        t.Method,
+		Body:          t.Body,
+		Headers:       headers,
+		Cookies:       t.Cookies,
+		CookiesB:      t.VictimCookies,
+		Bearer:        t.BearerToken,
+		Payloads:      s.opts.IDs,
+		Count:         s.opts.Count,
+		Wordlists:     s.opts.Wordlists,
+		Combine:       s.opts.Combine,
+		IDStart:       s.opts.IDStart,
+		IDEnd:         s.opts.IDEnd,
+		IDStep:        s.opts.IDStep,
+		Sample:        s.opts.Sample,
+		Checksum:      s.opts.Checksum,
+		EstimateRange: s.opts.EstimateRange,
+		Template:      s.opts.Template,
+		SampleIDs:     s.opts.SampleIDs,
+		Encodings:     s.opts.Encodings,
+		Injection:     s.opts.Injection,
+		Threads:       s.opts.Concurrency,
+		Threshold:     s.opts.Threshold,
+		PII:           !s.opts.DisablePII,
+		AuthMatrix:    s.opts.AuthMatrix,
+		MaxFindings:   s.opts.MaxFindings,
+		MaxRequests:   s.opts.MaxRequests,
+		MaxDuration:   maxDuration,
+		VerbTamper:    s.opts.VerbTamper,
+		PathBypass:    s.opts.PathBypass,
+		APIVersions:   s.opts.APIVersions,
+		ContentShift:  s.opts.ContentShift,
+		MassAssign:    s.opts.MassAssign,
+		Pollution:     s.opts.Pollution,
+		Pagination:    s.opts.Pagination,
+		Methods:       s.opts.Methods,
+		CORS:          s.opts.CORS,
+		OwnID:         s.opts.OwnID,
+		Script:        s.opts.Script,
+
+		MinConfidence:    minConfidence,
+		AllowDestructive: s.opts.AllowDestructive,
+		CanaryIDs:        s.opts.CanaryIDs,
+		CanaryCheckURL:   s.opts.CanaryCheckURL,
+	}
+}
+
+func toFinding(f *reporter.Finding) Finding {
+	fingerprint := f.Fingerprint
+	if fingerprint == "" {
+		fingerprint = reporter.Fingerprint(f)
+	}
+	return Finding{
+		Type:        f.Type,
+		Technique:   f.Technique,
+		URL:         f.URL,
+		Endpoint:    f.Endpoint,
+		Method:      f.Method,
+		ID:          f.Payload,
+		StatusCode:  f.StatusCode,
+		Severity:    f.Severity,
+		Confidence:  f.Confidence,
+		CVSSScore:   f.CVSSScore,
+		CVSSVector:  f.CVSSVector,
+		OWASP:       f.OWASP,
+		PII:         f.PIIFound,
+		Evidence:    f.Evidence,
+		Curl:        f.Curl,
+		Fingerprint: fingerprint,
+		Timestamp:   f.Timestamp,
+	}
+}
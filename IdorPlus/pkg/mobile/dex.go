@@ -0,0 +1,82 @@
+package mobile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"regexp"
+	"strconv"
+)
+
+// maxString skips longer strings, they are data rather than URLs
+const maxString = 2048
+
+// dexStrings returns the string table of a DEX file: every literal,
+// annotation value (e.g. a Retrofit @GET path), class and member name
+func dexStrings(data []byte) []string {
+	if len(data) < 0x70 || !bytes.HasPrefix(data, []byte("dex\n")) {
+		return nil
+	}
+	size := binary.LittleEndian.Uint32(data[0x38:])
+	offset := binary.LittleEndian.Uint32(data[0x3C:])
+	if uint64(offset)+4*uint64(size) > uint64(len(data)) {
+		return nil
+	}
+
+	strs := make([]string, 0, size)
+	for i := uint32(0); i < size; i++ {
+		at := binary.LittleEndian.Uint32(data[offset+4*i:])
+		if int(at) >= len(data) {
+			continue
+		}
+		// ULEB128 length in UTF-16 units, then MUTF-8 up to a NUL
+		s := data[at:]
+		for len(s) > 0 && s[0]&0x80 != 0 {
+			s = s[1:]
+		}
+		if len(s) == 0 {
+			continue
+		}
+		s = s[1:]
+		end := bytes.IndexByte(s, 0)
+		if end < 0 || end > maxString {
+			continue
+		}
+		strs = append(strs, string(s[:end]))
+	}
+	return strs
+}
+
+// smaliString matches the literals of smali code, apktool's output
+var smaliString = regexp.MustCompile(`const-string(?:/jumbo)? [vp]\d+, ("(?:\\.|[^"\\])*")`)
+
+// smaliStrings returns the string literals of a smali file
+func smaliStrings(data []byte) []string {
+	var strs []string
+	for _, m := range smaliString.FindAllSubmatch(data, -1) {
+		if s, err := strconv.Unquote(string(m[1])); err == nil {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// printableStrings returns the runs of at least min printable ASCII
+// characters of a binary, like strings(1): the literals of a Mach-O
+// executable or a native library
+func printableStrings(data []byte, min int) []string {
+	var strs []string
+	start := -1
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && data[i] >= 0x20 && data[i] < 0x7F {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= min && i-start <= maxString {
+			strs = append(strs, string(data[start:i]))
+		}
+		start = -1
+	}
+	return strs
+}
@@ -0,0 +1,357 @@
+// Package mobile extracts the API endpoints of Android and iOS apps from
+// their packages: APKs, IPAs, or directories unpacked by apktool or unzip.
+package mobile
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"idorplus/pkg/crawler"
+	"idorplus/pkg/utils"
+)
+
+// Platforms of an analyzed app
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+)
+
+// maxFileSize skips larger files of a package, e.g. bundled media
+const maxFileSize = 64 << 20
+
+var (
+	// skipExts are media and compiled resources without endpoints
+	skipExts = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".ico", ".ttf", ".otf", ".woff", ".woff2",
+		".mp3", ".mp4", ".ogg", ".wav", ".arsc", ".car", ".nib", ".pdf", ".der", ".cer", ".RSA", ".SF", ".MF"}
+	// scriptExts are bundled JavaScript and web views: React Native,
+	// Cordova, Capacitor
+	scriptExts = []string{".js", ".mjs", ".jsbundle", ".bundle", ".html", ".htm"}
+
+	// formatVerb matches the format verbs of Java, Kotlin and Objective-C
+	// strings, where an app puts IDs into paths
+	formatVerb = regexp.MustCompile(`%(?:\d+\$)?[sd@]|%l?[du]`)
+	// apiPath matches strings shaped like an absolute URL, a path or a
+	// Retrofit relative path
+	apiPath = regexp.MustCompile(`^(?:https?://[A-Za-z0-9.-]+(?::\d+)?)?/?[A-Za-z0-9_.~%{}:$-]+(?:/[A-Za-z0-9_.~%{}:$-]*)+(?:\?[^\s"'<>]*)?$`)
+	// relativeAPI matches relative paths that are API calls rather than
+	// package or asset paths
+	relativeAPI = regexp.MustCompile(`^(?:api|rest|v\d+)/|\{[A-Za-z_][^}]*\}`)
+	// absoluteURL matches the scheme and host of URLs in text
+	absoluteURL = regexp.MustCompile(`https?://[A-Za-z0-9.-]+\.[A-Za-z]{2,}(?::\d+)?[^\s"'<>)]*`)
+	// localPath matches file system paths of Android and iOS
+	localPath = regexp.MustCompile(`^/(?:proc|system|data|sdcard|storage|mnt|dev|etc|sys|usr|s?bin|vendor|tmp|var|private|Library|Applications|System|Users|acct|cache|odm|product|apex)(?:/|$)`)
+	// assetExt matches paths of files, not endpoints
+	assetExt = regexp.MustCompile(`(?i)\.(java|kt|class|smali|so|xml|png|jpe?g|gif|webp|svg|ttf|otf|css|js|html?|dex|plist|nib|strings|json|txt|properties)$`)
+
+	// ignoredHosts serve schemas, docs and SDKs rather than the app's API
+	ignoredHosts = []string{"schemas.android.com", "w3.org", "ns.adobe.com", "apple.com", "xmlpull.org", "apache.org",
+		"xml.org", "json-schema.org", "purl.org", "schemas.microsoft.com", "example.com", "example.org", "localhost",
+		"developer.android.com", "play.google.com", "goo.gl", "github.com", "fb.me", "ietf.org", "mozilla.org", "jquery.com", "reactjs.org"}
+)
+
+// App is what an analyzed package holds
+type App struct {
+	Path     string
+	Platform string // android, ios, or empty when unknown
+	// Files and Strings are the files read and the strings pulled out of
+	// DEX, smali and binary files
+	Files   int
+	Strings int
+	// BaseURLs are the API roots the app references, most referenced first
+	BaseURLs []string
+
+	discoverer *crawler.ShadowAPIDiscoverer
+	urls       []string
+	roots      map[string]bool
+}
+
+// Analyze reads an APK or IPA, or a directory unpacked from one. DEX string
+// tables, smali literals and the strings of native and Mach-O binaries are
+// matched like JavaScript URLs; bundled scripts are parsed as JavaScript.
+func Analyze(pkg string) (*App, error) {
+	info, err := os.Stat(pkg)
+	if err != nil {
+		return nil, err
+	}
+	app := &App{Path: pkg, discoverer: crawler.NewShadowAPIDiscoverer(), roots: make(map[string]bool)}
+
+	if info.IsDir() {
+		err = filepath.WalkDir(pkg, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(pkg, p)
+			rel = filepath.ToSlash(rel)
+			if fi, err := d.Info(); err != nil || fi.Size() > maxFileSize || skipped(rel) {
+				return nil
+			}
+			if data, err := os.ReadFile(p); err == nil {
+				app.read(rel, data)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		r, err := zip.OpenReader(pkg)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an APK, IPA or directory: %w", pkg, err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() || f.UncompressedSize64 > maxFileSize || skipped(f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				continue
+			}
+			// The size in the zip header is not trusted, larger files are
+			// skipped all the same
+			data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+			rc.Close()
+			if err == nil && len(data) <= maxFileSize {
+				app.read(f.Name, data)
+			}
+		}
+	}
+
+	app.BaseURLs = app.baseURLs()
+	return app, nil
+}
+
+// skipped reports whether a file of the package holds no endpoints
+func skipped(name string) bool {
+	ext := path.Ext(name)
+	for _, e := range skipExts {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// read extracts the endpoints of one file of the package
+func (a *App) read(name string, data []byte) {
+	a.Files++
+	base := path.Base(name)
+	switch {
+	case name == "AndroidManifest.xml" || strings.HasPrefix(base, "classes") && strings.HasSuffix(base, ".dex"):
+		a.Platform = PlatformAndroid
+	case strings.HasPrefix(name, "Payload/") && strings.Contains(name, ".app/"):
+		a.Platform = PlatformIOS
+	}
+
+	var strs []string
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case ext == ".dex":
+		strs = dexStrings(data)
+	case ext == ".smali":
+		strs = smaliStrings(data)
+	case slices.Contains(scriptExts, ext) && utf8.Valid(data):
+		content := string(data)
+		a.discoverer.ExtractFromJS(content, name)
+		for _, u := range absoluteURL.FindAllString(content, -1) {
+			a.addBase(u)
+		}
+		return
+	case ext == ".json" && utf8.Valid(data):
+		a.discoverer.ExtractFromJSON(string(data), name)
+		strs = printableStrings(data, 6)
+	default:
+		// Mach-O executables, native libraries, Hermes bytecode, plists
+		strs = printableStrings(data, 6)
+	}
+	a.Strings += len(strs)
+	a.extract(name, strs)
+}
+
+// extract matches the strings of a file that look like API calls. They
+// are passed to the JavaScript patterns as fetch() calls, format verbs
+// turned into {id} and relative Retrofit paths made absolute.
+func (a *App) extract(name string, strs []string) {
+	var b strings.Builder
+	for _, s := range strs {
+		s = strings.TrimSpace(s)
+		for _, u := range absoluteURL.FindAllString(s, -1) {
+			a.addBase(u)
+		}
+		s = formatVerb.ReplaceAllString(s, "{id}")
+		if !apiPath.MatchString(s) || assetExt.MatchString(strings.SplitN(s, "?", 2)[0]) {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://"):
+			if !hasPath(s) || ignored(s) {
+				continue
+			}
+		case strings.HasPrefix(s, "/"):
+			if localPath.MatchString(s) {
+				continue
+			}
+		case relativeAPI.MatchString(s):
+			s = "/" + s
+		default:
+			continue
+		}
+		fmt.Fprintf(&b, "fetch(%q)\n", s)
+	}
+	if b.Len() > 0 {
+		a.discoverer.ExtractFromJS(b.String(), name)
+	}
+}
+
+// addBase records a URL the app references. A Retrofit-style base ending
+// in / is an API root of its own.
+func (a *App) addBase(u string) {
+	if ignored(u) {
+		return
+	}
+	a.urls = append(a.urls, u)
+	scheme, rest, _ := strings.Cut(u, "://")
+	if _, p, _ := strings.Cut(rest, "/"); p != "" && strings.HasSuffix(p, "/") && !strings.ContainsAny(p, "{?%") && strings.Count(p, "/") <= 2 {
+		a.roots[scheme+"://"+strings.TrimSuffix(rest, "/")] = true
+	}
+}
+
+// baseURLs returns the API roots of the URLs, most referenced first: the
+// longest root a URL starts with, or its scheme and host
+func (a *App) baseURLs() []string {
+	counts := make(map[string]int)
+	for _, u := range a.urls {
+		scheme, rest, _ := strings.Cut(u, "://")
+		host, _, _ := strings.Cut(rest, "/")
+		base := scheme + "://" + host
+		for root := range a.roots {
+			if (u == root || strings.HasPrefix(u, root+"/")) && len(root) > len(base) {
+				base = root
+			}
+		}
+		counts[base]++
+	}
+	bases := make([]string, 0, len(counts))
+	for base := range counts {
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool {
+		if counts[bases[i]] != counts[bases[j]] {
+			return counts[bases[i]] > counts[bases[j]]
+		}
+		return bases[i] < bases[j]
+	})
+	return bases
+}
+
+// Endpoints returns every endpoint found, sorted
+func (a *App) Endpoints() []crawler.EndpointInfo {
+	return sorted(a.discoverer.GetAllEndpoints())
+}
+
+// Candidates returns the endpoints with ID parameters, sorted
+func (a *App) Candidates() []crawler.EndpointInfo {
+	return sorted(a.discoverer.GetEndpointsWithIDParams())
+}
+
+func sorted(eps []crawler.EndpointInfo) []crawler.EndpointInfo {
+	sort.Slice(eps, func(i, j int) bool { return eps[i].URL < eps[j].URL })
+	return eps
+}
+
+// TargetURL returns the scan target of an endpoint: resolved against base
+// if relative, its first ID parameter replaced by {ID} and other path
+// parameters by 1. ok is false without an ID parameter or a base for a
+// relative endpoint.
+func TargetURL(base string, ep crawler.EndpointInfo) (string, bool) {
+	u := ep.URL
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		if base == "" {
+			return "", false
+		}
+		u = strings.TrimRight(base, "/") + "/" + strings.TrimLeft(u, "/")
+	}
+	scheme, rest, _ := strings.Cut(u, "://")
+	host, p := rest, ""
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		host, p = rest[:i], rest[i:]
+	}
+	p, query, hasQuery := strings.Cut(p, "?")
+
+	found := false
+	p = utils.ReplacePathParams(p, func(name string) string {
+		if !found && crawler.IsIDParam(name) {
+			found = true
+			return "{ID}"
+		}
+		return "1"
+	})
+	if hasQuery {
+		pairs := strings.Split(query, "&")
+		for i, pair := range pairs {
+			name, _, _ := strings.Cut(pair, "=")
+			if !found && crawler.IsIDParam(name) {
+				found = true
+				pairs[i] = name + "={ID}"
+			}
+		}
+		p += "?" + strings.Join(pairs, "&")
+	}
+	return scheme + "://" + host + p, found
+}
+
+// Targets returns the scan targets of the candidates on the host of base,
+// see TargetURL, and how many candidates on other hosts were left out: an
+// app calls third-party APIs too, which are not in scope.
+func Targets(base string, candidates []crawler.EndpointInfo) (targets []string, offHost int) {
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return nil, 0
+	}
+	seen := make(map[string]bool)
+	for _, ep := range candidates {
+		target, ok := TargetURL(base, ep)
+		if !ok || seen[target] {
+			continue
+		}
+		seen[target] = true
+		if t, err := url.Parse(target); err != nil || !strings.EqualFold(t.Host, u.Host) {
+			offHost++
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets, offHost
+}
+
+// hasPath reports whether an absolute URL has more than a root path
+func hasPath(u string) bool {
+	_, rest, _ := strings.Cut(u, "://")
+	_, p, ok := strings.Cut(rest, "/")
+	return ok && p != ""
+}
+
+// ignored reports whether a URL is on a host that isn't the app's API
+func ignored(u string) bool {
+	_, rest, _ := strings.Cut(u, "://")
+	host, _, _ := strings.Cut(rest, "/")
+	host, _, _ = strings.Cut(host, ":")
+	host = strings.ToLower(host)
+	for _, h := range ignoredHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// Webhook types
+const (
+	Slack   = "slack"
+	Discord = "discord"
+	Teams   = "teams"
+)
+
+// Notifier posts confirmed findings to chat webhooks in the background so
+// a slow webhook never stalls the scan
+type Notifier struct {
+	webhooks    []utils.WebhookConfig
+	minSeverity string
+	client      *http.Client
+
+	queue chan *reporter.Finding
+	wg    sync.WaitGroup
+}
+
+// NewNotifier creates a notifier from config. Returns nil when no webhooks
+// are configured; a nil Notifier ignores all calls.
+func NewNotifier(cfg utils.NotifyConfig) (*Notifier, error) {
+	if len(cfg.Webhooks) == 0 {
+		return nil, nil
+	}
+	for _, wh := range cfg.Webhooks {
+		switch strings.ToLower(wh.Type) {
+		case Slack, Discord, Teams:
+		default:
+			return nil, fmt.Errorf("unknown webhook type %q (want slack, discord or teams)", wh.Type)
+		}
+		if wh.URL == "" {
+			return nil, fmt.Errorf("%s webhook has no url", wh.Type)
+		}
+	}
+
+	minSeverity := strings.ToUpper(cfg.MinSeverity)
+	if minSeverity == "" {
+		minSeverity = "HIGH"
+	}
+
+	n := &Notifier{
+		webhooks:    cfg.Webhooks,
+		minSeverity: minSeverity,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		queue:       make(chan *reporter.Finding, 100),
+	}
+	n.wg.Add(1)
+	go n.run()
+	return n, nil
+}
+
+// Notify queues a finding for every webhook whose minimum severity it
+// meets. If the queue is full the alert is dropped rather than blocking the scan.
+// Alerts leave for chat services, so the finding is always redacted first,
+// see reporter.RedactFinding, and secrets in its URL are masked.
+func (n *Notifier) Notify(f *reporter.Finding) {
+	if n == nil {
+		return
+	}
+
+	// Snapshot, the finding may still be modified by the reporter
+	copied := reporter.RedactFinding(f)
+	copied.URL = client.MaskURL(f.URL)
+	select {
+	case n.queue <- copied:
+	default:
+		utils.Warning.Printf("Notification queue full, dropping alert for finding %s\n", f.ID)
+	}
+}
+
+// Close sends the remaining queued alerts and stops the notifier
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	close(n.queue)
+	n.wg.Wait()
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+
+	for f := range n.queue {
+		for _, wh := range n.webhooks {
+			min := n.minSeverity
+			if wh.MinSeverity != "" {
+				min = strings.ToUpper(wh.MinSeverity)
+			}
+			if !reporter.SeverityAtLeast(f.Severity, min) {
+				continue
+			}
+			if err := n.send(wh, f); err != nil {
+				utils.Warning.Printf("%s notification failed: %v\n", wh.Type, err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) send(wh utils.WebhookConfig, f *reporter.Finding) error {
+	body, err := json.Marshal(Payload(wh.Type, f))
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(wh.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Payload builds the webhook message body for a finding
+func Payload(webhookType string, f *reporter.Finding) any {
+	title := fmt.Sprintf("[%s] %s confirmed: %s %s", f.Severity, f.Type, f.Method, f.URL)
+
+	var details []string
+	if f.Payload != "" {
+		details = append(details, "Payload: "+f.Payload)
+	}
+	if f.Technique != "" {
+		details = append(details, "Technique: "+f.Technique)
+	}
+	details = append(details, fmt.Sprintf("Status: %d (%d bytes)", f.StatusCode, f.ContentLen))
+	if f.CVSSVector != "" {
+		details = append(details, fmt.Sprintf("CVSS: %.1f %s", f.CVSSScore, f.CVSSVector))
+	}
+	if len(f.PIIFound) > 0 {
+		var kinds []string
+		for k := range f.PIIFound {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		details = append(details, "PII: "+strings.Join(kinds, ", "))
+	}
+
+	switch strings.ToLower(webhookType) {
+	case Discord:
+		return map[string]any{
+			"username": "IdorPlus",
+			"embeds": []map[string]any{{
+				"title":       title,
+				"description": strings.Join(details, "\n"),
+				"color":       severityColor(f.Severity),
+			}},
+		}
+	case Teams:
+		return map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    title,
+			"themeColor": fmt.Sprintf("%06X", severityColor(f.Severity)),
+			"title":      title,
+			"text":       strings.Join(details, "<br>"),
+		}
+	default:
+		return map[string]any{
+			"text": "*" + title + "*\n" + strings.Join(details, "\n"),
+		}
+	}
+}
+
+func severityColor(severity string) int {
+	switch severity {
+	case "CRITICAL":
+		return 0x991B1B
+	case "HIGH":
+		return 0xDC2626
+	case "MEDIUM":
+		return 0xF59E0B
+	default:
+		return 0x10B981
+	}
+}
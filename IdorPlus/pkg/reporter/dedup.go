@@ -0,0 +1,171 @@
+package reporter
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fingerprint identifies a class of finding independent of the payload:
+// type, method, endpoint template and evidence class. Findings that only
+// differ by the enumerated ID share a fingerprint.
+func Fingerprint(f *Finding) string {
+	endpoint := f.Endpoint
+	if endpoint == "" {
+		endpoint = templateURL(f.URL, f.Payload)
+	}
+
+	parts := []string{f.Type, f.Technique, strings.ToUpper(f.Method), endpoint, evidenceClass(f)}
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// templateURL replaces the payload in a URL with {ID}
+func templateURL(rawURL, payload string) string {
+	if payload == "" {
+		return rawURL
+	}
+	return strings.ReplaceAll(rawURL, payload, "{ID}")
+}
+
+// evidenceClass summarises the shape of a response: status, top-level JSON
+// keys and the kinds of PII found, but not the values
+func evidenceClass(f *Finding) string {
+	class := strconv.Itoa(f.StatusCode)
+
+	var obj map[string]json.RawMessage
+	if json.Unmarshal([]byte(f.Evidence), &obj) == nil {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		class += " keys:" + strings.Join(keys, ",")
+	}
+
+	if len(f.PIIFound) > 0 {
+		kinds := make([]string, 0, len(f.PIIFound))
+		for k := range f.PIIFound {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		class += " pii:" + strings.Join(kinds, ",")
+	}
+
+	return class
+}
+
+// DedupFindings groups findings by fingerprint. The first finding of each
+// group is kept with AffectedIDs and IDRange filled in from the whole group.
+// Input order is preserved.
+func DedupFindings(findings []*Finding) []*Finding {
+	groups := make(map[string][]*Finding)
+	var order []string
+
+	for _, f := range findings {
+		fp := f.Fingerprint
+		if fp == "" {
+			fp = Fingerprint(f)
+		}
+		if _, ok := groups[fp]; !ok {
+			order = append(order, fp)
+		}
+		groups[fp] = append(groups[fp], f)
+	}
+
+	deduped := make([]*Finding, 0, len(order))
+	for _, fp := range order {
+		group := groups[fp]
+		if len(group) == 1 {
+			deduped = append(deduped, group[0])
+			continue
+		}
+
+		merged := *group[0]
+		merged.Fingerprint = fp
+		merged.AffectedIDs = nil
+		merged.OWASP = slices.Clone(merged.OWASP)
+		seen := make(map[string]bool)
+		for _, f := range group {
+			ids := f.AffectedIDs
+			if len(ids) == 0 {
+				ids = []string{f.Payload}
+			}
+			for _, id := range ids {
+				if id != "" && !seen[id] {
+					seen[id] = true
+					merged.AffectedIDs = append(merged.AffectedIDs, id)
+				}
+			}
+			if f.CVSSScore > merged.CVSSScore {
+				merged.CVSSScore, merged.CVSSVector = f.CVSSScore, f.CVSSVector
+			}
+			if severityRank(f.Severity) > severityRank(merged.Severity) {
+				merged.Severity = f.Severity
+			}
+			for _, c := range f.OWASP {
+				if !slices.Contains(merged.OWASP, c) {
+					merged.OWASP = append(merged.OWASP, c)
+				}
+			}
+		}
+		merged.IDRange = SummarizeIDs(merged.AffectedIDs)
+		deduped = append(deduped, &merged)
+	}
+
+	return deduped
+}
+
+// SummarizeIDs describes a set of IDs compactly. Numeric IDs are collapsed
+// into ranges ("1-40, 45, 50-80"), anything else is counted.
+func SummarizeIDs(ids []string) string {
+	nums := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return fmt.Sprintf("%d distinct IDs", len(ids))
+		}
+		nums = append(nums, n)
+	}
+	if len(nums) == 0 {
+		return ""
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+
+	var ranges []string
+	start, prev := nums[0], nums[0]
+	flush := func() {
+		if start == prev {
+			ranges = append(ranges, strconv.FormatInt(start, 10))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", start, prev))
+		}
+	}
+	for _, n := range nums[1:] {
+		if n == prev {
+			continue
+		}
+		if n != prev+1 {
+			flush()
+			start = n
+		}
+		prev = n
+	}
+	flush()
+
+	return fmt.Sprintf("%s (%d IDs)", strings.Join(ranges, ", "), len(nums))
+}
+
+func severityRank(severity string) int {
+	for i, s := range severityOrder {
+		if s == severity {
+			return len(severityOrder) - i
+		}
+	}
+	return 0
+}
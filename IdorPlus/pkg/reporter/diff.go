@@ -0,0 +1,51 @@
+package reporter
+
+// ReportDiff compares two scans by finding fingerprint
+type ReportDiff struct {
+	New        []*Finding `json:"new"`
+	Fixed      []*Finding `json:"fixed"`
+	Persisting []*Finding `json:"persisting"`
+}
+
+// DiffReports returns findings only in newer (new), only in older (fixed)
+// and in both (persisting, taken from newer). Findings are grouped by
+// fingerprint first so ungrouped and grouped reports compare equally.
+func DiffReports(older, newer *Report) *ReportDiff {
+	oldGroups := DedupFindings(fingerprinted(older.Findings))
+	newGroups := DedupFindings(fingerprinted(newer.Findings))
+
+	oldByFP := make(map[string]bool, len(oldGroups))
+	for _, f := range oldGroups {
+		oldByFP[f.Fingerprint] = true
+	}
+	newByFP := make(map[string]bool, len(newGroups))
+	for _, f := range newGroups {
+		newByFP[f.Fingerprint] = true
+	}
+
+	diff := &ReportDiff{}
+	for _, f := range newGroups {
+		if oldByFP[f.Fingerprint] {
+			diff.Persisting = append(diff.Persisting, f)
+		} else {
+			diff.New = append(diff.New, f)
+		}
+	}
+	for _, f := range oldGroups {
+		if !newByFP[f.Fingerprint] {
+			diff.Fixed = append(diff.Fixed, f)
+		}
+	}
+	return diff
+}
+
+// fingerprinted fills in fingerprints missing from reports written before
+// they were recorded
+func fingerprinted(findings []*Finding) []*Finding {
+	for _, f := range findings {
+		if f.Fingerprint == "" {
+			f.Fingerprint = Fingerprint(f)
+		}
+	}
+	return findings
+}
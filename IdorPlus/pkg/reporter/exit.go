@@ -0,0 +1,44 @@
+package reporter
+
+import "strings"
+
+// Exit codes of scan and export, for CI gating
+const (
+	ExitClean      = 0 // the scan completed with no finding at or above --fail-on
+	ExitError      = 1 // the scan failed or its report could not be saved
+	ExitFindings   = 2 // findings at or above --fail-on
+	ExitIncomplete = 3 // the scan was interrupted or cut short by its budget
+)
+
+// Incomplete reports whether the scan stopped before testing every job,
+// interrupted or cut short by its budget
+func (r *Reporter) Incomplete() bool {
+	return r.Interrupted || r.Budget != nil
+}
+
+// ExitCode maps the outcome of a scan to its exit code. A report that could
+// not be saved fails the scan; findings at or above failOn ("" for none)
+// come next, an incomplete scan still found them; an incomplete scan
+// without them is not clean, what it did not test is unknown.
+func ExitCode(findings []*Finding, failOn string, incomplete, saveFailed bool) int {
+	switch {
+	case saveFailed:
+		return ExitError
+	case failOn != "" && CountAtLeast(findings, failOn) > 0:
+		return ExitFindings
+	case incomplete:
+		return ExitIncomplete
+	}
+	return ExitClean
+}
+
+// CountAtLeast counts the findings at or above a severity, in any case
+func CountAtLeast(findings []*Finding, severity string) int {
+	n := 0
+	for _, f := range findings {
+		if SeverityAtLeast(f.Severity, strings.ToUpper(severity)) {
+			n++
+		}
+	}
+	return n
+}
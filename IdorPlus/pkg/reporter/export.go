@@ -0,0 +1,239 @@
+package reporter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSONLines returns a Reporter.OnFinding callback writing each finding to w
+// as one line of JSON
+func JSONLines(w io.Writer) func(*Finding) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(f *Finding) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(f)
+	}
+}
+
+// Curl returns a copy-pasteable curl command reproducing the request
+func (rr *RecordedRequest) Curl() string {
+	parts := []string{"curl", "-i", "-s", "-k", "-X", shellQuote(rr.Method)}
+
+	names := make([]string, 0, len(rr.Headers))
+	for name := range rr.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// The client advertises the encodings it decodes, curl decodes its own
+		if http.CanonicalHeaderKey(name) == "Accept-Encoding" {
+			parts = append(parts, "--compressed")
+			continue
+		}
+		parts = append(parts, "-H", shellQuote(name+": "+rr.Headers[name]))
+	}
+
+	if rr.Body != "" {
+		parts = append(parts, "--data-binary", shellQuote(rr.Body))
+	}
+	parts = append(parts, shellQuote(rr.URL))
+
+	return strings.Join(parts, " ")
+}
+
+// shellQuote wraps s in single quotes for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// burpIssues mirrors the XML written by Burp's "Report selected issues" (XML)
+type burpIssues struct {
+	XMLName     xml.Name    `xml:"issues"`
+	BurpVersion string      `xml:"burpVersion,attr"`
+	ExportTime  string      `xml:"exportTime,attr"`
+	Issues      []burpIssue `xml:"issue"`
+}
+
+type burpIssue struct {
+	SerialNumber    string           `xml:"serialNumber"`
+	Type            string           `xml:"type"`
+	Name            string           `xml:"name"`
+	Host            burpHost         `xml:"host"`
+	Path            string           `xml:"path"`
+	Location        string           `xml:"location"`
+	Severity        string           `xml:"severity"`
+	Confidence      string           `xml:"confidence"`
+	IssueBackground string           `xml:"issueBackground"`
+	Remediation     string           `xml:"remediationBackground"`
+	IssueDetail     string           `xml:"issueDetail"`
+	RequestResponse *burpRequestResp `xml:"requestresponse,omitempty"`
+}
+
+type burpHost struct {
+	IP   string `xml:"ip,attr"`
+	Host string `xml:",chardata"`
+}
+
+type burpRequestResp struct {
+	Request  burpMessage  `xml:"request"`
+	Response *burpMessage `xml:"response,omitempty"`
+}
+
+type burpMessage struct {
+	Method string `xml:"method,attr,omitempty"`
+	Base64 bool   `xml:"base64,attr"`
+	Data   string `xml:",chardata"`
+}
+
+// burpIssueType is Burp's extension-generated issue type ID
+const burpIssueType = "134217728"
+
+const burpBackground = "Insecure direct object references (IDOR) occur when an application uses " +
+	"user-supplied identifiers to access objects without verifying that the requesting user " +
+	"is authorized to access them."
+
+const burpRemediation = "Enforce object-level authorization on every request, checking that the " +
+	"authenticated user owns or may access the referenced object."
+
+// generateBurpXML writes findings as Burp Suite issues
+func (r *Reporter) generateBurpXML(filename string, report *Report) error {
+	doc := burpIssues{
+		BurpVersion: "idorplus",
+		ExportTime:  time.Now().Format("Mon Jan 02 15:04:05 MST 2006"),
+	}
+
+	for _, f := range report.Findings {
+		doc.Issues = append(doc.Issues, toBurpIssue(f))
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return os.WriteFile(filename, data, 0644)
+}
+
+func toBurpIssue(f *Finding) burpIssue {
+	host, path := f.URL, ""
+	if u, err := url.Parse(f.URL); err == nil {
+		host = u.Scheme + "://" + u.Host
+		path = u.RequestURI()
+	}
+
+	detail := fmt.Sprintf("Payload <b>%s</b> returned status %d (%d bytes).",
+		xmlText(f.Payload), f.StatusCode, f.ContentLen)
+	if f.CVSSVector != "" {
+		detail += fmt.Sprintf("<br>CVSS %.1f (%s)", f.CVSSScore, f.CVSSVector)
+	}
+	for _, c := range f.OWASP {
+		detail += "<br>OWASP " + xmlText(c)
+	}
+	if f.IDRange != "" {
+		detail += fmt.Sprintf("<br>Affected IDs: %s", xmlText(f.IDRange))
+	}
+	if f.Technique != "" {
+		detail += fmt.Sprintf("<br>Technique: %s", xmlText(f.Technique))
+	}
+	if f.Request != nil {
+		detail += "<br><br>Reproduce with:<br><pre>" + xmlText(f.Request.Curl()) + "</pre>"
+	}
+
+	issue := burpIssue{
+		SerialNumber:    f.ID,
+		Type:            burpIssueType,
+		Name:            burpIssueName(f.Type),
+		Host:            burpHost{Host: host},
+		Path:            path,
+		Location:        path,
+		Severity:        burpSeverity(f.Severity),
+		Confidence:      "Firm",
+		IssueBackground: burpBackground,
+		Remediation:     burpRemediation,
+		IssueDetail:     detail,
+	}
+
+	if f.Request != nil {
+		rr := &burpRequestResp{
+			Request: burpMessage{
+				Method: f.Request.Method,
+				Base64: true,
+				Data:   base64.StdEncoding.EncodeToString([]byte(crlf(f.Request.Dump()))),
+			},
+		}
+		if f.Evidence != "" {
+			resp := fmt.Sprintf("HTTP/1.1 %d\r\n\r\n%s", f.StatusCode, f.Evidence)
+			rr.Response = &burpMessage{Base64: true, Data: base64.StdEncoding.EncodeToString([]byte(resp))}
+		}
+		issue.RequestResponse = rr
+	}
+
+	return issue
+}
+
+func burpIssueName(findingType string) string {
+	switch findingType {
+	case FindingVerbTamper:
+		return "Access control bypass via HTTP verb tampering"
+	case FindingPathBypass:
+		return "Access control bypass via path normalization"
+	case FindingContentShift:
+		return "Access control bypass via Content-Type shifting"
+	case FindingMassAssign:
+		return "Mass assignment"
+	case FindingParamPollution:
+		return "Access control bypass via HTTP parameter pollution"
+	case FindingAPIVersion:
+		return "Access control bypass via alternate API version"
+	case FindingPagination:
+		return "Other users' records exposed via pagination or filter tampering"
+	case FindingDownload:
+		return "Other users' files exposed via an export or download endpoint"
+	case FindingCORS:
+		return "Authenticated responses readable cross-origin via permissive CORS"
+	default:
+		return "Insecure direct object reference (IDOR)"
+	}
+}
+
+// burpSeverity maps our severities onto Burp's High/Medium/Low/Information
+func burpSeverity(severity string) string {
+	switch severity {
+	case "CRITICAL", "HIGH":
+		return "High"
+	case "MEDIUM":
+		return "Medium"
+	case "LOW":
+		return "Low"
+	default:
+		return "Information"
+	}
+}
+
+// crlf converts a dumped message to CRLF line endings for the header block
+func crlf(message string) string {
+	head, body, found := strings.Cut(message, "\n\n")
+	head = strings.ReplaceAll(head, "\n", "\r\n") + "\r\n\r\n"
+	if !found {
+		return head
+	}
+	return head + body
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
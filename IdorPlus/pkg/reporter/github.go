@@ -0,0 +1,127 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxTopFindings caps the findings annotated and listed in the step
+// summary, GitHub shows 10 annotations of a level per step
+const maxTopFindings = 10
+
+// InGitHubActions reports whether idorplus runs in a GitHub Actions job
+func InGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// PublishGitHub surfaces the findings in GitHub Actions: a summary appended
+// to the job's step summary, $GITHUB_STEP_SUMMARY, and an annotation per
+// top finding written to w as workflow commands
+func (r *Reporter) PublishGitHub(w io.Writer) error {
+	findings := topFindings(r.reportFindings(r.Snapshot()))
+	writeAnnotations(w, findings)
+
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := r.writeStepSummary(f, findings); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// topFindings returns findings most severe first
+func topFindings(findings []*Finding) []*Finding {
+	sorted := make([]*Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if a, b := severityRank(sorted[i].Severity), severityRank(sorted[j].Severity); a != b {
+			return a > b
+		}
+		return sorted[i].CVSSScore > sorted[j].CVSSScore
+	})
+	return sorted
+}
+
+// writeStepSummary writes the counts by severity and the top findings as
+// GitHub flavored markdown
+func (r *Reporter) writeStepSummary(w io.Writer, findings []*Finding) error {
+	var b strings.Builder
+	b.WriteString("## IdorPlus scan\n\n")
+	if r.Scan != nil && r.Scan.Target != "" {
+		fmt.Fprintf(&b, "Target: `%s`\n\n", r.Scan.Target)
+	}
+	if len(findings) == 0 {
+		b.WriteString(":white_check_mark: No vulnerabilities found\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	fmt.Fprintf(&b, ":warning: **%d vulnerabilities found**\n\n", len(findings))
+	b.WriteString("| Critical | High | Medium | Low |\n|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d |\n\n", counts["CRITICAL"], counts["HIGH"], counts["MEDIUM"], counts["LOW"])
+
+	b.WriteString("| Severity | Type | Method | URL | IDs |\n|---|---|---|---|---|\n")
+	for i, f := range findings {
+		if i == maxTopFindings {
+			fmt.Fprintf(&b, "\n%d more in the report\n", len(findings)-maxTopFindings)
+			break
+		}
+		ids := f.IDRange
+		if ids == "" {
+			ids = f.Payload
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | `%s` | %s |\n", f.Severity, f.Type, f.Method,
+			strings.ReplaceAll(f.URL, "|", "%7C"), strings.ReplaceAll(ids, "|", "\\|"))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeAnnotations writes a workflow command per top finding, shown as an
+// error, warning or notice annotation by severity
+func writeAnnotations(w io.Writer, findings []*Finding) {
+	for i, f := range findings {
+		if i == maxTopFindings {
+			break
+		}
+		level := "notice"
+		switch f.Severity {
+		case "CRITICAL", "HIGH":
+			level = "error"
+		case "MEDIUM":
+			level = "warning"
+		}
+		title := fmt.Sprintf("%s %s", f.Severity, f.Type)
+		msg := fmt.Sprintf("%s %s returned %d", f.Method, f.URL, f.StatusCode)
+		if f.IDRange != "" {
+			msg += " for IDs " + f.IDRange
+		} else if f.Payload != "" {
+			msg += " for ID " + f.Payload
+		}
+		fmt.Fprintf(w, "::%s title=%s::%s\n", level, escapeProperty(title), escapeData(msg))
+	}
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
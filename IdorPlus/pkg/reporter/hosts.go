@@ -0,0 +1,155 @@
+package reporter
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// HostSection is one host's part of a report covering several hosts
+type HostSection struct {
+	Host       string         `json:"host"`
+	FindingIDs []string       `json:"finding_ids,omitempty"`
+	Severities map[string]int `json:"severities,omitempty"`
+	Endpoints  int            `json:"endpoints"`
+	MaxCVSS    float64        `json:"max_cvss"`
+
+	// Findings are the host's findings, for the markdown and HTML layouts
+	Findings []*Finding `json:"-"`
+}
+
+// Summary is the executive summary of a report covering several hosts
+type Summary struct {
+	Hosts         int            `json:"hosts"`
+	AffectedHosts int            `json:"affected_hosts"`
+	Severities    map[string]int `json:"severities"`
+
+	// TypeHosts is on how many hosts each finding type was found
+	TypeHosts map[string]int `json:"type_hosts"`
+
+	Text string `json:"text"`
+}
+
+// findingHost returns the host of a finding's URL
+func findingHost(f *Finding) string {
+	u, err := url.Parse(f.URL)
+	if err != nil || u.Host == "" {
+		return f.URL
+	}
+	return u.Host
+}
+
+// hostSections groups findings by host. Hosts of targets without any
+// finding get an empty section. Nil is returned for a single host, whose
+// report needs no sections.
+func hostSections(findings []*Finding, targets []string) []*HostSection {
+	byHost := make(map[string]*HostSection)
+	section := func(host string) *HostSection {
+		s, ok := byHost[host]
+		if !ok {
+			s = &HostSection{Host: host, Severities: make(map[string]int)}
+			byHost[host] = s
+		}
+		return s
+	}
+	for _, target := range targets {
+		section(findingHost(&Finding{URL: target}))
+	}
+
+	endpoints := make(map[string]map[string]bool)
+	for _, f := range findings {
+		host := findingHost(f)
+		s := section(host)
+		s.Findings = append(s.Findings, f)
+		s.FindingIDs = append(s.FindingIDs, f.ID)
+		s.Severities[f.Severity]++
+		s.MaxCVSS = max(s.MaxCVSS, f.CVSSScore)
+
+		if endpoints[host] == nil {
+			endpoints[host] = make(map[string]bool)
+		}
+		endpoint := f.Endpoint
+		if endpoint == "" {
+			endpoint = templateURL(f.URL, f.Payload)
+		}
+		endpoints[host][f.Method+" "+endpoint] = true
+	}
+	if len(byHost) < 2 {
+		return nil
+	}
+
+	sections := make([]*HostSection, 0, len(byHost))
+	for host, s := range byHost {
+		s.Endpoints = len(endpoints[host])
+		sections = append(sections, s)
+	}
+	// Most severe hosts first
+	sort.Slice(sections, func(i, j int) bool {
+		a, b := sections[i], sections[j]
+		if a.MaxCVSS != b.MaxCVSS {
+			return a.MaxCVSS > b.MaxCVSS
+		}
+		if len(a.Findings) != len(b.Findings) {
+			return len(a.Findings) > len(b.Findings)
+		}
+		return a.Host < b.Host
+	})
+	return sections
+}
+
+// summarize builds the executive summary of the host sections
+func summarize(sections []*HostSection) *Summary {
+	sum := &Summary{
+		Hosts:      len(sections),
+		Severities: make(map[string]int),
+		TypeHosts:  make(map[string]int),
+	}
+	total := 0
+	for _, s := range sections {
+		if len(s.Findings) > 0 {
+			sum.AffectedHosts++
+		}
+		total += len(s.Findings)
+		types := make(map[string]bool)
+		for _, f := range s.Findings {
+			sum.Severities[f.Severity]++
+			types[f.Type] = true
+		}
+		for t := range types {
+			sum.TypeHosts[t]++
+		}
+	}
+
+	text := fmt.Sprintf("%d findings on %d of %d hosts", total, sum.AffectedHosts, sum.Hosts)
+	var counts []string
+	for _, sev := range severityOrder {
+		if n := sum.Severities[sev]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, strings.ToLower(sev)))
+		}
+	}
+	if len(counts) > 0 {
+		text += " (" + strings.Join(counts, ", ") + ")"
+	}
+	text += "."
+	if total > 0 {
+		worst := sections[0]
+		text += fmt.Sprintf(" Most exposed: %s with %d findings up to CVSS %.1f.", worst.Host, len(worst.Findings), worst.MaxCVSS)
+	}
+	if common, hosts := mostWidespread(sum.TypeHosts); hosts > 1 {
+		text += fmt.Sprintf(" %s was found on %d hosts, pointing to a shared flaw.", common, hosts)
+	}
+	sum.Text = text
+	return sum
+}
+
+// mostWidespread returns the finding type found on the most hosts
+func mostWidespread(typeHosts map[string]int) (string, int) {
+	best, hosts := "", 0
+	for t, n := range typeHosts {
+		if n > hosts || n == hosts && t < best {
+			best, hosts = t, n
+		}
+	}
+	return best, hosts
+}
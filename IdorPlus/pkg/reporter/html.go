@@ -0,0 +1,314 @@
+package reporter
+
+import (
+	"html"
+	"html/template"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+)
+
+// severityOrder lists severities from most to least severe
+var severityOrder = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW"}
+
+// chartBar is one row of a bar chart
+type chartBar struct {
+	Label   string
+	Count   int
+	Percent int
+	Class   string
+}
+
+// reportData is the data passed to the HTML template and custom templates
+type reportData struct {
+	*Report
+	Generated  string
+	Severities []chartBar
+	Types      []chartBar
+	// StatusCodes charts the responses of Statistics
+	StatusCodes []chartBar
+	// Planned and Gaps total the jobs of Coverage
+	Planned, Gaps int
+}
+
+func newReportData(report *Report) *reportData {
+	data := &reportData{
+		Report:     report,
+		Generated:  time.Now().Format(time.RFC1123),
+		Severities: severityChart(report.Findings),
+		Types:      typeChart(report.Findings),
+
+		StatusCodes: statusChart(report.Statistics),
+	}
+	data.Planned, data.Gaps = fuzzer.CoverageGaps(report.Coverage)
+	return data
+}
+
+// generateHTML outputs a self-contained HTML report, or one in the layout
+// of the custom template
+func (r *Reporter) generateHTML(filename string, report *Report) error {
+	layout := htmlTemplate
+	if r.Template != "" {
+		layout = r.Template
+	}
+	tmpl, err := template.New("report").Funcs(htmlFuncs()).Parse(layout)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tmpl.Execute(f, newReportData(report))
+}
+
+func severityChart(findings []*Finding) []chartBar {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+
+	var bars []chartBar
+	for _, sev := range severityOrder {
+		bars = append(bars, chartBar{
+			Label:   sev,
+			Count:   counts[sev],
+			Percent: percent(counts[sev], len(findings)),
+			Class:   strings.ToLower(sev),
+		})
+	}
+	return bars
+}
+
+func typeChart(findings []*Finding) []chartBar {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Type]++
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return counts[names[i]] > counts[names[j]] })
+
+	var bars []chartBar
+	for _, name := range names {
+		bars = append(bars, chartBar{
+			Label:   name,
+			Count:   counts[name],
+			Percent: percent(counts[name], len(findings)),
+			Class:   "type",
+		})
+	}
+	return bars
+}
+
+// statusChart charts the responses by status code, nil without statistics
+func statusChart(st *fuzzer.Breakdown) []chartBar {
+	if st == nil {
+		return nil
+	}
+	codes := make([]int, 0, len(st.StatusCodes))
+	total := 0
+	for code, n := range st.StatusCodes {
+		codes = append(codes, code)
+		total += int(n)
+	}
+	sort.Ints(codes)
+
+	var bars []chartBar
+	for _, code := range codes {
+		n := int(st.StatusCodes[code])
+		bars = append(bars, chartBar{
+			Label:   fuzzer.StatusLabel(code),
+			Count:   n,
+			Percent: percent(n, total),
+			Class:   "status",
+		})
+	}
+	return bars
+}
+
+func percent(n, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return n * 100 / total
+}
+
+// highlightPII escapes evidence and wraps every PII match in <mark>
+func highlightPII(evidence string, pii map[string][]string) template.HTML {
+	escaped := html.EscapeString(evidence)
+
+	var alternatives []string
+	for _, matches := range pii {
+		for _, m := range matches {
+			if m != "" {
+				alternatives = append(alternatives, regexp.QuoteMeta(html.EscapeString(m)))
+			}
+		}
+	}
+	if len(alternatives) == 0 {
+		return template.HTML(escaped)
+	}
+
+	// Longest first so a match is never split by a shorter one
+	sort.Slice(alternatives, func(i, j int) bool { return len(alternatives[i]) > len(alternatives[j]) })
+	re := regexp.MustCompile(strings.Join(alternatives, "|"))
+	return template.HTML(re.ReplaceAllString(escaped, "<mark>$0</mark>"))
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>IdorPlus Report</title>
+<style>
+body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;margin:0;background:#f5f6f8;color:#222}
+header{background:#1f2937;color:#fff;padding:20px 32px}
+header h1{margin:0 0 6px;font-size:22px}
+header p{margin:0;color:#cbd5e1;font-size:13px}
+main{padding:24px 32px;max-width:1200px}
+.cards{display:flex;gap:16px;flex-wrap:wrap;margin-bottom:24px}
+.card{background:#fff;border-radius:6px;padding:16px 20px;box-shadow:0 1px 2px rgba(0,0,0,.08);flex:1;min-width:280px}
+.card h2{font-size:15px;margin:0 0 12px}
+.bar{display:flex;align-items:center;margin:6px 0;font-size:13px}
+.bar span.label{width:110px}
+.bar .track{flex:1;background:#eef0f3;border-radius:3px;height:14px;margin:0 8px}
+.bar .fill{height:14px;border-radius:3px}
+.critical{background:#991b1b}.high{background:#dc2626}.medium{background:#f59e0b}.low{background:#10b981}.type{background:#3b82f6}.status{background:#64748b}
+.toolbar{display:flex;gap:12px;margin-bottom:12px}
+.toolbar input,.toolbar select{padding:6px 8px;border:1px solid #cbd5e1;border-radius:4px;font-size:13px}
+.toolbar input{flex:1}
+.finding{background:#fff;border-radius:6px;margin-bottom:10px;box-shadow:0 1px 2px rgba(0,0,0,.08)}
+.finding summary{padding:12px 16px;cursor:pointer;display:flex;gap:12px;align-items:center;font-size:14px}
+.finding .body{padding:0 16px 16px;font-size:13px}
+.sev{color:#fff;border-radius:3px;padding:2px 6px;font-size:11px;font-weight:600}
+.url{font-family:Menlo,Consolas,monospace;word-break:break-all}
+pre{background:#0f172a;color:#e2e8f0;padding:12px;border-radius:4px;overflow:auto;max-height:400px;white-space:pre-wrap;word-break:break-all}
+mark{background:#fde047;color:#000}
+table.meta td{padding:2px 12px 2px 0;vertical-align:top}
+.pii{color:#b45309}
+.summary,.params,.stats{margin-bottom:24px}
+.params summary,.stats summary{cursor:pointer;font-weight:600;font-size:15px}
+.params table,.stats table{margin-top:12px;font-size:13px}
+table.hosts{border-collapse:collapse;font-size:13px}
+table.hosts th,table.hosts td{padding:4px 12px 4px 0;text-align:left}
+h2.host{font-size:17px;margin:24px 0 10px}
+</style>
+</head>
+<body>
+<header>
+<h1>IdorPlus Scan Report</h1>
+{{if .TargetURL}}<p class="url">{{.TargetURL}}</p>{{end}}
+<p>Scan started {{.ScanTime.Format "2006-01-02 15:04:05"}} &middot; Duration {{.Duration}} &middot; {{.VulnCount}} findings{{if .RawCount}} ({{.RawCount}} before grouping){{end}}{{if .Interrupted}} &middot; <strong>interrupted, partial results</strong>{{end}} &middot; Generated {{.Generated}}</p>
+</header>
+<main>
+<div class="cards">
+<div class="card"><h2>Severity</h2>
+{{range .Severities}}<div class="bar"><span class="label">{{.Label}}</span><div class="track"><div class="fill {{.Class}}" style="width:{{.Percent}}%"></div></div><span>{{.Count}}</span></div>
+{{end}}</div>
+<div class="card"><h2>Finding Types</h2>
+{{range .Types}}<div class="bar"><span class="label">{{.Label}}</span><div class="track"><div class="fill {{.Class}}" style="width:{{.Percent}}%"></div></div><span>{{.Count}}</span></div>
+{{else}}<p>No findings</p>{{end}}</div>
+</div>
+
+{{with .Scan.Parameters}}
+<details class="card params"><summary>Scan parameters</summary>
+<table class="meta">
+{{range .}}<tr><td>{{index . 0}}</td><td><code>{{index . 1}}</code></td></tr>
+{{end}}</table>
+</details>
+{{end}}
+
+{{with .Statistics}}
+<details class="card stats"><summary>Statistics</summary>
+<p>{{bytes .Bytes}} received &middot; Latency {{.Latency}}</p>
+{{range $.StatusCodes}}<div class="bar"><span class="label">{{.Label}}</span><div class="track"><div class="fill {{.Class}}" style="width:{{.Percent}}%"></div></div><span>{{.Count}}</span></div>
+{{end}}
+<table class="hosts">
+<tr><th>Endpoint</th><th>Requests</th><th>Errors</th><th>Vulnerable</th><th>Status codes</th><th>p50</th><th>p95</th><th>Received</th></tr>
+{{range .Endpoints}}<tr><td class="url">{{.Endpoint}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{.Vulnerable}}</td><td>{{statusLine .StatusCodes}}</td><td>{{ms .Latency.P50}}</td><td>{{ms .Latency.P95}}</td><td>{{bytes .Bytes}}</td></tr>
+{{end}}</table>
+</details>
+{{end}}
+
+{{with .Coverage}}
+<details class="card stats"{{if $.Gaps}} open{{end}}><summary>Coverage</summary>
+{{if $.Gaps}}<p><strong>{{$.Gaps}} of {{$.Planned}} planned jobs did not test their ID</strong></p>{{else}}<p>All {{$.Planned}} planned jobs were tested or skipped on purpose</p>{{end}}
+<table class="hosts">
+<tr><th>Endpoint</th><th>Planned</th><th>Tested</th><th>Failed</th><th>Blocked</th><th>Skipped</th><th>Untested</th><th>Missing</th><th>Vulnerable</th></tr>
+{{range .}}<tr><td class="url">{{.Endpoint}}</td><td>{{.Planned}}</td><td>{{.Tested}}</td><td>{{.Failed}}</td><td>{{.Blocked}}</td><td>{{.Skipped}}</td><td>{{.Untested}}</td><td>{{.Missing}}</td><td>{{.Vulnerable}}</td></tr>
+{{end}}</table>
+</details>
+{{end}}
+
+{{if .Summary}}
+<div class="card summary"><h2>Executive Summary</h2>
+<p>{{.Summary.Text}}</p>
+<table class="hosts">
+<tr><th>Host</th><th>Findings</th><th>Critical</th><th>High</th><th>Medium</th><th>Low</th><th>Endpoints</th><th>Max CVSS</th></tr>
+{{range .Hosts}}<tr><td class="url">{{.Host}}</td><td>{{len .Findings}}</td><td>{{index .Severities "CRITICAL"}}</td><td>{{index .Severities "HIGH"}}</td><td>{{index .Severities "MEDIUM"}}</td><td>{{index .Severities "LOW"}}</td><td>{{.Endpoints}}</td><td>{{printf "%.1f" .MaxCVSS}}</td></tr>
+{{end}}</table>
+</div>
+{{end}}
+
+<div class="toolbar">
+<input id="search" type="search" placeholder="Search URL, payload, evidence...">
+<select id="severity"><option value="">All severities</option><option>CRITICAL</option><option>HIGH</option><option>MEDIUM</option><option>LOW</option></select>
+</div>
+
+{{if .Summary}}
+{{range .Hosts}}
+<h2 class="host">{{.Host}}</h2>
+{{range .Findings}}{{template "finding" .}}{{else}}<p>No findings</p>{{end}}
+{{end}}
+{{else}}
+{{range .Findings}}{{template "finding" .}}{{end}}
+{{end}}
+</main>
+<script>
+(function(){
+  var search=document.getElementById('search'),sev=document.getElementById('severity');
+  function filter(){
+    var q=search.value.toLowerCase(),s=sev.value;
+    document.querySelectorAll('.finding').forEach(function(el){
+      var ok=(!s||el.dataset.severity===s)&&(!q||el.textContent.toLowerCase().indexOf(q)!==-1);
+      el.style.display=ok?'':'none';
+    });
+  }
+  search.addEventListener('input',filter);sev.addEventListener('change',filter);
+})();
+</script>
+</body>
+</html>
+{{define "finding"}}
+<details class="finding" data-severity="{{.Severity}}">
+<summary><span class="sev {{lower .Severity}}">{{.Severity}}</span><b>#{{.ID}}</b><span>{{.Method}}</span><span class="url">{{.URL}}</span><span>{{.StatusCode}}</span></summary>
+<div class="body">
+<table class="meta">
+<tr><td>Type</td><td>{{.Type}}{{if .Technique}} ({{.Technique}}){{end}}</td></tr>
+{{if .Payload}}<tr><td>Payload</td><td><code>{{.Payload}}</code></td></tr>{{end}}
+{{if .IDRange}}<tr><td>Affected IDs</td><td>{{.IDRange}}</td></tr>{{end}}
+{{if .CVSSVector}}<tr><td>CVSS</td><td>{{printf "%.1f" .CVSSScore}} <code>{{.CVSSVector}}</code></td></tr>{{end}}
+{{if .OWASP}}<tr><td>OWASP API</td><td>{{range $i, $c := .OWASP}}{{if $i}}<br>{{end}}{{$c}}{{end}}</td></tr>{{end}}
+<tr><td>Content length</td><td>{{.ContentLen}} bytes</td></tr>
+<tr><td>Found</td><td>{{.Timestamp.Format "2006-01-02 15:04:05"}}</td></tr>
+{{if .ResponseFile}}<tr><td>Full response</td><td><code>{{.ResponseFile}}</code></td></tr>{{end}}
+{{range $name, $values := .PIIFound}}<tr><td class="pii">PII: {{$name}}</td><td>{{len $values}} match(es)</td></tr>{{end}}
+</table>
+{{if .Request}}<h4>Request</h4><pre>{{dump .Request}}</pre>{{end}}
+{{if .Curl}}<h4>Reproduce</h4><pre>{{.Curl}}</pre>{{end}}
+{{if .Evidence}}<h4>Response evidence</h4><pre>{{highlight .Evidence .PIIFound}}</pre>{{end}}
+</div>
+</details>
+{{end}}
+`
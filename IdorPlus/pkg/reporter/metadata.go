@@ -0,0 +1,122 @@
+package reporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ScanInfo records how a scan was run so its report can be reproduced and
+// audited later. It never holds credentials: auth headers are masked and
+// sessions are only named.
+type ScanInfo struct {
+	Target  string   `json:"target"`
+	Method  string   `json:"method,omitempty"`
+	Headers []string `json:"headers,omitempty"`
+	Body    string   `json:"body,omitempty"`
+	// Endpoint is what the pre-fuzz probe found, e.g. "object, auth required"
+	Endpoint string `json:"endpoint,omitempty"`
+	// Methods are the methods the endpoint allows per OPTIONS, and
+	// UntestedMethods the PUT, PATCH and DELETE among them the scan didn't
+	// send
+	Methods         []string `json:"methods,omitempty"`
+	UntestedMethods []string `json:"untested_methods,omitempty"`
+
+	// Sessions are the names of the sessions used, e.g. attacker, victim
+	Sessions []string `json:"sessions,omitempty"`
+
+	Bypass        string   `json:"bypass,omitempty"`
+	Threads       int      `json:"threads,omitempty"`
+	Delay         string   `json:"delay,omitempty"`
+	Threshold     float64  `json:"threshold,omitempty"`
+	MinConfidence int      `json:"min_confidence,omitempty"`
+	Confirmations int      `json:"confirmations,omitempty"`
+	Checks        []string `json:"checks,omitempty"`
+	Script        bool     `json:"script,omitempty"`
+	MaxRequests   int      `json:"max_requests,omitempty"`
+	MaxDuration   string   `json:"max_duration,omitempty"`
+
+	Payloads PayloadInfo `json:"payloads"`
+}
+
+// PayloadInfo describes the IDs a scan tried
+type PayloadInfo struct {
+	// Explicit is the number of IDs given, e.g. by a wordlist; the other
+	// settings only apply to generated IDs
+	Explicit      int            `json:"explicit,omitempty"`
+	Count         int            `json:"count,omitempty"`
+	IDRange       string         `json:"id_range,omitempty"`
+	Sample        int            `json:"sample,omitempty"`
+	SampleIDs     int            `json:"sample_ids,omitempty"`
+	Template      string         `json:"template,omitempty"`
+	Checksum      string         `json:"checksum,omitempty"`
+	EstimateRange bool           `json:"estimate_range,omitempty"`
+	Encodings     []string       `json:"encodings,omitempty"`
+	Wordlists     map[string]int `json:"wordlists,omitempty"` // placeholder name: entries
+	Combine       string         `json:"combine,omitempty"`
+	CanaryIDs     []string       `json:"canary_ids,omitempty"`
+}
+
+// MaskedHeader is the value of an auth header in ScanInfo.Headers
+const MaskedHeader = "[redacted]"
+
+// Parameters lists the scan parameters that are set as label and value,
+// for reports and their templates
+func (si *ScanInfo) Parameters() [][2]string {
+	if si == nil {
+		return nil
+	}
+	var params [][2]string
+	add := func(label, value string) {
+		if value != "" && value != "0" {
+			params = append(params, [2]string{label, value})
+		}
+	}
+	add("Method", si.Method)
+	add("Endpoint", si.Endpoint)
+	add("Allowed methods", strings.Join(si.Methods, ", "))
+	add("Untested methods", strings.Join(si.UntestedMethods, ", "))
+	add("Headers", strings.Join(si.Headers, "; "))
+	add("Body", si.Body)
+	add("Sessions", strings.Join(si.Sessions, ", "))
+	add("Bypass mode", si.Bypass)
+	add("Threads", fmt.Sprint(si.Threads))
+	add("Delay", si.Delay)
+	add("Similarity threshold", fmt.Sprint(si.Threshold))
+	add("Min confidence", fmt.Sprint(si.MinConfidence))
+	add("Retests", fmt.Sprint(si.Confirmations))
+	add("Checks", strings.Join(si.Checks, ", "))
+	if si.Script {
+		add("Hook script", "yes")
+	}
+	add("Max requests", fmt.Sprint(si.MaxRequests))
+	add("Max duration", si.MaxDuration)
+
+	p := si.Payloads
+	add("Explicit IDs", fmt.Sprint(p.Explicit))
+	add("Generated IDs", fmt.Sprint(p.Count))
+	add("ID range", p.IDRange)
+	add("Random sample", fmt.Sprint(p.Sample))
+	add("Known IDs learned from", fmt.Sprint(p.SampleIDs))
+	add("ID template", p.Template)
+	add("Checksum", p.Checksum)
+	if p.EstimateRange {
+		add("Range estimated", "yes")
+	}
+	add("Encodings", strings.Join(p.Encodings, ", "))
+	if len(p.Wordlists) > 0 {
+		names := make([]string, 0, len(p.Wordlists))
+		for name := range p.Wordlists {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lists := make([]string, len(names))
+		for i, name := range names {
+			lists[i] = fmt.Sprintf("{%s} %d entries", name, p.Wordlists[name])
+		}
+		add("Wordlists", strings.Join(lists, ", "))
+	}
+	add("Combine", p.Combine)
+	add("Canary IDs", strings.Join(p.CanaryIDs, ", "))
+	return params
+}
@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"idorplus/pkg/detector"
+)
+
+// redactMarker replaces a PII value of a type in redacted findings
+func redactMarker(kind string) string {
+	return "[REDACTED " + kind + "]"
+}
+
+// RedactFinding returns a copy of f with every PII value masked in its
+// evidence and PII matches, keeping only their type and count, so reports
+// can be shared without exposing the leaked data again. The fingerprint is
+// kept from before masking so findings still compare across scans.
+func RedactFinding(f *Finding) *Finding {
+	if f.Fingerprint == "" {
+		f.Fingerprint = Fingerprint(f)
+	}
+	redacted := *f
+
+	// Values the detector found in the whole response, and any in the
+	// possibly truncated evidence
+	markers := make(map[string]string)
+	for kind, values := range f.PIIFound {
+		for _, v := range values {
+			markers[v] = redactMarker(kind)
+		}
+	}
+	for kind, values := range detector.FindPII(f.Evidence) {
+		for _, v := range values {
+			if _, ok := markers[v]; !ok {
+				markers[v] = redactMarker(kind)
+			}
+		}
+	}
+	redacted.Evidence = redactText(f.Evidence, markers)
+
+	if f.PIIFound != nil {
+		redacted.PIIFound = make(map[string][]string, len(f.PIIFound))
+		for kind, values := range f.PIIFound {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = redactMarker(kind)
+			}
+			redacted.PIIFound[kind] = masked
+		}
+	}
+	return &redacted
+}
+
+// redactText replaces every value of markers in text with its marker
+func redactText(text string, markers map[string]string) string {
+	if text == "" || len(markers) == 0 {
+		return text
+	}
+	values := make([]string, 0, len(markers))
+	for v := range markers {
+		if v != "" {
+			values = append(values, regexp.QuoteMeta(v))
+		}
+	}
+	if len(values) == 0 {
+		return text
+	}
+
+	// Longest first so a value is never partly left by a shorter one
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	re := regexp.MustCompile(strings.Join(values, "|"))
+	return re.ReplaceAllStringFunc(text, func(v string) string { return markers[v] })
+}
@@ -3,10 +3,20 @@ package reporter
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/detector"
 	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
 
 	"github.com/pterm/pterm"
 )
@@ -16,20 +26,117 @@ type Reporter struct {
 	Findings  []*Finding
 	Format    string
 	StartTime time.Time
+
+	// EndTime is used for the report duration when regenerating a past scan;
+	// zero means the scan is still running
+	EndTime time.Time
+
+	// ResponsesDir, when set, receives the full request/response pair of
+	// every fuzzer finding as <id>.txt
+	ResponsesDir string
+
+	// Dedup groups findings sharing a fingerprint into one finding
+	// in reports and the summary, see DedupFindings
+	Dedup bool
+
+	// PII, when set, extracts PII matches from the response of fuzzer findings
+	PII func(body []byte) map[string][]string
+
+	// OnFinding is called with every finding once it is scored and recorded
+	OnFinding func(*Finding)
+
+	// SlowEndpoints were limited or skipped for their latency, reported
+	// apart from the findings as not fully scanned
+	SlowEndpoints []fuzzer.SlowEndpoint
+
+	// Budget, when set, is what a scan cut short by its budget tested
+	Budget *fuzzer.BudgetReport
+
+	// Statistics, when set, break the scan's responses down by status
+	// code and endpoint
+	Statistics *fuzzer.Breakdown
+
+	// Coverage, when set, accounts for the jobs of each endpoint
+	Coverage []fuzzer.EndpointCoverage
+
+	// Redact masks PII in the findings of reports and in saved responses,
+	// see RedactFinding
+	Redact bool
+
+	// Scan describes how the scan was run, recorded in the report
+	Scan *ScanInfo
+
+	// Targets are the URLs scanned, so a report covering several hosts
+	// lists those without findings too
+	Targets []string
+
+	// Template, when set, is the Go template laying out markdown and HTML
+	// reports instead of the built-in layout, see LoadTemplate
+	Template string
+
+	// Interrupted marks the report of a scan stopped before it finished
+	Interrupted bool
+
+	// mu guards Findings against reports generated while a scan, cut
+	// short, still records them
+	mu sync.Mutex
 }
 
+// Finding types
+const (
+	FindingIDOR           = "idor"
+	FindingVerbTamper     = "verb_tamper"
+	FindingPathBypass     = "path_bypass"
+	FindingContentShift   = "content_shift"
+	FindingMassAssign     = "mass_assignment"
+	FindingParamPollution = "param_pollution"
+	FindingAPIVersion     = "api_version"
+	FindingPagination     = "pagination"
+	FindingDownload       = "download"
+	FindingCORS           = "cors"
+)
+
 // Finding represents a discovered vulnerability
 type Finding struct {
+	ID          string              `json:"id"`
+	Type        string              `json:"type"`
+	Technique   string              `json:"technique,omitempty"`
+	Fingerprint string              `json:"fingerprint,omitempty"`
 	URL         string              `json:"url"`
+	Endpoint    string              `json:"endpoint,omitempty"`
 	Method      string              `json:"method"`
 	Payload     string              `json:"payload"`
 	StatusCode  int                 `json:"status_code"`
 	ContentLen  int                 `json:"content_length"`
 	Evidence    string              `json:"evidence,omitempty"`
 	PIIFound    map[string][]string `json:"pii_found,omitempty"`
+	File        *analyzer.FileInfo  `json:"file,omitempty"`
+	Redirects   []analyzer.Redirect `json:"redirects,omitempty"`
 	Severity    string              `json:"severity"`
+	Confidence  int                 `json:"confidence,omitempty"`
+	Reasons     []string            `json:"reasons,omitempty"`
+	CVSSScore   float64             `json:"cvss_score"`
+	CVSSVector  string              `json:"cvss_vector,omitempty"`
+	OWASP       []string            `json:"owasp,omitempty"`
 	Timestamp   time.Time           `json:"timestamp"`
 	RequestTime time.Duration       `json:"request_time"`
+	Request     *RecordedRequest    `json:"request,omitempty"`
+	Curl        string              `json:"curl,omitempty"`
+
+	// ResponseFile is the saved request/response pair, see Reporter.ResponsesDir
+	ResponseFile string `json:"response_file,omitempty"`
+
+	// AffectedIDs and IDRange are set when several findings were grouped
+	AffectedIDs []string `json:"affected_ids,omitempty"`
+	IDRange     string   `json:"id_range,omitempty"`
+}
+
+// RecordedRequest is the exact request behind a finding, used for replay
+type RecordedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
 }
 
 // Report is the complete scan report
@@ -39,7 +146,21 @@ type Report struct {
 	TargetURL  string     `json:"target_url,omitempty"`
 	TotalScans int        `json:"total_scans"`
 	VulnCount  int        `json:"vulnerabilities_found"`
+	RawCount   int        `json:"raw_findings,omitempty"`
 	Findings   []*Finding `json:"findings"`
+
+	// Scan is how the scan was run, without credentials
+	Scan *ScanInfo `json:"scan,omitempty"`
+
+	SlowEndpoints []fuzzer.SlowEndpoint     `json:"slow_endpoints,omitempty"`
+	Budget        *fuzzer.BudgetReport      `json:"budget,omitempty"`
+	Statistics    *fuzzer.Breakdown         `json:"statistics,omitempty"`
+	Coverage      []fuzzer.EndpointCoverage `json:"coverage,omitempty"`
+	Interrupted   bool                      `json:"interrupted,omitempty"`
+
+	// Summary and Hosts are set when the report covers several hosts
+	Summary *Summary       `json:"summary,omitempty"`
+	Hosts   []*HostSection `json:"hosts,omitempty"`
 }
 
 // NewReporter creates a new reporter
@@ -48,20 +169,27 @@ func NewReporter(format string) *Reporter {
 		Format:    format,
 		StartTime: time.Now(),
 		Findings:  make([]*Finding, 0),
+		Dedup:     true,
 	}
 }
 
-// AddFinding adds a finding from a fuzz result
-func (r *Reporter) AddFinding(result *fuzzer.FuzzResult) {
+// AddFinding adds a finding from a fuzz result and returns it
+func (r *Reporter) AddFinding(result *fuzzer.FuzzResult) *Finding {
 	finding := &Finding{
+		Type:        FindingIDOR,
 		URL:         result.Job.URL,
+		Endpoint:    result.Job.Endpoint,
 		Method:      result.Job.Method,
 		Payload:     result.Job.Payload,
 		StatusCode:  result.StatusCode,
 		ContentLen:  result.ContentLen,
-		Severity:    determineSeverity(result),
+		Confidence:  result.Confidence,
+		Reasons:     result.Reasons,
+		File:        result.File,
+		Redirects:   result.Redirects,
 		Timestamp:   time.Now(),
 		RequestTime: result.Duration,
+		Request:     recordRequest(result),
 	}
 
 	// Truncate evidence to prevent huge reports
@@ -71,17 +199,240 @@ func (r *Reporter) AddFinding(result *fuzzer.FuzzResult) {
 		finding.Evidence = result.Evidence
 	}
 
+	if r.PII != nil && result.Response != nil {
+		finding.PIIFound = r.PII(result.Body())
+	}
+	finding.Score()
+	finding.ID = r.nextID()
+	finding.Curl = finding.Request.Curl()
+	if r.ResponsesDir != "" && result.Response != nil {
+		path, err := r.saveExchange(finding, result)
+		if err != nil {
+			utils.Warning.Printf("Failed to save response for finding %s: %v\n", finding.ID, err)
+		} else {
+			finding.ResponseFile = path
+		}
+	}
+	r.mu.Lock()
 	r.Findings = append(r.Findings, finding)
+	r.mu.Unlock()
+	if r.OnFinding != nil {
+		r.OnFinding(finding)
+	}
+	return finding
+}
+
+// saveExchange writes the raw request and the full response of a finding to ResponsesDir
+func (r *Reporter) saveExchange(finding *Finding, result *fuzzer.FuzzResult) (string, error) {
+	if err := os.MkdirAll(r.ResponsesDir, 0755); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(finding.Request.Dump())
+	b.WriteString("\n\n")
+
+	resp := result.Response
+	fmt.Fprintf(&b, "%s %s\n", resp.Proto(), resp.Status())
+	names := make([]string, 0, len(resp.Header()))
+	for name := range resp.Header() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range resp.Header()[name] {
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+	b.WriteString("\n")
+
+	path := filepath.Join(r.ResponsesDir, finding.ID+".txt")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(b.String()); err != nil {
+		return "", err
+	}
+	if r.Redact {
+		body := string(result.Body())
+		markers := make(map[string]string)
+		for kind, values := range detector.FindPII(body) {
+			for _, v := range values {
+				markers[v] = redactMarker(kind)
+			}
+		}
+		if _, err := f.WriteString(redactText(body, markers)); err != nil {
+			return "", err
+		}
+		return path, f.Close()
+	}
+	// A spooled body is copied over instead of read into memory
+	if err := result.WriteBody(f); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// Dump formats the request as an HTTP/1.1 message
+func (rr *RecordedRequest) Dump() string {
+	target, host := rr.URL, ""
+	if u, err := url.Parse(rr.URL); err == nil {
+		target, host = u.RequestURI(), u.Host
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\n", rr.Method, target)
+	if host != "" {
+		fmt.Fprintf(&b, "Host: %s\n", host)
+	}
+	names := make([]string, 0, len(rr.Headers))
+	for name := range rr.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, rr.Headers[name])
+	}
+	if rr.Body != "" {
+		b.WriteString("\n" + rr.Body)
+	}
+	return b.String()
+}
+
+// AddCustomFinding adds a finding produced outside the fuzzer,
+// e.g. by the tamper and bypass modules
+func (r *Reporter) AddCustomFinding(f *Finding) {
+	if f.Type == "" {
+		f.Type = FindingIDOR
+	}
+	// An explicit severity overrides the CVSS derived one
+	severity := f.Severity
+	f.Score()
+	if severity != "" {
+		f.Severity = severity
+	}
+	if f.Timestamp.IsZero() {
+		f.Timestamp = time.Now()
+	}
+	if f.Request != nil && f.Curl == "" {
+		f.Curl = f.Request.Curl()
+	}
+	f.ID = r.nextID()
+	r.mu.Lock()
+	r.Findings = append(r.Findings, f)
+	r.mu.Unlock()
+	if r.OnFinding != nil {
+		r.OnFinding(f)
+	}
+}
+
+// nextID returns the ID for the next finding, its 1-based position in the report
+func (r *Reporter) nextID() string {
+	return strconv.Itoa(len(r.Findings) + 1)
+}
+
+// recordRequest captures the headers actually sent, including cookies and
+// rotated User-Agent, so the request can be replayed exactly
+func recordRequest(result *fuzzer.FuzzResult) *RecordedRequest {
+	rec := &RecordedRequest{
+		Method: result.Job.Method,
+		URL:    result.Job.URL,
+		Body:   result.Job.Interpolate(result.Job.Body),
+	}
+
+	if result.Response != nil && result.Response.Request != nil && result.Response.Request.RawRequest != nil {
+		raw := result.Response.Request.RawRequest
+		rec.Method = raw.Method
+		rec.Headers = make(map[string]string, len(raw.Header))
+		for name, values := range raw.Header {
+			rec.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+
+	return rec
+}
+
+// LoadReport reads a JSON report written by GenerateReport
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// FindFinding returns the finding with the given ID, or nil
+func (rp *Report) FindFinding(id string) *Finding {
+	for _, f := range rp.Findings {
+		if f.ID == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// reportFindings fingerprints the findings, groups them if Dedup is set
+// and masks their PII if Redact is set
+func (r *Reporter) reportFindings(all []*Finding) []*Finding {
+	findings := fingerprinted(all)
+	if r.Dedup {
+		findings = DedupFindings(findings)
+	}
+	if r.Redact {
+		redacted := make([]*Finding, len(findings))
+		for i, f := range findings {
+			redacted[i] = RedactFinding(f)
+		}
+		findings = redacted
+	}
+	return findings
+}
+
+// Snapshot returns the findings recorded so far. Unlike Findings it is
+// safe to call while a scan is still adding to them.
+func (r *Reporter) Snapshot() []*Finding {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.Findings)
 }
 
 // GenerateReport generates the report to file
 func (r *Reporter) GenerateReport(filename string) error {
+	all := r.Snapshot()
+	findings := r.reportFindings(all)
+	end := r.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
 	report := &Report{
 		ScanTime:   r.StartTime,
-		Duration:   time.Since(r.StartTime).Round(time.Second).String(),
-		TotalScans: len(r.Findings),
-		VulnCount:  len(r.Findings),
-		Findings:   r.Findings,
+		Duration:   end.Sub(r.StartTime).Round(time.Second).String(),
+		TotalScans: len(all),
+		VulnCount:  len(findings),
+		Findings:   findings,
+		Scan:       r.Scan,
+
+		SlowEndpoints: r.SlowEndpoints,
+		Budget:        r.Budget,
+		Statistics:    r.Statistics,
+		Coverage:      r.Coverage,
+		Interrupted:   r.Interrupted,
+	}
+	if len(findings) != len(all) {
+		report.RawCount = len(all)
+	}
+	if r.Scan != nil {
+		report.TargetURL = r.Scan.Target
+	}
+	if report.Hosts = hostSections(findings, r.Targets); report.Hosts != nil {
+		report.Summary = summarize(report.Hosts)
 	}
 
 	switch r.Format {
@@ -89,11 +440,22 @@ func (r *Reporter) GenerateReport(filename string) error {
 		return r.generateJSON(filename, report)
 	case "markdown":
 		return r.generateMarkdown(filename, report)
+	case "html":
+		return r.generateHTML(filename, report)
+	case "burp":
+		return r.generateBurpXML(filename, report)
 	default:
 		return r.generateJSON(filename, report)
 	}
 }
 
+// ExportBurp writes all findings as Burp Suite issues XML,
+// independent of the main report format
+func (r *Reporter) ExportBurp(filename string) error {
+	report := &Report{ScanTime: r.StartTime, Findings: r.reportFindings(r.Snapshot())}
+	return r.generateBurpXML(filename, report)
+}
+
 // generateJSON outputs JSON format
 func (r *Reporter) generateJSON(filename string, report *Report) error {
 	data, err := json.MarshalIndent(report, "", "  ")
@@ -105,43 +467,179 @@ func (r *Reporter) generateJSON(filename string, report *Report) error {
 
 // generateMarkdown outputs Markdown format
 func (r *Reporter) generateMarkdown(filename string, report *Report) error {
+	if r.Template != "" {
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return r.executeMarkdownTemplate(f, report)
+	}
+
 	content := "# IDOR Scan Report\n\n"
+	if report.TargetURL != "" {
+		content += fmt.Sprintf("**Target:** %s\n", report.TargetURL)
+	}
 	content += fmt.Sprintf("**Scan Time:** %s\n", report.ScanTime.Format(time.RFC3339))
 	content += fmt.Sprintf("**Duration:** %s\n", report.Duration)
-	content += fmt.Sprintf("**Vulnerabilities Found:** %d\n\n", report.VulnCount)
+	if report.Interrupted {
+		content += "**Status:** interrupted, partial results\n"
+	}
+	content += fmt.Sprintf("**Vulnerabilities Found:** %d\n", report.VulnCount)
+	if report.RawCount > 0 {
+		content += fmt.Sprintf("**Raw Findings:** %d (grouped by fingerprint)\n", report.RawCount)
+	}
+	content += "\n"
 
-	content += "## Findings\n\n"
+	if params := report.Scan.Parameters(); len(params) > 0 {
+		content += "## Scan Parameters\n\n| Parameter | Value |\n|---|---|\n"
+		for _, p := range params {
+			content += fmt.Sprintf("| %s | `%s` |\n", p[0], strings.ReplaceAll(p[1], "|", "\\|"))
+		}
+		content += "\n"
+	}
 
-	for i, f := range report.Findings {
-		content += fmt.Sprintf("### %d. %s\n\n", i+1, f.URL)
-		content += fmt.Sprintf("- **Method:** %s\n", f.Method)
-		content += fmt.Sprintf("- **Payload:** `%s`\n", f.Payload)
-		content += fmt.Sprintf("- **Status Code:** %d\n", f.StatusCode)
-		content += fmt.Sprintf("- **Severity:** %s\n", f.Severity)
-		content += fmt.Sprintf("- **Content Length:** %d bytes\n\n", f.ContentLen)
+	if report.Summary != nil {
+		content += "## Executive Summary\n\n" + report.Summary.Text + "\n\n"
+		content += "| Host | Findings | Critical | High | Medium | Low | Endpoints | Max CVSS |\n|---|---|---|---|---|---|---|---|\n"
+		for _, h := range report.Hosts {
+			content += fmt.Sprintf("| %s | %d | %d | %d | %d | %d | %d | %.1f |\n", h.Host, len(h.Findings),
+				h.Severities["CRITICAL"], h.Severities["HIGH"], h.Severities["MEDIUM"], h.Severities["LOW"], h.Endpoints, h.MaxCVSS)
+		}
+		content += "\n"
 
-		if f.Evidence != "" {
-			content += "**Evidence:**\n```\n" + f.Evidence + "\n```\n\n"
+		for _, h := range report.Hosts {
+			content += "## " + h.Host + "\n\n"
+			if len(h.Findings) == 0 {
+				content += "No findings.\n\n"
+			}
+			for _, f := range h.Findings {
+				content += markdownFinding(f)
+			}
+		}
+	} else {
+		content += "## Findings\n\n"
+		for _, f := range report.Findings {
+			content += markdownFinding(f)
 		}
 	}
 
+	if len(report.SlowEndpoints) > 0 {
+		content += "## Slow Endpoints\n\n"
+		for _, e := range report.SlowEndpoints {
+			content += fmt.Sprintf("- %s: p95 %s over %d requests", e.Endpoint, e.P95.Round(time.Millisecond), e.Samples)
+			if e.Skipped > 0 {
+				content += fmt.Sprintf(", %d jobs skipped", e.Skipped)
+			}
+			content += "\n"
+		}
+		content += "\n"
+	}
+
+	if b := report.Budget; b != nil {
+		content += "## Budget Exhausted\n\n"
+		content += fmt.Sprintf("Stopped after %d requests in %s: %s. %d jobs were not tested.\n\n",
+			b.Requests, b.Elapsed.Round(time.Second), b.Reason, b.Untested())
+		content += "| Endpoint | Tested | Untested |\n|---|---|---|\n"
+		for _, e := range b.Endpoints {
+			content += fmt.Sprintf("| %s | %d | %d |\n", e.Endpoint, e.Tested, e.Untested)
+		}
+		if len(b.Skipped) > 0 {
+			content += "\nNot run: " + strings.Join(b.Skipped, ", ") + "\n"
+		}
+		content += "\n"
+	}
+
+	if len(report.Coverage) > 0 {
+		content += "## Coverage\n\n"
+		if planned, gaps := fuzzer.CoverageGaps(report.Coverage); gaps > 0 {
+			content += fmt.Sprintf("**%d of %d planned jobs did not test their ID.**\n\n", gaps, planned)
+		} else {
+			content += fmt.Sprintf("All %d planned jobs were tested or skipped on purpose.\n\n", planned)
+		}
+		content += "| Endpoint | Planned | Tested | Failed | Blocked | Skipped | Untested | Missing | Vulnerable |\n|---|---|---|---|---|---|---|---|---|\n"
+		for _, c := range report.Coverage {
+			content += fmt.Sprintf("| %s | %d | %d | %d | %d | %d | %d | %d | %d |\n",
+				c.Endpoint, c.Planned, c.Tested, c.Failed, c.Blocked, c.Skipped, c.Untested, c.Missing(), c.Vulnerable)
+		}
+		content += "\n"
+	}
+
+	if st := report.Statistics; st != nil && len(st.StatusCodes) > 0 {
+		content += "## Statistics\n\n"
+		content += fmt.Sprintf("%s received. Latency: %s.\n\n", fuzzer.FormatBytes(st.Bytes), st.Latency)
+		content += "| Status | Responses |\n|---|---|\n"
+		for _, bar := range statusChart(st) {
+			content += fmt.Sprintf("| %s | %d (%d%%) |\n", bar.Label, bar.Count, bar.Percent)
+		}
+		content += "\n| Endpoint | Requests | Errors | Vulnerable | Status codes | p50 | p95 | Received |\n|---|---|---|---|---|---|---|---|\n"
+		for _, e := range st.Endpoints {
+			content += fmt.Sprintf("| %s | %d | %d | %d | %s | %s | %s | %s |\n", e.Endpoint, e.Requests, e.Errors, e.Vulnerable,
+				fuzzer.StatusLine(e.StatusCodes), e.Latency.P50.Round(time.Millisecond), e.Latency.P95.Round(time.Millisecond), fuzzer.FormatBytes(e.Bytes))
+		}
+		content += "\n"
+	}
+
 	return os.WriteFile(filename, []byte(content), 0644)
 }
 
+// markdownFinding renders one finding of a Markdown report
+func markdownFinding(f *Finding) string {
+	content := fmt.Sprintf("### %s. %s\n\n", f.ID, f.URL)
+	content += fmt.Sprintf("- **Type:** %s\n", f.Type)
+	if f.Technique != "" {
+		content += fmt.Sprintf("- **Technique:** %s\n", f.Technique)
+	}
+	content += fmt.Sprintf("- **Method:** %s\n", f.Method)
+	content += fmt.Sprintf("- **Payload:** `%s`\n", f.Payload)
+	if f.IDRange != "" {
+		content += fmt.Sprintf("- **Affected IDs:** %s\n", f.IDRange)
+	}
+	content += fmt.Sprintf("- **Status Code:** %d\n", f.StatusCode)
+	content += fmt.Sprintf("- **Severity:** %s (CVSS %.1f)\n", f.Severity, f.CVSSScore)
+	if f.Confidence > 0 {
+		content += fmt.Sprintf("- **Confidence:** %d/100 (%s)\n", f.Confidence, strings.Join(f.Reasons, "; "))
+	}
+	if f.CVSSVector != "" {
+		content += fmt.Sprintf("- **CVSS Vector:** `%s`\n", f.CVSSVector)
+	}
+	if len(f.OWASP) > 0 {
+		content += fmt.Sprintf("- **OWASP API Top 10:** %s\n", strings.Join(f.OWASP, "; "))
+	}
+	for _, hop := range f.Redirects {
+		content += fmt.Sprintf("- **Redirect:** %d %s → %s\n", hop.Status, hop.From, hop.To)
+	}
+	content += fmt.Sprintf("- **Content Length:** %d bytes\n\n", f.ContentLen)
+
+	if f.Curl != "" {
+		content += "**Reproduce:**\n```sh\n" + f.Curl + "\n```\n\n"
+	}
+
+	if f.Evidence != "" {
+		content += "**Evidence:**\n```\n" + f.Evidence + "\n```\n\n"
+	}
+	return content
+}
+
 // PrintSummary prints a summary of findings to console
 func (r *Reporter) PrintSummary() {
 	pterm.DefaultSection.Println("Scan Summary")
 
 	if len(r.Findings) == 0 {
-		pterm.Success.Println("No vulnerabilities found")
+		utils.Success.Println("No vulnerabilities found")
 		return
 	}
 
+	findings := r.reportFindings(r.Snapshot())
+	if len(findings) != len(r.Findings) {
+		utils.Info.Printf("%d findings grouped into %d\n", len(r.Findings), len(findings))
+	}
+
 	tableData := pterm.TableData{
-		{"URL", "Method", "Status", "Severity"},
+		{"URL", "Method", "Status", "Severity", "IDs"},
 	}
 
-	for _, f := range r.Findings {
+	for _, f := range findings {
 		severity := f.Severity
 		switch severity {
 		case "CRITICAL":
@@ -159,27 +657,13 @@ func (r *Reporter) PrintSummary() {
 			f.Method,
 			fmt.Sprintf("%d", f.StatusCode),
 			severity,
+			truncate(f.IDRange, 30),
 		})
 	}
 
 	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
 }
 
-// determineSeverity determines severity based on finding characteristics
-func determineSeverity(result *fuzzer.FuzzResult) string {
-	// High severity if status code changed from expected error to success
-	if result.StatusCode == 200 {
-		return "HIGH"
-	}
-
-	// Medium if there's content but not 200
-	if result.ContentLen > 100 {
-		return "MEDIUM"
-	}
-
-	return "LOW"
-}
-
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -0,0 +1,150 @@
+package reporter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// OWASP API Security Top 10 (2023) categories
+const (
+	OWASPBOLA      = "API1:2023 Broken Object Level Authorization"
+	OWASPBOPLA     = "API3:2023 Broken Object Property Level Authorization"
+	OWASPBFLA      = "API5:2023 Broken Function Level Authorization"
+	OWASPMisconfig = "API8:2023 Security Misconfiguration"
+)
+
+// CVSS v3.1 metric weights (scope unchanged)
+var (
+	cvssAV  = map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2}
+	cvssAC  = map[string]float64{"L": 0.77, "H": 0.44}
+	cvssPR  = map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	cvssUI  = map[string]float64{"N": 0.85, "R": 0.62}
+	cvssCIA = map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+)
+
+// CVSSMetrics holds the base metrics of a CVSS v3.1 vector with scope unchanged
+type CVSSMetrics struct {
+	AV, AC, PR, UI string
+	C, I, A        string
+}
+
+// Vector returns the CVSS v3.1 vector string
+func (m CVSSMetrics) Vector() string {
+	return fmt.Sprintf("CVSS:3.1/AV:%s/AC:%s/PR:%s/UI:%s/S:U/C:%s/I:%s/A:%s",
+		m.AV, m.AC, m.PR, m.UI, m.C, m.I, m.A)
+}
+
+// BaseScore computes the CVSS v3.1 base score
+func (m CVSSMetrics) BaseScore() float64 {
+	iss := 1 - (1-cvssCIA[m.C])*(1-cvssCIA[m.I])*(1-cvssCIA[m.A])
+	impact := 6.42 * iss
+	if impact <= 0 {
+		return 0
+	}
+	exploitability := 8.22 * cvssAV[m.AV] * cvssAC[m.AC] * cvssPR[m.PR] * cvssUI[m.UI]
+	return roundUp(math.Min(impact+exploitability, 10))
+}
+
+// roundUp is the CVSS v3.1 Roundup function, avoiding float artefacts
+func roundUp(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
+
+// SeverityForScore maps a CVSS score to the qualitative severity scale
+func SeverityForScore(score float64) string {
+	switch {
+	case score >= 9.0:
+		return "CRITICAL"
+	case score >= 7.0:
+		return "HIGH"
+	case score >= 4.0:
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
+
+// SeverityAtLeast reports whether severity is at or above min
+func SeverityAtLeast(severity, min string) bool {
+	return severityRank(severity) >= severityRank(min)
+}
+
+// FindingMetrics derives CVSS metrics from what a finding shows:
+// reads leak data (more with PII), writes modify data, DELETE also
+// hurts availability, and a session on the request means the attacker
+// needs an account.
+func FindingMetrics(f *Finding) CVSSMetrics {
+	m := CVSSMetrics{AV: "N", AC: "L", PR: "N", UI: "N", C: "N", I: "N", A: "N"}
+
+	if hasSession(f.Request) {
+		m.PR = "L"
+	}
+	// Permissive CORS is exploited with the victim's session, from a page
+	// the victim visits
+	if f.Type == FindingCORS {
+		m.PR, m.UI = "N", "R"
+	}
+
+	switch strings.ToUpper(f.Method) {
+	case "GET", "HEAD", "OPTIONS", "":
+		m.C = "L"
+		if len(f.PIIFound) > 0 {
+			m.C = "H"
+		}
+	case "DELETE":
+		m.I = "H"
+		m.A = "L"
+	default:
+		m.I = "H"
+		if f.ContentLen > 0 {
+			m.C = "L"
+		}
+		if len(f.PIIFound) > 0 {
+			m.C = "H"
+		}
+	}
+
+	return m
+}
+
+// OWASPCategories maps a finding to OWASP API Top 10 categories
+func OWASPCategories(f *Finding) []string {
+	if f.Type == FindingCORS {
+		return []string{OWASPMisconfig}
+	}
+	categories := []string{OWASPBOLA}
+	if f.Type == FindingVerbTamper {
+		categories = append(categories, OWASPBFLA)
+	}
+	if len(f.PIIFound) > 0 || f.Type == FindingMassAssign {
+		categories = append(categories, OWASPBOPLA)
+	}
+	return categories
+}
+
+// Score fills in the CVSS vector, score, OWASP categories and severity.
+// Call again after PII matches are attached.
+func (f *Finding) Score() {
+	m := FindingMetrics(f)
+	f.CVSSVector = m.Vector()
+	f.CVSSScore = m.BaseScore()
+	f.OWASP = OWASPCategories(f)
+	f.Severity = SeverityForScore(f.CVSSScore)
+}
+
+func hasSession(rr *RecordedRequest) bool {
+	if rr == nil {
+		return false
+	}
+	for name := range rr.Headers {
+		if strings.EqualFold(name, "Cookie") || strings.EqualFold(name, "Authorization") {
+			return true
+		}
+	}
+	return false
+}
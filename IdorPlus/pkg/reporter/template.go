@@ -0,0 +1,87 @@
+package reporter
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+)
+
+// remediations is the default remediation advice of each finding type
+var remediations = map[string]string{
+	FindingIDOR:           "Check on every request that the authenticated user owns or may access the referenced object, server side, instead of trusting the ID sent by the client.",
+	FindingVerbTamper:     "Apply the same authorization checks to every HTTP method of the route and reject the methods it does not serve.",
+	FindingPathBypass:     "Normalize the path before routing and authorization so encoded, doubled or trailing variants reach the same checks.",
+	FindingContentShift:   "Authorize the object itself rather than the request format, so every content type and parser goes through the same checks.",
+	FindingMassAssign:     "Bind request bodies to an allow-list of fields the user may set and ignore or reject the others.",
+	FindingParamPollution: "Reject requests repeating a parameter, or make the authorization and the handler read the same occurrence.",
+	FindingAPIVersion:     "Retire old API versions or apply the current authorization checks to them too.",
+	FindingPagination:     "Scope listings to the authenticated user on the server, ignore client-sent owner filters and cap page sizes.",
+	FindingDownload:       "Check that the requested export, report or file belongs to the user before generating or serving it.",
+	FindingCORS:           "Allow credentialed cross-origin requests only from an exact list of trusted origins, never by reflecting the Origin header or allowing null.",
+}
+
+// Remediation returns the default remediation advice of a finding type,
+// available to report templates as remediation
+func Remediation(findingType string) string {
+	if text, ok := remediations[findingType]; ok {
+		return text
+	}
+	return remediations[FindingIDOR]
+}
+
+// reportFuncs are the functions available to report templates
+func reportFuncs() map[string]any {
+	return map[string]any{
+		"lower":       strings.ToLower,
+		"upper":       strings.ToUpper,
+		"join":        strings.Join,
+		"dump":        func(rr *RecordedRequest) string { return rr.Dump() },
+		"remediation": Remediation,
+		"bytes":       fuzzer.FormatBytes,
+		"statusLine":  fuzzer.StatusLine,
+		"ms":          func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+	}
+}
+
+// htmlFuncs are the functions available to HTML report templates
+func htmlFuncs() htmltemplate.FuncMap {
+	funcs := htmltemplate.FuncMap(reportFuncs())
+	funcs["highlight"] = highlightPII
+	return funcs
+}
+
+// LoadTemplate replaces the layout of markdown and HTML reports with the Go
+// template at path, executed with the report, its findings and charts. It
+// is parsed at once so a mistake shows before the scan rather than after.
+func (r *Reporter) LoadTemplate(path string) error {
+	data, err := os.ReadFile(utils.ExpandHome(path))
+	if err != nil {
+		return err
+	}
+	text := string(data)
+	if r.Format == "html" {
+		_, err = htmltemplate.New(path).Funcs(htmlFuncs()).Parse(text)
+	} else {
+		_, err = template.New(path).Funcs(reportFuncs()).Parse(text)
+	}
+	if err != nil {
+		return err
+	}
+	r.Template = text
+	return nil
+}
+
+// executeMarkdownTemplate writes the report with the custom text template
+func (r *Reporter) executeMarkdownTemplate(w io.Writer, report *Report) error {
+	tmpl, err := template.New("report").Funcs(reportFuncs()).Parse(r.Template)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, newReportData(report))
+}
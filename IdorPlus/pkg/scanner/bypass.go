@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// runVerbTamper retries denied URLs with method override tricks and records bypasses
+func runVerbTamper(ctx context.Context, c *client.SmartClient, rep *reporter.Reporter, jobs []*fuzzer.FuzzJob, session string) {
+	utils.PrintSection("Verb Tampering")
+
+	vt := detector.NewVerbTamperTester(c)
+	for _, job := range jobs {
+		result := vt.TestEndpoint(ctx, job.URL, job.Method, session)
+		vt.PrintResult(result)
+		recordBypasses(rep, reporter.FindingVerbTamper, result)
+	}
+}
+
+// runPathBypass retries denied URLs with path normalisation mutations and records bypasses
+func runPathBypass(ctx context.Context, c *client.SmartClient, rep *reporter.Reporter, jobs []*fuzzer.FuzzJob, session string) {
+	utils.PrintSection("Path Bypass")
+
+	pb := detector.NewPathBypassTester(c)
+	for _, job := range jobs {
+		result := pb.TestEndpoint(ctx, job.URL, job.Method, session)
+		pb.PrintResult(result)
+		recordBypasses(rep, reporter.FindingPathBypass, result)
+	}
+}
+
+// runAPIVersions retries denied and vulnerable URLs on other versions of
+// the API and records the versions that serve them
+func runAPIVersions(ctx context.Context, c *client.SmartClient, rep *reporter.Reporter, jobs []*fuzzer.FuzzJob, session string) {
+	utils.PrintSection("API Versions")
+
+	av := detector.NewAPIVersionTester(c)
+	for _, job := range jobs {
+		result := av.TestEndpoint(ctx, job.URL, job.Method, session)
+		av.PrintResult(result)
+		recordBypasses(rep, reporter.FindingAPIVersion, result)
+	}
+}
+
+// runContentShift re-sends denied bodies in other content types and records bypasses
+func runContentShift(ctx context.Context, c *client.SmartClient, rep *reporter.Reporter, jobs []*fuzzer.FuzzJob, format, session string) {
+	utils.PrintSection("Content-Type Shifting")
+
+	cs := detector.NewContentShiftTester(c)
+	for _, job := range jobs {
+		result := cs.TestRequest(ctx, job.URL, job.Method, job.Interpolate(job.Body), format, session)
+		cs.PrintResult(result)
+		recordBypasses(rep, reporter.FindingContentShift, result)
+	}
+}
+
+// recordBypasses adds a finding for every successful bypass attempt
+func recordBypasses(rep *reporter.Reporter, findingType string, result *detector.TamperResult) {
+	evidence := fmt.Sprintf("Original %s %s denied with status %d", result.Method, result.URL, result.BaselineStatus)
+	if !detector.IsDenied(result.BaselineStatus) {
+		evidence = fmt.Sprintf("Also served where the vulnerable %s %s returns status %d", result.Method, result.URL, result.BaselineStatus)
+	}
+	for _, a := range result.Attempts {
+		if !a.Bypassed {
+			continue
+		}
+		rep.AddCustomFinding(&reporter.Finding{
+			Type:       findingType,
+			Technique:  a.Technique,
+			URL:        a.URL,
+			Method:     a.Method,
+			StatusCode: a.StatusCode,
+			ContentLen: a.ContentLen,
+			Evidence:   evidence,
+			Request: &reporter.RecordedRequest{
+				Method:  a.Method,
+				URL:     a.URL,
+				Headers: a.Headers,
+				Body:    a.Body,
+			},
+		})
+	}
+}
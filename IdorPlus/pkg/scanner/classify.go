@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// probe is the request pair classifying the endpoint before the baselines:
+// as the attacker and, when the attacker has credentials, without them
+type probe struct {
+	id, method, url string
+	own, anon       *resty.Request
+	// owned is set when id is the attacker's own, the ID in the URL
+	owned bool
+}
+
+// newProbe builds the probe of the endpoint, sent like the baselines
+func (s *Scanner) newProbe(ctx context.Context, r *request) *probe {
+	opts := s.Options
+	method, body := baselineMethod(opts.Method, opts.Body), baselineBody(opts.Method, opts.Body)
+	p := &probe{id: r.existingID, method: method, owned: r.existingID != ""}
+	if p.id == "" && len(r.payloads) > 0 {
+		p.id = r.payloads[0]
+	}
+	p.url = s.buildURL(r, p.id)
+	p.own = baselineRequest(ctx, s.Client, r, body, p.id)
+	if s.Client.GetSessionManager().GetSession("attacker") != nil {
+		job := &fuzzer.FuzzJob{Payload: p.id, Vars: r.baselineVars(p.id), Headers: r.headers, Body: body}
+		// Without the cookies and tokens of -H or the config either
+		p.anon = s.Client.Request(client.WithoutCredentials(ctx))
+		fuzzer.PrepareRequest(s.Client, p.anon, job)
+	}
+	return p
+}
+
+// classify probes the endpoint and adapts the scan to what it is. It
+// reports whether fuzzing is skipped, for static assets; an attacker
+// session sent to log in for its own object fails the scan.
+func (s *Scanner) classify(ctx context.Context, r *request) (bool, error) {
+	p := s.newProbe(ctx, r)
+	resp, err := p.own.Execute(p.method, p.url)
+	if err != nil {
+		return false, fmt.Errorf("failed to probe the endpoint: %w", err)
+	}
+	var anon *resty.Response
+	if p.anon != nil {
+		if anon, err = p.anon.Execute(p.method, p.url); err != nil {
+			return false, fmt.Errorf("failed to probe the endpoint without credentials: %w", err)
+		}
+	}
+
+	ec := analyzer.ClassifyEndpoint(resp, anon)
+	s.Class = ec
+	s.Reporter.Scan.Endpoint = ec.String()
+	utils.Info.Printf("Endpoint: %s\n", ec)
+
+	switch {
+	case ec.Kind == analyzer.EndpointStatic:
+		utils.Warning.Printf("%s is a static asset, not fuzzing it (--no-classify fuzzes it anyway)\n", s.Options.URL)
+		return true, nil
+	case ec.Kind == analyzer.EndpointLogin && p.owned && p.anon != nil:
+		return false, fmt.Errorf("the attacker session is redirected to log in for its own object %s, refresh its credentials", p.id)
+	case ec.Kind == analyzer.EndpointLogin && p.anon == nil:
+		return false, fmt.Errorf("%s redirects to log in, pass the attacker's cookies or token", s.Options.URL)
+	case ec.Kind == analyzer.EndpointDenied && p.owned:
+		utils.Warning.Printf("The attacker session is denied its own object %s (status %d), findings are unlikely\n", p.id, ec.Status)
+	case ec.Public:
+		utils.Warning.Println("The endpoint answers the same without credentials, its objects may be public by design")
+	}
+	return false, nil
+}
@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// runCORS sends a request of the scan with attacker origins: one flagged
+// as an IDOR when there is one, since a site reading it with the victim's
+// cookies exploits the IDOR from the victim's browser, or else an
+// unflagged one
+func (s *Scanner) runCORS(ctx context.Context, flagged, unflagged []*fuzzer.FuzzJob) {
+	utils.PrintSection("CORS")
+
+	jobs := flagged
+	if len(jobs) == 0 {
+		jobs = unflagged
+	}
+	if len(jobs) == 0 {
+		utils.Warning.Println("Skipping CORS: no request of the scan got a response")
+		return
+	}
+	job := jobs[0]
+	headers := make(map[string]string, len(job.Headers))
+	for k, v := range job.Headers {
+		headers[k] = job.Interpolate(v)
+	}
+
+	ct := detector.NewCORSTester(s.Client)
+	ct.Session = "attacker"
+	result := ct.Test(ctx, job.HTTPMethod(), job.URL, headers, job.Interpolate(job.Body))
+	ct.PrintResult(result)
+
+	var idors []*reporter.Finding
+	if len(flagged) > 0 {
+		for _, f := range s.Reporter.Snapshot() {
+			if f.Type == reporter.FindingIDOR && f.URL == result.URL {
+				idors = append(idors, f)
+			}
+		}
+	}
+	RecordCORS(s.Reporter, result, idors)
+}
+
+// RecordCORS adds a finding for every attacker origin allowed to read the
+// response with credentials. Together with IDOR findings of the same scan
+// it is at least high severity: any site the victim visits can read other
+// users' objects through the victim's session.
+func RecordCORS(rep *reporter.Reporter, result *detector.CORSResult, idors []*reporter.Finding) {
+	for _, a := range result.Attempts {
+		if !a.Vulnerable {
+			continue
+		}
+		headers := make(map[string]string, len(result.Headers)+1)
+		for k, v := range result.Headers {
+			headers[k] = v
+		}
+		headers["Origin"] = a.Origin
+
+		f := &reporter.Finding{
+			Type:       reporter.FindingCORS,
+			Technique:  a.Technique,
+			URL:        result.URL,
+			Method:     result.Method,
+			Payload:    a.Origin,
+			StatusCode: a.StatusCode,
+			ContentLen: a.ContentLen,
+			Evidence: fmt.Sprintf("Origin %s is answered with Access-Control-Allow-Origin: %s and Access-Control-Allow-Credentials: true",
+				a.Origin, a.AllowOrigin),
+			Request: &reporter.RecordedRequest{
+				Method:  result.Method,
+				URL:     result.URL,
+				Headers: headers,
+				Body:    result.Body,
+			},
+		}
+		if rep.PII != nil {
+			f.PIIFound = rep.PII(a.Response.Body())
+		}
+		if len(idors) > 0 {
+			ids := make([]string, len(idors))
+			for i, idor := range idors {
+				ids[i] = "#" + idor.ID
+			}
+			f.Evidence += fmt.Sprintf(". Combined with IDOR findings %s, any site the victim visits can read other users' objects with the victim's session",
+				strings.Join(ids, ", "))
+			f.Score()
+			if !reporter.SeverityAtLeast(f.Severity, "HIGH") {
+				f.Severity = "HIGH"
+			}
+		}
+		rep.AddCustomFinding(f)
+	}
+}
@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/reporter"
+)
+
+// RecordDownloads adds a finding for every file of another user the
+// attacker downloaded
+func RecordDownloads(rep *reporter.Reporter, result *detector.DownloadResult) {
+	for _, d := range result.Downloads {
+		if !d.Vulnerable {
+			continue
+		}
+		f := &reporter.Finding{
+			Type:       reporter.FindingDownload,
+			Technique:  d.File.ContentType,
+			URL:        d.URL,
+			Endpoint:   result.URL,
+			Method:     "GET",
+			Payload:    d.ID,
+			StatusCode: d.StatusCode,
+			ContentLen: d.File.Size,
+			Evidence:   d.Reason + "\n" + d.File.String(),
+			Request: &reporter.RecordedRequest{
+				Method:  "GET",
+				URL:     d.URL,
+				Headers: fillHeaders(result.Headers, d.ID),
+			},
+		}
+		if rep.PII != nil {
+			f.PIIFound = rep.PII([]byte(d.File.Text()))
+		}
+		rep.AddCustomFinding(f)
+	}
+}
+
+// fillHeaders returns headers with {ID} replaced by id and credentials
+// masked, they are not written to reports
+func fillHeaders(headers map[string]string, id string) map[string]string {
+	filled := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if client.IsCredentialHeader(k) {
+			filled[k] = reporter.MaskedHeader
+			continue
+		}
+		filled[k] = strings.ReplaceAll(v, "{ID}", id)
+	}
+	return filled
+}
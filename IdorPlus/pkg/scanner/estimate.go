@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// maxProbeID bounds the search for the highest ID
+	maxProbeID = int64(1) << 40
+	// probeWindow is how many consecutive IDs a probe tries, so that a
+	// deleted record doesn't look like the end of the range
+	probeWindow = 3
+)
+
+// idProber tells existing IDs from missing ones: an existing ID gets
+// anything but the invalid baselines' answer, a 403 included. Probes are
+// GETs whatever the scan method, so they never change data.
+type idProber struct {
+	ctx      context.Context
+	s        *Scanner
+	r        *request
+	profile  *analyzer.BaselineProfile
+	requests int
+}
+
+// exists reports whether id or one of the next probeWindow-1 IDs exists
+func (p *idProber) exists(id int64) bool {
+	for i := int64(0); i < probeWindow && p.ctx.Err() == nil; i++ {
+		s := strconv.FormatInt(id+i, 10)
+		resp, err := baselineRequest(p.ctx, p.s.Client, p.r, "", s).Execute("GET", p.s.buildURL(p.r, s))
+		p.requests++
+		if err == nil && !p.profile.Matches(resp) {
+			return true
+		}
+	}
+	return false
+}
+
+// estimateRange finds the lowest and highest existing IDs: an existing ID
+// to start from, then binary searches below and above it
+func (s *Scanner) estimateRange(ctx context.Context, r *request, profile *analyzer.BaselineProfile) (int64, int64, int, error) {
+	p := &idProber{ctx: ctx, s: s, r: r, profile: profile}
+	// Baselines sent with the scan method say nothing about GETs
+	if baselineMethod(s.Options.Method, s.Options.Body) != "GET" {
+		var resps []*resty.Response
+		for _, id := range s.invalidIDs() {
+			resp, err := baselineRequest(ctx, s.Client, r, "", id).Execute("GET", s.buildURL(r, id))
+			p.requests++
+			if err != nil {
+				return 0, 0, p.requests, err
+			}
+			resps = append(resps, resp)
+		}
+		p.profile = analyzer.NewBaselineProfile(resps)
+	}
+
+	// Start from the ID in the URL, or the first of 1-9, 10-90, 100-900...
+	// that exists
+	seed, err := strconv.ParseInt(r.existingID, 10, 64)
+	if err != nil || seed <= 0 || !p.exists(seed) {
+		seed = 0
+	Seed:
+		for magnitude := int64(1); magnitude <= maxProbeID; magnitude *= 10 {
+			for digit := int64(1); digit <= 9; digit++ {
+				if p.exists(digit * magnitude) {
+					seed = digit * magnitude
+					break Seed
+				}
+			}
+		}
+	}
+	if seed == 0 {
+		return 0, 0, p.requests, errors.New("no existing ID found")
+	}
+
+	// Highest: double until an ID is missing, then bisect
+	lo, hi := seed, seed*2
+	for hi <= maxProbeID && p.exists(hi) {
+		lo, hi = hi, hi*2
+	}
+	for hi-lo > probeWindow {
+		if mid := lo + (hi-lo)/2; p.exists(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	end := lo + probeWindow - 1
+
+	// Lowest: 1 unless it is missing, then bisect below the seed
+	start := int64(1)
+	if !p.exists(1) {
+		lo, hi = 1, seed
+		for hi-lo > probeWindow {
+			if mid := lo + (hi-lo)/2; p.exists(mid) {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		start = lo
+	}
+
+	if ctx.Err() != nil {
+		return 0, 0, p.requests, ctx.Err()
+	}
+	return start, end, p.requests, nil
+}
+
+// applyEstimatedRange replaces generated numeric payloads with a sample of
+// Count IDs from the estimated range
+func (s *Scanner) applyEstimatedRange(ctx context.Context, r *request, profile *analyzer.BaselineProfile) {
+	opts := s.Options
+	switch {
+	case len(opts.Payloads) > 0 || len(opts.CanaryIDs) > 0:
+		utils.Warning.Println("Not estimating the ID range, the IDs are given")
+		return
+	case opts.Template != "":
+		utils.Warning.Println("Not estimating the ID range, the IDs come from a template")
+		return
+	case opts.Checksum != "":
+		utils.Warning.Println("Not estimating the ID range, the IDs carry check digits")
+		return
+	case len(opts.SampleIDs) > 0:
+		utils.Warning.Println("Not estimating the ID range, the IDs follow the learned pattern")
+		return
+	case r.namedOnly:
+		utils.Warning.Println("Not estimating the ID range, the request has no {ID}")
+		return
+	case !strings.Contains(opts.URL, fuzzer.PayloadPlaceholder) && strings.Contains(opts.Body, fuzzer.PayloadPlaceholder):
+		utils.Warning.Println("Not estimating the ID range, the {ID} is in the body and probes are GETs")
+		return
+	case opts.IDEnd != 0:
+		utils.Warning.Println("Not estimating the ID range, --id-range is set")
+		return
+	case r.existingID != "" && analyzer.NewIdentifierAnalyzer().DetectType(r.existingID) != analyzer.TypeNumeric:
+		utils.Warning.Println("Not estimating the ID range, the IDs are not numeric")
+		return
+	}
+
+	utils.Info.Println("Estimating the ID range...")
+	start, end, requests, err := s.estimateRange(ctx, r, profile)
+	if err != nil {
+		utils.Warning.Printf("Could not estimate the ID range after %d requests: %v\n", requests, err)
+		return
+	}
+	utils.Info.Printf("Existing IDs range from about %d to %d (%d probes)\n", start, end, requests)
+
+	opts.IDStart, opts.IDEnd, opts.IDStep, opts.Sample = start, end, 1, opts.Count
+	payloads, vars := r.payloads, r.vars
+	r.payloads = s.withKnownIDs(r, GeneratePayloads(opts))
+	if err := s.combine(r); err != nil {
+		utils.Warning.Printf("Keeping the generated IDs: %v\n", err)
+		r.payloads, r.vars = payloads, vars
+	}
+}
@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/generator"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// isWriteMethod reports whether method sends a body that is stored
+func isWriteMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
+
+// massAssignTarget returns the URL and JSON body of an object the attacker
+// owns: the first canary, or the ID in the target URL
+func (s *Scanner) massAssignTarget(r *request) (string, map[string]interface{}, error) {
+	opts := s.Options
+	if !isWriteMethod(opts.Method) {
+		return "", nil, errors.New("the target is not a POST, PUT or PATCH endpoint")
+	}
+	if opts.Body == "" || r.bodyFormat != generator.FormatJSON {
+		return "", nil, errors.New("the target has no JSON body")
+	}
+
+	// A URL without {ID} already names the attacker's object
+	id, url := r.existingID, opts.URL
+	if client.IsDestructiveMethod(opts.Method) && len(opts.CanaryIDs) > 0 {
+		id = r.payloads[0]
+		url = s.buildURL(r, id)
+	}
+	if id == "" {
+		return "", nil, errors.New("no ID of the attacker's own object, put one in the URL or use --canary")
+	}
+
+	job := &fuzzer.FuzzJob{Payload: id}
+	var base map[string]interface{}
+	if err := json.Unmarshal([]byte(job.Interpolate(opts.Body)), &base); err != nil {
+		return "", nil, fmt.Errorf("the body is not a JSON object: %w", err)
+	}
+	return url, base, nil
+}
+
+// runMassAssignment injects sensitive fields into the attacker's own object
+// and records the accepted ones
+func (s *Scanner) runMassAssignment(ctx context.Context, r *request) {
+	utils.PrintSection("Mass Assignment")
+
+	url, base, err := s.massAssignTarget(r)
+	if err != nil {
+		utils.Warning.Printf("Skipping mass assignment: %v\n", err)
+		return
+	}
+
+	mt := detector.NewMassAssignmentTester(s.Client)
+	mt.Session = "attacker"
+	result := mt.TestEndpoint(ctx, url, s.Options.Method, base)
+	mt.PrintResult(result)
+	RecordMassAssignment(s.Reporter, result)
+}
+
+// RecordMassAssignment adds a finding for every accepted parameter
+func RecordMassAssignment(rep *reporter.Reporter, result *detector.MassAssignmentResult) {
+	method := strings.ToUpper(result.Method)
+	for _, a := range result.Attempts {
+		evidence := fmt.Sprintf("Injected %q accepted with status %d (baseline %d)", a.Param, a.StatusCode, result.BaselineStatus)
+		if a.Verified {
+			evidence += ", a GET of the object returns the injected value"
+		} else {
+			evidence += ", seen in the response only"
+		}
+		rep.AddCustomFinding(&reporter.Finding{
+			Type:       reporter.FindingMassAssign,
+			Technique:  fmt.Sprintf("%s=%v", a.Param, a.Value),
+			URL:        result.URL,
+			Method:     method,
+			StatusCode: a.StatusCode,
+			ContentLen: a.ContentLen,
+			Evidence:   evidence,
+			Request: &reporter.RecordedRequest{
+				Method:  method,
+				URL:     result.URL,
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    a.Body,
+			},
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// Info describes a scan of opts with cfg for its report. Credential
+// headers, and secrets in the query and body, are masked; cookies and
+// tokens only show as the sessions they set up.
+func Info(opts Options, cfg *utils.Config) *reporter.ScanInfo {
+	info := &reporter.ScanInfo{
+		Target:        client.MaskURL(opts.URL),
+		Method:        opts.Method,
+		Body:          client.MaskBody(opts.Body),
+		Bypass:        "none",
+		Threads:       opts.Threads,
+		Delay:         cfg.Scanner.Delay,
+		Threshold:     opts.Threshold,
+		Confirmations: cfg.Detection.Confirmations,
+		Script:        opts.Script != "",
+		MaxRequests:   opts.MaxRequests,
+		MaxDuration:   opts.MaxDuration,
+	}
+	if opts.MinConfidence != nil {
+		info.MinConfidence = *opts.MinConfidence
+	}
+	if cfg.WAFBypass.Enabled {
+		info.Bypass = cfg.WAFBypass.Mode
+	}
+
+	for _, h := range opts.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if ok && client.IsCredentialHeader(name) && !strings.Contains(value, fuzzer.PayloadPlaceholder) {
+			h = name + ": " + reporter.MaskedHeader
+		}
+		info.Headers = append(info.Headers, h)
+	}
+	if opts.Cookies != "" || opts.Bearer != "" || slices.ContainsFunc(info.Headers, isMasked) {
+		info.Sessions = append(info.Sessions, "attacker")
+	}
+	if opts.CookiesB != "" {
+		info.Sessions = append(info.Sessions, "victim")
+	}
+
+	for check, enabled := range map[string]bool{
+		"auth_matrix":   opts.AuthMatrix,
+		"verb_tamper":   opts.VerbTamper,
+		"path_bypass":   opts.PathBypass,
+		"content_shift": opts.ContentShift,
+		"api_versions":  opts.APIVersions,
+		"mass_assign":   opts.MassAssign,
+		"pollution":     opts.Pollution,
+		"pagination":    opts.Pagination,
+		"methods":       opts.Methods,
+		"cors":          opts.CORS,
+		"injection":     opts.Injection,
+		"pii":           opts.PII,
+	} {
+		if enabled {
+			info.Checks = append(info.Checks, check)
+		}
+	}
+	slices.Sort(info.Checks)
+
+	p := &info.Payloads
+	p.Explicit = len(opts.Payloads)
+	if p.Explicit == 0 {
+		p.Count = opts.Count
+		if opts.IDEnd > 0 {
+			p.Count = 0
+			p.IDRange = fmt.Sprintf("%d-%d", opts.IDStart, opts.IDEnd)
+			if opts.IDStep > 1 {
+				p.IDRange += fmt.Sprintf(" step %d", opts.IDStep)
+			}
+		}
+		p.Sample = opts.Sample
+		p.SampleIDs = len(opts.SampleIDs)
+		p.Template = opts.Template
+		p.Checksum = opts.Checksum
+		p.EstimateRange = opts.EstimateRange
+	}
+	p.Encodings = opts.Encodings
+	if len(opts.Wordlists) > 0 {
+		p.Wordlists = make(map[string]int, len(opts.Wordlists))
+		for name, entries := range opts.Wordlists {
+			p.Wordlists[name] = len(entries)
+		}
+		p.Combine = opts.Combine
+	}
+	p.CanaryIDs = opts.CanaryIDs
+	return info
+}
+
+func isMasked(header string) bool {
+	return strings.HasSuffix(header, ": "+reporter.MaskedHeader)
+}
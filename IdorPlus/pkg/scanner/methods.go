@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+
+	"idorplus/pkg/detector"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// methodsProbe returns the OPTIONS request asking the endpoint which
+// methods it allows, as the attacker for the ID a probe would use
+func (s *Scanner) methodsProbe(ctx context.Context, r *request) (*resty.Request, string, string) {
+	id := r.existingID
+	if id == "" && len(r.payloads) > 0 {
+		id = r.payloads[0]
+	}
+	url := s.buildURL(r, id)
+	req := baselineRequest(ctx, s.Client, r, "", id)
+	req.SetHeaders(detector.PreflightHeaders(url))
+	return req, id, url
+}
+
+// enumerateMethods asks the endpoint which methods it allows before
+// fuzzing. It reports whether fuzzing is skipped, when the scan's method
+// isn't one of them; allowed PUT, PATCH and DELETE the scan doesn't send
+// are listed in the report.
+func (s *Scanner) enumerateMethods(ctx context.Context, r *request) bool {
+	req, _, url := s.methodsProbe(ctx, r)
+	resp, err := req.Execute("OPTIONS", url)
+	if err != nil {
+		utils.Warning.Printf("Failed to enumerate the methods: %v\n", err)
+		return false
+	}
+	support := detector.ReadMethods(url, resp)
+	s.Methods = support
+	if !support.Known() {
+		utils.Info.Printf("OPTIONS lists no methods (status %d), fuzzing %s anyway\n", support.StatusCode, s.Options.Method)
+		return false
+	}
+
+	methods := support.Methods()
+	s.Reporter.Scan.Methods = methods
+	utils.Info.Printf("Allowed methods: %s\n", strings.Join(methods, ", "))
+	if untested := support.Untested(s.Options.Method); len(untested) > 0 {
+		s.Reporter.Scan.UntestedMethods = untested
+		utils.Warning.Printf("The endpoint allows %s, which this %s scan doesn't test: rescan with --method and --allow-destructive --canary for IDs you own\n",
+			strings.Join(untested, ", "), strings.ToUpper(s.Options.Method))
+	}
+	if !support.Supports(s.Options.Method) {
+		utils.Warning.Printf("%s isn't one of the allowed methods, not fuzzing it (drop --methods to fuzz it anyway)\n", strings.ToUpper(s.Options.Method))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// paginationTarget returns the URL and headers of the attacker's own
+// listing: the target with OwnID, the first canary or the ID in the URL
+func (s *Scanner) paginationTarget(r *request) (string, map[string]string, string, error) {
+	opts := s.Options
+	if client.IsDestructiveMethod(opts.Method) {
+		return "", nil, "", errors.New("the target modifies data")
+	}
+	if s.Class != nil && s.Class.Kind != analyzer.EndpointCollection {
+		return "", nil, "", fmt.Errorf("the endpoint is a %s, not a collection", s.Class.Kind)
+	}
+	ownID := opts.OwnID
+	if ownID == "" && len(opts.CanaryIDs) > 0 {
+		ownID = opts.CanaryIDs[0]
+	}
+	if ownID == "" {
+		ownID = r.existingID
+	}
+	if ownID == "" && strings.Contains(opts.URL+opts.Body, fuzzer.PayloadPlaceholder) {
+		return "", nil, "", errors.New("no ID of the attacker's own listing, use --own-id")
+	}
+	headers := make(map[string]string, len(r.headers))
+	for k, v := range r.headers {
+		headers[k] = strings.ReplaceAll(v, fuzzer.PayloadPlaceholder, ownID)
+	}
+	return s.buildURL(r, ownID), headers, ownID, nil
+}
+
+// runPagination pages through the attacker's own listing past their own
+// records and without its owner filters, and records the listings that
+// hold other users' records
+func (s *Scanner) runPagination(ctx context.Context, r *request) {
+	utils.PrintSection("Pagination")
+
+	url, headers, ownID, err := s.paginationTarget(r)
+	if err != nil {
+		utils.Warning.Printf("Skipping pagination: %v\n", err)
+		return
+	}
+	pt := detector.NewPaginationTester(s.Client)
+	pt.Session = "attacker"
+	body := strings.ReplaceAll(s.Options.Body, fuzzer.PayloadPlaceholder, ownID)
+	result := pt.Test(ctx, s.Options.Method, url, headers, body, ownID)
+	pt.PrintResult(result)
+	RecordPagination(s.Reporter, result)
+}
+
+// RecordPagination adds a finding for every listing with other users'
+// records
+func RecordPagination(rep *reporter.Reporter, result *detector.PaginationResult) {
+	for _, a := range result.Attempts {
+		if !a.Vulnerable {
+			continue
+		}
+		f := &reporter.Finding{
+			Type:       reporter.FindingPagination,
+			Technique:  a.Param + ": " + a.Technique,
+			URL:        a.URL,
+			Endpoint:   result.URL,
+			Method:     result.Method,
+			Payload:    result.OwnID,
+			StatusCode: a.StatusCode,
+			ContentLen: a.ContentLen,
+			Evidence: fmt.Sprintf("%s. The unmodified listing has %d records, this one %d",
+				a.Reason, result.BaselineRecords, a.Records),
+			Request: &reporter.RecordedRequest{
+				Method:  result.Method,
+				URL:     a.URL,
+				Headers: result.Headers,
+				Body:    result.Body,
+			},
+		}
+		if rep.PII != nil {
+			f.PIIFound = rep.PII(a.Response)
+		}
+		rep.AddCustomFinding(f)
+	}
+}
@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// PlannedRequest is a request a scan would send, as it would go on the wire
+type PlannedRequest struct {
+	Purpose string // "methods", "probe", "invalid baseline", "valid baseline" or "fuzz"
+	Payload string
+	Method  string
+	URL     string
+	Header  http.Header
+	Body    string
+	// Err is why the request could not be built, e.g. it is out of scope
+	Err error
+}
+
+// Plan lists the requests of a scan without sending any
+type Plan struct {
+	Requests []PlannedRequest
+	// AuthMatrix is the number of auth matrix requests, one per session and
+	// one without, which are not listed
+	AuthMatrix int
+	// BypassModules are the enabled bypass modules. Each retries up to
+	// MaxBypassSamples denied requests, which are not counted.
+	BypassModules    []string
+	MaxBypassSamples int
+	// MassAssignment is the number of mass assignment requests, one per
+	// injected parameter and a baseline
+	MassAssignment int
+}
+
+// Total is the number of requests the scan sends before any bypass
+// modules, excluding retries
+func (p *Plan) Total() int {
+	return len(p.Requests) + p.AuthMatrix + p.MassAssignment
+}
+
+// Plan prepares the scan like Run and records every baseline and fuzz
+// request instead of sending it. Headers, session cookies, the hook script
+// and request signing are applied as in a real scan.
+func (s *Scanner) Plan() (*Plan, error) {
+	opts := s.Options
+	c := s.Client
+	r, err := s.prepare()
+	if err != nil {
+		return nil, err
+	}
+	// Captured requests never reach the network
+	ctx := context.Background()
+
+	var captured *http.Request
+	c.SetCapture(func(req *http.Request) { captured = req })
+	defer c.SetCapture(nil)
+
+	plan := &Plan{MaxBypassSamples: maxBypassSamples}
+	record := func(purpose, payload string, req *resty.Request, method, url string) {
+		captured = nil
+		_, err := req.SetLogger(discardLogger{}).Execute(method, url)
+		p := PlannedRequest{Purpose: purpose, Payload: payload, Method: method, URL: url}
+		if captured != nil {
+			p.Method, p.URL, p.Header = captured.Method, captured.URL.String(), captured.Header
+			p.Body = readBody(captured)
+		} else if err != nil && !errors.Is(err, client.ErrCaptured) {
+			p.Err = err
+		}
+		plan.Requests = append(plan.Requests, p)
+	}
+
+	if opts.Methods {
+		req, id, url := s.methodsProbe(ctx, r)
+		record("methods", id, req, "OPTIONS", url)
+	}
+	if !opts.NoClassify {
+		p := s.newProbe(ctx, r)
+		record("probe", p.id, p.own, p.method, p.url)
+		if p.anon != nil {
+			record("probe", p.id, p.anon, p.method, p.url)
+		}
+	}
+	method, body := baselineMethod(opts.Method, opts.Body), baselineBody(opts.Method, opts.Body)
+	for _, id := range s.invalidIDs() {
+		record("invalid baseline", id, baselineRequest(ctx, c, r, body, id), method, s.buildURL(r, id))
+	}
+	if r.existingID != "" && opts.Cookies != "" {
+		record("valid baseline", r.existingID, baselineRequest(ctx, c, r, body, r.existingID), method, s.buildURL(r, r.existingID))
+	}
+
+	for i := range r.payloads {
+		job := s.job(r, i)
+		req := c.Request(ctx)
+		fuzzer.PrepareRequest(c, req, job)
+		record("fuzz", job.Payload, req, job.HTTPMethod(), job.URL)
+	}
+
+	if opts.AuthMatrix && opts.CookiesB != "" {
+		plan.AuthMatrix = 3
+	}
+	if opts.VerbTamper {
+		plan.BypassModules = append(plan.BypassModules, "verb-tamper")
+	}
+	if opts.PathBypass {
+		plan.BypassModules = append(plan.BypassModules, "path-bypass")
+	}
+	if opts.APIVersions {
+		plan.BypassModules = append(plan.BypassModules, "api-versions")
+	}
+	if opts.ContentShift && opts.Body != "" {
+		plan.BypassModules = append(plan.BypassModules, "content-shift")
+	}
+	if opts.Pollution {
+		if _, err := s.pollutionOwnID(); err == nil {
+			plan.BypassModules = append(plan.BypassModules, "pollution")
+		}
+	}
+	if opts.Pagination {
+		if _, _, _, err := s.paginationTarget(r); err == nil {
+			plan.BypassModules = append(plan.BypassModules, "pagination")
+		}
+	}
+	if opts.CORS && !client.IsDestructiveMethod(opts.Method) {
+		plan.BypassModules = append(plan.BypassModules, "cors")
+	}
+	if opts.MassAssign {
+		if _, _, err := s.massAssignTarget(r); err == nil {
+			plan.MassAssignment = 1 + len(detector.NewMassAssignmentTester(c).GetSensitiveParams())
+		}
+	}
+	return plan, nil
+}
+
+// readBody reads a captured request's body
+func readBody(req *http.Request) string {
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			defer rc.Close()
+			data, _ := io.ReadAll(rc)
+			return string(data)
+		}
+	}
+	if req.Body == nil {
+		return ""
+	}
+	data, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return string(data)
+}
+
+// discardLogger keeps resty from logging every captured request as failed
+type discardLogger struct{}
+
+func (discardLogger) Errorf(string, ...interface{}) {}
+func (discardLogger) Warnf(string, ...interface{})  {}
+func (discardLogger) Debugf(string, ...interface{}) {}
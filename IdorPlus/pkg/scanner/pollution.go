@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// pollutionOwnID returns the ID of the attacker's own object sent next to
+// the victims' IDs
+func (s *Scanner) pollutionOwnID() (string, error) {
+	opts := s.Options
+	if client.IsDestructiveMethod(opts.Method) {
+		return "", errors.New("the target modifies data")
+	}
+	if len(detector.FindPollutionParams(opts.URL, opts.Body)) == 0 {
+		return "", errors.New("the ID is not a query or body parameter")
+	}
+	if opts.OwnID != "" {
+		return opts.OwnID, nil
+	}
+	if len(opts.CanaryIDs) > 0 {
+		return opts.CanaryIDs[0], nil
+	}
+	return "", errors.New("no ID of the attacker's own object, use --own-id")
+}
+
+// runPollution sends the attacker's own ID together with the IDs of jobs
+// the attacker couldn't access and records the variants that leak
+func (s *Scanner) runPollution(ctx context.Context, r *request, jobs []*fuzzer.FuzzJob) {
+	utils.PrintSection("Parameter Pollution")
+
+	ownID, err := s.pollutionOwnID()
+	if err != nil {
+		utils.Warning.Printf("Skipping parameter pollution: %v\n", err)
+		return
+	}
+
+	pt := detector.NewParamPollutionTester(s.Client)
+	pt.Session = "attacker"
+	if s.Options.CookiesB != "" {
+		pt.VictimSession = "victim"
+	}
+	pt.Threshold = s.Options.Threshold
+	tmpl := detector.PollutionTemplate{
+		Method:  s.Options.Method,
+		URL:     s.Options.URL,
+		Body:    s.Options.Body,
+		Headers: r.headers,
+	}
+	for _, job := range jobs {
+		result := pt.Test(ctx, tmpl, ownID, job.Payload)
+		pt.PrintResult(result)
+		s.recordPollution(result)
+	}
+}
+
+// recordPollution adds a finding for every polluted request that leaked
+func (s *Scanner) recordPollution(result *detector.PollutionResult) {
+	rep := s.Reporter
+	for _, a := range result.Attempts {
+		if !a.Vulnerable {
+			continue
+		}
+		f := &reporter.Finding{
+			Type:       reporter.FindingParamPollution,
+			Technique:  a.Param + ": " + a.Technique,
+			URL:        a.URL,
+			Endpoint:   result.Endpoint,
+			Method:     a.Method,
+			Payload:    result.VictimID,
+			StatusCode: a.StatusCode,
+			ContentLen: a.ContentLen,
+			Evidence: fmt.Sprintf("%s. Own ID %s sent with victim ID %s, which is %d when requested directly",
+				a.Reason, result.OwnID, result.VictimID, result.DirectStatus),
+			Request: &reporter.RecordedRequest{
+				Method:  a.Method,
+				URL:     a.URL,
+				Headers: a.Headers,
+				Body:    a.Body,
+			},
+		}
+		if rep.PII != nil {
+			f.PIIFound = rep.PII(a.Response)
+		}
+		rep.AddCustomFinding(f)
+	}
+}
@@ -0,0 +1,201 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileVersion is the format version of saved scan profiles
+const ProfileVersion = 1
+
+// profileFile is a saved scan profile: the options of a scan and its
+// effective config, profiles and target overrides already applied
+type profileFile struct {
+	Version int           `yaml:"version"`
+	Scan    yaml.Node     `yaml:"scan"`
+	Config  *utils.Config `yaml:"config"`
+}
+
+// secretRef matches the environment variables a profile refers to
+var secretRef = regexp.MustCompile(`\$\{(` + utils.EnvPrefix + `_[A-Z0-9_]+)\}`)
+
+// SaveProfile writes opts and cfg as a YAML scan profile to share or rerun
+// with LoadProfile. Sessions, tokens, auth headers, webhook URLs and issue
+// tracker credentials are not written: they are replaced by ${IDORPLUS_...}
+// references, named like the environment variables of the flags or
+// settings where there is one, and the profile's header comment lists
+// them. It returns the variables referred to.
+func SaveProfile(path string, opts Options, cfg *utils.Config) ([]string, error) {
+	var vars []string
+	ref := func(name string) string {
+		name = utils.EnvPrefix + "_" + name
+		vars = append(vars, name)
+		return "${" + name + "}"
+	}
+
+	if opts.Cookies != "" {
+		opts.Cookies = ref("COOKIES")
+	}
+	if opts.CookiesB != "" {
+		opts.CookiesB = ref("COOKIES_B")
+	}
+	if opts.Bearer != "" {
+		opts.Bearer = ref("AUTH")
+	}
+	opts.Headers = slices.Clone(opts.Headers)
+	for i, h := range opts.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		// Fuzzed headers are part of the scan, not a secret
+		if !ok || !client.IsAuthHeader(strings.TrimSpace(name)) || strings.Contains(value, fuzzer.PayloadPlaceholder) {
+			continue
+		}
+		env := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+		opts.Headers[i] = strings.TrimSpace(name) + ": " + ref("HEADER_"+env)
+	}
+
+	cfg = cfg.Clone()
+	cfg.Profiles, cfg.Targets = nil, nil
+	// Empty AWS credentials fall back to the AWS_* environment
+	aws := &cfg.Signing.AWS
+	aws.AccessKey, aws.SecretKey, aws.SessionToken = "", "", ""
+	// Collector credentials fall back to OTEL_EXPORTER_OTLP_HEADERS
+	cfg.Tracing.Headers = nil
+	for i := range cfg.Notify.Webhooks {
+		cfg.Notify.Webhooks[i].URL = ref("WEBHOOK_" + strconv.Itoa(i+1))
+	}
+	if cfg.Output.Elasticsearch.APIKey != "" {
+		cfg.Output.Elasticsearch.APIKey = ref("OUTPUT_ELASTICSEARCH_API_KEY")
+	}
+	if cfg.Output.Elasticsearch.Password != "" {
+		cfg.Output.Elasticsearch.Password = ref("OUTPUT_ELASTICSEARCH_PASSWORD")
+	}
+	if cfg.Export.DefectDojo.APIKey != "" {
+		cfg.Export.DefectDojo.APIKey = ref("EXPORT_DEFECTDOJO_API_KEY")
+	}
+	if cfg.Export.Jira.Token != "" {
+		cfg.Export.Jira.Token = ref("EXPORT_JIRA_TOKEN")
+	}
+
+	// Options are described by their JSON tags, which any JSON document
+	// carries over into YAML
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	p := profileFile{Version: ProfileVersion, Scan: *doc.Content[0], Config: cfg}
+	blockStyle(&p.Scan)
+
+	var b bytes.Buffer
+	b.WriteString("# idorplus scan profile, rerun with: idorplus scan --load-profile " + path + "\n")
+	if len(vars) > 0 {
+		b.WriteString("# Secrets are not saved, set these environment variables first:\n")
+		for _, v := range vars {
+			b.WriteString("#   " + v + "\n")
+		}
+	}
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&p); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return vars, os.WriteFile(path, b.Bytes(), 0600)
+}
+
+// LoadProfile reads a profile written by SaveProfile, filling in the
+// ${IDORPLUS_...} references from the environment
+func LoadProfile(path string) (Options, *utils.Config, error) {
+	var opts Options
+	data, err := os.ReadFile(utils.ExpandHome(path))
+	if err != nil {
+		return opts, nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return opts, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if missing := expandRefs(&doc); len(missing) > 0 {
+		return opts, nil, fmt.Errorf("%s refers to unset environment variables: %s", path, strings.Join(missing, ", "))
+	}
+
+	var p profileFile
+	if err := doc.Decode(&p); err != nil {
+		return opts, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Version != ProfileVersion {
+		return opts, nil, fmt.Errorf("%s: unsupported profile version %d, want %d", path, p.Version, ProfileVersion)
+	}
+	if p.Config == nil {
+		return opts, nil, fmt.Errorf("%s: no config", path)
+	}
+	if err := p.Config.Validate(); err != nil {
+		return opts, nil, fmt.Errorf("%s: invalid config:\n%w", path, err)
+	}
+
+	var scan any
+	if err := p.Scan.Decode(&scan); err != nil {
+		return opts, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	data, err = json.Marshal(scan)
+	if err != nil {
+		return opts, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		return opts, nil, fmt.Errorf("%s: invalid scan options: %w", path, err)
+	}
+	if opts.URL == "" {
+		return opts, nil, fmt.Errorf("%s: no target url", path)
+	}
+	return opts, p.Config, nil
+}
+
+// blockStyle drops the flow style and quoting of a node decoded from
+// JSON, so it is written like hand-written YAML. Strings that would read as
+// another type are still quoted.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// expandRefs fills in the ${IDORPLUS_...} references of every scalar and
+// returns the variables that are not set
+func expandRefs(n *yaml.Node) []string {
+	var missing []string
+	if n.Kind == yaml.ScalarNode {
+		n.Value = secretRef.ReplaceAllStringFunc(n.Value, func(ref string) string {
+			name := secretRef.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return value
+		})
+	}
+	for _, c := range n.Content {
+		missing = append(missing, expandRefs(c)...)
+	}
+	return missing
+}
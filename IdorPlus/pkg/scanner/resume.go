@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+// ResumeVersion is the format version of resume state files
+const ResumeVersion = 1
+
+// ResumeState is what an interrupted scan needs to pick up where it
+// stopped: the payloads not tested yet and the findings so far. Payloads
+// are stored as fuzzed, so generated and sampled IDs are not drawn again.
+type ResumeState struct {
+	Version int       `json:"version"`
+	URL     string    `json:"url"`
+	Method  string    `json:"method"`
+	Saved   time.Time `json:"saved"`
+	// Total is the number of payloads of the scan as first started
+	Total    int                 `json:"total"`
+	Payloads []string            `json:"payloads"`
+	Vars     []map[string]string `json:"vars,omitempty"`
+	Findings []*reporter.Finding `json:"findings,omitempty"`
+}
+
+// Tested is the number of payloads tested before the scan was interrupted
+func (st *ResumeState) Tested() int {
+	return st.Total - len(st.Payloads)
+}
+
+// resume swaps the payloads of r for those Resume has left
+func (s *Scanner) resume(r *request) error {
+	st := s.Resume
+	if st.URL != s.Options.URL || st.Method != s.Options.Method {
+		return fmt.Errorf("the resume state is of %s %s, not %s %s", st.Method, st.URL, s.Options.Method, s.Options.URL)
+	}
+	if st.Vars != nil && len(st.Vars) != len(st.Payloads) {
+		return fmt.Errorf("the resume state has %d payloads but %d placeholder values", len(st.Payloads), len(st.Vars))
+	}
+	r.payloads, r.vars = st.Payloads, st.Vars
+	utils.Info.Printf("Resuming: %d of %d payloads tested, %d left\n", st.Tested(), st.Total, len(st.Payloads))
+	return nil
+}
+
+// markDone records that the job of a result was tested. Failed and
+// blocked requests are tried again by a resumed scan.
+func (s *Scanner) markDone(result *fuzzer.FuzzResult) {
+	if result.Error != nil || result.Blocked {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id := result.Job.ID; id >= 0 && id < len(s.done) {
+		s.done[id] = true
+	}
+}
+
+// ResumeState returns the state to resume the last Run from, nil if it
+// had not got to its payloads. It is safe to call while Run is still
+// going, e.g. when it does not stop in time.
+func (s *Scanner) ResumeState() *ResumeState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.req
+	if r == nil {
+		return nil
+	}
+	st := &ResumeState{
+		Version:  ResumeVersion,
+		URL:      s.Options.URL,
+		Method:   s.Options.Method,
+		Saved:    time.Now(),
+		Total:    len(r.payloads),
+		Findings: s.Reporter.Snapshot(),
+	}
+	if s.Resume != nil {
+		st.Total = s.Resume.Total
+	}
+	st.Payloads = []string{}
+	for i, p := range r.payloads {
+		if s.done[i] {
+			continue
+		}
+		st.Payloads = append(st.Payloads, p)
+		if r.vars != nil {
+			st.Vars = append(st.Vars, r.vars[i])
+		}
+	}
+	return st
+}
+
+// SaveResume writes st to path
+func SaveResume(path string, st *ResumeState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// LoadResume reads a state file written by SaveResume
+func LoadResume(path string) (*ResumeState, error) {
+	data, err := os.ReadFile(utils.ExpandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	var st ResumeState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if st.Version != ResumeVersion {
+		return nil, fmt.Errorf("%s: unsupported resume state version %d, want %d", path, st.Version, ResumeVersion)
+	}
+	return &st, nil
+}
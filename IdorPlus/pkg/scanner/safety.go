@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+)
+
+// ErrDeclined is returned when Confirm declines a destructive scan
+var ErrDeclined = errors.New("destructive scan declined")
+
+// guardDestructive refuses destructive scans that were not allowed, limits
+// the payloads of canary scans to the canary IDs and installs the client's
+// safety guard
+func (s *Scanner) guardDestructive(r *request) error {
+	opts := s.Options
+	destructive := client.IsDestructiveMethod(opts.Method)
+	if destructive && !opts.AllowDestructive {
+		return fmt.Errorf("%w: %s requests change or delete data and must be allowed explicitly (--allow-destructive)",
+			client.ErrDestructive, strings.ToUpper(opts.Method))
+	}
+
+	safety := &client.Safety{AllowDestructive: opts.AllowDestructive}
+	if destructive && len(opts.CanaryIDs) > 0 {
+		// Explicit payloads, e.g. a cluster shard, keep only their canaries
+		if len(opts.Payloads) > 0 {
+			r.payloads = slices.DeleteFunc(slices.Clone(opts.Payloads), func(id string) bool {
+				return !slices.Contains(opts.CanaryIDs, id)
+			})
+		} else {
+			r.payloads = slices.Clone(opts.CanaryIDs)
+		}
+		if len(r.payloads) == 0 {
+			return errors.New("none of the payloads is a canary ID")
+		}
+		// The invalid baseline IDs do not exist, so they are safe to send
+		safety.Canaries = append(slices.Clone(r.payloads), s.invalidIDs()...)
+		utils.Info.Printf("Canary mode: destructive requests limited to %d IDs\n", len(r.payloads))
+	}
+	s.Client.SetSafety(safety)
+	return nil
+}
+
+// verifyCanaries fetches every canary with GET, using the second session if
+// there is one, and drops those that do not exist. Canaries must be
+// resources the tester owns, so a failed check means a wrong ID.
+func (s *Scanner) verifyCanaries(ctx context.Context, r *request) error {
+	opts := s.Options
+	checkURL := opts.CanaryCheckURL
+	if checkURL == "" {
+		checkURL = opts.URL
+	}
+	if !strings.Contains(checkURL, fuzzer.PayloadPlaceholder) {
+		return errors.New("canary IDs are verified with a GET of a URL containing {ID}, set a canary check URL")
+	}
+	session := "attacker"
+	if opts.CookiesB != "" {
+		session = "victim"
+	}
+
+	var verified []string
+	for _, id := range r.payloads {
+		job := &fuzzer.FuzzJob{Payload: id, Headers: r.headers, Session: session}
+		req := s.Client.Request(ctx)
+		fuzzer.PrepareRequest(s.Client, req, job)
+		resp, err := req.Get(ReplaceID(checkURL, id))
+		switch {
+		case err != nil:
+			utils.Warning.Printf("Canary %s not verified: %v\n", id, err)
+		case !resp.IsSuccess():
+			utils.Warning.Printf("Canary %s not verified: status %d\n", id, resp.StatusCode())
+		default:
+			verified = append(verified, id)
+		}
+	}
+	if len(verified) == 0 {
+		return errors.New("no canary ID could be verified")
+	}
+
+	r.payloads = verified
+	s.Client.SetSafety(&client.Safety{
+		AllowDestructive: true,
+		Canaries:         append(slices.Clone(verified), s.invalidIDs()...),
+	})
+	utils.Success.Printf("%d/%d canaries verified\n", len(verified), len(opts.CanaryIDs))
+	return nil
+}
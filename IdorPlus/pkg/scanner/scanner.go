@@ -0,0 +1,943 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/generator"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/script"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Options describes what to scan
+type Options struct {
+	URL      string   `json:"url"`                 // target, {ID} marks the fuzzed position
+	Method   string   `json:"method,omitempty"`    // default GET
+	Body     string   `json:"body,omitempty"`      // {ID} is fuzzed per request
+	Headers  []string `json:"headers,omitempty"`   // "Name: value", {ID} is fuzzed per request
+	Cookies  string   `json:"cookies,omitempty"`   // attacker session
+	CookiesB string   `json:"cookies_b,omitempty"` // second user for auth matrix testing
+	Bearer   string   `json:"bearer,omitempty"`
+
+	// Payloads are explicit IDs to try, e.g. from a wordlist. When empty,
+	// Count IDs are generated from the detected ID type.
+	Payloads []string `json:"payloads,omitempty"`
+	Count    int      `json:"count,omitempty"`
+	// Wordlists fill named placeholders such as {ORG} besides {ID},
+	// combined with the IDs and each other by Combine: CombineProduct
+	// (default) or CombineZip
+	Wordlists map[string][]string `json:"wordlists,omitempty"`
+	Combine   string              `json:"combine,omitempty"`
+	// SampleIDs are known IDs, generated IDs follow the pattern learned
+	// from them
+	SampleIDs []string `json:"sample_ids,omitempty"`
+	// Checksum appends check digits (luhn or mod97) to generated numeric
+	// IDs. It is detected from the known IDs when empty.
+	Checksum string `json:"checksum,omitempty"`
+	// IDStart-IDEnd is the range of generated numeric IDs, IDStep apart,
+	// and Sample picks that many of them at random. Without IDEnd, Count
+	// IDs from IDStart (default 1) are generated.
+	IDStart int64 `json:"id_start,omitempty"`
+	IDEnd   int64 `json:"id_end,omitempty"`
+	IDStep  int64 `json:"id_step,omitempty"`
+	Sample  int   `json:"sample,omitempty"`
+	// Template builds structured IDs such as INV-{num:06d} from the
+	// numeric IDs, see generator.Template
+	Template string `json:"template,omitempty"`
+	// EstimateRange probes for the lowest and highest existing IDs before
+	// fuzzing and samples Count generated IDs from that range
+	EstimateRange bool `json:"estimate_range,omitempty"`
+	// Encodings add encoded copies of every payload, each an encoding or a
+	// chain such as base64+url, see generator.Encodings
+	Encodings []string `json:"encodings,omitempty"`
+	// Injection adds NoSQL operator and always-true SQL filter IDs to the
+	// generated ones. They are never sent with PUT, PATCH or DELETE.
+	Injection bool `json:"injection,omitempty"`
+
+	Threads     int     `json:"threads,omitempty"`
+	Threshold   float64 `json:"threshold,omitempty"`
+	PII         bool    `json:"pii"`
+	AuthMatrix  bool    `json:"auth_matrix,omitempty"`
+	MaxFindings int     `json:"max_findings,omitempty"`
+	// MaxRequests and MaxDuration (e.g. 30m) are the scan's budget: no
+	// request is sent beyond either, see client.Budget
+	MaxRequests int    `json:"max_requests,omitempty"`
+	MaxDuration string `json:"max_duration,omitempty"`
+	// MinConfidence (0-100) drops findings scored lower, nil for
+	// Detection.MinConfidence; 0 keeps every finding
+	MinConfidence *int `json:"min_confidence,omitempty"`
+
+	// Script is the source of a Starlark hook script, see script.Script
+	Script string `json:"script,omitempty"`
+
+	VerbTamper   bool `json:"verb_tamper,omitempty"`
+	PathBypass   bool `json:"path_bypass,omitempty"`
+	ContentShift bool `json:"content_shift,omitempty"`
+	APIVersions  bool `json:"api_versions,omitempty"` // retry denied and vulnerable requests on /v1/ for /v2/, /api/internal/, mobile prefixes
+	// MassAssign injects privileged fields into the JSON body of a POST,
+	// PUT or PATCH target, sent for the attacker's own object
+	MassAssign bool `json:"mass_assign,omitempty"`
+	// Pollution sends OwnID together with the IDs the attacker was denied:
+	// duplicated, as arrays and split between the query and the body
+	Pollution bool `json:"pollution,omitempty"`
+	// Pagination lists the attacker's own collection with huge page sizes,
+	// offsets past their records and without owner filters
+	Pagination bool `json:"pagination,omitempty"`
+	// CORS sends a request of the scan, an IDOR finding if there is one,
+	// with attacker origins and reports those allowed to read it with
+	// credentials
+	CORS bool `json:"cors,omitempty"`
+	// OwnID is the ID of an object the attacker owns, default the first
+	// canary. Without an ID in the URL, generated IDs are shaped like it.
+	OwnID string `json:"own_id,omitempty"`
+
+	// AllowDestructive permits scans with PUT, PATCH or DELETE. CanaryIDs
+	// then restricts them to these IDs, resources the tester owns, which
+	// are verified with a GET of CanaryCheckURL (default URL) first.
+	AllowDestructive bool     `json:"allow_destructive,omitempty"`
+	CanaryIDs        []string `json:"canary_ids,omitempty"`
+	CanaryCheckURL   string   `json:"canary_check_url,omitempty"`
+
+	// NoClassify fuzzes the endpoint without probing what it is first,
+	// see Scanner.Class
+	NoClassify bool `json:"no_classify,omitempty"`
+	// Methods asks the endpoint which methods it allows with OPTIONS first
+	// and skips fuzzing a method it doesn't, see Scanner.Methods
+	Methods bool `json:"methods,omitempty"`
+}
+
+// Scanner runs the IDOR scan pipeline: baselines, payload generation,
+// fuzzing and the bypass modules. Findings go to Reporter.
+type Scanner struct {
+	Client   *client.SmartClient
+	Config   *utils.Config
+	Options  Options
+	Reporter *reporter.Reporter
+
+	// Store, when set, receives every result and the findings of the scan
+	Store  *store.Store
+	ScanID int64
+	// SessionFile keeps the cookies servers set for the sessions across
+	// runs: loaded before the scan if it exists, written after it. Cookies
+	// of Options.Cookies and CookiesB win over those loaded.
+	SessionFile string
+
+	// OnStart is called with the number of payloads before fuzzing starts
+	OnStart func(total int)
+	// OnResult is called for every fuzz result, vulnerable or not
+	OnResult func(*fuzzer.FuzzResult)
+	// Confirm, when set, is asked before a destructive scan sends anything
+	Confirm func(method, endpoint string, requests int) bool
+
+	// Engine is the fuzz engine of the last Run, for its stats
+	Engine *fuzzer.FuzzEngine
+	// Class is what the probe of the last Run found the endpoint to be,
+	// nil when it wasn't probed. Static assets aren't fuzzed, and an empty
+	// list from a collection is not a finding.
+	Class *analyzer.EndpointClass
+	// Methods is what OPTIONS returned in the last Run with
+	// Options.Methods, nil when it wasn't sent
+	Methods *detector.MethodSupport
+
+	// Resume, when set, continues an interrupted scan with the payloads it
+	// has left instead of generating them, see ResumeState
+	Resume *ResumeState
+
+	// mu guards req and done, the payloads of the last Run and which of
+	// them were tested
+	mu   sync.Mutex
+	req  *request
+	done []bool
+}
+
+// maxBypassSamples caps how many denied requests the bypass modules retry
+const maxBypassSamples = 3
+
+// invalidID is the ID of the invalid baseline, assumed not to exist.
+// Further samples count down from it, see invalidIDs.
+const invalidID = "999999999999999"
+
+// maxInvalidSamples caps Detection.InvalidSamples
+const maxInvalidSamples = 5
+
+// storeBatchSize is how many results are written per database transaction
+const storeBatchSize = 200
+
+// New creates a scanner. Options defaults are filled in from cfg.
+func New(c *client.SmartClient, cfg *utils.Config, opts Options) *Scanner {
+	if opts.Method == "" {
+		opts.Method = "GET"
+	}
+	if opts.Threads <= 0 {
+		opts.Threads = cfg.Scanner.Threads
+	}
+	if opts.Threads <= 0 {
+		opts.Threads = 10
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = cfg.Detection.Threshold
+	}
+	if opts.MinConfidence == nil && cfg.Detection.MinConfidence > 0 {
+		minConfidence := cfg.Detection.MinConfidence
+		opts.MinConfidence = &minConfidence
+	}
+	if opts.Count <= 0 {
+		opts.Count = 100
+	}
+
+	return &Scanner{
+		Client:   c,
+		Config:   cfg,
+		Options:  opts,
+		Reporter: reporter.NewReporter(cfg.Output.Format),
+	}
+}
+
+// request is the request template of a scan, shared by Run and Plan
+type request struct {
+	hooks      *script.Script
+	headers    map[string]string // headers with {ID}, filled in per job
+	auth       map[string]string // the attacker's auth headers, see setAuth
+	bodyFormat string
+	existingID string
+	payloads   []string
+	// real are the IDs of existing objects and listed those the user gave,
+	// encoded as payloads, see priority
+	real   map[string]bool
+	listed map[string]bool
+	// outsideURL is set when {ID} only appears in headers, cookies or the
+	// body, so the URL is used as-is
+	outsideURL bool
+	// vars are the values of named placeholders per payload, see
+	// Options.Wordlists. With namedOnly the request has no {ID}.
+	vars      []map[string]string
+	namedOnly bool
+}
+
+// prepare loads the hook script, sets up the client's sessions and default
+// headers and generates the payloads
+func (s *Scanner) prepare() (*request, error) {
+	opts := s.Options
+	c := s.Client
+	if opts.URL == "" {
+		return nil, errors.New("no target URL")
+	}
+	for _, chain := range opts.Encodings {
+		if err := generator.ValidateEncoding(chain); err != nil {
+			return nil, err
+		}
+	}
+	if err := opts.validateWordlists(); err != nil {
+		return nil, err
+	}
+	if len(opts.SampleIDs) > 0 {
+		if _, err := analyzer.NewIdentifierAnalyzer().LearnFromSamples(opts.SampleIDs); err != nil {
+			return nil, err
+		}
+	}
+	if err := numericGenerator(opts).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ID range: %w", err)
+	}
+	if opts.Template != "" {
+		if _, err := generator.ParseTemplate(opts.Template); err != nil {
+			return nil, err
+		}
+	}
+	r := &request{headers: make(map[string]string), auth: make(map[string]string)}
+
+	// Hook scripts must be in place before the baselines
+	if opts.Script != "" {
+		var err error
+		if r.hooks, err = script.Load("script", opts.Script); err != nil {
+			return nil, fmt.Errorf("invalid script: %w", err)
+		}
+		if r.hooks.HasRequestHook() {
+			c.SetRequestMutator(r.hooks)
+		}
+		utils.Info.Println("Using hook script")
+	}
+
+	// Set up sessions, with the cookies of earlier runs
+	if s.SessionFile != "" {
+		if err := c.GetSessionManager().Load(s.SessionFile); err == nil {
+			utils.Info.Printf("Restored session cookies from %s\n", s.SessionFile)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("invalid session file: %w", err)
+		}
+	}
+	if opts.Cookies != "" {
+		c.GetSessionManager().AddSession("attacker", opts.Cookies)
+	}
+	if opts.CookiesB != "" {
+		c.GetSessionManager().AddSession("victim", opts.CookiesB)
+	}
+
+	// Add custom headers. Headers containing {ID} are filled in per job.
+	for _, h := range opts.Headers {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) == 2 {
+			key := strings.TrimSpace(parts[0])
+			val := strings.TrimSpace(parts[1])
+			if strings.Contains(val, fuzzer.PayloadPlaceholder) || opts.hasNamedPlaceholder(val) {
+				r.headers[key] = val
+				utils.Info.Printf("Fuzzed header: %s\n", key)
+				continue
+			}
+			if client.IsAuthHeader(key) {
+				r.auth[key] = val
+				utils.Info.Printf("Auth header: %s\n", key)
+				continue
+			}
+			c.SetDefaultHeader(key, val)
+			utils.Info.Printf("Custom header: %s\n", key)
+		}
+	}
+
+	// Bodies get a Content-Type matching their format unless one was given
+	if opts.Body != "" {
+		r.bodyFormat = generator.DetectBodyFormat(opts.Body)
+		if !hasHeader(opts.Headers, "Content-Type") {
+			c.SetDefaultHeader("Content-Type", generator.ContentTypeFor(r.bodyFormat))
+		}
+	}
+
+	r.outsideURL = placeholderOutsideURL(opts)
+	if !r.outsideURL && !opts.hasNamedPlaceholder(opts.URL) {
+		r.existingID = extractExistingID(opts.URL)
+	}
+
+	// Add bearer token
+	if opts.Bearer != "" {
+		r.auth["Authorization"] = "Bearer " + opts.Bearer
+		utils.Info.Println("Using Bearer token authentication")
+	}
+	if len(r.auth) > 0 && c.GetSessionManager().GetSession("attacker") == nil {
+		c.GetSessionManager().AddSession("attacker", "")
+	}
+	s.setAuth(r, "attacker")
+	r.setPriorities(opts)
+
+	// Canary scans only fuzz the canaries
+	if err := s.guardDestructive(r); err != nil {
+		return nil, err
+	}
+	if len(r.payloads) > 0 {
+		return r, nil
+	}
+
+	// Explicit payloads are real candidates and run ahead of generated
+	// sequences, the known IDs ahead of both
+	r.payloads = EncodePayloads(opts, opts.Payloads)
+	if len(r.payloads) == 0 && !opts.usesPlaceholder(fuzzer.PayloadPlaceholder) && len(opts.Wordlists) > 0 {
+		// Only the named wordlists are fuzzed
+	} else {
+		if len(r.payloads) == 0 {
+			r.payloads = GeneratePayloads(opts)
+			utils.Info.Printf("Generated %d payloads\n", len(r.payloads))
+		}
+		r.payloads = s.withKnownIDs(r, r.payloads)
+	}
+	if err := s.combine(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// setPriorities records which payloads are real IDs: the ID in the URL,
+// OwnID, the known IDs and the canaries, and which the user listed
+func (r *request) setPriorities(opts Options) {
+	real := append([]string{r.existingID, opts.OwnID}, opts.SampleIDs...)
+	real = append(real, opts.CanaryIDs...)
+	r.real = make(map[string]bool)
+	for _, id := range EncodePayloads(opts, real) {
+		r.real[id] = id != ""
+	}
+	r.listed = make(map[string]bool)
+	for _, id := range EncodePayloads(opts, opts.Payloads) {
+		r.listed[id] = true
+	}
+}
+
+// priority returns the queue priority of a payload: real IDs first, then
+// those the user listed, then generated sequences
+func (r *request) priority(payload string) int {
+	switch {
+	case r.real[payload]:
+		return fuzzer.PriorityHarvested
+	case r.listed[payload], r.namedOnly:
+		return fuzzer.PriorityWordlist
+	}
+	return fuzzer.PrioritySynthetic
+}
+
+// withKnownIDs adds the known IDs missing from payloads and orders them by
+// priority, so a scan cut short has tried the real IDs
+func (s *Scanner) withKnownIDs(r *request, payloads []string) []string {
+	for _, id := range EncodePayloads(s.Options, s.Options.SampleIDs) {
+		if !slices.Contains(payloads, id) {
+			payloads = append(payloads, id)
+		}
+	}
+	slices.SortStableFunc(payloads, func(a, b string) int {
+		return r.priority(b) - r.priority(a)
+	})
+	return payloads
+}
+
+// buildURL returns the target URL for an ID, named placeholders filled as
+// for a baseline
+func (s *Scanner) buildURL(r *request, id string) string {
+	return s.urlFor(r, id, r.baselineVars(id))
+}
+
+// urlFor returns the target URL for an ID and named placeholder values
+func (s *Scanner) urlFor(r *request, id string, vars map[string]string) string {
+	url := s.Options.URL
+	if !r.namedOnly && (!r.outsideURL || strings.Contains(url, fuzzer.PayloadPlaceholder)) {
+		url = ReplaceID(url, id)
+	}
+	for name, value := range vars {
+		url = strings.ReplaceAll(url, "{"+name+"}", value)
+	}
+	return url
+}
+
+// job returns the fuzz job for the i-th payload
+func (s *Scanner) job(r *request, i int) *fuzzer.FuzzJob {
+	p := r.payloads[i]
+	var vars map[string]string
+	if r.vars != nil {
+		vars = r.vars[i]
+	}
+	return &fuzzer.FuzzJob{
+		ID:       i,
+		URL:      s.urlFor(r, p, vars),
+		Method:   s.Options.Method,
+		Payload:  p,
+		Headers:  r.headers,
+		Body:     s.Options.Body,
+		Session:  "attacker",
+		Priority: r.priority(p),
+		Endpoint: s.Options.URL,
+		Vars:     vars,
+	}
+}
+
+// Run executes the scan. Cancelling ctx stops fuzzing and skips the bypass
+// modules; findings recorded so far stay in the Reporter.
+func (s *Scanner) Run(ctx context.Context) error {
+	opts := s.Options
+	c := s.Client
+	url, method, body := opts.URL, opts.Method, opts.Body
+
+	r, err := s.prepare()
+	if err != nil {
+		return err
+	}
+	if s.Resume != nil {
+		if err := s.resume(r); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.req, s.done = r, make([]bool, len(r.payloads))
+	s.mu.Unlock()
+	s.Reporter.Scan = Info(opts, s.Config)
+	if opts.MaxRequests > 0 || opts.MaxDuration != "" {
+		maxDuration, err := time.ParseDuration(opts.MaxDuration)
+		if err != nil && opts.MaxDuration != "" {
+			return fmt.Errorf("invalid max duration: %w", err)
+		}
+		c.SetBudget(client.NewBudget(int64(opts.MaxRequests), maxDuration))
+	}
+	if s.SessionFile != "" {
+		defer func() {
+			if err := c.GetSessionManager().Save(s.SessionFile); err != nil {
+				utils.Warning.Printf("Failed to save session cookies: %v\n", err)
+			}
+		}()
+	}
+	if client.IsDestructiveMethod(method) {
+		if len(opts.CanaryIDs) > 0 {
+			if err := s.verifyCanaries(ctx, r); err != nil {
+				return err
+			}
+		}
+		if s.Confirm != nil && !s.Confirm(strings.ToUpper(method), url, len(r.payloads)) {
+			return ErrDeclined
+		}
+	}
+
+	// Check proxy health before the baselines go through them
+	proxyCheckURL := s.Config.Scanner.ProxyCheckURL
+	if proxyCheckURL == "" {
+		proxyCheckURL = ReplaceID(url, "1")
+	}
+	if proxyCount := c.GetProxyManager().Count(); proxyCount > 0 {
+		utils.Info.Printf("Using %d proxies\n", proxyCount)
+
+		healthy := c.GetProxyManager().CheckHealth(ctx, proxyCheckURL, 10*time.Second)
+		if healthy == 0 {
+			return errors.New("no healthy proxies")
+		}
+		utils.Info.Printf("%d/%d proxies healthy\n", healthy, proxyCount)
+	}
+
+	// Find out what the endpoint is before spending requests on it
+	if opts.Methods && s.enumerateMethods(ctx, r) {
+		return nil
+	}
+	if !opts.NoClassify {
+		if skip, err := s.classify(ctx, r); err != nil || skip {
+			return err
+		}
+	}
+
+	// Get baselines
+	utils.Info.Println("Establishing baselines...")
+
+	// Invalid baselines (non-existent resources), several to measure how
+	// much the response varies
+	var invalidResps []*resty.Response
+	for _, id := range s.invalidIDs() {
+		resp, err := baselineRequest(ctx, c, r, baselineBody(method, body), id).Execute(baselineMethod(method, body), s.buildURL(r, id))
+		if err != nil {
+			return fmt.Errorf("failed to get invalid baseline: %w", err)
+		}
+		utils.Debug.Printf("Invalid baseline %s: Status %d, Length %d\n", id, resp.StatusCode(), analyzer.BodySize(resp))
+		invalidResps = append(invalidResps, resp)
+	}
+	invalidResp := invalidResps[0]
+	if blocked, reason := c.GetBlockPageDetector().Check(invalidResp); blocked {
+		utils.Warning.Printf("Invalid baseline looks like a WAF block page (%s), results may be unreliable\n", reason)
+	}
+	invalidProfile := analyzer.NewBaselineProfile(invalidResps)
+	utils.Debug.Printf("Invalid baseline profile: Status %d, Length %.0f ± %.0f, %d stable tokens\n",
+		invalidProfile.Status, invalidProfile.MeanLen, invalidProfile.LengthTolerance(), len(invalidProfile.Tokens))
+
+	if opts.EstimateRange {
+		s.applyEstimatedRange(ctx, r, invalidProfile)
+	}
+
+	// Valid baseline (if we have an existing ID in the URL)
+	var validResp = invalidResp // Fallback
+	if r.existingID != "" && opts.Cookies != "" {
+		validURL := s.buildURL(r, r.existingID)
+		vr, err := baselineRequest(ctx, c, r, baselineBody(method, body), r.existingID).Execute(baselineMethod(method, body), validURL)
+		if err == nil {
+			validResp = vr
+			utils.Debug.Printf("Valid baseline: Status %d, Length %d\n", validResp.StatusCode(), analyzer.BodySize(validResp))
+		}
+	}
+
+	// Create detector
+	det := detector.NewIDORDetector(validResp, invalidResp, opts.Threshold, opts.PII)
+	if len(invalidResps) > 1 {
+		det.InvalidProfile = invalidProfile
+	}
+	det.Collection = s.Class != nil && s.Class.Kind == analyzer.EndpointCollection
+	if s.Config.Detection.Weights != (utils.ConfidenceWeights{}) {
+		det.Weights = s.Config.Detection.Weights
+	}
+	if opts.MinConfidence != nil {
+		det.MinConfidence = *opts.MinConfidence
+	}
+
+	// Auth Matrix testing
+	if opts.AuthMatrix && opts.CookiesB != "" {
+		utils.PrintSection("Auth Matrix Testing")
+		amt := detector.NewAuthMatrixTester(c)
+		amt.AddSession("user_a", opts.Cookies)
+		amt.AddSession("user_b", opts.CookiesB)
+		s.setAuth(r, "user_a")
+
+		testURL := s.buildURL(r, r.existingID)
+		result := amt.TestEndpoint(ctx, testURL, method)
+		amt.PrintMatrix(result)
+	}
+
+	// Periodic proxy health checks
+	if c.GetProxyManager().IsEnabled() && s.Config.Scanner.ProxyCheckInterval != "" {
+		if interval, err := time.ParseDuration(s.Config.Scanner.ProxyCheckInterval); err == nil {
+			c.GetProxyManager().StartHealthChecks(ctx, proxyCheckURL, interval, 10*time.Second)
+		}
+	}
+
+	// Initialize fuzzer
+	fe := fuzzer.NewFuzzEngine(c, opts.Threads, det)
+	fe.MaxInFlight = s.Config.Scanner.MaxInFlight
+	fe.MaxPerHost = s.Config.Scanner.MaxPerHost
+	fe.MaxFindings = opts.MaxFindings
+	fe.Queue.SetLimit(s.Config.Scanner.QueueSize)
+	fe.Overflow = s.Config.Scanner.ResultOverflow
+	if d, err := time.ParseDuration(s.Config.Scanner.JobTimeout); err == nil {
+		fe.JobTimeout = d
+	}
+	if d, err := time.ParseDuration(s.Config.Scanner.SlowP95); err == nil {
+		fe.SlowP95 = d
+	}
+	// Findings' bodies wait on disk until the reporter has read them
+	if dir, err := os.MkdirTemp("", "idorplus-bodies-"); err != nil {
+		utils.Warning.Printf("Keeping finding bodies in memory: %v\n", err)
+	} else {
+		fe.BodyDir, fe.SpillDir = dir, dir
+		defer os.RemoveAll(dir)
+	}
+	// Re-sending destructive requests, as the victim or to re-test a
+	// finding, would change data again
+	if !client.IsDestructiveMethod(method) {
+		if opts.CookiesB != "" {
+			fe.VictimSession = "victim"
+		}
+		fe.Confirmations = s.Config.Detection.Confirmations
+	}
+	if r.hooks != nil && r.hooks.HasResponseHook() {
+		fe.Verdict = r.hooks.Verdict
+	}
+	if s.Config.WAFBypass.BlockCooldown != "" {
+		if d, err := time.ParseDuration(s.Config.WAFBypass.BlockCooldown); err == nil {
+			fe.BlockCooldown = d
+		}
+	}
+	s.Engine = fe
+	fe.Start()
+
+	// Queued jobs are not re-checked against ctx, so stop the engine directly
+	stop := context.AfterFunc(ctx, fe.Cancel)
+	defer stop()
+
+	if s.OnStart != nil {
+		s.OnStart(len(r.payloads))
+	}
+
+	// Feed jobs in goroutine
+	go func() {
+	JobLoop:
+		for i := range r.payloads {
+			select {
+			case <-ctx.Done():
+				break JobLoop
+			default:
+				if !fe.Submit(s.job(r, i)) {
+					break JobLoop
+				}
+			}
+		}
+		fe.CloseQueue()
+		fe.WaitAndClose() // Wait for workers and close Results channel
+	}()
+
+	// Collect results
+	rep := s.Reporter
+	if opts.PII {
+		rep.PII = det.GetPIIMatches
+	}
+	if s.Store != nil {
+		if s.ScanID, err = s.Store.BeginScan(url, rep.StartTime); err != nil {
+			utils.Warning.Printf("Results database disabled: %v\n", err)
+			s.Store = nil
+		}
+	}
+
+	var denied, flagged, unflagged []*fuzzer.FuzzJob
+	var batch []*fuzzer.FuzzResult
+	for result := range fe.Results {
+		if s.OnResult != nil {
+			s.OnResult(result)
+		}
+
+		if s.Store != nil {
+			if batch = append(batch, result); len(batch) >= storeBatchSize {
+				s.saveResults(batch)
+				batch = nil
+			}
+		}
+
+		if detector.IsDenied(result.StatusCode) && len(denied) < maxBypassSamples {
+			denied = append(denied, result.Job)
+		}
+
+		if result.IsVulnerable {
+			rep.AddFinding(result)
+			if len(flagged) < maxBypassSamples {
+				flagged = append(flagged, result.Job)
+			}
+		} else if len(unflagged) < maxBypassSamples {
+			unflagged = append(unflagged, result.Job)
+		}
+		// After its finding, so a resume state never loses one
+		s.markDone(result)
+	}
+	if s.Store != nil && len(batch) > 0 {
+		s.saveResults(batch)
+	}
+	rep.SlowEndpoints = append(rep.SlowEndpoints, fe.SlowEndpoints()...)
+	rep.Statistics = fe.Stats.Breakdown()
+	rep.Coverage = fe.Coverage()
+
+	// Checks left out by the budget are listed in its report
+	var unrun []string
+	shouldRun := func(check string, enabled bool) bool {
+		if !enabled || ctx.Err() != nil {
+			return false
+		}
+		if c.GetBudget().Exhausted() != "" {
+			unrun = append(unrun, check)
+			return false
+		}
+		return true
+	}
+
+	// Retry denied requests with bypass techniques
+	if len(denied) > 0 {
+		if shouldRun("verb tampering", opts.VerbTamper) {
+			runVerbTamper(ctx, c, rep, denied, "attacker")
+		}
+		if shouldRun("path bypass", opts.PathBypass) {
+			runPathBypass(ctx, c, rep, denied, "attacker")
+		}
+		if shouldRun("content-type shifting", opts.ContentShift && body != "") {
+			runContentShift(ctx, c, rep, denied, r.bodyFormat, "attacker")
+		}
+	}
+	if shouldRun("API versions", opts.APIVersions && len(denied)+len(flagged) > 0) {
+		runAPIVersions(ctx, c, rep, append(flagged, denied...), "attacker")
+	}
+	// Without denied requests, e.g. when others' objects are 404, pollute
+	// any IDs that weren't flagged
+	if shouldRun("parameter pollution", opts.Pollution) {
+		if len(denied) > 0 {
+			s.runPollution(ctx, r, denied)
+		} else {
+			s.runPollution(ctx, r, unflagged)
+		}
+	}
+	if shouldRun("pagination", opts.Pagination) {
+		s.runPagination(ctx, r)
+	}
+	if shouldRun("CORS", opts.CORS && !client.IsDestructiveMethod(method)) {
+		s.runCORS(ctx, flagged, unflagged)
+	}
+	if shouldRun("mass assignment", opts.MassAssign) {
+		s.runMassAssignment(ctx, r)
+	}
+	if rep.Budget = fe.BudgetReport(); rep.Budget != nil {
+		rep.Budget.Skipped = unrun
+	}
+
+	if s.Store != nil {
+		if err := s.Store.SaveFindings(s.ScanID, rep.Findings); err != nil {
+			utils.Warning.Printf("Failed to store findings: %v\n", err)
+		}
+		s.Store.FinishScan(s.ScanID)
+	}
+
+	return ctx.Err()
+}
+
+// GeneratePayloads generates opts.Count IDs shaped like the ID already in
+// the target URL, or else opts.OwnID. They are numeric without a sample or
+// when a range is set.
+func GeneratePayloads(opts Options) []string {
+	idType := analyzer.TypeNumeric
+	var pattern *analyzer.IDPattern
+	var learned *analyzer.LearnedPattern
+	if opts.IDEnd == 0 && opts.Template == "" && len(opts.SampleIDs) > 0 {
+		var err error
+		if learned, err = analyzer.NewIdentifierAnalyzer().LearnFromSamples(opts.SampleIDs); err != nil {
+			utils.Warning.Printf("Not learning the ID pattern: %v\n", err)
+		} else {
+			utils.Info.Printf("Learned ID pattern %s from %d IDs\n", learned, len(opts.SampleIDs))
+		}
+	}
+	if opts.IDEnd == 0 && opts.Template == "" && learned == nil {
+		sample := opts.OwnID
+		if !placeholderOutsideURL(opts) {
+			if existingID := extractExistingID(opts.URL); existingID != "" {
+				sample = existingID
+			}
+		}
+		if sample != "" {
+			pattern = analyzer.NewIdentifierAnalyzer().LearnPattern(sample)
+			idType = pattern.Type
+			utils.Info.Printf("Detected ID type: %v\n", idType)
+		}
+	}
+
+	count := opts.Count
+	if count <= 0 {
+		count = 100
+	}
+	pg := generator.NewPayloadGenerator(idType)
+	if opts.Checksum == "" && idType == analyzer.TypeNumeric && learned == nil {
+		if opts.Checksum = detectChecksum(opts); opts.Checksum != "" {
+			utils.Info.Printf("Detected %s check digits in the known IDs\n", opts.Checksum)
+		}
+	}
+	pg.Numeric = numericGenerator(opts)
+	pg.Pattern = pattern
+	pg.Learned = learned
+	if opts.Template != "" {
+		pg.Template, _ = generator.ParseTemplate(opts.Template)
+	}
+	pg.Encodings = opts.Encodings
+	// A wildcard or null ID in a PUT, PATCH or DELETE may hit every object
+	if client.IsDestructiveMethod(opts.Method) {
+		pg.Special = nil
+	}
+	// So may a filter matching every object, and only when asked for
+	if !opts.Injection || client.IsDestructiveMethod(opts.Method) {
+		pg.Injection = nil
+	}
+	return pg.Generate(count)
+}
+
+// numericGenerator generates the numeric IDs of opts
+func numericGenerator(opts Options) *generator.NumericGenerator {
+	return &generator.NumericGenerator{Start: opts.IDStart, End: opts.IDEnd, Step: opts.IDStep, Sample: opts.Sample, Checksum: opts.Checksum}
+}
+
+// detectChecksum returns the check digit scheme followed by the known
+// numeric IDs: the ID in the URL, the own ID and the canaries
+func detectChecksum(opts Options) string {
+	var known []string
+	if id := extractExistingID(opts.URL); id != "" && !placeholderOutsideURL(opts) {
+		known = append(known, id)
+	}
+	if opts.OwnID != "" {
+		known = append(known, opts.OwnID)
+	}
+	known = append(known, opts.CanaryIDs...)
+	slices.Sort(known)
+	return analyzer.DetectChecksum(slices.Compact(known))
+}
+
+// EncodePayloads returns explicit payloads with their opts.Encodings
+func EncodePayloads(opts Options, payloads []string) []string {
+	pg := generator.NewPayloadGenerator(analyzer.TypeUnknown)
+	pg.Encodings = opts.Encodings
+	return pg.Encode(payloads)
+}
+
+// placeholderOutsideURL reports whether {ID} appears in headers, cookies or the body
+func placeholderOutsideURL(opts Options) bool {
+	for _, h := range opts.Headers {
+		if _, val, ok := strings.Cut(h, ":"); ok && strings.Contains(val, fuzzer.PayloadPlaceholder) {
+			return true
+		}
+	}
+	return strings.Contains(opts.Cookies, fuzzer.PayloadPlaceholder) || strings.Contains(opts.Body, fuzzer.PayloadPlaceholder)
+}
+
+func (s *Scanner) saveResults(batch []*fuzzer.FuzzResult) {
+	if err := s.Store.SaveResults(s.ScanID, batch); err != nil {
+		utils.Warning.Printf("Failed to store results: %v\n", err)
+	}
+}
+
+// invalidIDs returns the IDs of the invalid baselines, Detection.InvalidSamples
+// (default 3) of them counting down from invalidID
+func (s *Scanner) invalidIDs() []string {
+	n := min(s.Config.Detection.InvalidSamples, maxInvalidSamples)
+	if n <= 0 {
+		n = 3
+	}
+	base, _ := strconv.ParseInt(invalidID, 10, 64)
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = strconv.FormatInt(base-int64(i), 10)
+	}
+	return ids
+}
+
+// setAuth gives a session the attacker's auth headers. They are never
+// client defaults, so the victim's requests only carry its own cookies.
+func (s *Scanner) setAuth(r *request, name string) {
+	session := s.Client.GetSessionManager().GetSession(name)
+	if session == nil {
+		return
+	}
+	for k, v := range r.auth {
+		session.SetHeader(k, v)
+	}
+}
+
+// baselineRequest builds a request as the attacker with {ID} and named
+// placeholders in headers and body filled in for id
+func baselineRequest(ctx context.Context, c *client.SmartClient, r *request, body, id string) *resty.Request {
+	job := &fuzzer.FuzzJob{Payload: id, Vars: r.baselineVars(id), Headers: r.headers, Body: body, Session: "attacker"}
+	req := c.Request(ctx)
+	fuzzer.PrepareRequest(c, req, job)
+	return req
+}
+
+// baselineMethod is the scan method when a body is sent and GET otherwise,
+// so baselines never repeat state-changing requests without a body to fuzz.
+// Destructive methods are never repeated, their baselines use GET.
+func baselineMethod(method, body string) string {
+	if body != "" && !client.IsDestructiveMethod(method) {
+		return strings.ToUpper(method)
+	}
+	return "GET"
+}
+
+// baselineBody is the body sent with baselines, none when they use GET
+func baselineBody(method, body string) string {
+	if baselineMethod(method, body) == "GET" {
+		return ""
+	}
+	return body
+}
+
+// hasHeader reports whether a "Name: value" header list sets the named header
+func hasHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		parts := strings.SplitN(h, ":", 2)
+		if strings.EqualFold(strings.TrimSpace(parts[0]), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceID fills the {ID} placeholder of url, or appends id as a path
+// segment. Spaces are escaped, a query operator payload follows the
+// parameter name.
+func ReplaceID(url, id string) string {
+	id = strings.ReplaceAll(id, " ", "%20")
+	if fuzzer.IsOperatorPayload(id) && strings.Contains(url, "={ID}") {
+		return strings.Replace(url, "={ID}", id, 1)
+	}
+	if strings.Contains(url, "{ID}") {
+		return strings.Replace(url, "{ID}", id, 1)
+	}
+	// Fallback: append to URL
+	if strings.HasSuffix(url, "/") {
+		return url + id
+	}
+	return url + "/" + id
+}
+
+func extractExistingID(url string) string {
+	// Try to find an existing ID in the URL
+	if strings.Contains(url, "{ID}") {
+		return ""
+	}
+	return utils.ExtractIDFromURL(url)
+}
@@ -0,0 +1,169 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+)
+
+// maxCombinations caps the jobs of combined wordlists
+const maxCombinations = 1000000
+
+// Ways to combine named wordlists
+const (
+	CombineProduct = "product" // every combination of the lists
+	CombineZip     = "zip"     // the n-th entries of each list together
+)
+
+// placeholderNames returns the named wordlists in order
+func (o Options) placeholderNames() []string {
+	names := make([]string, 0, len(o.Wordlists))
+	for name := range o.Wordlists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// usesPlaceholder reports whether the URL, a header, the cookies or the
+// body contain placeholder
+func (o Options) usesPlaceholder(placeholder string) bool {
+	if strings.Contains(o.URL, placeholder) || strings.Contains(o.Cookies, placeholder) || strings.Contains(o.Body, placeholder) {
+		return true
+	}
+	for _, h := range o.Headers {
+		if _, val, ok := strings.Cut(h, ":"); ok && strings.Contains(val, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNamedPlaceholder reports whether s contains a named placeholder
+func (o Options) hasNamedPlaceholder(s string) bool {
+	for name := range o.Wordlists {
+		if strings.Contains(s, "{"+name+"}") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWordlists checks the named wordlists and the combine strategy
+func (o Options) validateWordlists() error {
+	if len(o.Wordlists) == 0 {
+		return nil
+	}
+	switch o.Combine {
+	case "", CombineProduct, CombineZip:
+	default:
+		return fmt.Errorf("unknown combine strategy %q, want %s or %s", o.Combine, CombineProduct, CombineZip)
+	}
+	if client.IsDestructiveMethod(o.Method) {
+		return errors.New("named wordlists can't be used with destructive methods, canary mode only covers {ID}")
+	}
+	for _, name := range o.placeholderNames() {
+		if name == "" || name == "ID" || strings.ContainsAny(name, "{}") {
+			return fmt.Errorf("invalid placeholder name %q", name)
+		}
+		if len(o.Wordlists[name]) == 0 {
+			return fmt.Errorf("the wordlist for {%s} is empty", name)
+		}
+		if !o.usesPlaceholder("{" + name + "}") {
+			return fmt.Errorf("{%s} appears in neither the URL, headers, cookies nor body", name)
+		}
+	}
+	return nil
+}
+
+// combine pairs the IDs with the named wordlists. Without {ID} in the
+// request only the named lists are combined.
+func (s *Scanner) combine(r *request) error {
+	opts := s.Options
+	if len(opts.Wordlists) == 0 {
+		return nil
+	}
+
+	names := opts.placeholderNames()
+	var lists [][]string
+	r.namedOnly = !opts.usesPlaceholder(fuzzer.PayloadPlaceholder)
+	if !r.namedOnly {
+		names = append([]string{"ID"}, names...)
+		lists = append(lists, r.payloads)
+	}
+	for _, name := range opts.placeholderNames() {
+		lists = append(lists, opts.Wordlists[name])
+	}
+
+	var combos [][]string
+	if opts.Combine == CombineZip {
+		n := len(lists[0])
+		for _, l := range lists[1:] {
+			n = min(n, len(l))
+		}
+		for i := 0; i < n; i++ {
+			combo := make([]string, len(lists))
+			for j, l := range lists {
+				combo[j] = l[i]
+			}
+			combos = append(combos, combo)
+		}
+	} else {
+		total := 1
+		for _, l := range lists {
+			if total *= len(l); total > maxCombinations {
+				return fmt.Errorf("the wordlists combine into more than %d requests, use --combine zip or shorter lists", maxCombinations)
+			}
+		}
+		combos = [][]string{{}}
+		for _, l := range lists {
+			next := make([][]string, 0, len(combos)*len(l))
+			for _, c := range combos {
+				for _, v := range l {
+					next = append(next, append(c[:len(c):len(c)], v))
+				}
+			}
+			combos = next
+		}
+	}
+
+	r.payloads = make([]string, len(combos))
+	r.vars = make([]map[string]string, len(combos))
+	for i, combo := range combos {
+		vars := make(map[string]string, len(names))
+		labels := make([]string, 0, len(names))
+		for j, name := range names {
+			if name == "ID" {
+				r.payloads[i] = combo[j]
+				continue
+			}
+			vars[name] = combo[j]
+			labels = append(labels, name+"="+combo[j])
+		}
+		if r.namedOnly {
+			r.payloads[i] = strings.Join(labels, ",")
+		}
+		r.vars[i] = vars
+	}
+	return nil
+}
+
+// baselineVars fills the named placeholders of a baseline request for id:
+// all of them with id when {ID} isn't used, else the first combination
+func (r *request) baselineVars(id string) map[string]string {
+	if len(r.vars) == 0 {
+		return nil
+	}
+	if !r.namedOnly {
+		return r.vars[0]
+	}
+	vars := make(map[string]string, len(r.vars[0]))
+	for name := range r.vars[0] {
+		vars[name] = id
+	}
+	return vars
+}
@@ -0,0 +1,235 @@
+package schedule
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/notify"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+)
+
+// Config configures the scheduler
+type Config struct {
+	// NewClient builds the HTTP client for a run
+	NewClient func(*utils.Config) (*client.SmartClient, error)
+	// Store keeps the results and findings of every run, and the findings
+	// of the last run that new ones are compared against
+	Store *store.Store
+}
+
+// Entry is a saved scan profile rerun on a schedule
+type Entry struct {
+	Spec    string
+	Profile string
+
+	schedule Schedule
+}
+
+// NewEntry parses the schedule of a profile, see Parse
+func NewEntry(spec, profile string) (*Entry, error) {
+	s, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Spec: spec, Profile: profile, schedule: s}, nil
+}
+
+// Next returns when the entry runs next after t, zero if it never does
+func (e *Entry) Next(t time.Time) time.Time {
+	return e.schedule.Next(t)
+}
+
+// ParseCrontab reads a crontab-like file: a schedule then a profile path
+// per line, e.g.
+//
+//	# m h dom mon dow  profile
+//	0 3 * * *          profiles/users.yaml
+//	@every 6h          profiles/orders.yaml
+//
+// Relative profile paths are relative to the file.
+func ParseCrontab(path string) ([]*Entry, error) {
+	path = utils.ExpandHome(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		specFields := 5
+		switch {
+		case fields[0] == "@every":
+			specFields = 2
+		case strings.HasPrefix(fields[0], "@"):
+			specFields = 1
+		}
+		if len(fields) <= specFields {
+			return nil, fmt.Errorf("%s: line %d: want a schedule and a profile path", path, n)
+		}
+		spec := strings.Join(fields[:specFields], " ")
+		profile := utils.ExpandHome(strings.Join(fields[specFields:], " "))
+		if !filepath.IsAbs(profile) {
+			profile = filepath.Join(filepath.Dir(path), profile)
+		}
+
+		e, err := NewEntry(spec, profile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, n, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no scheduled profiles", path)
+	}
+	return entries, nil
+}
+
+// Run is the outcome of one scheduled scan
+type Run struct {
+	Profile  string
+	Target   string
+	ScanID   int64
+	Findings int
+	// New are the findings whose fingerprint the previous run of the
+	// target did not have, one per fingerprint. Only these are notified.
+	New []*reporter.Finding
+}
+
+// Scheduler reruns saved scan profiles on their schedules
+type Scheduler struct {
+	cfg     Config
+	entries []*Entry
+
+	// OnRun, when set, is called after every run
+	OnRun func(*Run, error)
+}
+
+// New creates a scheduler for entries
+func New(cfg Config, entries []*Entry) (*Scheduler, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("scheduler needs a store")
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("nothing to schedule")
+	}
+	return &Scheduler{cfg: cfg, entries: entries}, nil
+}
+
+// Run runs every entry on its schedule until ctx is cancelled. Runs of an
+// entry never overlap: times missed while it was still running are skipped.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range s.entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, e *Entry) {
+	for {
+		next := e.Next(time.Now())
+		if next.IsZero() {
+			utils.Warning.Printf("%s: schedule %q has no future runs\n", e.Profile, e.Spec)
+			return
+		}
+		utils.Info.Printf("%s: next run at %s\n", e.Profile, next.Format(time.RFC1123))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run, err := s.RunEntry(ctx, e)
+		if ctx.Err() != nil {
+			return
+		}
+		if s.OnRun != nil {
+			s.OnRun(run, err)
+		}
+	}
+}
+
+// RunEntry scans an entry's profile now. The profile is loaded again for
+// every run, so edits and rotated secrets apply to the next one.
+func (s *Scheduler) RunEntry(ctx context.Context, e *Entry) (*Run, error) {
+	run := &Run{Profile: e.Profile}
+	opts, cfg, err := scanner.LoadProfile(e.Profile)
+	if err != nil {
+		return run, err
+	}
+	run.Target = opts.URL
+
+	c, err := s.cfg.NewClient(cfg)
+	if err != nil {
+		return run, err
+	}
+	notifier, err := notify.NewNotifier(cfg.Notify)
+	if err != nil {
+		return run, fmt.Errorf("invalid notify config: %w", err)
+	}
+	defer notifier.Close()
+
+	sc := scanner.New(c, cfg, opts)
+	sc.Store = s.cfg.Store
+	utils.Info.Printf("%s: scanning %s\n", e.Profile, opts.URL)
+	if err := sc.Run(ctx); err != nil {
+		return run, err
+	}
+	if sc.ScanID == 0 {
+		return run, errors.New("results database unavailable")
+	}
+	run.ScanID, run.Findings = sc.ScanID, len(sc.Reporter.Findings)
+
+	prevID, err := s.cfg.Store.PreviousScan(opts.URL, sc.ScanID)
+	if err != nil {
+		return run, err
+	}
+	seen := make(map[string]bool)
+	if prevID != 0 {
+		if seen, err = s.cfg.Store.Fingerprints(prevID); err != nil {
+			return run, err
+		}
+	}
+	for _, f := range sc.Reporter.Findings {
+		fp := f.Fingerprint
+		if fp == "" {
+			fp = reporter.Fingerprint(f)
+		}
+		if seen[fp] {
+			continue
+		}
+		seen[fp] = true
+		run.New = append(run.New, f)
+		notifier.Notify(f)
+	}
+	return run, nil
+}
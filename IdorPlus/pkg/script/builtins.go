@@ -0,0 +1,155 @@
+package script
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+type builtinFunc func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error)
+
+// functions are the helpers available to scripts besides the json module.
+// Strings are treated as raw bytes; digests and HMACs return lowercase hex.
+var functions = map[string]builtinFunc{
+	"md5":             digest(md5.New),
+	"sha1":            digest(sha1.New),
+	"sha256":          digest(sha256.New),
+	"hmac_sha1":       hmacDigest(sha1.New),
+	"hmac_sha256":     hmacDigest(sha256.New),
+	"base64_encode":   codec(func(s string) (string, error) { return base64.StdEncoding.EncodeToString([]byte(s)), nil }),
+	"base64_decode":   codec(decodeBase64),
+	"hex_encode":      codec(func(s string) (string, error) { return hex.EncodeToString([]byte(s)), nil }),
+	"hex_decode":      codec(func(s string) (string, error) { b, err := hex.DecodeString(s); return string(b), err }),
+	"now":             now,
+	"random_hex":      randomHex,
+	"aes_cbc_decrypt": aesCBCDecrypt,
+	"aes_gcm_decrypt": aesGCMDecrypt,
+}
+
+func digest(newHash func() hash.Hash) builtinFunc {
+	return func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var data string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &data); err != nil {
+			return nil, err
+		}
+		h := newHash()
+		h.Write([]byte(data))
+		return starlark.String(hex.EncodeToString(h.Sum(nil))), nil
+	}
+}
+
+func hmacDigest(newHash func() hash.Hash) builtinFunc {
+	return func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var key, data string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &key, &data); err != nil {
+			return nil, err
+		}
+		mac := hmac.New(newHash, []byte(key))
+		mac.Write([]byte(data))
+		return starlark.String(hex.EncodeToString(mac.Sum(nil))), nil
+	}
+}
+
+func codec(fn func(string) (string, error)) builtinFunc {
+	return func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var data string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &data); err != nil {
+			return nil, err
+		}
+		out, err := fn(data)
+		if err != nil {
+			return nil, err
+		}
+		return starlark.String(out), nil
+	}
+}
+
+// decodeBase64 accepts standard and URL-safe alphabets, padded or not
+func decodeBase64(s string) (string, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return string(b), nil
+		}
+	}
+	return "", errors.New("invalid base64")
+}
+
+// now returns the Unix time in seconds, or milliseconds with now(ms=True)
+func now(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var ms bool
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "ms?", &ms); err != nil {
+		return nil, err
+	}
+	if ms {
+		return starlark.MakeInt64(time.Now().UnixMilli()), nil
+	}
+	return starlark.MakeInt64(time.Now().Unix()), nil
+}
+
+func randomHex(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	n := 16
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0, &n); err != nil {
+		return nil, err
+	}
+	if n <= 0 || n > 1024 {
+		return nil, errors.New("random_hex: n must be between 1 and 1024")
+	}
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return starlark.String(hex.EncodeToString(buf)), nil
+}
+
+// aesCBCDecrypt decrypts data with PKCS#7 padding: aes_cbc_decrypt(key, iv, data)
+func aesCBCDecrypt(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key, iv, data string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 3, &key, &iv, &data); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("aes_cbc_decrypt: bad iv or ciphertext length")
+	}
+	out := []byte(data)
+	cipher.NewCBCDecrypter(block, []byte(iv)).CryptBlocks(out, out)
+
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, errors.New("aes_cbc_decrypt: bad padding")
+	}
+	return starlark.String(out[:len(out)-pad]), nil
+}
+
+// aesGCMDecrypt decrypts and authenticates data: aes_gcm_decrypt(key, nonce, data)
+func aesGCMDecrypt(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key, nonce, data string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 3, &key, &nonce, &data); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+	out, err := gcm.Open(nil, []byte(nonce), []byte(data), nil)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(out), nil
+}
@@ -0,0 +1,292 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// maxSteps bounds the work of a single hook call so a buggy script cannot
+// hang the scan
+const maxSteps = 10_000_000
+
+// Script is a Starlark hook script. It may define two functions:
+//
+//	def on_request(req):
+//	    # req is a dict with method, url, headers (dict) and body; changes
+//	    # are applied to the outgoing request before it is signed
+//	    req["headers"]["X-Signature"] = hmac_sha256(SECRET, req["body"])
+//
+//	def on_response(req, resp):
+//	    # resp is a dict with status, headers, body and vulnerable (the
+//	    # detector's verdict); req also carries the fuzzed id. Return True or
+//	    # False to override the verdict, None to keep it. A changed
+//	    # resp["body"] becomes the finding's evidence.
+//	    return "email" in resp["body"]
+//
+// Hooks run concurrently from every worker, so globals are frozen after
+// the script is loaded.
+type Script struct {
+	name       string
+	onRequest  *starlark.Function
+	onResponse *starlark.Function
+	warnOnce   sync.Once
+}
+
+// Load compiles and runs the top level of a script
+func Load(name, src string) (*Script, error) {
+	thread := newThread("load")
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{
+		Set:             true,
+		While:           true,
+		TopLevelControl: true,
+		GlobalReassign:  true,
+	}, thread, name, src, predeclared())
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	globals.Freeze()
+
+	s := &Script{name: name}
+	if s.onRequest, err = hook(globals, "on_request", 1); err != nil {
+		return nil, err
+	}
+	if s.onResponse, err = hook(globals, "on_response", 2); err != nil {
+		return nil, err
+	}
+	if s.onRequest == nil && s.onResponse == nil {
+		return nil, errors.New("script defines neither on_request nor on_response")
+	}
+	return s, nil
+}
+
+// hook looks up an optional hook function and checks its arity
+func hook(globals starlark.StringDict, name string, params int) (*starlark.Function, error) {
+	v, ok := globals[name]
+	if !ok {
+		return nil, nil
+	}
+	fn, ok := v.(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a function, got %s", name, v.Type())
+	}
+	if fn.NumParams() != params {
+		return nil, fmt.Errorf("%s must take %d parameter(s), takes %d", name, params, fn.NumParams())
+	}
+	return fn, nil
+}
+
+// HasRequestHook reports whether the script defines on_request
+func (s *Script) HasRequestHook() bool {
+	return s.onRequest != nil
+}
+
+// HasResponseHook reports whether the script defines on_response
+func (s *Script) HasResponseHook() bool {
+	return s.onResponse != nil
+}
+
+// Mutate runs on_request against an outgoing request. It implements
+// client.RequestMutator.
+func (s *Script) Mutate(req *http.Request) error {
+	if s.onRequest == nil {
+		return nil
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+	}
+
+	d := requestDict(req.Method, req.URL.String(), req.Header, string(body))
+	if _, err := starlark.Call(newThread("on_request"), s.onRequest, starlark.Tuple{d}, nil); err != nil {
+		return fmt.Errorf("on_request: %w", scriptError(err))
+	}
+
+	method, err := stringField(d, "method")
+	if err != nil {
+		return err
+	}
+	rawURL, err := stringField(d, "url")
+	if err != nil {
+		return err
+	}
+	newBody, err := stringField(d, "body")
+	if err != nil {
+		return err
+	}
+	headers, err := headerField(d)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("on_request set an invalid url: %w", err)
+	}
+	req.Method = method
+	if u.String() != req.URL.String() {
+		req.URL, req.Host = u, u.Host
+	}
+	req.Header = headers
+	setBody(req, []byte(newBody))
+	return nil
+}
+
+// Verdict runs on_response for a fuzz result and returns whether it is
+// vulnerable. Script errors keep the detector's verdict.
+func (s *Script) Verdict(result *fuzzer.FuzzResult) bool {
+	if s.onResponse == nil || result.Response == nil {
+		return result.IsVulnerable
+	}
+
+	job := result.Job
+	reqHeaders := http.Header{}
+	reqURL := job.URL
+	if raw := result.Response.Request.RawRequest; raw != nil {
+		reqHeaders, reqURL = raw.Header, raw.URL.String()
+	}
+	req := requestDict(job.Method, reqURL, reqHeaders, job.Interpolate(job.Body))
+	req.SetKey(starlark.String("id"), starlark.String(job.Payload))
+
+	body := string(result.Response.Body())
+	resp := starlark.NewDict(4)
+	resp.SetKey(starlark.String("status"), starlark.MakeInt(result.StatusCode))
+	resp.SetKey(starlark.String("headers"), headersDict(result.Response.Header()))
+	resp.SetKey(starlark.String("body"), starlark.String(body))
+	resp.SetKey(starlark.String("vulnerable"), starlark.Bool(result.IsVulnerable))
+
+	v, err := starlark.Call(newThread("on_response"), s.onResponse, starlark.Tuple{req, resp}, nil)
+	if err != nil {
+		s.warnOnce.Do(func() {
+			utils.Warning.Printf("Script %s: on_response failed, keeping detector verdicts: %v\n", s.name, scriptError(err))
+		})
+		utils.Debug.Printf("on_response %s: %v\n", job.URL, err)
+		return result.IsVulnerable
+	}
+
+	if newBody, err := stringField(resp, "body"); err == nil && newBody != body {
+		result.Evidence = newBody
+	}
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return result.IsVulnerable
+	case starlark.Bool:
+		return bool(v)
+	default:
+		s.warnOnce.Do(func() {
+			utils.Warning.Printf("Script %s: on_response returned %s, expected True, False or None\n", s.name, v.Type())
+		})
+		return result.IsVulnerable
+	}
+}
+
+func newThread(name string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			utils.Debug.Printf("[script] %s\n", msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+	return thread
+}
+
+// scriptError adds the Starlark backtrace to evaluation errors
+func scriptError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
+
+func requestDict(method, rawURL string, header http.Header, body string) *starlark.Dict {
+	d := starlark.NewDict(4)
+	d.SetKey(starlark.String("method"), starlark.String(method))
+	d.SetKey(starlark.String("url"), starlark.String(rawURL))
+	d.SetKey(starlark.String("headers"), headersDict(header))
+	d.SetKey(starlark.String("body"), starlark.String(body))
+	return d
+}
+
+// headersDict maps header names to their first value, in sorted order
+func headersDict(header http.Header) *starlark.Dict {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	d := starlark.NewDict(len(names))
+	for _, name := range names {
+		d.SetKey(starlark.String(name), starlark.String(header.Get(name)))
+	}
+	return d
+}
+
+func stringField(d *starlark.Dict, key string) (string, error) {
+	v, found, err := d.Get(starlark.String(key))
+	if err != nil || !found {
+		return "", fmt.Errorf("script removed %q", key)
+	}
+	s, ok := starlark.AsString(v)
+	if !ok {
+		return "", fmt.Errorf("script set %q to %s, expected a string", key, v.Type())
+	}
+	return s, nil
+}
+
+func headerField(d *starlark.Dict) (http.Header, error) {
+	v, found, _ := d.Get(starlark.String("headers"))
+	hd, ok := v.(*starlark.Dict)
+	if !found || !ok {
+		return nil, errors.New("script headers must be a dict")
+	}
+	header := http.Header{}
+	for _, item := range hd.Items() {
+		name, ok1 := starlark.AsString(item[0])
+		value, ok2 := starlark.AsString(item[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("script header %s must map a string to a string", item[0])
+		}
+		header.Set(name, value)
+	}
+	return header, nil
+}
+
+func setBody(req *http.Request, body []byte) {
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body, req.GetBody = nil, nil
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+func predeclared() starlark.StringDict {
+	builtins := starlark.StringDict{"json": json.Module}
+	for name, fn := range functions {
+		builtins[name] = starlark.NewBuiltin(name, fn)
+	}
+	return builtins
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"idorplus/pkg/fuzzer"
+)
+
+// metrics are the counters of finished scans; running scans are read from
+// their engines when scraped. Guarded by Server.mu.
+type metrics struct {
+	requests int64
+	failed   int64
+	blocked  int64
+	findings map[string]int64 // by severity
+	finished map[string]int64 // jobs by final status
+}
+
+func (m *metrics) init() {
+	m.findings = make(map[string]int64)
+	m.finished = make(map[string]int64)
+}
+
+// add counts the requests of a scan that stopped
+func (m *metrics) add(stats *fuzzer.Stats) {
+	m.requests += stats.GetTotal()
+	m.failed += stats.GetFailedCount()
+	m.blocked += stats.GetBlockedCount()
+}
+
+// handleMetrics serves the metrics in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.writeMetrics(w)
+}
+
+// writeMetrics writes the server's counters and gauges in the Prometheus
+// text format
+func (s *Server) writeMetrics(w io.Writer) {
+	s.mu.Lock()
+	total := s.metrics
+	total.findings = copyCounts(s.metrics.findings)
+	total.finished = copyCounts(s.metrics.finished)
+	var queued, running, inFlight, pending int64
+	for _, j := range s.jobs {
+		switch j.Status {
+		case StatusQueued:
+			queued++
+		case StatusRunning:
+			running++
+		}
+		if j.engine != nil {
+			total.add(j.engine.Stats)
+			inFlight += int64(j.engine.InFlight())
+			pending += int64(j.engine.Queue.Len())
+		}
+	}
+	s.mu.Unlock()
+
+	metric := func(name, kind, help string, value int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+	}
+	labeled := func(name, kind, help, label string, values map[string]int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, k, values[k])
+		}
+	}
+
+	metric("idorplus_requests_total", "counter", "Requests sent by scans, retries included.", total.requests)
+	metric("idorplus_request_errors_total", "counter", "Requests that failed without a response.", total.failed)
+	metric("idorplus_requests_blocked_total", "counter", "Responses recognized as WAF block pages.", total.blocked)
+	metric("idorplus_requests_in_flight", "gauge", "Requests being sent by running scans.", inFlight)
+	labeled("idorplus_findings_total", "counter", "Findings reported, by severity.", "severity", total.findings)
+	labeled("idorplus_jobs_finished_total", "counter", "Scan jobs finished, by status.", "status", total.finished)
+	metric("idorplus_jobs_queued", "gauge", "Scan jobs waiting for a worker.", queued)
+	metric("idorplus_jobs_running", "gauge", "Scan jobs running.", running)
+	metric("idorplus_workers", "gauge", "Workers running scan jobs, at most one job each.", int64(s.cfg.Workers))
+	metric("idorplus_fuzz_queue_depth", "gauge", "Requests queued by running scans.", pending)
+}
+
+func copyCounts(m map[string]int64) map[string]int64 {
+	c := make(map[string]int64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
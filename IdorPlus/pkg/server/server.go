@@ -0,0 +1,673 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// queueSize caps how many jobs can wait for a worker
+const queueSize = 1024
+
+// Config configures the API server
+type Config struct {
+	// Scan is the base configuration every job starts from
+	Scan *utils.Config
+	// NewClient builds the HTTP client for a job
+	NewClient func(*utils.Config) (*client.SmartClient, error)
+	// Store persists the job queue, results and findings
+	Store *store.Store
+	// Workers is how many scans run at once
+	Workers int
+	// Token, when set, is required as "Authorization: Bearer <token>"
+	Token string
+}
+
+// Job is a submitted scan
+type Job struct {
+	ID         string          `json:"id"`
+	Status     string          `json:"status"`
+	Options    scanner.Options `json:"options"`
+	Error      string          `json:"error,omitempty"`
+	ScanID     int64           `json:"scan_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  time.Time       `json:"started_at,omitzero"`
+	FinishedAt time.Time       `json:"finished_at,omitzero"`
+	Total      int             `json:"total"`
+	Completed  int64           `json:"completed"`
+	Findings   int             `json:"findings"`
+
+	cancel      context.CancelFunc
+	engine      *fuzzer.FuzzEngine // while running, for metrics
+	findings    []*reporter.Finding
+	subscribers map[chan event]struct{}
+}
+
+// event is a server-sent event for a job's stream
+type event struct {
+	name string
+	data any
+}
+
+// Server runs submitted scans on a pool of workers and serves the REST API
+type Server struct {
+	cfg   Config
+	mux   *http.ServeMux
+	queue chan *Job
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	ids     []string // submission order
+	metrics metrics
+}
+
+// New creates a server and re-queues jobs that were queued or running
+// when the previous server stopped
+func New(cfg Config) (*Server, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("server needs a store")
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+
+	s := &Server{
+		cfg:   cfg,
+		mux:   http.NewServeMux(),
+		queue: make(chan *Job, queueSize),
+		jobs:  make(map[string]*Job),
+	}
+	s.metrics.init()
+	s.routes()
+
+	stored, err := cfg.Store.Jobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+	for _, sj := range stored {
+		j := &Job{
+			ID:         sj.ID,
+			Status:     sj.Status,
+			Error:      sj.Error,
+			ScanID:     sj.ScanID,
+			CreatedAt:  sj.CreatedAt,
+			StartedAt:  sj.StartedAt,
+			FinishedAt: sj.FinishedAt,
+		}
+		if err := json.Unmarshal([]byte(sj.Options), &j.Options); err != nil {
+			j.Status, j.Error = StatusFailed, "invalid stored options: "+err.Error()
+		}
+		s.jobs[j.ID] = j
+		s.ids = append(s.ids, j.ID)
+
+		if j.Status == StatusQueued || j.Status == StatusRunning {
+			j.Status, j.ScanID, j.StartedAt = StatusQueued, 0, time.Time{}
+			s.persist(j)
+			select {
+			case s.queue <- j:
+			default:
+				j.Status, j.Error = StatusFailed, "queue full on restart"
+				s.persist(j)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Start launches the workers. They stop when ctx is cancelled, cancelling
+// running scans; those are re-queued on the next start.
+func (s *Server) Start(ctx context.Context) {
+	for i := 0; i < s.cfg.Workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.worker(ctx)
+		}()
+	}
+}
+
+// Wait blocks until the workers have stopped
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /api/scans", s.handleSubmit)
+	s.mux.HandleFunc("GET /api/scans", s.handleList)
+	s.mux.HandleFunc("GET /api/scans/{id}", s.handleStatus)
+	s.mux.HandleFunc("DELETE /api/scans/{id}", s.handleCancel)
+	s.mux.HandleFunc("GET /api/scans/{id}/findings", s.handleFindings)
+	s.mux.HandleFunc("GET /api/scans/{id}/events", s.handleEvents)
+	s.mux.HandleFunc("GET /api/scans/{id}/report", s.handleReport)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+}
+
+// Submit queues a scan and returns its job
+func (s *Server) Submit(opts scanner.Options) (*Job, error) {
+	if opts.URL == "" {
+		return nil, errors.New("url is required")
+	}
+
+	j := &Job{
+		ID:        newJobID(),
+		Status:    StatusQueued,
+		Options:   opts,
+		CreatedAt: time.Now(),
+	}
+	if err := s.persist(j); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	s.ids = append(s.ids, j.ID)
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- j:
+		return j, nil
+	default:
+		s.finish(j, StatusFailed, "queue full")
+		return nil, errors.New("queue full")
+	}
+}
+
+func (s *Server) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.queue:
+			if ctx.Err() != nil {
+				// Still queued in the store, picked up on the next start
+				return
+			}
+			s.run(ctx, j)
+		}
+	}
+}
+
+// run executes one job
+func (s *Server) run(ctx context.Context, j *Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	if j.Status != StatusQueued {
+		// Cancelled while waiting
+		s.mu.Unlock()
+		return
+	}
+	j.Status, j.StartedAt, j.cancel = StatusRunning, time.Now(), cancel
+	s.mu.Unlock()
+	s.persist(j)
+	s.publish(j, event{"status", s.snapshot(j)})
+
+	cfg, err := s.cfg.Scan.ForTarget(j.Options.URL)
+	if err != nil {
+		s.finish(j, StatusFailed, err.Error())
+		return
+	}
+	c, err := s.cfg.NewClient(cfg)
+	if err != nil {
+		s.finish(j, StatusFailed, err.Error())
+		return
+	}
+
+	sc := scanner.New(c, cfg, j.Options)
+	sc.Store = s.cfg.Store
+	sc.OnStart = func(total int) {
+		s.mu.Lock()
+		j.Total, j.engine = total, sc.Engine
+		s.mu.Unlock()
+	}
+	sc.OnResult = func(*fuzzer.FuzzResult) {
+		atomic.AddInt64(&j.Completed, 1)
+	}
+	sc.Reporter.OnFinding = func(f *reporter.Finding) {
+		s.mu.Lock()
+		j.findings = append(j.findings, f)
+		j.Findings = len(j.findings)
+		s.metrics.findings[f.Severity]++
+		s.mu.Unlock()
+		s.publish(j, event{"finding", f})
+	}
+
+	utils.Info.Printf("Job %s: scanning %s\n", j.ID, j.Options.URL)
+	err = sc.Run(jobCtx)
+
+	s.mu.Lock()
+	j.ScanID = sc.ScanID
+	if j.engine != nil {
+		s.metrics.add(j.engine.Stats)
+		j.engine = nil
+	}
+	s.mu.Unlock()
+
+	switch {
+	case ctx.Err() != nil:
+		// Server shutting down: leave the job running so it is re-queued
+		s.persist(j)
+	case errors.Is(err, context.Canceled):
+		s.finish(j, StatusCancelled, "")
+	case err != nil:
+		s.finish(j, StatusFailed, err.Error())
+	default:
+		s.finish(j, StatusDone, "")
+	}
+	snap := s.snapshot(j)
+	utils.Info.Printf("Job %s: %s with %d findings\n", snap.ID, snap.Status, snap.Findings)
+}
+
+// finish records the final status of a job and closes its event streams
+func (s *Server) finish(j *Job, status, errMsg string) {
+	s.mu.Lock()
+	j.Status, j.Error, j.FinishedAt, j.cancel = status, errMsg, time.Now(), nil
+	s.metrics.finished[status]++
+	s.mu.Unlock()
+	s.persist(j)
+
+	s.publish(j, event{"status", s.snapshot(j)})
+	s.mu.Lock()
+	for ch := range j.subscribers {
+		close(ch)
+	}
+	j.subscribers = nil
+	s.mu.Unlock()
+}
+
+// Cancel stops a queued or running job
+func (s *Server) Cancel(id string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		return errNotFound
+	}
+	status, cancel := j.Status, j.cancel
+	s.mu.Unlock()
+
+	switch status {
+	case StatusQueued:
+		s.finish(j, StatusCancelled, "")
+	case StatusRunning:
+		if cancel != nil {
+			cancel()
+		}
+	default:
+		return fmt.Errorf("job is already %s", status)
+	}
+	return nil
+}
+
+// Job returns a copy of a job, safe to encode
+func (s *Server) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+	return s.snapshot(j), true
+}
+
+// snapshot copies a job with credentials redacted
+func (s *Server) snapshot(j *Job) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Job{
+		ID:         j.ID,
+		Status:     j.Status,
+		Options:    redact(j.Options),
+		Error:      j.Error,
+		ScanID:     j.ScanID,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+		Total:      j.Total,
+		Completed:  atomic.LoadInt64(&j.Completed),
+		Findings:   j.Findings,
+	}
+}
+
+// jobFindings returns the findings of a job: live ones while it runs in
+// this process, otherwise the ones stored for its scan
+func (s *Server) jobFindings(j *Job) ([]*reporter.Finding, error) {
+	s.mu.Lock()
+	live, scanID := append([]*reporter.Finding(nil), j.findings...), j.ScanID
+	s.mu.Unlock()
+	if len(live) > 0 || scanID == 0 {
+		return live, nil
+	}
+	return s.cfg.Store.Findings(store.Filter{ScanID: scanID})
+}
+
+func (s *Server) publish(j *Job, ev event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range j.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Slow client, drop the event rather than stall the scan
+		}
+	}
+}
+
+// subscribe returns a channel of the job's future events along with the
+// findings recorded so far, or a nil channel if the job already finished
+func (s *Server) subscribe(j *Job) (chan event, []*reporter.Finding) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j.Status != StatusQueued && j.Status != StatusRunning {
+		return nil, nil
+	}
+	if j.subscribers == nil {
+		j.subscribers = make(map[chan event]struct{})
+	}
+	ch := make(chan event, 64)
+	j.subscribers[ch] = struct{}{}
+	return ch, append([]*reporter.Finding(nil), j.findings...)
+}
+
+func (s *Server) unsubscribe(j *Job, ch chan event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(j.subscribers, ch)
+}
+
+// persist writes the job to the store
+func (s *Server) persist(j *Job) error {
+	s.mu.Lock()
+	opts, err := json.Marshal(j.Options)
+	sj := &store.Job{
+		ID:         j.ID,
+		Status:     j.Status,
+		Options:    string(opts),
+		Error:      j.Error,
+		ScanID:     j.ScanID,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := s.cfg.Store.SaveJob(sj); err != nil {
+		utils.Warning.Printf("Failed to store job %s: %v\n", j.ID, err)
+		return err
+	}
+	return nil
+}
+
+var errNotFound = errors.New("job not found")
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*Job, bool) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errNotFound.Error())
+	}
+	return j, ok
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var opts scanner.Options
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(&opts); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	j, err := s.Submit(opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/api/scans/"+j.ID)
+	writeJSON(w, http.StatusAccepted, s.snapshot(j))
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	ids := append([]string(nil), s.ids...)
+	s.mu.Unlock()
+
+	jobs := make([]Job, 0, len(ids))
+	for _, id := range ids {
+		if j, ok := s.Job(id); ok {
+			jobs = append(jobs, j)
+		}
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if j, ok := s.lookup(w, r); ok {
+		writeJSON(w, http.StatusOK, s.snapshot(j))
+	}
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if err := s.Cancel(j.ID); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.snapshot(j))
+}
+
+func (s *Server) handleFindings(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	findings, err := s.jobFindings(j)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if findings == nil {
+		findings = []*reporter.Finding{}
+	}
+	writeJSON(w, http.StatusOK, findings)
+}
+
+// handleEvents streams findings and status changes as server-sent events.
+// Findings recorded before the client connected are sent first.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	ch, findings := s.subscribe(j)
+	if ch != nil {
+		defer s.unsubscribe(j, ch)
+	} else {
+		var err error
+		if findings, err = s.jobFindings(j); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(ev event) {
+		data, _ := json.Marshal(ev.data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, data)
+		flusher.Flush()
+	}
+
+	for _, f := range findings {
+		send(event{"finding", f})
+	}
+	send(event{"status", s.snapshot(j)})
+	if ch == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, open := <-ch:
+			if !open {
+				return
+			}
+			send(ev)
+		}
+	}
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	contentType, ok := reportTypes[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unknown format "+format)
+		return
+	}
+
+	findings, err := s.jobFindings(j)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	snap := s.snapshot(j)
+	rep := reporter.NewReporter(format)
+	rep.Findings = findings
+	rep.StartTime, rep.EndTime = snap.StartedAt, snap.FinishedAt
+	if rep.StartTime.IsZero() {
+		rep.StartTime = snap.CreatedAt
+	}
+
+	// Reports are written to files, so render into a temporary one
+	dir, err := os.MkdirTemp("", "idorplus-report-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report")
+	if format == "burp" {
+		err = rep.ExportBurp(path)
+	} else {
+		err = rep.GenerateReport(path)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeFile(w, r, path)
+}
+
+var reportTypes = map[string]string{
+	"json":     "application/json",
+	"markdown": "text/markdown; charset=utf-8",
+	"html":     "text/html; charset=utf-8",
+	"burp":     "application/xml",
+}
+
+// redact hides credentials in options returned by the API
+func redact(opts scanner.Options) scanner.Options {
+	mask := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "[redacted]"
+	}
+	opts.Cookies = mask(opts.Cookies)
+	opts.CookiesB = mask(opts.CookiesB)
+	opts.Bearer = mask(opts.Bearer)
+	headers := make([]string, 0, len(opts.Headers))
+	for _, h := range opts.Headers {
+		name, _, _ := strings.Cut(h, ":")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "authorization", "cookie", "x-api-key":
+			h = name + ": [redacted]"
+		}
+		headers = append(headers, h)
+	}
+	if opts.Headers != nil {
+		opts.Headers = headers
+	}
+	opts.Payloads = nil
+	return opts
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
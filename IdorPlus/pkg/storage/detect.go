@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Providers of object storage
+const (
+	ProviderS3    = "s3"
+	ProviderGCS   = "gcs"
+	ProviderAzure = "azure"
+)
+
+// Object is an object storage URL found in a response or script
+type Object struct {
+	Provider string `json:"provider"`
+	// Bucket is the bucket, or account/container for Azure
+	Bucket string `json:"bucket"`
+	Key    string `json:"key,omitempty"`
+	// Base is the URL of the bucket, objects are at Base/Key
+	Base   string `json:"base"`
+	URL    string `json:"url"`
+	Signed bool   `json:"signed,omitempty"` // a pre-signed or SAS URL
+}
+
+// urlPattern matches absolute URLs in text, HTML and JSON
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>()\\]+`)
+
+var (
+	s3VirtualHost = regexp.MustCompile(`^([a-z0-9.-]+)\.s3[.-](?:[a-z0-9-]+\.)?amazonaws\.com$`)
+	s3PathHost    = regexp.MustCompile(`^s3[.-](?:[a-z0-9-]+\.)?amazonaws\.com$|^s3\.amazonaws\.com$`)
+	gcsVirtual    = regexp.MustCompile(`^([a-z0-9._-]+)\.storage\.googleapis\.com$`)
+	azureHost     = regexp.MustCompile(`^([a-z0-9]+)\.blob\.core\.windows\.net$`)
+)
+
+// signatureParams are the query parameters signing a URL
+var signatureParams = []string{"X-Amz-Signature", "Signature", "X-Goog-Signature", "sig"}
+
+// FindObjects returns the object storage URLs in content, each once
+func FindObjects(content string) []Object {
+	// JSON escapes slashes and ampersands
+	content = strings.NewReplacer(`\/`, "/", `\u0026`, "&").Replace(content)
+	seen := make(map[string]bool)
+	var objects []Object
+	for _, raw := range urlPattern.FindAllString(content, -1) {
+		raw = strings.ReplaceAll(raw, "&amp;", "&")
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		if obj, ok := ParseObject(raw); ok {
+			objects = append(objects, obj)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].URL < objects[j].URL })
+	return objects
+}
+
+// ParseObject parses an S3, Google Cloud Storage or Azure Blob URL,
+// virtual-hosted or path-style
+func ParseObject(raw string) (Object, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Object{}, false
+	}
+	host := strings.ToLower(u.Hostname())
+	path := strings.TrimPrefix(u.Path, "/")
+	obj := Object{URL: raw, Signed: isSigned(u.Query())}
+	root := u.Scheme + "://" + u.Host
+
+	switch {
+	case s3VirtualHost.MatchString(host):
+		obj.Provider, obj.Bucket, obj.Base, obj.Key = ProviderS3, s3VirtualHost.FindStringSubmatch(host)[1], root, path
+	case s3PathHost.MatchString(host):
+		obj.Provider = ProviderS3
+		obj.Bucket, obj.Key, _ = strings.Cut(path, "/")
+		obj.Base = root + "/" + obj.Bucket
+	case host == "storage.googleapis.com":
+		obj.Provider = ProviderGCS
+		obj.Bucket, obj.Key, _ = strings.Cut(path, "/")
+		obj.Base = root + "/" + obj.Bucket
+	case gcsVirtual.MatchString(host):
+		obj.Provider, obj.Bucket, obj.Base, obj.Key = ProviderGCS, gcsVirtual.FindStringSubmatch(host)[1], root, path
+	case azureHost.MatchString(host):
+		container, blob, _ := strings.Cut(path, "/")
+		obj.Provider, obj.Key = ProviderAzure, blob
+		obj.Bucket = azureHost.FindStringSubmatch(host)[1] + "/" + container
+		obj.Base = root + "/" + container
+	default:
+		return Object{}, false
+	}
+	if obj.Bucket == "" {
+		return Object{}, false
+	}
+	return obj, true
+}
+
+func isSigned(query url.Values) bool {
+	for _, p := range signatureParams {
+		if query.Has(p) {
+			return true
+		}
+	}
+	return false
+}
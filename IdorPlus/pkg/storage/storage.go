@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+)
+
+// Probe is the answer to one storage request
+type Probe struct {
+	URL        string `json:"url"`
+	Key        string `json:"key,omitempty"`
+	Status     int    `json:"status"`
+	Accessible bool   `json:"accessible"`
+	Size       int    `json:"size,omitempty"`
+}
+
+// Result is what Test found out about an object's bucket
+type Result struct {
+	Object Object `json:"object"`
+	// Listing is the bucket listing request; Listable is set when it
+	// returned keys, the first of them in Keys
+	Listing  Probe    `json:"listing"`
+	Listable bool     `json:"listable"`
+	Keys     []string `json:"keys,omitempty"`
+	// Unsigned is the object requested without its signature, for signed
+	// URLs only
+	Unsigned *Probe `json:"unsigned,omitempty"`
+	// Enumerated are the objects at keys predicted from the object's key
+	Enumerated []Probe `json:"enumerated,omitempty"`
+}
+
+// Vulnerable reports whether the bucket gives away more than the object
+func (r *Result) Vulnerable() bool {
+	if r.Listable || (r.Unsigned != nil && r.Unsigned.Accessible) {
+		return true
+	}
+	for _, p := range r.Enumerated {
+		if p.Accessible {
+			return true
+		}
+	}
+	return false
+}
+
+// Tester checks object storage for bucket listing, signature-stripped
+// access and predictable keys. It only reads.
+type Tester struct {
+	client *client.SmartClient
+}
+
+// NewTester creates a storage tester
+func NewTester(c *client.SmartClient) *Tester {
+	return &Tester{client: c}
+}
+
+// maxListed caps the keys a listing asks for
+const maxListed = 5
+
+// Test lists the object's bucket, requests a signed object without its
+// signature and requests the keys predicted from its key with ids in
+// place of its ID, see PredictKeys. Predicted keys are requested unsigned,
+// a signature only covers the key it was made for.
+func (t *Tester) Test(ctx context.Context, obj Object, ids []string) (*Result, error) {
+	r := &Result{Object: obj}
+	listing, body, err := t.probe(ctx, listURL(obj), "")
+	if err != nil {
+		return r, err
+	}
+	r.Listing = listing
+	r.Keys = listedKeys(body)
+	r.Listable = r.Listing.Status == http.StatusOK && len(r.Keys) > 0
+	r.Listing.Accessible = r.Listable
+
+	if obj.Signed && obj.Key != "" {
+		p, _, err := t.probe(ctx, obj.Base+"/"+obj.Key, obj.Key)
+		if err != nil {
+			return r, err
+		}
+		r.Unsigned = &p
+	}
+	for _, key := range PredictKeys(obj.Key, ids) {
+		p, _, err := t.probe(ctx, obj.Base+"/"+key, key)
+		if err != nil {
+			return r, err
+		}
+		r.Enumerated = append(r.Enumerated, p)
+	}
+	return r, nil
+}
+
+// probe requests url and returns the probe and the body
+func (t *Tester) probe(ctx context.Context, url, key string) (Probe, string, error) {
+	resp, err := t.client.Request(ctx).Get(url)
+	if err != nil {
+		return Probe{}, "", err
+	}
+	p := Probe{URL: url, Key: key, Status: resp.StatusCode(), Accessible: resp.StatusCode() == http.StatusOK, Size: analyzer.BodySize(resp)}
+	return p, string(resp.Body()), nil
+}
+
+// listURL returns the URL listing the first keys of the object's bucket
+func listURL(obj Object) string {
+	switch obj.Provider {
+	case ProviderAzure:
+		return fmt.Sprintf("%s?restype=container&comp=list&maxresults=%d", obj.Base, maxListed)
+	case ProviderGCS:
+		return fmt.Sprintf("%s/?max-keys=%d", obj.Base, maxListed)
+	}
+	return fmt.Sprintf("%s/?list-type=2&max-keys=%d", obj.Base, maxListed)
+}
+
+// keyElement matches the keys of S3 and GCS listings (<Key>) and Azure
+// ones (<Name> of a <Blob>); S3's own <Name> is the bucket's
+var keyElement = regexp.MustCompile(`<Key>([^<]+)</Key>|<Blob>\s*<Name>([^<]+)</Name>`)
+
+// listedKeys returns the keys of a listing
+func listedKeys(body string) []string {
+	if !strings.Contains(body, "<ListBucketResult") && !strings.Contains(body, "<EnumerationResults") {
+		return nil
+	}
+	var keys []string
+	for _, m := range keyElement.FindAllStringSubmatch(body, -1) {
+		keys = append(keys, m[1]+m[2])
+	}
+	return keys
+}
+
+// idInKey matches the numeric IDs of a key
+var idInKey = regexp.MustCompile(`\d+`)
+
+// PredictKeys returns the keys of key with its last number replaced by
+// each of ids, zero-padded like it: invoices/00041.pdf gives
+// invoices/00042.pdf for 42. Without ids the numbers around it are used.
+// A key without a number predicts nothing.
+func PredictKeys(key string, ids []string) []string {
+	locs := idInKey.FindAllStringIndex(key, -1)
+	if len(locs) == 0 {
+		return nil
+	}
+	loc := locs[len(locs)-1]
+	own := key[loc[0]:loc[1]]
+	if len(ids) == 0 {
+		ids = Neighbors(own, 3)
+	}
+	var keys []string
+	for _, id := range ids {
+		if len(id) < len(own) && strings.HasPrefix(own, "0") {
+			id = strings.Repeat("0", len(own)-len(id)) + id
+		}
+		if id != own {
+			keys = append(keys, key[:loc[0]]+id+key[loc[1]:])
+		}
+	}
+	return keys
+}
+
+// Neighbors returns the n numbers below and above a numeric ID, closest
+// first
+func Neighbors(id string, n int) []string {
+	v, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for i := int64(1); i <= int64(n); i++ {
+		if v-i >= 0 {
+			ids = append(ids, strconv.FormatInt(v-i, 10))
+		}
+		ids = append(ids, strconv.FormatInt(v+i, 10))
+	}
+	return ids
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+const jobsSchema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id          TEXT PRIMARY KEY,
+	status      TEXT NOT NULL,
+	options     TEXT NOT NULL,
+	error       TEXT NOT NULL DEFAULT '',
+	scan_id     INTEGER NOT NULL DEFAULT 0,
+	created_at  TIMESTAMP NOT NULL,
+	started_at  TIMESTAMP,
+	finished_at TIMESTAMP
+);
+`
+
+// Job is a queued or finished server scan. Options holds the scan options
+// as JSON so the queue survives restarts.
+type Job struct {
+	ID         string
+	Status     string
+	Options    string
+	Error      string
+	ScanID     int64
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// SaveJob inserts or updates a job
+func (s *Store) SaveJob(j *Job) error {
+	_, err := s.db.Exec(`INSERT INTO jobs (id, status, options, error, scan_id, created_at, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET status = excluded.status, error = excluded.error, scan_id = excluded.scan_id,
+			started_at = excluded.started_at, finished_at = excluded.finished_at`,
+		j.ID, j.Status, j.Options, j.Error, j.ScanID, j.CreatedAt.UTC(), nullTime(j.StartedAt), nullTime(j.FinishedAt))
+	return err
+}
+
+// Jobs returns all jobs in submission order
+func (s *Store) Jobs() ([]*Job, error) {
+	rows, err := s.db.Query(`SELECT id, status, options, error, scan_id, created_at, started_at, finished_at
+		FROM jobs ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		var j Job
+		var started, finished sql.NullTime
+		if err := rows.Scan(&j.ID, &j.Status, &j.Options, &j.Error, &j.ScanID, &j.CreatedAt, &started, &finished); err != nil {
+			return nil, err
+		}
+		j.StartedAt, j.FinishedAt = started.Time, finished.Time
+		jobs = append(jobs, &j)
+	}
+	return jobs, rows.Err()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
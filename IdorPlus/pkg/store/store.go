@@ -0,0 +1,358 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	target      TEXT NOT NULL,
+	started_at  TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS results (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	scan_id     INTEGER NOT NULL REFERENCES scans(id),
+	url         TEXT NOT NULL,
+	method      TEXT NOT NULL,
+	payload     TEXT,
+	status_code INTEGER,
+	content_len INTEGER,
+	duration_ms INTEGER,
+	vulnerable  BOOLEAN NOT NULL DEFAULT 0,
+	blocked     BOOLEAN NOT NULL DEFAULT 0,
+	error       TEXT,
+	created_at  TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_results_scan ON results(scan_id);
+CREATE INDEX IF NOT EXISTS idx_results_status ON results(status_code);
+
+CREATE TABLE IF NOT EXISTS findings (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	scan_id     INTEGER NOT NULL REFERENCES scans(id),
+	finding_id  TEXT,
+	fingerprint TEXT,
+	severity    TEXT,
+	url         TEXT,
+	created_at  TIMESTAMP NOT NULL,
+	data        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_findings_scan ON findings(scan_id);
+`
+
+// Store persists scans, every fuzz result and findings in SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// Scan is a stored scan run
+type Scan struct {
+	ID         int64
+	Target     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Results    int
+	Findings   int
+}
+
+// Result is a stored fuzz result
+type Result struct {
+	ScanID     int64
+	URL        string
+	Method     string
+	Payload    string
+	StatusCode int
+	ContentLen int
+	Duration   time.Duration
+	Vulnerable bool
+	Blocked    bool
+	Error      string
+	CreatedAt  time.Time
+}
+
+// Filter narrows result and finding queries. Zero values match everything.
+type Filter struct {
+	ScanID     int64
+	Target     string // substring of the scan target
+	StatusCode int
+	Severity   string
+	Since      time.Time
+	Until      time.Time
+	Vulnerable bool // results only
+	Limit      int
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema + jobsSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// BeginScan records the start of a scan and returns its ID
+func (s *Store) BeginScan(target string, started time.Time) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO scans (target, started_at) VALUES (?, ?)`, target, started.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishScan records the end of a scan
+func (s *Store) FinishScan(scanID int64) error {
+	_, err := s.db.Exec(`UPDATE scans SET finished_at = ? WHERE id = ?`, time.Now().UTC(), scanID)
+	return err
+}
+
+// SaveResults inserts a batch of fuzz results in one transaction
+func (s *Store) SaveResults(scanID int64, results []*fuzzer.FuzzResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO results
+		(scan_id, url, method, payload, status_code, content_len, duration_ms, vulnerable, blocked, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, r := range results {
+		errMsg := ""
+		if r.Error != nil {
+			errMsg = r.Error.Error()
+		}
+		if _, err := stmt.Exec(scanID, r.Job.URL, r.Job.Method, r.Job.Payload, r.StatusCode, r.ContentLen,
+			r.Duration.Milliseconds(), r.IsVulnerable, r.Blocked, errMsg, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveFindings stores the findings of a scan
+func (s *Store) SaveFindings(scanID int64, findings []*reporter.Finding) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, f := range findings {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		fingerprint := f.Fingerprint
+		if fingerprint == "" {
+			fingerprint = reporter.Fingerprint(f)
+		}
+		if _, err := tx.Exec(`INSERT INTO findings
+			(scan_id, finding_id, fingerprint, severity, url, created_at, data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			scanID, f.ID, fingerprint, f.Severity, f.URL, f.Timestamp.UTC(), string(data)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Scans lists stored scans, newest first
+func (s *Store) Scans(filter Filter) ([]Scan, error) {
+	where, args := filter.scanClauses("s")
+	query := `SELECT s.id, s.target, s.started_at, s.finished_at,
+		(SELECT COUNT(*) FROM results r WHERE r.scan_id = s.id),
+		(SELECT COUNT(*) FROM findings f WHERE f.scan_id = s.id)
+		FROM scans s` + where + ` ORDER BY s.id DESC` + filter.limit()
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scans []Scan
+	for rows.Next() {
+		var sc Scan
+		var finished sql.NullTime
+		if err := rows.Scan(&sc.ID, &sc.Target, &sc.StartedAt, &finished, &sc.Results, &sc.Findings); err != nil {
+			return nil, err
+		}
+		sc.FinishedAt = finished.Time
+		scans = append(scans, sc)
+	}
+	return scans, rows.Err()
+}
+
+// GetScan returns a single scan
+func (s *Store) GetScan(scanID int64) (*Scan, error) {
+	scans, err := s.Scans(Filter{ScanID: scanID})
+	if err != nil {
+		return nil, err
+	}
+	if len(scans) == 0 {
+		return nil, fmt.Errorf("scan %d not found", scanID)
+	}
+	return &scans[0], nil
+}
+
+// Results queries stored fuzz results, newest first
+func (s *Store) Results(filter Filter) ([]Result, error) {
+	where, args := filter.scanClauses("s")
+	if filter.StatusCode != 0 {
+		where, args = and(where, "r.status_code = ?"), append(args, filter.StatusCode)
+	}
+	if filter.Vulnerable {
+		where = and(where, "r.vulnerable = 1")
+	}
+	where, args = filter.timeClauses(where, args, "r.created_at")
+
+	query := `SELECT r.scan_id, r.url, r.method, r.payload, r.status_code, r.content_len,
+		r.duration_ms, r.vulnerable, r.blocked, r.error, r.created_at
+		FROM results r JOIN scans s ON s.id = r.scan_id` + where + ` ORDER BY r.id DESC` + filter.limit()
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var r Result
+		var ms int64
+		if err := rows.Scan(&r.ScanID, &r.URL, &r.Method, &r.Payload, &r.StatusCode, &r.ContentLen,
+			&ms, &r.Vulnerable, &r.Blocked, &r.Error, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Duration = time.Duration(ms) * time.Millisecond
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Findings queries stored findings in the order they were found
+func (s *Store) Findings(filter Filter) ([]*reporter.Finding, error) {
+	where, args := filter.scanClauses("s")
+	if filter.Severity != "" {
+		where, args = and(where, "f.severity = ?"), append(args, strings.ToUpper(filter.Severity))
+	}
+	where, args = filter.timeClauses(where, args, "f.created_at")
+
+	query := `SELECT f.data FROM findings f JOIN scans s ON s.id = f.scan_id` +
+		where + ` ORDER BY f.id` + filter.limit()
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []*reporter.Finding
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var f reporter.Finding
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			return nil, err
+		}
+		findings = append(findings, &f)
+	}
+	return findings, rows.Err()
+}
+
+// PreviousScan returns the ID of the last finished scan of exactly target
+// before scanID, 0 if there is none
+func (s *Store) PreviousScan(target string, scanID int64) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM scans WHERE target = ? AND id < ? AND finished_at IS NOT NULL
+		ORDER BY id DESC LIMIT 1`, target, scanID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// Fingerprints returns the fingerprints of a scan's findings
+func (s *Store) Fingerprints(scanID int64) (map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT fingerprint FROM findings WHERE scan_id = ?`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fingerprints := make(map[string]bool)
+	for rows.Next() {
+		var fp string
+		if err := rows.Scan(&fp); err != nil {
+			return nil, err
+		}
+		fingerprints[fp] = true
+	}
+	return fingerprints, rows.Err()
+}
+
+// scanClauses builds the WHERE clause for the scan ID and target filters
+func (f Filter) scanClauses(alias string) (string, []any) {
+	var where string
+	var args []any
+	if f.ScanID != 0 {
+		where, args = and(where, alias+".id = ?"), append(args, f.ScanID)
+	}
+	if f.Target != "" {
+		where, args = and(where, alias+".target LIKE ?"), append(args, "%"+f.Target+"%")
+	}
+	return where, args
+}
+
+func (f Filter) timeClauses(where string, args []any, column string) (string, []any) {
+	if !f.Since.IsZero() {
+		where, args = and(where, column+" >= ?"), append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where, args = and(where, column+" < ?"), append(args, f.Until.UTC())
+	}
+	return where, args
+}
+
+func (f Filter) limit() string {
+	if f.Limit > 0 {
+		return fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+	return ""
+}
+
+func and(where, clause string) string {
+	if where == "" {
+		return " WHERE " + clause
+	}
+	return where + " AND " + clause
+}
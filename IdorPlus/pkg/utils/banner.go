@@ -34,26 +34,25 @@ func PrintSection(title string) {
 
 // PrintSuccess prints a success message
 func PrintSuccess(msg string) {
-	pterm.Success.Println(msg)
+	Success.Println(msg)
 }
 
 // PrintError prints an error message
 func PrintError(msg string) {
-	pterm.Error.Println(msg)
+	Error.Println(msg)
 }
 
 // PrintWarning prints a warning message
 func PrintWarning(msg string) {
-	pterm.Warning.Println(msg)
+	Warning.Println(msg)
 }
 
 // PrintInfo prints an info message
 func PrintInfo(msg string) {
-	pterm.Info.Println(msg)
+	Info.Println(msg)
 }
 
 // PrintVulnerable prints a vulnerability found message
 func PrintVulnerable(url string, status int) {
-	pterm.NewStyle(pterm.FgRed, pterm.Bold).Printf("[VULN] ")
-	pterm.Printf("%s (Status: %d)\n", url, status)
+	Logger().Warn(vulnerableMsg, "url", url, "status", status)
 }
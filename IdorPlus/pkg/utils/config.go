@@ -1,8 +1,15 @@
 package utils
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
@@ -12,6 +19,21 @@ type Config struct {
 	WAFBypass WAFBypassConfig `yaml:"waf_bypass"`
 	Detection DetectionConfig `yaml:"detection"`
 	Output    OutputConfig    `yaml:"output"`
+	Signing   SigningConfig   `yaml:"signing"`
+	CSRF      CSRFConfig      `yaml:"csrf"`
+	Notify    NotifyConfig    `yaml:"notify"`
+	Export    ExportConfig    `yaml:"export"`
+	Scope     ScopeConfig     `yaml:"scope"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+
+	// Profiles are named sets of overrides selected with --profile, e.g.
+	// a slow "stealth" profile for production and a fast one for labs
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+	// Targets apply overrides to scans of matching hosts, in order
+	Targets []TargetConfig `yaml:"targets"`
+
+	// overrides are the environment and --set settings, see LoadOverrides
+	overrides *viper.Viper
 }
 
 type ScannerConfig struct {
@@ -19,38 +41,347 @@ type ScannerConfig struct {
 	Timeout    string `yaml:"timeout"`
 	MaxRetries int    `yaml:"max_retries"`
 	Delay      string `yaml:"delay"`
+
+	// MaxInFlight caps concurrent requests overall, MaxPerHost per target host (0 = no limit)
+	MaxInFlight int `yaml:"max_in_flight"`
+	MaxPerHost  int `yaml:"max_per_host"`
+
+	ProxyCheckURL      string `yaml:"proxy_check_url"`
+	ProxyCheckInterval string `yaml:"proxy_check_interval"`
+	ProxyMaxFailures   int    `yaml:"proxy_max_failures"`
+
+	UpstreamProxy string `yaml:"upstream_proxy"`
+	UpstreamCA    string `yaml:"upstream_ca"`
+
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+
+	// Certificates are verified unless Insecure. CACert is trusted besides
+	// the system roots; PinSHA256 only accepts servers with one of these
+	// public keys, see client.ParsePin.
+	Insecure  bool     `yaml:"insecure"`
+	CACert    string   `yaml:"ca_cert"`
+	PinSHA256 []string `yaml:"pin_sha256"`
+
+	Cache    bool   `yaml:"cache"`
+	CacheDir string `yaml:"cache_dir"`
+	CacheTTL string `yaml:"cache_ttl"`
+
+	// JobTimeout bounds each fuzz job, retries included. Endpoints whose
+	// p95 latency exceeds SlowP95 run one job at a time, then are skipped
+	// if they stay slow. Empty disables either.
+	JobTimeout string `yaml:"job_timeout"`
+	SlowP95    string `yaml:"slow_p95"`
+
+	// Connection pool: MaxConnsPerHost caps open connections per host (0 =
+	// no limit), MaxIdleConnsPerHost defaults to Threads
+	MaxConnsPerHost     int    `yaml:"max_conns_per_host"`
+	MaxIdleConnsPerHost int    `yaml:"max_idle_conns_per_host"`
+	DisableKeepAlives   bool   `yaml:"disable_keep_alives"`
+	DialTimeout         string `yaml:"dial_timeout"`
+
+	// Resolve pins host names to addresses as host:addr or host:port:addr,
+	// like curl --resolve. HostHeader replaces the Host header of every
+	// request.
+	Resolve    []string `yaml:"resolve"`
+	HostHeader string   `yaml:"host_header"`
+
+	// Bind is the local IP address or interface connections originate
+	// from. PreferIPv6 tries a target's IPv6 addresses first.
+	Bind       string `yaml:"bind"`
+	PreferIPv6 bool   `yaml:"prefer_ipv6"`
+
+	// QueueSize bounds the jobs generated ahead of the workers (0 = no
+	// bound). ResultOverflow is what happens to results while the scan is
+	// slower to process them than the workers are to produce them: block,
+	// drop or spill to disk, see fuzzer.FuzzEngine.Overflow.
+	QueueSize      int    `yaml:"queue_size"`
+	ResultOverflow string `yaml:"result_overflow"`
+
+	// RetryOn are the failures retried, up to MaxRetries times: refused,
+	// timeout, network, 5xx (502-504) and 429, see RetryClasses. POST and
+	// PATCH are only retried when refused or rate limited. Retries
+	// back off exponentially with jitter from RetryBackoff up to
+	// RetryMaxBackoff, which also caps a Retry-After. After
+	// BreakerThreshold consecutive failures of a host, its requests fail
+	// fast for BreakerCooldown (0 disables the breaker).
+	RetryOn          []string `yaml:"retry_on"`
+	RetryBackoff     string   `yaml:"retry_backoff"`
+	RetryMaxBackoff  string   `yaml:"retry_max_backoff"`
+	BreakerThreshold int      `yaml:"breaker_threshold"`
+	BreakerCooldown  string   `yaml:"breaker_cooldown"`
+
+	// MaxBodyMB caps how much of a response body is read, in MiB (0 = no
+	// cap). The size of a truncated body is still compared, from its
+	// Content-Length.
+	MaxBodyMB int `yaml:"max_body_mb"`
 }
 
 type WAFBypassConfig struct {
-	Enabled bool              `yaml:"enabled"`
-	Mode    string            `yaml:"mode"`
-	Headers map[string]string `yaml:"headers"`
+	Enabled       bool              `yaml:"enabled"`
+	Mode          string            `yaml:"mode"`
+	Headers       map[string]string `yaml:"headers"`
+	BlockCooldown string            `yaml:"block_cooldown"`
 }
 
 type DetectionConfig struct {
 	Threshold float64 `yaml:"threshold"`
 	CheckPII  bool    `yaml:"check_pii"`
 	BlindIDOR bool    `yaml:"blind_idor"`
+	// InvalidSamples is how many invalid baselines are requested (1-5, 0
+	// for the default of 3)
+	InvalidSamples int `yaml:"invalid_samples"`
+	// MinConfidence is the confidence (0-100) from which a response is
+	// reported, the sum of the Weights of the heuristics it matches
+	MinConfidence int               `yaml:"min_confidence"`
+	Weights       ConfidenceWeights `yaml:"weights"`
+	// Confirmations is how many times a finding is re-tested before it is
+	// reported, 0 reports it at once
+	Confirmations int `yaml:"confirmations"`
+}
+
+// ConfidenceWeights are the confidence points of each detection heuristic
+type ConfidenceWeights struct {
+	Status     int `yaml:"status"`     // 2xx where a missing ID is denied
+	Similarity int `yaml:"similarity"` // content differs from the attacker's own resource
+	PII        int `yaml:"pii"`        // PII in the response
+	Victim     int `yaml:"victim"`     // the victim session (-C) gets the same response
+	Redirect   int `yaml:"redirect"`   // redirected somewhere the baselines aren't, not to a login
+	MassData   int `yaml:"mass_data"`  // many more records than the attacker's own resource
 }
 
 type OutputConfig struct {
 	Format        string `yaml:"format"`
 	Verbose       bool   `yaml:"verbose"`
 	SaveResponses bool   `yaml:"save_responses"`
+	Database      string `yaml:"database"`
+	Redact        bool   `yaml:"redact"`   // mask PII in reports, keeping its type and count
+	Template      string `yaml:"template"` // Go template for markdown and HTML reports
+
+	Elasticsearch ElasticConfig `yaml:"elasticsearch"`
+}
+
+// ElasticConfig ships every result to an Elasticsearch or OpenSearch index.
+// APIKey or Username and Password authenticate, if set.
+type ElasticConfig struct {
+	URL      string `yaml:"url"` // e.g. https://localhost:9200, empty disables
+	Index    string `yaml:"index"`
+	APIKey   string `yaml:"api_key"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type SigningConfig struct {
+	AWS AWSSigningConfig `yaml:"aws"`
+}
+
+// AWSSigningConfig enables SigV4 signing. Credentials fall back to the
+// standard AWS_* environment variables when left empty.
+type AWSSigningConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Region       string `yaml:"region"`
+	Service      string `yaml:"service"`
+	AccessKey    string `yaml:"access_key"`
+	SecretKey    string `yaml:"secret_key"`
+	SessionToken string `yaml:"session_token"`
+}
+
+// CSRFConfig supplies POST, PUT, PATCH and DELETE requests with an
+// anti-CSRF token fetched from URL, and fetches it again once rejected
+type CSRFConfig struct {
+	URL     string `yaml:"url"`     // page or endpoint with the token, empty disables
+	Extract string `yaml:"extract"` // auto, regex:PATTERN, json:PATH, meta:NAME, input:NAME or cookie:NAME
+	Header  string `yaml:"header"`  // request header carrying the token
+	Param   string `yaml:"param"`   // form or JSON body field instead of the header
+}
+
+// NotifyConfig sends real-time alerts for confirmed findings
+type NotifyConfig struct {
+	MinSeverity string          `yaml:"min_severity"`
+	Webhooks    []WebhookConfig `yaml:"webhooks"`
+}
+
+// WebhookConfig is a Slack, Discord or Teams incoming webhook. MinSeverity
+// overrides the global minimum for this webhook.
+type WebhookConfig struct {
+	Type        string `yaml:"type"`
+	URL         string `yaml:"url"`
+	MinSeverity string `yaml:"min_severity"`
+}
+
+// ExportConfig configures the issue trackers the export command sends
+// findings to
+type ExportConfig struct {
+	DefectDojo DefectDojoConfig `yaml:"defectdojo"`
+	Jira       JiraConfig       `yaml:"jira"`
 }
 
-// LoadConfig loads configuration from a YAML file
+// DefectDojoConfig imports reports into DefectDojo, into the engagement
+// with ID Engagement or else the one named EngagementName of Product,
+// created if missing
+type DefectDojoConfig struct {
+	URL            string `yaml:"url"`
+	APIKey         string `yaml:"api_key"`
+	Engagement     int    `yaml:"engagement"`
+	Product        string `yaml:"product"`
+	EngagementName string `yaml:"engagement_name"`
+	MinSeverity    string `yaml:"min_severity"`
+}
+
+// JiraConfig creates a Jira issue per finding. With User, Token is an API
+// token for Jira Cloud; without, a personal access token for Jira Server.
+type JiraConfig struct {
+	URL         string   `yaml:"url"`
+	User        string   `yaml:"user"`
+	Token       string   `yaml:"token"`
+	Project     string   `yaml:"project"`
+	IssueType   string   `yaml:"issue_type"`
+	Labels      []string `yaml:"labels"`
+	MinSeverity string   `yaml:"min_severity"`
+}
+
+// ScopeConfig limits which URLs may be requested. Rules are regular
+// expressions matched against the full URL; with no include rules every
+// URL not excluded is in scope.
+type ScopeConfig struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+// TracingConfig exports OpenTelemetry traces of scans over OTLP/HTTP, see
+// the tracing package
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the collector's URL, e.g. http://localhost:4318; empty
+	// uses OTEL_EXPORTER_OTLP_ENDPOINT or https://localhost:4318
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"`
+	// SampleRatio is the share of jobs traced, 0 means all
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// LoadConfig loads configuration from a YAML file. A leading ~ is the
+// home directory. Unknown settings are errors, as are malformed values;
+// both name the line, e.g. "config.yaml: line 4: field thread not found".
 func LoadConfig(path string) (*Config, error) {
+	path = ExpandHome(path)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return nil, err
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
 	}
 
 	return &config, nil
 }
+
+// ExpandHome replaces a leading ~ of a path with the home directory, for
+// paths the shell did not expand, e.g. --config=~/idorplus.yaml
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// ConfigPaths lists where a config file is looked for when none is given,
+// in order: ./configs/default.yaml, then ~/.config/idorplus/config.yaml
+func ConfigPaths() []string {
+	paths := []string{filepath.Join("configs", "default.yaml")}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		paths = append(paths, filepath.Join(dir, "idorplus", "config.yaml"))
+	}
+	return paths
+}
+
+// FindConfig returns the first existing file of ConfigPaths, or "" if there
+// is none
+func FindConfig() string {
+	for _, path := range ConfigPaths() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// DefaultLogoutRule keeps scans from ending their own session
+const DefaultLogoutRule = `(?i)/(logout|log-out|signout|sign-out|logoff)([/?#.]|$)`
+
+// DefaultConfig returns the built-in configuration used when no config file is found
+func DefaultConfig() *Config {
+	return &Config{
+		Scanner: ScannerConfig{
+			Threads:    10,
+			Timeout:    "10s",
+			MaxRetries: 3,
+			Delay:      "100ms",
+
+			ProxyCheckInterval: "60s",
+			ProxyMaxFailures:   5,
+
+			CacheTTL: "1h",
+
+			DialTimeout: "30s",
+
+			QueueSize:      10000,
+			ResultOverflow: "block",
+
+			RetryOn:          []string{"refused", "timeout", "network", "5xx", "429"},
+			RetryBackoff:     "500ms",
+			RetryMaxBackoff:  "30s",
+			BreakerThreshold: 10,
+			BreakerCooldown:  "30s",
+
+			MaxBodyMB: 5,
+		},
+		WAFBypass: WAFBypassConfig{
+			Enabled: true,
+			Mode:    "normal",
+			Headers: map[string]string{
+				"X-Forwarded-For": "127.0.0.1",
+				"X-Real-IP":       "127.0.0.1",
+			},
+			BlockCooldown: "30s",
+		},
+		Detection: DetectionConfig{
+			Threshold: 0.8,
+			CheckPII:  true,
+			BlindIDOR: false,
+
+			InvalidSamples: 3,
+			MinConfidence:  30,
+			Weights: ConfidenceWeights{
+				Status:     40,
+				Similarity: 30,
+				PII:        30,
+				Victim:     40,
+				Redirect:   40,
+				MassData:   40,
+			},
+			Confirmations: 2,
+		},
+		Output: OutputConfig{
+			Format:  "json",
+			Verbose: true,
+		},
+		Scope: ScopeConfig{
+			Exclude: []string{DefaultLogoutRule},
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"math/big"
 	"os"
+	"regexp"
 	"strings"
 )
 
@@ -114,3 +115,18 @@ func ParseCookieString(cookieStr string) map[string]string {
 	}
 	return cookies
 }
+
+// pathParam matches {id} and /:id path parameters
+var pathParam = regexp.MustCompile(`\{([^}]+)\}|/:(\w+)`)
+
+// ReplacePathParams replaces each {name} and :name parameter of path with
+// fn(name). The slash before a :name parameter is kept.
+func ReplacePathParams(path string, fn func(name string) string) string {
+	return pathParam.ReplaceAllStringFunc(path, func(m string) string {
+		sub := pathParam.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return fn(sub[1])
+		}
+		return "/" + fn(sub[2])
+	})
+}
@@ -1,23 +1,301 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"atomicgo.dev/cursor"
 	"github.com/pterm/pterm"
 )
 
+// Printer logs messages at one level. It keeps the Printf/Println style of
+// the pterm printers it replaced; records go through the slog logger
+// returned by Logger.
+type Printer struct {
+	level   slog.Level
+	success bool
+}
+
 var (
 	// Logger instances
-	Info    = pterm.Info
-	Success = pterm.Success
-	Warning = pterm.Warning
-	Error   = pterm.Error
-	Debug   = pterm.Debug
+	Info    = &Printer{level: slog.LevelInfo}
+	Success = &Printer{level: slog.LevelInfo, success: true}
+	Warning = &Printer{level: slog.LevelWarn}
+	Error   = &Printer{level: slog.LevelError}
+	Debug   = &Printer{level: slog.LevelDebug}
+)
+
+// Printf logs a formatted message. A trailing newline is dropped.
+func (p *Printer) Printf(format string, a ...any) {
+	p.log(fmt.Sprintf(format, a...))
+}
+
+// Println logs its operands separated by spaces
+func (p *Printer) Println(a ...any) {
+	p.log(fmt.Sprintln(a...))
+}
+
+func (p *Printer) log(msg string) {
+	l := Logger()
+	ctx := context.Background()
+	if !l.Enabled(ctx, p.level) {
+		return
+	}
+	msg = strings.TrimRight(msg, "\n")
+	if p.success {
+		l.LogAttrs(ctx, p.level, msg, slog.String(statusKey, "success"))
+		return
+	}
+	l.LogAttrs(ctx, p.level, msg)
+}
+
+const (
+	// statusKey marks Success records, which are logged at info level
+	statusKey = "status"
+	// vulnerableMsg is the message of PrintVulnerable records
+	vulnerableMsg = "Vulnerable"
+)
+
+// LogOptions configure InitLogger
+type LogOptions struct {
+	Debug bool
+	// Format is text (default), colored console output, or json, one JSON
+	// object per record on stderr
+	Format string
+	// File also appends records to this file, in Format (text is written
+	// as key=value pairs)
+	File string
+	// NoColor prints plain text: no colors, banner, spinners or progress bars
+	NoColor bool
+	// Silent logs nothing but errors, to stderr, leaving stdout to results
+	Silent bool
+}
+
+var (
+	logger    atomic.Pointer[slog.Logger]
+	decorated atomic.Bool
+	logMu     sync.Mutex
+	logFile   *os.File
 )
 
-// InitLogger initializes the logger settings
-func InitLogger(debugMode bool) {
-	if debugMode {
+func init() {
+	logger.Store(slog.New(&consoleHandler{level: slog.LevelInfo}))
+	decorated.Store(true)
+}
+
+// Logger returns the logger behind Info, Warning etc., for records with
+// attributes:
+//
+//	utils.Logger().Info("Shard finished", "shard", id, "findings", n)
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+// Decorated reports whether the console shows banners, spinners and
+// progress bars, i.e. logs are colored text and neither NoColor nor Silent
+// is set
+func Decorated() bool {
+	return decorated.Load()
+}
+
+// InitLogger initializes the logger settings. The json format disables
+// pterm output (banners, tables, progress bars) so that stderr carries
+// nothing but log records.
+func InitLogger(opts LogOptions) error {
+	level := slog.LevelInfo
+	if opts.Debug {
+		level = slog.LevelDebug
 		pterm.EnableDebugMessages()
 	} else {
 		pterm.DisableDebugMessages()
 	}
+	if opts.Silent {
+		level = slog.LevelError
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level, ReplaceAttr: trimMessage}
+
+	if opts.NoColor || opts.Silent {
+		pterm.DisableStyling()
+	} else {
+		pterm.EnableStyling()
+	}
+
+	var handlers []slog.Handler
+	switch opts.Format {
+	case "", "text":
+		if opts.Silent {
+			pterm.DisableOutput()
+			handlers = append(handlers, slog.NewTextHandler(os.Stderr, handlerOpts))
+		} else {
+			pterm.EnableOutput()
+			handlers = append(handlers, &consoleHandler{level: level})
+		}
+	case "json":
+		pterm.DisableOutput()
+		handlers = append(handlers, slog.NewJSONHandler(os.Stderr, handlerOpts))
+	default:
+		return fmt.Errorf("unknown log format %q (text or json)", opts.Format)
+	}
+	decorated.Store(opts.Format != "json" && !opts.NoColor && !opts.Silent)
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+	if opts.File != "" {
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		logFile = f
+		if opts.Format == "json" {
+			handlers = append(handlers, slog.NewJSONHandler(f, handlerOpts))
+		} else {
+			handlers = append(handlers, slog.NewTextHandler(f, handlerOpts))
+		}
+	}
+
+	if len(handlers) == 1 {
+		logger.Store(slog.New(handlers[0]))
+	} else {
+		logger.Store(slog.New(fanoutHandler(handlers)))
+	}
+	return nil
+}
+
+// SetOutput redirects all console output, e.g. to io.Discard when idorplus
+// is embedded as a library
+func SetOutput(w io.Writer) {
+	pterm.SetDefaultOutput(w)
+	// Progress bars hide and show the cursor on stdout otherwise
+	if f, ok := w.(cursor.Writer); ok {
+		cursor.SetTarget(f)
+	}
+}
+
+// trimMessage drops the newlines messages carry for console layout
+func trimMessage(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.MessageKey {
+		a.Value = slog.StringValue(strings.TrimSpace(a.Value.String()))
+	}
+	return a
+}
+
+// consoleHandler renders records with the pterm prefix printers
+type consoleHandler struct {
+	level slog.Leveler
+	attrs []slog.Attr
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, len(h.attrs)+r.NumAttrs())
+	attrs = append(attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	if r.Message == vulnerableMsg {
+		var url, status string
+		for _, a := range attrs {
+			switch a.Key {
+			case "url":
+				url = a.Value.String()
+			case "status":
+				status = a.Value.String()
+			}
+		}
+		pterm.NewStyle(pterm.FgRed, pterm.Bold).Printf("[VULN] ")
+		pterm.Printf("%s (Status: %s)\n", url, status)
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString(r.Message)
+	success := false
+	for _, a := range attrs {
+		if a.Key == statusKey && a.Value.String() == "success" {
+			success = true
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+	}
+
+	printer := pterm.Debug
+	switch {
+	case r.Level >= slog.LevelError:
+		printer = pterm.Error
+	case r.Level >= slog.LevelWarn:
+		printer = pterm.Warning
+	case success:
+		printer = pterm.Success
+	case r.Level >= slog.LevelInfo:
+		printer = pterm.Info
+	}
+	// The default printers hold os.Stdout; without a writer they follow
+	// SetOutput and clear the line of an active progress bar
+	printer.Writer = nil
+	printer.Println(b.String())
+	return nil
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &consoleHandler{level: h.level, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+// WithGroup is not rendered on the console, attributes stay flat
+func (h *consoleHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// fanoutHandler sends records to several handlers, e.g. the console and
+// --log-file
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
 }
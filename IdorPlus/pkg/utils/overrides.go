@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes the environment variables that override settings:
+// scanner.threads is IDORPLUS_SCANNER_THREADS
+const EnvPrefix = "IDORPLUS"
+
+// SettingKeys lists the dotted keys of every setting that can be overridden
+// from the environment or with --set, e.g. "waf_bypass.mode". Lists and maps
+// such as notify.webhooks can only be set in the config file.
+func SettingKeys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if !f.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			switch f.Type.Kind() {
+			case reflect.Struct:
+				walk(f.Type, prefix+name+".")
+			case reflect.String, reflect.Bool, reflect.Int, reflect.Float64:
+				keys = append(keys, prefix+name)
+			}
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+// EnvName returns the environment variable overriding a setting key
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// LoadOverrides reads IDORPLUS_* environment variables and --set style
+// "key=value" assignments, which win over the environment, and applies
+// them. They stay in effect for ForTarget, so they also beat the
+// config's per-target overrides.
+func (c *Config) LoadOverrides(sets []string) error {
+	keys := SettingKeys()
+
+	v := viper.New()
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for _, key := range keys {
+		v.BindEnv(key)
+	}
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok {
+			return fmt.Errorf("invalid setting %q, expected key=value", set)
+		}
+		if !slices.Contains(keys, key) {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		v.Set(key, value)
+	}
+
+	c.overrides = v
+	return c.applyOverrides()
+}
+
+func (c *Config) applyOverrides() error {
+	if c.overrides == nil {
+		return nil
+	}
+	err := c.overrides.Unmarshal(c, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+	})
+	if err != nil {
+		return fmt.Errorf("invalid override: %w", err)
+	}
+	return nil
+}
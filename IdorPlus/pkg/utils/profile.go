@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TargetConfig overrides settings for scans of matching targets:
+//
+//	targets:
+//	  - match: "*.prod.example.com"   # host glob, optionally followed by a path prefix
+//	    profile: stealth              # applied first
+//	    scanner:
+//	      threads: 2
+//
+// Any other top-level section of the config may be overridden.
+type TargetConfig struct {
+	Match   string
+	Profile string
+
+	overrides yaml.Node
+}
+
+// UnmarshalYAML keeps the whole entry so the overrides can be applied later
+func (t *TargetConfig) UnmarshalYAML(node *yaml.Node) error {
+	var head struct {
+		Match   string `yaml:"match"`
+		Profile string `yaml:"profile"`
+	}
+	if err := node.Decode(&head); err != nil {
+		return err
+	}
+	if head.Match == "" {
+		return fmt.Errorf("line %d: target override without match", node.Line)
+	}
+	t.Match, t.Profile, t.overrides = head.Match, head.Profile, *node
+	return nil
+}
+
+// Matches reports whether the target URL is covered by the entry. The host
+// part of Match is a glob ("*.example.com"), the optional path part a prefix.
+func (t *TargetConfig) Matches(u *url.URL) bool {
+	hostPattern, pathPrefix, hasPath := strings.Cut(strings.ToLower(t.Match), "/")
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(hostPattern, ":") {
+		host = strings.ToLower(u.Host)
+	}
+	if ok, _ := path.Match(hostPattern, host); !ok {
+		return false
+	}
+	return !hasPath || strings.HasPrefix(strings.ToLower(u.Path), "/"+pathPrefix)
+}
+
+// Clone returns a copy of the config that can be modified independently
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.WAFBypass.Headers = maps.Clone(c.WAFBypass.Headers)
+	clone.Notify.Webhooks = slices.Clone(c.Notify.Webhooks)
+	clone.Export.Jira.Labels = slices.Clone(c.Export.Jira.Labels)
+	clone.Tracing.Headers = maps.Clone(c.Tracing.Headers)
+	clone.Scope.Include = slices.Clone(c.Scope.Include)
+	clone.Scope.Exclude = slices.Clone(c.Scope.Exclude)
+	clone.Scanner.PinSHA256 = slices.Clone(c.Scanner.PinSHA256)
+	clone.Scanner.RetryOn = slices.Clone(c.Scanner.RetryOn)
+	return &clone
+}
+
+// ApplyProfile overlays the named profile on the config. Settings the
+// profile does not mention are kept.
+func (c *Config) ApplyProfile(name string) error {
+	node, ok := c.Profiles[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(c.Profiles))
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q: the config defines no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (defined: %s)", name, strings.Join(names, ", "))
+	}
+	if err := node.Decode(c); err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// ForTarget returns a copy of the config with the overrides of every
+// Targets entry matching rawURL applied in order. Environment and --set
+// overrides are reapplied on top.
+func (c *Config) ForTarget(rawURL string) (*Config, error) {
+	cfg := c.Clone()
+	if len(c.Targets) == 0 || rawURL == "" {
+		return cfg, nil
+	}
+	// The placeholder may sit in the host, where url.Parse rejects braces
+	u, err := url.Parse(strings.ReplaceAll(rawURL, "{ID}", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+
+	for _, t := range c.Targets {
+		if !t.Matches(u) {
+			continue
+		}
+		if t.Profile != "" {
+			if err := cfg.ApplyProfile(t.Profile); err != nil {
+				return nil, fmt.Errorf("target %s: %w", t.Match, err)
+			}
+		}
+		if err := t.overrides.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("target %s: %w", t.Match, err)
+		}
+		Debug.Printf("Applied config overrides for %s\n", t.Match)
+	}
+	return cfg, cfg.applyOverrides()
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// BypassModes are the WAF bypass modes, none disables the bypass
+var BypassModes = []string{"none", "normal", "aggressive", "stealth"}
+
+// ReportFormats are the formats a report can be written in
+var ReportFormats = []string{"json", "markdown", "html", "burp"}
+
+// ResultOverflows are what the fuzzer does with results the scan has no
+// room for yet, see fuzzer.FuzzEngine.Overflow
+var ResultOverflows = []string{"block", "drop", "spill"}
+
+// RetryClasses are the failures scanner.retry_on may name, see
+// client.Classify
+var RetryClasses = []string{"refused", "timeout", "network", "5xx", "429"}
+
+// Severities are the finding severities, from least to most severe
+var Severities = []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// standardMethods are the methods of RFC 9110 and PATCH
+var standardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions, http.MethodTrace, http.MethodConnect,
+}
+
+// ValidateBypassMode checks a WAF bypass mode, see BypassModes
+func ValidateBypassMode(mode string) error {
+	if slices.Contains(BypassModes, mode) {
+		return nil
+	}
+	return unknownValue("bypass mode", mode, BypassModes)
+}
+
+// ValidateReportFormat checks a report format, see ReportFormats
+func ValidateReportFormat(format string) error {
+	if slices.Contains(ReportFormats, format) {
+		return nil
+	}
+	return unknownValue("report format", format, ReportFormats)
+}
+
+// ValidateSeverity checks a severity in any case, see Severities
+func ValidateSeverity(severity string) error {
+	if slices.Contains(Severities, strings.ToUpper(severity)) {
+		return nil
+	}
+	return unknownValue("severity", severity, Severities)
+}
+
+// ValidateMethod checks an HTTP method. Methods beyond the standard ones
+// are allowed for WebDAV and custom verbs, unless they look like a typo of
+// a standard one.
+func ValidateMethod(method string) error {
+	if method == "" || strings.IndexFunc(method, func(r rune) bool { return !isTokenChar(r) }) >= 0 {
+		return fmt.Errorf("invalid HTTP method %q", method)
+	}
+	upper := strings.ToUpper(method)
+	if slices.Contains(standardMethods, upper) {
+		return nil
+	}
+	if suggestion := closest(upper, standardMethods); suggestion != "" {
+		return fmt.Errorf("unknown HTTP method %q, did you mean %s?", method, suggestion)
+	}
+	return nil
+}
+
+// isTokenChar reports whether r may appear in an HTTP token, RFC 9110 5.6.2
+func isTokenChar(r rune) bool {
+	return r < 0x7f && (r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || strings.ContainsRune("!#$%&'*+-.^_`|~", r))
+}
+
+// ValidateThreshold checks a similarity threshold
+func ValidateThreshold(threshold float64) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("must be between 0 and 1, got %v", threshold)
+	}
+	return nil
+}
+
+// validateDuration checks an optional duration setting such as "500ms"
+func validateDuration(value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration, use a number with a unit such as 500ms, 30s or 5m", value)
+	}
+	if d < 0 {
+		return fmt.Errorf("%q is negative", value)
+	}
+	return nil
+}
+
+// Validate checks the settings a typo would otherwise silently replace with
+// a default. All problems are reported at once, each with its key.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	atLeast := func(key string, value, min int) {
+		if value < min {
+			check(key, fmt.Errorf("must be at least %d, got %d", min, value))
+		}
+	}
+
+	sc := c.Scanner
+	atLeast("scanner.threads", sc.Threads, 0)
+	atLeast("scanner.max_retries", sc.MaxRetries, 0)
+	atLeast("scanner.max_in_flight", sc.MaxInFlight, 0)
+	atLeast("scanner.max_per_host", sc.MaxPerHost, 0)
+	atLeast("scanner.max_conns_per_host", sc.MaxConnsPerHost, 0)
+	atLeast("scanner.max_idle_conns_per_host", sc.MaxIdleConnsPerHost, 0)
+	atLeast("scanner.queue_size", sc.QueueSize, 0)
+	if sc.ResultOverflow != "" && !slices.Contains(ResultOverflows, sc.ResultOverflow) {
+		check("scanner.result_overflow", unknownValue("overflow policy", sc.ResultOverflow, ResultOverflows))
+	}
+	atLeast("scanner.breaker_threshold", sc.BreakerThreshold, 0)
+	atLeast("scanner.max_body_mb", sc.MaxBodyMB, 0)
+	for _, class := range sc.RetryOn {
+		if !slices.Contains(RetryClasses, class) {
+			check("scanner.retry_on", unknownValue("failure class", class, RetryClasses))
+		}
+	}
+	for key, value := range map[string]string{
+		"scanner.timeout":              sc.Timeout,
+		"scanner.delay":                sc.Delay,
+		"scanner.proxy_check_interval": sc.ProxyCheckInterval,
+		"scanner.cache_ttl":            sc.CacheTTL,
+		"scanner.job_timeout":          sc.JobTimeout,
+		"scanner.slow_p95":             sc.SlowP95,
+		"scanner.dial_timeout":         sc.DialTimeout,
+		"scanner.retry_backoff":        sc.RetryBackoff,
+		"scanner.retry_max_backoff":    sc.RetryMaxBackoff,
+		"scanner.breaker_cooldown":     sc.BreakerCooldown,
+		"waf_bypass.block_cooldown":    c.WAFBypass.BlockCooldown,
+	} {
+		check(key, validateDuration(value))
+	}
+
+	if c.WAFBypass.Mode != "" {
+		check("waf_bypass.mode", ValidateBypassMode(c.WAFBypass.Mode))
+	}
+
+	d := c.Detection
+	check("detection.threshold", ValidateThreshold(d.Threshold))
+	if d.MinConfidence < 0 || d.MinConfidence > 100 {
+		check("detection.min_confidence", fmt.Errorf("must be between 0 and 100, got %d", d.MinConfidence))
+	}
+	if d.InvalidSamples < 0 || d.InvalidSamples > 5 {
+		check("detection.invalid_samples", fmt.Errorf("must be between 1 and 5, or 0 for the default, got %d", d.InvalidSamples))
+	}
+	atLeast("detection.confirmations", d.Confirmations, 0)
+
+	if c.Output.Format != "" {
+		check("output.format", ValidateReportFormat(c.Output.Format))
+	}
+
+	if r := c.Tracing.SampleRatio; r < 0 || r > 1 {
+		check("tracing.sample_ratio", fmt.Errorf("must be between 0 and 1, got %g", r))
+	}
+
+	for key, value := range map[string]string{
+		"notify.min_severity":            c.Notify.MinSeverity,
+		"export.defectdojo.min_severity": c.Export.DefectDojo.MinSeverity,
+		"export.jira.min_severity":       c.Export.Jira.MinSeverity,
+	} {
+		if value != "" {
+			check(key, ValidateSeverity(value))
+		}
+	}
+
+	// Sorted so the same config always gives the same message
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+// unknownValue describes a value that is not one of valid, suggesting the
+// closest one
+func unknownValue(what, value string, valid []string) error {
+	msg := fmt.Sprintf("unknown %s %q", what, value)
+	if suggestion := closest(value, valid); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	return fmt.Errorf("%s (valid: %s)", msg, strings.Join(valid, ", "))
+}
+
+// closest returns the candidate within two edits of value, "" if none is
+func closest(value string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if dist := fuzzy.LevenshteinDistance(strings.ToLower(value), strings.ToLower(c)); dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+	return best
+}
@@ -1,9 +1,18 @@
 package tests
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"idorplus/pkg/analyzer"
+
+	"github.com/go-resty/resty/v2"
 )
 
 func TestIDTypeDetection(t *testing.T) {
@@ -41,3 +50,192 @@ func TestIdentifierAnalyzerEmpty(t *testing.T) {
 		t.Errorf("Expected TypeUnknown for empty string, got %v", result)
 	}
 }
+
+func TestBaselineProfileToleratesVariance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if id == "7" {
+			fmt.Fprint(w, `{"id":7,"email":"alice@example.com","name":"Alice"}`)
+			return
+		}
+		// A soft 404 whose length varies with the echoed ID and request ID
+		fmt.Fprintf(w, `{"error":"user not found","id":"%s","request_id":"%s"}`, id, strings.Repeat("f", len(id)*3))
+	}))
+	defer server.Close()
+
+	client := resty.New()
+	get := func(id string) *resty.Response {
+		resp, err := client.R().SetQueryParam("id", id).Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	profile := analyzer.NewBaselineProfile([]*resty.Response{get("999999999999999"), get("99999999"), get("9999999999")})
+	if profile.Status != 200 || profile.StdDev == 0 {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+	if !profile.Tokens["found"] || profile.Tokens["99999999"] {
+		t.Errorf("expected only stable tokens, got %v", profile.Tokens)
+	}
+	if !profile.Matches(get("12345")) {
+		t.Error("another missing ID should match the profile")
+	}
+	if profile.Matches(get("7")) {
+		t.Error("an existing resource should not match the profile")
+	}
+}
+
+func TestInspectFileMetadata(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<< /Title (Invoice 42) /Author (Jane \\(JD\\) Doe) /Creator <FEFF006A0061006E0065> >>\nendobj\n%%EOF")
+
+	// JPEG with a big-endian EXIF IFD holding Artist
+	artist := "jane@example.com\x00"
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x013B)
+	tiff = binary.BigEndian.AppendUint16(tiff, 2)
+	tiff = binary.BigEndian.AppendUint32(tiff, uint32(len(artist)))
+	tiff = binary.BigEndian.AppendUint32(tiff, 26)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, artist...)
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(app1)+2))
+	jpeg = append(jpeg, app1...)
+	jpeg = append(jpeg, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+
+	var docx bytes.Buffer
+	zw := zip.NewWriter(&docx)
+	w, _ := zw.Create("docProps/core.xml")
+	w.Write([]byte(`<cp:coreProperties><dc:creator>John Smith</dc:creator><cp:lastModifiedBy>HR &amp; Payroll</cp:lastModifiedBy></cp:coreProperties>`))
+	zw.Close()
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		metadata    map[string]string
+	}{
+		{"PDF", pdf, "application/pdf", map[string]string{"Title": "Invoice 42", "Author": "Jane (JD) Doe", "Creator": "jane"}},
+		{"JPEG", jpeg, "image/jpeg", map[string]string{"Artist": "jane@example.com"}},
+		{"DOCX", docx.Bytes(), "application/zip", map[string]string{"Entries": "1", "dc:creator": "John Smith", "cp:lastModifiedBy": "HR & Payroll"}},
+	}
+	for _, tt := range tests {
+		f := analyzer.InspectFile(tt.body)
+		if f == nil {
+			t.Fatalf("%s: not recognized as a file", tt.name)
+		}
+		if f.ContentType != tt.contentType || f.Size != len(tt.body) || len(f.SHA256) != 64 {
+			t.Errorf("%s: got %s, %d bytes, sha256 %q", tt.name, f.ContentType, f.Size, f.SHA256)
+		}
+		for k, v := range tt.metadata {
+			if f.Metadata[k] != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, f.Metadata[k], v)
+			}
+		}
+	}
+
+	if f := analyzer.InspectFile([]byte(`{"id":1,"email":"a@example.com"}`)); f != nil {
+		t.Errorf("JSON should not be inspected as a file, got %s", f.ContentType)
+	}
+}
+
+func TestLearnFromSamples(t *testing.T) {
+	ia := analyzer.NewIdentifierAnalyzer()
+
+	p, err := ia.LearnFromSamples([]string{"INV-001040", "INV-001046", "INV-001043"})
+	if err != nil {
+		t.Fatalf("LearnFromSamples: %v", err)
+	}
+	if !p.Counter || p.Prefix != "INV-" || p.Width != 6 || p.Min != 1040 || p.Max != 1046 || p.Step != 3 {
+		t.Fatalf("unexpected pattern %+v", p)
+	}
+	if p.String() != "INV-{num:06d}" || p.Format(7) != "INV-000007" {
+		t.Errorf("unexpected template %s", p)
+	}
+
+	// Card-like numbers ending in a Luhn check digit
+	p, err = ia.LearnFromSamples([]string{"acct-79927398713", "acct-79927398721", "acct-79927398739"})
+	if err != nil {
+		t.Fatalf("LearnFromSamples: %v", err)
+	}
+	if p.Checksum != "luhn" || p.Min != 7992739871 || p.Format(7992739874) != "acct-79927398747" {
+		t.Errorf("expected a Luhn check digit, got %+v", p)
+	}
+
+	p, _ = ia.LearnFromSamples([]string{"user_3f9a01", "user_b8c2e4"})
+	if p.Counter || p.Charset != analyzer.CharsetHexLower || p.String() != "user_{hex:6}" {
+		t.Errorf("expected random hex, got %+v", p)
+	}
+
+	if _, err := ia.LearnFromSamples([]string{"42", "42"}); err == nil {
+		t.Error("expected an error for a single distinct ID")
+	}
+}
+
+func TestClassifyEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authed := r.Header.Get("Cookie") == "sid=attacker"
+		switch r.URL.Path {
+		case "/app.js":
+			w.Header().Set("Content-Type", "application/javascript")
+			fmt.Fprint(w, "console.log(1)")
+		case "/avatars/7.png":
+			if !authed {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "\x89PNG")
+		case "/users/7/orders":
+			if !authed {
+				http.Redirect(w, r, "/login?next=/users/7/orders", http.StatusFound)
+				return
+			}
+			fmt.Fprint(w, `{"data":[{"id":1},{"id":2},{"id":3}]}`)
+		case "/users/7":
+			fmt.Fprint(w, `{"id":7,"name":"Alice"}`)
+		case "/login":
+			fmt.Fprint(w, "<form>")
+		case "/admin":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := resty.New()
+	get := func(path string, authed bool) *resty.Response {
+		req := client.R()
+		if authed {
+			req.SetHeader("Cookie", "sid=attacker")
+		}
+		resp, err := req.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/app.js", "static application/javascript, public"},
+		{"/avatars/7.png", "object, auth required"},
+		{"/users/7/orders", "collection of 3 records, auth required"},
+		{"/users/7", "object, public"},
+		{"/admin", "denied (status 403)"},
+		{"/missing", "other (status 404)"},
+	}
+	for _, tt := range tests {
+		if got := analyzer.ClassifyEndpoint(get(tt.path, true), get(tt.path, false)).String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	if ec := analyzer.ClassifyEndpoint(get("/users/7/orders", false), nil); ec.Kind != analyzer.EndpointLogin {
+		t.Errorf("expected a login redirect without credentials, got %s", ec)
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/cluster"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+func TestClusterReassignsLostShards(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/users/"))
+		if id < 1 || id > 8 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%d,"email":"user%d@example.com"}`, id, id)
+	}))
+	defer target.Close()
+
+	var payloads []string
+	for i := 1; i <= 20; i++ {
+		payloads = append(payloads, strconv.Itoa(i))
+	}
+	opts := scanner.Options{URL: target.URL + "/users/{ID}", Cookies: "sid=attacker", Threads: 2}
+	shards := cluster.Split(opts, payloads, 5)
+	if len(shards) != 4 {
+		t.Fatalf("expected 4 shards, got %d", len(shards))
+	}
+
+	rep := reporter.NewReporter("json")
+	coord := cluster.NewCoordinator(shards, rep)
+	coord.Token = "secret"
+	coord.LeaseTimeout = 300 * time.Millisecond
+	api := httptest.NewServer(coord)
+	defer api.Close()
+
+	// A worker that leases a shard and dies without reporting it
+	req, _ := http.NewRequest("POST", api.URL+"/api/lease", strings.NewReader(`{"worker":"crashed"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("lease: %v %v", err, resp)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	w := &cluster.Worker{
+		Coordinator:  api.URL,
+		Token:        "secret",
+		Name:         "w1",
+		Config:       &utils.Config{Scanner: utils.ScannerConfig{Delay: "0s"}},
+		NewClient:    func(cfg *utils.Config) (*client.SmartClient, error) { return client.NewSmartClient(cfg), nil },
+		PollInterval: 50 * time.Millisecond,
+	}
+	workerDone := make(chan error, 1)
+	go func() { workerDone <- w.Run(ctx) }()
+
+	if err := coord.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v (status %+v)", err, coord.Status())
+	}
+	if err := <-workerDone; err != nil {
+		t.Errorf("worker: %v", err)
+	}
+
+	if st := coord.Status(); st.Shards[cluster.ShardDone] != 4 {
+		t.Errorf("expected all shards done, got %+v", st.Shards)
+	}
+	if stats := coord.Stats(); stats.Requests != 20 {
+		t.Errorf("expected 20 requests across shards, got %d", stats.Requests)
+	}
+	if len(rep.Findings) != 8 {
+		t.Errorf("expected 8 findings, got %d", len(rep.Findings))
+	}
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+func TestConfigProfilesAndTargetOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+scanner:
+  threads: 10
+  delay: 100ms
+waf_bypass:
+  enabled: true
+  mode: normal
+  headers:
+    X-Forwarded-For: 127.0.0.1
+profiles:
+  stealth:
+    scanner:
+      threads: 2
+    waf_bypass:
+      mode: stealth
+targets:
+  - match: "*.prod.example.com"
+    profile: stealth
+    waf_bypass:
+      headers:
+        X-Real-IP: 10.0.0.1
+  - match: "api.example.com/v2"
+    detection:
+      threshold: 0.95
+`), 0644)
+
+	cfg, err := utils.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := cfg.Clone().ApplyProfile("missing"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+
+	prod, err := cfg.ForTarget("https://api.prod.example.com/users/{ID}")
+	if err != nil {
+		t.Fatalf("ForTarget: %v", err)
+	}
+	if prod.Scanner.Threads != 2 || prod.WAFBypass.Mode != "stealth" || prod.Scanner.Delay != "100ms" {
+		t.Errorf("profile not applied on top of the base config: %+v %+v", prod.Scanner, prod.WAFBypass)
+	}
+	if prod.WAFBypass.Headers["X-Real-IP"] != "10.0.0.1" || prod.WAFBypass.Headers["X-Forwarded-For"] != "127.0.0.1" {
+		t.Errorf("expected merged headers, got %v", prod.WAFBypass.Headers)
+	}
+	if len(cfg.WAFBypass.Headers) != 1 || cfg.Scanner.Threads != 10 {
+		t.Errorf("ForTarget modified the base config: %+v", cfg)
+	}
+
+	if v2, _ := cfg.ForTarget("https://api.example.com/v2/orders/1"); v2.Detection.Threshold != 0.95 {
+		t.Errorf("expected path override, got threshold %v", v2.Detection.Threshold)
+	}
+	if v1, _ := cfg.ForTarget("https://api.example.com/v1/orders/1"); v1.Detection.Threshold != 0 || v1.Scanner.Threads != 10 {
+		t.Errorf("unmatched target got overrides: %+v", v1)
+	}
+}
+
+func TestConfigEnvAndSetOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+scanner:
+  threads: 10
+  delay: 100ms
+targets:
+  - match: "api.example.com"
+    scanner:
+      threads: 2
+      delay: 1s
+`), 0644)
+	t.Setenv("IDORPLUS_SCANNER_THREADS", "4")
+	t.Setenv("IDORPLUS_WAF_BYPASS_ENABLED", "true")
+	t.Setenv("IDORPLUS_DETECTION_THRESHOLD", "0.7")
+
+	cfg, err := utils.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := cfg.LoadOverrides([]string{"detection.threshold=0.9", "waf_bypass.mode=stealth"}); err != nil {
+		t.Fatalf("LoadOverrides: %v", err)
+	}
+	if err := cfg.Clone().LoadOverrides([]string{"scanner.nope=1"}); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+
+	target, err := cfg.ForTarget("https://api.example.com/users/{ID}")
+	if err != nil {
+		t.Fatalf("ForTarget: %v", err)
+	}
+	// The environment beats target overrides, --set beats the environment
+	if target.Scanner.Threads != 4 || target.Scanner.Delay != "1s" {
+		t.Errorf("expected env threads over target overrides, got %+v", target.Scanner)
+	}
+	if !target.WAFBypass.Enabled || target.WAFBypass.Mode != "stealth" || target.Detection.Threshold != 0.9 {
+		t.Errorf("overrides not applied: %+v %+v", target.WAFBypass, target.Detection)
+	}
+}
+
+func TestLoadConfigPathAndErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.WriteFile(filepath.Join(home, "idorplus.yaml"), []byte("scanner:\n  threads: 7\n"), 0644)
+
+	cfg, err := utils.LoadConfig("~/idorplus.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Scanner.Threads != 7 {
+		t.Errorf("expected the file under the home directory, got threads %d", cfg.Scanner.Threads)
+	}
+
+	bad := filepath.Join(home, "bad.yaml")
+	os.WriteFile(bad, []byte("scanner:\n  threads: 5\n  thread: 4\ndetection:\n  threshold: high\n"), 0644)
+	_, err = utils.LoadConfig(bad)
+	if err == nil {
+		t.Fatal("expected an error for an unknown setting and a malformed value")
+	}
+	for _, want := range []string{bad, "line 3: field thread not found", "line 5:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should contain %q", err, want)
+		}
+	}
+
+	os.WriteFile(bad, []byte("scanner:\n  threads: 5\n\tdelay: 1s\n"), 0644)
+	if _, err := utils.LoadConfig(bad); err == nil || !strings.Contains(err.Error(), ": line ") {
+		t.Errorf("expected a syntax error with its line, got %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := utils.DefaultConfig().Validate(); err != nil {
+		t.Fatalf("the default config should be valid: %v", err)
+	}
+
+	cfg := utils.DefaultConfig()
+	cfg.Detection.Threshold = 1.5
+	cfg.WAFBypass.Mode = "agressive"
+	cfg.Scanner.Delay = "100"
+	cfg.Output.Format = "pdf"
+	cfg.Detection.InvalidSamples = 6
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected the invalid settings to be reported")
+	}
+	for _, want := range []string{
+		"detection.threshold: must be between 0 and 1",
+		`waf_bypass.mode: unknown bypass mode "agressive", did you mean "aggressive"?`,
+		`scanner.delay: "100" is not a duration`,
+		`output.format: unknown report format "pdf"`,
+		"detection.invalid_samples: must be between 1 and 5, or 0 for the default, got 6",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should contain %q", err, want)
+		}
+	}
+
+	cfg = utils.DefaultConfig()
+	cfg.Detection.InvalidSamples = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("invalid_samples 0 should select the default: %v", err)
+	}
+
+	for method, valid := range map[string]bool{"get": true, "PROPFIND": true, "GTE": false, "GE T": false, "": false} {
+		if err := utils.ValidateMethod(method); (err == nil) != valid {
+			t.Errorf("ValidateMethod(%q) = %v, want valid %v", method, err, valid)
+		}
+	}
+	if err := utils.ValidateMethod("PSOT"); err == nil || !strings.Contains(err.Error(), "did you mean POST") {
+		t.Errorf("expected a suggestion for a typo, got %v", err)
+	}
+
+	if err := utils.ValidateSeverity("high"); err != nil {
+		t.Errorf("ValidateSeverity(high) = %v", err)
+	}
+	if err := utils.ValidateSeverity("hihg"); err == nil || !strings.Contains(err.Error(), `did you mean "HIGH"?`) {
+		t.Errorf("expected a suggestion for a severity typo, got %v", err)
+	}
+}
+
+func TestScanProfileRoundTrip(t *testing.T) {
+	opts := scanner.Options{
+		URL:       "https://api.example.com/users/{ID}",
+		Method:    "POST",
+		Body:      `{"id": "{ID}"}`,
+		Headers:   []string{"X-Api-Key: s3cret", "X-User: {ID}", "Accept: 123"},
+		Cookies:   "session=attacker",
+		Bearer:    "tok3n",
+		Payloads:  []string{"1", "007", "true"},
+		Threshold: 0.9,
+		PII:       true,
+		Script:    "def on_request(req):\n    pass\n",
+	}
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Threads = 3
+	cfg.Notify.Webhooks = []utils.WebhookConfig{{Type: "slack", URL: "https://hooks.slack.com/secret"}}
+
+	path := filepath.Join(t.TempDir(), "pentest1.yaml")
+	vars, err := scanner.SaveProfile(path, opts, cfg)
+	if err != nil {
+		t.Fatalf("SaveProfile: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"session=attacker", "tok3n", "s3cret", "hooks.slack.com"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("profile should not contain %q:\n%s", secret, data)
+		}
+	}
+	if cfg.Notify.Webhooks[0].URL != "https://hooks.slack.com/secret" {
+		t.Error("saving should not change the config")
+	}
+
+	if _, _, err := scanner.LoadProfile(path); err == nil || !strings.Contains(err.Error(), "IDORPLUS_COOKIES") {
+		t.Fatalf("expected an error naming the unset variables, got %v", err)
+	}
+	secrets := map[string]string{
+		"IDORPLUS_COOKIES":          "session=attacker",
+		"IDORPLUS_AUTH":             "tok3n",
+		"IDORPLUS_HEADER_X_API_KEY": "s3cret",
+		"IDORPLUS_WEBHOOK_1":        "https://hooks.slack.com/secret",
+	}
+	if len(vars) != len(secrets) {
+		t.Errorf("expected the variables %v, got %v", secrets, vars)
+	}
+	for name, value := range secrets {
+		t.Setenv(name, value)
+	}
+
+	loaded, loadedCfg, err := scanner.LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, opts) {
+		t.Errorf("options changed in the round trip:\n got %+v\nwant %+v", loaded, opts)
+	}
+	if loadedCfg.Scanner.Threads != 3 || loadedCfg.Notify.Webhooks[0].URL != "https://hooks.slack.com/secret" {
+		t.Errorf("config changed in the round trip: %+v", loadedCfg.Scanner)
+	}
+}
@@ -0,0 +1,416 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/client"
+	"idorplus/pkg/crawler"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func TestDetectorConfidence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/404":
+			http.NotFound(w, r)
+		case "/users/1":
+			fmt.Fprint(w, `{"id":1,"name":"attacker"}`)
+		default:
+			fmt.Fprint(w, `{"id":2,"name":"victim","contact":"victim@example.com"}`)
+		}
+	}))
+	defer server.Close()
+
+	client := resty.New()
+	get := func(path string) *resty.Response {
+		resp, err := client.R().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	det := detector.NewIDORDetector(get("/users/1"), get("/users/404"), 0.8, true)
+	a := det.Assess(get("/users/2"))
+	if a.Confidence != det.Weights.Status+det.Weights.PII || len(a.Reasons) != 2 {
+		t.Fatalf("expected status and PII to score, got %d %v", a.Confidence, a.Reasons)
+	}
+	if !det.Vulnerable(a) {
+		t.Error("default min confidence should report the response")
+	}
+
+	det.MinConfidence = 90
+	if det.Vulnerable(a) {
+		t.Errorf("confidence %d should not reach 90", a.Confidence)
+	}
+	det.ConfirmVictim(a)
+	if a.Confidence != 100 || !det.Vulnerable(a) {
+		t.Errorf("victim confirmation should raise confidence to 100, got %d", a.Confidence)
+	}
+
+	if a := det.Assess(get("/users/404")); a.Confidence != 0 {
+		t.Errorf("a denied response should score 0, got %d %v", a.Confidence, a.Reasons)
+	}
+}
+
+func TestDetectorFileDownload(t *testing.T) {
+	pdf := func(author string) string {
+		return "%PDF-1.4\n1 0 obj\n<< /Author (" + author + ") >>\nendobj\n%%EOF"
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/1":
+			fmt.Fprint(w, pdf("Attacker"))
+		case "/files/2":
+			fmt.Fprint(w, pdf("victim@example.com"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := resty.New()
+	get := func(path string) *resty.Response {
+		resp, err := client.R().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	det := detector.NewIDORDetector(get("/files/1"), get("/files/404"), 0.8, true)
+	if a := det.Assess(get("/files/2")); a.Confidence != 100 || len(a.Reasons) != 3 {
+		t.Errorf("another user's file with an email in its metadata should score 100, got %d %v", a.Confidence, a.Reasons)
+	}
+	if a := det.Assess(get("/files/1")); a.Confidence != det.Weights.Status {
+		t.Errorf("the attacker's own file should only score the status, got %d %v", a.Confidence, a.Reasons)
+	}
+	if pii := det.GetPIIMatches(get("/files/2").Body()); len(pii["email"]) != 1 {
+		t.Errorf("expected the metadata email as PII, got %v", pii)
+	}
+}
+
+func TestDetectorDownloads(t *testing.T) {
+	pdf := func(author string) string {
+		return "%PDF-1.4\n1 0 obj\n<< /Author (" + author + ") >>\nendobj\n%%EOF"
+	}
+	owners := map[string]string{"1": "attacker", "2": "victim", "3": "victim"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/invoices/"), "/download")
+		session, _ := r.Cookie("session")
+		switch {
+		case session == nil:
+			w.WriteHeader(http.StatusUnauthorized)
+		case owners[id] == "":
+			// Missing invoices get a blank PDF
+			fmt.Fprint(w, pdf("Billing"))
+		case id == "3":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="invoice-3.csv"`)
+			fmt.Fprint(w, "item,amount,email\nrent,900,victim@example.com\n")
+		default:
+			fmt.Fprint(w, pdf(owners[id]+"@example.com"))
+		}
+	}))
+	defer server.Close()
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	c := client.NewSmartClient(cfg)
+	c.GetSessionManager().AddSession("attacker", "session=attacker")
+	c.GetSessionManager().AddSession("victim", "session=victim")
+	dt := detector.NewDownloadTester(c)
+	dt.Session, dt.VictimSession = "attacker", "victim"
+
+	headers := map[string]string{"Authorization": "Bearer secret", "X-Invoice": "{ID}"}
+	result := dt.Test(context.Background(), server.URL+"/invoices/{ID}/download", headers, "1", []string{"2", "3", "4"})
+	if result.Own == nil || result.Placeholder == nil {
+		t.Fatalf("expected the own file and the placeholder, got %v %v", result.Own, result.Placeholder)
+	}
+	got := make(map[string]*detector.Download)
+	for _, d := range result.Downloads {
+		got[d.ID] = d
+	}
+	if d := got["2"]; d == nil || !d.Vulnerable || !d.VictimConfirmed || !slices.Equal(d.Owners, []string{"victim@example.com"}) {
+		t.Errorf("expected the victim's PDF, confirmed, owned by their email, got %+v", d)
+	}
+	if d := got["3"]; d == nil || !d.Vulnerable || d.File.Metadata["Filename"] != "invoice-3.csv" || d.File.Metadata["Rows"] != "1" {
+		t.Errorf("expected the victim's CSV export with its file name, got %+v", d)
+	}
+	if d := got["4"]; d == nil || d.Vulnerable {
+		t.Errorf("the placeholder of a missing invoice is not a leak, got %+v", d)
+	}
+
+	rep := reporter.NewReporter("json")
+	scanner.RecordDownloads(rep, result)
+	findings := rep.Snapshot()
+	if len(findings) != 2 || findings[0].Type != reporter.FindingDownload {
+		t.Fatalf("expected 2 download findings, got %d", len(findings))
+	}
+	if h := findings[0].Request.Headers; h["Authorization"] != reporter.MaskedHeader || h["X-Invoice"] != findings[0].Payload {
+		t.Errorf("expected the credentials masked and {ID} filled in, got %v", h)
+	}
+	// Exports stamped with the time differ on every download: only files
+	// naming another owner count
+	var downloads atomic.Int32
+	stamped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/invoices/"), "/download")
+		author := "Billing"
+		if owners[id] != "" {
+			author = owners[id] + "@example.com"
+		}
+		if id == "3" {
+			author = "attacker@example.com"
+		}
+		fmt.Fprintf(w, "%%PDF-1.4\n1 0 obj\n<< /Author (%s) /CreationDate (D:%d) >>\nendobj\n%%%%EOF", author, downloads.Add(1))
+	}))
+	defer stamped.Close()
+	result = dt.Test(context.Background(), stamped.URL+"/invoices/{ID}/download", nil, "1", []string{"2", "3", "4"})
+	if !result.Unstable {
+		t.Error("expected the stamped export to be unstable")
+	}
+	for _, d := range result.Downloads {
+		if d.Vulnerable != (d.ID == "2") {
+			t.Errorf("expected only the file naming the victim to leak, got %s %+v", d.ID, d)
+		}
+	}
+
+	if !detector.IsDownloadEndpoint("/api/reports/7.pdf") || !detector.IsDownloadEndpoint("/export?id=7") || detector.IsDownloadEndpoint("/api/users/7") {
+		t.Error("expected report and export URLs, and only those, to look like downloads")
+	}
+}
+
+func TestDetectorVersionSiblings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v2/"):
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Path == "/api/v1/users/2":
+			fmt.Fprint(w, `{"id":2,"email":"victim@example.com"}`)
+		case strings.HasPrefix(r.URL.Path, "/api/internal/"):
+			// Serves its SPA for any path
+			fmt.Fprint(w, `<html><div id="app"></div></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	versions := crawler.BuildVersionMap([]crawler.EndpointInfo{
+		{URL: "/api/v1/users/{id}"},
+		{URL: "/api/v2/users/{id}"},
+		{URL: "/api/v2/orders/{id}"},
+		{URL: "/static/app.js"},
+	})
+	if len(versions.Roots) != 1 {
+		t.Fatalf("expected the /api root, got %d roots", len(versions.Roots))
+	}
+	root := versions.Roots[0]
+	if root.Prefix != "/api" || root.Canonical != "v2" || root.Endpoints["v2"] != 2 {
+		t.Fatalf("unexpected root %+v", root)
+	}
+
+	siblings := versions.Siblings(server.URL + "/api/v2/users/2?expand=1")
+	techniques := make(map[string]string)
+	for _, m := range siblings {
+		techniques[m.Technique] = m.URL
+	}
+	for _, want := range []string{"version v1", "unlisted version v3", "unlisted version v0", "unlisted version beta", "unlisted version internal"} {
+		if techniques[want] == "" {
+			t.Errorf("expected the sibling %q, got %v", want, techniques)
+		}
+	}
+	if got := techniques["version v1"]; got != server.URL+"/api/v1/users/2?expand=1" {
+		t.Errorf("unexpected v1 URL %s", got)
+	}
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	result := detector.NewAPIVersionTester(client.NewSmartClient(cfg)).
+		TestVariants(context.Background(), server.URL+"/api/v2/users/2", "GET", "", versions.Siblings(server.URL+"/api/v2/users/2"))
+	if result.BaselineStatus != http.StatusForbidden {
+		t.Fatalf("expected v2 to be denied, got %d", result.BaselineStatus)
+	}
+	var bypassed []string
+	for _, a := range result.Attempts {
+		if a.Bypassed {
+			bypassed = append(bypassed, a.Technique)
+		}
+	}
+	if !slices.Equal(bypassed, []string{"version v1"}) {
+		t.Errorf("expected only v1 to answer, not the SPA fallback of internal, got %v", bypassed)
+	}
+
+	// Other methods are not sent to other versions
+	result = detector.NewAPIVersionTester(client.NewSmartClient(cfg)).
+		TestVariants(context.Background(), server.URL+"/api/v2/users/2", "POST", "", versions.Siblings(server.URL+"/api/v2/users/2"))
+	if len(result.Attempts) != 0 || result.IsVulnerable {
+		t.Errorf("expected no attempt for POST, got %d", len(result.Attempts))
+	}
+}
+
+func TestDetectorRedirectChains(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/login":
+			fmt.Fprint(w, "<form>Sign in</form>")
+		case strings.HasPrefix(r.URL.Path, "/users/"):
+			fmt.Fprintf(w, `{"order":%q}`, r.URL.Path)
+		case r.URL.Path == "/orders/1", r.URL.Path == "/orders/2":
+			http.Redirect(w, r, "/users/1"+r.URL.Path, http.StatusFound)
+		case r.URL.Path == "/orders/5":
+			http.Redirect(w, r, "/users/17/orders/5", http.StatusFound)
+		default:
+			http.Redirect(w, r, "/login?next="+r.URL.Path, http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client := resty.New()
+	get := func(path string) *resty.Response {
+		resp, err := client.R().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	det := detector.NewIDORDetector(get("/orders/1"), get("/orders/404"), 0.8, false)
+	if a := det.Assess(get("/orders/5")); a.Confidence != det.Weights.Redirect {
+		t.Errorf("a redirect into another user's orders should score, got %d %v", a.Confidence, a.Reasons)
+	}
+	if a := det.Assess(get("/orders/2")); a.Confidence != 0 {
+		t.Errorf("a redirect into the attacker's own orders should not score, got %d %v", a.Confidence, a.Reasons)
+	}
+	if a := det.Assess(get("/orders/999")); a.Confidence != 0 {
+		t.Errorf("a redirect to the login should not score, got %d %v", a.Confidence, a.Reasons)
+	}
+
+	chain := analyzer.RedirectChain(get("/orders/5"))
+	if len(chain) != 1 || chain[0].Status != http.StatusFound || !strings.HasSuffix(chain[0].To, "/users/17/orders/5") {
+		t.Errorf("unexpected chain %+v", chain)
+	}
+}
+
+func TestDetectorMassData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A lookup that takes filters: operators and SQL match everyone
+		id := r.URL.Query().Get("id")
+		switch {
+		case r.URL.Query().Has("id[$ne]") || strings.Contains(id, " OR "):
+			fmt.Fprint(w, `{"data":[{"id":1},{"id":2},{"id":3},{"id":4}]}`)
+		case id == "1":
+			fmt.Fprint(w, `{"id":1,"name":"attacker"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := resty.New()
+	get := func(id string) *resty.Response {
+		resp, err := client.R().Get(scanner.ReplaceID(server.URL+"/users?id={ID}", id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	det := detector.NewIDORDetector(get("1"), get("404"), 0.8, false)
+	for _, id := range []string{"[$ne]=1", "' OR '1'='1"} {
+		a := det.Assess(get(id))
+		if !strings.Contains(strings.Join(a.Reasons, "; "), "Returns 4 records where the baseline has 1") {
+			t.Errorf("%s: expected a mass data reason, got %d %v", id, a.Confidence, a.Reasons)
+		}
+	}
+	if a := det.Assess(get("1")); a.Confidence != det.Weights.Status {
+		t.Errorf("the attacker's own object should only score the status, got %d %v", a.Confidence, a.Reasons)
+	}
+}
+
+func TestDetectorMassAssignmentVerifiesPastCache(t *testing.T) {
+	// Echoes every field but only stores the role
+	role := "user"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `{"id":1,"role":%q}`, role)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if v, ok := body["role"].(string); ok {
+			role = v
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer server.Close()
+
+	c := client.NewSmartClient(&utils.Config{Scanner: utils.ScannerConfig{Delay: "0s"}})
+	c.SetSafety(&client.Safety{AllowDestructive: true})
+	if err := c.EnableCache("", 0); err != nil {
+		t.Fatalf("EnableCache failed: %v", err)
+	}
+	result := detector.NewMassAssignmentTester(c).TestEndpoint(context.Background(), server.URL+"/users/1", "PUT", map[string]interface{}{"name": "me"})
+	if len(result.Attempts) != 1 || result.Attempts[0].Param != "role" || !result.Attempts[0].Verified {
+		t.Errorf("expected the role to be verified as stored, got %v", result.VulnerableParams)
+	}
+}
+
+func TestDetectorVerbTamper(t *testing.T) {
+	// The override is honoured: a POST tunnelling GET skips the GET check
+	bypass := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.Header.Get("X-HTTP-Method-Override") == "GET":
+			fmt.Fprint(w, `{"id":1,"owner":"victim","note":"secret"}`)
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	}))
+	defer bypass.Close()
+	// The override is ignored: POST is a form handler whatever it says
+	ignored := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			fmt.Fprint(w, `{"ok":true}`)
+			return
+		}
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer ignored.Close()
+
+	vt := detector.NewVerbTamperTester(client.NewSmartClient(nil))
+	result := vt.TestEndpoint(context.Background(), bypass.URL+"/notes/1", "GET", "")
+	var bypassed []string
+	for _, a := range result.Attempts {
+		if a.Bypassed {
+			bypassed = append(bypassed, a.Technique)
+		}
+	}
+	if !result.IsVulnerable || !slices.Equal(bypassed, []string{"X-HTTP-Method-Override: GET"}) {
+		t.Errorf("expected the honoured override to be the bypass, got %v", bypassed)
+	}
+
+	result = vt.TestEndpoint(context.Background(), ignored.URL+"/notes/1", "GET", "")
+	if result.IsVulnerable {
+		for _, a := range result.Attempts {
+			if a.Bypassed {
+				t.Errorf("%s: the override was ignored, not a bypass", a.Technique)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"idorplus/pkg/elastic"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+)
+
+func TestElasticIndexer(t *testing.T) {
+	var mu sync.Mutex
+	var mapping map[string]any
+	var docs []elastic.Document
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "ApiKey k3y" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/scans":
+			if mapping == nil {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPut && r.URL.Path == "/scans":
+			json.NewDecoder(r.Body).Decode(&mapping)
+		case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+			scanner := bufio.NewScanner(r.Body)
+			for line := 0; scanner.Scan(); line++ {
+				if line%2 == 0 {
+					var action map[string]map[string]string
+					json.Unmarshal(scanner.Bytes(), &action)
+					if action["index"]["_index"] != "scans" {
+						http.Error(w, "wrong index", http.StatusBadRequest)
+						return
+					}
+					continue
+				}
+				var doc elastic.Document
+				json.Unmarshal(scanner.Bytes(), &doc)
+				docs = append(docs, doc)
+			}
+			w.Write([]byte(`{"errors": false, "items": []}`))
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	ix, err := elastic.NewIndexer(utils.ElasticConfig{URL: server.URL + "/", Index: "scans", APIKey: "k3y"}, "https://api.test/users/{ID}")
+	if err != nil {
+		t.Fatalf("NewIndexer: %v", err)
+	}
+	if mapping == nil {
+		t.Fatal("index not created with the mapping")
+	}
+
+	ix.Add(&fuzzer.FuzzResult{
+		Job:          &fuzzer.FuzzJob{URL: "https://api.test/users/2", Method: "GET", Payload: "2", Session: "attacker"},
+		StatusCode:   200,
+		IsVulnerable: true,
+		Confidence:   90,
+		Duration:     120 * time.Millisecond,
+	})
+	ix.Add(&fuzzer.FuzzResult{
+		Job:   &fuzzer.FuzzJob{URL: "https://api.test/users/3", Method: "GET", Payload: "3"},
+		Error: errors.New("connection reset"),
+	})
+	if sent, failed := ix.Close(); sent != 2 || failed != 0 {
+		t.Fatalf("Close = %d sent, %d failed, want 2, 0", sent, failed)
+	}
+
+	if len(docs) != 2 {
+		t.Fatalf("indexed %d documents, want 2 including the non-vulnerable one", len(docs))
+	}
+	first := docs[0]
+	if first.ScanID != ix.ScanID || first.Host != "api.test" || first.Endpoint != first.URL ||
+		!first.Vulnerable || first.DurationMS != 120 || first.Target != "https://api.test/users/{ID}" {
+		t.Errorf("unexpected document: %+v", first)
+	}
+	if docs[1].Vulnerable || docs[1].Error != "connection reset" {
+		t.Errorf("unexpected document for the failed request: %+v", docs[1])
+	}
+}
+
+func TestElasticIndexerDisabled(t *testing.T) {
+	ix, err := elastic.NewIndexer(utils.ElasticConfig{}, "https://api.test/{ID}")
+	if ix != nil || err != nil {
+		t.Fatalf("NewIndexer without URL = %v, %v, want nil, nil", ix, err)
+	}
+	ix.Add(&fuzzer.FuzzResult{Job: &fuzzer.FuzzJob{}})
+	if sent, failed := ix.Close(); sent != 0 || failed != 0 {
+		t.Errorf("nil Indexer Close = %d, %d", sent, failed)
+	}
+}
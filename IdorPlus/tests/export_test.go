@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"idorplus/pkg/export"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+func TestDefectDojoImport(t *testing.T) {
+	var fields map[string]string
+	var imported struct {
+		Findings []map[string]any `json:"findings"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/import-scan/" || r.Header.Get("Authorization") != "Token k3y" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields = make(map[string]string)
+		for name, values := range r.MultipartForm.Value {
+			fields[name] = values[0]
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(file).Decode(&imported)
+		w.Write([]byte(`{"test": 42}`))
+	}))
+	defer server.Close()
+
+	if _, err := export.NewDefectDojo(utils.DefectDojoConfig{URL: server.URL, APIKey: "k3y"}); err == nil {
+		t.Error("expected an error without an engagement")
+	}
+	dojo, err := export.NewDefectDojo(utils.DefectDojoConfig{URL: server.URL + "/", APIKey: "k3y", Product: "Shop", EngagementName: "Q3 pentest", MinSeverity: "medium"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := &reporter.Report{ScanTime: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), Findings: []*reporter.Finding{
+		{Type: reporter.FindingIDOR, URL: "https://api.example.com:8443/users/7?full=1", Method: "GET", Payload: "7", Severity: "HIGH", Fingerprint: "abc123",
+			Evidence: `{"email":"victim@example.com"}`, PIIFound: map[string][]string{"email": {"victim@example.com"}},
+			Request: &reporter.RecordedRequest{Method: "GET", URL: "https://api.example.com:8443/users/7?full=1", Headers: map[string]string{"Cookie": "sid=s3ssion", "Authorization": "Bearer t0k"}}},
+		{Type: reporter.FindingIDOR, URL: "https://api.example.com/users/8", Method: "GET", Payload: "8", Severity: "LOW"},
+	}}
+	test, err := dojo.Import(report)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if test != 42 {
+		t.Errorf("test = %d, want 42", test)
+	}
+	if fields["product_name"] != "Shop" || fields["engagement_name"] != "Q3 pentest" || fields["auto_create_context"] != "true" || fields["scan_type"] != "Generic Findings Import" {
+		t.Errorf("unexpected form fields: %v", fields)
+	}
+	if len(imported.Findings) != 1 {
+		t.Fatalf("expected the LOW finding to be left out, got %d findings", len(imported.Findings))
+	}
+	f := imported.Findings[0]
+	if f["severity"] != "High" || f["unique_id_from_tool"] != "abc123" || f["cwe"] != float64(639) || f["date"] != "2024-06-01" {
+		t.Errorf("unexpected finding: %v", f)
+	}
+	data, _ := json.Marshal(f)
+	for _, secret := range []string{"victim@example.com", "s3ssion", "t0k"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("the finding sent %q: %s", secret, data)
+		}
+	}
+	if steps := f["steps_to_reproduce"].(string); !strings.Contains(steps, "Cookie: "+reporter.MaskedHeader) {
+		t.Errorf("expected the curl reproduction with the cookie masked, got %s", steps)
+	}
+	endpoint := f["endpoints"].([]any)[0].(map[string]any)
+	if endpoint["host"] != "api.example.com" || endpoint["port"] != float64(8443) || endpoint["path"] != "users/7" || endpoint["query"] != "full=1" {
+		t.Errorf("unexpected endpoint: %v", endpoint)
+	}
+}
+
+func TestJiraExport(t *testing.T) {
+	filed := map[string]string{"idorplus-old": "SEC-1"}
+	var created []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me@example.com" || pass != "t0ken" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/rest/api/3/search/jql":
+			jql := r.URL.Query().Get("jql")
+			for label, key := range filed {
+				if strings.Contains(jql, `labels = "`+label+`"`) {
+					w.Write([]byte(`{"issues":[{"key":"` + key + `"}]}`))
+					return
+				}
+			}
+			w.Write([]byte(`{"issues":[]}`))
+		case "/rest/api/2/issue":
+			var issue map[string]any
+			data, _ := io.ReadAll(r.Body)
+			json.Unmarshal(data, &issue)
+			created = append(created, issue["fields"].(map[string]any))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"key":"SEC-2"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	jira, err := export.NewJira(utils.JiraConfig{URL: server.URL, User: "me@example.com", Token: "t0ken", Project: "SEC", Labels: []string{"idorplus"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := jira.Export([]*reporter.Finding{
+		{Type: reporter.FindingIDOR, URL: "https://api.example.com/users/7", Method: "GET", Severity: "HIGH", Fingerprint: "old"},
+		{Type: reporter.FindingMassAssign, URL: "https://api.example.com/users/8", Method: "PUT", Severity: "CRITICAL", Fingerprint: "new", Evidence: `{"role":"admin"}`},
+		{Type: reporter.FindingIDOR, URL: "https://api.example.com/users/9", Method: "GET", Severity: "LOW", Fingerprint: "low"},
+	})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if strings.Join(result.Existing, ",") != "SEC-1" || strings.Join(result.Created, ",") != "SEC-2" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(created) != 1 {
+		t.Fatalf("expected one issue, got %d", len(created))
+	}
+	fields := created[0]
+	labels, _ := json.Marshal(fields["labels"])
+	if string(labels) != `["idorplus","idorplus-new"]` || fields["issuetype"].(map[string]any)["name"] != "Bug" {
+		t.Errorf("unexpected issue fields: %v", fields)
+	}
+	if desc := fields["description"].(string); !strings.Contains(desc, `{"role":"admin"}`) || !strings.Contains(desc, reporter.Remediation(reporter.FindingMassAssign)) {
+		t.Errorf("unexpected description:\n%s", desc)
+	}
+}
@@ -0,0 +1,639 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/utils"
+
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFuzzJobInterpolate(t *testing.T) {
+	job := &fuzzer.FuzzJob{
+		Payload: "42",
+		Vars:    map[string]string{"ORG": "acme"},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{ID}", "42"},
+		{"Bearer user-{ID}", "Bearer user-42"},
+		{`{"id":"{ID}","org":"{ORG}"}`, `{"id":"42","org":"acme"}`},
+		{"{UNKNOWN}", "{UNKNOWN}"},
+		{"no placeholder", "no placeholder"},
+	}
+
+	for _, tt := range tests {
+		if result := job.Interpolate(tt.input); result != tt.expected {
+			t.Errorf("Interpolate(%s) = %s, want %s", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestFuzzEngineMaxPerHost(t *testing.T) {
+	newServer := func(maxSeen *int32) *httptest.Server {
+		var active int32
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&active, 1)
+			defer atomic.AddInt32(&active, -1)
+			for {
+				seen := atomic.LoadInt32(maxSeen)
+				if n <= seen || atomic.CompareAndSwapInt32(maxSeen, seen, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}))
+	}
+
+	var maxA, maxB int32
+	srvA, srvB := newServer(&maxA), newServer(&maxB)
+	defer srvA.Close()
+	defer srvB.Close()
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 100, Delay: "0s"}}
+	fe := fuzzer.NewFuzzEngine(client.NewSmartClient(cfg), 6, nil)
+	fe.MaxPerHost = 2
+	fe.Start()
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			fe.Submit(&fuzzer.FuzzJob{ID: i, URL: srvA.URL + "/a", Method: "GET"})
+			fe.Submit(&fuzzer.FuzzJob{ID: i, URL: srvB.URL + "/b", Method: "GET"})
+		}
+		fe.CloseQueue()
+		fe.WaitAndClose()
+	}()
+
+	count := 0
+	for result := range fe.Results {
+		if result.Error != nil {
+			t.Errorf("Job failed: %v", result.Error)
+		}
+		count++
+	}
+
+	if count != 20 {
+		t.Errorf("Expected 20 results, got %d", count)
+	}
+	if maxA > 2 || maxB > 2 {
+		t.Errorf("Per-host limit exceeded: A=%d B=%d", maxA, maxB)
+	}
+}
+
+func TestJobQueuePriority(t *testing.T) {
+	q := fuzzer.NewJobQueue()
+	q.Push(&fuzzer.FuzzJob{Payload: "gen-1", Priority: fuzzer.PrioritySynthetic})
+	q.Push(&fuzzer.FuzzJob{Payload: "real-1", Priority: fuzzer.PriorityHarvested})
+	q.Push(&fuzzer.FuzzJob{Payload: "gen-2", Priority: fuzzer.PrioritySynthetic, Endpoint: "other"})
+	q.Push(&fuzzer.FuzzJob{Payload: "real-2", Priority: fuzzer.PriorityHarvested})
+
+	if dropped := q.Drop(func(j *fuzzer.FuzzJob) bool { return j.Endpoint == "other" }); dropped != 1 {
+		t.Errorf("Expected 1 dropped job, got %d", dropped)
+	}
+	q.Close()
+
+	var order []string
+	for {
+		job, closed := q.TryPop()
+		if job == nil {
+			if !closed {
+				t.Fatal("Empty queue should report closed")
+			}
+			break
+		}
+		order = append(order, job.Payload)
+	}
+
+	expected := []string{"real-1", "real-2", "gen-1"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Errorf("Pop order = %v, want %v", order, expected)
+	}
+	if q.Push(&fuzzer.FuzzJob{}) {
+		t.Error("Push after Close should fail")
+	}
+}
+
+func TestEngineRunsHarvestedFirst(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 100, Delay: "0s"}}
+	fe := fuzzer.NewFuzzEngine(client.NewSmartClient(cfg), 1, nil)
+	for i := 1; i <= 5; i++ {
+		fe.Submit(&fuzzer.FuzzJob{ID: i, URL: server.URL + "/users/" + strconv.Itoa(i), Method: "GET", Payload: strconv.Itoa(i), Priority: fuzzer.PrioritySynthetic})
+	}
+	fe.Submit(&fuzzer.FuzzJob{ID: 6, URL: server.URL + "/users/1042", Method: "GET", Payload: "1042", Priority: fuzzer.PriorityHarvested})
+	fe.CloseQueue()
+	fe.Start()
+	go fe.WaitAndClose()
+
+	var order []string
+	for result := range fe.Results {
+		order = append(order, result.Job.Payload)
+	}
+	if len(order) != 6 || order[0] != "1042" {
+		t.Errorf("expected the harvested job submitted last to run first, got %v", order)
+	}
+}
+
+func TestJobQueueLimit(t *testing.T) {
+	q := fuzzer.NewJobQueue()
+	q.SetLimit(2)
+	q.Push(&fuzzer.FuzzJob{Payload: "1"})
+	q.Push(&fuzzer.FuzzJob{Payload: "2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if q.PushContext(ctx, &fuzzer.FuzzJob{Payload: "3"}) {
+		t.Fatal("PushContext into a full queue should give up with its context")
+	}
+
+	pushed := make(chan bool)
+	go func() { pushed <- q.Push(&fuzzer.FuzzJob{Payload: "3"}) }()
+	select {
+	case <-pushed:
+		t.Fatal("Push into a full queue should wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	q.TryPop()
+	if !<-pushed || q.Len() != 2 {
+		t.Errorf("Push should go through once a job is taken, queue holds %d", q.Len())
+	}
+
+	// A job set aside keeps its room until it is released
+	q.TryPop()
+	q.Hold(1)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if q.PushContext(ctx, &fuzzer.FuzzJob{Payload: "4"}) {
+		t.Fatal("PushContext should wait while held jobs fill the queue")
+	}
+	q.Unhold(1)
+	if !q.Push(&fuzzer.FuzzJob{Payload: "4"}) || q.Len() != 2 {
+		t.Errorf("Push should go through once the held job is released, queue holds %d", q.Len())
+	}
+
+	go func() { pushed <- q.Push(&fuzzer.FuzzJob{Payload: "5"}) }()
+	q.Close()
+	if <-pushed {
+		t.Error("Push waiting for room should fail once the queue is closed")
+	}
+}
+
+func TestFuzzEngineDropsFlakyFindings(t *testing.T) {
+	var flakyHits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/1":
+			w.Write([]byte(`{"id":1,"name":"attacker"}`))
+		case "/users/2":
+			// Leaks once, e.g. a stale cache entry, then denies
+			if atomic.AddInt32(&flakyHits, 1) > 1 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(`{"id":2,"contact":"victim@example.com"}`))
+		case "/users/3":
+			w.Write([]byte(`{"id":3,"contact":"other@example.com"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 10, Delay: "0s"}}
+	c := client.NewSmartClient(cfg)
+	// Re-tests are not served the cached leak
+	if err := c.EnableCache("", 0); err != nil {
+		t.Fatalf("EnableCache failed: %v", err)
+	}
+	get := func(path string) *resty.Response {
+		resp, err := c.Request(context.Background()).Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	fe := fuzzer.NewFuzzEngine(c, 2, detector.NewIDORDetector(get("/users/1"), get("/users/404"), 0.8, true))
+	fe.Confirmations = 2
+	fe.Start()
+	go func() {
+		fe.Submit(&fuzzer.FuzzJob{ID: 2, URL: server.URL + "/users/2", Method: "GET"})
+		fe.Submit(&fuzzer.FuzzJob{ID: 3, URL: server.URL + "/users/3", Method: "GET"})
+		fe.CloseQueue()
+		fe.WaitAndClose()
+	}()
+
+	vulnerable := map[int]bool{}
+	for result := range fe.Results {
+		vulnerable[result.Job.ID] = result.IsVulnerable
+	}
+	if vulnerable[2] {
+		t.Error("a finding that does not reproduce should be dropped")
+	}
+	if !vulnerable[3] {
+		t.Error("a reproducible finding should be kept")
+	}
+	if fe.Stats.GetFlakyCount() != 1 || fe.Stats.GetVulnCount() != 1 {
+		t.Errorf("expected 1 flaky and 1 vulnerable, got %d and %d", fe.Stats.GetFlakyCount(), fe.Stats.GetVulnCount())
+	}
+}
+
+func TestFuzzEngineSlowEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(80 * time.Millisecond)
+		case "/hang":
+			time.Sleep(400 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 100, Delay: "0s"}}
+	fe := fuzzer.NewFuzzEngine(client.NewSmartClient(cfg), 4, nil)
+	fe.MaxRetries = 0
+	fe.JobTimeout = 200 * time.Millisecond
+	fe.SlowP95 = 50 * time.Millisecond
+	fe.Start()
+	go func() {
+		fe.Submit(&fuzzer.FuzzJob{ID: -1, URL: server.URL + "/hang", Method: "GET"})
+		for i := 0; i < 30; i++ {
+			fe.Submit(&fuzzer.FuzzJob{ID: i, URL: server.URL + "/slow", Method: "GET"})
+			fe.Submit(&fuzzer.FuzzJob{ID: i, URL: server.URL + "/fast", Method: "GET"})
+		}
+		fe.CloseQueue()
+		fe.WaitAndClose()
+	}()
+
+	fast := 0
+	for result := range fe.Results {
+		switch {
+		case result.Job.ID == -1:
+			if result.Error == nil || !strings.Contains(result.Error.Error(), "timed out") {
+				t.Errorf("expected the hanging job to time out, got %v", result.Error)
+			}
+		case strings.HasSuffix(result.Job.URL, "/fast"):
+			fast++
+		}
+	}
+
+	if fast != 30 {
+		t.Errorf("expected every fast job, got %d", fast)
+	}
+	slow := fe.SlowEndpoints()
+	if len(slow) != 1 || slow[0].Endpoint != server.URL+"/slow" || slow[0].Skipped == 0 {
+		t.Fatalf("expected /slow limited then skipped, got %+v", slow)
+	}
+	if fe.Stats.GetSkippedCount() != int64(slow[0].Skipped) {
+		t.Errorf("skipped %d jobs, stats count %d", slow[0].Skipped, fe.Stats.GetSkippedCount())
+	}
+}
+
+func TestFuzzEngineReleasesBodies(t *testing.T) {
+	body := strings.Repeat("x", 5000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 10, Delay: "0s"}}
+	fe := fuzzer.NewFuzzEngine(client.NewSmartClient(cfg), 2, nil)
+	fe.BodyDir = t.TempDir()
+	fe.Verdict = func(result *fuzzer.FuzzResult) bool { return result.Job.ID == 1 }
+	fe.Start()
+	go func() {
+		fe.Submit(&fuzzer.FuzzJob{ID: 1, URL: server.URL + "/users/1", Method: "GET"})
+		fe.Submit(&fuzzer.FuzzJob{ID: 2, URL: server.URL + "/users/2", Method: "GET"})
+		fe.CloseQueue()
+		fe.WaitAndClose()
+	}()
+
+	for result := range fe.Results {
+		if len(result.Evidence) != fuzzer.SnippetSize || result.ContentLen != len(body) || result.BodyDigest == "" {
+			t.Errorf("job %d: expected a %d byte snippet and a digest of the %d byte body, got %d bytes, %d, %q",
+				result.Job.ID, fuzzer.SnippetSize, len(body), len(result.Evidence), result.ContentLen, result.BodyDigest)
+		}
+		if !result.IsVulnerable {
+			if result.Response != nil || result.Body() != nil {
+				t.Error("a non-vulnerable result should drop its response")
+			}
+			continue
+		}
+		if result.BodyFile == "" || len(result.Response.Body()) != 0 {
+			t.Error("a finding's body should be spooled to BodyDir")
+		}
+		if string(result.Body()) != body {
+			t.Errorf("expected the full body back from %s, got %d bytes", result.BodyFile, len(result.Body()))
+		}
+	}
+}
+
+func TestFuzzEngineConnReuse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	run := func(keepAlive bool) *fuzzer.Stats {
+		cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 100, Delay: "0s", DisableKeepAlives: !keepAlive}}
+		fe := fuzzer.NewFuzzEngine(client.NewSmartClient(cfg), 1, nil)
+		fe.Start()
+		go func() {
+			for i := 0; i < 10; i++ {
+				fe.Submit(&fuzzer.FuzzJob{ID: i, URL: server.URL + "/users/1", Method: "GET"})
+			}
+			fe.CloseQueue()
+			fe.WaitAndClose()
+		}()
+		for range fe.Results {
+		}
+		return fe.Stats
+	}
+
+	if st := run(true); st.ConnsOpened != 1 || st.ConnsReused != 9 {
+		t.Errorf("expected 1 connection reused 9 times, got %d opened and %d reused", st.ConnsOpened, st.ConnsReused)
+	}
+	if st := run(false); st.ConnsOpened != 10 || st.GetConnReuse() != 0 {
+		t.Errorf("expected a connection per request without keep-alives, got %d opened and %d reused", st.ConnsOpened, st.ConnsReused)
+	}
+}
+
+func TestJobTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/404" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"id":` + strings.TrimPrefix(r.URL.Path, "/users/") + `,"email":"someone@example.com"}`))
+	}))
+	defer server.Close()
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 10, Delay: "0s"}}
+	c := client.NewSmartClient(cfg)
+	baseline, _ := c.Request(context.Background()).Get(server.URL + "/users/1")
+	invalid, _ := c.Request(context.Background()).Get(server.URL + "/users/404")
+
+	fe := fuzzer.NewFuzzEngine(c, 1, detector.NewIDORDetector(baseline, invalid, 0.8, true))
+	fe.Start()
+	go func() {
+		fe.Submit(&fuzzer.FuzzJob{ID: 2, URL: server.URL + "/users/2", Method: "GET", Payload: "2"})
+		fe.CloseQueue()
+		fe.WaitAndClose()
+	}()
+	for range fe.Results {
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	job, ok := spans["fuzz.job"]
+	if !ok {
+		t.Fatalf("no job span, got %v", spans)
+	}
+	for _, name := range []string{"fuzz.queue_wait", "ratelimit.wait", "http.request", "fuzz.detect"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if span.Parent().SpanID() != job.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of the job span", name)
+		}
+	}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range job.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["idorplus.payload"].AsString() != "2" || attrs["http.response.status_code"].AsInt64() != 200 {
+		t.Errorf("unexpected job span attributes: %v", job.Attributes())
+	}
+}
+
+func TestStatsBreakdown(t *testing.T) {
+	st := fuzzer.NewStats()
+	for i := 1; i <= 100; i++ {
+		result := &fuzzer.FuzzResult{StatusCode: 200, ContentLen: 10}
+		endpoint := "/users/{ID}"
+		if i%4 == 0 {
+			result = &fuzzer.FuzzResult{StatusCode: 404, ContentLen: 5}
+			endpoint = "/orders/{ID}"
+		}
+		if i == 100 {
+			result = &fuzzer.FuzzResult{Error: context.DeadlineExceeded, IsVulnerable: false}
+		}
+		st.Record(endpoint, result, time.Duration(i)*time.Millisecond)
+	}
+
+	b := st.Breakdown()
+	if b.StatusCodes[200] != 75 || b.StatusCodes[404] != 24 || b.StatusCodes[0] != 1 {
+		t.Errorf("unexpected status codes: %v", b.StatusCodes)
+	}
+	if b.Bytes != 75*10+24*5 {
+		t.Errorf("Bytes = %d", b.Bytes)
+	}
+	within := func(name string, got, want time.Duration) {
+		if got < want*8/10 || got > want*12/10 {
+			t.Errorf("%s = %s, want about %s", name, got, want)
+		}
+	}
+	within("avg", b.Latency.Avg, 50*time.Millisecond)
+	within("p50", b.Latency.P50, 50*time.Millisecond)
+	within("p95", b.Latency.P95, 95*time.Millisecond)
+	if b.Latency.Max != 100*time.Millisecond || b.Latency.Samples != 100 {
+		t.Errorf("unexpected latency: %+v", b.Latency)
+	}
+
+	if len(b.Endpoints) != 2 || b.Endpoints[0].Endpoint != "/users/{ID}" || b.Endpoints[0].Requests != 75 {
+		t.Fatalf("unexpected endpoints: %+v", b.Endpoints)
+	}
+	if orders := b.Endpoints[1]; orders.Errors != 1 || orders.StatusCodes[404] != 24 {
+		t.Errorf("unexpected orders endpoint: %+v", orders)
+	}
+	if line := fuzzer.StatusLine(b.StatusCodes); line != "200: 75, 404: 24, error: 1" {
+		t.Errorf("StatusLine = %q", line)
+	}
+}
+
+// newOverflowEngine returns an engine and the URL of a server fast enough
+// for consumers to fall behind
+func newOverflowEngine(t *testing.T, overflow string) (*fuzzer.FuzzEngine, string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 1000, Delay: "0s"}}
+	fe := fuzzer.NewFuzzEngine(client.NewSmartClient(cfg), 4, nil)
+	fe.MaxRetries = 0
+	fe.Overflow = overflow
+	fe.SpillDir = t.TempDir()
+	fe.Queue.SetLimit(20)
+	fe.Start()
+	return fe, server.URL
+}
+
+// feed submits n jobs like the scanner does and closes the engine's queue
+func feed(fe *fuzzer.FuzzEngine, url string, n int) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			if !fe.Submit(&fuzzer.FuzzJob{ID: i, URL: url, Method: "GET"}) {
+				break
+			}
+		}
+		fe.CloseQueue()
+		fe.WaitAndClose()
+	}()
+	return done
+}
+
+func TestResultOverflow(t *testing.T) {
+	const jobs = 300
+	for _, overflow := range []string{fuzzer.OverflowDrop, fuzzer.OverflowSpill} {
+		t.Run(overflow, func(t *testing.T) {
+			fe, url := newOverflowEngine(t, overflow)
+			done := feed(fe, url, jobs)
+
+			// Stall until the workers are done, far more than Results holds
+			deadline := time.Now().Add(10 * time.Second)
+			for fe.Stats.GetTotal() < jobs && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			received := 0
+			for result := range fe.Results {
+				if result.Job == nil || result.StatusCode != 200 {
+					t.Fatalf("unexpected result %+v", result)
+				}
+				received++
+			}
+			<-done
+
+			switch overflow {
+			case fuzzer.OverflowDrop:
+				if dropped := fe.Stats.GetDroppedCount(); dropped == 0 || int64(received)+dropped != jobs {
+					t.Errorf("received %d and dropped %d of %d results", received, dropped, jobs)
+				}
+			case fuzzer.OverflowSpill:
+				if received != jobs || fe.Stats.GetSpilledCount() == 0 {
+					t.Errorf("received %d of %d results, %d spilled", received, jobs, fe.Stats.GetSpilledCount())
+				}
+				if files, _ := os.ReadDir(fe.SpillDir); len(files) != 0 {
+					t.Errorf("spill file left behind: %v", files)
+				}
+			}
+		})
+	}
+}
+
+func TestEngineCancelMidStream(t *testing.T) {
+	for _, overflow := range []string{fuzzer.OverflowBlock, fuzzer.OverflowDrop, fuzzer.OverflowSpill} {
+		t.Run(overflow, func(t *testing.T) {
+			fe, url := newOverflowEngine(t, overflow)
+			done := feed(fe, url, 100000)
+
+			read := 0
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				for range fe.Results {
+					if read++; read == 10 {
+						fe.Cancel()
+					}
+					// A slow consumer, e.g. writing evidence to disk
+					time.Sleep(time.Millisecond)
+				}
+			}()
+
+			select {
+			case <-closed:
+			case <-time.After(10 * time.Second):
+				t.Fatal("Results not closed after Cancel")
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("feeder still blocked in Submit after Cancel")
+			}
+			if fe.Submit(&fuzzer.FuzzJob{URL: url}) {
+				t.Error("Submit after Cancel should fail")
+			}
+			fe.Stop() // after WaitAndClose, must not close Results again
+		})
+	}
+}
+
+func TestEngineCoverage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := "http://" + ln.Addr().String()
+	ln.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := &utils.Config{Scanner: utils.ScannerConfig{Threads: 100, Delay: "0s", RetryBackoff: "1ms"}}
+	fe := fuzzer.NewFuzzEngine(client.NewSmartClient(cfg), 4, nil)
+	fe.Start()
+	for i := 0; i < 10; i++ {
+		fe.Submit(&fuzzer.FuzzJob{ID: i, URL: server.URL + "/users/" + strconv.Itoa(i), Endpoint: "/users/{ID}", Method: "GET"})
+		if i < 4 {
+			fe.Submit(&fuzzer.FuzzJob{ID: 10 + i, URL: down + "/orders/" + strconv.Itoa(i), Endpoint: "/orders/{ID}", Method: "GET"})
+		}
+	}
+	fe.CloseQueue()
+	go fe.WaitAndClose()
+	for range fe.Results {
+	}
+
+	coverage := fe.Coverage()
+	if len(coverage) != 2 {
+		t.Fatalf("expected 2 endpoints, got %+v", coverage)
+	}
+	orders, users := coverage[0], coverage[1]
+	if orders.Planned != 4 || orders.Tested != 4 || orders.Failed != 4 || orders.Gaps() != 4 {
+		t.Errorf("expected the 4 orders jobs to have failed, got %+v", orders)
+	}
+	if users.Planned != 10 || users.Tested != 10 || users.Gaps() != 0 {
+		t.Errorf("expected the 10 users jobs to have been tested, got %+v", users)
+	}
+	if planned, gaps := fuzzer.CoverageGaps(coverage); planned != 14 || gaps != 4 {
+		t.Errorf("CoverageGaps = %d, %d", planned, gaps)
+	}
+}
@@ -1,9 +1,16 @@
 package tests
 
 import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 
+	"idorplus/pkg/analyzer"
+	"idorplus/pkg/fuzzer"
 	"idorplus/pkg/generator"
+	"idorplus/pkg/scanner"
 )
 
 func TestNumericGenerator(t *testing.T) {
@@ -39,6 +46,119 @@ func TestNumericGenerator(t *testing.T) {
 	}
 }
 
+func TestNumericRange(t *testing.T) {
+	ng := &generator.NumericGenerator{Start: 100, End: 120, Step: 5}
+	if got := strings.Join(ng.Generate(100), ","); got != "100,105,110,115,120" {
+		t.Errorf("unexpected range %s", got)
+	}
+
+	ng = &generator.NumericGenerator{Step: 7}
+	if got := ng.Generate(3); strings.Join(got[:3], ",") != "1,8,15" {
+		t.Errorf("unexpected stepped IDs %v", got[:3])
+	}
+
+	ng = &generator.NumericGenerator{Start: 1000000, End: 9000000, Sample: 50}
+	if err := ng.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	sample := ng.Generate(0)
+	prev := int64(999999)
+	for _, id := range sample {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || n <= prev || n > 9000000 {
+			t.Fatalf("expected distinct ascending IDs in the range, got %v", sample)
+		}
+		prev = n
+	}
+	if len(sample) != 50 {
+		t.Errorf("expected 50 IDs, got %d", len(sample))
+	}
+
+	for _, bad := range []*generator.NumericGenerator{
+		{Sample: 10},
+		{Start: 1, End: 9000000},
+		{Start: 10, End: 5},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", *bad)
+		}
+	}
+}
+
+func TestPayloadTemplates(t *testing.T) {
+	tmpl, err := generator.ParseTemplate("INV-{num:06d}-{num|md5|upper}")
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	if got := tmpl.Expand(42); got != "INV-000042-A1D0C6E83F027327D8461063F4AC58A6" {
+		t.Errorf("unexpected ID %s", got)
+	}
+
+	tmpl, _ = generator.ParseTemplate("user_{uuid}_{hex:4|base64}")
+	if got := tmpl.Expand(1); !regexp.MustCompile(`^user_[0-9a-f-]{36}_[A-Za-z0-9+/=]{8}$`).MatchString(got) {
+		t.Errorf("unexpected ID %s", got)
+	}
+
+	for _, bad := range []string{"INV-", "{num:06q}", "{serial}", "{num|rot13}", "{uuid:8}"} {
+		if _, err := generator.ParseTemplate(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+
+	pg := generator.NewPayloadGenerator(analyzer.TypeUUID)
+	pg.Template, _ = generator.ParseTemplate("ORD{num:04d}")
+	pg.Numeric.Start = 7
+	if payloads := pg.Generate(2); payloads[0] != "ORD0007" || payloads[1] != "ORD0008" {
+		t.Errorf("expected templated IDs first, got %v", payloads[:2])
+	}
+}
+
+func TestSpecialPayloads(t *testing.T) {
+	payloads := generator.NewPayloadGenerator(analyzer.TypeUUID).Generate(4)
+
+	seen := make(map[string]int)
+	for _, p := range payloads {
+		seen[p]++
+	}
+	array := `["` + payloads[0] + `","` + payloads[1] + `"]`
+	for _, want := range []string{"me", "self", "*", "all", "null", "", "0", "-1", `{"$ne":null}`, array} {
+		if seen[want] != 1 {
+			t.Errorf("expected %q once in the UUID payloads, got %d", want, seen[want])
+		}
+	}
+	// Numeric boundaries already include 0 and -1
+	seen = make(map[string]int)
+	for _, p := range generator.NewPayloadGenerator(analyzer.TypeNumeric).Generate(5) {
+		seen[p]++
+	}
+	if seen["0"] != 1 || seen["-1"] != 1 || seen["[1,2]"] != 1 {
+		t.Errorf("expected 0, -1 and [1,2] once in the numeric payloads, got %v", seen)
+	}
+
+	// A quoted placeholder takes null, arrays and objects as JSON values
+	job := &fuzzer.FuzzJob{Payload: `{"$ne":null}`}
+	if got := job.Interpolate(`{"user_id":"{ID}"}`); got != `{"user_id":{"$ne":null}}` {
+		t.Errorf("unexpected body %s", got)
+	}
+	job.Payload = "me"
+	if got := job.Interpolate(`{"user_id":"{ID}"}`); got != `{"user_id":"me"}` {
+		t.Errorf("unexpected body %s", got)
+	}
+
+	// Injections are opt-in, and destructive scans only get real IDs
+	if payloads := scanner.GeneratePayloads(scanner.Options{Count: 5}); slices.Contains(payloads, "[$ne]=1") {
+		t.Error("expected no injection payloads without Injection")
+	}
+	if payloads := scanner.GeneratePayloads(scanner.Options{Count: 5, Injection: true}); !slices.Contains(payloads, "[$ne]=1") {
+		t.Error("expected injection payloads with Injection")
+	}
+	for _, p := range scanner.GeneratePayloads(scanner.Options{Method: "DELETE", Count: 5, Injection: true}) {
+		if p == "*" || p == "all" || p == "null" || p == `{"$ne":null}` || strings.Contains(p, " OR ") || strings.HasPrefix(p, "[$") {
+			t.Errorf("expected no special payloads in a DELETE scan, got %q", p)
+		}
+	}
+}
+
 func TestEncodingEngine(t *testing.T) {
 	ee := generator.NewEncodingEngine()
 
@@ -66,6 +186,24 @@ func TestEncodingEngine(t *testing.T) {
 	}
 }
 
+func TestEncodingChains(t *testing.T) {
+	ee := generator.NewEncodingEngine()
+	if got := ee.EncodeChain("1", "base64+url"); got != "MQ%3D%3D" {
+		t.Errorf("base64+url of 1 = %s, want MQ%%3D%%3D", got)
+	}
+	if err := generator.ValidateEncoding("base64+rot13"); err == nil {
+		t.Error("expected an unknown encoding in a chain to be rejected")
+	}
+
+	pg := generator.NewPayloadGenerator(analyzer.TypeNumeric)
+	pg.Encodings = []string{"url", "base64+url"}
+	payloads := pg.Encode([]string{"1", "me too"})
+	want := []string{"1", "MQ%3D%3D", "me too", "me+too", "bWUgdG9v"}
+	if strings.Join(payloads, " | ") != strings.Join(want, " | ") {
+		t.Errorf("expected %q, got %q", want, payloads)
+	}
+}
+
 func TestUnicodeEncode(t *testing.T) {
 	ee := generator.NewEncodingEngine()
 
@@ -76,3 +214,130 @@ func TestUnicodeEncode(t *testing.T) {
 		t.Errorf("Unicode encode failed: got %s, want %s", result, expected)
 	}
 }
+
+func TestShiftContentType(t *testing.T) {
+	shifted, err := generator.ShiftContentType(`{"order_id": 42, "note": "a&b"}`, generator.FormatJSON)
+	if err != nil {
+		t.Fatalf("ShiftContentType failed: %v", err)
+	}
+
+	got := make(map[string]*generator.ShiftedBody)
+	for _, sb := range shifted {
+		got[sb.Format] = sb
+	}
+
+	if _, ok := got[generator.FormatJSON]; ok {
+		t.Error("Original format should not be included")
+	}
+	if got[generator.FormatForm].Body != "note=a%26b&order_id=42" {
+		t.Errorf("Unexpected form body: %s", got[generator.FormatForm].Body)
+	}
+	if got[generator.FormatXML].Body != "<root><note>a&amp;b</note><order_id>42</order_id></root>" {
+		t.Errorf("Unexpected XML body: %s", got[generator.FormatXML].Body)
+	}
+	if !strings.HasPrefix(got[generator.FormatMultipart].ContentType, "multipart/form-data; boundary=") {
+		t.Errorf("Unexpected multipart content type: %s", got[generator.FormatMultipart].ContentType)
+	}
+
+	// Round trip back to JSON keeps numbers unquoted
+	fields, err := generator.ParseBodyFields(got[generator.FormatXML].Body, generator.FormatXML)
+	if err != nil {
+		t.Fatalf("ParseBodyFields failed: %v", err)
+	}
+	sb, _ := generator.EncodeBody(fields, generator.FormatJSON)
+	if sb.Body != `{"note":"a&b","order_id":42}` {
+		t.Errorf("Unexpected JSON body: %s", sb.Body)
+	}
+}
+
+func TestStructuredIDs(t *testing.T) {
+	ia := analyzer.NewIdentifierAnalyzer()
+
+	dated := ia.LearnPattern("20240115-0042")
+	if dated.Type != analyzer.TypeDate || dated.Layout != "20060102" || dated.Counter != 42 || dated.Width != 4 {
+		t.Fatalf("unexpected pattern %+v", dated)
+	}
+	pg := generator.NewPayloadGenerator(dated.Type)
+	pg.Pattern = dated
+	pg.Special, pg.Injection = nil, nil
+	payloads := pg.Generate(14)
+	if len(payloads) != 14 || payloads[0] != "20240115-0041" || payloads[1] != "20240115-0042" || payloads[2] != "20240114-0041" {
+		t.Errorf("unexpected date payloads %v", payloads)
+	}
+
+	slashed := ia.LearnPattern("2024/01/15/42")
+	if slashed.Type != analyzer.TypeDate {
+		t.Fatalf("expected a date ID, got %+v", slashed)
+	}
+	if got := generator.NewDateGenerator(slashed).Generate(1); got[0] != "2024/01/15/42" {
+		t.Errorf("expected the layout kept, got %v", got)
+	}
+
+	composite := ia.LearnPattern("u17-042")
+	if composite.Type != analyzer.TypeComposite || len(composite.Parts) != 2 {
+		t.Fatalf("unexpected pattern %+v", composite)
+	}
+	got := generator.NewCompositeGenerator(composite).Generate(4)
+	want := []string{"u17-041", "u17-043", "u16-042", "u18-042"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("composite payloads = %v, want %v", got, want)
+	}
+
+	if p := ia.LearnPattern("random-string-here"); p.Type != analyzer.TypeUnknown {
+		t.Errorf("expected plain strings to stay unknown, got %+v", p)
+	}
+}
+
+func TestLearnedPayloads(t *testing.T) {
+	p, err := analyzer.NewIdentifierAnalyzer().LearnFromSamples([]string{"ORD-0100", "ORD-0110"})
+	if err != nil {
+		t.Fatalf("LearnFromSamples: %v", err)
+	}
+	pg := generator.NewPayloadGenerator(analyzer.TypeUnknown)
+	pg.Learned = p
+	pg.Special, pg.Injection = nil, nil
+
+	got := strings.Join(pg.Generate(5), " ")
+	if want := "ORD-0090 ORD-0120 ORD-0080 ORD-0130 ORD-0070"; got != want {
+		t.Errorf("payloads = %s, want %s", got, want)
+	}
+}
+
+func TestChecksumIDs(t *testing.T) {
+	ng := &generator.NumericGenerator{Start: 7992739871, End: 7992739873, Checksum: analyzer.ChecksumLuhn}
+	if err := ng.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	got := strings.Join(ng.Generate(0), " ")
+	if want := "79927398713 79927398721 79927398739"; got != want {
+		t.Errorf("luhn IDs = %s, want %s", got, want)
+	}
+
+	ng = &generator.NumericGenerator{Start: 123456, Checksum: analyzer.ChecksumMod97}
+	for _, id := range ng.Generate(5) {
+		if !analyzer.HasCheckDigits(id, analyzer.ChecksumMod97) {
+			t.Errorf("%s fails mod 97", id)
+		}
+	}
+	if ng.Format(123456) != "12345676" {
+		t.Errorf("unexpected mod 97 ID %s", ng.Format(123456))
+	}
+
+	if got := analyzer.DetectChecksum([]string{"12345676", "12345773"}); got != analyzer.ChecksumMod97 {
+		t.Errorf("expected mod97 detected, got %q", got)
+	}
+	if got := analyzer.DetectChecksum([]string{"79927398713"}); got != "" {
+		t.Errorf("a single ID can't tell a check digit from chance, got %q", got)
+	}
+	if err := (&generator.NumericGenerator{Checksum: "crc"}).Validate(); err == nil {
+		t.Error("expected an unknown checksum to be rejected")
+	}
+
+	tmpl, err := generator.ParseTemplate("ACC-{num:05d|luhn}")
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	if id := tmpl.Expand(42); id != "ACC-000422" {
+		t.Errorf("template ID = %s", id)
+	}
+}
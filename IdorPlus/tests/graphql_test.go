@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"testing"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/graphql"
+	"idorplus/pkg/utils"
+)
+
+// userQuery matches the user query of an operation and captures the ID
+var userQuery = regexp.MustCompile(`user\(id: "(\w+)"\)`)
+
+// graphqlServer answers user queries. The attacker owns user 1; a single
+// operation for another user is denied, one in an array batch is not
+// checked.
+func graphqlServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("sid"); err != nil || c.Value != "attacker" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		answer := func(op graphql.GraphQLQuery, authorize bool) map[string]interface{} {
+			id := userQuery.FindStringSubmatch(op.Query)[1]
+			if authorize && id != "1" {
+				return map[string]interface{}{"data": map[string]interface{}{"user": nil}, "errors": []map[string]string{{"message": "forbidden"}}}
+			}
+			return map[string]interface{}{"data": map[string]interface{}{"user": map[string]string{"id": id}}}
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var batch []graphql.GraphQLQuery
+		if json.Unmarshal(body, &batch) == nil {
+			var results []map[string]interface{}
+			for _, op := range batch {
+				results = append(results, answer(op, false))
+			}
+			json.NewEncoder(w).Encode(results)
+			return
+		}
+		var op graphql.GraphQLQuery
+		if err := json.Unmarshal(body, &op); err != nil {
+			t.Errorf("unexpected body %s", body)
+			return
+		}
+		json.NewEncoder(w).Encode(answer(op, true))
+	}))
+}
+
+func TestGraphQLBatchArray(t *testing.T) {
+	server := graphqlServer(t)
+	defer server.Close()
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	c := client.NewSmartClient(cfg)
+	c.GetSessionManager().AddSession("attacker", "sid=attacker")
+	gt := graphql.NewGraphQLTester(c, server.URL)
+	gt.Session = "attacker"
+
+	result, err := gt.TestBatchArray(context.Background(), "user", "id", []string{"1", "2", "3"})
+	if err != nil {
+		t.Fatalf("TestBatchArray: %v", err)
+	}
+	if !result.Supported || len(result.Operations) != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if op := result.Operations[0]; !op.Single || !op.Batched {
+		t.Errorf("expected the own user on both paths, got %+v", op)
+	}
+	if op := result.Operations[1]; op.Single || !op.Batched || op.Error != "forbidden" {
+		t.Errorf("expected user 2 denied alone and returned batched, got %+v", op)
+	}
+	if !slices.Equal(result.Bypassed, []string{"2", "3"}) {
+		t.Errorf("expected users 2 and 3 to bypass authorization, got %v", result.Bypassed)
+	}
+
+	// Servers answering an array with a single error don't support batching
+	single := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errors":[{"message":"Must provide query string."}]}`)
+	}))
+	defer single.Close()
+	result, err = graphql.NewGraphQLTester(c, single.URL).TestBatchArray(context.Background(), "user", "id", []string{"1"})
+	if err != nil || result.Supported {
+		t.Errorf("expected array batching to be unsupported, got %+v, %v", result, err)
+	}
+	// A single query answered with something else than GraphQL, e.g. a WAF
+	// page, is an error rather than a denied operation
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<html>Request blocked</html>")
+	}))
+	defer html.Close()
+	if result, err := graphql.NewGraphQLTester(c, html.URL).TestBatchArray(context.Background(), "user", "id", []string{"1"}); err == nil {
+		t.Errorf("expected an error for a response that is not GraphQL, got %+v", result)
+	}
+}
+
+func TestGraphQLAdaptiveBatching(t *testing.T) {
+	// At most limit operations per request
+	limit := 3
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var op graphql.GraphQLQuery
+		json.NewDecoder(r.Body).Decode(&op)
+		ids := userQuery.FindAllStringSubmatch(op.Query, -1)
+		sizes = append(sizes, len(ids))
+		if len(ids) > limit {
+			fmt.Fprintf(w, `{"errors":[{"message":"Query is too complex: %d","extensions":{"code":"MAX_COMPLEXITY"}}]}`, len(ids))
+			return
+		}
+		data := map[string]interface{}{}
+		for i, id := range ids {
+			data[fmt.Sprintf("q%d", i)] = map[string]string{"id": id[1]}
+		}
+		if len(ids) == 1 {
+			data = map[string]interface{}{"user": map[string]string{"id": ids[0][1]}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	gt := graphql.NewGraphQLTester(client.NewSmartClient(cfg), server.URL)
+	ids := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	found, err := gt.TestBatchIDOR(context.Background(), "user", "id", ids)
+	if err != nil {
+		t.Fatalf("TestBatchIDOR: %v", err)
+	}
+	if !slices.Equal(found, ids) {
+		t.Errorf("expected every ID through smaller batches, got %v", found)
+	}
+	// 10 refused, 5 refused, then batches of 2
+	if !slices.Equal(sizes, []int{10, 5, 2, 2, 2, 2, 2}) || gt.BatchSize != 2 {
+		t.Errorf("unexpected batch sizes %v, kept %d", sizes, gt.BatchSize)
+	}
+
+	// A limit even single queries exceed stops the test
+	limit = 0
+	if found, err := gt.TestBatchIDOR(context.Background(), "user", "id", ids); err == nil || len(found) != 0 {
+		t.Errorf("expected the refused single query to stop the test, got %v, %v", found, err)
+	}
+}
+
+func TestGraphQLFuzzVariable(t *testing.T) {
+	// Orders 1001 (the attacker's) and 1002 (the victim's) exist and are
+	// returned to any logged-in user
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var op graphql.GraphQLQuery
+		json.NewDecoder(r.Body).Decode(&op)
+		if _, err := r.Cookie("sid"); err != nil {
+			fmt.Fprint(w, `{"data":{"order":null},"errors":[{"message":"unauthenticated"}]}`)
+			return
+		}
+		input := op.Variables["input"].(map[string]interface{})
+		if input["tenant"] != "acme" {
+			t.Errorf("required variable lost: %v", op.Variables)
+		}
+		id := fmt.Sprint(input["orderId"])
+		if id != "1001" && id != "1002" {
+			fmt.Fprint(w, `{"data":{"order":null},"errors":[{"message":"not found"}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"order":{"id":%s,"total":42}}}`, id)
+	}))
+	defer server.Close()
+
+	op, err := graphql.LoadOperation([]byte(`{
+		"operationName": "Order",
+		"query": "query Order($input: OrderInput!) { order(input: $input) { id total } }",
+		"variables": {"input": {"tenant": "acme", "orderId": 1001}}
+	}`))
+	if err != nil {
+		t.Fatalf("LoadOperation: %v", err)
+	}
+	path := graphql.InjectionPoint(op)
+	if path != "input.orderId" {
+		t.Fatalf("expected input.orderId as the injection point, got %q", path)
+	}
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	c := client.NewSmartClient(cfg)
+	c.GetSessionManager().AddSession("attacker", "sid=attacker")
+	c.GetSessionManager().AddSession("victim", "sid=victim")
+	gt := graphql.NewGraphQLTester(c, server.URL)
+
+	results, err := gt.FuzzVariable(context.Background(), op, path, []string{"1001", "1002", "1003"}, []string{"attacker", "victim", ""})
+	if err != nil {
+		t.Fatalf("FuzzVariable: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if r := results[0]; r.Vulnerable || !r.Sessions["attacker"].Granted {
+		t.Errorf("the attacker's own order is not a finding: %+v", r)
+	}
+	if r := results[1]; !r.Vulnerable || r.Public || !r.Sessions["victim"].Granted || r.Sessions[""].Error != "unauthenticated" {
+		t.Errorf("expected the victim's order to be a finding: %+v", r)
+	}
+	if r := results[2]; r.Vulnerable || r.Sessions["attacker"].Error != "not found" {
+		t.Errorf("a missing order is not a finding: %+v", r)
+	}
+
+	// Mutations need AllowMutations and are only sent with canaries
+	op.Query = "mutation Order($input: OrderInput!) { cancelOrder(input: $input) { id } }"
+	if !op.IsMutation() {
+		t.Fatal("expected the operation to be a mutation")
+	}
+	if _, err := gt.FuzzVariable(context.Background(), op, path, []string{"1002"}, []string{"attacker"}); !errors.Is(err, client.ErrDestructive) {
+		t.Errorf("expected a mutation to be refused, got %v", err)
+	}
+	gt.AllowMutations, gt.Canaries = true, []string{"1001", "1003"}
+	results, err = gt.FuzzVariable(context.Background(), op, path, []string{"1002", "1003"}, []string{"attacker"})
+	if err != nil || len(results) != 1 || results[0].ID != "1003" {
+		t.Errorf("expected the mutation to be sent for the canary 1003 only, got %+v, %v", results, err)
+	}
+}
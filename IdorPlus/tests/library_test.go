@@ -0,0 +1,312 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"idorplus/pkg/idorplus"
+)
+
+func TestLibraryScan(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/orders/"))
+		if r.Header.Get("X-Tenant") != "acme" || id < 1 || id > 3 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"order":%d,"owner":"user%d@example.com"}`, id, id)
+	}))
+	defer target.Close()
+
+	idorplus.SetLogOutput(io.Discard)
+	defer idorplus.SetLogOutput(os.Stdout)
+
+	if _, err := idorplus.New(idorplus.Options{BypassMode: "bogus"}); err == nil {
+		t.Error("expected an error for an unknown bypass mode")
+	}
+
+	s, err := idorplus.New(idorplus.Options{IDs: []string{"1", "2", "3", "4", "5"}, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	findings, stats, err := s.Scan(context.Background(), idorplus.Target{
+		URL:     target.URL + "/orders/{ID}",
+		Headers: map[string]string{"X-Tenant": "acme"},
+		Cookies: "session=attacker",
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	if stats.Requests != 5 {
+		t.Errorf("expected 5 requests, got %d", stats.Requests)
+	}
+	if len(findings) != 3 {
+		t.Fatalf("expected 3 findings, got %d", len(findings))
+	}
+	f := findings[0]
+	if f.Endpoint != target.URL+"/orders/{ID}" || f.Fingerprint == "" || f.Severity == "" || len(f.PII["email"]) == 0 {
+		t.Errorf("incomplete finding: %+v", f)
+	}
+}
+
+func TestLibraryScanMassAssign(t *testing.T) {
+	var mu sync.Mutex
+	user := map[string]any{"id": 1, "name": "me", "role": "user"}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/1" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			json.NewEncoder(w).Encode(user)
+			mu.Unlock()
+			return
+		}
+		// Echoes every field but only stores the name and, mistakenly,
+		// the role
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		for _, k := range []string{"name", "role"} {
+			if v, ok := body[k]; ok {
+				user[k] = v
+			}
+		}
+		mu.Unlock()
+		body["id"] = 1
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer target.Close()
+
+	idorplus.SetLogOutput(io.Discard)
+	defer idorplus.SetLogOutput(os.Stdout)
+
+	s, err := idorplus.New(idorplus.Options{IDs: []string{"2"}, MassAssign: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	findings, _, err := s.Scan(context.Background(), idorplus.Target{
+		URL:     target.URL + "/users/1",
+		Method:  "POST",
+		Body:    `{"name":"me"}`,
+		Cookies: "session=attacker",
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	var accepted []string
+	for _, f := range findings {
+		if f.Type == "mass_assignment" {
+			accepted = append(accepted, f.Technique)
+		}
+	}
+	if len(accepted) != 1 || accepted[0] != "role=admin" {
+		t.Errorf("expected only role to be accepted, got %v", accepted)
+	}
+}
+
+func TestLibraryScanPollution(t *testing.T) {
+	owners := map[string]string{"1": "attacker", "2": "victim"}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := r.Cookie("session")
+		// The check reads the first id, the lookup the last
+		ids := r.URL.Query()["id"]
+		if len(ids) == 0 || session == nil || owners[ids[0]] != session.Value {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		id := ids[len(ids)-1]
+		if owners[id] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "owner": owners[id], "address": owners[id] + " street"})
+	}))
+	defer target.Close()
+
+	idorplus.SetLogOutput(io.Discard)
+	defer idorplus.SetLogOutput(os.Stdout)
+
+	s, err := idorplus.New(idorplus.Options{IDs: []string{"2", "3"}, Pollution: true, OwnID: "1"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	findings, _, err := s.Scan(context.Background(), idorplus.Target{
+		URL:           target.URL + "/orders?id={ID}",
+		Cookies:       "session=attacker",
+		VictimCookies: "session=victim",
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	var polluted []string
+	for _, f := range findings {
+		if f.Type == "param_pollution" {
+			polluted = append(polluted, f.ID+" "+f.Technique)
+		}
+	}
+	if len(polluted) != 1 || polluted[0] != "2 id: duplicate, own first" {
+		t.Errorf("expected only the own-first duplicate of ID 2, got %v", polluted)
+	}
+}
+
+func TestLibraryScanEstimateRange(t *testing.T) {
+	// Orders 70000-70400 exist, a few were deleted
+	var mu sync.Mutex
+	var posted []int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/orders/"))
+		if r.Method != http.MethodGet && err == nil {
+			mu.Lock()
+			posted = append(posted, id)
+			mu.Unlock()
+		}
+		if err != nil || id < 70000 || id > 70400 || id%50 == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"order":%d,"owner":"user%d@example.com"}`, id, id)
+	}))
+	defer target.Close()
+
+	idorplus.SetLogOutput(io.Discard)
+	defer idorplus.SetLogOutput(os.Stdout)
+
+	s, err := idorplus.New(idorplus.Options{Count: 20, EstimateRange: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	findings, _, err := s.Scan(context.Background(), idorplus.Target{
+		URL:     target.URL + "/orders/{ID}",
+		Cookies: "session=attacker",
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	// Sampled from the range, nearly every ID hits
+	if len(findings) < 15 {
+		t.Errorf("expected most of the 20 sampled IDs to exist, got %d findings", len(findings))
+	}
+	for _, f := range findings {
+		if id, _ := strconv.Atoi(f.ID); id < 70000 || id > 70400 {
+			t.Errorf("finding for ID %s outside the range", f.ID)
+		}
+	}
+
+	// The range of a POST endpoint is probed with GETs, the search above
+	// the highest ID never POSTs
+	findings, _, err = s.Scan(context.Background(), idorplus.Target{
+		URL:     target.URL + "/orders/{ID}",
+		Method:  "POST",
+		Body:    `{"fields":["total"]}`,
+		Cookies: "session=attacker",
+	})
+	if err != nil || len(findings) < 15 {
+		t.Fatalf("expected the POST scan to sample the range, got %d findings, %v", len(findings), err)
+	}
+	for _, id := range posted {
+		if id > 70400 && id < 1<<40 {
+			t.Errorf("expected the range to be probed with GET, got a POST for %d", id)
+		}
+	}
+}
+
+func TestLibraryScanAPIVersions(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the current version checks ownership
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v2/orders/") && id != "1":
+			w.WriteHeader(http.StatusForbidden)
+		case strings.HasPrefix(r.URL.Path, "/api/v1/orders/") || strings.HasPrefix(r.URL.Path, "/api/v2/orders/"):
+			fmt.Fprintf(w, `{"order":%s,"owner":"user%s"}`, id, id)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer target.Close()
+
+	idorplus.SetLogOutput(io.Discard)
+	defer idorplus.SetLogOutput(os.Stdout)
+
+	s, err := idorplus.New(idorplus.Options{IDs: []string{"2"}, APIVersions: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	findings, _, err := s.Scan(context.Background(), idorplus.Target{URL: target.URL + "/api/v2/orders/{ID}", Cookies: "session=attacker"})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	var versions []string
+	for _, f := range findings {
+		if f.Type == "api_version" {
+			versions = append(versions, f.Technique+" "+f.URL)
+		}
+	}
+	if len(versions) != 1 || versions[0] != "older version v1 "+target.URL+"/api/v1/orders/2" {
+		t.Errorf("expected the v1 bypass only, got %v", versions)
+	}
+}
+
+func TestLibraryScanBudget(t *testing.T) {
+	var mu sync.Mutex
+	hits := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/orders/"))
+		if id < 1 || id > 3 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"order":%d,"owner":"user%d@example.com"}`, id, id)
+	}))
+	defer target.Close()
+
+	idorplus.SetLogOutput(io.Discard)
+	defer idorplus.SetLogOutput(os.Stdout)
+
+	ids := make([]string, 30)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+	s, err := idorplus.New(idorplus.Options{IDs: ids, Concurrency: 4, MaxRequests: 12})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, stats, err := s.Scan(context.Background(), idorplus.Target{URL: target.URL + "/orders/{ID}", Cookies: "session=attacker"})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits > 12 {
+		t.Errorf("budget of 12 requests exceeded: the target got %d", hits)
+	}
+	if stats.BudgetExhausted == "" || stats.Untested == 0 {
+		t.Errorf("expected the scan to stop short with untested IDs, got %+v", stats)
+	}
+	if stats.Requests+stats.Untested != int64(len(ids)) {
+		t.Errorf("every ID should be tested or untested: %d requests, %d untested", stats.Requests, stats.Untested)
+	}
+	if stats.Planned != int64(len(ids)) || stats.Gaps != stats.Untested {
+		t.Errorf("expected the untested IDs as the coverage gaps, got %d of %d planned", stats.Gaps, stats.Planned)
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+func TestJSONLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idorplus.log")
+	if err := utils.InitLogger(utils.LogOptions{Format: "json", File: path}); err != nil {
+		t.Fatal(err)
+	}
+	defer utils.InitLogger(utils.LogOptions{})
+
+	utils.Debug.Printf("hidden without --debug\n")
+	utils.Info.Printf("Generated %d payloads\n", 3)
+	utils.Success.Println("\nNo vulnerabilities found")
+	utils.PrintVulnerable("http://target/users/2", 200)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("not a JSON line: %q", line)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d: %s", len(records), data)
+	}
+
+	if records[0]["level"] != "INFO" || records[0]["msg"] != "Generated 3 payloads" {
+		t.Errorf("unexpected info record %v", records[0])
+	}
+	if records[1]["msg"] != "No vulnerabilities found" || records[1]["status"] != "success" {
+		t.Errorf("unexpected success record %v", records[1])
+	}
+	if records[2]["level"] != "WARN" || records[2]["url"] != "http://target/users/2" || records[2]["status"] != float64(200) {
+		t.Errorf("unexpected finding record %v", records[2])
+	}
+}
+
+func TestSilentModeWritesFindingLines(t *testing.T) {
+	if err := utils.InitLogger(utils.LogOptions{Silent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if utils.Decorated() {
+		t.Error("silent mode should disable banner and progress bars")
+	}
+	if err := utils.InitLogger(utils.LogOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !utils.Decorated() {
+		t.Error("default mode should be decorated")
+	}
+
+	var out bytes.Buffer
+	write := reporter.JSONLines(&out)
+	write(&reporter.Finding{ID: "1", URL: "http://target/users/1", Severity: "high"})
+	write(&reporter.Finding{ID: "2", URL: "http://target/users/2", Severity: "high"})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", out.String())
+	}
+	var f reporter.Finding
+	if err := json.Unmarshal([]byte(lines[1]), &f); err != nil || f.URL != "http://target/users/2" {
+		t.Errorf("unexpected line %q: %v", lines[1], err)
+	}
+}
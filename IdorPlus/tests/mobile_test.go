@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"idorplus/pkg/crawler"
+	"idorplus/pkg/mobile"
+	"idorplus/pkg/utils"
+)
+
+// buildDex returns a DEX file holding only a string table
+func buildDex(strs []string) []byte {
+	header := make([]byte, 0x70)
+	copy(header, "dex\n035\x00")
+	binary.LittleEndian.PutUint32(header[0x38:], uint32(len(strs)))
+	binary.LittleEndian.PutUint32(header[0x3C:], 0x70)
+
+	ids := make([]byte, 4*len(strs))
+	var data []byte
+	at := 0x70 + len(ids)
+	for i, s := range strs {
+		binary.LittleEndian.PutUint32(ids[4*i:], uint32(at+len(data)))
+		data = append(data, byte(len(s)))
+		data = append(data, s...)
+		data = append(data, 0)
+	}
+	return append(append(header, ids...), data...)
+}
+
+func TestMobileAnalyzeAPK(t *testing.T) {
+	apk := filepath.Join(t.TempDir(), "app.apk")
+	f, err := os.Create(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	files := map[string][]byte{
+		"AndroidManifest.xml": []byte("\x03\x00\x08\x00binary xml"),
+		"classes.dex": buildDex([]string{
+			"https://api.target.com/v2/",
+			"users/{userId}/orders",
+			"invoices/%d/pdf",
+			"Lretrofit2/http/GET;",
+			"com/target/app/MainActivity",
+			"/proc/self/maps",
+			"http://schemas.android.com/apk/res/android",
+		}),
+		"assets/index.android.bundle": []byte(`fetch("/api/documents?doc_id=" + id)`),
+		"lib/arm64-v8a/libnative.so":  []byte("\x7fELF\x00\x00https://api.target.com/v2/internal/accounts/%s\x00\x01"),
+		"res/drawable/icon.png":       []byte("\x89PNG https://cdn.target.com/x"),
+	}
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+
+	app, err := mobile.Analyze(apk)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if app.Platform != mobile.PlatformAndroid {
+		t.Errorf("expected android, got %q", app.Platform)
+	}
+	if len(app.BaseURLs) == 0 || app.BaseURLs[0] != "https://api.target.com/v2" {
+		t.Errorf("expected the Retrofit base URL first, got %v", app.BaseURLs)
+	}
+	if slices.ContainsFunc(app.BaseURLs, func(b string) bool { return b == "http://schemas.android.com" || b == "https://cdn.target.com" }) {
+		t.Errorf("expected schema hosts and skipped files left out, got %v", app.BaseURLs)
+	}
+
+	var urls []string
+	for _, ep := range app.Candidates() {
+		urls = append(urls, ep.URL)
+	}
+	want := []string{
+		"/api/documents?doc_id=",
+		"/invoices/{id}/pdf",
+		"/users/{userId}/orders",
+		"https://api.target.com/v2/internal/accounts/{id}",
+	}
+	if !slices.Equal(urls, want) {
+		t.Errorf("expected candidates %v, got %v", want, urls)
+	}
+	for _, ep := range app.Endpoints() {
+		if ep.URL == "/proc/self/maps" || ep.URL == "/com/target/app/MainActivity" {
+			t.Errorf("expected no file system or class paths, got %s", ep.URL)
+		}
+	}
+
+	for ep, want := range map[string]string{
+		"/users/{userId}/orders/:orderId": "https://api.target.com/v2/users/{ID}/orders/1",
+		"/api/documents?doc_id=":          "https://api.target.com/v2/api/documents?doc_id={ID}",
+		"https://h.target.com/a/{id}":     "https://h.target.com/a/{ID}",
+	} {
+		if got, ok := mobile.TargetURL(app.BaseURLs[0], crawler.EndpointInfo{URL: ep}); !ok || got != want {
+			t.Errorf("TargetURL(%s) = %s, %v, want %s", ep, got, ok, want)
+		}
+	}
+	if _, ok := mobile.TargetURL("", crawler.EndpointInfo{URL: "/users/{id}"}); ok {
+		t.Error("expected a relative endpoint without a base not to be a target")
+	}
+
+	// Only endpoints on the host of the base are scanned
+	targets, offHost := mobile.Targets("https://api.target.com/v2", []crawler.EndpointInfo{
+		{URL: "/users/{id}"}, {URL: "/users/{id}"}, {URL: "https://API.target.com/v2/orders/{orderId}"},
+		{URL: "https://api.analytics.com/events/{eventId}"}, {URL: "/health"},
+	})
+	want = []string{"https://api.target.com/v2/users/{ID}", "https://API.target.com/v2/orders/{ID}"}
+	if !slices.Equal(targets, want) || offHost != 1 {
+		t.Errorf("expected targets %v and 1 off host, got %v, %d", want, targets, offHost)
+	}
+	if targets, _ := mobile.Targets("", []crawler.EndpointInfo{{URL: "/users/{id}"}}); len(targets) != 0 {
+		t.Errorf("expected no target without a base, got %v", targets)
+	}
+}
+
+func TestReplacePathParams(t *testing.T) {
+	var names []string
+	got := utils.ReplacePathParams("/users/:id/posts/{postId}/at/10:30", func(name string) string {
+		names = append(names, name)
+		return "1"
+	})
+	if want := "/users/1/posts/1/at/10:30"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if !slices.Equal(names, []string{"id", "postId"}) {
+		t.Errorf("expected parameters [id postId], got %v", names)
+	}
+}
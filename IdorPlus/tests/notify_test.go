@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"idorplus/pkg/notify"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/utils"
+)
+
+func TestNotifierMinSeverity(t *testing.T) {
+	var mu sync.Mutex
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]any
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		received = append(received, msg)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	n, err := notify.NewNotifier(utils.NotifyConfig{
+		MinSeverity: "HIGH",
+		Webhooks:    []utils.WebhookConfig{{Type: "slack", URL: server.URL}},
+	})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+
+	n.Notify(&reporter.Finding{ID: "1", Type: reporter.FindingIDOR, Severity: "MEDIUM", URL: "https://x/users/1"})
+	leak := &reporter.Finding{ID: "2", Type: reporter.FindingIDOR, Severity: "CRITICAL", URL: "https://x/users/2?api_key=k3y",
+		Evidence: "victim@example.com", PIIFound: map[string][]string{"email": {"victim@example.com"}}}
+	n.Notify(leak)
+	n.Close()
+
+	if len(received) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(received))
+	}
+	if text, _ := received[0]["text"].(string); !strings.Contains(text, "https://x/users/2?api_key=[redacted]") || !strings.Contains(text, "PII: email") {
+		t.Errorf("unexpected slack message: %v", received[0])
+	}
+	if leak.URL != "https://x/users/2?api_key=k3y" || leak.PIIFound["email"][0] != "victim@example.com" {
+		t.Errorf("the notified finding was modified: %+v", leak)
+	}
+
+	if _, err := notify.NewNotifier(utils.NotifyConfig{Webhooks: []utils.WebhookConfig{{Type: "irc", URL: "x"}}}); err == nil {
+		t.Error("expected error for unknown webhook type")
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+func TestScanPlanSendsNothing(t *testing.T) {
+	var hits int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer target.Close()
+
+	cfg := utils.DefaultConfig()
+	cfg.Detection.InvalidSamples = 1
+	c := client.NewSmartClient(cfg)
+	scope, _ := client.NewScope(nil, []string{"/users/3$"})
+	c.SetScope(scope)
+
+	sc := scanner.New(c, cfg, scanner.Options{
+		URL:      target.URL + "/users/{ID}",
+		Method:   "PUT",
+		Body:     `{"owner":"{ID}"}`,
+		Cookies:  "sid=attacker",
+		Bearer:   "tok",
+		Payloads: []string{"1", "2", "3"},
+
+		AllowDestructive: true,
+	})
+	plan, err := sc.Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	if hits != 0 {
+		t.Fatalf("dry run sent %d requests", hits)
+	}
+	if len(plan.Requests) != 6 || plan.Total() != 6 {
+		t.Fatalf("expected 2 probes, the invalid baseline and 3 fuzz requests, got %+v", plan.Requests)
+	}
+	// The destructive method is never repeated for probes and baselines
+	for _, req := range plan.Requests[:3] {
+		if req.Method != "GET" || req.Body != "" {
+			t.Errorf("expected %s to be a GET without body, got %s %q", req.Purpose, req.Method, req.Body)
+		}
+	}
+	fuzz := plan.Requests[3]
+	if fuzz.Method != "PUT" || fuzz.URL != target.URL+"/users/1" || fuzz.Body != `{"owner":"1"}` {
+		t.Errorf("unexpected request %s %s %s", fuzz.Method, fuzz.URL, fuzz.Body)
+	}
+	if fuzz.Header.Get("Cookie") != "sid=attacker" || fuzz.Header.Get("Authorization") != "Bearer tok" {
+		t.Errorf("session not resolved: %v", fuzz.Header)
+	}
+	if err := plan.Requests[5].Err; !errors.Is(err, client.ErrOutOfScope) {
+		t.Errorf("expected the excluded request to be out of scope, got %v", err)
+	}
+}
+
+func TestScanPlanNamedWordlists(t *testing.T) {
+	cfg := utils.DefaultConfig()
+	cfg.Detection.InvalidSamples = 1
+	c := client.NewSmartClient(cfg)
+
+	opts := scanner.Options{
+		URL:       "http://example.test/orgs/{ORG}/users/{ID}",
+		Method:    "GET",
+		Headers:   []string{"X-Tenant: {TENANT}"},
+		Payloads:  []string{"1", "2"},
+		Wordlists: map[string][]string{"ORG": {"acme", "globex", "initech"}, "TENANT": {"t1", "t2", "t3"}},
+	}
+	plan, err := scanner.New(c, cfg, opts).Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	// The probe, the invalid baseline, then 2 IDs x 3 orgs x 3 tenants
+	if plan.Total() != 2+18 {
+		t.Fatalf("expected 18 combinations, got %d requests", plan.Total())
+	}
+	last := plan.Requests[len(plan.Requests)-1]
+	if last.URL != "http://example.test/orgs/initech/users/2" || last.Header.Get("X-Tenant") != "t3" {
+		t.Errorf("unexpected request %s %v", last.URL, last.Header)
+	}
+
+	opts.Combine = scanner.CombineZip
+	plan, err = scanner.New(c, cfg, opts).Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan.Total() != 2+2 {
+		t.Fatalf("expected the lists zipped to 2 requests, got %d", plan.Total())
+	}
+	second := plan.Requests[3]
+	if second.URL != "http://example.test/orgs/globex/users/2" || second.Header.Get("X-Tenant") != "t2" {
+		t.Errorf("unexpected request %s %v", second.URL, second.Header)
+	}
+
+	opts.Wordlists = map[string][]string{"TEAM": {"a"}}
+	if _, err := scanner.New(c, cfg, opts).Plan(); err == nil {
+		t.Error("expected an error for a placeholder the request doesn't use")
+	}
+}
@@ -0,0 +1,519 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+func TestReportRoundTrip(t *testing.T) {
+	rep := reporter.NewReporter("json")
+	rep.AddFinding(&fuzzer.FuzzResult{
+		Job: &fuzzer.FuzzJob{
+			URL:     "https://api.example.com/orders",
+			Method:  "POST",
+			Payload: "42",
+			Body:    `{"order_id": {ID}}`,
+		},
+		StatusCode:   200,
+		IsVulnerable: true,
+	})
+	rep.AddCustomFinding(&reporter.Finding{Type: reporter.FindingPathBypass, URL: "https://api.example.com/API/orders/42"})
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := rep.GenerateReport(path); err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+
+	report, err := reporter.LoadReport(path)
+	if err != nil {
+		t.Fatalf("LoadReport failed: %v", err)
+	}
+
+	f := report.FindFinding("1")
+	if f == nil {
+		t.Fatal("Finding 1 not found")
+	}
+	if f.Request == nil || f.Request.Body != `{"order_id": 42}` {
+		t.Errorf("Recorded request body not interpolated: %+v", f.Request)
+	}
+
+	if dump := f.Request.Dump(); !strings.HasPrefix(dump, "POST /orders HTTP/1.1\nHost: api.example.com\n") {
+		t.Errorf("Unexpected request dump:\n%s", dump)
+	}
+
+	if f := report.FindFinding("2"); f == nil || f.Type != reporter.FindingPathBypass {
+		t.Errorf("Finding 2 = %+v, want path bypass finding", f)
+	}
+	if report.FindFinding("3") != nil {
+		t.Error("Unexpected finding 3")
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	rep := reporter.NewReporter("html")
+	rep.AddCustomFinding(&reporter.Finding{
+		URL:      "https://api.example.com/users/7",
+		Severity: "HIGH",
+		Evidence: `<script>alert(1)</script>{"email":"bob@example.com"}`,
+		PIIFound: map[string][]string{"email": {"bob@example.com"}},
+	})
+
+	path := filepath.Join(t.TempDir(), "report.html")
+	if err := rep.GenerateReport(path); err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+
+	if strings.Contains(out, "<script>alert(1)</script>") {
+		t.Error("Evidence was not escaped")
+	}
+	if !strings.Contains(out, "<mark>bob@example.com</mark>") {
+		t.Error("PII was not highlighted")
+	}
+}
+
+func TestReportScanInfo(t *testing.T) {
+	cfg := utils.DefaultConfig()
+	cfg.WAFBypass.Enabled, cfg.WAFBypass.Mode = true, "stealth"
+	opts := scanner.Options{
+		URL:        "https://api.example.com/users/{ID}?access_token=qtoken&view=full",
+		Method:     "POST",
+		Body:       `{"name":"{NAME}","password":"hunter2","session":"{ID}"}`,
+		Headers:    []string{"Authorization: Bearer s3cret", "X-Tenant: acme", "X-User: {ID}", "Cookie: sid=cookiesecret", "Proxy-Authorization: Basic proxysecret"},
+		Cookies:    "session=topsecret",
+		CookiesB:   "session=victimsecret",
+		IDStart:    100,
+		IDEnd:      200,
+		Encodings:  []string{"base64"},
+		VerbTamper: true,
+	}
+
+	rep := reporter.NewReporter("json")
+	rep.Scan = scanner.Info(opts, cfg)
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := rep.GenerateReport(path); err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"s3cret", "topsecret", "victimsecret", "qtoken", "hunter2", "cookiesecret", "proxysecret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("report contains the secret %q", secret)
+		}
+	}
+
+	report, err := reporter.LoadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://api.example.com/users/{ID}?access_token=[redacted]&view=full"; report.TargetURL != want {
+		t.Errorf("TargetURL = %q, want %q", report.TargetURL, want)
+	}
+	info := report.Scan
+	if info == nil {
+		t.Fatal("scan parameters missing from the report")
+	}
+	if want := []string{"Authorization: [redacted]", "X-Tenant: acme", "X-User: {ID}", "Cookie: [redacted]", "Proxy-Authorization: [redacted]"}; strings.Join(info.Headers, "|") != strings.Join(want, "|") {
+		t.Errorf("Headers = %v, want %v", info.Headers, want)
+	}
+	if want := `{"name":"{NAME}","password":"[redacted]","session":"{ID}"}`; info.Body != want {
+		t.Errorf("Body = %s, want %s", info.Body, want)
+	}
+	if strings.Join(info.Sessions, ",") != "attacker,victim" || info.Bypass != "stealth" || strings.Join(info.Checks, ",") != "verb_tamper" {
+		t.Errorf("unexpected scan info: %+v", info)
+	}
+	if info.Payloads.IDRange != "100-200" || info.Payloads.Encodings[0] != "base64" {
+		t.Errorf("unexpected payload info: %+v", info.Payloads)
+	}
+
+	rep.Format = "markdown"
+	md := filepath.Join(t.TempDir(), "report.md")
+	if err := rep.GenerateReport(md); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(md); !strings.Contains(string(data), "| Bypass mode | `stealth` |") {
+		t.Errorf("scan parameters missing from the markdown report:\n%s", data)
+	}
+}
+
+func TestCurlAndBurpExport(t *testing.T) {
+	rr := &reporter.RecordedRequest{
+		Method:  "POST",
+		URL:     "https://api.example.com/users/7?x=1",
+		Headers: map[string]string{"Cookie": "session=a'b", "Accept-Encoding": "gzip, deflate, br, zstd"},
+		Body:    `{"id": 7}`,
+	}
+
+	want := `curl -i -s -k -X 'POST' --compressed -H 'Cookie: session=a'\''b' --data-binary '{"id": 7}' 'https://api.example.com/users/7?x=1'`
+	if got := rr.Curl(); got != want {
+		t.Errorf("Curl() = %s, want %s", got, want)
+	}
+
+	rep := reporter.NewReporter("json")
+	rep.AddCustomFinding(&reporter.Finding{
+		Type: reporter.FindingPathBypass, URL: rr.URL, Method: "POST",
+		StatusCode: 200, Severity: "HIGH", Request: rr,
+	})
+
+	path := filepath.Join(t.TempDir(), "issues.xml")
+	if err := rep.ExportBurp(path); err != nil {
+		t.Fatalf("ExportBurp: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, s := range []string{"<issues ", "<host ip=\"\">https://api.example.com</host>",
+		"<path>/users/7?x=1</path>", "<severity>High</severity>", `<request method="POST" base64="true">`} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("Burp XML missing %q", s)
+		}
+	}
+}
+
+func TestRedactedReport(t *testing.T) {
+	rep := reporter.NewReporter("json")
+	rep.Redact = true
+	finding := &reporter.Finding{
+		URL:      "https://api.example.com/users/7",
+		Severity: "HIGH",
+		Evidence: `{"email":"bob@example.com","alt":"bob@example.org","ssn":"123-45-6789"}`,
+		PIIFound: map[string][]string{"email": {"bob@example.com", "bob@example.org"}, "ssn": {"123-45-6789"}},
+	}
+	rep.AddCustomFinding(finding)
+	fingerprint := reporter.Fingerprint(finding)
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := rep.GenerateReport(path); err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+	report, err := reporter.LoadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, leaked := range []string{"bob@example.com", "bob@example.org", "123-45-6789"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("report still contains %q", leaked)
+		}
+	}
+
+	f := report.Findings[0]
+	if want := `{"email":"[REDACTED email]","alt":"[REDACTED email]","ssn":"[REDACTED ssn]"}`; f.Evidence != want {
+		t.Errorf("Evidence = %s, want %s", f.Evidence, want)
+	}
+	if len(f.PIIFound["email"]) != 2 || len(f.PIIFound["ssn"]) != 1 {
+		t.Errorf("expected the PII types and counts kept, got %v", f.PIIFound)
+	}
+	if f.Fingerprint != fingerprint {
+		t.Errorf("fingerprint changed by redaction: %s, want %s", f.Fingerprint, fingerprint)
+	}
+	if rep.Findings[0].Evidence != finding.Evidence {
+		t.Error("redaction modified the recorded finding")
+	}
+}
+
+func TestReportTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "report.md.tmpl")
+	os.WriteFile(tmpl, []byte(`# ACME Security Assessment
+{{.VulnCount}} findings
+{{range .Findings}}## {{upper .Severity}} {{.URL}}
+Remediation: {{remediation .Type}}
+{{end}}`), 0644)
+
+	rep := reporter.NewReporter("markdown")
+	if err := rep.LoadTemplate(tmpl); err != nil {
+		t.Fatalf("LoadTemplate failed: %v", err)
+	}
+	rep.AddCustomFinding(&reporter.Finding{Type: reporter.FindingMassAssign, URL: "https://api.example.com/users/7", Severity: "high"})
+
+	path := filepath.Join(dir, "report.md")
+	if err := rep.GenerateReport(path); err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "# ACME Security Assessment\n1 findings\n## HIGH https://api.example.com/users/7\nRemediation: " +
+		reporter.Remediation(reporter.FindingMassAssign) + "\n"
+	if string(data) != want {
+		t.Errorf("report =\n%s\nwant\n%s", data, want)
+	}
+
+	os.WriteFile(tmpl, []byte("{{range .Findings}}{{highlight .Evidence .PIIFound}}{{end}}"), 0644)
+	if err := rep.LoadTemplate(tmpl); err == nil {
+		t.Error("expected highlight to be undefined in markdown templates")
+	}
+	rep = reporter.NewReporter("html")
+	if err := rep.LoadTemplate(tmpl); err != nil {
+		t.Errorf("LoadTemplate of an HTML template failed: %v", err)
+	}
+	if err := rep.LoadTemplate(filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("expected an error for a missing template")
+	}
+}
+
+func TestMultiHostReport(t *testing.T) {
+	rep := reporter.NewReporter("markdown")
+	rep.Targets = []string{"https://a.example.com/users/{ID}", "https://b.example.com/orders/{ID}", "https://c.example.com/items/{ID}"}
+	rep.AddCustomFinding(&reporter.Finding{Type: reporter.FindingIDOR, URL: "https://a.example.com/users/1", Method: "GET", Payload: "1", Severity: "HIGH"})
+	rep.AddCustomFinding(&reporter.Finding{Type: reporter.FindingIDOR, URL: "https://b.example.com/orders/2", Method: "GET", Payload: "2", Severity: "CRITICAL"})
+	rep.AddCustomFinding(&reporter.Finding{Type: reporter.FindingVerbTamper, URL: "https://b.example.com/orders/3", Method: "DELETE", Payload: "3", Severity: "HIGH"})
+
+	dir := t.TempDir()
+	if err := rep.GenerateReport(filepath.Join(dir, "report.md")); err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "report.md"))
+	md := string(data)
+	for _, want := range []string{"3 findings on 2 of 3 hosts (1 critical, 2 high). Most exposed: b.example.com with 2 findings", "idor was found on 2 hosts"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("executive summary should contain %q:\n%s", want, md)
+		}
+	}
+	a, b, c := strings.Index(md, "## a.example.com"), strings.Index(md, "## b.example.com"), strings.Index(md, "## c.example.com")
+	if a < 0 || b < 0 || c < 0 || !(b < a && a < c) {
+		t.Errorf("expected host sections, most exposed first:\n%s", md)
+	}
+
+	rep.Format = "html"
+	if err := rep.GenerateReport(filepath.Join(dir, "report.html")); err != nil {
+		t.Fatalf("HTML report failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "report.html")); !strings.Contains(string(data), `<h2 class="host">c.example.com</h2>`) {
+		t.Error("HTML report has no host sections")
+	}
+
+	rep.Format = "json"
+	if err := rep.GenerateReport(filepath.Join(dir, "report.json")); err != nil {
+		t.Fatal(err)
+	}
+	report, err := reporter.LoadReport(filepath.Join(dir, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Hosts) != 3 || report.Hosts[0].Host != "b.example.com" || report.Hosts[0].Endpoints != 2 || report.Hosts[2].Endpoints != 0 {
+		t.Errorf("unexpected host sections: %+v", report.Hosts)
+	}
+	if report.Summary == nil || report.Summary.AffectedHosts != 2 || report.Summary.TypeHosts[reporter.FindingIDOR] != 2 {
+		t.Errorf("unexpected summary: %+v", report.Summary)
+	}
+
+	single := reporter.NewReporter("json")
+	single.AddCustomFinding(&reporter.Finding{URL: "https://a.example.com/users/1", Severity: "HIGH"})
+	if err := single.GenerateReport(filepath.Join(dir, "single.json")); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := reporter.LoadReport(filepath.Join(dir, "single.json")); report.Hosts != nil || report.Summary != nil {
+		t.Error("a single host report should have no host sections")
+	}
+}
+
+func TestPublishGitHub(t *testing.T) {
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+	if !reporter.InGitHubActions() {
+		t.Fatal("GitHub Actions not detected")
+	}
+
+	rep := reporter.NewReporter("json")
+	rep.AddCustomFinding(&reporter.Finding{Type: reporter.FindingIDOR, URL: "https://api.example.com/users/7", Method: "GET", Payload: "7", StatusCode: 200, Severity: "MEDIUM"})
+	rep.AddCustomFinding(&reporter.Finding{Type: reporter.FindingVerbTamper, URL: "https://api.example.com/a,b/1", Method: "DELETE", Payload: "1", StatusCode: 204, Severity: "CRITICAL"})
+
+	var annotations strings.Builder
+	if err := rep.PublishGitHub(&annotations); err != nil {
+		t.Fatalf("PublishGitHub failed: %v", err)
+	}
+	want := "::error title=CRITICAL verb_tamper::DELETE https://api.example.com/a,b/1 returned 204 for ID 1\n" +
+		"::warning title=MEDIUM idor::GET https://api.example.com/users/7 returned 200 for ID 7\n"
+	if annotations.String() != want {
+		t.Errorf("annotations =\n%s\nwant\n%s", annotations.String(), want)
+	}
+
+	data, err := os.ReadFile(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"**2 vulnerabilities found**", "| 1 | 0 | 1 | 0 |", "| CRITICAL | verb_tamper | DELETE |"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("step summary should contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestDedupFindings(t *testing.T) {
+	var findings []*reporter.Finding
+	for _, id := range []string{"3", "1", "2", "7", "5", "6"} {
+		findings = append(findings, &reporter.Finding{
+			Type: reporter.FindingIDOR, Method: "GET", Payload: id,
+			URL: "https://api.example.com/users/" + id, Endpoint: "https://api.example.com/users/{ID}",
+			StatusCode: 200, Severity: "HIGH", Evidence: `{"id":` + id + `,"email":"u` + id + `@x.com"}`,
+		})
+	}
+	// Different response shape on the same endpoint stays separate
+	findings = append(findings, &reporter.Finding{
+		Type: reporter.FindingIDOR, Method: "GET", Payload: "9",
+		URL: "https://api.example.com/users/9", Endpoint: "https://api.example.com/users/{ID}",
+		StatusCode: 200, Severity: "MEDIUM", Evidence: `{"error":"partial"}`,
+	})
+
+	deduped := reporter.DedupFindings(findings)
+	if len(deduped) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(deduped))
+	}
+	if len(deduped[0].AffectedIDs) != 6 {
+		t.Errorf("expected 6 affected IDs, got %v", deduped[0].AffectedIDs)
+	}
+	if want := "1-3, 5-7 (6 IDs)"; deduped[0].IDRange != want {
+		t.Errorf("IDRange = %q, want %q", deduped[0].IDRange, want)
+	}
+	if deduped[1].AffectedIDs != nil {
+		t.Errorf("single finding should not be grouped: %v", deduped[1].AffectedIDs)
+	}
+}
+
+func TestFindingScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		finding  reporter.Finding
+		vector   string
+		score    float64
+		severity string
+	}{
+		{
+			name: "authenticated read with PII",
+			finding: reporter.Finding{Method: "GET", PIIFound: map[string][]string{"email": {"a@b.c"}},
+				Request: &reporter.RecordedRequest{Headers: map[string]string{"Cookie": "s=1"}}},
+			vector: "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N", score: 6.5, severity: "MEDIUM",
+		},
+		{
+			name:    "unauthenticated delete",
+			finding: reporter.Finding{Method: "DELETE"},
+			vector:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:H/A:L", score: 8.2, severity: "HIGH",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.finding
+			f.Score()
+			if f.CVSSVector != tt.vector || f.CVSSScore != tt.score || f.Severity != tt.severity {
+				t.Errorf("got %s %.1f %s, want %s %.1f %s", f.CVSSVector, f.CVSSScore, f.Severity, tt.vector, tt.score, tt.severity)
+			}
+			if f.OWASP[0] != reporter.OWASPBOLA {
+				t.Errorf("expected BOLA category first, got %v", f.OWASP)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	findings := []*reporter.Finding{{Severity: "MEDIUM"}, {Severity: "HIGH"}}
+	tests := []struct {
+		name       string
+		findings   []*reporter.Finding
+		failOn     string
+		incomplete bool
+		saveFailed bool
+		want       int
+	}{
+		{name: "clean", findings: findings, want: reporter.ExitClean},
+		{name: "below threshold", findings: findings, failOn: "critical", want: reporter.ExitClean},
+		{name: "at threshold", findings: findings, failOn: "high", want: reporter.ExitFindings},
+		{name: "interrupted", findings: findings, failOn: "critical", incomplete: true, want: reporter.ExitIncomplete},
+		{name: "interrupted without fail-on", incomplete: true, want: reporter.ExitIncomplete},
+		{name: "interrupted with findings", findings: findings, failOn: "medium", incomplete: true, want: reporter.ExitFindings},
+		{name: "report not saved", findings: findings, failOn: "low", incomplete: true, saveFailed: true, want: reporter.ExitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reporter.ExitCode(tt.findings, tt.failOn, tt.incomplete, tt.saveFailed); got != tt.want {
+				t.Errorf("got exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	rep := reporter.NewReporter("json")
+	if rep.Incomplete() {
+		t.Error("a new report is not incomplete")
+	}
+	rep.Budget = &fuzzer.BudgetReport{Reason: "max requests"}
+	if !rep.Incomplete() {
+		t.Error("a report cut short by its budget is incomplete")
+	}
+	rep.Budget, rep.Interrupted = nil, true
+	if !rep.Incomplete() {
+		t.Error("an interrupted report is incomplete")
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	finding := func(endpoint, id string) *reporter.Finding {
+		return &reporter.Finding{
+			Type: reporter.FindingIDOR, Method: "GET", Payload: id, StatusCode: 200,
+			URL: strings.Replace(endpoint, "{ID}", id, 1), Endpoint: endpoint,
+		}
+	}
+	older := &reporter.Report{Findings: []*reporter.Finding{
+		finding("https://x/users/{ID}", "1"),
+		finding("https://x/orders/{ID}", "5"),
+	}}
+	newer := &reporter.Report{Findings: []*reporter.Finding{
+		finding("https://x/users/{ID}", "2"),
+		finding("https://x/users/{ID}", "3"),
+		finding("https://x/invoices/{ID}", "9"),
+	}}
+
+	diff := reporter.DiffReports(older, newer)
+	if len(diff.New) != 1 || diff.New[0].Endpoint != "https://x/invoices/{ID}" {
+		t.Errorf("unexpected new findings: %+v", diff.New)
+	}
+	if len(diff.Fixed) != 1 || diff.Fixed[0].Endpoint != "https://x/orders/{ID}" {
+		t.Errorf("unexpected fixed findings: %+v", diff.Fixed)
+	}
+	if len(diff.Persisting) != 1 || len(diff.Persisting[0].AffectedIDs) != 2 {
+		t.Errorf("unexpected persisting findings: %+v", diff.Persisting)
+	}
+}
+
+func TestReportStatistics(t *testing.T) {
+	st := fuzzer.NewStats()
+	st.Record("https://api.example.com/users/{ID}", &fuzzer.FuzzResult{StatusCode: 200, ContentLen: 2048}, 80*time.Millisecond)
+	st.Record("https://api.example.com/users/{ID}", &fuzzer.FuzzResult{StatusCode: 429}, 20*time.Millisecond)
+
+	dir := t.TempDir()
+	for _, format := range []string{"markdown", "html", "json"} {
+		rep := reporter.NewReporter(format)
+		rep.Statistics = st.Breakdown()
+		path := filepath.Join(dir, "report."+format)
+		if err := rep.GenerateReport(path); err != nil {
+			t.Fatalf("%s: GenerateReport failed: %v", format, err)
+		}
+		data, _ := os.ReadFile(path)
+		if format == "json" {
+			report, err := reporter.LoadReport(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Statistics == nil || report.Statistics.StatusCodes[429] != 1 || report.Statistics.Bytes != 2048 {
+				t.Errorf("statistics not kept in the JSON report: %+v", report.Statistics)
+			}
+			continue
+		}
+		for _, want := range []string{"Statistics", "429", "2.0 kB", "users/{ID}"} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s report lacks %q", format, want)
+			}
+		}
+	}
+}
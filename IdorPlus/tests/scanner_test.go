@@ -0,0 +1,543 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/detector"
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/utils"
+)
+
+func TestScanResume(t *testing.T) {
+	var mu sync.Mutex
+	var fuzzed []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/users/")
+		n, _ := strconv.Atoi(id)
+		if n >= 1 && n <= 20 {
+			mu.Lock()
+			fuzzed = append(fuzzed, id)
+			mu.Unlock()
+		}
+		if n < 1 || n > 3 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%d,"email":"user%d@example.com"}`, n, n)
+	}))
+	defer target.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	cfg.Detection.InvalidSamples = 1
+	cfg.Detection.Confirmations = 0
+	var payloads []string
+	for i := 1; i <= 20; i++ {
+		payloads = append(payloads, strconv.Itoa(i))
+	}
+	opts := scanner.Options{
+		URL:      target.URL + "/users/{ID}",
+		Cookies:  "sid=attacker",
+		Payloads: payloads,
+		Threads:  1,
+		// The probe would send the first payload again
+		NoClassify: true,
+	}
+
+	// Interrupted after 8 results
+	sc := scanner.New(client.NewSmartClient(cfg), cfg, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := 0
+	sc.OnResult = func(*fuzzer.FuzzResult) {
+		if results++; results == 8 {
+			cancel()
+		}
+	}
+	if err := sc.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the scan to be cancelled, got %v", err)
+	}
+	st := sc.ResumeState()
+	if st == nil || st.Total != 20 || st.Tested() < 8 || len(st.Payloads) == 0 {
+		t.Fatalf("unexpected resume state %+v", st)
+	}
+	if len(st.Findings) != 3 {
+		t.Fatalf("expected the 3 findings so far in the resume state, got %d", len(st.Findings))
+	}
+
+	path := filepath.Join(t.TempDir(), "scan.resume.json")
+	if err := scanner.SaveResume(path, st); err != nil {
+		t.Fatalf("SaveResume: %v", err)
+	}
+	loaded, err := scanner.LoadResume(path)
+	if err != nil {
+		t.Fatalf("LoadResume: %v", err)
+	}
+
+	// The resumed scan only fuzzes what was left
+	mu.Lock()
+	fuzzed = nil
+	mu.Unlock()
+	sc = scanner.New(client.NewSmartClient(cfg), cfg, opts)
+	sc.Resume = loaded
+	if err := sc.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	mu.Lock()
+	slices.SortFunc(fuzzed, func(a, b string) int {
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	})
+	if !slices.Equal(fuzzed, loaded.Payloads) {
+		t.Errorf("resumed scan fuzzed %v, want %v", fuzzed, loaded.Payloads)
+	}
+	mu.Unlock()
+	if st := sc.ResumeState(); st.Total != 20 || len(st.Payloads) != 0 {
+		t.Errorf("expected nothing left after the resumed scan, got %+v", st)
+	}
+
+	// A resume state only continues the scan it was saved from
+	opts.URL = target.URL + "/accounts/{ID}"
+	sc = scanner.New(client.NewSmartClient(cfg), cfg, opts)
+	sc.Resume = loaded
+	if err := sc.Run(context.Background()); err == nil {
+		t.Error("expected a resume state of another target to be refused")
+	}
+}
+
+func TestScanCancelDuringBaselines(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer target.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Timeout = "30s"
+	cfg.Scanner.MaxRetries = 0
+	sc := scanner.New(client.NewSmartClient(cfg), cfg, scanner.Options{
+		URL:      target.URL + "/users/{ID}",
+		Payloads: []string{"1"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := sc.Run(ctx)
+	if err == nil {
+		t.Fatal("expected the hanging baseline to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("baseline request ran on for %s after the scan was cancelled", elapsed)
+	}
+}
+
+func TestScanClassifiesEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/assets/") {
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, "body{}")
+			return
+		}
+		if r.URL.Path == "/login" {
+			fmt.Fprint(w, "<form>")
+			return
+		}
+		if !strings.Contains(r.Header.Get("Cookie"), "sid=attacker") {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		// Every user has a list of orders, empty for most
+		if r.URL.Path == "/users/2/orders" {
+			fmt.Fprint(w, `{"data":[{"id":1,"email":"bob@example.com"},{"id":2,"email":"bob@example.com"},{"id":3,"email":"bob@example.com"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	defer target.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	cfg.Detection.InvalidSamples = 1
+	cfg.Detection.Confirmations = 0
+	run := func(opts scanner.Options) (*scanner.Scanner, error) {
+		sc := scanner.New(client.NewSmartClient(cfg), cfg, opts)
+		return sc, sc.Run(context.Background())
+	}
+
+	// Static assets are probed, not fuzzed
+	sc, err := run(scanner.Options{URL: target.URL + "/assets/{ID}.css", Payloads: []string{"1", "2", "3"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if sc.Class == nil || sc.Class.Kind != "static" || sc.Engine != nil || requests != 1 {
+		t.Errorf("expected a single probe of the static asset, got %d requests, class %v", requests, sc.Class)
+	}
+
+	// Without credentials the login redirect fails the scan
+	if _, err := run(scanner.Options{URL: target.URL + "/users/{ID}/orders", Payloads: []string{"1"}}); err == nil {
+		t.Error("expected a login redirect without credentials to fail the scan")
+	}
+
+	// A collection's empty lists are not findings, the full one is
+	sc, err = run(scanner.Options{URL: target.URL + "/users/{ID}/orders", Cookies: "sid=attacker", Payloads: []string{"1", "2", "3"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if sc.Class == nil || sc.Class.Kind != "collection" || !sc.Class.AuthRequired {
+		t.Fatalf("expected an auth-required collection, got %v", sc.Class)
+	}
+	findings := sc.Reporter.Snapshot()
+	if len(findings) != 1 || findings[0].Payload != "2" {
+		t.Errorf("expected only the full list as a finding, got %d findings", len(findings))
+	}
+
+	// Cookies given with -H are not sent by the probe without credentials
+	sc, err = run(scanner.Options{URL: target.URL + "/users/{ID}/orders", Cookies: "theme=dark", Headers: []string{"Cookie: sid=attacker"}, Payloads: []string{"1"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if sc.Class == nil || !sc.Class.AuthRequired || sc.Class.Public {
+		t.Errorf("expected the endpoint to require credentials, got %v", sc.Class)
+	}
+}
+
+func TestScanPagination(t *testing.T) {
+	orders := []map[string]int{
+		{"id": 31, "user_id": 3}, {"id": 30, "user_id": 3}, {"id": 20, "user_id": 2},
+		{"id": 11, "user_id": 1}, {"id": 10, "user_id": 1},
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "sid=attacker" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// The owner filter is trusted and optional, paging stays within it
+		q := r.URL.Query()
+		limit, err := strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			limit = 20
+		}
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		var list []map[string]int
+		for _, o := range orders {
+			if uid := q.Get("user_id"); uid == "" || uid == strconv.Itoa(o["user_id"]) {
+				list = append(list, o)
+			}
+		}
+		list = list[min(offset, len(list)):]
+		list = list[:min(limit, len(list))]
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": list})
+	}))
+	defer target.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	cfg.Detection.InvalidSamples = 1
+	opts := scanner.Options{
+		URL:        target.URL + "/orders?user_id={ID}&limit=2",
+		Cookies:    "sid=attacker",
+		Payloads:   []string{"1"},
+		OwnID:      "1",
+		Pagination: true,
+	}
+	sc := scanner.New(client.NewSmartClient(cfg), cfg, opts)
+	if err := sc.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var techniques []string
+	for _, f := range sc.Reporter.Snapshot() {
+		if f.Type == reporter.FindingPagination {
+			techniques = append(techniques, f.Technique)
+		}
+	}
+	slices.Sort(techniques)
+	want := []string{"user_id: filter emptied", "user_id: filter removed"}
+	if !slices.Equal(techniques, want) {
+		t.Errorf("expected only the owner filter to leak, got %v", techniques)
+	}
+
+	// A filter whose value happens to be the attacker's ID, on records
+	// without an owner field, is not known to scope them to their owner
+	items := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list := []map[string]int{{"id": 1, "status": 1}}
+		if r.URL.Query().Get("status") == "" {
+			list = append(list, map[string]int{"id": 2, "status": 2})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": list})
+	}))
+	defer items.Close()
+	result := detector.NewPaginationTester(client.NewSmartClient(cfg)).Test(context.Background(), "GET", items.URL+"/items?status=1", nil, "", "1")
+	if result.OwnerField != "" || result.IsVulnerable {
+		t.Errorf("expected no finding without an owner field, got %q, %v", result.OwnerField, result.IsVulnerable)
+	}
+}
+
+func TestScanMethods(t *testing.T) {
+	var mu sync.Mutex
+	sent := make(map[string]int)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent[r.Method]++
+		mu.Unlock()
+		switch r.Method {
+		case http.MethodOptions:
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, DELETE")
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			fmt.Fprintf(w, `{"id":%q}`, strings.TrimPrefix(r.URL.Path, "/notes/"))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer target.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	cfg.Detection.InvalidSamples = 1
+	run := func(method string) *scanner.Scanner {
+		sc := scanner.New(client.NewSmartClient(cfg), cfg, scanner.Options{
+			URL:      target.URL + "/notes/{ID}",
+			Method:   method,
+			Body:     map[string]string{"POST": `{"id":"{ID}"}`}[method],
+			Payloads: []string{"1", "2"},
+			Methods:  true,
+		})
+		if err := sc.Run(context.Background()); err != nil {
+			t.Fatalf("Run %s: %v", method, err)
+		}
+		return sc
+	}
+
+	sc := run("GET")
+	if sc.Methods == nil || !slices.Equal(sc.Methods.Methods(), []string{"DELETE", "GET", "HEAD", "OPTIONS"}) {
+		t.Fatalf("expected the Allow and CORS methods, got %+v", sc.Methods)
+	}
+	if got := sc.Reporter.Scan.UntestedMethods; !slices.Equal(got, []string{"DELETE"}) {
+		t.Errorf("expected DELETE to be left untested, got %v", got)
+	}
+	if sent[http.MethodGet] < 2 {
+		t.Errorf("expected the GET scan to fuzz, got %v", sent)
+	}
+
+	run("POST")
+	if sent[http.MethodPost] != 0 {
+		t.Errorf("expected POST, which isn't allowed, not to be fuzzed, got %d requests", sent[http.MethodPost])
+	}
+}
+
+func TestScanCORS(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The origin check is a substring match on the API's host
+		if origin := r.Header.Get("Origin"); strings.Contains(origin, "127.0.0.1") {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Header.Get("Cookie") != "sid=attacker" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/users/1":
+			fmt.Fprint(w, `{"id":1,"name":"attacker"}`)
+		case "/users/2":
+			fmt.Fprint(w, `{"id":2,"name":"victim","email":"victim@example.com","phone":"+1 555 0100"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer target.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	cfg.Detection.InvalidSamples = 1
+	cfg.Detection.Confirmations = 0
+	sc := scanner.New(client.NewSmartClient(cfg), cfg, scanner.Options{
+		URL:      target.URL + "/users/{ID}",
+		Cookies:  "sid=attacker",
+		Payloads: []string{"2"},
+		PII:      true,
+		CORS:     true,
+	})
+	// An IDOR on another endpoint of the same report is not readable here
+	other := sc.Reporter.AddFinding(&fuzzer.FuzzResult{Job: &fuzzer.FuzzJob{URL: target.URL + "/orders/7", Method: "GET", Payload: "7"}})
+	if err := sc.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var idor bool
+	var techniques []string
+	for _, f := range sc.Reporter.Snapshot() {
+		switch f.Type {
+		case reporter.FindingIDOR:
+			idor = idor || f != other
+		case reporter.FindingCORS:
+			techniques = append(techniques, f.Technique)
+			if f.Severity != "HIGH" && f.Severity != "CRITICAL" || !strings.Contains(f.Evidence, "IDOR findings") {
+				t.Errorf("expected the CORS finding to be raised by the IDOR, got %s: %s", f.Severity, f.Evidence)
+			}
+			if strings.Contains(f.Evidence, "#"+other.ID+",") {
+				t.Errorf("expected only the IDOR findings of the CORS URL, got %s", f.Evidence)
+			}
+		}
+	}
+	if !idor {
+		t.Fatal("expected an IDOR finding for the victim")
+	}
+	slices.Sort(techniques)
+	if want := []string{"target as prefix", "target as suffix"}; !slices.Equal(techniques, want) {
+		t.Errorf("expected the origins passing the substring check, got %v", techniques)
+	}
+}
+
+func TestScanMinConfidenceZero(t *testing.T) {
+	cfg := utils.DefaultConfig()
+	c := client.NewSmartClient(cfg)
+
+	if sc := scanner.New(c, cfg, scanner.Options{URL: "https://api.test/users/{ID}"}); sc.Options.MinConfidence == nil || *sc.Options.MinConfidence != cfg.Detection.MinConfidence {
+		t.Errorf("expected detection.min_confidence when unset, got %v", sc.Options.MinConfidence)
+	}
+	zero := 0
+	if sc := scanner.New(c, cfg, scanner.Options{URL: "https://api.test/users/{ID}", MinConfidence: &zero}); sc.Options.MinConfidence == nil || *sc.Options.MinConfidence != 0 {
+		t.Errorf("expected an explicit 0 to be kept, got %v", sc.Options.MinConfidence)
+	}
+}
+
+func TestScanRunsKnownIDsFirst(t *testing.T) {
+	var mu sync.Mutex
+	var fuzzed []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/users/")
+		if n, _ := strconv.Atoi(id); n < 1 || n > 2000 {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		fuzzed = append(fuzzed, id)
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer target.Close()
+
+	utils.SetOutput(io.Discard)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	cfg.Detection.InvalidSamples = 1
+	cfg.Detection.Confirmations = 0
+	sc := scanner.New(client.NewSmartClient(cfg), cfg, scanner.Options{
+		URL:        target.URL + "/users/{ID}",
+		Cookies:    "sid=attacker",
+		Payloads:   []string{"7", "1002", "8"},
+		SampleIDs:  []string{"1001", "1002", "1003"},
+		Threads:    1,
+		NoClassify: true,
+	})
+	if err := sc.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := []string{"1002", "1001", "1003", "7", "8"}; !slices.Equal(fuzzed, want) {
+		t.Errorf("expected the known IDs ahead of the listed ones, got %v, want %v", fuzzed, want)
+	}
+}
+
+func TestScanJSONLines(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/1":
+			fmt.Fprint(w, `{"id":1,"name":"attacker","email":"attacker@example.com"}`)
+		case "/users/2", "/users/3":
+			id := strings.TrimPrefix(r.URL.Path, "/users/")
+			fmt.Fprintf(w, `{"id":%s,"name":"victim %s","email":"victim%s@example.com","phone":"+1 555 010%s"}`, id, id, id, id)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer target.Close()
+
+	// As with --jsonl: findings on stdout, everything else on stderr
+	var stdout, stderr bytes.Buffer
+	utils.SetOutput(&stderr)
+	defer utils.SetOutput(os.Stdout)
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	cfg.Detection.InvalidSamples = 1
+	cfg.Detection.Confirmations = 0
+	sc := scanner.New(client.NewSmartClient(cfg), cfg, scanner.Options{
+		URL:      target.URL + "/users/{ID}",
+		Cookies:  "sid=attacker",
+		Payloads: []string{"2", "3", "4"},
+		PII:      true,
+	})
+	sc.Reporter.OnFinding = reporter.JSONLines(&stdout)
+	if err := sc.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 2 || len(sc.Reporter.Snapshot()) != 2 {
+		t.Fatalf("expected a line for each of the 2 findings, got %d lines for %d findings:\n%s", len(lines), len(sc.Reporter.Snapshot()), stdout.String())
+	}
+	for _, line := range lines {
+		var f reporter.Finding
+		if err := json.Unmarshal([]byte(line), &f); err != nil {
+			t.Fatalf("not a JSON line: %q: %v", line, err)
+		}
+		if f.Type != reporter.FindingIDOR || (f.Payload != "2" && f.Payload != "3") {
+			t.Errorf("unexpected finding %+v", f)
+		}
+	}
+	if stderr.Len() == 0 {
+		t.Error("expected the logs on stderr")
+	}
+}
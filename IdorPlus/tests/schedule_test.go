@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/scanner"
+	"idorplus/pkg/schedule"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+)
+
+func TestCronSchedule(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	from := at("2026-03-13 10:07") // a Friday
+
+	for spec, want := range map[string]string{
+		"*/15 * * * *":      "2026-03-13 10:15",
+		"0 3 * * *":         "2026-03-14 03:00",
+		"30 9-18 * * 1-5":   "2026-03-13 10:30",
+		"0 9 * * mon":       "2026-03-16 09:00",
+		"0 0 1 * *":         "2026-04-01 00:00",
+		"0 0 13 * 1":        "2026-03-16 00:00", // day of month or day of week
+		"@daily":            "2026-03-14 00:00",
+		"@every 90m":        "2026-03-13 11:37",
+		"0 12 29 feb *":     "2028-02-29 12:00",
+		"5,10 22 * dec sun": "2026-12-06 22:05",
+	} {
+		s, err := schedule.Parse(spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(at(want)) {
+			t.Errorf("%q: next run %s, want %s", spec, got.Format("2006-01-02 15:04"), want)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "0 0 30 2 *", "*/0 * * * *", "@fortnightly", "@every 10s"} {
+		if _, err := schedule.Parse(spec); err == nil {
+			t.Errorf("Parse(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestScheduledRunNotifiesNewFindings(t *testing.T) {
+	// The second run exposes a field the first did not, a new finding
+	var run atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/users/"))
+		if id < 1 || id > 5 {
+			http.NotFound(w, r)
+			return
+		}
+		if run.Load() > 1 {
+			fmt.Fprintf(w, `{"id":%d,"email":"user%d@example.com","ssn":"123-45-678%d"}`, id, id, id)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%d,"email":"user%d@example.com"}`, id, id)
+	}))
+	defer target.Close()
+
+	var mu sync.Mutex
+	var alerts []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]string
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		alerts = append(alerts, msg["text"])
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	dir := t.TempDir()
+	// Threads also raise the request rate, keeping three scans quick
+	cfg := &utils.Config{
+		Scanner:   utils.ScannerConfig{Threads: 32, Delay: "0s"},
+		Detection: utils.DetectionConfig{Threshold: 0.8},
+		Notify: utils.NotifyConfig{
+			MinSeverity: "LOW",
+			Webhooks:    []utils.WebhookConfig{{Type: "slack", URL: webhook.URL}},
+		},
+	}
+	opts := scanner.Options{URL: target.URL + "/users/{ID}", Count: 10, Cookies: "sid=attacker"}
+	if _, err := scanner.SaveProfile(filepath.Join(dir, "users.yaml"), opts, cfg); err != nil {
+		t.Fatalf("SaveProfile: %v", err)
+	}
+	t.Setenv("IDORPLUS_COOKIES", "sid=attacker")
+	t.Setenv("IDORPLUS_WEBHOOK_1", webhook.URL)
+
+	crontab := filepath.Join(dir, "scans.cron")
+	os.WriteFile(crontab, []byte("# nightly\n0 3 * * *  users.yaml\n"), 0644)
+	entries, err := schedule.ParseCrontab(crontab)
+	if err != nil {
+		t.Fatalf("ParseCrontab: %v", err)
+	}
+
+	db, err := store.Open(filepath.Join(dir, "schedule.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+	sched, err := schedule.New(schedule.Config{
+		NewClient: func(cfg *utils.Config) (*client.SmartClient, error) { return client.NewSmartClient(cfg), nil },
+		Store:     db,
+	}, entries)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	alertsAfter := func(n int32) int {
+		run.Store(n)
+		r, err := sched.RunEntry(context.Background(), entries[0])
+		if err != nil {
+			t.Fatalf("run %d: %v", n, err)
+		}
+		if r.Findings < 5 || r.ScanID == 0 {
+			t.Fatalf("run %d: expected a stored scan with a finding per user, got %+v", n, r)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return len(alerts)
+	}
+
+	if got := alertsAfter(0); got != 1 {
+		t.Errorf("first run: expected one alert for the new finding class, got %d", got)
+	}
+	if got := alertsAfter(1); got != 1 {
+		t.Errorf("unchanged rerun: expected no new alerts, got %d in total", got)
+	}
+	if got := alertsAfter(2); got != 2 {
+		t.Errorf("rerun with new exposure: expected one new alert, got %d in total", got)
+	}
+	if scans, _ := db.Scans(store.Filter{}); len(scans) != 3 {
+		t.Errorf("expected every run stored, got %d scans", len(scans))
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"idorplus/pkg/idorplus"
+	"idorplus/pkg/script"
+)
+
+const signingScript = `
+SECRET = "k3y"
+
+def on_request(req):
+    path = "/" + req["url"].split("/", 3)[3]
+    req["headers"]["X-Sig"] = hmac_sha256(SECRET, path)
+
+def on_response(req, resp):
+    if resp["status"] != 200:
+        return False
+    resp["body"] = base64_decode(resp["body"])
+    return json.decode(resp["body"])["owner"] != "attacker"
+`
+
+func TestScriptSignsRequestsAndDecidesVerdict(t *testing.T) {
+	// The API rejects unsigned requests and base64-encodes its responses
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mac := hmac.New(sha256.New, []byte("k3y"))
+		mac.Write([]byte(r.URL.Path))
+		if r.Header.Get("X-Sig") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		owner := "attacker"
+		if id := strings.TrimPrefix(r.URL.Path, "/docs/"); id == "7" || id == "9" {
+			owner = "victim" + id
+		}
+		fmt.Fprint(w, base64.StdEncoding.EncodeToString([]byte(`{"owner":"`+owner+`"}`)))
+	}))
+	defer target.Close()
+
+	if _, err := script.Load("bad.star", "def on_request(a, b):\n    pass\n"); err == nil {
+		t.Error("expected an error for a hook with the wrong arity")
+	}
+
+	idorplus.SetLogOutput(io.Discard)
+	defer idorplus.SetLogOutput(os.Stdout)
+
+	s, _ := idorplus.New(idorplus.Options{
+		IDs:    []string{"5", "6", "7", "8", "9"},
+		Script: signingScript,
+	})
+	findings, _, err := s.Scan(context.Background(), idorplus.Target{URL: target.URL + "/docs/{ID}", Cookies: "session=attacker"})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d: %+v", len(findings), findings)
+	}
+	for _, f := range findings {
+		if f.ID != "7" && f.ID != "9" {
+			t.Errorf("unexpected finding for ID %s", f.ID)
+		}
+		if !strings.Contains(f.Evidence, `"owner":"victim`) {
+			t.Errorf("expected decoded evidence, got %q", f.Evidence)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/server"
+	"idorplus/pkg/store"
+	"idorplus/pkg/utils"
+)
+
+func TestServerRunsSubmittedScan(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/users/"))
+		if id < 1 || id > 5 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%d,"email":"user%d@example.com"}`, id, id)
+	}))
+	defer target.Close()
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "server.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	srv, err := server.New(server.Config{
+		Scan: &utils.Config{
+			Scanner:   utils.ScannerConfig{Threads: 4, Delay: "0s"},
+			Detection: utils.DetectionConfig{Threshold: 0.8},
+		},
+		NewClient: func(cfg *utils.Config) (*client.SmartClient, error) { return client.NewSmartClient(cfg), nil },
+		Store:     db,
+		Token:     "secret",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); srv.Wait() }()
+	srv.Start(ctx)
+
+	api := httptest.NewServer(srv)
+	defer api.Close()
+
+	call := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+
+	if resp, _ := http.Get(api.URL + "/api/scans"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	resp := call("POST", "/api/scans", `{"url":"`+target.URL+`/users/{ID}","count":10,"cookies":"sid=attacker"}`)
+	var job server.Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || job.ID == "" {
+		t.Fatalf("submit: status %d, job %+v", resp.StatusCode, job)
+	}
+	if job.Options.Cookies != "[redacted]" {
+		t.Errorf("expected cookies to be redacted, got %q", job.Options.Cookies)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for job.Status != server.StatusDone {
+		if job.Status == server.StatusFailed || time.Now().After(deadline) {
+			t.Fatalf("scan did not finish: %+v", job)
+		}
+		time.Sleep(50 * time.Millisecond)
+		resp := call("GET", "/api/scans/"+job.ID, "")
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+	}
+
+	resp = call("GET", "/api/scans/"+job.ID+"/findings", "")
+	var findings []map[string]any
+	json.NewDecoder(resp.Body).Decode(&findings)
+	resp.Body.Close()
+	if len(findings) < 5 || len(findings) != job.Findings {
+		t.Errorf("expected a finding per existing user, got %d (job reports %d)", len(findings), job.Findings)
+	}
+
+	resp = call("GET", "/metrics", "")
+	metrics, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		"# TYPE idorplus_requests_total counter",
+		`idorplus_jobs_finished_total{status="done"} 1`,
+		"idorplus_jobs_running 0",
+		"idorplus_requests_in_flight 0",
+	} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("metrics lack %q:\n%s", want, metrics)
+		}
+	}
+	if !regexp.MustCompile(`(?m)^idorplus_requests_total ([1-9]\d+)$`).Match(metrics) {
+		t.Errorf("requests of the finished scan not counted:\n%s", metrics)
+	}
+	if !strings.Contains(string(metrics), "idorplus_findings_total{severity=") {
+		t.Errorf("findings not counted:\n%s", metrics)
+	}
+
+	stored, _ := db.Jobs()
+	if len(stored) != 1 || stored[0].Status != server.StatusDone || stored[0].ScanID == 0 {
+		t.Errorf("job not persisted as done: %+v", stored)
+	}
+}
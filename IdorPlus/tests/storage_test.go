@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"idorplus/pkg/client"
+	"idorplus/pkg/storage"
+	"idorplus/pkg/utils"
+)
+
+func TestFindStorageObjects(t *testing.T) {
+	body := `{"invoice":"https:\/\/acme-files.s3.eu-west-1.amazonaws.com\/invoices\/00041.pdf?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Signature=abc",
+	"avatar":"https://storage.googleapis.com/acme-avatars/users/7.png",
+	"export":"https://acmeprod.blob.core.windows.net/exports/2024/report.csv?sv=2021&sig=xyz",
+	"legacy":"https://s3.amazonaws.com/acme-old/docs/1.txt",
+	"other":"https://example.com/files/1.pdf"}`
+	objects := storage.FindObjects(body)
+	if len(objects) != 4 {
+		t.Fatalf("expected 4 storage URLs, got %+v", objects)
+	}
+	byProvider := make(map[string]storage.Object)
+	for _, obj := range objects {
+		byProvider[obj.Provider+" "+obj.Bucket] = obj
+	}
+	if s3 := byProvider["s3 acme-files"]; s3.Key != "invoices/00041.pdf" || !s3.Signed || s3.Base != "https://acme-files.s3.eu-west-1.amazonaws.com" {
+		t.Errorf("unexpected S3 object %+v", s3)
+	}
+	if old := byProvider["s3 acme-old"]; old.Key != "docs/1.txt" || old.Base != "https://s3.amazonaws.com/acme-old" {
+		t.Errorf("unexpected path-style S3 object %+v", old)
+	}
+	if gcs := byProvider["gcs acme-avatars"]; gcs.Key != "users/7.png" || gcs.Signed {
+		t.Errorf("unexpected GCS object %+v", gcs)
+	}
+	if az := byProvider["azure acmeprod/exports"]; az.Key != "2024/report.csv" || !az.Signed {
+		t.Errorf("unexpected Azure object %+v", az)
+	}
+
+	keys := storage.PredictKeys("invoices/2024/00041.pdf", nil)
+	if !slices.Equal(keys, []string{"invoices/2024/00040.pdf", "invoices/2024/00042.pdf", "invoices/2024/00039.pdf", "invoices/2024/00043.pdf", "invoices/2024/00038.pdf", "invoices/2024/00044.pdf"}) {
+		t.Errorf("unexpected predicted keys %v", keys)
+	}
+}
+
+func TestStorageTester(t *testing.T) {
+	// The bucket lists and serves invoices without a signature
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/bucket/" && r.URL.Query().Get("list-type") == "2":
+			fmt.Fprint(w, `<?xml version="1.0"?><ListBucketResult><Name>bucket</Name><Contents><Key>invoices/00040.pdf</Key></Contents><Contents><Key>invoices/00041.pdf</Key></Contents></ListBucketResult>`)
+		case r.URL.Path == "/bucket/invoices/00041.pdf", r.URL.Path == "/bucket/invoices/00042.pdf":
+			fmt.Fprint(w, "%PDF-1.4")
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code></Error>`)
+		}
+	}))
+	defer server.Close()
+
+	cfg := utils.DefaultConfig()
+	cfg.Scanner.Delay = "0ms"
+	obj := storage.Object{
+		Provider: storage.ProviderS3,
+		Bucket:   "bucket",
+		Key:      "invoices/00041.pdf",
+		Base:     server.URL + "/bucket",
+		URL:      server.URL + "/bucket/invoices/00041.pdf?X-Amz-Signature=abc",
+		Signed:   true,
+	}
+	result, err := storage.NewTester(client.NewSmartClient(cfg)).Test(context.Background(), obj, []string{"42", "43"})
+	if err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	if !result.Listable || !slices.Equal(result.Keys, []string{"invoices/00040.pdf", "invoices/00041.pdf"}) {
+		t.Errorf("expected the listing's keys without the bucket name, got %v", result.Keys)
+	}
+	if result.Unsigned == nil || !result.Unsigned.Accessible || strings.Contains(result.Unsigned.URL, "Signature") {
+		t.Errorf("expected the object readable without its signature, got %+v", result.Unsigned)
+	}
+	if len(result.Enumerated) != 2 || !result.Enumerated[0].Accessible || result.Enumerated[1].Accessible {
+		t.Errorf("expected 00042 readable and 00043 denied, got %+v", result.Enumerated)
+	}
+	if !result.Vulnerable() {
+		t.Error("expected the bucket to be vulnerable")
+	}
+}
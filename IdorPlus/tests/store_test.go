@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"idorplus/pkg/fuzzer"
+	"idorplus/pkg/reporter"
+	"idorplus/pkg/store"
+)
+
+func TestStoreQueries(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	scanID, err := db.BeginScan("https://api.example.com/users/{ID}", time.Now())
+	if err != nil {
+		t.Fatalf("BeginScan: %v", err)
+	}
+
+	var results []*fuzzer.FuzzResult
+	for i, status := range []int{200, 404, 404, 200, 403} {
+		results = append(results, &fuzzer.FuzzResult{
+			Job:          &fuzzer.FuzzJob{URL: "https://api.example.com/users/" + string(rune('1'+i)), Method: "GET"},
+			StatusCode:   status,
+			IsVulnerable: status == 200,
+		})
+	}
+	if err := db.SaveResults(scanID, results); err != nil {
+		t.Fatalf("SaveResults: %v", err)
+	}
+	if err := db.SaveFindings(scanID, []*reporter.Finding{
+		{ID: "1", Type: reporter.FindingIDOR, URL: "https://api.example.com/users/1", Severity: "HIGH", Timestamp: time.Now()},
+		{ID: "2", Type: reporter.FindingIDOR, URL: "https://api.example.com/users/4", Severity: "LOW", Timestamp: time.Now()},
+	}); err != nil {
+		t.Fatalf("SaveFindings: %v", err)
+	}
+	db.FinishScan(scanID)
+
+	notFound, _ := db.Results(store.Filter{StatusCode: 404})
+	if len(notFound) != 2 {
+		t.Errorf("expected 2 results with status 404, got %d", len(notFound))
+	}
+	vulnerable, _ := db.Results(store.Filter{Target: "api.example.com", Vulnerable: true})
+	if len(vulnerable) != 2 {
+		t.Errorf("expected 2 vulnerable results, got %d", len(vulnerable))
+	}
+	high, _ := db.Findings(store.Filter{Severity: "high"})
+	if len(high) != 1 || high[0].URL != "https://api.example.com/users/1" {
+		t.Errorf("unexpected HIGH findings: %+v", high)
+	}
+	future, _ := db.Findings(store.Filter{Since: time.Now().Add(time.Hour)})
+	if len(future) != 0 {
+		t.Errorf("expected no findings after since, got %d", len(future))
+	}
+
+	sc, err := db.GetScan(scanID)
+	if err != nil || sc.Results != 5 || sc.Findings != 2 || sc.FinishedAt.IsZero() {
+		t.Errorf("unexpected scan: %+v (%v)", sc, err)
+	}
+}